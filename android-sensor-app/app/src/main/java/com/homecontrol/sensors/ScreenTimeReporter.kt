@@ -0,0 +1,94 @@
+package com.homecontrol.sensors
+
+import android.app.AppOpsManager
+import android.app.usage.UsageStatsManager
+import android.content.Context
+import android.content.pm.PackageManager
+import android.os.Build
+import android.os.Process
+import android.provider.Settings
+import android.util.Log
+import org.json.JSONArray
+import org.json.JSONObject
+import java.util.Calendar
+
+/**
+ * Builds daily screen time reports from Android usage stats for /api/screentime/report.
+ * Requires usage access, granted once via:
+ *   adb shell appops set com.homecontrol.sensors GET_USAGE_STATS allow
+ */
+object ScreenTimeReporter {
+    private const val TAG = "ScreenTimeReporter"
+
+    // Ignore apps used for less than this (launchers flashing by, system UI)
+    private const val MIN_FOREGROUND_MS = 60_000L
+
+    fun hasUsageAccess(context: Context): Boolean {
+        val appOps = context.getSystemService(Context.APP_OPS_SERVICE) as AppOpsManager
+        @Suppress("DEPRECATION")
+        val mode = appOps.checkOpNoThrow(AppOpsManager.OPSTR_GET_USAGE_STATS, Process.myUid(), context.packageName)
+        return mode == AppOpsManager.MODE_ALLOWED
+    }
+
+    /** Device name reported to the server (user-set device name, falling back to the model) */
+    fun deviceName(context: Context): String {
+        if (Build.VERSION.SDK_INT >= Build.VERSION_CODES.N_MR1) {
+            Settings.Global.getString(context.contentResolver, Settings.Global.DEVICE_NAME)?.let {
+                if (it.isNotBlank()) return it
+            }
+        }
+        return Build.MODEL
+    }
+
+    /**
+     * Today's cumulative foreground time per app as report JSON, or null if usage
+     * access hasn't been granted.
+     */
+    fun buildReport(context: Context): String? {
+        if (!hasUsageAccess(context)) {
+            Log.w(TAG, "Usage access not granted, skipping screen time report")
+            return null
+        }
+
+        val midnight = Calendar.getInstance().apply {
+            set(Calendar.HOUR_OF_DAY, 0)
+            set(Calendar.MINUTE, 0)
+            set(Calendar.SECOND, 0)
+            set(Calendar.MILLISECOND, 0)
+        }.timeInMillis
+        val now = System.currentTimeMillis()
+
+        val usageStatsManager = context.getSystemService(Context.USAGE_STATS_SERVICE) as UsageStatsManager
+        val stats = usageStatsManager.queryAndAggregateUsageStats(midnight, now)
+        val pm = context.packageManager
+
+        val apps = JSONArray()
+        var totalMs = 0L
+        stats.values
+            .filter { it.totalTimeInForeground >= MIN_FOREGROUND_MS && it.packageName != context.packageName }
+            .sortedByDescending { it.totalTimeInForeground }
+            .forEach { usage ->
+                totalMs += usage.totalTimeInForeground
+                apps.put(JSONObject().apply {
+                    put("app", usage.packageName)
+                    put("name", appLabel(pm, usage.packageName))
+                    put("minutes", usage.totalTimeInForeground / 60000.0)
+                })
+            }
+
+        return JSONObject().apply {
+            put("device", deviceName(context))
+            put("source", "tablet")
+            put("totalMinutes", totalMs / 60000.0)
+            put("apps", apps)
+        }.toString()
+    }
+
+    private fun appLabel(pm: PackageManager, packageName: String): String {
+        return try {
+            pm.getApplicationLabel(pm.getApplicationInfo(packageName, 0)).toString()
+        } catch (e: PackageManager.NameNotFoundException) {
+            packageName
+        }
+    }
+}
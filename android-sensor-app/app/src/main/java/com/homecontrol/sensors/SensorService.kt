@@ -71,6 +71,7 @@ class SensorService : Service(), SensorEventListener {
     private var idleCheckJob: Job? = null
     private var heartbeatJob: Job? = null
     private var adbCheckJob: Job? = null
+    private var screenTimeJob: Job? = null
     private var lastReportedAdbPort: Int = 0
 
     companion object {
@@ -83,6 +84,7 @@ class SensorService : Service(), SensorEventListener {
         private const val PRESENCE_DETECT_THRESHOLD = 0.40f  // Detect presence at 40% drop from recent avg
         private const val WAKE_COOLDOWN_MS = 3000L  // Don't try to wake again for 3 seconds
         private const val LIGHT_HISTORY_SIZE = 20   // ~4 seconds of history at 5Hz sampling
+        private const val SCREEN_TIME_INTERVAL_MS = 15 * 60 * 1000L  // Report usage every 15 minutes
 
         // Default server URL
         const val DEFAULT_SERVER_URL = "http://192.168.69.229:8080"
@@ -153,6 +155,7 @@ class SensorService : Service(), SensorEventListener {
         startIdleCheck()
         startHeartbeat()
         startAdbCheck()
+        startScreenTimeReports()
 
         // Start the HTTP command server for remote shell execution
         startCommandServer()
@@ -175,6 +178,7 @@ class SensorService : Service(), SensorEventListener {
         idleCheckJob?.cancel()
         heartbeatJob?.cancel()
         adbCheckJob?.cancel()
+        screenTimeJob?.cancel()
         scope.cancel()
         stopCommandServer()
         releaseWakeLocks()
@@ -390,6 +394,16 @@ class SensorService : Service(), SensorEventListener {
         }
     }
 
+    private fun startScreenTimeReports() {
+        screenTimeJob?.cancel()
+        screenTimeJob = scope.launch {
+            while (isActive) {
+                reportScreenTime()
+                delay(SCREEN_TIME_INTERVAL_MS)
+            }
+        }
+    }
+
     private fun startCommandServer() {
         try {
             commandServer = CommandServer(this, COMMAND_SERVER_PORT).apply {
@@ -976,6 +990,22 @@ class SensorService : Service(), SensorEventListener {
         }
     }
 
+    private fun reportScreenTime() {
+        if (serverUrl.isEmpty()) return
+
+        try {
+            val json = ScreenTimeReporter.buildReport(this) ?: return
+            val request = Request.Builder()
+                .url("$serverUrl/api/screentime/report")
+                .post(json.toRequestBody("application/json".toMediaType()))
+                .build()
+
+            client.newCall(request).execute().close()
+        } catch (e: Exception) {
+            Log.e(TAG, "Failed to report screen time: ${e.message}")
+        }
+    }
+
     private fun createNotificationChannel() {
         if (Build.VERSION.SDK_INT >= Build.VERSION_CODES.O) {
             val channel = NotificationChannel(
@@ -0,0 +1,376 @@
+// Command loadgen simulates a fleet of kiosk tablets against a running server
+// and reports request latency percentiles per endpoint.
+//
+// Each simulated tablet polls the same endpoints as the web UI on the same
+// schedule, keeps a WebSocket open, and optionally fires control actions:
+//
+//	go run ./cmd/loadgen -url http://localhost:8080 -clients 20 -duration 5m
+//	go run ./cmd/loadgen -clients 5 -actions "POST /api/toggle/switch.test_fan" -action-interval 30s
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// poll is an endpoint the UI refreshes on an interval
+type poll struct {
+	path     string
+	interval time.Duration
+}
+
+// defaultPolls mirrors the refresh timers in static/js and templates
+var defaultPolls = []poll{
+	{"/api/hue/rooms", 1 * time.Second},            // hue.js loadRooms
+	{"/api/spotify/playback", 5 * time.Second},     // spotify.js loadSpotifyPlayback
+	{"/api/entities", 30 * time.Second},            // entities.js refreshEntityStates
+	{"/api/calendar/events?view=day", time.Minute}, // calendar.html checkForEventUpdates
+	{"/api/tablet/sensor/state", 2 * time.Second},  // screensaver.js proximity fallback
+	{"/api/weather", 5 * time.Minute},              // weather.js
+	{"/api/suggestions", 5 * time.Minute},          // quick-action bar
+}
+
+// action is a control request fired at random by simulated tablets
+type action struct {
+	method string
+	path   string
+}
+
+// stats collects latencies per endpoint
+type stats struct {
+	mu        sync.Mutex
+	latencies map[string][]time.Duration
+	errors    map[string]int
+	wsConns   atomic.Int64
+	wsEvents  atomic.Int64
+	wsErrors  atomic.Int64
+}
+
+func newStats() *stats {
+	return &stats{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]int),
+	}
+}
+
+func (s *stats) record(key string, d time.Duration, err bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err {
+		s.errors[key]++
+		return
+	}
+	s.latencies[key] = append(s.latencies[key], d)
+}
+
+func main() {
+	baseURL := flag.String("url", "http://localhost:8080", "Server base URL")
+	clients := flag.Int("clients", 10, "Number of simulated tablets")
+	duration := flag.Duration("duration", time.Minute, "How long to run (0 = until interrupted)")
+	speed := flag.Float64("speed", 1, "Poll rate multiplier (2 = poll twice as often as the UI)")
+	actionList := flag.String("actions", "", "Comma-separated control actions, e.g. \"POST /api/toggle/switch.test\"")
+	actionInterval := flag.Duration("action-interval", time.Minute, "Average time between actions per tablet")
+	token := flag.String("token", os.Getenv("API_TOKEN"), "API token sent as a Bearer token (defaults to $API_TOKEN)")
+	noWS := flag.Bool("no-ws", false, "Don't open WebSocket connections")
+	reportEvery := flag.Duration("report", 30*time.Second, "Interval between progress reports")
+	flag.Parse()
+
+	if *clients <= 0 || *speed <= 0 {
+		log.Fatal("clients and speed must be positive")
+	}
+	actions, err := parseActions(*actionList)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	st := newStats()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+
+	httpClient := &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			MaxIdleConnsPerHost: *clients * 2,
+		},
+	}
+
+	log.Printf("Starting %d simulated tablets against %s", *clients, *baseURL)
+	for i := 0; i < *clients; i++ {
+		t := &tablet{
+			id:      fmt.Sprintf("loadgen-%03d", i),
+			baseURL: strings.TrimRight(*baseURL, "/"),
+			client:  httpClient,
+			token:   *token,
+			stats:   st,
+			stop:    stop,
+		}
+		for _, p := range defaultPolls {
+			wg.Add(1)
+			go func(p poll) {
+				defer wg.Done()
+				t.pollLoop(p.path, time.Duration(float64(p.interval) / *speed))
+			}(p)
+		}
+		if !*noWS {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				t.wsLoop()
+			}()
+		}
+		if len(actions) > 0 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				t.actionLoop(actions, *actionInterval)
+			}()
+		}
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	var deadline <-chan time.Time
+	if *duration > 0 {
+		deadline = time.After(*duration)
+	}
+	ticker := time.NewTicker(*reportEvery)
+	defer ticker.Stop()
+
+	started := time.Now()
+loop:
+	for {
+		select {
+		case <-ticker.C:
+			log.Printf("%s elapsed, %d WebSocket connections, %d events received",
+				time.Since(started).Round(time.Second), st.wsConns.Load(), st.wsEvents.Load())
+		case <-deadline:
+			break loop
+		case <-interrupt:
+			break loop
+		}
+	}
+
+	close(stop)
+	wg.Wait()
+	report(st, time.Since(started))
+}
+
+func parseActions(s string) ([]action, error) {
+	var result []action
+	for _, item := range strings.Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		method, path, ok := strings.Cut(item, " ")
+		if !ok || !strings.HasPrefix(path, "/") {
+			return nil, fmt.Errorf("invalid action %q (want \"METHOD /path\")", item)
+		}
+		result = append(result, action{method: strings.ToUpper(method), path: strings.TrimSpace(path)})
+	}
+	return result, nil
+}
+
+// tablet is one simulated kiosk
+type tablet struct {
+	id      string
+	baseURL string
+	client  *http.Client
+	token   string
+	stats   *stats
+	stop    chan struct{}
+}
+
+// pollLoop requests path every interval, starting at a random offset so
+// tablets don't all fire in lockstep
+func (t *tablet) pollLoop(path string, interval time.Duration) {
+	if !t.sleep(time.Duration(rand.Int63n(int64(interval)))) {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		t.do(http.MethodGet, path)
+		select {
+		case <-ticker.C:
+		case <-t.stop:
+			return
+		}
+	}
+}
+
+// actionLoop fires a random action at exponentially distributed intervals
+func (t *tablet) actionLoop(actions []action, mean time.Duration) {
+	for {
+		wait := time.Duration(rand.ExpFloat64() * float64(mean))
+		if !t.sleep(wait) {
+			return
+		}
+		a := actions[rand.Intn(len(actions))]
+		t.do(a.method, a.path)
+	}
+}
+
+// wsLoop keeps a WebSocket connection open, reconnecting on failure
+func (t *tablet) wsLoop() {
+	u, err := url.Parse(t.baseURL)
+	if err != nil {
+		return
+	}
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	u.Path = "/ws"
+
+	for {
+		conn, _, err := websocket.DefaultDialer.Dial(u.String(), t.headers())
+		if err != nil {
+			t.stats.wsErrors.Add(1)
+			if !t.sleep(5 * time.Second) {
+				return
+			}
+			continue
+		}
+		t.stats.wsConns.Add(1)
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return
+				}
+				t.stats.wsEvents.Add(1)
+			}
+		}()
+
+		// Keepalive pings like websocket.js
+		ping := time.NewTicker(30 * time.Second)
+	session:
+		for {
+			select {
+			case <-ping.C:
+				if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"type":"ping"}`)); err != nil {
+					break session
+				}
+			case <-done:
+				t.stats.wsErrors.Add(1)
+				break session
+			case <-t.stop:
+				break session
+			}
+		}
+		ping.Stop()
+		conn.Close()
+		<-done
+		t.stats.wsConns.Add(-1)
+
+		select {
+		case <-t.stop:
+			return
+		default:
+		}
+	}
+}
+
+func (t *tablet) do(method, path string) {
+	key := method + " " + path
+	req, err := http.NewRequest(method, t.baseURL+path, nil)
+	if err != nil {
+		t.stats.record(key, 0, true)
+		return
+	}
+	req.Header = t.headers()
+
+	start := time.Now()
+	resp, err := t.client.Do(req)
+	if err != nil {
+		t.stats.record(key, 0, true)
+		return
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	elapsed := time.Since(start)
+
+	// 503 means the integration isn't configured, which still measures the handler
+	t.stats.record(key, elapsed, resp.StatusCode >= 500 && resp.StatusCode != http.StatusServiceUnavailable)
+}
+
+func (t *tablet) headers() http.Header {
+	h := http.Header{}
+	h.Set("X-Device-ID", t.id)
+	h.Set("User-Agent", "home-control-loadgen")
+	if t.token != "" {
+		h.Set("Authorization", "Bearer "+t.token)
+	}
+	return h
+}
+
+// sleep waits for d, returning false if the run was stopped
+func (t *tablet) sleep(d time.Duration) bool {
+	select {
+	case <-time.After(d):
+		return true
+	case <-t.stop:
+		return false
+	}
+}
+
+func report(st *stats, elapsed time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	keys := make(map[string]bool)
+	for k := range st.latencies {
+		keys[k] = true
+	}
+	for k := range st.errors {
+		keys[k] = true
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	fmt.Printf("\nRan for %s\n\n", elapsed.Round(time.Second))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "endpoint\trequests\terrors\treq/s\tp50\tp90\tp99\tmax\t")
+	for _, k := range sorted {
+		lat := st.latencies[k]
+		sort.Slice(lat, func(i, j int) bool { return lat[i] < lat[j] })
+		total := len(lat) + st.errors[k]
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.1f\t%s\t%s\t%s\t%s\t\n",
+			k, total, st.errors[k], float64(total)/elapsed.Seconds(),
+			percentile(lat, 0.50), percentile(lat, 0.90), percentile(lat, 0.99), percentile(lat, 1))
+	}
+	w.Flush()
+
+	fmt.Printf("\nWebSocket: %d events received, %d connection errors\n", st.wsEvents.Load(), st.wsErrors.Load())
+}
+
+// percentile returns the p-th percentile of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted)-1) * p)
+	return sorted[idx].Round(100 * time.Microsecond)
+}
@@ -2,34 +2,99 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
 	"log"
+	"mime"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"home_control/internal/actions"
 	"home_control/internal/adb"
+	"home_control/internal/alarms"
+	"home_control/internal/announce"
+	"home_control/internal/aquarium"
+	"home_control/internal/arrivalcam"
+	"home_control/internal/buttons"
+	"home_control/internal/cache"
 	"home_control/internal/calendar"
 	"home_control/internal/camera"
+	"home_control/internal/chores"
+	"home_control/internal/classroom"
+	"home_control/internal/clientlog"
+	"home_control/internal/countdowns"
+	"home_control/internal/daycontext"
 	"home_control/internal/drive"
+	"home_control/internal/egress"
+	"home_control/internal/emergency"
+	"home_control/internal/energy"
 	"home_control/internal/entertainment"
+	"home_control/internal/favorites"
+	"home_control/internal/flags"
+	"home_control/internal/geofence"
+	"home_control/internal/heating"
+	"home_control/internal/history"
 	"home_control/internal/homeassistant"
+	"home_control/internal/hotwater"
+	"home_control/internal/housemode"
 	"home_control/internal/hue"
 	"home_control/internal/icons"
+	"home_control/internal/ir"
+	"home_control/internal/items"
+	"home_control/internal/journal"
+	"home_control/internal/lighting"
+	"home_control/internal/lightning"
+	"home_control/internal/locks"
+	"home_control/internal/mailbox"
+	"home_control/internal/manual"
+	"home_control/internal/morning"
 	"home_control/internal/mqtt"
+	"home_control/internal/notes"
+	"home_control/internal/notify"
+	"home_control/internal/pantry"
+	"home_control/internal/party"
+	"home_control/internal/photos"
+	"home_control/internal/pool"
+	"home_control/internal/presence"
+	"home_control/internal/printer3d"
+	"home_control/internal/qr"
+	"home_control/internal/quiethours"
+	"home_control/internal/recipes"
+	"home_control/internal/routines"
+	"home_control/internal/scheduler"
+	"home_control/internal/screentime"
+	"home_control/internal/security"
+	"home_control/internal/shading"
+	"home_control/internal/sleeptimer"
+	"home_control/internal/sonos"
 	"home_control/internal/spotify"
+	"home_control/internal/store"
+	"home_control/internal/suggestions"
 
 	pahomqtt "github.com/eclipse/paho.mqtt.golang"
 	"home_control/internal/syncbox"
 	"home_control/internal/tasks"
+	"home_control/internal/timers"
+	"home_control/internal/vacuum"
+	"home_control/internal/vault"
+	"home_control/internal/visitors"
 	"home_control/internal/weather"
 	"home_control/internal/websocket"
 
@@ -95,44 +160,227 @@ func ConditionalLogger(next http.Handler) http.Handler {
 	})
 }
 
+// csrfCookieName is the double-submit cookie that must be echoed in the X-CSRF-Token header
+const csrfCookieName = "csrf_token"
+
+// csrfExemptPrefixes are paths that authenticate themselves (e.g. webhook secret)
+var csrfExemptPrefixes = []string{
+	"/api/webhook/",
+	"/api/party/join/", // Guest uploads carry the party token
+}
+
+// CSRFProtect is a middleware that blocks cross-site state-changing requests from browsers.
+// Browsers get a SameSite=Strict token cookie which must be sent back in the X-CSRF-Token
+// header on POST/PUT/PATCH/DELETE. Clients authenticated with API_TOKEN are exempt, as are
+// non-browser clients (tablet sensor app, curl) that send no Origin, cookies or fetch metadata.
+func CSRFProtect(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, err := r.Cookie(csrfCookieName)
+		if err != nil || cookie.Value == "" {
+			cookie = &http.Cookie{
+				Name:     csrfCookieName,
+				Value:    newCSRFToken(),
+				Path:     "/",
+				SameSite: http.SameSiteStrictMode,
+				Secure:   r.TLS != nil,
+				MaxAge:   365 * 24 * 60 * 60,
+			}
+			http.SetCookie(w, cookie)
+		}
+
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		for _, prefix := range csrfExemptPrefixes {
+			if strings.HasPrefix(r.URL.Path, prefix) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		if isAPITokenRequest(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// Reject outright if the browser tells us the request came from another site
+		if origin := r.Header.Get("Origin"); origin != "" {
+			if u, err := url.Parse(origin); err != nil || u.Host != r.Host {
+				log.Printf("CSRF: blocked %s %s from origin %s", r.Method, r.URL.Path, origin)
+				http.Error(w, "Cross-origin request blocked", http.StatusForbidden)
+				return
+			}
+		}
+		if site := r.Header.Get("Sec-Fetch-Site"); site == "cross-site" || site == "same-site" {
+			log.Printf("CSRF: blocked %s %s (Sec-Fetch-Site: %s)", r.Method, r.URL.Path, site)
+			http.Error(w, "Cross-origin request blocked", http.StatusForbidden)
+			return
+		}
+
+		// Non-browser clients don't carry any browser context; nothing for CSRF to ride on
+		isBrowser := r.Header.Get("Origin") != "" || r.Header.Get("Sec-Fetch-Site") != "" || r.Header.Get("Cookie") != ""
+		if !isBrowser {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := r.Header.Get("X-CSRF-Token")
+		if token == "" || subtle.ConstantTimeCompare([]byte(token), []byte(cookie.Value)) != 1 {
+			log.Printf("CSRF: missing or invalid token for %s %s", r.Method, r.URL.Path)
+			http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newCSRFToken generates a random token for the CSRF cookie
+func newCSRFToken() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Printf("Warning: Failed to generate CSRF token: %v", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// isAPITokenRequest reports whether the request carries a valid API_TOKEN bearer token
+func isAPITokenRequest(r *http.Request) bool {
+	if appConfig.APIToken == "" {
+		return false
+	}
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		token = r.Header.Get("X-API-Token")
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(appConfig.APIToken)) == 1
+}
+
 type Config struct {
 	Port               string
 	BaseURL            string
 	HomeAssistantURL   string
 	HomeAssistantToken string
-	Entities           []string
+	Entities           []string          // Entity IDs and domain:/area:/label: selectors (see configuredEntities)
+	EntityExclude      []string          // Entity IDs, globs or selectors dropped from Entities
+	HAServiceAllowlist []string          // "domain.service" or "domain.*" callable via /api/ha/service
+	LockPINs           map[string]string // lock entity ID (or "*" for all locks) -> PIN
+	EgressDoors        []string          // lock.* entities emergency mode unlocks
+	VisitorDoors       []string          // lock.* entities that may be unlocked for an expected visitor
+	VaultKey           string            // Encrypts the document vault; empty disables it
+	VaultPIN           string            // Unlocks the vault on a kiosk
+	PartyBaseURL       string            // Server URL guests' phones reach for party uploads (default: the kiosk's)
+	EgressInstructions string            // Shown on every tablet in emergency mode
+	PresenceEntities   []string          // person.*/device_tracker.* to track (default: those in HA_ENTITIES)
+	GeofencePeople     map[string]string // person ID in /api/geofence/{person} -> display name
+	GeofenceRadius     int               // Home zone radius in meters for raw phone locations
+	VacuumMaps         map[string]string // vacuum entity ID -> camera.*/image.* entity showing its map
 	GoogleClientID     string
 	GoogleClientSecret string
 	GoogleCalendars    []string
 	GooglePlacesAPIKey string
 	OpenWeatherAPIKey  string
+	GoogleWeatherKey   string // Weather, Air Quality and Pollen APIs (optional; Open-Meteo otherwise)
 	WeatherLat         float64
 	WeatherLon         float64
+	WeatherLocations   []weather.Location // Extra named locations besides home
 	Timezone           *time.Location
 	// MQTT settings
 	MQTTHost           string
 	MQTTPort           int
 	MQTTUsername       string
 	MQTTPassword       string
-	MQTTDoorbellTopics []string // Custom doorbell topics (optional)
+	MQTTDoorbellTopics []string               // Custom doorbell topics (optional)
+	MQTTPublishTopics  []string               // Topic filters (+/# wildcards) writable via /api/mqtt/publish; empty disables
+	ZigbeeBaseTopic    string                 // Zigbee2MQTT base topic; empty disables the Zigbee device registry
+	Appliances         []mqtt.ApplianceConfig // Coffee maker, dishwasher etc. via a Home Connect MQTT bridge
+	ValetudoRobots     map[string]string      // vacuum entity ID -> Valetudo MQTT base topic
+	BLETrackerTopic    string                 // ESPresense-style BLE sightings (<topic>/<tag>/<room>); empty disables
+	// Lightning alerts (Blitzortung strike feed, distances from WEATHER_LAT/LON)
+	LightningMQTTHost   string        // Empty disables lightning alerts
+	LightningRadiiKm    []float64     // Escalation radii, e.g. 30,15,8
+	LightningClearAfter time.Duration // Quiet period before the all clear
+	// Emergency alerts (non-weather NWS alerts, CAP/Nixle feeds) near WEATHER_LAT/LON
+	EmergencyNWS      bool     // Include non-weather NWS alerts (US only)
+	EmergencyFeeds    []string // CAP or Atom-of-CAP feed URLs
+	EmergencyRadiusKm float64  // Ignore alerts whose area is farther than this
+	// Morning briefing (daily notification with the day's highlights)
+	BriefingTime     string  // "HH:MM" local time; empty disables the notification
+	UVAlertThreshold float64 // Peak UV at or above this is mentioned in the briefing (0 = off)
+	UVAlertMessage   string  // Advice appended to the UV line
+	// Countdowns auto-created from calendar events containing this tag (empty disables)
+	CountdownKeyword string
+	// Pantry items expiring within this many days trigger a reminder (0 disables)
+	PantryExpiryDays int
+	// Lights the sleep timer turns off by default (hue:<group ID> or light.*)
+	SleepTimerLights []string
+	// Announcement rooms (room -> kiosk names) and the default pre-chime
+	AnnounceRooms map[string][]string
+	AnnounceChime string
+	// Pools and hot tubs: heater (climate/water_heater) and pump entities per body
+	PoolBodies []pool.Body
+	// Hot water (combi boiler) switch, climate or water_heater entity; empty disables
+	HotWaterEntity string
+	// Smart start: deferrable loads run in the cheapest window before a deadline
+	SmartStartLoads   []energy.Load
+	EnergyPriceSensor string        // HA sensor with a price forecast (Nord Pool and similar)
+	EnergyTariff      energy.Tariff // Fixed time-of-use prices, used without a price sensor
+	// Grid carbon intensity: carbonintensity (GB, region = postcode) or electricitymaps (region = zone)
+	CarbonProvider string
+	CarbonRegion   string
+	CarbonAPIKey   string
+	// Solar surplus rules: HA sensor with the grid export in W or kW; invert for
+	// grid power sensors that are negative while exporting
+	SolarExportSensor string
+	SolarExportInvert bool
+	// 3D printer monitoring through OctoPrint or Moonraker (Klipper); empty URL disables
+	Printer3DType     string // octoprint or moonraker
+	Printer3DURL      string
+	Printer3DAPIKey   string
+	Printer3DSnapshot string // Webcam snapshot URL (default: <url>/webcam/?action=snapshot)
+	Printer3DName     string
+	// Away-mode security incidents (needs presence tracking)
+	SecurityEntities []string      // Motion/door/person sensors (default: matching binary sensors in HA_ENTITIES)
+	SecurityGap      time.Duration // Signals closer together than this form one incident
+	// Mailbox contact sensor (binary_sensor.* or mqtt.*) for the postal delivery log
+	MailboxSensor string
 	// Camera settings
 	Cameras        map[string]string // name -> RTSP URL
 	FrigateHost    string            // Frigate server URL (optional, for camera proxying)
 	DoorbellCamera string            // Camera name for doorbell events (default: front_door)
 	// Webhook settings
 	WebhookSecret string // Optional secret for webhook authentication
+	// API token for non-browser clients (exempts requests from CSRF checks)
+	APIToken string
+	// TLS settings (optional). When a client CA is set, route groups listed in
+	// MTLSRouteGroups require a verified client certificate.
+	TLSCertFile     string
+	TLSKeyFile      string
+	TLSClientCAFile string
+	MTLSRouteGroups []string // e.g. "locks,cameras"
 	// Philips Hue settings
 	HueBridgeIP  string
 	HueUsername  string
 	HueClientKey string
+	// Adaptive lighting follows the sun at WEATHER_LAT/LON in Hue rooms and these HA lights
+	AdaptiveLights []string
 	// Sync Box settings (format: "name:ip:token,name2:ip2:token2")
 	SyncBoxes []SyncBoxConfig
 	// Google Drive settings (for screensaver and background photos)
 	DrivePhotosFolder string
+	// Google Photos album as the screensaver source (shares the Google token)
+	GooglePhotos bool
+	// Local directory (e.g. a bind-mounted NAS share) as the screensaver source
+	PhotosDir string
+	// Google Classroom students whose assignments are tracked (shares the Google token)
+	ClassroomStudents []classroom.Student
 	ScreensaverTimeout     int // Seconds of inactivity before screensaver (default: 300)
 	// Spotify settings
 	SpotifyClientID     string
 	SpotifyClientSecret string
+	SpotifyPlayerName   string   // Spotify Connect name of the kiosk's own web player (empty = disabled)
+	SpotifyAccounts     []string // Extra accounts besides "default", selected with ?account=
 	// Tablet ADB settings
 	TabletADBAddr          string
 	TabletProximityEnabled bool
@@ -143,14 +391,21 @@ type Config struct {
 	// Entertainment device settings
 	// Sony devices (soundbar + TV) format: "name:host:port:psk:type" (type = soundbar|tv)
 	SonyDevices []SonyDeviceConfig
-	// Nvidia Shield format: "name:host:port"
+	// Nvidia Shield format: "name:host:port[:transport]"
 	ShieldDevices []ShieldDeviceConfig
 	// Xbox format: "name:host:liveid"
 	XboxDevices       []XboxDeviceConfig
 	XboxRESTServerURL string // Optional: xbox-smartglass-rest server URL
 	// PS5 format: "name:deviceid:psnaccount"
-	PS5Devices    []PS5DeviceConfig
-	PS5MQTTTopic  string // Base MQTT topic for PS5-MQTT (default: homeassistant)
+	PS5Devices        []PS5DeviceConfig
+	PS5MQTTTopic      string // Base MQTT topic for PS5-MQTT (default: homeassistant)
+	PS5MQTTStateTopic string // PS5-MQTT device state topic prefix (default: ps5-mqtt)
+	// Sonos speaker IPs; rooms and groups are read from whichever answers first
+	SonosHosts []string
+	// IR devices format: "receiver=broadlink:192.168.1.50,projector=esphome:living_room_ir"
+	IRDevices []ir.Device
+	// Activity macros format: "Movie Night=step|step|...;Game Time=..."
+	EntertainmentActivities []entertainment.Activity
 }
 
 // SonyDeviceConfig holds configuration for a Sony device
@@ -164,9 +419,10 @@ type SonyDeviceConfig struct {
 
 // ShieldDeviceConfig holds configuration for an Nvidia Shield
 type ShieldDeviceConfig struct {
-	Name string
-	Host string
-	Port int
+	Name      string
+	Host      string
+	Port      int
+	Transport string // "adb" (default) or "remote" for the Android TV remote protocol
 }
 
 // XboxDeviceConfig holds configuration for an Xbox
@@ -185,9 +441,9 @@ type PS5DeviceConfig struct {
 
 // SyncBoxConfig holds configuration for a single Hue Sync Box
 type SyncBoxConfig struct {
-	Name        string
-	IP          string
-	AccessToken string
+	Name        string `json:"name"`
+	IP          string `json:"ip"`
+	AccessToken string `json:"accessToken"`
 }
 
 // CalendarPrefs stores user preferences for calendar display
@@ -210,20 +466,104 @@ type CalendarWithPrefs struct {
 }
 
 var haClient *homeassistant.Client
+var cardGrouping *homeassistant.GroupingStore
+
+// HA_ENTITIES with its selectors resolved; see resolveHAEntities
+var (
+	haEntitiesMu sync.RWMutex
+	haEntityIDs  []string
+)
 var hueClient *hue.Client
 var hueStreamer *hue.EntertainmentStreamer
-var syncBoxClients []*syncbox.Client
+var hueEvents *hue.EventStream
+var hueSensors *hue.SensorMonitor
+var adaptiveLighting *lighting.Scheduler
+var heatingCurve *heating.Manager
+var shadingManager *shading.Manager
+var (
+	syncBoxMu      sync.RWMutex // Guards the slices; boxes can be added at runtime
+	syncBoxClients []*syncbox.Client
+	syncBoxPollers []*syncbox.Poller
+	syncBoxDoc     *store.Doc // Boxes registered through /api/syncbox/register
+)
 var calClient *calendar.Client
 var tasksClient *tasks.Client
-var weatherClient *weather.Client
+var weatherClient *weather.Client // Home location
+var weatherLocations *weather.Locations
 var mqttClient *mqtt.Client
+var mqttSensors *mqtt.SensorBridge
+var zigbeeDevices *mqtt.Zigbee
+var appliances *mqtt.Appliances
+var valetudo *mqtt.Valetudo
+var itemTracker *items.Tracker
+var printer3dClient *printer3d.Client
+var vacuumRegions *vacuum.Manager
 var cameraManager *camera.Manager
 var driveClient *drive.Client
-var spotifyClient *spotify.Client
+var photosClient *photos.Client
+var photoFolder *photos.Folder
+var classroomClient *classroom.Client
+var spotifyClient *spotify.Client // The default account
+var spotifyDeviceSettings *spotify.DeviceSettingsStore
+
+// Spotify accounts by name, including spotifyClient as "default", and their
+// playback pollers. Both are filled at startup and only read afterwards.
+var (
+	spotifyClients      = make(map[string]*spotify.Client)
+	spotifyAccountNames []string
+	spotifyPollers      = make(map[string]*spotify.Poller)
+)
 var wsHub *websocket.Hub
+var announcements *announce.Queue
+var notesStore *notes.Store
+var choresManager *chores.Manager
+var countdownManager *countdowns.Manager
+var timerManager *timers.Manager
+var sleepTimer *sleeptimer.Timer
+var recipeBox *recipes.Manager
+var pantryManager *pantry.Manager
+var jobScheduler *scheduler.Scheduler
+var aquariums *aquarium.Manager
+var poolManager *pool.Manager
+var hotWater *hotwater.Manager
+var smartStart *energy.SmartStart
+var solarSurplus *energy.Surplus
+var carbonClient *energy.Carbon
+
+// Quiet hours volume cap for media players
+var quietHours *quiethours.Manager
+var houseMode *housemode.Manager
+var routineRunners = map[string]*routines.Runner{}
+var morningRoutine *morning.Routine
+var alarmManager *alarms.Manager
+var actionLog *actions.Log
+var favoritesStore *favorites.Store
+var buttonManager *buttons.Manager
 var appConfig Config
 var calendarPrefs *CalendarPrefs
-var calendarPrefsFile string
+var calendarPrefsDoc *store.Doc
+var dataStore *store.Store
+var sensorHistory *history.Recorder
+var clientLog *clientlog.Log
+var stateJournal *journal.Journal
+var lockGuard *locks.Guard
+var documentVault *vault.Vault
+var vaultGuard *locks.Guard
+var partyMode *party.Manager
+var egressMode *egress.Manager
+var visitorManager *visitors.Manager
+var houseManual *manual.Manager
+var notifyCenter *notify.Center
+var lightningMonitor *lightning.Monitor
+var emergencyMonitor *emergency.Monitor
+var presenceTracker *presence.Tracker
+var geofenceManager *geofence.Manager
+var arrivalCameras *arrivalcam.Manager
+var securityMonitor *security.Monitor
+var mailboxLog *mailbox.Log
+var screenTime *screentime.Tracker
+var dayContext *daycontext.Service
+var featureFlags *flags.Service
 var tabletClient *adb.Client
 var proximityMonitor *adb.ProximityMonitor
 var brightnessController *adb.BrightnessController
@@ -234,6 +574,19 @@ var shieldManager *entertainment.ShieldManager
 var xboxManager *entertainment.XboxManager
 var ps5Manager *entertainment.PS5Manager
 
+// Activity macros across entertainment devices
+var activityRunner *entertainment.ActivityRunner
+
+// Cached entertainment device states, kept fresh in the background
+var (
+	sonyStates   *entertainment.StatePoller[*entertainment.DeviceState]
+	shieldStates *entertainment.StatePoller[*entertainment.ShieldState]
+	xboxStates   *entertainment.StatePoller[*entertainment.XboxState]
+	ps5States    *entertainment.StatePoller[*entertainment.PS5State]
+)
+var sonosManager *sonos.Manager
+var irManager *ir.Manager
+
 // Sensor state from Android app (HCC)
 var sensorState struct {
 	sync.RWMutex
@@ -245,6 +598,33 @@ var sensorState struct {
 	IdleTimeoutSecs  int       // seconds before screen turns off (from app config)
 }
 
+// tabletClients holds what each kiosk page last reported about itself, for remote support
+var tabletClients = struct {
+	sync.RWMutex
+	reports map[string]*tabletClientReport // device ID -> report
+}{reports: make(map[string]*tabletClientReport)}
+
+// maxTabletClientErrors is how many recent errors are kept per device
+const maxTabletClientErrors = 50
+
+type tabletClientReport struct {
+	Device    string              `json:"device"`
+	Page      string              `json:"page"`
+	UserAgent string              `json:"userAgent"`
+	Viewport  string              `json:"viewport,omitempty"`
+	LastSeen  time.Time           `json:"lastSeen"`
+	Errors    []tabletClientError `json:"errors"` // Oldest first
+}
+
+type tabletClientError struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+	Source  string    `json:"source,omitempty"`
+	Line    int       `json:"line,omitempty"`
+	Column  int       `json:"column,omitempty"`
+	Stack   string    `json:"stack,omitempty"`
+}
+
 var tabletIdleTimeout = 180 * time.Second // default 180 seconds (3 minutes)
 
 // Calendar cache for faster page loads
@@ -257,6 +637,71 @@ var calendarCache struct {
 	Calendars        []CalendarWithPrefs
 	CalendarsUpdatedAt time.Time
 	CacheDuration    time.Duration
+	Counters         cache.Counters
+}
+
+// calendarCacheTunable exposes calendarCache to the cache registry
+type calendarCacheTunable struct{}
+
+func (calendarCacheTunable) Name() string { return "calendar" }
+
+func (calendarCacheTunable) Stats() cache.Stats {
+	calendarCache.RLock()
+	s := cache.Stats{
+		Name:        "calendar",
+		TTLSeconds:  calendarCache.CacheDuration.Seconds(),
+		Entries:     len(calendarCache.Events) + len(calendarCache.Calendars),
+		LastRefresh: calendarCache.EventsUpdatedAt,
+	}
+	calendarCache.RUnlock()
+	calendarCache.Counters.Fill(&s)
+	return s
+}
+
+func (calendarCacheTunable) SetTTL(ttl time.Duration) {
+	calendarCache.Lock()
+	calendarCache.CacheDuration = ttl
+	calendarCache.Unlock()
+}
+
+func (calendarCacheTunable) InvalidateAll() { invalidateCalendarCache() }
+
+// Short-lived caches for endpoints every tablet polls. TTLs are tunable via /api/admin/caches.
+var (
+	cacheRegistry = cache.NewRegistry()
+	hueRoomsCache = cache.New[[]*hue.Room]("hue", time.Second)
+	playbackCache = cache.New[*spotify.PlaybackState]("playback", time.Second)
+	syncBoxCache  = cache.New[*syncbox.Status]("syncbox", 2*time.Second)
+	areasCache    = cache.New[map[string]string]("areas", 10*time.Minute)
+	cacheTTLDoc   *store.Doc
+)
+
+// cacheInvalidations maps path prefixes to the cache that state-changing requests under them invalidate
+var cacheInvalidations = []struct {
+	prefix string
+	cache  cache.Tunable
+}{
+	{"/api/hue/", hueRoomsCache},
+	{"/api/spotify/", playbackCache},
+	{"/api/syncbox/", syncBoxCache},
+}
+
+// InvalidateCaches drops cached state after a control request so the next poll sees the change
+func InvalidateCaches(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r)
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			return
+		}
+		for _, inv := range cacheInvalidations {
+			if strings.HasPrefix(r.URL.Path, inv.prefix) {
+				inv.cache.InvalidateAll()
+			}
+		}
+		if poller := spotifyPollers[spotifyAccountName(r)]; poller != nil && strings.HasPrefix(r.URL.Path, "/api/spotify/") {
+			poller.Poke()
+		}
+	})
 }
 
 func init() {
@@ -288,6 +733,24 @@ func main() {
 		mqttDoorbellTopics = parseEntities(topicsStr)
 	}
 
+	// Parse lightning alert radii and all-clear delay
+	var lightningRadii []float64
+	for _, r := range parseEntities(getEnv("LIGHTNING_RADII_KM", "30,15,8")) {
+		if km, err := strconv.ParseFloat(r, 64); err == nil && km > 0 {
+			lightningRadii = append(lightningRadii, km)
+		}
+	}
+	lightningClearMins, _ := strconv.Atoi(getEnv("LIGHTNING_CLEAR_MINUTES", "30"))
+	emergencyRadius, _ := strconv.ParseFloat(getEnv("EMERGENCY_RADIUS_KM", "25"), 64)
+	securityGapMins, _ := strconv.Atoi(getEnv("SECURITY_INCIDENT_GAP_MINUTES", "5"))
+	if securityGapMins <= 0 {
+		securityGapMins = 5
+	}
+	pantryExpiryDays, _ := strconv.Atoi(getEnv("PANTRY_EXPIRY_DAYS", "3"))
+
+	// Parse UV threshold for the morning briefing
+	uvAlertThreshold, _ := strconv.ParseFloat(getEnv("UV_ALERT_THRESHOLD", "6"), 64)
+
 	// Parse cameras from environment
 	cameras := make(map[string]string)
 	// Support simple CAMERAS list (for Frigate-only setups)
@@ -316,31 +779,94 @@ func main() {
 		HomeAssistantURL:   getEnv("HA_URL", "http://homeassistant.local:8123"),
 		HomeAssistantToken: getEnv("HA_TOKEN", ""),
 		Entities:           parseEntities(getEnv("HA_ENTITIES", "")),
+		EntityExclude:      parseEntities(getEnv("HA_ENTITIES_EXCLUDE", "")),
+		HAServiceAllowlist: parseEntities(getEnv("HA_SERVICE_ALLOWLIST", "script.*,scene.turn_on,vacuum.*,media_player.*")),
+		LockPINs:           parseLockPINs(getEnv("LOCK_PINS", "")),
+		EgressDoors:        parseEntities(getEnv("EGRESS_DOORS", "")),
+		EgressInstructions: getEnv("EGRESS_INSTRUCTIONS", ""),
+		VisitorDoors:       parseEntities(getEnv("VISITOR_DOORS", "")),
+		VaultKey:           getEnv("VAULT_KEY", ""),
+		VaultPIN:           getEnv("VAULT_PIN", ""),
+		PartyBaseURL:       strings.TrimSuffix(getEnv("PARTY_BASE_URL", ""), "/"),
+		PresenceEntities:   parseEntities(getEnv("PRESENCE_ENTITIES", "")),
+		GeofencePeople:     parseEntityMap(getEnv("GEOFENCE_PEOPLE", "")),
+		GeofenceRadius:     parseIntEnv("GEOFENCE_HOME_RADIUS", geofence.DefaultHomeRadius),
+		VacuumMaps:         parseEntityMap(getEnv("VACUUM_MAPS", "")),
 		GoogleClientID:     getEnv("GOOGLE_CLIENT_ID", ""),
 		GoogleClientSecret: getEnv("GOOGLE_CLIENT_SECRET", ""),
 		GoogleCalendars:    parseEntities(getEnv("GOOGLE_CALENDARS", "")),
 		GooglePlacesAPIKey: getEnv("GOOGLE_PLACES_API_KEY", ""),
 		OpenWeatherAPIKey:  getEnv("OPENWEATHER_API_KEY", ""),
+		GoogleWeatherKey:   getEnv("GOOGLE_WEATHER_API_KEY", ""),
 		WeatherLat:         weatherLat,
 		WeatherLon:         weatherLon,
+		WeatherLocations:   parseWeatherLocations(getEnv("WEATHER_LOCATIONS", "")),
 		Timezone:           loc,
 		MQTTHost:           getEnv("MQTT_HOST", ""),
 		MQTTPort:           mqttPort,
 		MQTTUsername:       getEnv("MQTT_USERNAME", ""),
 		MQTTPassword:       getEnv("MQTT_PASSWORD", ""),
 		MQTTDoorbellTopics: mqttDoorbellTopics,
+		MQTTPublishTopics:  parseEntities(getEnv("MQTT_PUBLISH_ALLOWLIST", "")),
+		ZigbeeBaseTopic:    getEnv("ZIGBEE2MQTT_TOPIC", ""),
+		Appliances:         parseAppliances(getEnv("APPLIANCES", "")),
+		ValetudoRobots:     parseEntityMap(getEnv("VALETUDO_VACUUMS", "")),
+		BLETrackerTopic:    getEnv("BLE_TRACKER_TOPIC", ""),
+		LightningMQTTHost:   getEnv("LIGHTNING_MQTT_HOST", ""),
+		LightningRadiiKm:    lightningRadii,
+		LightningClearAfter: time.Duration(lightningClearMins) * time.Minute,
+		EmergencyNWS:        getEnv("EMERGENCY_NWS", "false") == "true",
+		EmergencyFeeds:      parseEntities(getEnv("EMERGENCY_FEEDS", "")),
+		EmergencyRadiusKm:   emergencyRadius,
+		BriefingTime:        getEnv("BRIEFING_TIME", "07:00"),
+		UVAlertThreshold:    uvAlertThreshold,
+		UVAlertMessage:      getEnv("UV_ALERT_MESSAGE", "sunscreen for the kids"),
+		CountdownKeyword:    getEnv("COUNTDOWN_KEYWORD", "#countdown"),
+		PantryExpiryDays:    pantryExpiryDays,
+		SleepTimerLights:    parseEntities(getEnv("SLEEP_TIMER_LIGHTS", "")),
+		AnnounceRooms:       parseAnnounceRooms(getEnv("ANNOUNCE_ROOMS", "")),
+		AnnounceChime:       getEnv("ANNOUNCE_CHIME", "chime"),
+		PoolBodies:          parsePoolBodies(getEnv("POOL_HEATERS", ""), getEnv("POOL_PUMPS", "")),
+		HotWaterEntity:      getEnv("HOTWATER_ENTITY", ""),
+		SmartStartLoads:     parseSmartStartLoads(getEnv("SMART_START_LOADS", "")),
+		EnergyPriceSensor:   getEnv("ENERGY_PRICE_SENSOR", ""),
+		EnergyTariff:        parseEnergyTariff(getEnv("ENERGY_TARIFF", "")),
+		CarbonProvider:      getEnv("CARBON_PROVIDER", ""),
+		CarbonRegion:        getEnv("CARBON_REGION", ""),
+		CarbonAPIKey:        getEnv("CARBON_API_KEY", ""),
+		SolarExportSensor:   getEnv("SOLAR_EXPORT_SENSOR", ""),
+		SolarExportInvert:   getEnv("SOLAR_EXPORT_INVERT", "false") == "true",
+		Printer3DType:       getEnv("PRINTER3D_TYPE", "octoprint"),
+		Printer3DURL:        getEnv("PRINTER3D_URL", ""),
+		Printer3DAPIKey:     getEnv("PRINTER3D_API_KEY", ""),
+		Printer3DSnapshot:   getEnv("PRINTER3D_SNAPSHOT_URL", ""),
+		Printer3DName:       getEnv("PRINTER3D_NAME", "3D Printer"),
+		SecurityEntities:    parseEntities(getEnv("SECURITY_ENTITIES", "")),
+		SecurityGap:         time.Duration(securityGapMins) * time.Minute,
+		MailboxSensor:       getEnv("MAILBOX_SENSOR", ""),
 		Cameras:            cameras,
 		FrigateHost:        getEnv("FRIGATE_HOST", ""),
 		DoorbellCamera:     getEnv("DOORBELL_CAMERA", "front_door"),
 		WebhookSecret:      getEnv("WEBHOOK_SECRET", ""),
+		APIToken:           getEnv("API_TOKEN", ""),
+		TLSCertFile:        getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:         getEnv("TLS_KEY_FILE", ""),
+		TLSClientCAFile:    getEnv("TLS_CLIENT_CA_FILE", ""),
+		MTLSRouteGroups:    parseEntities(getEnv("MTLS_ROUTE_GROUPS", "")),
 		HueBridgeIP:            getEnv("HUE_BRIDGE_IP", ""),
 		HueUsername:            getEnv("HUE_USERNAME", ""),
 		HueClientKey:           getEnv("HUE_CLIENT_KEY", ""),
+		AdaptiveLights:         parseEntities(getEnv("ADAPTIVE_LIGHTS", "")),
 		SyncBoxes:              parseSyncBoxes(getEnv("SYNC_BOXES", "")),
 		DrivePhotosFolder: getEnv("DRIVE_PHOTOS_FOLDER", getEnv("DRIVE_BACKGROUND_FOLDER", "")),
+		GooglePhotos:      getEnv("GOOGLE_PHOTOS", "false") == "true",
+		PhotosDir:         getEnv("PHOTOS_DIR", ""),
+		ClassroomStudents: parseClassroomStudents(getEnv("CLASSROOM_STUDENTS", "")),
 		ScreensaverTimeout:     parseIntEnv("SCREENSAVER_TIMEOUT", 300),
 		SpotifyClientID:           getEnv("SPOTIFY_CLIENT_ID", ""),
 		SpotifyClientSecret:       getEnv("SPOTIFY_CLIENT_SECRET", ""),
+		SpotifyPlayerName:         getEnv("SPOTIFY_PLAYER_NAME", ""),
+		SpotifyAccounts:           parseSpotifyAccounts(getEnv("SPOTIFY_ACCOUNTS", "")),
 		TabletADBAddr:             getEnv("TABLET_ADB_ADDR", ""),
 		TabletProximityEnabled:    getEnv("TABLET_PROXIMITY_ENABLED", "false") == "true",
 		TabletIdleTimeout:         parseIntEnv("TABLET_IDLE_TIMEOUT", 60),
@@ -354,14 +880,36 @@ func main() {
 		XboxRESTServerURL: getEnv("XBOX_REST_SERVER", ""),
 		PS5Devices:        parsePS5Devices(getEnv("PS5_DEVICES", "")),
 		PS5MQTTTopic:      getEnv("PS5_MQTT_TOPIC", "homeassistant"),
+		PS5MQTTStateTopic: getEnv("PS5_MQTT_STATE_TOPIC", "ps5-mqtt"),
+		SonosHosts:        parseEntities(getEnv("SONOS_HOSTS", "")),
+		IRDevices:         parseIRDevices(getEnv("IR_DEVICES", "")),
+		// Activity macros
+		EntertainmentActivities: parseActivities(getEnv("ENTERTAINMENT_ACTIVITIES", "")),
 	}
 	appConfig = cfg
 	log.Printf("Using timezone: %s", loc.String())
 
+	// Open the state database (prefs, tokens, history)
+	dataDir := getEnv("DATA_DIR", "data")
+	if err := os.MkdirAll(dataDir, 0755); err != nil {
+		log.Printf("Warning: Failed to create data directory: %v", err)
+	}
+	dataStore, err = store.Open(filepath.Join(dataDir, "home_control.db"))
+	if err != nil {
+		log.Fatalf("Failed to open data store: %v", err)
+	}
+	log.Printf("Data store opened at %s", filepath.Join(dataDir, "home_control.db"))
+
 	// Initialize HA client
 	if cfg.HomeAssistantToken != "" {
 		haClient = homeassistant.NewClient(cfg.HomeAssistantURL, cfg.HomeAssistantToken)
 		log.Printf("Home Assistant client initialized for %s", cfg.HomeAssistantURL)
+		cardGrouping = homeassistant.NewGroupingStore(dataStore.Doc("settings", "card_grouping", ""))
+		if n, err := resolveHAEntities(cfg); err != nil {
+			log.Printf("Warning: Failed to resolve HA_ENTITIES selectors, using plain entity IDs only: %v", err)
+		} else {
+			log.Printf("Resolved HA_ENTITIES to %d entities", n)
+		}
 	} else {
 		log.Println("Warning: HA_TOKEN not set, Home Assistant integration disabled")
 	}
@@ -378,11 +926,21 @@ func main() {
 		log.Println("Info: Hue bridge not configured (optional)")
 	}
 
-	// Initialize Sync Box clients
-	if len(cfg.SyncBoxes) > 0 {
-		for _, sbCfg := range cfg.SyncBoxes {
-			client := syncbox.NewClient(sbCfg.IP, sbCfg.AccessToken, sbCfg.Name)
-			syncBoxClients = append(syncBoxClients, client)
+	// Initialize Sync Box clients from SYNC_BOXES and boxes registered in the app
+	syncBoxDoc = dataStore.Doc("tokens", "syncboxes", "")
+	syncBoxes := cfg.SyncBoxes
+	var registered []SyncBoxConfig
+	if _, err := syncBoxDoc.Load(&registered); err != nil {
+		log.Printf("Warning: Failed to load registered Sync Boxes: %v", err)
+	}
+	for _, sbCfg := range registered {
+		if !slices.ContainsFunc(syncBoxes, func(b SyncBoxConfig) bool { return b.IP == sbCfg.IP }) {
+			syncBoxes = append(syncBoxes, sbCfg)
+		}
+	}
+	if len(syncBoxes) > 0 {
+		for _, sbCfg := range syncBoxes {
+			addSyncBox(sbCfg)
 			log.Printf("Hue Sync Box client initialized: %s (%s)", sbCfg.Name, sbCfg.IP)
 		}
 	} else {
@@ -398,12 +956,12 @@ func main() {
 		}
 
 		// Set up calendar preferences file path and load prefs
-		calendarPrefsFile = filepath.Join(dataDir, "calendar_prefs.json")
+		calendarPrefsDoc = dataStore.Doc("prefs", "calendar", filepath.Join(dataDir, "calendar_prefs.json"))
 		calendarPrefs = loadCalendarPrefs()
 
 		redirectURL := cfg.BaseURL + "/auth/google/callback"
-		tokenFile := filepath.Join(dataDir, "token.json")
-		calClient = calendar.NewClient(cfg.GoogleClientID, cfg.GoogleClientSecret, redirectURL, tokenFile, cfg.GoogleCalendars, cfg.Timezone)
+		tokenDoc := dataStore.Doc("tokens", "google", filepath.Join(dataDir, "token.json"))
+		calClient = calendar.NewClient(cfg.GoogleClientID, cfg.GoogleClientSecret, redirectURL, tokenDoc, cfg.GoogleCalendars, cfg.Timezone)
 
 		// Try to initialize with stored token
 		if calClient.IsAuthorized() {
@@ -422,8 +980,9 @@ func main() {
 	// Initialize Google Tasks client (shares OAuth token with Calendar)
 	if cfg.GoogleClientID != "" && cfg.GoogleClientSecret != "" {
 		dataDir := getEnv("DATA_DIR", "data")
-		tokenFile := filepath.Join(dataDir, "token.json")
-		tasksClient = tasks.NewClient(cfg.GoogleClientID, cfg.GoogleClientSecret, tokenFile, cfg.Timezone)
+		tokenDoc := dataStore.Doc("tokens", "google", filepath.Join(dataDir, "token.json"))
+		tasksClient = tasks.NewClient(cfg.GoogleClientID, cfg.GoogleClientSecret, tokenDoc, cfg.Timezone)
+		tasksClient.SetRecurrenceStore(tasks.NewRecurrenceStore(dataStore.Doc("rules", "task_recurrence", "")))
 
 		// Try to initialize with stored token (if calendar is authorized)
 		if calClient != nil && calClient.IsAuthorized() {
@@ -456,42 +1015,69 @@ func main() {
 		}
 	}
 
-	// Initialize Spotify client
-	if cfg.SpotifyClientID != "" && cfg.SpotifyClientSecret != "" {
-		dataDir := getEnv("DATA_DIR", "data")
-		redirectURL := cfg.BaseURL + "/auth/spotify/callback"
-		spotifyClient = spotify.NewClient(cfg.SpotifyClientID, cfg.SpotifyClientSecret, redirectURL)
+	// Initialize Google Photos client (shares OAuth token with Calendar)
+	if cfg.GooglePhotos && calClient != nil {
+		calClient.AddScopes(photos.Scope)
+		if !calClient.IsAuthorized() {
+			log.Println("Google Photos: Skipping - Calendar not authorized (complete OAuth first)")
+		} else if httpClient, err := calClient.GetHTTPClient(context.Background()); err != nil {
+			log.Printf("Warning: Failed to get HTTP client for Google Photos: %v", err)
+		} else {
+			photosClient = photos.NewClient(httpClient, dataStore.Doc("settings", "google_photos", ""), filepath.Join(dataDir, "google_photos"))
+			log.Println("Google Photos client initialized (re-authorize at /auth/google if access is denied)")
+		}
+	}
 
-		// Set up token persistence
-		tokenFile := filepath.Join(dataDir, "spotify_token.json")
-		spotifyClient.SetTokenSaveCallback(func(token *spotify.Token) error {
-			data, err := json.MarshalIndent(token, "", "  ")
-			if err != nil {
-				return err
-			}
-			return os.WriteFile(tokenFile, data, 0600)
-		})
+	// Local photo folder (SMB/NFS share mounted into the container)
+	if cfg.PhotosDir != "" {
+		if info, err := os.Stat(cfg.PhotosDir); err != nil || !info.IsDir() {
+			log.Printf("Warning: PHOTOS_DIR %s is not a readable directory, skipping", cfg.PhotosDir)
+		} else {
+			photoFolder = photos.NewFolder(cfg.PhotosDir)
+			log.Printf("Photo folder initialized (%s)", cfg.PhotosDir)
+		}
+	}
 
-		// Try to load existing token
-		if tokenData, err := os.ReadFile(tokenFile); err == nil {
-			var token spotify.Token
-			if err := json.Unmarshal(tokenData, &token); err == nil {
-				spotifyClient.SetToken(&token)
-				log.Println("Spotify client initialized with saved token")
-			}
+	// Initialize Google Classroom client (shares OAuth token with Calendar)
+	if len(cfg.ClassroomStudents) > 0 && calClient != nil {
+		calClient.AddScopes(classroom.Scopes...)
+		if !calClient.IsAuthorized() {
+			log.Println("Classroom: Skipping - Calendar not authorized (complete OAuth first)")
+		} else if httpClient, err := calClient.GetHTTPClient(context.Background()); err != nil {
+			log.Printf("Warning: Failed to get HTTP client for Classroom: %v", err)
+		} else if classroomClient, err = classroom.NewClient(httpClient, cfg.ClassroomStudents, cfg.Timezone); err != nil {
+			log.Printf("Warning: Failed to initialize Classroom client: %v", err)
 		} else {
-			log.Println("Spotify client initialized. Visit /auth/spotify to authorize.")
+			log.Printf("Google Classroom client initialized for %d students (re-authorize at /auth/google if access is denied)", len(cfg.ClassroomStudents))
+		}
+	}
+
+	// Initialize Spotify client
+	if cfg.SpotifyClientID != "" && cfg.SpotifyClientSecret != "" {
+		spotifyDeviceSettings = spotify.NewDeviceSettingsStore(dataStore.Doc("settings", "spotify_devices", ""))
+
+		spotifyClient = addSpotifyAccount(cfg, defaultSpotifyAccount)
+		for _, account := range cfg.SpotifyAccounts {
+			addSpotifyAccount(cfg, account)
 		}
 	} else {
 		log.Println("Info: Spotify not configured (optional)")
 	}
 
-	// Initialize Weather client
-	if cfg.OpenWeatherAPIKey != "" && cfg.WeatherLat != 0 && cfg.WeatherLon != 0 {
-		weatherClient = weather.NewClient(cfg.OpenWeatherAPIKey, cfg.WeatherLat, cfg.WeatherLon, cfg.Timezone)
-		weatherClient.Start()
+	// Initialize Weather client (Google or OpenWeatherMap when keyed, Open-Meteo otherwise)
+	if cfg.WeatherLat != 0 && cfg.WeatherLon != 0 {
+		locations := append([]weather.Location{{Name: weather.HomeLocation, Lat: cfg.WeatherLat, Lon: cfg.WeatherLon}}, cfg.WeatherLocations...)
+		weatherLocations = weather.NewLocations(cfg.GoogleWeatherKey, cfg.OpenWeatherAPIKey, locations, cfg.Timezone)
+		weatherClient = weatherLocations.Default()
+		weatherLocations.Start()
+		if cfg.BriefingTime != "" {
+			go runMorningBriefing(cfg.BriefingTime)
+		}
 		log.Printf("Weather client initialized for coordinates (%.4f, %.4f)", cfg.WeatherLat, cfg.WeatherLon)
-	} else if cfg.OpenWeatherAPIKey != "" {
+		if len(cfg.WeatherLocations) > 0 {
+			log.Printf("Weather: tracking %d more location(s)", len(weatherLocations.List())-1)
+		}
+	} else if cfg.OpenWeatherAPIKey != "" || cfg.GoogleWeatherKey != "" {
 		log.Println("Warning: WEATHER_LAT and WEATHER_LON required for weather. Set your coordinates.")
 	}
 
@@ -500,6 +1086,280 @@ func main() {
 	go wsHub.Run()
 	log.Println("WebSocket hub started")
 
+	// Announcements play one at a time on the targeted tablets
+	announcements = announce.NewQueue(cfg.AnnounceRooms, cfg.AnnounceChime, sendAnnouncement)
+	announcements.OnChange(func(a announce.Announcement) {
+		wsHub.Broadcast(websocket.Event{Type: "announcement_status", Payload: a})
+	})
+	go announcements.Run()
+
+	for _, account := range spotifyAccountNames {
+		startSpotifyPoller(account)
+	}
+
+	// Initialize local notes / shopping list
+	notesStore = notes.NewStore(dataStore.Doc("lists", "notes", ""))
+	notesStore.OnChange(func(list string) {
+		wsHub.Broadcast(websocket.Event{
+			Type:    "notes_changed",
+			Payload: map[string]interface{}{"list": list, "items": notesStore.List(list)},
+		})
+	})
+
+	// Notification center (delivered to tablets over WebSocket)
+	notifyCenter = notify.NewCenter(dataStore, 30*24*time.Hour)
+	notifyCenter.OnNotify(func(n notify.Notification) {
+		if n.Severity != notify.SeverityInfo {
+			go wakeTablet()
+		}
+		wsHub.Broadcast(websocket.Event{Type: "notification", Payload: n})
+	})
+
+	// Action history and quick-action favorites
+	actionLog = actions.NewLog(dataStore, 90*24*time.Hour)
+	favoritesStore = favorites.NewStore(dataStore.Doc("settings", "favorites", ""))
+
+	// Sensor history (tablet sensors + numeric HA sensors)
+	sensorHistory = history.NewRecorder(dataStore, time.Minute)
+	if n, err := sensorHistory.Prune(time.Now().Add(-sensorHistoryRetention)); err != nil {
+		log.Printf("Warning: %v", err)
+	} else if n > 0 {
+		log.Printf("Pruned %d old sensor readings", n)
+	}
+	if haClient != nil {
+		go sampleSensorHistory(configuredEntities())
+	}
+
+	// Client-side error reports (JS / companion app)
+	clientLog = clientlog.NewLog(dataStore, 14*24*time.Hour)
+
+	// State change journal
+	stateJournal = journal.New(dataStore, 90*24*time.Hour)
+
+	// Screen time reports from tablets and routers
+	screenTime = screentime.NewTracker(dataStore, cfg.Timezone, 180*24*time.Hour)
+
+	// Lock PINs and audit log
+	lockGuard = locks.NewGuard(dataStore, cfg.LockPINs, 365*24*time.Hour)
+	if len(cfg.LockPINs) > 0 {
+		log.Printf("PIN required for %d lock(s)", len(cfg.LockPINs))
+	}
+
+	// Household document vault, opened on the kiosk with VAULT_PIN
+	if cfg.VaultKey != "" {
+		if v, err := vault.New(filepath.Join(dataDir, "vault"), cfg.VaultKey, dataStore.Doc("settings", "vault", "")); err != nil {
+			log.Printf("Warning: Document vault disabled: %v", err)
+		} else if cfg.VaultPIN == "" {
+			log.Println("Warning: VAULT_PIN missing, the vault is only open to admin clients")
+			documentVault = v
+		} else {
+			documentVault = v
+			// The lock guard's failed attempt lockout covers the vault PIN too
+			vaultGuard = locks.NewGuard(dataStore, map[string]string{vaultSubject: cfg.VaultPIN}, 0)
+		}
+	}
+
+	// Party mode: guests upload photos from their phones into the slideshow
+	partyMode = party.NewManager(filepath.Join(dataDir, "party"))
+	partyMode.OnChange(func() {
+		wsHub.Broadcast(websocket.Event{Type: "party", Payload: partyMode.Status(false)})
+	})
+	partyMode.OnPhoto(func(p party.Photo) {
+		wsHub.Broadcast(websocket.Event{Type: "party_photo", Payload: p})
+	})
+	go partyMode.Run()
+
+	// Emergency egress mode (fire drill): lights up, doors unlocked, media stopped
+	egressMode = egress.NewManager(dataStore, egress.Config{Doors: cfg.EgressDoors, Instructions: cfg.EgressInstructions}, egress.Actions{
+		Lights:    allLightsFull,
+		Unlock:    unlockForEgress,
+		StopMedia: func() error { return pauseMedia(nil) },
+		Display: func(s egress.Status) {
+			go wakeTablet()
+			wsHub.Broadcast(websocket.Event{Type: "egress", Payload: s})
+		},
+	})
+
+	// Expected visitors: doorbell announcements and pre-unlocked doors
+	visitorManager = visitors.NewManager(dataStore.Doc("lists", "visitors", ""), cfg.VisitorDoors, lockForVisitor)
+	visitorManager.OnChange(func() {
+		wsHub.Broadcast(websocket.Event{Type: "visitors_changed", Payload: visitorManager.List(time.Now())})
+	})
+	go visitorManager.Run()
+
+	// House manual for babysitters and guests, shown on the tablets in guest mode
+	houseManual = manual.NewManager(dataStore.Doc("settings", "house_manual", ""))
+	houseManual.OnChange(func() {
+		wsHub.Broadcast(websocket.Event{Type: "manual_changed", Payload: houseManual.Get()})
+	})
+
+	// Cache statistics and TTL overrides
+	initCaches()
+
+	// Per-device feature flags
+	featureFlags = flags.NewService(dataStore.Doc("settings", "feature_flags", ""))
+
+	// Chore rotation
+	choresManager = chores.NewManager(dataStore.Doc("lists", "chores", ""), cfg.Timezone)
+	choresManager.OnChange(func() {
+		wsHub.Broadcast(websocket.Event{Type: "chores_changed", Payload: choresManager.List()})
+	})
+
+	// Countdowns to big events ("12 days until vacation")
+	countdownManager = countdowns.NewManager(dataStore.Doc("lists", "countdowns", ""), cfg.Timezone)
+	countdownManager.OnChange(func() {
+		wsHub.Broadcast(websocket.Event{Type: "countdowns_changed", Payload: countdownManager.List(false)})
+	})
+	if calClient != nil && cfg.CountdownKeyword != "" {
+		go syncCountdowns(cfg.CountdownKeyword)
+	}
+
+	// Kitchen timers and the recipe box that starts them
+	timerManager = timers.NewManager(dataStore.Doc("lists", "timers", ""))
+	timerManager.OnChange(func() {
+		wsHub.Broadcast(websocket.Event{Type: "timers_changed", Payload: timerManager.List()})
+	})
+	timerManager.OnDone(func(t timers.Timer) {
+		wsHub.Broadcast(websocket.Event{Type: "timer_done", Payload: t})
+		notifyCenter.Notify(notify.Notification{
+			Source:   "timer",
+			Key:      "timer-" + t.ID,
+			Severity: notify.SeverityWarning,
+			Title:    "Timer done",
+			Message:  t.Name,
+		})
+	})
+	go timerManager.Run()
+
+	// Sleep timer: pause media and power down after N minutes
+	sleepTimer = sleeptimer.New(runSleepTimer)
+	sleepTimer.OnChange(func(s sleeptimer.Status) {
+		wsHub.Broadcast(websocket.Event{Type: "sleep_timer", Payload: s})
+	})
+	recipeBox = recipes.NewManager(dataStore.Doc("lists", "recipes", ""))
+	recipeBox.OnChange(func() {
+		wsHub.Broadcast(websocket.Event{Type: "recipes_changed", Payload: recipeBox.List()})
+	})
+
+	// Pantry inventory: used-up items go on the shopping list
+	pantryManager = pantry.NewManager(dataStore.Doc("lists", "pantry", ""), cfg.Timezone)
+	pantryManager.OnChange(func() {
+		wsHub.Broadcast(websocket.Event{Type: "pantry_changed", Payload: pantryManager.List("")})
+	})
+	pantryManager.OnUsedUp(addToShoppingList)
+	if cfg.PantryExpiryDays > 0 {
+		pantryManager.OnExpiring(func(items []pantry.Status) {
+			for _, item := range items {
+				severity := notify.SeverityInfo
+				if item.Expired {
+					severity = notify.SeverityWarning
+				}
+				notifyCenter.Notify(notify.Notification{
+					Source:   "pantry",
+					Key:      "pantry-" + item.ID,
+					Severity: severity,
+					Title:    "Use it up",
+					Message:  item.Label(),
+				})
+			}
+		})
+		go pantryManager.RunReminders(cfg.PantryExpiryDays)
+	}
+
+	// Time-of-day jobs, e.g. aquarium light and pump schedules
+	jobScheduler = scheduler.New(cfg.Timezone)
+	go jobScheduler.Run()
+
+	// House mode (home/away/night/vacation), a condition for routines and buttons
+	initHouseMode()
+
+	// Aquariums and terrariums: probe thresholds and device schedules
+	initAquariums()
+
+	// Pool and hot tub equipment through Home Assistant
+	if haClient != nil && len(cfg.PoolBodies) > 0 {
+		poolManager = pool.NewManager(haClient, cfg.PoolBodies, dataStore.Doc("settings", "pool", ""))
+		syncPoolSchedules()
+		go poolManager.Run()
+	}
+
+	// Hot water: weekly schedule and boost button
+	if haClient != nil && cfg.HotWaterEntity != "" {
+		hotWater = hotwater.NewManager(cfg.HotWaterEntity, dataStore.Doc("settings", "hotwater", ""), cfg.Timezone, func(on bool) error {
+			return setEntityPower(cfg.HotWaterEntity, on)
+		})
+		hotWater.OnChange(func(s hotwater.Status) {
+			wsHub.Broadcast(websocket.Event{Type: "hotwater", Payload: s})
+		})
+		syncHotWaterSchedule()
+		go hotWater.Run()
+	}
+
+	// Grid carbon intensity for /api/energy/carbon and "greener later" hints
+	if cfg.CarbonProvider != "" {
+		if c, err := energy.NewCarbon(cfg.CarbonProvider, cfg.CarbonRegion, cfg.CarbonAPIKey); err != nil {
+			log.Printf("Warning: Carbon intensity disabled: %v", err)
+		} else {
+			carbonClient = c
+			log.Printf("Carbon intensity from %s %s", cfg.CarbonProvider, cfg.CarbonRegion)
+		}
+	}
+
+	// Smart start: EV charging, dishwasher etc. in the cheapest price window
+	if haClient != nil && len(cfg.SmartStartLoads) > 0 {
+		smartStart = energy.NewSmartStart(dataStore.Doc("settings", "smart_start", ""), cfg.SmartStartLoads, energyForecast, func(entity string, on bool) error {
+			err := setEntityPower(entity, on)
+			if err == nil {
+				recordJournal(journal.Entry{Kind: journal.KindAction, Subject: entity, To: onOff(on), Source: "smart_start"})
+			}
+			return err
+		})
+		smartStart.OnChange(func() {
+			wsHub.Broadcast(websocket.Event{Type: "smart_start", Payload: smartStartStatus()})
+		})
+		go smartStart.Run()
+		log.Printf("Smart start: %d load(s), prices from %s", len(cfg.SmartStartLoads), energyPriceSource())
+	}
+
+	// Solar surplus: run loads like the pool pump while exporting
+	if haClient != nil && cfg.SolarExportSensor != "" {
+		solarSurplus = energy.NewSurplus(dataStore.Doc("settings", "solar_surplus", ""), solarExport, func(entity string, on bool) error {
+			err := setEntityPower(entity, on)
+			if err == nil {
+				recordJournal(journal.Entry{Kind: journal.KindAction, Subject: entity, To: onOff(on), Source: "solar_surplus"})
+			}
+			return err
+		})
+		solarSurplus.OnChange(func() {
+			wsHub.Broadcast(websocket.Event{Type: "solar_surplus", Payload: solarSurplusStatus()})
+		})
+		go solarSurplus.Run()
+		log.Printf("Solar surplus rules reading %s", cfg.SolarExportSensor)
+	}
+
+	// Quiet hours: media volume cap at night (off until enabled at /api/quiet-hours)
+	quietHours = quiethours.NewManager(dataStore.Doc("settings", "quiet_hours", ""), cfg.Timezone)
+
+	// Day classification (school day, holiday, weekend, WFH), which routine
+	// stages, alarms and morning routine members can be limited to
+	dayContext = daycontext.NewService(dataStore.Doc("settings", "day_context", ""), cfg.Timezone, dayContextEvents)
+
+	// Wind-down evening routine (off until enabled at /api/routines/evening)
+	addRoutine(routines.NewRunner("evening", dataStore.Doc("settings", "routine_evening", ""), cfg.Timezone, defaultEveningRoutine))
+
+	// Morning routine: alarms, bathroom lights and departure countdowns from each
+	// member's first calendar event
+	morningRoutine = morning.New(dataStore.Doc("settings", "routine_morning", ""), cfg.Timezone, morningEvents)
+	morningRoutine.SetExecutor(runMorningStep)
+	morningRoutine.SetDaySource(dayTags)
+	go morningRoutine.Run()
+
+	// Music alarms: Spotify at a rising volume, optionally after a Hue sunrise
+	alarmManager = alarms.NewManager(dataStore.Doc("settings", "alarms", ""))
+	alarmManager.SetDaySource(dayTags)
+	alarmManager.OnChange(syncAlarmSchedules)
+	syncAlarmSchedules()
+
 	// Initialize Camera manager
 	cameraManager = camera.NewManager()
 	if cfg.FrigateHost != "" {
@@ -527,8 +1387,7 @@ func main() {
 
 		// Set doorbell handler to broadcast via WebSocket and wake tablet
 		mqttClient.SetDoorbellHandler(func() {
-			go wakeTablet() // Wake tablet screen first
-			wsHub.BroadcastDoorbell(cfg.DoorbellCamera)
+			ringDoorbell("mqtt")
 		})
 
 		go func() {
@@ -539,6 +1398,127 @@ func main() {
 		log.Printf("MQTT client connecting to %s:%d", cfg.MQTTHost, cfg.MQTTPort)
 	}
 
+	// Lightning strike alerts from the Blitzortung feed (separate public broker)
+	if cfg.LightningMQTTHost != "" {
+		if cfg.WeatherLat == 0 && cfg.WeatherLon == 0 {
+			log.Println("Warning: LIGHTNING_MQTT_HOST set but WEATHER_LAT/WEATHER_LON missing, lightning alerts disabled")
+		} else {
+			initLightning(cfg)
+		}
+	}
+
+	// Local emergency alerts merged into the notification center
+	if cfg.EmergencyNWS || len(cfg.EmergencyFeeds) > 0 {
+		if cfg.WeatherLat == 0 && cfg.WeatherLon == 0 {
+			log.Println("Warning: Emergency feeds configured but WEATHER_LAT/WEATHER_LON missing, emergency alerts disabled")
+		} else {
+			initEmergency(cfg)
+		}
+	}
+
+	// Presence tracking from HA person/device_tracker entities and phone geofence webhooks
+	initPresence(cfg)
+
+	// Security incidents from sensors and cameras while nobody is home
+	if presenceTracker != nil {
+		initSecurity(cfg)
+	}
+
+	// Show the driveway camera on a kiosk when a car pulls in or someone arrives
+	initArrivalCameras(cfg)
+
+	// Initialize physical button mappings (MQTT / Hue remotes)
+	buttonManager = buttons.NewManager(dataStore.Doc("settings", "buttons", ""))
+	buttonManager.SetActionHandler(runButtonAction)
+	buttonManager.SetModeSource(houseMode.Mode)
+	if mqttClient != nil {
+		buttonManager.OnChange(syncButtonSubscriptions)
+		syncButtonSubscriptions(buttonManager.List())
+	}
+
+	// Real-time Hue state from the bridge event stream; /api/hue/rooms reads its cache
+	if hueClient != nil {
+		hueEvents = hue.NewEventStream(hueClient)
+		hueEvents.OnChange(func(rooms []*hue.Room) {
+			wsHub.Broadcast(websocket.Event{Type: "hue_rooms", Payload: rooms})
+		})
+		go hueEvents.Run()
+
+		initHueSensors()
+	}
+
+	// Adaptive lighting: color temperature and brightness follow the sun in enabled rooms
+	if hueClient != nil || (haClient != nil && len(cfg.AdaptiveLights) > 0) {
+		if cfg.WeatherLat == 0 && cfg.WeatherLon == 0 {
+			log.Println("Warning: WEATHER_LAT/WEATHER_LON missing, adaptive lighting disabled")
+		} else {
+			adaptiveLighting = lighting.NewScheduler(dataStore.Doc("settings", "adaptive_lighting", ""), cfg.WeatherLat, cfg.WeatherLon)
+			adaptiveLighting.SetApplier(applyAdaptiveLighting)
+			go adaptiveLighting.Run()
+		}
+	}
+
+	// Sun-tracking shading: close covers on sunlit facades when rooms get warm
+	if haClient != nil && (cfg.WeatherLat != 0 || cfg.WeatherLon != 0) {
+		shadingManager = shading.NewManager(dataStore.Doc("settings", "shading", ""), cfg.WeatherLat, cfg.WeatherLon, shadingTemperature, applyShading)
+		go shadingManager.Run()
+	}
+
+	// Weather-compensated heating: climate targets follow the outdoor forecast
+	if haClient != nil && weatherClient != nil {
+		heatingCurve = heating.NewManager(dataStore.Doc("settings", "heating_curve", ""), heatingForecast)
+		heatingCurve.SetApplier(haClient.SetClimateTemperature)
+		go heatingCurve.Run()
+	}
+
+	// Virtual sensors from arbitrary MQTT topics (mailbox, garage tilt, probes)
+	if mqttClient != nil {
+		initMQTTSensors()
+	}
+
+	// Zigbee2MQTT devices that aren't bridged into Home Assistant
+	if mqttClient != nil && cfg.ZigbeeBaseTopic != "" {
+		initZigbee(cfg.ZigbeeBaseTopic)
+	}
+
+	// Smart appliances (coffee maker, dishwasher, oven) bridged over MQTT
+	if mqttClient != nil && len(cfg.Appliances) > 0 {
+		initAppliances(cfg.Appliances)
+	}
+
+	// Keys and wallets: last-seen room from BLE tag sightings (MQTT receivers and tablets)
+	itemTracker = items.NewTracker(dataStore.Doc("lists", "items", ""))
+	itemTracker.OnChange(func() {
+		wsHub.Broadcast(websocket.Event{Type: "items_changed", Payload: itemTracker.List()})
+	})
+	if mqttClient != nil && cfg.BLETrackerTopic != "" {
+		prefix := strings.TrimSuffix(cfg.BLETrackerTopic, "/")
+		mqttClient.Subscribe(prefix+"/+/+", func(topic string, payload []byte) {
+			if s, ok := items.ParseMQTT(prefix, topic, payload); ok {
+				itemTracker.Record(s)
+			}
+		})
+	}
+
+	// 3D printer progress, with a notification when a print finishes or fails
+	if cfg.Printer3DURL != "" {
+		initPrinter3D(cfg)
+	}
+
+	// Postal deliveries from the mailbox contact sensor
+	if cfg.MailboxSensor != "" {
+		initMailbox(cfg)
+	}
+
+	// Robot vacuum cleaning regions; Valetudo robots also report their rooms over MQTT
+	vacuumRegions = vacuum.NewManager(dataStore.Doc("settings", "vacuum_regions", ""))
+	vacuumRegions.OnChange(func() {
+		wsHub.Broadcast(websocket.Event{Type: "vacuum_regions_changed"})
+	})
+	if mqttClient != nil && len(cfg.ValetudoRobots) > 0 {
+		valetudo = mqtt.NewValetudo(mqttClient, cfg.ValetudoRobots)
+	}
+
 	// Initialize entertainment device managers
 	initEntertainmentManagers(cfg)
 
@@ -619,7 +1599,7 @@ func main() {
 
 	// Parse each page template separately with base to avoid content block conflicts
 	pageTemplates = make(map[string]*template.Template)
-	pages := []string{"calendar", "home"}
+	pages := []string{"calendar", "home", "support"}
 	for _, page := range pages {
 		t, err := template.New("").Funcs(templateFuncMap).ParseFiles(
 			filepath.Join("templates", "base.html"),
@@ -634,6 +1614,9 @@ func main() {
 	r := chi.NewRouter()
 	r.Use(ConditionalLogger)
 	r.Use(middleware.Compress(5))
+	r.Use(CSRFProtect)
+	r.Use(RequireClientCert(cfg.MTLSRouteGroups))
+	r.Use(InvalidateCaches)
 
 	// Static files
 	r.Handle("/static/*", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
@@ -642,6 +1625,7 @@ func main() {
 	r.Get("/", handleCalendar)
 	r.Get("/calendar", handleCalendar)
 	r.Get("/home", handleHome(cfg))
+	r.Get("/support", handleSupport)
 
 	// Google OAuth routes
 	r.Get("/auth/google", handleGoogleAuth)
@@ -651,11 +1635,110 @@ func main() {
 	// API endpoints
 	r.Post("/api/toggle/{entityID}", handleToggle)
 
+	// Presence (who is home)
+	r.Get("/api/presence", handleGetPresence)
+	r.Get("/api/geofence", handleGetGeofence)
+	r.Put("/api/geofence/settings", handleUpdateGeofenceSettings)
+	r.Post("/api/geofence/{person}", handleGeofenceWebhook)
+	r.Get("/api/mode", handleGetHouseMode)
+	r.Put("/api/mode", handleSetHouseMode)
+	r.Put("/api/mode/rules", handleUpdateHouseModeRules)
+
+	// Security incidents while away
+	r.Get("/api/security/incidents", handleGetSecurityIncidents)
+
+	// Mailbox deliveries
+	r.Get("/api/mailbox", handleGetMailbox)
+
+	// Screen time / device usage
+	r.Get("/api/screentime", handleGetScreenTime)
+	r.Post("/api/screentime/report", handleScreenTimeReport)
+
+	// Announcements
+	r.Get("/api/announcements", handleGetAnnouncements)
+	r.Post("/api/announcements", handleAnnounce)
+	r.Post("/api/announcements/{id}/played", handleAnnouncementPlayed)
+
+	// Notifications and lightning alerts
+	r.Get("/api/notifications", handleGetNotifications)
+	r.Get("/api/lightning", handleGetLightning)
+	r.Get("/api/emergency/alerts", handleGetEmergencyAlerts)
+
+	// Lock audit log (lock/unlock go through toggle with an X-Lock-PIN header)
+	r.Get("/api/locks/audit", handleGetLockAudit)
+
+	// Document vault: admin clients upload, kiosks view after the PIN
+	r.Get("/api/vault", handleGetVault)
+	r.Post("/api/vault", handleUploadVaultDocument)
+	r.Post("/api/vault/unlock", handleUnlockVault)
+	r.Post("/api/vault/lock", handleLockVault)
+	r.Get("/api/vault/{id}", handleGetVaultDocument)
+	r.Delete("/api/vault/{id}", handleDeleteVaultDocument)
+
+	// Party mode: guests scan the QR code and upload photos to the slideshow
+	r.Get("/api/party", handleGetParty)
+	r.Post("/api/party", handleStartParty)
+	r.Delete("/api/party", handleEndParty)
+	r.Get("/api/party/qr.svg", handleGetPartyQR)
+	r.Get("/api/party/photos/{id}", handleGetPartyPhoto)
+	r.Post("/api/party/photos/{id}/approve", handleApprovePartyPhoto)
+	r.Delete("/api/party/photos/{id}", handleDeletePartyPhoto)
+	r.Post("/api/party/join/{token}/photos", handleUploadPartyPhotos)
+	r.Get("/party/{token}", handlePartyJoinPage)
+
+	// Emergency egress mode (fire drill)
+	r.Get("/api/egress", handleGetEgress)
+	r.Post("/api/egress/start", handleStartEgress)
+	r.Post("/api/egress/clear", handleClearEgress)
+	r.Get("/api/egress/audit", handleGetEgressAudit)
+
+	// Expected visitors
+	r.Get("/api/visitors", handleGetVisitors)
+	r.Post("/api/visitors", handleCreateVisitor)
+	r.Put("/api/visitors/{id}", handleUpdateVisitor)
+	r.Delete("/api/visitors/{id}", handleDeleteVisitor)
+	r.Post("/api/visitors/{id}/arrived", handleVisitorArrived)
+
+	// House manual and guest mode
+	r.Get("/api/manual", handleGetManual)
+	r.Put("/api/manual/guest-mode", handleSetGuestMode)
+	r.Post("/api/manual/sections", handleCreateManualSection)
+	r.Put("/api/manual/sections/{id}", handleUpdateManualSection)
+	r.Delete("/api/manual/sections/{id}", handleDeleteManualSection)
+
 	// Climate control endpoints
 	r.Post("/api/climate/{entityID}/temperature", handleSetClimateTemperature)
 	r.Post("/api/climate/{entityID}/mode", handleSetClimateMode)
 	r.Post("/api/climate/{entityID}/fan", handleSetClimateFanMode)
 
+	// Generic Home Assistant service calls (restricted by HA_SERVICE_ALLOWLIST)
+	r.Post("/api/ha/service/{domain}/{service}", handleCallHAService)
+
+	// Covers / blinds
+	r.Post("/api/cover/{entityID}/position", handleSetCoverPosition)
+
+	// Home Assistant media players
+	r.Post("/api/media/{entityID}/{command}", handleMediaCommand)
+
+	// 3D printer
+	r.Get("/api/printer3d", handleGetPrinter3D)
+	r.Get("/api/printer3d/snapshot", handleGetPrinter3DSnapshot)
+	r.Post("/api/printer3d/{action}", handlePrinter3DCommand)
+
+	// Robot vacuums
+	r.Post("/api/vacuum/{entityID}/command", handleVacuumCommand)
+	r.Get("/api/vacuum/{entityID}/map", handleGetVacuumMap)
+	r.Get("/api/vacuum/{entityID}/map/image", handleGetVacuumMapImage)
+	r.Post("/api/vacuum/{entityID}/clean", handleVacuumClean)
+	r.Post("/api/vacuum/{entityID}/regions", handleSaveVacuumRegion)
+	r.Put("/api/vacuum/{entityID}/regions/{id}", handleSaveVacuumRegion)
+	r.Delete("/api/vacuum/{entityID}/regions/{id}", handleDeleteVacuumRegion)
+
+	// Home Assistant scenes and scripts
+	r.Get("/api/ha/scenes", handleGetHAScenes)
+	r.Get("/api/ha/scripts", handleGetHAScripts)
+	r.Post("/api/ha/scene/{id}/activate", handleActivateHAScene)
+
 	// Calendar API endpoints
 	r.Get("/api/calendar/events", handleGetCalendarEvents)
 	r.Get("/api/calendar/colors", handleGetColors)
@@ -678,11 +1761,173 @@ func main() {
 	r.Get("/api/tasks/lists", handleGetTaskLists)
 	r.Post("/api/tasks", handleCreateTask)
 	r.Post("/api/tasks/{listID}/{taskID}/toggle", handleToggleTask)
+	r.Patch("/api/tasks/{listID}/{taskID}", handleUpdateTask)
+	r.Post("/api/tasks/{listID}/{taskID}/move", handleMoveTask)
+	r.Put("/api/tasks/{listID}/{taskID}/recurrence", handleSetTaskRecurrence)
+	r.Delete("/api/tasks/{listID}/{taskID}/recurrence", handleDeleteTaskRecurrence)
 	r.Delete("/api/tasks/{listID}/{taskID}", handleDeleteTask)
 	r.Post("/api/tasks/{listID}/clear", handleClearCompleted)
 
+	// Local notes / shopping list
+	r.Get("/api/notes", handleGetNotes)
+	r.Post("/api/notes", handleCreateNote)
+	r.Patch("/api/notes/{id}", handleUpdateNote)
+	r.Delete("/api/notes/{id}", handleDeleteNote)
+	r.Post("/api/notes/clear", handleClearNotes)
+
+	// Chores
+	r.Get("/api/chores", handleGetChores)
+	r.Post("/api/chores", handleCreateChore)
+	r.Put("/api/chores/{id}", handleUpdateChore)
+	r.Delete("/api/chores/{id}", handleDeleteChore)
+	r.Post("/api/chores/{id}/done", handleChoreDone)
+
+	// Countdowns
+	r.Get("/api/countdowns", handleGetCountdowns)
+	r.Post("/api/countdowns", handleCreateCountdown)
+	r.Put("/api/countdowns/{id}", handleUpdateCountdown)
+	r.Delete("/api/countdowns/{id}", handleDeleteCountdown)
+
+	// Item tracker (BLE tags on keys, wallets)
+	r.Get("/api/items", handleGetItems)
+	r.Post("/api/items", handleCreateItem)
+	r.Get("/api/items/tags", handleGetItemTags)
+	r.Post("/api/items/sightings", handleItemSighting)
+	r.Put("/api/items/{id}", handleUpdateItem)
+	r.Delete("/api/items/{id}", handleDeleteItem)
+	r.Post("/api/items/{id}/ring", handleRingItem)
+	r.Get("/api/alarms", handleGetAlarms)
+	r.Post("/api/alarms", handleCreateAlarm)
+	r.Put("/api/alarms/{id}", handleUpdateAlarm)
+	r.Delete("/api/alarms/{id}", handleDeleteAlarm)
+
+	// Timers
+	r.Get("/api/timers", handleGetTimers)
+	r.Post("/api/timers", handleCreateTimer)
+	r.Delete("/api/timers/{id}", handleCancelTimer)
+	r.Get("/api/sleep-timer", handleGetSleepTimer)
+	r.Post("/api/sleep-timer", handleStartSleepTimer)
+	r.Delete("/api/sleep-timer", handleCancelSleepTimer)
+
+	// Recipes
+	r.Get("/api/recipes", handleGetRecipes)
+	r.Post("/api/recipes", handleImportRecipe)
+	r.Get("/api/recipes/{id}", handleGetRecipe)
+	r.Delete("/api/recipes/{id}", handleDeleteRecipe)
+	r.Post("/api/recipes/{id}/steps/{step}/timer", handleStartRecipeStepTimer)
+
+	// Aquariums and terrariums
+	r.Get("/api/aquarium", handleGetAquariums)
+	r.Post("/api/aquarium", handleCreateAquarium)
+	r.Get("/api/aquarium/schedule", handleGetAquariumSchedule)
+	r.Get("/api/aquarium/{id}", handleGetAquarium)
+	r.Put("/api/aquarium/{id}", handleUpdateAquarium)
+	r.Delete("/api/aquarium/{id}", handleDeleteAquarium)
+	r.Post("/api/aquarium/{id}/devices/{device}", handleSetAquariumDevice)
+
+	// Routines
+	r.Get("/api/routines/morning", handleGetMorningRoutine)
+	r.Put("/api/routines/morning", handleUpdateMorningRoutine)
+	r.Get("/api/routines/{name}", handleGetRoutine)
+	r.Put("/api/routines/{name}", handleUpdateRoutine)
+	r.Post("/api/routines/{name}/stages/{stage}/run", handleRunRoutineStage)
+	r.Post("/api/routines/{name}/stages/{stage}/skip", handleSkipRoutineStage)
+
+	// Pool and hot tub
+	r.Get("/api/pool", handleGetPools)
+	r.Get("/api/pool/{id}", handleGetPool)
+	r.Post("/api/pool/{id}/heat", handleHeatPool)
+	r.Put("/api/pool/{id}/setpoint", handleSetPoolSetpoint)
+	r.Post("/api/pool/{id}/heater/off", handlePoolHeaterOff)
+	r.Post("/api/pool/{id}/pumps/{entity}", handleSetPoolPump)
+	r.Put("/api/pool/{id}/pumps/{entity}/schedule", handleSetPoolPumpSchedule)
+
+	// Hot water
+	r.Get("/api/hotwater", handleGetHotWater)
+	r.Post("/api/hotwater/boost", handleHotWaterBoost)
+	r.Delete("/api/hotwater/boost", handleCancelHotWaterBoost)
+	r.Put("/api/hotwater/schedule", handleSetHotWaterSchedule)
+
+	// Smart start queue for deferrable loads
+	r.Get("/api/energy/smart-start", handleGetSmartStart)
+	r.Post("/api/energy/smart-start", handleQueueSmartStart)
+	r.Delete("/api/energy/smart-start/{id}", handleCancelSmartStart)
+	r.Get("/api/energy/carbon", handleGetCarbon)
+	r.Get("/api/energy/surplus", handleGetSolarSurplus)
+	r.Put("/api/energy/surplus/rules", handleUpdateSolarSurplusRules)
+
+	// Quiet hours
+	r.Get("/api/quiet-hours", handleGetQuietHours)
+	r.Put("/api/quiet-hours", handleUpdateQuietHours)
+
+	// Pantry inventory
+	r.Get("/api/pantry", handleGetPantry)
+	r.Post("/api/pantry", handleAddPantryItem)
+	r.Get("/api/pantry/lookup/{barcode}", handleLookupPantryBarcode)
+	r.Put("/api/pantry/{id}", handleUpdatePantryItem)
+	r.Delete("/api/pantry/{id}", handleDeletePantryItem)
+	r.Post("/api/pantry/{id}/use", handleUsePantryItem)
+
+	// Day context (school day / holiday / weekend / WFH)
+	r.Get("/api/context/today", handleGetContextToday)
+	r.Get("/api/context/day/{date}", handleGetContextDay)
+	r.Get("/api/context/rules", handleGetContextRules)
+	r.Put("/api/context/rules", handleSetContextRules)
+
+	// Favorites quick-action bar
+	r.Get("/api/favorites", handleGetFavorites)
+	r.Put("/api/favorites", handleSetFavorites)
+	r.Post("/api/favorites", handleAddFavorite)
+	r.Delete("/api/favorites/{kind}/{target}", handleRemoveFavorite)
+	r.Get("/api/suggestions", handleGetSuggestions)
+
+	// Cache tuning
+	r.Get("/api/admin/caches", handleGetCaches)
+	r.Put("/api/admin/caches/{name}", handleSetCacheTTL)
+	r.Post("/api/admin/caches/{name}/invalidate", handleInvalidateCache)
+
+	// Feature flags
+	r.Get("/api/flags", handleGetFlags)
+	r.Get("/api/flags/config", handleGetFlagConfig)
+	r.Put("/api/flags/profiles/{device}", handleSetDeviceProfile)
+	r.Put("/api/flags/{name}", handleSetFlag)
+	r.Delete("/api/flags/{name}", handleDeleteFlag)
+
+	// Physical button mappings
+	r.Get("/api/buttons", handleGetButtonMappings)
+	r.Post("/api/buttons", handleCreateButtonMapping)
+	r.Put("/api/buttons/{id}", handleUpdateButtonMapping)
+	r.Delete("/api/buttons/{id}", handleDeleteButtonMapping)
+	r.Post("/api/buttons/press", handleButtonPress)
+
+	// MQTT virtual sensors
+	r.Post("/api/mqtt/publish", handlePublishMQTT)
+	r.Get("/api/mqtt/sensors", handleGetMQTTSensors)
+	r.Post("/api/mqtt/sensors", handleCreateMQTTSensor)
+	r.Get("/api/mqtt/sensors/{id}", handleGetMQTTSensor)
+	r.Put("/api/mqtt/sensors/{id}", handleUpdateMQTTSensor)
+	r.Delete("/api/mqtt/sensors/{id}", handleDeleteMQTTSensor)
+	r.Get("/api/zigbee/devices", handleGetZigbeeDevices)
+	r.Get("/api/zigbee/devices/{id}", handleGetZigbeeDevice)
+	r.Post("/api/zigbee/devices/{id}/set", handleSetZigbeeDevice)
+
+	// Smart appliances (start/stop/pause/resume/power)
+	r.Get("/api/appliances", handleGetAppliances)
+	r.Get("/api/appliances/{id}", handleGetAppliance)
+	r.Post("/api/appliances/{id}/{action}", handleApplianceCommand)
+
+	// Sensor history for dashboard charts
+	r.Get("/api/history/sensors", handleGetHistorySensors)
+	r.Get("/api/history/sensor/{id}", handleGetSensorHistory)
+
+	// State change journal (JSON or server-sent events)
+	r.Get("/api/journal", handleGetJournal)
+
 	// Weather API
 	r.Get("/api/weather", handleGetWeather)
+	r.Get("/api/weather/locations", handleGetWeatherLocations)
+	r.Get("/api/astronomy", handleGetAstronomy)
+	r.Get("/api/briefing", handleGetBriefing)
 
 	// WebSocket
 	r.Get("/ws", handleWebSocket)
@@ -692,9 +1937,14 @@ func main() {
 	r.Get("/api/camera/{name}/stream", handleCameraStream)
 	r.Post("/api/camera/{name}/talk", handleCameraTalk)
 	r.Get("/api/cameras", handleGetCameras)
+	r.Get("/api/cameras/arrival", handleGetArrivalCameras)
+	r.Put("/api/cameras/arrival", handleUpdateArrivalCameras)
 
 	// Entity states API (for AJAX refresh)
 	r.Get("/api/entities", handleGetEntities(cfg))
+	r.Post("/api/entities/refresh", handleRefreshEntities(cfg))
+	r.Get("/api/entities/grouping", handleGetCardGrouping)
+	r.Put("/api/entities/grouping", handleUpdateCardGrouping)
 
 	// Test doorbell (for debugging)
 	r.Post("/api/doorbell/test", handleTestDoorbell)
@@ -715,13 +1965,41 @@ func main() {
 	r.Post("/api/tablet/kiosk/exit", handleExitKiosk)
 	r.Post("/api/tablet/reload", handleTabletReload)
 	r.Get("/api/tablet/theme", handleGetTabletTheme)
+	r.Get("/api/tablet/screenshot", handleTabletScreenshot)
+	r.Post("/api/tablet/clientlog", handleTabletClientLog)
+	r.Get("/api/tablet/support", handleGetTabletSupport)
+
+	// Client error / crash reports
+	r.Post("/api/clientlog", handlePostClientLog)
+	r.Get("/api/clientlog", handleGetClientLog)
 
 	// Hue API routes
 	r.Get("/api/hue/rooms", handleGetHueRooms)
 	r.Post("/api/hue/light/{id}/toggle", handleToggleHueLight)
 	r.Post("/api/hue/light/{id}/brightness", handleSetHueLightBrightness)
+	r.Post("/api/hue/light/{id}/color", handleSetHueLightColor)
+	r.Post("/api/hue/light/{id}/ct", handleSetHueLightCT)
+	r.Get("/api/hue/sensors", handleGetHueSensors)
+
+	// Adaptive lighting
+	r.Get("/api/lighting/adaptive", handleGetAdaptiveLighting)
+	r.Put("/api/lighting/adaptive", handleUpdateAdaptiveLighting)
+	r.Put("/api/lighting/adaptive/{room}", handleSetAdaptiveRoom)
+
+	// Sun-tracking shading
+	r.Get("/api/shading", handleGetShading)
+	r.Put("/api/shading/rules", handleUpdateShadingRules)
+
+	// Weather-compensated heating curve
+	r.Get("/api/heating/curve", handleGetHeatingCurve)
+	r.Put("/api/heating/curve", handleUpdateHeatingCurve)
+	r.Get("/api/heating/preview", handleGetHeatingPreview)
+	r.Get("/api/hue/light/{id}/effect", handleGetHueLightEffect)
+	r.Post("/api/hue/light/{id}/effect", handleSetHueLightEffect)
 	r.Post("/api/hue/group/{id}/toggle", handleToggleHueGroup)
 	r.Post("/api/hue/group/{id}/brightness", handleSetHueGroupBrightness)
+	r.Post("/api/hue/group/{id}/color", handleSetHueGroupColor)
+	r.Post("/api/hue/group/{id}/ct", handleSetHueGroupCT)
 	r.Post("/api/hue/scene/{id}/activate", handleActivateHueScene)
 	r.Post("/api/hue/entertainment/{id}/activate", handleActivateEntertainment)
 	r.Post("/api/hue/entertainment/deactivate", handleDeactivateEntertainment)
@@ -729,25 +2007,46 @@ func main() {
 
 	// Sync Box routes
 	r.Get("/api/syncbox", handleGetSyncBoxes)
+	r.Post("/api/syncbox/register", handleRegisterSyncBox)
 	r.Get("/api/syncbox/{index}/status", handleGetSyncBoxStatus)
 	r.Post("/api/syncbox/{index}/sync", handleSetSyncBoxSync)
 	r.Post("/api/syncbox/{index}/area", handleSetSyncBoxArea)
 	r.Post("/api/syncbox/{index}/mode", handleSetSyncBoxMode)
 	r.Post("/api/syncbox/{index}/brightness", handleSetSyncBoxBrightness)
+	r.Post("/api/syncbox/{index}/intensity", handleSetSyncBoxIntensity)
 	r.Post("/api/syncbox/{index}/input", handleSetSyncBoxInput)
 
 	// Google Drive routes (photos for screensaver/background)
 	r.Get("/api/drive/photos", handleGetDrivePhotos)
 	r.Get("/api/drive/photos/random", handleGetRandomDrivePhoto)
 	r.Get("/api/drive/photo/{id}", handleGetDrivePhoto)
+
+	// Google Photos album (screensaver source when an album is picked)
+	r.Get("/api/photos/albums", handleGetPhotoAlbums)
+	r.Post("/api/photos/albums", handleCreatePhotoAlbum)
+	r.Delete("/api/photos/albums/{id}", handleDeletePhotoAlbum)
+	r.Put("/api/photos/album", handleSetPhotoAlbum)
+	r.Get("/api/photos", handleGetGooglePhotos)
+	r.Get("/api/photos/random", handleGetRandomGooglePhoto)
+	r.Get("/api/photos/{id}", handleGetGooglePhoto)
 	r.Get("/api/screensaver/config", handleGetScreensaverConfig)
+	r.Get("/api/screensaver/photos", handleGetScreensaverPhotos)
+	r.Get("/api/screensaver/photos/random", handleGetRandomScreensaverPhoto)
+	r.Get("/api/screensaver/photo/{id}", handleGetScreensaverPhoto)
+
+	// Google Classroom assignments
+	r.Get("/api/classroom/upcoming", handleGetClassroomUpcoming)
 
 	// Spotify routes
 	r.Get("/auth/spotify", handleSpotifyAuth)
 	r.Get("/auth/spotify/callback", handleSpotifyCallback)
 	r.Get("/api/spotify/status", handleSpotifyStatus)
+	r.Get("/api/spotify/accounts", handleSpotifyAccounts)
+	r.Get("/api/spotify/token", handleSpotifyToken)
 	r.Get("/api/spotify/playback", handleSpotifyPlayback)
 	r.Get("/api/spotify/devices", handleSpotifyDevices)
+	r.Get("/api/spotify/devices/settings", handleGetSpotifyDeviceSettings)
+	r.Put("/api/spotify/devices/settings", handleUpdateSpotifyDeviceSettings)
 	r.Post("/api/spotify/play", handleSpotifyPlay)
 	r.Post("/api/spotify/pause", handleSpotifyPause)
 	r.Post("/api/spotify/next", handleSpotifyNext)
@@ -757,8 +2056,14 @@ func main() {
 	r.Post("/api/spotify/shuffle", handleSpotifyShuffle)
 	r.Post("/api/spotify/repeat", handleSpotifyRepeat)
 	r.Post("/api/spotify/transfer", handleSpotifyTransfer)
+	r.Get("/api/spotify/queue", handleSpotifyQueue)
+	r.Post("/api/spotify/queue", handleSpotifyAddToQueue)
 	r.Get("/api/spotify/playlists", handleSpotifyPlaylists)
+	r.Post("/api/spotify/playlists", handleSpotifyCreatePlaylist)
 	r.Get("/api/spotify/playlist/{id}/tracks", handleSpotifyPlaylistTracks)
+	r.Post("/api/spotify/playlist/{id}/tracks", handleSpotifyAddPlaylistTracks)
+	r.Delete("/api/spotify/playlist/{id}/tracks", handleSpotifyRemovePlaylistTracks)
+	r.Put("/api/spotify/playlist/{id}/tracks", handleSpotifyReorderPlaylistTracks)
 	r.Get("/api/spotify/search", handleSpotifySearch)
 	r.Get("/api/spotify/recent", handleSpotifyRecentlyPlayed)
 	r.Get("/api/spotify/top/artists", handleSpotifyTopArtists)
@@ -777,9 +2082,13 @@ func main() {
 	r.Get("/api/spotify/library/artists", handleSpotifyLibraryArtists)
 	r.Get("/api/spotify/library/tracks", handleSpotifyLibraryTracks)
 	r.Get("/api/spotify/library/shows", handleSpotifyLibraryShows)
+	r.Get("/api/spotify/show/{id}/episodes", handleSpotifyShowEpisodes)
+	r.Get("/api/spotify/episode/{id}", handleSpotifyEpisode)
+	r.Post("/api/spotify/episode/{id}/resume", handleSpotifyResumeEpisode)
 
 	// Entertainment device routes
 	r.Get("/api/entertainment/devices", handleGetEntertainmentDevices)
+	r.Get("/api/entertainment/discover", handleDiscoverEntertainment)
 	// Sony (soundbar + TV)
 	r.Get("/api/entertainment/sony", handleGetSonyDevices)
 	r.Get("/api/entertainment/sony/{name}/state", handleGetSonyState)
@@ -787,6 +2096,10 @@ func main() {
 	r.Post("/api/entertainment/sony/{name}/volume", handleSonyVolume)
 	r.Post("/api/entertainment/sony/{name}/mute", handleSonyMute)
 	r.Post("/api/entertainment/sony/{name}/input", handleSonyInput)
+	r.Get("/api/entertainment/sony/{name}/inputs", handleGetSonyInputs)
+	r.Get("/api/entertainment/sony/{name}/channels", handleGetSonyChannels)
+	r.Get("/api/entertainment/sony/{name}/apps", handleGetSonyApps)
+	r.Post("/api/entertainment/sony/{name}/apps/{id}/launch", handleLaunchSonyApp)
 	// Shield
 	r.Get("/api/entertainment/shield", handleGetShieldDevices)
 	r.Get("/api/entertainment/shield/{name}/state", handleGetShieldState)
@@ -794,6 +2107,7 @@ func main() {
 	r.Post("/api/entertainment/shield/{name}/navigate", handleShieldNavigate)
 	r.Post("/api/entertainment/shield/{name}/media", handleShieldMedia)
 	r.Post("/api/entertainment/shield/{name}/app", handleShieldApp)
+	r.Post("/api/entertainment/shield/{name}/pair", handleShieldPair)
 	// Xbox
 	r.Get("/api/entertainment/xbox", handleGetXboxDevices)
 	r.Get("/api/entertainment/xbox/{name}/state", handleGetXboxState)
@@ -805,11 +2119,124 @@ func main() {
 	r.Get("/api/entertainment/ps5/{name}/state", handleGetPS5State)
 	r.Post("/api/entertainment/ps5/{name}/power", handlePS5Power)
 
+	// Activity macros
+	r.Get("/api/entertainment/activities", handleGetActivities)
+	r.Post("/api/entertainment/activity/{name}", handleRunActivity)
+
+	// IR blasters (Broadlink, ESPHome)
+	r.Get("/api/ir", handleGetIRDevices)
+	r.Post("/api/ir/{device}/{command}", handleSendIR)
+	r.Put("/api/ir/{device}/{command}", handleSetIRCode)
+	r.Delete("/api/ir/{device}/{command}", handleDeleteIRCode)
+	r.Post("/api/ir/{device}/{command}/learn", handleLearnIR)
+
+	// Sonos
+	r.Get("/api/sonos/zones", handleGetSonosZones)
+	r.Get("/api/sonos/rooms", handleGetSonosRooms)
+	r.Post("/api/sonos/rooms/{room}/join", handleSonosJoin)
+	r.Post("/api/sonos/rooms/{room}/leave", handleSonosLeave)
+	r.Put("/api/sonos/rooms/{room}/volume", handleSonosVolume)
+	r.Post("/api/sonos/rooms/{room}/{action}", handleSonosControl)
+
 	// Icon serving
 	r.Get("/icon/{name}", icons.Handler())
 
-	log.Printf("Server starting on :%s", cfg.Port)
-	log.Fatal(http.ListenAndServe(":"+cfg.Port, r))
+	if cfg.TLSCertFile != "" && cfg.TLSKeyFile != "" {
+		tlsConfig, err := buildTLSConfig(cfg)
+		if err != nil {
+			log.Fatalf("Failed to configure TLS: %v", err)
+		}
+		server := &http.Server{
+			Addr:      ":" + cfg.Port,
+			Handler:   r,
+			TLSConfig: tlsConfig,
+		}
+		log.Printf("Server starting on :%s (TLS)", cfg.Port)
+		log.Fatal(server.ListenAndServeTLS(cfg.TLSCertFile, cfg.TLSKeyFile))
+	}
+
+	log.Printf("Server starting on :%s", cfg.Port)
+	log.Fatal(http.ListenAndServe(":"+cfg.Port, r))
+}
+
+// buildTLSConfig creates the server TLS config. Client certificates are requested
+// but only verified (not required) at the handshake; RequireClientCert enforces them
+// per route group so the kiosk UI keeps working without a certificate.
+func buildTLSConfig(cfg Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		caPEM, err := os.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", cfg.TLSClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+		log.Printf("mTLS enabled for route groups: %v", cfg.MTLSRouteGroups)
+	} else if len(cfg.MTLSRouteGroups) > 0 {
+		log.Println("Warning: MTLS_ROUTE_GROUPS set without TLS_CLIENT_CA_FILE, those routes will reject all requests")
+	}
+
+	return tlsConfig, nil
+}
+
+// mtlsRouteGroups maps route group names (for MTLS_ROUTE_GROUPS) to the paths they cover
+var mtlsRouteGroups = map[string]func(path string) bool{
+	"locks": func(path string) bool {
+		return strings.HasPrefix(path, "/api/toggle/lock.") || strings.HasPrefix(path, "/api/lock/") ||
+			strings.HasPrefix(path, "/api/locks/") ||
+			strings.HasPrefix(path, "/api/ha/service/lock/") || strings.HasPrefix(path, "/api/egress/") ||
+			path == "/api/visitors" || strings.HasPrefix(path, "/api/visitors/")
+	},
+	"cameras": func(path string) bool {
+		return path == "/api/cameras" || strings.HasPrefix(path, "/api/cameras/") || strings.HasPrefix(path, "/api/camera/")
+	},
+	"spotify_token": func(path string) bool {
+		return path == "/api/spotify/token"
+	},
+	"vault": func(path string) bool {
+		return path == "/api/vault" || strings.HasPrefix(path, "/api/vault/")
+	},
+}
+
+// RequireClientCert is a middleware that rejects requests to the given route groups
+// unless they arrived over TLS with a client certificate verified against TLS_CLIENT_CA_FILE
+func RequireClientCert(groups []string) func(http.Handler) http.Handler {
+	var matchers []func(string) bool
+	for _, group := range groups {
+		match, ok := mtlsRouteGroups[group]
+		if !ok {
+			log.Printf("Warning: Unknown mTLS route group %q", group)
+			continue
+		}
+		matchers = append(matchers, match)
+	}
+
+	return func(next http.Handler) http.Handler {
+		if len(matchers) == 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			for _, match := range matchers {
+				if !match(r.URL.Path) {
+					continue
+				}
+				if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+					log.Printf("mTLS: rejected %s %s from %s (no verified client certificate)", r.Method, r.URL.Path, r.RemoteAddr)
+					http.Error(w, "Client certificate required", http.StatusForbidden)
+					return
+				}
+				break
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 func handleCalendar(w http.ResponseWriter, r *http.Request) {
@@ -916,7 +2343,7 @@ func handleCalendar(w http.ResponseWriter, r *http.Request) {
 		"PrevDate":          prevDate,
 		"NextDate":          nextDate,
 		"TodayDate":         todayDate,
-		"WeatherConfigured": appConfig.OpenWeatherAPIKey != "",
+		"WeatherConfigured": weatherClient != nil,
 		"AsyncLoad":         asyncLoad,
 	}
 
@@ -1263,8 +2690,8 @@ func handleHome(cfg Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var cards []*homeassistant.Card
 
-		if haClient != nil && len(cfg.Entities) > 0 {
-			entities, err := haClient.GetStates(cfg.Entities)
+		if ids := configuredEntities(); haClient != nil && len(ids) > 0 {
+			entities, err := haClient.GetStates(ids)
 			if err != nil {
 				log.Printf("Error fetching HA states: %v", err)
 			}
@@ -1276,7 +2703,7 @@ func handleHome(cfg Config) http.HandlerFunc {
 			populateLightGroupMembers(cards)
 		}
 
-		groups := homeassistant.GroupCards(cards)
+		groups := groupCards(cards)
 
 		// Get camera list
 		var cameras []map[string]string
@@ -1292,19 +2719,71 @@ func handleHome(cfg Config) http.HandlerFunc {
 		data := map[string]interface{}{
 			"Title":             "Home",
 			"Groups":            groups,
-			"WeatherConfigured": appConfig.OpenWeatherAPIKey != "",
+			"WeatherConfigured": weatherClient != nil,
 			"Cameras":           cameras,
 		}
 		getTemplate("home").ExecuteTemplate(w, "base", data)
 	}
 }
 
+// configuredEntities returns the Home Assistant entities shown on the dashboard
+func configuredEntities() []string {
+	haEntitiesMu.RLock()
+	defer haEntitiesMu.RUnlock()
+	return haEntityIDs
+}
+
+// resolveHAEntities expands the domain:, area: and label: selectors in
+// HA_ENTITIES, minus HA_ENTITIES_EXCLUDE, so devices added in Home Assistant
+// show up without editing the list. If Home Assistant can't be reached, plain
+// entity IDs are used until the next refresh. Returns the entity count.
+func resolveHAEntities(cfg Config) (int, error) {
+	ids, err := haClient.ResolveEntities(cfg.Entities, cfg.EntityExclude)
+	if err != nil {
+		haEntitiesMu.Lock()
+		if haEntityIDs == nil {
+			for _, id := range cfg.Entities {
+				if !homeassistant.IsSelector(id) && !slices.Contains(cfg.EntityExclude, id) {
+					haEntityIDs = append(haEntityIDs, id)
+				}
+			}
+		}
+		haEntitiesMu.Unlock()
+		return 0, err
+	}
+
+	haEntitiesMu.Lock()
+	haEntityIDs = ids
+	haEntitiesMu.Unlock()
+	return len(ids), nil
+}
+
+// handleRefreshEntities re-resolves the HA_ENTITIES selectors
+func handleRefreshEntities(cfg Config) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if haClient == nil {
+			http.Error(w, "Home Assistant not configured", http.StatusServiceUnavailable)
+			return
+		}
+		if _, err := resolveHAEntities(cfg); err != nil {
+			log.Printf("Error resolving HA entities: %v", err)
+			http.Error(w, "Failed to resolve entities: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"entities": configuredEntities(),
+		})
+	}
+}
+
 func handleGetEntities(cfg Config) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var cards []*homeassistant.Card
 
-		if haClient != nil && len(cfg.Entities) > 0 {
-			entities, err := haClient.GetStates(cfg.Entities)
+		if ids := configuredEntities(); haClient != nil && len(ids) > 0 {
+			entities, err := haClient.GetStates(ids)
 			if err != nil {
 				log.Printf("Error fetching HA states: %v", err)
 				http.Error(w, "Failed to fetch states", http.StatusInternalServerError)
@@ -1318,30 +2797,85 @@ func handleGetEntities(cfg Config) http.HandlerFunc {
 			populateLightGroupMembers(cards)
 		}
 
-		groups := homeassistant.GroupCards(cards)
+		groups := groupCards(cards)
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(groups)
 	}
 }
 
-func handleToggle(w http.ResponseWriter, r *http.Request) {
-	if haClient == nil {
-		http.Error(w, "HA not configured", http.StatusServiceUnavailable)
+// groupCards groups dashboard cards with the saved grouping scheme
+func groupCards(cards []*homeassistant.Card) []*homeassistant.CardGroup {
+	if cardGrouping == nil {
+		return homeassistant.GroupCards(cards)
+	}
+	grouping := cardGrouping.Get()
+
+	var areas map[string]string
+	if grouping.Scheme == homeassistant.GroupByArea {
+		ids := make([]string, len(cards))
+		for i, card := range cards {
+			ids[i] = card.EntityID
+		}
+		var err error
+		areas, err = areasCache.Get(strings.Join(ids, ","), func() (map[string]string, error) {
+			return haClient.EntityAreas(ids)
+		})
+		if err != nil {
+			log.Printf("Warning: Failed to get entity areas: %v", err)
+		}
+	}
+	return homeassistant.GroupCardsBy(cards, grouping, areas)
+}
+
+func handleGetCardGrouping(w http.ResponseWriter, r *http.Request) {
+	if cardGrouping == nil {
+		http.Error(w, "Home Assistant not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	entityID := chi.URLParam(r, "entityID")
-	if entityID == "" {
-		http.Error(w, "Missing entity ID", http.StatusBadRequest)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cardGrouping.Get())
+}
+
+func handleUpdateCardGrouping(w http.ResponseWriter, r *http.Request) {
+	if cardGrouping == nil {
+		http.Error(w, "Home Assistant not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var grouping homeassistant.Grouping
+	if err := json.NewDecoder(r.Body).Decode(&grouping); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if grouping.Scheme == "" {
+		grouping.Scheme = homeassistant.GroupByDefault
+	}
+	if err := grouping.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := cardGrouping.Set(grouping); err != nil {
+		log.Printf("Error saving card grouping: %v", err)
+		http.Error(w, "Failed to save card grouping: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cardGrouping.Get())
+}
+
+// errNotToggleable is returned by toggleEntity for entity IDs it can't toggle
+var errNotToggleable = errors.New("cannot toggle this entity type")
+
+// toggleEntity toggles a Home Assistant entity, using lock/unlock for locks.
+// Returns the service that was called.
+func toggleEntity(entityID string) (string, error) {
 	// Determine domain and service
 	parts := strings.Split(entityID, ".")
 	if len(parts) != 2 {
-		http.Error(w, "Invalid entity ID", http.StatusBadRequest)
-		return
+		return "", fmt.Errorf("invalid entity ID: %w", errNotToggleable)
 	}
 
 	domain := parts[0]
@@ -1355,8 +2889,7 @@ func handleToggle(w http.ResponseWriter, r *http.Request) {
 		// Check current state to determine lock/unlock
 		entity, err := haClient.GetState(entityID)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			return "", err
 		}
 		if entity.State == "locked" {
 			service = "unlock"
@@ -1364,11 +2897,42 @@ func handleToggle(w http.ResponseWriter, r *http.Request) {
 			service = "lock"
 		}
 	default:
-		http.Error(w, "Cannot toggle this entity type", http.StatusBadRequest)
+		return "", errNotToggleable
+	}
+
+	return service, haClient.CallService(domain, service, entityID)
+}
+
+func handleToggle(w http.ResponseWriter, r *http.Request) {
+	if haClient == nil {
+		http.Error(w, "HA not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	entityID := chi.URLParam(r, "entityID")
+	if entityID == "" {
+		http.Error(w, "Missing entity ID", http.StatusBadRequest)
+		return
+	}
+
+	isLock := strings.HasPrefix(entityID, "lock.")
+	if isLock && !authorizeLock(w, r, entityID, "toggle", r.Header.Get("X-Lock-PIN")) {
 		return
 	}
 
-	if err := haClient.CallService(domain, service, entityID); err != nil {
+	service, err := toggleEntity(entityID)
+	if isLock && !errors.Is(err, errNotToggleable) {
+		action := service
+		if action == "" {
+			action = "toggle"
+		}
+		lockGuard.Record(entityID, action, requestDeviceID(r), err)
+	}
+	if errors.Is(err, errNotToggleable) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
 		log.Printf("Error toggling %s: %v", entityID, err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -1381,6 +2945,12 @@ func handleToggle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Record the resulting direction so suggestions can say "turn on" rather than "toggle"
+	if service == "toggle" && (entity.State == "on" || entity.State == "off") {
+		service = "turn_" + entity.State
+	}
+	recordAction(r, actions.KindEntity, entityID, service)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(entity.ToCard())
 }
@@ -1516,2179 +3086,10599 @@ func handleSetClimateFanMode(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(entity.ToCard())
 }
 
-type CreateEventRequest struct {
-	Type        string `json:"type"`        // "event" or "task"
-	Title       string `json:"title"`
-	Date        string `json:"date"`        // YYYY-MM-DD (start date)
-	EndDate     string `json:"endDate"`     // YYYY-MM-DD (end date, optional - defaults to Date)
-	Time        string `json:"time"`        // HH:MM (optional, empty = all day)
-	EndTime     string `json:"endTime"`     // HH:MM (optional)
-	Location    string `json:"location"`    // optional
-	Description string `json:"description"` // optional
-	Repeat      string `json:"repeat"`      // optional: daily, weekly, monthly, yearly, weekdays
-	CalendarID  string `json:"calendarId"`  // optional: calendar to create event on
+// haServiceAllowed reports whether domain.service matches the configured allowlist
+func haServiceAllowed(domain, service string) bool {
+	for _, allowed := range appConfig.HAServiceAllowlist {
+		d, svc, ok := strings.Cut(allowed, ".")
+		if !ok || d != domain {
+			continue
+		}
+		if svc == "*" || svc == service {
+			return true
+		}
+	}
+	return false
 }
 
-func handleCreateEvent(w http.ResponseWriter, r *http.Request) {
-	if calClient == nil {
-		http.Error(w, "Calendar not configured", http.StatusServiceUnavailable)
+// haTargetKeys are the service data keys besides entity_id that select
+// entities in Home Assistant
+var haTargetKeys = []string{"target", "area_id", "device_id", "floor_id", "label_id"}
+
+// handleCallHAService calls an allowlisted HA service with the request body as service data
+func handleCallHAService(w http.ResponseWriter, r *http.Request) {
+	if haClient == nil {
+		http.Error(w, "HA not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	if !calClient.IsAuthorized() {
-		http.Error(w, "Calendar not authorized", http.StatusUnauthorized)
+	domain := chi.URLParam(r, "domain")
+	service := chi.URLParam(r, "service")
+	if !haServiceAllowed(domain, service) {
+		http.Error(w, fmt.Sprintf("Service %s.%s is not allowed", domain, service), http.StatusForbidden)
 		return
 	}
 
-	var req CreateEventRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	// Body is optional (e.g. script.reload takes no data)
+	var data map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil && err != io.EOF {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if req.Title == "" || req.Date == "" {
-		http.Error(w, "Title and date are required", http.StatusBadRequest)
-		return
+	// Lock services are subject to the same PIN check as toggles. The PIN may come in
+	// the X-Lock-PIN header or as "pin" in the body; it is never forwarded to HA.
+	// Only entity_id may target locks, so every lock HA acts on is checked.
+	var lockIDs []string
+	if domain == "lock" {
+		pin := r.Header.Get("X-Lock-PIN")
+		if p, ok := data["pin"].(string); ok {
+			pin = p
+			delete(data, "pin")
+		}
+		for _, key := range haTargetKeys {
+			if _, ok := data[key]; ok {
+				http.Error(w, fmt.Sprintf("%s is not allowed for lock services; use entity_id", key), http.StatusBadRequest)
+				return
+			}
+		}
+		ids, _ := data["entity_id"].(string)
+		for _, id := range strings.Split(ids, ",") {
+			id = strings.TrimSpace(id)
+			if !strings.HasPrefix(id, "lock.") {
+				http.Error(w, "entity_id must list locks", http.StatusBadRequest)
+				return
+			}
+			lockIDs = append(lockIDs, id)
+		}
+		for _, id := range lockIDs {
+			if !authorizeLock(w, r, id, service, pin) {
+				return
+			}
+		}
 	}
 
-	// Parse start date
-	startDate, err := time.ParseInLocation("2006-01-02", req.Date, appConfig.Timezone)
-	if err != nil {
-		http.Error(w, "Invalid date format", http.StatusBadRequest)
-		return
+	// Media volume is subject to the quiet hours cap like the media endpoints
+	calls := []haServiceCall{{service: service, data: data}}
+	if domain == "media_player" {
+		var err error
+		if calls, err = clampServiceVolume(w, service, data); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 	}
 
-	// Parse end date (defaults to start date if not provided)
-	endDateStr := req.EndDate
-	if endDateStr == "" {
-		endDateStr = req.Date
+	var changed []*homeassistant.Entity
+	var err error
+	for _, call := range calls {
+		var c []*homeassistant.Entity
+		c, err = haClient.CallServiceData(domain, call.service, call.data)
+		changed = append(changed, c...)
+		if err != nil {
+			break
+		}
+	}
+	for _, id := range lockIDs {
+		lockGuard.Record(id, service, requestDeviceID(r), err)
 	}
-	endDate, err := time.ParseInLocation("2006-01-02", endDateStr, appConfig.Timezone)
 	if err != nil {
-		http.Error(w, "Invalid end date format", http.StatusBadRequest)
+		log.Printf("Error calling HA service %s.%s: %v", domain, service, err)
+		http.Error(w, "Failed to call service: "+err.Error(), http.StatusBadGateway)
 		return
 	}
 
-	var start, end time.Time
-	var allDay bool
+	if entityID, ok := data["entity_id"].(string); ok {
+		recordAction(r, actions.KindEntity, entityID, service)
+	}
 
-	if req.Time == "" {
-		// All day event
-		allDay = true
-		start = startDate
-		// For all-day events, end date is exclusive (add 1 day)
-		end = endDate.AddDate(0, 0, 1)
-	} else {
-		// Timed event
-		allDay = false
-		startTime, err := time.ParseInLocation("2006-01-02 15:04", req.Date+" "+req.Time, appConfig.Timezone)
+	cards := make([]*homeassistant.Card, 0, len(changed))
+	for _, e := range changed {
+		cards = append(cards, e.ToCard())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"changed": cards,
+	})
+}
+
+// haVolumeStep is how far media_player.volume_up raises the volume by default
+const haVolumeStep = 10
+
+// haServiceCall is one Home Assistant service call
+type haServiceCall struct {
+	service string
+	data    map[string]interface{}
+}
+
+// clampServiceVolume applies the quiet hours cap to a media_player service call,
+// lowering volume_level in place. Under the cap, volume_up becomes a volume_set
+// per player that stops at the cap, so the players must be listed in entity_id.
+// Returns the calls to make.
+func clampServiceVolume(w http.ResponseWriter, service string, data map[string]interface{}) ([]haServiceCall, error) {
+	switch service {
+	case "volume_set":
+		level, err := serviceVolumeLevel(data["volume_level"])
 		if err != nil {
-			http.Error(w, "Invalid time format", http.StatusBadRequest)
-			return
+			return nil, err
 		}
-		start = startTime
-
-		if req.EndTime != "" {
-			// Use end date with end time
-			endTime, err := time.ParseInLocation("2006-01-02 15:04", endDateStr+" "+req.EndTime, appConfig.Timezone)
+		data["volume_level"] = level
+		player, _ := data["entity_id"].(string)
+		if player == "" {
+			player = "media_player"
+		}
+		requested := int(level*100 + 0.5)
+		if volume := clampVolume(w, player, requested); volume < requested {
+			data["volume_level"] = float64(volume) / 100
+		}
+	case "volume_up":
+		if quietHours == nil {
+			break
+		}
+		if _, _, ok := quietHours.Limit(time.Now()); !ok {
+			break
+		}
+		for _, key := range haTargetKeys {
+			if _, ok := data[key]; ok {
+				return nil, fmt.Errorf("%s is not allowed during quiet hours; use entity_id", key)
+			}
+		}
+		ids, _ := data["entity_id"].(string)
+		if ids == "" {
+			return nil, fmt.Errorf("entity_id is required during quiet hours")
+		}
+		var calls []haServiceCall
+		for _, id := range strings.Split(ids, ",") {
+			id = strings.TrimSpace(id)
+			entity, err := haClient.GetState(id)
 			if err != nil {
-				http.Error(w, "Invalid end time format", http.StatusBadRequest)
-				return
+				return nil, fmt.Errorf("failed to read %s volume: %w", id, err)
 			}
-			end = endTime
-		} else {
-			end = start.Add(1 * time.Hour) // Default 1 hour duration
+			level, ok := entity.Attributes["volume_level"].(float64)
+			if !ok {
+				return nil, fmt.Errorf("%s has no volume level", id)
+			}
+			current := int(level*100 + 0.5)
+			requested := current + haVolumeStep
+			call := haServiceCall{service: service, data: map[string]interface{}{"entity_id": id}}
+			if volume := clampVolume(w, id, requested); volume < requested {
+				// Up never turns it down
+				call = haServiceCall{service: "volume_set", data: map[string]interface{}{"entity_id": id, "volume_level": float64(max(volume, current)) / 100}}
+			}
+			calls = append(calls, call)
 		}
+		return calls, nil
 	}
+	return []haServiceCall{{service: service, data: data}}, nil
+}
 
-	// Build event options
-	opts := &calendar.CreateEventOptions{
-		CalendarID:  req.CalendarID,
-		Location:    req.Location,
-		Description: req.Description,
-	}
-
-	// Convert repeat string to RRULE
-	if req.Repeat != "" {
-		rrule := repeatToRRule(req.Repeat)
-		if rrule != "" {
-			opts.Recurrence = []string{rrule}
+// serviceVolumeLevel reads a volume_level, which HA accepts as a number or a
+// numeric string, between 0 and 1
+func serviceVolumeLevel(v interface{}) (float64, error) {
+	var level float64
+	switch v := v.(type) {
+	case float64:
+		level = v
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		if err != nil {
+			return 0, fmt.Errorf("volume_level must be a number")
 		}
+		level = f
+	case nil:
+		return 0, fmt.Errorf("volume_level is required")
+	default:
+		return 0, fmt.Errorf("volume_level must be a number")
 	}
-
-	log.Printf("Creating event: title=%q, date=%s, start=%v, end=%v, allDay=%v, calendarId=%q", req.Title, req.Date, start, end, allDay, req.CalendarID)
-	event, err := calClient.CreateEvent(r.Context(), req.Title, start, end, allDay, opts)
-	if err != nil {
-		log.Printf("Error creating event: %v", err)
-		http.Error(w, "Failed to create event: "+err.Error(), http.StatusInternalServerError)
-		return
+	if !(level >= 0 && level <= 1) { // Also catches NaN
+		return 0, fmt.Errorf("volume_level must be between 0 and 1")
 	}
+	return level, nil
+}
 
-	// Invalidate server-side cache so next page load shows the new event
-	invalidateCalendarCache()
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(event)
+// CoverPositionRequest sets position and/or tilt (0 = closed, 100 = open)
+type CoverPositionRequest struct {
+	Position *int `json:"position"`
+	Tilt     *int `json:"tilt"`
 }
 
-func handleGetEvent(w http.ResponseWriter, r *http.Request) {
-	if calClient == nil || !calClient.IsAuthorized() {
-		http.Error(w, "Calendar not configured or not authorized", http.StatusServiceUnavailable)
+func handleSetCoverPosition(w http.ResponseWriter, r *http.Request) {
+	if haClient == nil {
+		http.Error(w, "HA not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	calendarID, _ := url.QueryUnescape(chi.URLParam(r, "calendarID"))
-	eventID, _ := url.QueryUnescape(chi.URLParam(r, "eventID"))
+	entityID := chi.URLParam(r, "entityID")
+	if !strings.HasPrefix(entityID, "cover.") {
+		http.Error(w, "Not a cover: "+entityID, http.StatusBadRequest)
+		return
+	}
 
-	event, err := calClient.GetEvent(r.Context(), calendarID, eventID)
-	if err != nil {
-		log.Printf("Error getting event: %v", err)
-		http.Error(w, "Failed to get event: "+err.Error(), http.StatusInternalServerError)
+	var req CoverPositionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Position == nil && req.Tilt == nil {
+		http.Error(w, "position or tilt is required", http.StatusBadRequest)
+		return
+	}
+	if (req.Position != nil && (*req.Position < 0 || *req.Position > 100)) ||
+		(req.Tilt != nil && (*req.Tilt < 0 || *req.Tilt > 100)) {
+		http.Error(w, "position and tilt must be between 0 and 100", http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	// Invalidate calendar cache after creating event
-	invalidateCalendarCache()
+	if req.Position != nil {
+		if err := haClient.SetCoverPosition(entityID, *req.Position); err != nil {
+			log.Printf("Error setting position for %s: %v", entityID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		recordAction(r, actions.KindEntity, entityID, "set_cover_position")
+	}
+	if req.Tilt != nil {
+		if err := haClient.SetCoverTiltPosition(entityID, *req.Tilt); err != nil {
+			log.Printf("Error setting tilt for %s: %v", entityID, err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
 
-	json.NewEncoder(w).Encode(event)
-}
+	// Return updated state
+	entity, err := haClient.GetState(entityID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-type UpdateEventRequest struct {
-	Title       string `json:"title"`
-	Date        string `json:"date"`
-	Time        string `json:"time"`
-	EndTime     string `json:"endTime"`
-	Location    string `json:"location"`
-	Description string `json:"description"`
-	Repeat      string `json:"repeat"`
-	ColorID     string `json:"colorId"`
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entity.ToCard())
 }
 
-func handleUpdateEvent(w http.ResponseWriter, r *http.Request) {
-	if calClient == nil || !calClient.IsAuthorized() {
-		http.Error(w, "Calendar not configured or not authorized", http.StatusServiceUnavailable)
-		return
+// haMediaPlayerCards returns cards for the configured media_player entities
+func haMediaPlayerCards() []*homeassistant.Card {
+	if haClient == nil {
+		return nil
+	}
+	var ids []string
+	for _, id := range configuredEntities() {
+		if strings.HasPrefix(id, "media_player.") {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
 	}
 
-	calendarID, _ := url.QueryUnescape(chi.URLParam(r, "calendarID"))
-	eventID, _ := url.QueryUnescape(chi.URLParam(r, "eventID"))
+	entities, err := haClient.GetStates(ids)
+	if err != nil {
+		log.Printf("Error fetching media players: %v", err)
+		return nil
+	}
+	cards := make([]*homeassistant.Card, 0, len(entities))
+	for _, e := range entities {
+		cards = append(cards, e.ToCard())
+	}
+	return cards
+}
 
-	var req UpdateEventRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+// MediaCommandRequest carries the value for volume, mute and source commands
+type MediaCommandRequest struct {
+	Volume *float64 `json:"volume"` // 0-1
+	Muted  *bool    `json:"muted"`
+	Source string   `json:"source"`
+}
+
+// mediaServices maps /api/media commands without arguments to media_player services
+var mediaServices = map[string]string{
+	"play":        "media_play",
+	"pause":       "media_pause",
+	"play_pause":  "media_play_pause",
+	"stop":        "media_stop",
+	"next":        "media_next_track",
+	"previous":    "media_previous_track",
+	"volume_up":   "volume_up",
+	"volume_down": "volume_down",
+	"turn_on":     "turn_on",
+	"turn_off":    "turn_off",
+	"toggle":      "toggle",
+}
+
+// handleMediaCommand controls an HA media_player: play, pause, play_pause, stop, next,
+// previous, volume ({"volume":0.4}), volume_up, volume_down, mute ({"muted":true}),
+// source ({"source":"HDMI 1"}), turn_on, turn_off, toggle
+func handleMediaCommand(w http.ResponseWriter, r *http.Request) {
+	if haClient == nil {
+		http.Error(w, "HA not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	if req.Title == "" || req.Date == "" {
-		http.Error(w, "Title and date are required", http.StatusBadRequest)
+	entityID := chi.URLParam(r, "entityID")
+	command := chi.URLParam(r, "command")
+	if !strings.HasPrefix(entityID, "media_player.") {
+		http.Error(w, "Not a media player: "+entityID, http.StatusBadRequest)
 		return
 	}
 
-	date, err := time.ParseInLocation("2006-01-02", req.Date, appConfig.Timezone)
-	if err != nil {
-		http.Error(w, "Invalid date format", http.StatusBadRequest)
+	var req MediaCommandRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	var start, end time.Time
-	var allDay bool
-
-	if req.Time == "" {
-		allDay = true
-		start = date
-		end = date.AddDate(0, 0, 1)
-	} else {
-		allDay = false
-		startTime, err := time.ParseInLocation("2006-01-02 15:04", req.Date+" "+req.Time, appConfig.Timezone)
-		if err != nil {
-			http.Error(w, "Invalid time format", http.StatusBadRequest)
-			return
-		}
-		start = startTime
-
-		if req.EndTime != "" {
-			endTime, err := time.ParseInLocation("2006-01-02 15:04", req.Date+" "+req.EndTime, appConfig.Timezone)
-			if err != nil {
-				http.Error(w, "Invalid end time format", http.StatusBadRequest)
+	data := map[string]interface{}{"entity_id": entityID}
+	service, ok := mediaServices[command]
+	if !ok {
+		switch command {
+		case "volume":
+			if req.Volume == nil || *req.Volume < 0 || *req.Volume > 1 {
+				http.Error(w, "volume must be between 0 and 1", http.StatusBadRequest)
 				return
 			}
-			end = endTime
-			if !end.After(start) {
-				end = end.AddDate(0, 0, 1)
+			service = "volume_set"
+			data["volume_level"] = *req.Volume
+			requested := int(*req.Volume*100 + 0.5)
+			if volume := clampVolume(w, entityID, requested); volume < requested {
+				data["volume_level"] = float64(volume) / 100
 			}
-		} else {
-			end = start.Add(1 * time.Hour)
+		case "mute":
+			if req.Muted == nil {
+				http.Error(w, "muted is required", http.StatusBadRequest)
+				return
+			}
+			service = "volume_mute"
+			data["is_volume_muted"] = *req.Muted
+		case "source":
+			if req.Source == "" {
+				http.Error(w, "source is required", http.StatusBadRequest)
+				return
+			}
+			service = "select_source"
+			data["source"] = req.Source
+		default:
+			http.Error(w, "Unknown media command: "+command, http.StatusBadRequest)
+			return
 		}
 	}
 
-	opts := &calendar.CreateEventOptions{
-		Location:    req.Location,
-		Description: req.Description,
-		ColorID:     req.ColorID,
-	}
-
-	if req.Repeat != "" {
-		rrule := repeatToRRule(req.Repeat)
-		if rrule != "" {
-			opts.Recurrence = []string{rrule}
-		}
+	if _, err := haClient.CallServiceData("media_player", service, data); err != nil {
+		log.Printf("Error sending %s to %s: %v", command, entityID, err)
+		http.Error(w, "Failed to control media player: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	event, err := calClient.UpdateEvent(r.Context(), calendarID, eventID, req.Title, start, end, allDay, opts)
+	// Return updated state
+	entity, err := haClient.GetState(entityID)
 	if err != nil {
-		log.Printf("Error updating event: %v", err)
-		http.Error(w, "Failed to update event: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	invalidateCalendarCache()
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(event)
+	json.NewEncoder(w).Encode(entity.ToCard())
 }
 
-type PatchEventRequest struct {
-	Title       *string `json:"title,omitempty"`
-	Date        *string `json:"date,omitempty"`
-	Time        *string `json:"time,omitempty"`
-	EndTime     *string `json:"endTime,omitempty"`
-	Location    *string `json:"location,omitempty"`
-	Description *string `json:"description,omitempty"`
-	ColorID     *string `json:"colorId,omitempty"`
+// VacuumCommandRequest is the body for /api/vacuum/{entityID}/command
+type VacuumCommandRequest struct {
+	Command  string `json:"command"`  // start, pause, stop, return_to_base, locate, clean_spot, fan_speed
+	FanSpeed string `json:"fanSpeed"` // Required for fan_speed, one of the card's fanSpeedList
 }
 
-func handlePatchEvent(w http.ResponseWriter, r *http.Request) {
-	if calClient == nil || !calClient.IsAuthorized() {
-		http.Error(w, "Calendar not configured or not authorized", http.StatusServiceUnavailable)
+// vacuumServices maps vacuum commands without arguments to vacuum services
+var vacuumServices = map[string]string{
+	"start":          "start",
+	"pause":          "pause",
+	"stop":           "stop",
+	"return_to_base": "return_to_base",
+	"locate":         "locate",
+	"clean_spot":     "clean_spot",
+}
+
+// handleVacuumCommand controls an HA vacuum and returns the updated card
+func handleVacuumCommand(w http.ResponseWriter, r *http.Request) {
+	if haClient == nil {
+		http.Error(w, "HA not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	calendarID, _ := url.QueryUnescape(chi.URLParam(r, "calendarID"))
-	eventID, _ := url.QueryUnescape(chi.URLParam(r, "eventID"))
+	entityID := chi.URLParam(r, "entityID")
+	if !strings.HasPrefix(entityID, "vacuum.") {
+		http.Error(w, "Not a vacuum: "+entityID, http.StatusBadRequest)
+		return
+	}
 
-	var req PatchEventRequest
+	var req VacuumCommandRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	opts := &calendar.UpdateEventOptions{}
-
-	if req.Title != nil {
-		opts.Title = req.Title
-	}
-	if req.Location != nil {
-		opts.Location = req.Location
-	}
-	if req.Description != nil {
-		opts.Description = req.Description
-	}
-	if req.ColorID != nil {
-		opts.ColorID = req.ColorID
-	}
-
-	// Handle time updates
-	if req.Date != nil {
-		date, err := time.ParseInLocation("2006-01-02", *req.Date, appConfig.Timezone)
-		if err != nil {
-			http.Error(w, "Invalid date format", http.StatusBadRequest)
+	data := map[string]interface{}{"entity_id": entityID}
+	service, ok := vacuumServices[req.Command]
+	if !ok {
+		if req.Command != "fan_speed" {
+			http.Error(w, "Unknown vacuum command: "+req.Command, http.StatusBadRequest)
 			return
 		}
-
-		if req.Time != nil && *req.Time != "" {
-			startTime, err := time.ParseInLocation("2006-01-02 15:04", *req.Date+" "+*req.Time, appConfig.Timezone)
-			if err != nil {
-				http.Error(w, "Invalid time format", http.StatusBadRequest)
-				return
-			}
-			opts.Start = &startTime
-			allDay := false
-			opts.AllDay = &allDay
-		} else {
-			opts.Start = &date
-			allDay := true
-			opts.AllDay = &allDay
+		if req.FanSpeed == "" {
+			http.Error(w, "fanSpeed is required", http.StatusBadRequest)
+			return
 		}
+		service = "set_fan_speed"
+		data["fan_speed"] = req.FanSpeed
+	}
 
-		if req.EndTime != nil && *req.EndTime != "" {
-			endTime, err := time.ParseInLocation("2006-01-02 15:04", *req.Date+" "+*req.EndTime, appConfig.Timezone)
-			if err != nil {
-				http.Error(w, "Invalid end time format", http.StatusBadRequest)
-				return
-			}
-			if opts.Start != nil && !endTime.After(*opts.Start) {
-				endTime = endTime.AddDate(0, 0, 1)
-			}
-			opts.End = &endTime
-		}
+	if _, err := haClient.CallServiceData("vacuum", service, data); err != nil {
+		log.Printf("Error sending %s to %s: %v", req.Command, entityID, err)
+		http.Error(w, "Failed to control vacuum: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
+	recordAction(r, actions.KindEntity, entityID, service)
 
-	event, err := calClient.PatchEvent(r.Context(), calendarID, eventID, opts)
+	// Return updated state
+	entity, err := haClient.GetState(entityID)
 	if err != nil {
-		log.Printf("Error patching event: %v", err)
-		http.Error(w, "Failed to patch event: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	invalidateCalendarCache()
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(event)
+	json.NewEncoder(w).Encode(entity.ToCard())
 }
 
-func handleDeleteEvent(w http.ResponseWriter, r *http.Request) {
-	if calClient == nil || !calClient.IsAuthorized() {
-		http.Error(w, "Calendar not configured or not authorized", http.StatusServiceUnavailable)
-		return
-	}
+// VacuumMap is the response of /api/vacuum/{entityID}/map
+type VacuumMap struct {
+	EntityID string           `json:"entityId"`
+	Image    string           `json:"image,omitempty"`    // Map image URL when VACUUM_MAPS has one
+	Segments []vacuum.Segment `json:"segments,omitempty"` // Rooms reported by a Valetudo robot
+	Regions  []vacuum.Region  `json:"regions"`
+}
 
-	calendarID, _ := url.QueryUnescape(chi.URLParam(r, "calendarID"))
-	eventID, _ := url.QueryUnescape(chi.URLParam(r, "eventID"))
+// VacuumCleanRequest is the body for /api/vacuum/{entityID}/clean. Either a saved
+// region or ad-hoc segments/zones are cleaned.
+type VacuumCleanRequest struct {
+	Region   string        `json:"region,omitempty"`
+	Segments []string      `json:"segments,omitempty"`
+	Zones    []vacuum.Zone `json:"zones,omitempty"`
+	Repeats  int           `json:"repeats,omitempty"`
+}
 
-	if err := calClient.DeleteEvent(r.Context(), calendarID, eventID); err != nil {
-		log.Printf("Error deleting event: %v", err)
-		http.Error(w, "Failed to delete event: "+err.Error(), http.StatusInternalServerError)
+// vacuumEntityID returns the vacuum entity from the URL, or writes a 400
+func vacuumEntityID(w http.ResponseWriter, r *http.Request) (string, bool) {
+	entityID := chi.URLParam(r, "entityID")
+	if !strings.HasPrefix(entityID, "vacuum.") {
+		http.Error(w, "Not a vacuum: "+entityID, http.StatusBadRequest)
+		return "", false
+	}
+	return entityID, true
+}
+
+// handleGetVacuumMap returns a vacuum's map image URL, detected rooms and saved regions
+func handleGetVacuumMap(w http.ResponseWriter, r *http.Request) {
+	entityID, ok := vacuumEntityID(w, r)
+	if !ok {
 		return
 	}
 
-	invalidateCalendarCache()
+	m := VacuumMap{EntityID: entityID, Regions: vacuumRegions.List(entityID)}
+	if _, ok := appConfig.VacuumMaps[entityID]; ok && haClient != nil {
+		m.Image = "/api/vacuum/" + entityID + "/map/image"
+	}
+	if valetudo != nil && valetudo.Has(entityID) {
+		m.Segments = valetudo.Segments(entityID)
+	}
 
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
 }
 
-type MoveEventRequest struct {
-	DestinationCalendarID string `json:"destinationCalendarId"`
-}
+// handleGetVacuumMapImage proxies the HA camera/image entity rendering the vacuum's map
+func handleGetVacuumMapImage(w http.ResponseWriter, r *http.Request) {
+	if haClient == nil {
+		http.Error(w, "HA not configured", http.StatusServiceUnavailable)
+		return
+	}
+	entityID, ok := vacuumEntityID(w, r)
+	if !ok {
+		return
+	}
+	mapEntity, ok := appConfig.VacuumMaps[entityID]
+	if !ok {
+		http.Error(w, "No map configured for "+entityID, http.StatusNotFound)
+		return
+	}
 
-func handleMoveEvent(w http.ResponseWriter, r *http.Request) {
-	if calClient == nil || !calClient.IsAuthorized() {
-		http.Error(w, "Calendar not configured or not authorized", http.StatusServiceUnavailable)
+	data, contentType, err := haClient.GetEntityImage(mapEntity)
+	if err != nil {
+		log.Printf("Error getting vacuum map %s: %v", mapEntity, err)
+		http.Error(w, "Failed to get map: "+err.Error(), http.StatusBadGateway)
 		return
 	}
 
-	sourceCalendarID, _ := url.QueryUnescape(chi.URLParam(r, "calendarID"))
-	eventID, _ := url.QueryUnescape(chi.URLParam(r, "eventID"))
+	if contentType == "" {
+		contentType = "image/png"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(data)
+}
 
-	var req MoveEventRequest
+// handleVacuumClean cleans a saved region or the given segments/zones
+func handleVacuumClean(w http.ResponseWriter, r *http.Request) {
+	entityID, ok := vacuumEntityID(w, r)
+	if !ok {
+		return
+	}
+
+	var req VacuumCleanRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if req.DestinationCalendarID == "" {
-		http.Error(w, "Destination calendar ID is required", http.StatusBadRequest)
+	label := "segments"
+	if req.Region != "" {
+		region, ok := vacuumRegions.Get(req.Region)
+		if !ok || region.Vacuum != entityID {
+			http.Error(w, "Region not found", http.StatusNotFound)
+			return
+		}
+		req.Segments, req.Zones, label = region.Segments, region.Zones, region.Name
+		if req.Repeats == 0 {
+			req.Repeats = region.Repeats
+		}
+	} else if len(req.Zones) > 0 {
+		label = "zones"
+	}
+	if err := vacuum.ValidateTarget(req.Segments, req.Zones, &req.Repeats); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	event, err := calClient.MoveEvent(r.Context(), sourceCalendarID, eventID, req.DestinationCalendarID)
-	if err != nil {
-		log.Printf("Error moving event: %v", err)
-		http.Error(w, "Failed to move event: "+err.Error(), http.StatusInternalServerError)
+	if err := cleanVacuum(entityID, req.Segments, req.Zones, req.Repeats); err != nil {
+		log.Printf("Error cleaning %s with %s: %v", label, entityID, err)
+		http.Error(w, "Failed to start cleaning: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	invalidateCalendarCache()
+	recordAction(r, actions.KindEntity, entityID, "clean "+label)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(event)
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
 }
 
-func handleGetEventInstances(w http.ResponseWriter, r *http.Request) {
-	if calClient == nil || !calClient.IsAuthorized() {
-		http.Error(w, "Calendar not configured or not authorized", http.StatusServiceUnavailable)
-		return
+// cleanVacuum starts a segment or zone clean. Valetudo robots are commanded over
+// MQTT; other vacuums get the Roborock/Xiaomi send_command through Home Assistant.
+func cleanVacuum(entityID string, segments []string, zones []vacuum.Zone, repeats int) error {
+	if valetudo != nil && valetudo.Has(entityID) {
+		if len(zones) > 0 {
+			return valetudo.CleanZones(entityID, zones, repeats)
+		}
+		return valetudo.CleanSegments(entityID, segments, repeats)
+	}
+	if haClient == nil {
+		return fmt.Errorf("HA not configured")
 	}
 
-	calendarID, _ := url.QueryUnescape(chi.URLParam(r, "calendarID"))
-	eventID, _ := url.QueryUnescape(chi.URLParam(r, "eventID"))
-
-	var timeMin, timeMax time.Time
-
-	if minStr := r.URL.Query().Get("timeMin"); minStr != "" {
-		t, err := time.Parse(time.RFC3339, minStr)
-		if err == nil {
-			timeMin = t
+	data := map[string]interface{}{"entity_id": entityID}
+	if len(zones) > 0 {
+		params := make([][]int, 0, len(zones))
+		for _, z := range zones {
+			params = append(params, []int{z.X1, z.Y1, z.X2, z.Y2, repeats})
+		}
+		data["command"] = "app_zoned_clean"
+		data["params"] = params
+	} else {
+		ids := make([]int, 0, len(segments))
+		for _, s := range segments {
+			id, err := strconv.Atoi(s)
+			if err != nil {
+				return fmt.Errorf("invalid segment ID: %s", s)
+			}
+			ids = append(ids, id)
 		}
+		data["command"] = "app_segment_clean"
+		data["params"] = []map[string]interface{}{{"segments": ids, "repeat": repeats}}
 	}
+	_, err := haClient.CallServiceData("vacuum", "send_command", data)
+	return err
+}
 
-	if maxStr := r.URL.Query().Get("timeMax"); maxStr != "" {
-		t, err := time.Parse(time.RFC3339, maxStr)
-		if err == nil {
-			timeMax = t
+// handleSaveVacuumRegion creates (POST) or updates (PUT) a named cleaning region
+func handleSaveVacuumRegion(w http.ResponseWriter, r *http.Request) {
+	entityID, ok := vacuumEntityID(w, r)
+	if !ok {
+		return
+	}
+
+	var region vacuum.Region
+	if err := json.NewDecoder(r.Body).Decode(&region); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	region.Vacuum = entityID
+	region.ID = ""
+	if id := chi.URLParam(r, "id"); id != "" {
+		existing, ok := vacuumRegions.Get(id)
+		if !ok || existing.Vacuum != entityID {
+			http.Error(w, "Region not found", http.StatusNotFound)
+			return
 		}
+		region.ID = id
 	}
 
-	instances, err := calClient.GetEventInstances(r.Context(), calendarID, eventID, timeMin, timeMax)
+	saved, err := vacuumRegions.Save(region)
 	if err != nil {
-		log.Printf("Error getting event instances: %v", err)
-		http.Error(w, "Failed to get event instances: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(instances)
+	json.NewEncoder(w).Encode(saved)
 }
 
-func handleGetColors(w http.ResponseWriter, r *http.Request) {
-	if calClient == nil || !calClient.IsAuthorized() {
-		http.Error(w, "Calendar not configured or not authorized", http.StatusServiceUnavailable)
+func handleDeleteVacuumRegion(w http.ResponseWriter, r *http.Request) {
+	entityID, ok := vacuumEntityID(w, r)
+	if !ok {
 		return
 	}
 
-	colors, err := calClient.GetColors(r.Context())
-	if err != nil {
-		log.Printf("Error getting colors: %v", err)
-		http.Error(w, "Failed to get colors: "+err.Error(), http.StatusInternalServerError)
+	id := chi.URLParam(r, "id")
+	if region, ok := vacuumRegions.Get(id); !ok || region.Vacuum != entityID {
+		http.Error(w, "Region not found", http.StatusNotFound)
+		return
+	}
+	if err := vacuumRegions.Delete(id); err != nil {
+		log.Printf("Error deleting vacuum region: %v", err)
+		http.Error(w, "Failed to delete region: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(colors)
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func handleGetCalendars(w http.ResponseWriter, r *http.Request) {
-	if calClient == nil || !calClient.IsAuthorized() {
-		http.Error(w, "Calendar not configured or not authorized", http.StatusServiceUnavailable)
+func handleGetHAScenes(w http.ResponseWriter, r *http.Request) {
+	if haClient == nil {
+		http.Error(w, "HA not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	calendars, err := calClient.GetCalendarList(r.Context())
+	scenes, err := haClient.GetScenes()
 	if err != nil {
-		log.Printf("Error getting calendars: %v", err)
-		http.Error(w, "Failed to get calendars: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Error getting HA scenes: %v", err)
+		http.Error(w, "Failed to get scenes: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(calendars)
+	json.NewEncoder(w).Encode(scenes)
 }
 
-func handleGetCalendarPrefs(w http.ResponseWriter, r *http.Request) {
-	if calendarPrefs == nil {
-		calendarPrefs = &CalendarPrefs{Calendars: make(map[string]CalendarPref)}
+func handleGetHAScripts(w http.ResponseWriter, r *http.Request) {
+	if haClient == nil {
+		http.Error(w, "HA not configured", http.StatusServiceUnavailable)
+		return
 	}
 
-	// Return calendars with preferences merged
-	calendarsWithPrefs, err := getCalendarsWithPrefs(r.Context())
+	scripts, err := haClient.GetScripts()
 	if err != nil {
-		log.Printf("Error getting calendars with prefs: %v", err)
-		http.Error(w, "Failed to get calendars: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Error getting HA scripts: %v", err)
+		http.Error(w, "Failed to get scripts: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(calendarsWithPrefs)
+	json.NewEncoder(w).Encode(scripts)
 }
 
-func handleUpdateCalendarPref(w http.ResponseWriter, r *http.Request) {
-	calendarID := chi.URLParam(r, "calendarID")
-	if calendarID == "" {
-		http.Error(w, "Calendar ID required", http.StatusBadRequest)
+// handleActivateHAScene turns on a scene; id may be "movie_night" or "scene.movie_night"
+func handleActivateHAScene(w http.ResponseWriter, r *http.Request) {
+	if haClient == nil {
+		http.Error(w, "HA not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	// Decode URL-encoded calendar ID to match Google Calendar API format
-	decodedID, err := url.QueryUnescape(calendarID)
-	if err != nil {
-		decodedID = calendarID // Fall back to original if decode fails
-	}
-
-	var pref CalendarPref
-	if err := json.NewDecoder(r.Body).Decode(&pref); err != nil {
-		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "Missing scene ID", http.StatusBadRequest)
 		return
 	}
-
-	if calendarPrefs == nil {
-		calendarPrefs = &CalendarPrefs{Calendars: make(map[string]CalendarPref)}
+	if !strings.HasPrefix(id, "scene.") {
+		id = "scene." + id
 	}
 
-	calendarPrefs.Calendars[decodedID] = pref
-
-	if err := saveCalendarPrefs(); err != nil {
-		log.Printf("Error saving calendar prefs: %v", err)
-		http.Error(w, "Failed to save preferences: "+err.Error(), http.StatusInternalServerError)
+	if err := haClient.ActivateScene(id); err != nil {
+		log.Printf("Error activating HA scene %s: %v", id, err)
+		http.Error(w, "Failed to activate scene: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	recordAction(r, actions.KindEntity, id, "turn_on")
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"success":    true,
-		"calendarId": decodedID,
-		"pref":       pref,
-	})
+	json.NewEncoder(w).Encode(map[string]interface{}{"success": true, "scene": id})
 }
 
-func handlePlacesAutocomplete(w http.ResponseWriter, r *http.Request) {
-	if appConfig.GooglePlacesAPIKey == "" {
-		http.Error(w, "Places API not configured", http.StatusServiceUnavailable)
+type CreateEventRequest struct {
+	Type        string `json:"type"`        // "event" or "task"
+	Title       string `json:"title"`
+	Date        string `json:"date"`        // YYYY-MM-DD (start date)
+	EndDate     string `json:"endDate"`     // YYYY-MM-DD (end date, optional - defaults to Date)
+	Time        string `json:"time"`        // HH:MM (optional, empty = all day)
+	EndTime     string `json:"endTime"`     // HH:MM (optional)
+	Location    string `json:"location"`    // optional
+	Description string `json:"description"` // optional
+	Repeat      string `json:"repeat"`      // optional: daily, weekly, monthly, yearly, weekdays
+	CalendarID  string `json:"calendarId"`  // optional: calendar to create event on
+}
+
+func handleCreateEvent(w http.ResponseWriter, r *http.Request) {
+	if calClient == nil {
+		http.Error(w, "Calendar not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	input := r.URL.Query().Get("input")
-	if input == "" {
-		http.Error(w, "Input parameter required", http.StatusBadRequest)
+	if !calClient.IsAuthorized() {
+		http.Error(w, "Calendar not authorized", http.StatusUnauthorized)
 		return
 	}
 
-	// Build the Google Places API URL
-	apiURL := fmt.Sprintf(
-		"https://maps.googleapis.com/maps/api/place/autocomplete/json?input=%s&key=%s",
-		url.QueryEscape(input),
-		appConfig.GooglePlacesAPIKey,
-	)
-
-	// Make the request to Google
-	resp, err := http.Get(apiURL)
-	if err != nil {
-		log.Printf("Error calling Places API: %v", err)
-		http.Error(w, "Failed to fetch places", http.StatusInternalServerError)
+	var req CreateEventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-	defer resp.Body.Close()
-
-	// Forward the response
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.StatusCode)
 
-	// Read and forward the body
-	var result map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		http.Error(w, "Failed to parse places response", http.StatusInternalServerError)
+	if req.Title == "" || req.Date == "" {
+		http.Error(w, "Title and date are required", http.StatusBadRequest)
 		return
 	}
-	json.NewEncoder(w).Encode(result)
-}
 
-// repeatToRRule converts user-friendly repeat option to RRULE format
-func repeatToRRule(repeat string) string {
-	switch repeat {
-	case "daily":
-		return "RRULE:FREQ=DAILY"
-	case "weekly":
-		return "RRULE:FREQ=WEEKLY"
-	case "monthly":
-		return "RRULE:FREQ=MONTHLY"
-	case "yearly":
-		return "RRULE:FREQ=YEARLY"
-	case "weekdays":
-		return "RRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"
-	default:
-		return ""
+	// Parse start date
+	startDate, err := time.ParseInLocation("2006-01-02", req.Date, appConfig.Timezone)
+	if err != nil {
+		http.Error(w, "Invalid date format", http.StatusBadRequest)
+		return
 	}
-}
 
-func getEnv(key, fallback string) string {
-	if val := os.Getenv(key); val != "" {
-		return val
+	// Parse end date (defaults to start date if not provided)
+	endDateStr := req.EndDate
+	if endDateStr == "" {
+		endDateStr = req.Date
+	}
+	endDate, err := time.ParseInLocation("2006-01-02", endDateStr, appConfig.Timezone)
+	if err != nil {
+		http.Error(w, "Invalid end date format", http.StatusBadRequest)
+		return
 	}
-	return fallback
-}
 
-func parseIntEnv(key string, fallback int) int {
-	if val := os.Getenv(key); val != "" {
-		if i, err := strconv.Atoi(val); err == nil {
-			return i
+	var start, end time.Time
+	var allDay bool
+
+	if req.Time == "" {
+		// All day event
+		allDay = true
+		start = startDate
+		// For all-day events, end date is exclusive (add 1 day)
+		end = endDate.AddDate(0, 0, 1)
+	} else {
+		// Timed event
+		allDay = false
+		startTime, err := time.ParseInLocation("2006-01-02 15:04", req.Date+" "+req.Time, appConfig.Timezone)
+		if err != nil {
+			http.Error(w, "Invalid time format", http.StatusBadRequest)
+			return
 		}
-	}
-	return fallback
-}
+		start = startTime
 
-// initEntertainmentManagers initializes all entertainment device managers
-func initEntertainmentManagers(cfg Config) {
-	// Initialize Sony manager
-	if len(cfg.SonyDevices) > 0 {
-		sonyManager = entertainment.NewSonyManager()
-		for _, dev := range cfg.SonyDevices {
-			sonyManager.AddDevice(dev.Name, dev.Host, dev.Port, dev.PSK, dev.DeviceType)
+		if req.EndTime != "" {
+			// Use end date with end time
+			endTime, err := time.ParseInLocation("2006-01-02 15:04", endDateStr+" "+req.EndTime, appConfig.Timezone)
+			if err != nil {
+				http.Error(w, "Invalid end time format", http.StatusBadRequest)
+				return
+			}
+			end = endTime
+		} else {
+			end = start.Add(1 * time.Hour) // Default 1 hour duration
 		}
-		log.Printf("Sony Entertainment manager initialized with %d device(s)", len(cfg.SonyDevices))
 	}
 
-	// Initialize Shield manager
-	if len(cfg.ShieldDevices) > 0 {
-		shieldManager = entertainment.NewShieldManager()
-		for _, dev := range cfg.ShieldDevices {
-			shieldManager.AddDevice(dev.Name, dev.Host, dev.Port)
-		}
-		log.Printf("Nvidia Shield manager initialized with %d device(s)", len(cfg.ShieldDevices))
+	// Build event options
+	opts := &calendar.CreateEventOptions{
+		CalendarID:  req.CalendarID,
+		Location:    req.Location,
+		Description: req.Description,
 	}
 
-	// Initialize Xbox manager
-	if len(cfg.XboxDevices) > 0 {
-		xboxManager = entertainment.NewXboxManager(cfg.XboxRESTServerURL)
-		for _, dev := range cfg.XboxDevices {
-			xboxManager.AddDevice(dev.Name, dev.Host, dev.LiveID)
+	// Convert repeat string to RRULE
+	if req.Repeat != "" {
+		rrule := repeatToRRule(req.Repeat)
+		if rrule != "" {
+			opts.Recurrence = []string{rrule}
 		}
-		log.Printf("Xbox manager initialized with %d device(s)", len(cfg.XboxDevices))
 	}
 
-	// Initialize PS5 manager (requires MQTT)
-	if len(cfg.PS5Devices) > 0 {
-		var pahoClient pahomqtt.Client
-		if mqttClient != nil {
-			pahoClient = mqttClient.GetPahoClient()
-		}
-		ps5Manager = entertainment.NewPS5Manager(pahoClient, cfg.PS5MQTTTopic)
-		for _, dev := range cfg.PS5Devices {
-			ps5Manager.AddDevice(dev.Name, dev.DeviceID, dev.PSNAccount)
+	log.Printf("Creating event: title=%q, date=%s, start=%v, end=%v, allDay=%v, calendarId=%q", req.Title, req.Date, start, end, allDay, req.CalendarID)
+	event, err := calClient.CreateEvent(r.Context(), req.Title, start, end, allDay, opts)
+	if err != nil {
+		log.Printf("Error creating event: %v", err)
+		http.Error(w, "Failed to create event: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Invalidate server-side cache so next page load shows the new event
+	invalidateCalendarCache()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+func handleGetEvent(w http.ResponseWriter, r *http.Request) {
+	if calClient == nil || !calClient.IsAuthorized() {
+		http.Error(w, "Calendar not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	calendarID, _ := url.QueryUnescape(chi.URLParam(r, "calendarID"))
+	eventID, _ := url.QueryUnescape(chi.URLParam(r, "eventID"))
+
+	event, err := calClient.GetEvent(r.Context(), calendarID, eventID)
+	if err != nil {
+		log.Printf("Error getting event: %v", err)
+		http.Error(w, "Failed to get event: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	// Invalidate calendar cache after creating event
+	invalidateCalendarCache()
+
+	json.NewEncoder(w).Encode(event)
+}
+
+type UpdateEventRequest struct {
+	Title       string `json:"title"`
+	Date        string `json:"date"`
+	Time        string `json:"time"`
+	EndTime     string `json:"endTime"`
+	Location    string `json:"location"`
+	Description string `json:"description"`
+	Repeat      string `json:"repeat"`
+	ColorID     string `json:"colorId"`
+}
+
+func handleUpdateEvent(w http.ResponseWriter, r *http.Request) {
+	if calClient == nil || !calClient.IsAuthorized() {
+		http.Error(w, "Calendar not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	calendarID, _ := url.QueryUnescape(chi.URLParam(r, "calendarID"))
+	eventID, _ := url.QueryUnescape(chi.URLParam(r, "eventID"))
+
+	var req UpdateEventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Title == "" || req.Date == "" {
+		http.Error(w, "Title and date are required", http.StatusBadRequest)
+		return
+	}
+
+	date, err := time.ParseInLocation("2006-01-02", req.Date, appConfig.Timezone)
+	if err != nil {
+		http.Error(w, "Invalid date format", http.StatusBadRequest)
+		return
+	}
+
+	var start, end time.Time
+	var allDay bool
+
+	if req.Time == "" {
+		allDay = true
+		start = date
+		end = date.AddDate(0, 0, 1)
+	} else {
+		allDay = false
+		startTime, err := time.ParseInLocation("2006-01-02 15:04", req.Date+" "+req.Time, appConfig.Timezone)
+		if err != nil {
+			http.Error(w, "Invalid time format", http.StatusBadRequest)
+			return
+		}
+		start = startTime
+
+		if req.EndTime != "" {
+			endTime, err := time.ParseInLocation("2006-01-02 15:04", req.Date+" "+req.EndTime, appConfig.Timezone)
+			if err != nil {
+				http.Error(w, "Invalid end time format", http.StatusBadRequest)
+				return
+			}
+			end = endTime
+			if !end.After(start) {
+				end = end.AddDate(0, 0, 1)
+			}
+		} else {
+			end = start.Add(1 * time.Hour)
+		}
+	}
+
+	opts := &calendar.CreateEventOptions{
+		Location:    req.Location,
+		Description: req.Description,
+		ColorID:     req.ColorID,
+	}
+
+	if req.Repeat != "" {
+		rrule := repeatToRRule(req.Repeat)
+		if rrule != "" {
+			opts.Recurrence = []string{rrule}
+		}
+	}
+
+	event, err := calClient.UpdateEvent(r.Context(), calendarID, eventID, req.Title, start, end, allDay, opts)
+	if err != nil {
+		log.Printf("Error updating event: %v", err)
+		http.Error(w, "Failed to update event: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	invalidateCalendarCache()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+type PatchEventRequest struct {
+	Title       *string `json:"title,omitempty"`
+	Date        *string `json:"date,omitempty"`
+	Time        *string `json:"time,omitempty"`
+	EndTime     *string `json:"endTime,omitempty"`
+	Location    *string `json:"location,omitempty"`
+	Description *string `json:"description,omitempty"`
+	ColorID     *string `json:"colorId,omitempty"`
+}
+
+func handlePatchEvent(w http.ResponseWriter, r *http.Request) {
+	if calClient == nil || !calClient.IsAuthorized() {
+		http.Error(w, "Calendar not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	calendarID, _ := url.QueryUnescape(chi.URLParam(r, "calendarID"))
+	eventID, _ := url.QueryUnescape(chi.URLParam(r, "eventID"))
+
+	var req PatchEventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	opts := &calendar.UpdateEventOptions{}
+
+	if req.Title != nil {
+		opts.Title = req.Title
+	}
+	if req.Location != nil {
+		opts.Location = req.Location
+	}
+	if req.Description != nil {
+		opts.Description = req.Description
+	}
+	if req.ColorID != nil {
+		opts.ColorID = req.ColorID
+	}
+
+	// Handle time updates
+	if req.Date != nil {
+		date, err := time.ParseInLocation("2006-01-02", *req.Date, appConfig.Timezone)
+		if err != nil {
+			http.Error(w, "Invalid date format", http.StatusBadRequest)
+			return
+		}
+
+		if req.Time != nil && *req.Time != "" {
+			startTime, err := time.ParseInLocation("2006-01-02 15:04", *req.Date+" "+*req.Time, appConfig.Timezone)
+			if err != nil {
+				http.Error(w, "Invalid time format", http.StatusBadRequest)
+				return
+			}
+			opts.Start = &startTime
+			allDay := false
+			opts.AllDay = &allDay
+		} else {
+			opts.Start = &date
+			allDay := true
+			opts.AllDay = &allDay
+		}
+
+		if req.EndTime != nil && *req.EndTime != "" {
+			endTime, err := time.ParseInLocation("2006-01-02 15:04", *req.Date+" "+*req.EndTime, appConfig.Timezone)
+			if err != nil {
+				http.Error(w, "Invalid end time format", http.StatusBadRequest)
+				return
+			}
+			if opts.Start != nil && !endTime.After(*opts.Start) {
+				endTime = endTime.AddDate(0, 0, 1)
+			}
+			opts.End = &endTime
+		}
+	}
+
+	event, err := calClient.PatchEvent(r.Context(), calendarID, eventID, opts)
+	if err != nil {
+		log.Printf("Error patching event: %v", err)
+		http.Error(w, "Failed to patch event: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	invalidateCalendarCache()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+func handleDeleteEvent(w http.ResponseWriter, r *http.Request) {
+	if calClient == nil || !calClient.IsAuthorized() {
+		http.Error(w, "Calendar not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	calendarID, _ := url.QueryUnescape(chi.URLParam(r, "calendarID"))
+	eventID, _ := url.QueryUnescape(chi.URLParam(r, "eventID"))
+
+	if err := calClient.DeleteEvent(r.Context(), calendarID, eventID); err != nil {
+		log.Printf("Error deleting event: %v", err)
+		http.Error(w, "Failed to delete event: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	invalidateCalendarCache()
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type MoveEventRequest struct {
+	DestinationCalendarID string `json:"destinationCalendarId"`
+}
+
+func handleMoveEvent(w http.ResponseWriter, r *http.Request) {
+	if calClient == nil || !calClient.IsAuthorized() {
+		http.Error(w, "Calendar not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	sourceCalendarID, _ := url.QueryUnescape(chi.URLParam(r, "calendarID"))
+	eventID, _ := url.QueryUnescape(chi.URLParam(r, "eventID"))
+
+	var req MoveEventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.DestinationCalendarID == "" {
+		http.Error(w, "Destination calendar ID is required", http.StatusBadRequest)
+		return
+	}
+
+	event, err := calClient.MoveEvent(r.Context(), sourceCalendarID, eventID, req.DestinationCalendarID)
+	if err != nil {
+		log.Printf("Error moving event: %v", err)
+		http.Error(w, "Failed to move event: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	invalidateCalendarCache()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+func handleGetEventInstances(w http.ResponseWriter, r *http.Request) {
+	if calClient == nil || !calClient.IsAuthorized() {
+		http.Error(w, "Calendar not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	calendarID, _ := url.QueryUnescape(chi.URLParam(r, "calendarID"))
+	eventID, _ := url.QueryUnescape(chi.URLParam(r, "eventID"))
+
+	var timeMin, timeMax time.Time
+
+	if minStr := r.URL.Query().Get("timeMin"); minStr != "" {
+		t, err := time.Parse(time.RFC3339, minStr)
+		if err == nil {
+			timeMin = t
+		}
+	}
+
+	if maxStr := r.URL.Query().Get("timeMax"); maxStr != "" {
+		t, err := time.Parse(time.RFC3339, maxStr)
+		if err == nil {
+			timeMax = t
+		}
+	}
+
+	instances, err := calClient.GetEventInstances(r.Context(), calendarID, eventID, timeMin, timeMax)
+	if err != nil {
+		log.Printf("Error getting event instances: %v", err)
+		http.Error(w, "Failed to get event instances: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(instances)
+}
+
+func handleGetColors(w http.ResponseWriter, r *http.Request) {
+	if calClient == nil || !calClient.IsAuthorized() {
+		http.Error(w, "Calendar not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	colors, err := calClient.GetColors(r.Context())
+	if err != nil {
+		log.Printf("Error getting colors: %v", err)
+		http.Error(w, "Failed to get colors: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(colors)
+}
+
+func handleGetCalendars(w http.ResponseWriter, r *http.Request) {
+	if calClient == nil || !calClient.IsAuthorized() {
+		http.Error(w, "Calendar not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	calendars, err := calClient.GetCalendarList(r.Context())
+	if err != nil {
+		log.Printf("Error getting calendars: %v", err)
+		http.Error(w, "Failed to get calendars: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(calendars)
+}
+
+func handleGetCalendarPrefs(w http.ResponseWriter, r *http.Request) {
+	if calendarPrefs == nil {
+		calendarPrefs = &CalendarPrefs{Calendars: make(map[string]CalendarPref)}
+	}
+
+	// Return calendars with preferences merged
+	calendarsWithPrefs, err := getCalendarsWithPrefs(r.Context())
+	if err != nil {
+		log.Printf("Error getting calendars with prefs: %v", err)
+		http.Error(w, "Failed to get calendars: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(calendarsWithPrefs)
+}
+
+func handleUpdateCalendarPref(w http.ResponseWriter, r *http.Request) {
+	calendarID := chi.URLParam(r, "calendarID")
+	if calendarID == "" {
+		http.Error(w, "Calendar ID required", http.StatusBadRequest)
+		return
+	}
+
+	// Decode URL-encoded calendar ID to match Google Calendar API format
+	decodedID, err := url.QueryUnescape(calendarID)
+	if err != nil {
+		decodedID = calendarID // Fall back to original if decode fails
+	}
+
+	var pref CalendarPref
+	if err := json.NewDecoder(r.Body).Decode(&pref); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if calendarPrefs == nil {
+		calendarPrefs = &CalendarPrefs{Calendars: make(map[string]CalendarPref)}
+	}
+
+	calendarPrefs.Calendars[decodedID] = pref
+
+	if err := saveCalendarPrefs(); err != nil {
+		log.Printf("Error saving calendar prefs: %v", err)
+		http.Error(w, "Failed to save preferences: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success":    true,
+		"calendarId": decodedID,
+		"pref":       pref,
+	})
+}
+
+func handlePlacesAutocomplete(w http.ResponseWriter, r *http.Request) {
+	if appConfig.GooglePlacesAPIKey == "" {
+		http.Error(w, "Places API not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	input := r.URL.Query().Get("input")
+	if input == "" {
+		http.Error(w, "Input parameter required", http.StatusBadRequest)
+		return
+	}
+
+	// Build the Google Places API URL
+	apiURL := fmt.Sprintf(
+		"https://maps.googleapis.com/maps/api/place/autocomplete/json?input=%s&key=%s",
+		url.QueryEscape(input),
+		appConfig.GooglePlacesAPIKey,
+	)
+
+	// Make the request to Google
+	resp, err := http.Get(apiURL)
+	if err != nil {
+		log.Printf("Error calling Places API: %v", err)
+		http.Error(w, "Failed to fetch places", http.StatusInternalServerError)
+		return
+	}
+	defer resp.Body.Close()
+
+	// Forward the response
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.StatusCode)
+
+	// Read and forward the body
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		http.Error(w, "Failed to parse places response", http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// repeatToRRule converts user-friendly repeat option to RRULE format
+func repeatToRRule(repeat string) string {
+	switch repeat {
+	case "daily":
+		return "RRULE:FREQ=DAILY"
+	case "weekly":
+		return "RRULE:FREQ=WEEKLY"
+	case "monthly":
+		return "RRULE:FREQ=MONTHLY"
+	case "yearly":
+		return "RRULE:FREQ=YEARLY"
+	case "weekdays":
+		return "RRULE:FREQ=WEEKLY;BYDAY=MO,TU,WE,TH,FR"
+	default:
+		return ""
+	}
+}
+
+func getEnv(key, fallback string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return fallback
+}
+
+func parseIntEnv(key string, fallback int) int {
+	if val := os.Getenv(key); val != "" {
+		if i, err := strconv.Atoi(val); err == nil {
+			return i
+		}
+	}
+	return fallback
+}
+
+// initEntertainmentManagers initializes all entertainment device managers
+func initEntertainmentManagers(cfg Config) {
+	// Initialize Sony manager
+	if len(cfg.SonyDevices) > 0 {
+		sonyManager = entertainment.NewSonyManager()
+		for _, dev := range cfg.SonyDevices {
+			sonyManager.AddDevice(dev.Name, dev.Host, dev.Port, dev.PSK, dev.DeviceType)
+		}
+		log.Printf("Sony Entertainment manager initialized with %d device(s)", len(cfg.SonyDevices))
+	}
+
+	// Initialize Shield manager
+	if len(cfg.ShieldDevices) > 0 {
+		shieldManager = entertainment.NewShieldManager(dataStore.Doc("settings", "androidtv_identity", ""))
+		for _, dev := range cfg.ShieldDevices {
+			shieldManager.AddDevice(dev.Name, dev.Host, dev.Port, dev.Transport)
+		}
+		log.Printf("Nvidia Shield manager initialized with %d device(s)", len(cfg.ShieldDevices))
+	}
+
+	// Initialize Xbox manager
+	if len(cfg.XboxDevices) > 0 {
+		xboxManager = entertainment.NewXboxManager(cfg.XboxRESTServerURL)
+		for _, dev := range cfg.XboxDevices {
+			xboxManager.AddDevice(dev.Name, dev.Host, dev.LiveID)
+		}
+		log.Printf("Xbox manager initialized with %d device(s)", len(cfg.XboxDevices))
+	}
+
+	// Initialize PS5 manager (requires MQTT)
+	if len(cfg.PS5Devices) > 0 {
+		var pahoClient pahomqtt.Client
+		if mqttClient != nil {
+			pahoClient = mqttClient.GetPahoClient()
+		}
+		ps5Manager = entertainment.NewPS5Manager(pahoClient, cfg.PS5MQTTTopic, cfg.PS5MQTTStateTopic)
+		for _, dev := range cfg.PS5Devices {
+			ps5Manager.AddDevice(dev.Name, dev.DeviceID, dev.PSNAccount)
+		}
+		log.Printf("PS5 manager initialized with %d device(s)", len(cfg.PS5Devices))
+	}
+
+	// Initialize Sonos manager
+	if len(cfg.SonosHosts) > 0 {
+		sonosManager = sonos.NewManager(cfg.SonosHosts)
+		log.Printf("Sonos manager initialized with %d host(s)", len(cfg.SonosHosts))
+	}
+
+	// IR blasters for devices without network control
+	if len(cfg.IRDevices) > 0 {
+		var callService func(domain, service string, data map[string]interface{}) error
+		if haClient != nil {
+			callService = func(domain, service string, data map[string]interface{}) error {
+				_, err := haClient.CallServiceData(domain, service, data)
+				return err
+			}
+		}
+		irManager = ir.NewManager(cfg.IRDevices, dataStore.Doc("settings", "ir_codes", ""), callService)
+		log.Printf("IR manager initialized with %d device(s)", len(cfg.IRDevices))
+	}
+
+	startEntertainmentPollers()
+
+	if len(cfg.EntertainmentActivities) > 0 {
+		activityRunner = entertainment.NewActivityRunner(cfg.EntertainmentActivities, runActivityStep, activateSceneTarget)
+		log.Printf("Entertainment activities: %d configured", len(cfg.EntertainmentActivities))
+	}
+}
+
+// startEntertainmentPollers starts background state polling for each configured
+// platform, so handlers answer from the cache instead of waiting on devices
+// that are off
+func startEntertainmentPollers() {
+	if sonyManager != nil {
+		sonyStates = entertainment.NewPoller(deviceNames(sonyManager.GetDevices()),
+			func(name string) *entertainment.DeviceState { return sonyManager.GetDevice(name).GetState() },
+			func(s *entertainment.DeviceState) string {
+				if !s.Online {
+					return entertainment.Unreachable
+				}
+				return s.Power
+			})
+		sonyStates.OnChange(entertainmentPowerChanged[*entertainment.DeviceState]("sony"))
+		go sonyStates.Run()
+	}
+
+	if shieldManager != nil {
+		shieldStates = entertainment.NewPoller(deviceNames(shieldManager.GetDevices()),
+			func(name string) *entertainment.ShieldState { return shieldManager.GetDevice(name).GetState() },
+			func(s *entertainment.ShieldState) string {
+				if !s.Online {
+					return entertainment.Unreachable
+				}
+				if s.Power != "" {
+					return s.Power
+				}
+				return "on"
+			})
+		shieldStates.OnChange(entertainmentPowerChanged[*entertainment.ShieldState]("shield"))
+		go shieldStates.Run()
+	}
+
+	if xboxManager != nil {
+		xboxStates = entertainment.NewPoller(deviceNames(xboxManager.GetDevices()),
+			func(name string) *entertainment.XboxState { return xboxManager.GetDevice(name).GetState() },
+			func(s *entertainment.XboxState) string {
+				if !s.Online {
+					return entertainment.Unreachable
+				}
+				return "on"
+			})
+		xboxStates.OnChange(entertainmentPowerChanged[*entertainment.XboxState]("xbox"))
+		go xboxStates.Run()
+	}
+
+	// PS5 state arrives over MQTT, so polling just reads it and reports changes
+	if ps5Manager != nil {
+		ps5States = entertainment.NewPoller(deviceNames(ps5Manager.GetDevices()),
+			ps5Manager.GetState,
+			func(s *entertainment.PS5State) string {
+				if s == nil || !s.Online {
+					return entertainment.Unreachable
+				}
+				return strings.ToLower(s.Power)
+			})
+		ps5States.OnChange(entertainmentPowerChanged[*entertainment.PS5State]("ps5"))
+		// Titles change between polls, so push them as they arrive
+		ps5Manager.OnChange(func(name string, s *entertainment.PS5State) {
+			ps5States.Refresh(name)
+			if wsHub != nil {
+				wsHub.Broadcast(websocket.Event{
+					Type: "entertainment_activity",
+					Payload: map[string]interface{}{
+						"platform": "ps5",
+						"name":     name,
+						"activity": s.Activity,
+						"online":   s.Online,
+						"state":    s,
+					},
+				})
+			}
+		})
+		go ps5States.Run()
+	}
+}
+
+// deviceNames returns the keys of a manager's device map
+func deviceNames[D any](devices map[string]D) []string {
+	names := make([]string, 0, len(devices))
+	for name := range devices {
+		names = append(names, name)
+	}
+	return names
+}
+
+// entertainmentPowerChanged returns a poller callback that broadcasts power
+// state changes for a platform
+func entertainmentPowerChanged[S any](platform string) func(name, power string, s S) {
+	return func(name, power string, s S) {
+		log.Printf("Entertainment: %s/%s is now %s", platform, name, power)
+		if wsHub != nil {
+			wsHub.Broadcast(websocket.Event{
+				Type: "entertainment_power",
+				Payload: map[string]interface{}{
+					"platform": platform,
+					"name":     name,
+					"power":    power,
+					"state":    s,
+				},
+			})
+		}
+	}
+}
+
+// refreshEntertainmentState re-checks a device after it was controlled, so the
+// cache and any power change event don't wait for the next poll. Devices take a
+// moment to switch, hence the delay.
+func refreshEntertainmentState(platform, name string) {
+	time.Sleep(3 * time.Second)
+	switch platform {
+	case "sony":
+		if sonyStates != nil && sonyManager.GetDevice(name) != nil {
+			sonyStates.Refresh(name)
+		}
+	case "shield":
+		if shieldStates != nil && shieldManager.GetDevice(name) != nil {
+			shieldStates.Refresh(name)
+		}
+	case "xbox":
+		if xboxStates != nil && xboxManager.GetDevice(name) != nil {
+			xboxStates.Refresh(name)
+		}
+	case "ps5":
+		if ps5States != nil && ps5Manager.GetDevice(name) != nil {
+			ps5States.Refresh(name)
+		}
+	}
+}
+
+func parseEntities(s string) []string {
+	if s == "" {
+		return nil
+	}
+	entities := strings.Split(s, ",")
+	for i := range entities {
+		entities[i] = strings.TrimSpace(entities[i])
+	}
+	return entities
+}
+
+// parseAnnounceRooms parses ANNOUNCE_ROOMS env var format:
+// "upstairs=bedroom,office;downstairs=hallway,kitchen"
+func parseAnnounceRooms(s string) map[string][]string {
+	rooms := make(map[string][]string)
+	for _, entry := range strings.Split(s, ";") {
+		room, kiosks, ok := strings.Cut(entry, "=")
+		room = strings.TrimSpace(room)
+		if !ok || room == "" {
+			continue
+		}
+		rooms[room] = parseEntities(kiosks)
+	}
+	return rooms
+}
+
+// parseLockPINs parses LOCK_PINS env var format: "lock.front_door:1234,*:0000"
+func parseLockPINs(s string) map[string]string {
+	pins := make(map[string]string)
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		if len(parts) == 2 && parts[0] != "" && parts[1] != "" {
+			pins[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return pins
+}
+
+// parseEntityMap parses "entity:value" pairs, e.g. "vacuum.s8:image.s8_map"
+func parseEntityMap(s string) map[string]string {
+	result := make(map[string]string)
+	for _, entry := range parseEntities(s) {
+		key, value, ok := strings.Cut(entry, ":")
+		if ok && key != "" && value != "" {
+			result[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return result
+}
+
+// parsePoolBodies builds pools/hot tubs from "id:heater" pairs and "id:pump" pairs,
+// e.g. "hot_tub:climate.spa" and "hot_tub:switch.spa_circulation,hot_tub:switch.spa_jets"
+func parsePoolBodies(heaters, pumps string) []pool.Body {
+	var bodies []pool.Body
+	for id, heater := range parseEntityMap(heaters) {
+		name := strings.Join(strings.Fields(strings.ReplaceAll(id, "_", " ")), " ")
+		if name != "" {
+			name = strings.ToUpper(name[:1]) + name[1:]
+		}
+		body := pool.Body{ID: id, Name: name, Heater: heater}
+		for _, entry := range parseEntities(pumps) {
+			if bodyID, pump, ok := strings.Cut(entry, ":"); ok && strings.TrimSpace(bodyID) == id {
+				body.Pumps = append(body.Pumps, strings.TrimSpace(pump))
+			}
+		}
+		bodies = append(bodies, body)
+	}
+	sort.Slice(bodies, func(i, j int) bool { return bodies[i].ID < bodies[j].ID })
+	return bodies
+}
+
+// parseClassroomStudents parses CLASSROOM_STUDENTS format: "Emma:emma@school.org,Jack:me"
+// (a bare ID is also its display name)
+func parseClassroomStudents(s string) []classroom.Student {
+	var students []classroom.Student
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) == 1 {
+			students = append(students, classroom.Student{Name: entry, ID: entry})
+		} else if parts[0] != "" && parts[1] != "" {
+			students = append(students, classroom.Student{Name: strings.TrimSpace(parts[0]), ID: strings.TrimSpace(parts[1])})
+		}
+	}
+	return students
+}
+
+// parseSyncBoxes parses SYNC_BOXES env var format: "name:ip:token,name2:ip2:token2"
+func parseSyncBoxes(s string) []SyncBoxConfig {
+	if s == "" {
+		return nil
+	}
+	var boxes []SyncBoxConfig
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) == 3 {
+			boxes = append(boxes, SyncBoxConfig{
+				Name:        strings.TrimSpace(parts[0]),
+				IP:          strings.TrimSpace(parts[1]),
+				AccessToken: strings.TrimSpace(parts[2]),
+			})
+		}
+	}
+	return boxes
+}
+
+// parseSonyDevices parses format: "name:host:port:psk:type,..."
+func parseSonyDevices(s string) []SonyDeviceConfig {
+	if s == "" {
+		return nil
+	}
+	var devices []SonyDeviceConfig
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		parts := strings.SplitN(entry, ":", 5)
+		if len(parts) >= 4 {
+			port := 10000 // default Sony port
+			if len(parts) >= 3 {
+				if p, err := strconv.Atoi(strings.TrimSpace(parts[2])); err == nil {
+					port = p
+				}
+			}
+			deviceType := "soundbar"
+			if len(parts) >= 5 {
+				deviceType = strings.TrimSpace(parts[4])
+			}
+			devices = append(devices, SonyDeviceConfig{
+				Name:       strings.TrimSpace(parts[0]),
+				Host:       strings.TrimSpace(parts[1]),
+				Port:       port,
+				PSK:        strings.TrimSpace(parts[3]),
+				DeviceType: deviceType,
+			})
+		}
+	}
+	return devices
+}
+
+// parseShieldDevices parses format: "name:host:port[:transport],..."
+func parseShieldDevices(s string) []ShieldDeviceConfig {
+	if s == "" {
+		return nil
+	}
+	var devices []ShieldDeviceConfig
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		parts := strings.SplitN(entry, ":", 4)
+		if len(parts) >= 2 {
+			port := 5555 // default ADB port
+			if len(parts) >= 3 {
+				if p, err := strconv.Atoi(strings.TrimSpace(parts[2])); err == nil {
+					port = p
+				}
+			}
+			transport := entertainment.TransportADB
+			if len(parts) >= 4 {
+				switch t := strings.ToLower(strings.TrimSpace(parts[3])); t {
+				case entertainment.TransportADB, entertainment.TransportRemote:
+					transport = t
+				default:
+					log.Printf("Warning: Unknown Shield transport %q for %s, using adb", t, parts[0])
+				}
+			}
+			devices = append(devices, ShieldDeviceConfig{
+				Name:      strings.TrimSpace(parts[0]),
+				Host:      strings.TrimSpace(parts[1]),
+				Port:      port,
+				Transport: transport,
+			})
+		}
+	}
+	return devices
+}
+
+// parseXboxDevices parses format: "name:host:liveid,..."
+func parseXboxDevices(s string) []XboxDeviceConfig {
+	if s == "" {
+		return nil
+	}
+	var devices []XboxDeviceConfig
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) >= 3 {
+			devices = append(devices, XboxDeviceConfig{
+				Name:   strings.TrimSpace(parts[0]),
+				Host:   strings.TrimSpace(parts[1]),
+				LiveID: strings.TrimSpace(parts[2]),
+			})
+		}
+	}
+	return devices
+}
+
+// parsePS5Devices parses format: "name:deviceid:psnaccount,..."
+func parsePS5Devices(s string) []PS5DeviceConfig {
+	if s == "" {
+		return nil
+	}
+	var devices []PS5DeviceConfig
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		parts := strings.SplitN(entry, ":", 3)
+		if len(parts) >= 2 {
+			psnAccount := ""
+			if len(parts) >= 3 {
+				psnAccount = strings.TrimSpace(parts[2])
+			}
+			devices = append(devices, PS5DeviceConfig{
+				Name:       strings.TrimSpace(parts[0]),
+				DeviceID:   strings.TrimSpace(parts[1]),
+				PSNAccount: psnAccount,
+			})
+		}
+	}
+	return devices
+}
+
+// parseActivities parses ENTERTAINMENT_ACTIVITIES format:
+// "Movie Night=sony:tv:power:on|wait:5|sony:tv:input:hdmi2;Game Time=..."
+// Activities with an invalid step are skipped with a warning.
+func parseActivities(s string) []entertainment.Activity {
+	if s == "" {
+		return nil
+	}
+	var activities []entertainment.Activity
+	for _, entry := range strings.Split(s, ";") {
+		name, stepList, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			continue
+		}
+		var steps []entertainment.Step
+		for _, str := range strings.Split(stepList, "|") {
+			step, err := entertainment.ParseStep(str)
+			if err != nil {
+				log.Printf("Warning: Skipping activity %s: %v", name, err)
+				steps = nil
+				break
+			}
+			steps = append(steps, step)
+		}
+		if len(steps) > 0 {
+			activities = append(activities, entertainment.NewActivity(name, steps))
+		}
+	}
+	return activities
+}
+
+// ========== Entertainment Device Handlers ==========
+
+// handleGetEntertainmentDevices returns all configured entertainment devices
+func handleGetEntertainmentDevices(w http.ResponseWriter, r *http.Request) {
+	devices := map[string]interface{}{
+		"sony":   []interface{}{},
+		"shield": []interface{}{},
+		"xbox":   []interface{}{},
+		"ps5":    []interface{}{},
+		"ha":     []interface{}{},
+	}
+
+	if sonyManager != nil {
+		devices["sony"] = sonyStates.States()
+	}
+	if shieldManager != nil {
+		devices["shield"] = shieldStates.States()
+	}
+	if xboxManager != nil {
+		devices["xbox"] = xboxStates.States()
+	}
+	if ps5Manager != nil {
+		devices["ps5"] = ps5States.States()
+	}
+	if cards := haMediaPlayerCards(); len(cards) > 0 {
+		devices["ha"] = cards
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(devices)
+}
+
+// handleDiscoverEntertainment searches the LAN for Sony TVs and soundbars to
+// help fill in SONY_DEVICES. ?timeout= sets how long to listen (1-10 seconds).
+func handleDiscoverEntertainment(w http.ResponseWriter, r *http.Request) {
+	timeout := 3 * time.Second
+	if t := r.URL.Query().Get("timeout"); t != "" {
+		seconds, err := strconv.Atoi(t)
+		if err != nil || seconds < 1 || seconds > 10 {
+			http.Error(w, "timeout must be between 1 and 10 seconds", http.StatusBadRequest)
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+
+	found, err := entertainment.DiscoverSony(r.Context(), timeout)
+	if err != nil {
+		log.Printf("Error discovering Sony devices: %v", err)
+		http.Error(w, "Failed to discover devices: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	configured := make(map[string]bool)
+	for _, dev := range appConfig.SonyDevices {
+		configured[dev.Host] = true
+	}
+	for i := range found {
+		found[i].Configured = configured[found[i].IP]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(found)
+}
+
+// ========== Sony Handlers ==========
+
+func handleGetSonyDevices(w http.ResponseWriter, r *http.Request) {
+	if sonyManager == nil {
+		http.Error(w, "Sony devices not configured", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sonyStates.States())
+}
+
+func handleGetSonyState(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if sonyManager == nil {
+		http.Error(w, "Sony devices not configured", http.StatusNotFound)
+		return
+	}
+	if sonyManager.GetDevice(name) == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sonyStates.State(name))
+}
+
+func handleSonyPower(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if sonyManager == nil {
+		http.Error(w, "Sony devices not configured", http.StatusNotFound)
+		return
+	}
+	device := sonyManager.GetDevice(name)
+	if device == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Action string `json:"action"` // "on", "off", "toggle"
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	switch req.Action {
+	case "on":
+		err = device.PowerOn()
+	case "off":
+		err = device.PowerOff()
+	case "toggle":
+		status, _ := device.GetPowerStatus()
+		if status != nil && status.Status == "active" {
+			err = device.PowerOff()
+		} else {
+			err = device.PowerOn()
+		}
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordDevicePower(r, "sony", name, req.Action)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+func handleSonyVolume(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if sonyManager == nil {
+		http.Error(w, "Sony devices not configured", http.StatusNotFound)
+		return
+	}
+	device := sonyManager.GetDevice(name)
+	if device == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Action string `json:"action"` // "set", "up", "down"
+		Value  int    `json:"value"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	switch req.Action {
+	case "set", "up":
+		// The quiet hours cap is a percentage; soundbars go to 50, TVs to 100
+		var vol *entertainment.VolumeInfo
+		if vol, err = device.GetVolume(); err != nil {
+			break
+		}
+		volume := req.Value
+		if req.Action == "up" {
+			step := req.Value
+			if step <= 0 {
+				step = 1
+			}
+			volume = min(vol.Volume+step, vol.MaxVol)
+		}
+		if vol.MaxVol > 0 {
+			requested := volume * 100 / vol.MaxVol
+			if capped := clampVolume(w, "sony/"+name, requested); capped < requested {
+				volume = capped * vol.MaxVol / 100
+				if req.Action == "up" {
+					volume = max(volume, vol.Volume) // Up never turns it down
+				}
+			}
+		}
+		err = device.SetVolume(volume)
+	case "down":
+		step := req.Value
+		if step <= 0 {
+			step = 1
+		}
+		err = device.VolumeDown(step)
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+func handleSonyMute(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if sonyManager == nil {
+		http.Error(w, "Sony devices not configured", http.StatusNotFound)
+		return
+	}
+	device := sonyManager.GetDevice(name)
+	if device == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Action string `json:"action"` // "on", "off", "toggle"
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	switch req.Action {
+	case "on":
+		err = device.SetMute(true)
+	case "off":
+		err = device.SetMute(false)
+	case "toggle":
+		err = device.ToggleMute()
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+func handleSonyInput(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if sonyManager == nil {
+		http.Error(w, "Sony devices not configured", http.StatusNotFound)
+		return
+	}
+	device := sonyManager.GetDevice(name)
+	if device == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Input string `json:"input"` // HDMI port number or URI
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := setSonyInput(device, req.Input); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// handleGetSonyInputs returns a TV's external inputs (with labels and whether
+// anything is connected) and what's playing
+func handleGetSonyInputs(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if sonyManager == nil {
+		http.Error(w, "Sony devices not configured", http.StatusNotFound)
+		return
+	}
+	device := sonyManager.GetDevice(name)
+	if device == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	inputs, err := device.GetInputs()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	playing, _ := device.GetPlayingContent()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"inputs":  inputs,
+		"playing": playing,
+	})
+}
+
+// handleGetSonyChannels returns a TV's tuner channels and what's playing
+func handleGetSonyChannels(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if sonyManager == nil {
+		http.Error(w, "Sony devices not configured", http.StatusNotFound)
+		return
+	}
+	device := sonyManager.GetDevice(name)
+	if device == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	channels, err := device.GetChannels()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	playing, _ := device.GetPlayingContent()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"channels": channels,
+		"playing":  playing,
+	})
+}
+
+func handleGetSonyApps(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if sonyManager == nil {
+		http.Error(w, "Sony devices not configured", http.StatusNotFound)
+		return
+	}
+	device := sonyManager.GetDevice(name)
+	if device == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	apps, err := device.GetApps()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(apps)
+}
+
+// handleLaunchSonyApp starts an app by the ID from /apps, or by its title
+func handleLaunchSonyApp(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if sonyManager == nil {
+		http.Error(w, "Sony devices not configured", http.StatusNotFound)
+		return
+	}
+	device := sonyManager.GetDevice(name)
+	if device == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	if err := device.LaunchApp(chi.URLParam(r, "id")); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// setSonyInput switches a Sony device to a named input (tv, bluetooth, analog),
+// an HDMI port ("2" or "hdmi2") or a raw URI
+func setSonyInput(device *entertainment.SonyDevice, input string) error {
+	switch strings.ToLower(input) {
+	case "tv":
+		return device.SetTV()
+	case "bluetooth", "bt":
+		return device.SetBluetooth()
+	case "analog", "line":
+		return device.SetAnalog()
+	}
+	// Check if it's a simple HDMI port number
+	if port, err := strconv.Atoi(strings.TrimPrefix(strings.ToLower(input), "hdmi")); err == nil {
+		return device.SetHDMI(port)
+	}
+	// Assume it's a raw URI
+	return device.SetInput(input)
+}
+
+// ========== Activity Handlers ==========
+
+func handleGetActivities(w http.ResponseWriter, r *http.Request) {
+	activities := []entertainment.Activity{}
+	if activityRunner != nil {
+		activities = activityRunner.Activities()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(activities)
+}
+
+// activityTimeout bounds an activity run, waits included
+const activityTimeout = 3 * time.Minute
+
+// handleRunActivity runs an activity macro. The run isn't tied to the request,
+// so a client giving up doesn't leave the devices half switched.
+func handleRunActivity(w http.ResponseWriter, r *http.Request) {
+	if activityRunner == nil {
+		http.Error(w, "Activities not configured", http.StatusNotFound)
+		return
+	}
+	name := chi.URLParam(r, "name")
+
+	ctx, cancel := context.WithTimeout(context.Background(), activityTimeout)
+	defer cancel()
+	result, err := activityRunner.Run(ctx, name)
+	switch {
+	case errors.Is(err, entertainment.ErrUnknownActivity):
+		http.Error(w, "Activity not found", http.StatusNotFound)
+		return
+	case errors.Is(err, entertainment.ErrActivityRunning):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case err != nil:
+		log.Printf("Error running activity %s: %v", name, err)
+		http.Error(w, "Failed to run activity: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordJournal(journal.Entry{Kind: journal.KindAction, Subject: "activity", To: result.Activity, Source: requestDeviceID(r)})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// runActivityStep performs an activity's device step, returning how to undo it
+func runActivityStep(ctx context.Context, step entertainment.Step) (func() error, error) {
+	on := step.Value == "on"
+	if step.Action == "power" && !on && step.Value != "off" {
+		return nil, fmt.Errorf("power must be on or off")
+	}
+
+	switch step.Platform {
+	case "sony":
+		if sonyManager == nil || sonyManager.GetDevice(step.Device) == nil {
+			return nil, fmt.Errorf("unknown Sony device %s", step.Device)
+		}
+		device := sonyManager.GetDevice(step.Device)
+		switch step.Action {
+		case "power":
+			previous, _ := device.GetPowerStatus()
+			var err error
+			if on {
+				err = device.PowerOn()
+			} else {
+				err = device.PowerOff()
+			}
+			if err != nil {
+				return nil, err
+			}
+			go refreshEntertainmentState("sony", step.Device)
+			if previous == nil || (previous.Status == "active") == on {
+				return nil, nil
+			}
+			return func() error {
+				if on {
+					return device.PowerOff()
+				}
+				return device.PowerOn()
+			}, nil
+		case "input":
+			previous, _ := device.GetPlayingContent()
+			if err := setSonyInput(device, step.Value); err != nil {
+				return nil, err
+			}
+			if previous == nil || previous.URI == "" {
+				return nil, nil
+			}
+			return func() error { return device.SetInput(previous.URI) }, nil
+		}
+
+	case "shield":
+		if shieldManager == nil || shieldManager.GetDevice(step.Device) == nil {
+			return nil, fmt.Errorf("unknown Shield device %s", step.Device)
+		}
+		device := shieldManager.GetDevice(step.Device)
+		if step.Action == "power" {
+			if !on {
+				return nil, device.Sleep()
+			}
+			// Only put it back to sleep if it was known to be asleep; over
+			// ADB a reachable Shield may be awake or in standby
+			previous := device.GetState()
+			wasOff := !previous.Online || previous.Power == "standby"
+			if err := device.WakeUp(); err != nil {
+				return nil, err
+			}
+			if !wasOff {
+				return nil, nil
+			}
+			return device.Sleep, nil
+		}
+
+	case "xbox":
+		if xboxManager == nil || xboxManager.GetDevice(step.Device) == nil {
+			return nil, fmt.Errorf("unknown Xbox device %s", step.Device)
+		}
+		if step.Action == "power" {
+			if !on {
+				return nil, xboxManager.PowerOff(step.Device)
+			}
+			device := xboxManager.GetDevice(step.Device)
+			wasOff := !device.GetState().Online
+			if err := device.PowerOn(); err != nil {
+				return nil, err
+			}
+			if !wasOff {
+				return nil, nil
+			}
+			return func() error { return xboxManager.PowerOff(step.Device) }, nil
+		}
+
+	case "ps5":
+		if ps5Manager == nil || ps5Manager.GetDevice(step.Device) == nil {
+			return nil, fmt.Errorf("unknown PS5 device %s", step.Device)
+		}
+		if step.Action == "power" {
+			if !on {
+				return nil, ps5Manager.PowerOff(step.Device)
+			}
+			previous := ps5Manager.GetState(step.Device)
+			if err := ps5Manager.PowerOn(step.Device); err != nil {
+				return nil, err
+			}
+			if previous == nil || previous.Power != "STANDBY" {
+				return nil, nil
+			}
+			return func() error { return ps5Manager.PowerOff(step.Device) }, nil
+		}
+
+	case "ir":
+		if irManager == nil || !irManager.HasDevice(step.Device) {
+			return nil, fmt.Errorf("unknown IR device %s", step.Device)
+		}
+		repeat := 1
+		if step.Value != "" {
+			n, err := strconv.Atoi(step.Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IR repeat %q", step.Value)
+			}
+			repeat = n
+		}
+		if err := irManager.Send(step.Device, step.Action, repeat); err != nil {
+			return nil, err
+		}
+		// A command with an _off counterpart, like power_on, is undone by it
+		if base, ok := strings.CutSuffix(step.Action, "_on"); ok && irManager.HasCommand(step.Device, base+"_off") {
+			return func() error { return irManager.Send(step.Device, base+"_off", 1) }, nil
+		}
+		return nil, nil
+
+	case "syncbox":
+		client := findSyncBox(step.Device)
+		if client == nil {
+			return nil, fmt.Errorf("unknown sync box %s", step.Device)
+		}
+		previous, _ := client.GetExecution()
+		switch step.Action {
+		case "mode":
+			if err := client.SetMode(step.Value); err != nil {
+				return nil, err
+			}
+			if previous == nil {
+				return nil, nil
+			}
+			return func() error { return client.SetMode(previous.Mode) }, nil
+		case "input":
+			if err := client.SetHDMISource(step.Value); err != nil {
+				return nil, err
+			}
+			if previous == nil {
+				return nil, nil
+			}
+			return func() error { return client.SetHDMISource(previous.HDMISource) }, nil
+		case "sync":
+			if err := client.SetSyncActive(step.Value == "on"); err != nil {
+				return nil, err
+			}
+			if previous == nil {
+				return nil, nil
+			}
+			return func() error { return client.SetSyncActive(previous.SyncActive) }, nil
+		}
+
+	default:
+		return nil, fmt.Errorf("unknown platform %s", step.Platform)
+	}
+	return nil, fmt.Errorf("unsupported %s action %s", step.Platform, step.Action)
+}
+
+// parseIRDevices parses IR_DEVICES, e.g. "receiver=broadlink:192.168.1.50"
+func parseIRDevices(s string) []ir.Device {
+	devices, err := ir.ParseDevices(s)
+	if err != nil {
+		log.Printf("Warning: Ignoring IR_DEVICES: %v", err)
+		return nil
+	}
+	return devices
+}
+
+// ========== IR Handlers ==========
+
+func handleGetIRDevices(w http.ResponseWriter, r *http.Request) {
+	if irManager == nil {
+		http.Error(w, "IR devices not configured", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(irManager.Devices())
+}
+
+// SendIRRequest is the optional body for POST /api/ir/{device}/{command}
+type SendIRRequest struct {
+	Repeat int `json:"repeat"` // Times to send, e.g. for volume steps (default 1)
+}
+
+func handleSendIR(w http.ResponseWriter, r *http.Request) {
+	if irManager == nil {
+		http.Error(w, "IR devices not configured", http.StatusNotFound)
+		return
+	}
+	device := chi.URLParam(r, "device")
+	command := chi.URLParam(r, "command")
+	if !irManager.HasDevice(device) {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	var req SendIRRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := irManager.Send(device, command, req.Repeat); err != nil {
+		log.Printf("Error sending IR %s %s: %v", device, command, err)
+		http.Error(w, "Failed to send IR command: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordJournal(journal.Entry{Kind: journal.KindAction, Subject: "ir:" + device, To: command, Source: requestDeviceID(r)})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// SetIRCodeRequest is the body for PUT /api/ir/{device}/{command}
+type SetIRCodeRequest struct {
+	Code string `json:"code"` // Broadlink base64, or ESPHome raw timings
+}
+
+func handleSetIRCode(w http.ResponseWriter, r *http.Request) {
+	if irManager == nil {
+		http.Error(w, "IR devices not configured", http.StatusNotFound)
+		return
+	}
+	device := chi.URLParam(r, "device")
+	if !irManager.HasDevice(device) {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	var req SetIRCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := irManager.SetCode(device, chi.URLParam(r, "command"), req.Code); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(irManager.Devices())
+}
+
+func handleDeleteIRCode(w http.ResponseWriter, r *http.Request) {
+	if irManager == nil {
+		http.Error(w, "IR devices not configured", http.StatusNotFound)
+		return
+	}
+	if err := irManager.DeleteCode(chi.URLParam(r, "device"), chi.URLParam(r, "command")); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// irLearnTimeout is how long learning waits for a remote's button
+const irLearnTimeout = 30 * time.Second
+
+// handleLearnIR records a code from a remote pointed at a Broadlink blaster
+func handleLearnIR(w http.ResponseWriter, r *http.Request) {
+	if irManager == nil {
+		http.Error(w, "IR devices not configured", http.StatusNotFound)
+		return
+	}
+	device := chi.URLParam(r, "device")
+	if !irManager.HasDevice(device) {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	code, err := irManager.Learn(device, chi.URLParam(r, "command"), irLearnTimeout)
+	if err != nil {
+		log.Printf("Error learning IR code for %s: %v", device, err)
+		http.Error(w, "Failed to learn IR code: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(SetIRCodeRequest{Code: code})
+}
+
+// findSyncBox returns a sync box by index or name
+func findSyncBox(device string) *syncbox.Client {
+	syncBoxMu.RLock()
+	defer syncBoxMu.RUnlock()
+	if i, err := strconv.Atoi(device); err == nil && i >= 0 && i < len(syncBoxClients) {
+		return syncBoxClients[i]
+	}
+	for _, client := range syncBoxClients {
+		if strings.EqualFold(client.GetName(), device) {
+			return client
+		}
+	}
+	return nil
+}
+
+// ========== Shield Handlers ==========
+
+func handleGetShieldDevices(w http.ResponseWriter, r *http.Request) {
+	if shieldManager == nil {
+		http.Error(w, "Shield devices not configured", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shieldStates.States())
+}
+
+func handleGetShieldState(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if shieldManager == nil {
+		http.Error(w, "Shield devices not configured", http.StatusNotFound)
+		return
+	}
+	if shieldManager.GetDevice(name) == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shieldStates.State(name))
+}
+
+func handleShieldPower(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if shieldManager == nil {
+		http.Error(w, "Shield devices not configured", http.StatusNotFound)
+		return
+	}
+	device := shieldManager.GetDevice(name)
+	if device == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Action string `json:"action"` // "wake", "sleep"
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	switch req.Action {
+	case "wake":
+		err = device.WakeUp()
+	case "sleep":
+		err = device.Sleep()
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordDevicePower(r, "shield", name, req.Action)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// ShieldPairRequest pairs the Android TV remote transport: without a code the
+// Shield shows one on screen, then the same call with that code finishes
+type ShieldPairRequest struct {
+	Code string `json:"code"`
+}
+
+func handleShieldPair(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if shieldManager == nil {
+		http.Error(w, "Shield devices not configured", http.StatusNotFound)
+		return
+	}
+	device := shieldManager.GetDevice(name)
+	if device == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	var req ShieldPairRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	status := "paired"
+	if req.Code == "" {
+		if err := device.StartPairing(); err != nil {
+			log.Printf("Error starting Shield pairing for %s: %v", name, err)
+			http.Error(w, "Failed to start pairing: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		status = "code_shown"
+	} else if err := device.FinishPairing(req.Code); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": status})
+}
+
+func handleShieldNavigate(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if shieldManager == nil {
+		http.Error(w, "Shield devices not configured", http.StatusNotFound)
+		return
+	}
+	device := shieldManager.GetDevice(name)
+	if device == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Action string `json:"action"` // "up", "down", "left", "right", "select", "back", "home", "menu"
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	switch req.Action {
+	case "up":
+		err = device.Up()
+	case "down":
+		err = device.Down()
+	case "left":
+		err = device.Left()
+	case "right":
+		err = device.Right()
+	case "select", "enter":
+		err = device.Select()
+	case "back":
+		err = device.Back()
+	case "home":
+		err = device.Home()
+	case "menu":
+		err = device.Menu()
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+func handleShieldMedia(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if shieldManager == nil {
+		http.Error(w, "Shield devices not configured", http.StatusNotFound)
+		return
+	}
+	device := shieldManager.GetDevice(name)
+	if device == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Action string `json:"action"` // "play", "pause", "playpause", "stop", "next", "previous", "rewind", "forward"
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	switch req.Action {
+	case "play":
+		err = device.Play()
+	case "pause":
+		err = device.Pause()
+	case "playpause":
+		err = device.PlayPause()
+	case "stop":
+		err = device.Stop()
+	case "next":
+		err = device.Next()
+	case "previous":
+		err = device.Previous()
+	case "rewind":
+		err = device.Rewind()
+	case "forward":
+		err = device.FastForward()
+	case "volumeup":
+		err = device.VolumeUp()
+	case "volumedown":
+		err = device.VolumeDown()
+	case "mute":
+		err = device.Mute()
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+func handleShieldApp(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if shieldManager == nil {
+		http.Error(w, "Shield devices not configured", http.StatusNotFound)
+		return
+	}
+	device := shieldManager.GetDevice(name)
+	if device == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Action  string `json:"action"`  // "launch", "stop"
+		Package string `json:"package"` // App name or package
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	switch req.Action {
+	case "launch":
+		err = device.LaunchApp(req.Package)
+	case "stop":
+		err = device.ForceStopApp(req.Package)
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// ========== Xbox Handlers ==========
+
+func handleGetXboxDevices(w http.ResponseWriter, r *http.Request) {
+	if xboxManager == nil {
+		http.Error(w, "Xbox devices not configured", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(xboxStates.States())
+}
+
+func handleGetXboxState(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if xboxManager == nil {
+		http.Error(w, "Xbox devices not configured", http.StatusNotFound)
+		return
+	}
+	if xboxManager.GetDevice(name) == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(xboxStates.State(name))
+}
+
+func handleXboxPower(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if xboxManager == nil {
+		http.Error(w, "Xbox devices not configured", http.StatusNotFound)
+		return
+	}
+	device := xboxManager.GetDevice(name)
+	if device == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Action string `json:"action"` // "on", "off"
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	switch req.Action {
+	case "on":
+		err = device.PowerOn()
+	case "off":
+		err = xboxManager.PowerOff(name)
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordDevicePower(r, "xbox", name, req.Action)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+func handleXboxInput(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if xboxManager == nil {
+		http.Error(w, "Xbox devices not configured", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Button string `json:"button"` // Button name
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := xboxManager.SendButton(name, req.Button); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+func handleXboxMedia(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if xboxManager == nil {
+		http.Error(w, "Xbox devices not configured", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Action string `json:"action"` // "play", "pause", "playpause", "stop", "next", "previous"
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	switch req.Action {
+	case "play":
+		err = xboxManager.Play(name)
+	case "pause":
+		err = xboxManager.Pause(name)
+	case "playpause":
+		err = xboxManager.PlayPause(name)
+	case "stop":
+		err = xboxManager.Stop(name)
+	case "next":
+		err = xboxManager.Next(name)
+	case "previous":
+		err = xboxManager.Previous(name)
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// ========== PS5 Handlers ==========
+
+func handleGetPS5Devices(w http.ResponseWriter, r *http.Request) {
+	if ps5Manager == nil {
+		http.Error(w, "PS5 devices not configured", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ps5States.States())
+}
+
+func handleGetPS5State(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if ps5Manager == nil {
+		http.Error(w, "PS5 devices not configured", http.StatusNotFound)
+		return
+	}
+	state := ps5Manager.GetState(name)
+	if state == nil {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(state)
+}
+
+func handlePS5Power(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if ps5Manager == nil {
+		http.Error(w, "PS5 devices not configured", http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		Action string `json:"action"` // "on", "off", "toggle"
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var err error
+	switch req.Action {
+	case "on":
+		err = ps5Manager.PowerOn(name)
+	case "off":
+		err = ps5Manager.PowerOff(name)
+	case "toggle":
+		err = ps5Manager.TogglePower(name)
+	default:
+		http.Error(w, "Invalid action", http.StatusBadRequest)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordDevicePower(r, "ps5", name, req.Action)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+func handleGetSonosZones(w http.ResponseWriter, r *http.Request) {
+	if sonosManager == nil {
+		http.Error(w, "Sonos not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	zones, err := sonosManager.Zones(r.Context())
+	if err != nil {
+		log.Printf("Error getting Sonos zones: %v", err)
+		http.Error(w, "Failed to get Sonos zones: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(zones)
+}
+
+func handleGetSonosRooms(w http.ResponseWriter, r *http.Request) {
+	if sonosManager == nil {
+		http.Error(w, "Sonos not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	rooms, err := sonosManager.Rooms(r.Context())
+	if err != nil {
+		log.Printf("Error getting Sonos rooms: %v", err)
+		http.Error(w, "Failed to get Sonos rooms: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rooms)
+}
+
+// handleSonosRoom runs a room action and replies with the zones as they are now
+func handleSonosRoom(w http.ResponseWriter, r *http.Request, action func(room string) error) {
+	if sonosManager == nil {
+		http.Error(w, "Sonos not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	room := chi.URLParam(r, "room")
+	if err := action(room); err != nil {
+		log.Printf("Error controlling Sonos %s: %v", room, err)
+		http.Error(w, "Failed to control Sonos: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	handleGetSonosZones(w, r)
+}
+
+func handleSonosJoin(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Coordinator string `json:"coordinator"` // Room to join
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Coordinator == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	handleSonosRoom(w, r, func(room string) error {
+		return sonosManager.Join(r.Context(), room, req.Coordinator)
+	})
+}
+
+func handleSonosLeave(w http.ResponseWriter, r *http.Request) {
+	handleSonosRoom(w, r, func(room string) error {
+		return sonosManager.Leave(r.Context(), room)
+	})
+}
+
+func handleSonosVolume(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Volume *int  `json:"volume,omitempty"`
+		Muted  *bool `json:"muted,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	handleSonosRoom(w, r, func(room string) error {
+		if req.Volume != nil {
+			if err := sonosManager.SetVolume(r.Context(), room, clampVolume(w, "sonos/"+room, *req.Volume)); err != nil {
+				return err
+			}
+		}
+		if req.Muted != nil {
+			return sonosManager.SetMute(r.Context(), room, *req.Muted)
+		}
+		return nil
+	})
+}
+
+func handleSonosControl(w http.ResponseWriter, r *http.Request) {
+	action := chi.URLParam(r, "action")
+	handleSonosRoom(w, r, func(room string) error {
+		return sonosManager.Control(r.Context(), room, action)
+	})
+}
+
+// Template functions
+func formatDate(t time.Time) string {
+	return t.In(appConfig.Timezone).Format("Mon, Jan 2")
+}
+
+func formatTime(t time.Time) string {
+	return t.In(appConfig.Timezone).Format("3:04 PM")
+}
+
+func formatDateTime(t time.Time) string {
+	return t.In(appConfig.Timezone).Format("Mon, Jan 2 at 3:04 PM")
+}
+
+func isToday(t time.Time) bool {
+	now := time.Now().In(appConfig.Timezone)
+	local := t.In(appConfig.Timezone)
+	return local.Year() == now.Year() && local.YearDay() == now.YearDay()
+}
+
+func isTomorrow(t time.Time) bool {
+	tomorrow := time.Now().In(appConfig.Timezone).AddDate(0, 0, 1)
+	local := t.In(appConfig.Timezone)
+	return local.Year() == tomorrow.Year() && local.YearDay() == tomorrow.YearDay()
+}
+
+func dayName(t time.Time) string {
+	local := t.In(appConfig.Timezone)
+	if isToday(t) {
+		return "Today"
+	}
+	if isTomorrow(t) {
+		return "Tomorrow"
+	}
+	return fmt.Sprintf("%s, %s %d", local.Weekday().String(), local.Month().String()[:3], local.Day())
+}
+
+// Tasks API handlers
+
+func handleGetTasks(w http.ResponseWriter, r *http.Request) {
+	if tasksClient == nil || !tasksClient.IsAuthorized() {
+		http.Error(w, "Tasks not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	listID := r.URL.Query().Get("listId")
+	tasksList, err := tasksClient.GetTasks(r.Context(), listID)
+	if err != nil {
+		log.Printf("Error getting tasks: %v", err)
+		http.Error(w, "Failed to get tasks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tasksList)
+}
+
+func handleGetTaskLists(w http.ResponseWriter, r *http.Request) {
+	if tasksClient == nil || !tasksClient.IsAuthorized() {
+		http.Error(w, "Tasks not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	lists, err := tasksClient.GetTaskLists(r.Context())
+	if err != nil {
+		log.Printf("Error getting task lists: %v", err)
+		http.Error(w, "Failed to get task lists: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lists)
+}
+
+type CreateTaskRequest struct {
+	ListID string     `json:"listId"`
+	Title  string     `json:"title"`
+	Notes  string     `json:"notes"`
+	Due    *time.Time `json:"due,omitempty"`
+	Parent string     `json:"parent,omitempty"` // Parent task ID to create a subtask
+	// Optional repeat rule; the next occurrence is created when the task is completed
+	Recurrence *tasks.Recurrence `json:"recurrence,omitempty"`
+}
+
+func handleCreateTask(w http.ResponseWriter, r *http.Request) {
+	if tasksClient == nil || !tasksClient.IsAuthorized() {
+		http.Error(w, "Tasks not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req CreateTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Title == "" {
+		http.Error(w, "Title is required", http.StatusBadRequest)
+		return
+	}
+
+	if req.Recurrence != nil {
+		if err := req.Recurrence.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	task, err := tasksClient.CreateTask(r.Context(), req.ListID, req.Title, req.Notes, req.Due, req.Parent)
+	if err != nil {
+		log.Printf("Error creating task: %v", err)
+		http.Error(w, "Failed to create task: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if req.Recurrence != nil {
+		if err := tasksClient.SetRecurrence(task.ID, req.Recurrence); err != nil {
+			log.Printf("Error setting task recurrence: %v", err)
+		} else {
+			task.Recurrence = req.Recurrence
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(task)
+}
+
+func handleToggleTask(w http.ResponseWriter, r *http.Request) {
+	if tasksClient == nil || !tasksClient.IsAuthorized() {
+		http.Error(w, "Tasks not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	listID := chi.URLParam(r, "listID")
+	taskID := chi.URLParam(r, "taskID")
+
+	task, err := tasksClient.ToggleTask(r.Context(), listID, taskID)
+	if err != nil {
+		log.Printf("Error toggling task: %v", err)
+		http.Error(w, "Failed to toggle task: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(task)
+}
+
+// UpdateTaskRequest holds editable task fields; omitted fields are left unchanged
+type UpdateTaskRequest struct {
+	Title    *string    `json:"title,omitempty"`
+	Notes    *string    `json:"notes,omitempty"`
+	Due      *time.Time `json:"due,omitempty"`
+	ClearDue bool       `json:"clearDue,omitempty"`
+}
+
+func handleUpdateTask(w http.ResponseWriter, r *http.Request) {
+	if tasksClient == nil || !tasksClient.IsAuthorized() {
+		http.Error(w, "Tasks not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	listID := chi.URLParam(r, "listID")
+	taskID := chi.URLParam(r, "taskID")
+
+	var req UpdateTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Title != nil && *req.Title == "" {
+		http.Error(w, "Title cannot be empty", http.StatusBadRequest)
+		return
+	}
+
+	task, err := tasksClient.UpdateTask(r.Context(), listID, taskID, tasks.TaskUpdate{
+		Title:    req.Title,
+		Notes:    req.Notes,
+		Due:      req.Due,
+		ClearDue: req.ClearDue,
+	})
+	if err != nil {
+		log.Printf("Error updating task: %v", err)
+		http.Error(w, "Failed to update task: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(task)
+}
+
+// MoveTaskRequest positions a task after a sibling and/or under a parent task
+type MoveTaskRequest struct {
+	Parent   string `json:"parent,omitempty"`   // Parent task ID (empty = top level)
+	Previous string `json:"previous,omitempty"` // Sibling to place after (empty = first)
+}
+
+func handleMoveTask(w http.ResponseWriter, r *http.Request) {
+	if tasksClient == nil || !tasksClient.IsAuthorized() {
+		http.Error(w, "Tasks not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	listID := chi.URLParam(r, "listID")
+	taskID := chi.URLParam(r, "taskID")
+
+	var req MoveTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Parent == taskID || req.Previous == taskID {
+		http.Error(w, "Task cannot be moved relative to itself", http.StatusBadRequest)
+		return
+	}
+
+	task, err := tasksClient.MoveTask(r.Context(), listID, taskID, req.Parent, req.Previous)
+	if err != nil {
+		log.Printf("Error moving task: %v", err)
+		http.Error(w, "Failed to move task: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(task)
+}
+
+func handleSetTaskRecurrence(w http.ResponseWriter, r *http.Request) {
+	if tasksClient == nil || !tasksClient.IsAuthorized() {
+		http.Error(w, "Tasks not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	taskID := chi.URLParam(r, "taskID")
+
+	var rule tasks.Recurrence
+	if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := rule.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := tasksClient.SetRecurrence(taskID, &rule); err != nil {
+		log.Printf("Error setting task recurrence: %v", err)
+		http.Error(w, "Failed to set recurrence: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rule)
+}
+
+func handleDeleteTaskRecurrence(w http.ResponseWriter, r *http.Request) {
+	if tasksClient == nil || !tasksClient.IsAuthorized() {
+		http.Error(w, "Tasks not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	taskID := chi.URLParam(r, "taskID")
+
+	if err := tasksClient.SetRecurrence(taskID, nil); err != nil {
+		log.Printf("Error removing task recurrence: %v", err)
+		http.Error(w, "Failed to remove recurrence: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleDeleteTask(w http.ResponseWriter, r *http.Request) {
+	if tasksClient == nil || !tasksClient.IsAuthorized() {
+		http.Error(w, "Tasks not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	listID := chi.URLParam(r, "listID")
+	taskID := chi.URLParam(r, "taskID")
+
+	if err := tasksClient.DeleteTask(r.Context(), listID, taskID); err != nil {
+		log.Printf("Error deleting task: %v", err)
+		http.Error(w, "Failed to delete task: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleClearCompleted(w http.ResponseWriter, r *http.Request) {
+	if tasksClient == nil || !tasksClient.IsAuthorized() {
+		http.Error(w, "Tasks not configured or not authorized", http.StatusServiceUnavailable)
+		return
+	}
+
+	listID := chi.URLParam(r, "listID")
+
+	if err := tasksClient.ClearCompleted(r.Context(), listID); err != nil {
+		log.Printf("Error clearing completed tasks: %v", err)
+		http.Error(w, "Failed to clear completed tasks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Notes API handlers
+
+func handleGetNotes(w http.ResponseWriter, r *http.Request) {
+	list := r.URL.Query().Get("list")
+	if list == "" {
+		list = notes.ListShopping
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"list":  list,
+		"lists": notesStore.Lists(),
+		"items": notesStore.List(list),
+	})
+}
+
+func handleCreateNote(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		List string `json:"list"`
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	req.Text = strings.TrimSpace(req.Text)
+	if req.Text == "" {
+		http.Error(w, "Text is required", http.StatusBadRequest)
+		return
+	}
+
+	item, err := notesStore.Add(req.List, req.Text)
+	if err != nil {
+		log.Printf("Error creating note: %v", err)
+		http.Error(w, "Failed to create note: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+func handleUpdateNote(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req struct {
+		Text *string `json:"text,omitempty"`
+		Done *bool   `json:"done,omitempty"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	item, err := notesStore.Update(id, req.Text, req.Done)
+	if err != nil {
+		log.Printf("Error updating note: %v", err)
+		http.Error(w, "Failed to update note: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+func handleDeleteNote(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := notesStore.Delete(id); err != nil {
+		log.Printf("Error deleting note: %v", err)
+		http.Error(w, "Failed to delete note: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleClearNotes(w http.ResponseWriter, r *http.Request) {
+	list := r.URL.Query().Get("list")
+	if list == "" {
+		list = notes.ListShopping
+	}
+
+	if err := notesStore.ClearDone(list); err != nil {
+		log.Printf("Error clearing notes: %v", err)
+		http.Error(w, "Failed to clear notes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Day context
+
+// dayTags returns the kind and tags of t's day, for schedules limited to
+// some kinds of day
+func dayTags(t time.Time) []string {
+	return dayContext.Classify(context.Background(), t).Tags
+}
+
+// dayContextEvents supplies calendar events for day classification when Google
+// Calendar is authorized
+func dayContextEvents(ctx context.Context, start, end time.Time) ([]daycontext.Event, error) {
+	if calClient == nil || !calClient.IsAuthorized() {
+		return nil, nil
+	}
+	events, err := calClient.GetEventsInRange(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]daycontext.Event, 0, len(events))
+	for _, e := range events {
+		result = append(result, daycontext.Event{Title: e.Title, CalendarID: e.CalendarID, AllDay: e.AllDay})
+	}
+	return result, nil
+}
+
+func handleGetContextToday(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dayContext.Today(r.Context()))
+}
+
+// handleGetContextDay classifies any date, e.g. /api/context/day/2026-12-24
+func handleGetContextDay(w http.ResponseWriter, r *http.Request) {
+	date, err := time.ParseInLocation("2006-01-02", chi.URLParam(r, "date"), appConfig.Timezone)
+	if err != nil {
+		http.Error(w, "date must be YYYY-MM-DD", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dayContext.Classify(r.Context(), date))
+}
+
+func handleGetContextRules(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dayContext.Rules())
+}
+
+func handleSetContextRules(w http.ResponseWriter, r *http.Request) {
+	var rules daycontext.Rules
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := dayContext.SetRules(rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dayContext.Rules())
+}
+
+// Chores API handlers
+
+func handleGetChores(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// ?format=tasks returns chores in the tasks panel model
+	if r.URL.Query().Get("format") == "tasks" {
+		json.NewEncoder(w).Encode(choresManager.Tasks())
+		return
+	}
+	json.NewEncoder(w).Encode(choresManager.List())
+}
+
+func handleCreateChore(w http.ResponseWriter, r *http.Request) {
+	var req chores.Chore
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	chore, err := choresManager.Create(req)
+	if err != nil {
+		log.Printf("Error creating chore: %v", err)
+		http.Error(w, "Failed to create chore: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chore)
+}
+
+func handleUpdateChore(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req chores.Chore
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	chore, err := choresManager.Update(id, req)
+	if err != nil {
+		log.Printf("Error updating chore: %v", err)
+		http.Error(w, "Failed to update chore: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chore)
+}
+
+func handleDeleteChore(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	if err := choresManager.Delete(id); err != nil {
+		log.Printf("Error deleting chore: %v", err)
+		http.Error(w, "Failed to delete chore: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleChoreDone(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	// Optional body: {"by": "name"} when someone other than the assignee did it
+	var req struct {
+		By string `json:"by"`
+	}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	chore, err := choresManager.MarkDone(id, req.By)
+	if err != nil {
+		log.Printf("Error marking chore done: %v", err)
+		http.Error(w, "Failed to mark chore done: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(chore)
+}
+
+// Countdown API handlers
+
+// syncCountdowns periodically mirrors tagged calendar events (a year ahead) into countdowns
+func syncCountdowns(keyword string) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		if calClient.IsAuthorized() {
+			now := time.Now()
+			events, err := calClient.GetEventsInRange(context.Background(), now, now.AddDate(1, 0, 0))
+			if err != nil {
+				log.Printf("Warning: Failed to fetch calendar events for countdowns: %v", err)
+			} else {
+				tagged := make([]countdowns.Event, 0, len(events))
+				for _, e := range events {
+					tagged = append(tagged, countdowns.Event{ID: e.ID, Title: e.Title, Description: e.Description, Start: e.Start})
+				}
+				if err := countdownManager.SyncCalendar(tagged, keyword); err != nil {
+					log.Printf("Warning: Failed to sync calendar countdowns: %v", err)
+				}
+			}
+		}
+		<-ticker.C
+	}
+}
+
+func handleGetCountdowns(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(countdownManager.List(r.URL.Query().Get("past") == "true"))
+}
+
+func handleCreateCountdown(w http.ResponseWriter, r *http.Request) {
+	var req countdowns.Countdown
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	countdown, err := countdownManager.Create(req)
+	if err != nil {
+		log.Printf("Error creating countdown: %v", err)
+		http.Error(w, "Failed to create countdown: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(countdown)
+}
+
+func handleUpdateCountdown(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var req countdowns.Countdown
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	countdown, err := countdownManager.Update(id, req)
+	if err != nil {
+		log.Printf("Error updating countdown: %v", err)
+		http.Error(w, "Failed to update countdown: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(countdown)
+}
+
+func handleDeleteCountdown(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := countdownManager.Delete(id); err != nil {
+		log.Printf("Error deleting countdown: %v", err)
+		http.Error(w, "Failed to delete countdown: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Item tracker API handlers
+
+func handleGetItems(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(itemTracker.List())
+}
+
+func handleCreateItem(w http.ResponseWriter, r *http.Request) {
+	var req items.Item
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	item, err := itemTracker.Create(req)
+	if err != nil {
+		log.Printf("Error creating item: %v", err)
+		http.Error(w, "Failed to create item: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+func handleUpdateItem(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var req items.Item
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	item, err := itemTracker.Update(id, req)
+	if err != nil {
+		log.Printf("Error updating item: %v", err)
+		http.Error(w, "Failed to update item: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+func handleDeleteItem(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := itemTracker.Delete(id); err != nil {
+		log.Printf("Error deleting item: %v", err)
+		http.Error(w, "Failed to delete item: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetItemTags lists recently heard tags not yet assigned to an item
+func handleGetItemTags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(itemTracker.Tags())
+}
+
+// handleItemSighting records tags a tablet heard. The room defaults to the
+// reporting kiosk's device ID.
+func handleItemSighting(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Room      string           `json:"room"`
+		Sightings []items.Sighting `json:"sightings"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	device := requestDeviceID(r)
+	room := strings.TrimSpace(req.Room)
+	if room == "" {
+		room = device
+	}
+	now := time.Now()
+	for _, s := range req.Sightings {
+		s.Room = room
+		s.Source = device
+		s.At = now
+		itemTracker.Record(s)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleRingItem makes an item's tag beep through its HA button or script
+func handleRingItem(w http.ResponseWriter, r *http.Request) {
+	item, ok := itemTracker.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "Item not found", http.StatusNotFound)
+		return
+	}
+	if !item.CanRing {
+		http.Error(w, "This tag can't ring", http.StatusBadRequest)
+		return
+	}
+	if haClient == nil {
+		http.Error(w, "HA not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	domain, _, _ := strings.Cut(item.RingEntity, ".")
+	service := "turn_on"
+	if domain == "button" || domain == "input_button" {
+		service = "press"
+	}
+	if err := haClient.CallService(domain, service, item.RingEntity); err != nil {
+		log.Printf("Error ringing %s: %v", item.Name, err)
+		http.Error(w, "Failed to ring: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	recordJournal(journal.Entry{Kind: journal.KindAction, Subject: "item:" + item.Name, To: "ring", Source: requestDeviceID(r)})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// Timer API handlers
+
+// CreateTimerRequest starts a timer
+type CreateTimerRequest struct {
+	Name    string `json:"name"`
+	Seconds int    `json:"seconds"`
+}
+
+func handleGetTimers(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(timerManager.List())
+}
+
+func handleCreateTimer(w http.ResponseWriter, r *http.Request) {
+	var req CreateTimerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	timer, err := timerManager.Create(req.Name, req.Seconds, "")
+	if err != nil {
+		log.Printf("Error creating timer: %v", err)
+		http.Error(w, "Failed to create timer: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(timer)
+}
+
+func handleCancelTimer(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := timerManager.Cancel(id); err != nil {
+		log.Printf("Error cancelling timer: %v", err)
+		http.Error(w, "Failed to cancel timer: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SleepTimerRequest is the body for POST /api/sleep-timer. Omitted targets
+// default to pausing Spotify, every Sony and Shield device, and SLEEP_TIMER_LIGHTS.
+type SleepTimerRequest struct {
+	Minutes int       `json:"minutes"`
+	Spotify *bool     `json:"spotify,omitempty"`
+	Sony    *[]string `json:"sony,omitempty"`
+	Shield  *[]string `json:"shield,omitempty"`
+	Lights  *[]string `json:"lights,omitempty"`
+}
+
+func handleGetSleepTimer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sleepTimer.Status())
+}
+
+func handleStartSleepTimer(w http.ResponseWriter, r *http.Request) {
+	var req SleepTimerRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	actions := sleeptimer.Actions{Spotify: len(spotifyClients) > 0, Lights: appConfig.SleepTimerLights}
+	if sonyManager != nil {
+		for name := range sonyManager.GetDevices() {
+			actions.Sony = append(actions.Sony, name)
+		}
+	}
+	if shieldManager != nil {
+		for name := range shieldManager.GetDevices() {
+			actions.Shield = append(actions.Shield, name)
+		}
+	}
+	if req.Spotify != nil {
+		actions.Spotify = *req.Spotify
+	}
+	if req.Sony != nil {
+		actions.Sony = *req.Sony
+	}
+	if req.Shield != nil {
+		actions.Shield = *req.Shield
+	}
+	if req.Lights != nil {
+		actions.Lights = *req.Lights
+	}
+	sort.Strings(actions.Sony)
+	sort.Strings(actions.Shield)
+	for _, name := range actions.Sony {
+		if sonyManager == nil || sonyManager.GetDevice(name) == nil {
+			http.Error(w, "Sony device not found: "+name, http.StatusBadRequest)
+			return
+		}
+	}
+	for _, name := range actions.Shield {
+		if shieldManager == nil || shieldManager.GetDevice(name) == nil {
+			http.Error(w, "Shield not found: "+name, http.StatusBadRequest)
+			return
+		}
+	}
+
+	status, err := sleepTimer.Start(req.Minutes, actions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func handleCancelSleepTimer(w http.ResponseWriter, r *http.Request) {
+	if !sleepTimer.Cancel() {
+		http.Error(w, "No sleep timer running", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// runSleepTimer pauses media and powers things down when the sleep timer runs out
+func runSleepTimer(actions sleeptimer.Actions) {
+	log.Printf("Sleep timer: turning things off")
+	recordJournal(journal.Entry{Kind: journal.KindAction, Subject: "sleep_timer", To: "off", Source: "timer"})
+
+	if actions.Spotify {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		for account, client := range spotifyClients {
+			if state, err := client.GetPlaybackState(ctx); err == nil && state != nil && state.IsPlaying {
+				if err := client.Pause(ctx, ""); err != nil {
+					log.Printf("Warning: Sleep timer failed to pause Spotify (%s): %v", account, err)
+				}
+			}
+		}
+		cancel()
+	}
+	for _, name := range actions.Sony {
+		if err := sonyManager.GetDevice(name).PowerOff(); err != nil {
+			log.Printf("Warning: Sleep timer failed to power off %s: %v", name, err)
+		}
+	}
+	for _, name := range actions.Shield {
+		if err := shieldManager.GetDevice(name).Sleep(); err != nil {
+			log.Printf("Warning: Sleep timer failed to put %s to sleep: %v", name, err)
+		}
+	}
+	for _, target := range actions.Lights {
+		var err error
+		if groupID, ok := strings.CutPrefix(target, "hue:"); ok {
+			if hueClient == nil {
+				err = fmt.Errorf("Hue bridge not configured")
+			} else {
+				err = hueClient.TurnOffGroup(groupID)
+			}
+		} else {
+			err = setEntityPower(target, false)
+		}
+		if err != nil {
+			log.Printf("Warning: Sleep timer failed to turn off %s: %v", target, err)
+		}
+	}
+}
+
+// Recipe API handlers
+
+// ImportRecipeRequest imports a recipe from a web page
+type ImportRecipeRequest struct {
+	URL string `json:"url"`
+}
+
+// StartStepTimerRequest optionally overrides the duration suggested by a recipe step
+type StartStepTimerRequest struct {
+	Seconds int `json:"seconds"`
+}
+
+func handleGetRecipes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recipeBox.List())
+}
+
+func handleImportRecipe(w http.ResponseWriter, r *http.Request) {
+	var req ImportRecipeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	recipe, err := recipeBox.Import(req.URL)
+	if err != nil {
+		log.Printf("Error importing recipe: %v", err)
+		http.Error(w, "Failed to import recipe: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recipe)
+}
+
+func handleGetRecipe(w http.ResponseWriter, r *http.Request) {
+	recipe, err := recipeBox.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(recipe)
+}
+
+func handleDeleteRecipe(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := recipeBox.Delete(id); err != nil {
+		log.Printf("Error deleting recipe: %v", err)
+		http.Error(w, "Failed to delete recipe: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleStartRecipeStepTimer starts a timer for a recipe step (0-based), named after
+// the recipe and step so several running timers stay distinguishable
+func handleStartRecipeStepTimer(w http.ResponseWriter, r *http.Request) {
+	recipe, err := recipeBox.Get(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	index, err := strconv.Atoi(chi.URLParam(r, "step"))
+	if err != nil || index < 0 || index >= len(recipe.Steps) {
+		http.Error(w, fmt.Sprintf("step must be between 0 and %d", len(recipe.Steps)-1), http.StatusBadRequest)
+		return
+	}
+
+	var req StartStepTimerRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	seconds := req.Seconds
+	if seconds == 0 {
+		seconds = recipe.Steps[index].Seconds
+	}
+	if seconds == 0 {
+		http.Error(w, "Step has no duration; pass seconds", http.StatusBadRequest)
+		return
+	}
+
+	name := fmt.Sprintf("%s · step %d", recipe.Name, index+1)
+	timer, err := timerManager.Create(name, seconds, "recipe:"+recipe.ID)
+	if err != nil {
+		log.Printf("Error creating recipe timer: %v", err)
+		http.Error(w, "Failed to create timer: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(timer)
+}
+
+// Pantry API handlers
+
+// UsePantryItemRequest takes some (or, with amount 0, all) of an item
+type UsePantryItemRequest struct {
+	Amount float64 `json:"amount"`
+}
+
+func handleGetPantry(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	w.Header().Set("Content-Type", "application/json")
+
+	// ?expiring=N lists items expiring within N days (including expired ones)
+	if v := q.Get("expiring"); v != "" {
+		days, err := strconv.Atoi(v)
+		if err != nil || days < 0 || days > 365 {
+			http.Error(w, "expiring must be between 0 and 365", http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(pantryManager.Expiring(days))
+		return
+	}
+	json.NewEncoder(w).Encode(pantryManager.List(q.Get("location")))
+}
+
+func handleAddPantryItem(w http.ResponseWriter, r *http.Request) {
+	var req pantry.Item
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	// Scanned items only need a barcode; fill in the rest from Open Food Facts
+	if req.Barcode != "" && req.Name == "" {
+		product, err := pantryManager.Lookup(req.Barcode)
+		if err != nil {
+			log.Printf("Error looking up barcode %s: %v", req.Barcode, err)
+			http.Error(w, "Failed to look up barcode: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		req.Name = product.Name
+		if req.Brand == "" {
+			req.Brand = product.Brand
+		}
+		if req.Image == "" {
+			req.Image = product.Image
+		}
+	}
+
+	item, err := pantryManager.Add(req)
+	if err != nil {
+		log.Printf("Error adding pantry item: %v", err)
+		http.Error(w, "Failed to add pantry item: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+func handleLookupPantryBarcode(w http.ResponseWriter, r *http.Request) {
+	product, err := pantryManager.Lookup(chi.URLParam(r, "barcode"))
+	if errors.Is(err, pantry.ErrProductNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error looking up barcode: %v", err)
+		http.Error(w, "Failed to look up barcode: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(product)
+}
+
+func handleUpdatePantryItem(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var req pantry.Item
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	item, err := pantryManager.Update(id, req)
+	if err != nil {
+		log.Printf("Error updating pantry item: %v", err)
+		http.Error(w, "Failed to update pantry item: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(item)
+}
+
+func handleDeletePantryItem(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := pantryManager.Delete(id); err != nil {
+		log.Printf("Error deleting pantry item: %v", err)
+		http.Error(w, "Failed to delete pantry item: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleUsePantryItem(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var req UsePantryItemRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	item, usedUp, err := pantryManager.Use(id, req.Amount)
+	if err != nil {
+		log.Printf("Error using pantry item: %v", err)
+		http.Error(w, "Failed to use pantry item: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"item":   item,
+		"usedUp": usedUp,
+	})
+}
+
+// addToShoppingList puts a used-up pantry item on the shopping list unless it's already there
+func addToShoppingList(item pantry.Item) {
+	for _, existing := range notesStore.List(notes.ListShopping) {
+		if !existing.Done && strings.EqualFold(existing.Text, item.Name) {
+			return
+		}
+	}
+	if _, err := notesStore.Add(notes.ListShopping, item.Name); err != nil {
+		log.Printf("Warning: Failed to add %s to the shopping list: %v", item.Name, err)
+	}
+}
+
+// Favorites API handlers
+
+// requestDeviceID identifies the kiosk/phone making a request (X-Device-ID header or ?device=)
+func requestDeviceID(r *http.Request) string {
+	if id := r.Header.Get("X-Device-ID"); id != "" {
+		return id
+	}
+	if id := r.URL.Query().Get("device"); id != "" {
+		return id
+	}
+	return favorites.DefaultDevice
+}
+
+// recordAction adds a user action to the history used for favorites suggestions
+func recordAction(r *http.Request, kind, target, service string) {
+	if actionLog == nil {
+		return
+	}
+	actionLog.Record(actions.Action{
+		Device:  requestDeviceID(r),
+		Kind:    kind,
+		Target:  target,
+		Service: service,
+	})
+	recordJournal(journal.Entry{
+		Kind:    journal.KindAction,
+		Subject: target,
+		To:      service,
+		Source:  requestDeviceID(r),
+		Data:    map[string]interface{}{"kind": kind},
+	})
+}
+
+func handleGetFavorites(w http.ResponseWriter, r *http.Request) {
+	device := requestDeviceID(r)
+	history := actionLog.Since(time.Now().AddDate(0, 0, -30))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"device":      device,
+		"favorites":   favoritesStore.Get(device),
+		"suggestions": favoritesStore.Suggest(device, history, 6),
+	})
+}
+
+func handleSetFavorites(w http.ResponseWriter, r *http.Request) {
+	var favs []favorites.Favorite
+	if err := json.NewDecoder(r.Body).Decode(&favs); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	device := requestDeviceID(r)
+	if err := favoritesStore.Set(device, favs); err != nil {
+		log.Printf("Error saving favorites: %v", err)
+		http.Error(w, "Failed to save favorites: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(favoritesStore.Get(device))
+}
+
+func handleAddFavorite(w http.ResponseWriter, r *http.Request) {
+	var fav favorites.Favorite
+	if err := json.NewDecoder(r.Body).Decode(&fav); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	device := requestDeviceID(r)
+	if err := favoritesStore.Add(device, fav); err != nil {
+		log.Printf("Error adding favorite: %v", err)
+		http.Error(w, "Failed to add favorite: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(favoritesStore.Get(device))
+}
+
+func handleRemoveFavorite(w http.ResponseWriter, r *http.Request) {
+	kind := chi.URLParam(r, "kind")
+	target := chi.URLParam(r, "target")
+	if unescaped, err := url.PathUnescape(target); err == nil {
+		target = unescaped
+	}
+
+	if err := favoritesStore.Remove(requestDeviceID(r), kind, target); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SuggestionChip is a one-tap action shown on the home screen
+type SuggestionChip struct {
+	Kind    string `json:"kind"`
+	Target  string `json:"target"`
+	Service string `json:"service"`
+	Label   string `json:"label"`
+	Message string `json:"message,omitempty"`
+	Hint    string `json:"hint,omitempty"` // e.g. "Greener in 2 hours" for smart start loads
+}
+
+func handleGetSuggestions(w http.ResponseWriter, r *http.Request) {
+	now := time.Now().In(appConfig.Timezone)
+	history := actionLog.Since(now.AddDate(0, 0, -28))
+
+	predicted := []SuggestionChip{}
+	for _, p := range suggestions.Predict(history, now, suggestions.Options{}) {
+		label := actionLabel(p.Kind, p.Target)
+		typical, _ := time.Parse("15:04", p.TypicalTime)
+		predicted = append(predicted, SuggestionChip{
+			Kind:    p.Kind,
+			Target:  p.Target,
+			Service: p.Service,
+			Label:   label,
+			Message: fmt.Sprintf("You usually %s %s around %s", serviceVerb(p.Service), label, typical.Format("3:04 PM")),
+		})
+	}
+
+	recent := []SuggestionChip{}
+	for _, a := range suggestions.Recent(history, 5) {
+		recent = append(recent, SuggestionChip{
+			Kind:    a.Kind,
+			Target:  a.Target,
+			Service: a.Service,
+			Label:   actionLabel(a.Kind, a.Target),
+		})
+	}
+	addGreenerHints(predicted, now)
+	addGreenerHints(recent, now)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"predicted": predicted,
+		"recent":    recent,
+	})
+}
+
+// addGreenerHints notes on chips that switch on a smart start load when the
+// grid will be cleaner later
+func addGreenerHints(chips []SuggestionChip, now time.Time) {
+	if carbonClient == nil || len(appConfig.SmartStartLoads) == 0 {
+		return
+	}
+	var slots []energy.Slot
+	for i, chip := range chips {
+		if chip.Service == "turn_off" {
+			continue
+		}
+		for _, load := range appConfig.SmartStartLoads {
+			if load.Entity != chip.Target {
+				continue
+			}
+			if slots == nil {
+				if slots = carbonForecast(); slots == nil {
+					return
+				}
+			}
+			chips[i].Hint = greenerHint(slots, now, time.Duration(load.Minutes)*time.Minute)
+		}
+	}
+}
+
+// actionLabel resolves a friendly name for an action target, falling back to the ID
+func actionLabel(kind, target string) string {
+	switch kind {
+	case actions.KindEntity:
+		if haClient != nil {
+			if entity, err := haClient.GetState(target); err == nil {
+				if name, ok := entity.Attributes["friendly_name"].(string); ok && name != "" {
+					return name
+				}
+			}
+		}
+	case actions.KindLight:
+		if hueClient != nil {
+			if light, err := hueClient.GetLight(target); err == nil && light.Name != "" {
+				return light.Name
+			}
+		}
+	}
+	return target
+}
+
+// serviceVerb turns a recorded service into words for suggestion messages
+func serviceVerb(service string) string {
+	switch service {
+	case "turn_on":
+		return "turn on"
+	case "turn_off":
+		return "turn off"
+	case "set_cover_position":
+		return "adjust"
+	case "return_to_base":
+		return "dock"
+	case "":
+		return "use"
+	}
+	return strings.ReplaceAll(service, "_", " ")
+}
+
+// Aquarium API handlers
+
+// initAquariums polls tank probes, alerts when a reading leaves its range and
+// registers device schedules with the job scheduler
+func initAquariums() {
+	aquariums = aquarium.NewManager(dataStore.Doc("settings", "aquariums", ""))
+	aquariums.SetReader(readEntityValue)
+	aquariums.OnChange(func() {
+		syncAquariumSchedules()
+		wsHub.Broadcast(websocket.Event{Type: "aquarium_changed", Payload: aquariums.List()})
+	})
+	aquariums.OnAlert(func(tank aquarium.Tank, r aquarium.Reading, previous string) {
+		sensorKey := "aquarium-" + tank.ID + "-" + r.Entity
+		if r.Status == aquarium.StatusOK {
+			notifyCenter.Notify(notify.Notification{
+				Source:   "aquarium",
+				Key:      sensorKey,
+				Severity: notify.SeverityInfo,
+				Title:    tank.Name + " back to normal",
+				Message:  r.Label(),
+			})
+		} else {
+			notifyCenter.Notify(notify.Notification{
+				Source:   "aquarium",
+				Key:      sensorKey,
+				Severity: notify.SeverityWarning,
+				Title:    tank.Name + " needs attention",
+				Message:  r.Label(),
+			})
+		}
+		recordJournal(journal.Entry{Kind: journal.KindEntity, Subject: r.Entity, From: previous, To: r.Status, Source: "aquarium"})
+		wsHub.Broadcast(websocket.Event{Type: "aquarium_changed", Payload: aquariums.List()})
+	})
+	syncAquariumSchedules()
+
+	// Catch up on schedules missed while the server was down
+	if haClient != nil {
+		go func() {
+			for entity, on := range aquariums.Desired(time.Now().In(appConfig.Timezone)) {
+				if err := setEntityPower(entity, on); err != nil {
+					log.Printf("Warning: Failed to restore aquarium device %s: %v", entity, err)
+				}
+			}
+		}()
+	}
+	go aquariums.Run(time.Minute)
+}
+
+// syncAquariumSchedules replaces the scheduled jobs of all tank devices
+func syncAquariumSchedules() {
+	jobScheduler.RemovePrefix("aquarium:")
+	for _, sw := range aquariums.Switches() {
+		err := jobScheduler.Set(sw.ID, sw.At, sw.Name, nil, func() {
+			if err := setEntityPower(sw.Entity, sw.On); err != nil {
+				log.Printf("Error running schedule %s: %v", sw.Name, err)
+				return
+			}
+			recordJournal(journal.Entry{Kind: journal.KindAction, Subject: sw.Entity, To: onOff(sw.On), Source: "schedule"})
+		})
+		if err != nil {
+			log.Printf("Warning: Failed to schedule %s: %v", sw.Name, err)
+		}
+	}
+}
+
+// readEntityValue reads the numeric state of an HA sensor or MQTT virtual sensor
+func readEntityValue(entityID string) (float64, error) {
+	if strings.HasPrefix(entityID, mqtt.SensorEntityPrefix) {
+		if mqttSensors == nil {
+			return 0, fmt.Errorf("MQTT sensors not configured")
+		}
+		s, ok := mqttSensors.Get(entityID)
+		if !ok || s.Value == nil {
+			return 0, fmt.Errorf("no reading for %s", entityID)
+		}
+		return *s.Value, nil
+	}
+
+	if haClient == nil {
+		return 0, fmt.Errorf("HA not configured")
+	}
+	entity, err := haClient.GetState(entityID)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(entity.State, 64)
+}
+
+// setEntityPower turns an HA switch, light or fan on or off
+func setEntityPower(entityID string, on bool) error {
+	if haClient == nil {
+		return fmt.Errorf("HA not configured")
+	}
+	domain, _, ok := strings.Cut(entityID, ".")
+	if !ok {
+		return fmt.Errorf("invalid entity ID: %s", entityID)
+	}
+	service := "turn_off"
+	if on {
+		service = "turn_on"
+	}
+	return haClient.CallService(domain, service, entityID)
+}
+
+func handleGetAquariums(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(aquariums.List())
+}
+
+func handleGetAquarium(w http.ResponseWriter, r *http.Request) {
+	tank, ok := aquariums.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "Tank not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tank)
+}
+
+func handleCreateAquarium(w http.ResponseWriter, r *http.Request) {
+	var req aquarium.Tank
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tank, err := aquariums.Create(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(tank)
+}
+
+func handleUpdateAquarium(w http.ResponseWriter, r *http.Request) {
+	var req aquarium.Tank
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if _, ok := aquariums.Get(id); !ok {
+		http.Error(w, "Tank not found", http.StatusNotFound)
+		return
+	}
+	tank, err := aquariums.Update(id, req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tank)
+}
+
+func handleDeleteAquarium(w http.ResponseWriter, r *http.Request) {
+	if err := aquariums.Delete(chi.URLParam(r, "id")); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetAquariumSchedule lists upcoming scheduled device changes
+func handleGetAquariumSchedule(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jobScheduler.List("aquarium:"))
+}
+
+// SetAquariumDeviceRequest is the body for POST /api/aquarium/{id}/devices/{device}
+type SetAquariumDeviceRequest struct {
+	On bool `json:"on"`
+}
+
+// handleSetAquariumDevice switches a tank device by hand; its schedule resumes at the next change
+func handleSetAquariumDevice(w http.ResponseWriter, r *http.Request) {
+	device, ok := aquariums.Device(chi.URLParam(r, "id"), chi.URLParam(r, "device"))
+	if !ok {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	var req SetAquariumDeviceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := setEntityPower(device.Entity, req.On); err != nil {
+		log.Printf("Error switching aquarium device %s: %v", device.Entity, err)
+		http.Error(w, "Failed to switch device: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	service := "turn_off"
+	if req.On {
+		service = "turn_on"
+	}
+	recordAction(r, actions.KindEntity, device.Entity, service)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// Pool API handlers
+
+// syncPoolSchedules replaces the scheduled jobs of all pool pumps
+func syncPoolSchedules() {
+	jobScheduler.RemovePrefix("pool:")
+	for _, run := range poolManager.Runs() {
+		err := jobScheduler.Set(run.ID, run.At, run.Name, nil, func() {
+			if err := poolManager.SetPump(run.Body, run.Entity, run.On); err != nil {
+				log.Printf("Error running schedule %s: %v", run.Name, err)
+				return
+			}
+			recordJournal(journal.Entry{Kind: journal.KindAction, Subject: run.Entity, To: onOff(run.On), Source: "schedule"})
+		})
+		if err != nil {
+			log.Printf("Warning: Failed to schedule %s: %v", run.Name, err)
+		}
+	}
+}
+
+func handleGetPools(w http.ResponseWriter, r *http.Request) {
+	if poolManager == nil {
+		http.Error(w, "Pool not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	result := []*pool.Status{}
+	for _, b := range poolManager.Bodies() {
+		s, err := poolManager.Status(b.ID)
+		if err != nil {
+			log.Printf("Error getting %s status: %v", b.Name, err)
+			continue
+		}
+		result = append(result, s)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func handleGetPool(w http.ResponseWriter, r *http.Request) {
+	if poolManager == nil {
+		http.Error(w, "Pool not configured", http.StatusServiceUnavailable)
+		return
+	}
+	writePoolStatus(w, chi.URLParam(r, "id"))
+}
+
+// writePoolStatus responds with a body's current status
+func writePoolStatus(w http.ResponseWriter, id string) {
+	if _, ok := poolManager.Body(id); !ok {
+		http.Error(w, "Pool not found", http.StatusNotFound)
+		return
+	}
+	s, err := poolManager.Status(id)
+	if err != nil {
+		log.Printf("Error getting pool status: %v", err)
+		http.Error(w, "Failed to get pool status: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
+
+// PoolSetpointRequest is the body for the heat and setpoint endpoints
+type PoolSetpointRequest struct {
+	Setpoint float64 `json:"setpoint"` // Optional for heat, which defaults to the last heat setpoint
+}
+
+// handleHeatPool runs the "heat the hot tub" scene and returns the status with its estimate
+func handleHeatPool(w http.ResponseWriter, r *http.Request) {
+	if poolManager == nil {
+		http.Error(w, "Pool not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req PoolSetpointRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	id := chi.URLParam(r, "id")
+	if _, ok := poolManager.Body(id); !ok {
+		http.Error(w, "Pool not found", http.StatusNotFound)
+		return
+	}
+	if err := poolManager.Heat(id, req.Setpoint); err != nil {
+		log.Printf("Error heating %s: %v", id, err)
+		http.Error(w, "Failed to heat: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordAction(r, actions.KindEntity, "pool."+id, "heat")
+
+	writePoolStatus(w, id)
+}
+
+func handleSetPoolSetpoint(w http.ResponseWriter, r *http.Request) {
+	if poolManager == nil {
+		http.Error(w, "Pool not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req PoolSetpointRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if _, ok := poolManager.Body(id); !ok {
+		http.Error(w, "Pool not found", http.StatusNotFound)
+		return
+	}
+	if err := poolManager.SetSetpoint(id, req.Setpoint); err != nil {
+		http.Error(w, "Failed to set setpoint: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	recordAction(r, actions.KindEntity, "pool."+id, "set_temperature")
+
+	writePoolStatus(w, id)
+}
+
+func handlePoolHeaterOff(w http.ResponseWriter, r *http.Request) {
+	if poolManager == nil {
+		http.Error(w, "Pool not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if _, ok := poolManager.Body(id); !ok {
+		http.Error(w, "Pool not found", http.StatusNotFound)
+		return
+	}
+	if err := poolManager.TurnOffHeater(id); err != nil {
+		log.Printf("Error turning off %s heater: %v", id, err)
+		http.Error(w, "Failed to turn off heater: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordAction(r, actions.KindEntity, "pool."+id, "turn_off")
+
+	writePoolStatus(w, id)
+}
+
+// SetPoolPumpRequest is the body for POST /api/pool/{id}/pumps/{entity}
+type SetPoolPumpRequest struct {
+	On bool `json:"on"`
+}
+
+func handleSetPoolPump(w http.ResponseWriter, r *http.Request) {
+	if poolManager == nil {
+		http.Error(w, "Pool not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req SetPoolPumpRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id, entity := chi.URLParam(r, "id"), chi.URLParam(r, "entity")
+	if err := poolManager.SetPump(id, entity, req.On); err != nil {
+		log.Printf("Error switching pool pump %s: %v", entity, err)
+		http.Error(w, "Failed to switch pump: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	service := "turn_off"
+	if req.On {
+		service = "turn_on"
+	}
+	recordAction(r, actions.KindEntity, entity, service)
+
+	writePoolStatus(w, id)
+}
+
+// SetPoolPumpScheduleRequest is the body for PUT /api/pool/{id}/pumps/{entity}/schedule
+type SetPoolPumpScheduleRequest struct {
+	Windows []pool.Window `json:"windows"` // Empty clears the schedule
+}
+
+func handleSetPoolPumpSchedule(w http.ResponseWriter, r *http.Request) {
+	if poolManager == nil {
+		http.Error(w, "Pool not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req SetPoolPumpScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id, entity := chi.URLParam(r, "id"), chi.URLParam(r, "entity")
+	if err := poolManager.SetSchedule(id, entity, req.Windows); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	syncPoolSchedules()
+
+	writePoolStatus(w, id)
+}
+
+// syncHotWaterSchedule registers the hot water schedule windows with the scheduler
+func syncHotWaterSchedule() {
+	jobScheduler.RemovePrefix("hotwater:")
+	for _, run := range hotWater.Runs() {
+		err := jobScheduler.Set(run.ID, run.At, run.Name, run.Days, func() {
+			if err := hotWater.Scheduled(run.On); err != nil {
+				log.Printf("Error running schedule %s: %v", run.Name, err)
+				return
+			}
+			recordJournal(journal.Entry{Kind: journal.KindAction, Subject: appConfig.HotWaterEntity, To: onOff(run.On), Source: "schedule"})
+		})
+		if err != nil {
+			log.Printf("Warning: Failed to schedule %s: %v", run.Name, err)
+		}
+	}
+}
+
+func handleGetHotWater(w http.ResponseWriter, r *http.Request) {
+	if hotWater == nil {
+		http.Error(w, "Hot water not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hotWater.Status())
+}
+
+// HotWaterBoostRequest is the body for POST /api/hotwater/boost
+type HotWaterBoostRequest struct {
+	Minutes int `json:"minutes"` // Default 30
+}
+
+func handleHotWaterBoost(w http.ResponseWriter, r *http.Request) {
+	if hotWater == nil {
+		http.Error(w, "Hot water not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req HotWaterBoostRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	status, err := hotWater.Boost(req.Minutes)
+	if err != nil {
+		log.Printf("Error boosting hot water: %v", err)
+		http.Error(w, "Failed to boost hot water: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func handleCancelHotWaterBoost(w http.ResponseWriter, r *http.Request) {
+	if hotWater == nil {
+		http.Error(w, "Hot water not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	status, err := hotWater.CancelBoost()
+	if err != nil {
+		log.Printf("Error cancelling hot water boost: %v", err)
+		http.Error(w, "Failed to cancel boost: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func handleSetHotWaterSchedule(w http.ResponseWriter, r *http.Request) {
+	if hotWater == nil {
+		http.Error(w, "Hot water not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var windows []hotwater.Window
+	if err := json.NewDecoder(r.Body).Decode(&windows); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := hotWater.SetSchedule(windows); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	syncHotWaterSchedule()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(hotWater.Status())
+}
+
+// Smart start
+
+// parseSmartStartLoads parses SMART_START_LOADS, e.g.
+// "EV=switch.ev_charger:240,Dishwasher=switch.dishwasher:150"
+func parseSmartStartLoads(s string) []energy.Load {
+	loads, err := energy.ParseLoads(s)
+	if err != nil {
+		log.Printf("Warning: Ignoring SMART_START_LOADS: %v", err)
+		return nil
+	}
+	return loads
+}
+
+// parseEnergyTariff parses ENERGY_TARIFF, e.g. "00:00-07:00=0.08,07:00-00:00=0.25"
+func parseEnergyTariff(s string) energy.Tariff {
+	tariff, err := energy.ParseTariff(s)
+	if err != nil {
+		log.Printf("Warning: Ignoring ENERGY_TARIFF: %v", err)
+		return nil
+	}
+	return tariff
+}
+
+// energyPriceSource names where the price forecast comes from
+func energyPriceSource() string {
+	switch {
+	case appConfig.EnergyPriceSensor != "" && haClient != nil:
+		return appConfig.EnergyPriceSensor
+	case len(appConfig.EnergyTariff) > 0:
+		return "tariff"
+	default:
+		return "none"
+	}
+}
+
+// energyForecast returns upcoming electricity prices from the price sensor,
+// or the fixed tariff when there's no sensor
+func energyForecast() ([]energy.Slot, error) {
+	if appConfig.EnergyPriceSensor != "" && haClient != nil {
+		entity, err := haClient.GetState(appConfig.EnergyPriceSensor)
+		if err != nil {
+			return nil, err
+		}
+		return energy.ParseHAForecast(entity.Attributes), nil
+	}
+	if len(appConfig.EnergyTariff) > 0 {
+		return appConfig.EnergyTariff.Forecast(time.Now(), 48*time.Hour, appConfig.Timezone), nil
+	}
+	return nil, nil
+}
+
+// SmartStartStatus is the smart start queue as shown to clients
+type SmartStartStatus struct {
+	Loads       []energy.Load `json:"loads"`
+	Jobs        []energy.Job  `json:"jobs"`
+	PriceSource string        `json:"priceSource"`
+}
+
+// smartStartStatus returns the queue, with a hint on waiting jobs that could
+// start later on a cleaner grid and still meet their deadline
+func smartStartStatus() SmartStartStatus {
+	jobs := smartStart.Jobs()
+	if slots := carbonForecast(); slots != nil {
+		for i, j := range jobs {
+			if j.Status != energy.StatusScheduled || j.Start == nil {
+				continue
+			}
+			d := time.Duration(j.Minutes) * time.Minute
+			if hint := energy.Greener(slots, *j.Start, j.Deadline.Add(-d), d); hint != nil {
+				hint.Message = fmt.Sprintf("Greener at %s (%d%% less CO₂)", hint.Start.In(appConfig.Timezone).Format("3:04 PM"), int(hint.Saving*100))
+				jobs[i].Greener = hint
+			}
+		}
+	}
+	return SmartStartStatus{Loads: smartStart.Loads(), Jobs: jobs, PriceSource: energyPriceSource()}
+}
+
+func handleGetSmartStart(w http.ResponseWriter, r *http.Request) {
+	if smartStart == nil {
+		http.Error(w, "Smart start not configured", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(smartStartStatus())
+}
+
+func handleQueueSmartStart(w http.ResponseWriter, r *http.Request) {
+	if smartStart == nil {
+		http.Error(w, "Smart start not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req energy.JobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	job, err := smartStart.Add(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// Carbon intensity
+
+// carbonHorizon is how far ahead "greener later" hints look
+const carbonHorizon = 12 * time.Hour
+
+// carbonForecast returns the carbon intensity forecast, or nil when it's not
+// configured or unavailable
+func carbonForecast() []energy.Slot {
+	if carbonClient == nil {
+		return nil
+	}
+	slots, err := carbonClient.Forecast()
+	if err != nil {
+		log.Printf("Warning: Carbon intensity forecast unavailable: %v", err)
+		return nil
+	}
+	return slots
+}
+
+// greenerHint suggests starting a run of length d later on a cleaner grid,
+// e.g. "Greener in 2 hours (30% less CO₂)". Empty when now is as good as it gets.
+func greenerHint(slots []energy.Slot, now time.Time, d time.Duration) string {
+	hint := energy.Greener(slots, now, now.Add(carbonHorizon), d)
+	if hint == nil {
+		return ""
+	}
+	wait := hint.Start.Sub(now)
+	when := fmt.Sprintf("in %d min", int(wait.Minutes()))
+	if hours := int(wait.Hours() + 0.5); wait >= time.Hour {
+		when = fmt.Sprintf("in %d hour", hours)
+		if hours != 1 {
+			when += "s"
+		}
+	}
+	return fmt.Sprintf("Greener %s (%d%% less CO₂)", when, int(hint.Saving*100))
+}
+
+// CarbonStatus is the grid's carbon intensity now and ahead, in gCO2/kWh
+type CarbonStatus struct {
+	Provider string        `json:"provider"`
+	Region   string        `json:"region,omitempty"`
+	Current  *energy.Slot  `json:"current,omitempty"`
+	Greenest *energy.Slot  `json:"greenest,omitempty"` // Cleanest slot in the next 24 hours
+	Hint     string        `json:"hint,omitempty"`     // For an hour's run starting now
+	Forecast []energy.Slot `json:"forecast"`           // Next 24 hours
+}
+
+func handleGetCarbon(w http.ResponseWriter, r *http.Request) {
+	if carbonClient == nil {
+		http.Error(w, "Carbon intensity not configured", http.StatusServiceUnavailable)
+		return
+	}
+	slots, err := carbonClient.Forecast()
+	if err != nil {
+		log.Printf("Error fetching carbon intensity: %v", err)
+		http.Error(w, "Failed to fetch carbon intensity: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	now := time.Now()
+	status := CarbonStatus{Provider: carbonClient.Provider(), Region: carbonClient.Region(), Forecast: []energy.Slot{}}
+	if current, ok := energy.At(slots, now); ok {
+		status.Current = &current
+	}
+	for _, s := range slots {
+		if !s.End.After(now) || s.Start.After(now.Add(24*time.Hour)) {
+			continue
+		}
+		status.Forecast = append(status.Forecast, s)
+		if status.Greenest == nil || s.Value < status.Greenest.Value {
+			greenest := s
+			status.Greenest = &greenest
+		}
+	}
+	status.Hint = greenerHint(slots, now, time.Hour)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func handleCancelSmartStart(w http.ResponseWriter, r *http.Request) {
+	if smartStart == nil {
+		http.Error(w, "Smart start not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	job, err := smartStart.Cancel(chi.URLParam(r, "id"))
+	if err != nil {
+		log.Printf("Error cancelling smart start job: %v", err)
+		http.Error(w, "Failed to cancel job: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(job)
+}
+
+// solarExport reads the grid export in watts, negative while importing
+func solarExport() (float64, error) {
+	entity, err := haClient.GetState(appConfig.SolarExportSensor)
+	if err != nil {
+		return 0, err
+	}
+	value, err := strconv.ParseFloat(entity.State, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s is %q", appConfig.SolarExportSensor, entity.State)
+	}
+	if unit, _ := entity.Attributes["unit_of_measurement"].(string); strings.EqualFold(unit, "kW") {
+		value *= 1000
+	}
+	if appConfig.SolarExportInvert {
+		value = -value
+	}
+	return value, nil
+}
+
+// SolarSurplusStatus is the response of GET /api/energy/surplus
+type SolarSurplusStatus struct {
+	Sensor   string                 `json:"sensor"`
+	Rules    []energy.SurplusRule   `json:"rules"`
+	Statuses []energy.SurplusStatus `json:"statuses"`
+}
+
+func solarSurplusStatus() SolarSurplusStatus {
+	return SolarSurplusStatus{
+		Sensor:   appConfig.SolarExportSensor,
+		Rules:    solarSurplus.Rules(),
+		Statuses: solarSurplus.Statuses(),
+	}
+}
+
+func handleGetSolarSurplus(w http.ResponseWriter, r *http.Request) {
+	if solarSurplus == nil {
+		http.Error(w, "Solar surplus not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(solarSurplusStatus())
+}
+
+func handleUpdateSolarSurplusRules(w http.ResponseWriter, r *http.Request) {
+	if solarSurplus == nil {
+		http.Error(w, "Solar surplus not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var rules []energy.SurplusRule
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := solarSurplus.Update(rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	go solarSurplus.Tick(time.Now())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(solarSurplus.Rules())
+}
+
+// Quiet hours
+
+func handleGetQuietHours(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quietHours.Status())
+}
+
+func handleUpdateQuietHours(w http.ResponseWriter, r *http.Request) {
+	var settings quiethours.Settings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := quietHours.Update(settings); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(quietHours.Status())
+}
+
+// clampVolume caps a requested volume percentage during quiet hours. When it's
+// lowered, the response headers tell the requester the volume actually set and
+// when the cap lifts.
+func clampVolume(w http.ResponseWriter, player string, volume int) int {
+	if quietHours == nil {
+		return volume
+	}
+	clamped, limited := quietHours.Clamp(volume)
+	if limited != nil {
+		log.Printf("Quiet hours: %s volume %d%% capped to %d%%", player, volume, clamped)
+		w.Header().Set("X-Quiet-Hours-Volume", strconv.Itoa(clamped))
+		w.Header().Set("X-Quiet-Hours-Until", limited.Until.Format(time.RFC3339))
+	}
+	return clamped
+}
+
+// Routines
+
+// defaultEveningRoutine dims the house at 9, quiets it at 9:30 and closes it up at 10.
+// Empty targets mean every light, media player or lock in HA_ENTITIES (and Hue rooms).
+var defaultEveningRoutine = routines.Routine{
+	Stages: []routines.Stage{
+		{ID: "dim", Name: "Dim the lights", At: "21:00", Enabled: true, Actions: []routines.Action{
+			{Type: routines.ActionDim, Brightness: 50},
+		}},
+		{ID: "quiet", Name: "Pause media and do not disturb", At: "21:30", Enabled: true, Actions: []routines.Action{
+			{Type: routines.ActionPauseMedia},
+			{Type: routines.ActionDoNotDisturb, Until: "07:00"},
+		}},
+		{ID: "bedtime", Name: "Screensaver and lock up", At: "22:00", Enabled: true, Actions: []routines.Action{
+			{Type: routines.ActionScreensaver},
+			{Type: routines.ActionLock},
+		}},
+	},
+}
+
+// addRoutine registers a routine and keeps its stages scheduled
+func addRoutine(runner *routines.Runner) {
+	runner.SetExecutor(runRoutineAction)
+	runner.SetModeSource(houseMode.Mode)
+	runner.SetDaySource(dayTags)
+	runner.OnChange(func() { syncRoutineSchedule(runner) })
+	routineRunners[runner.Name()] = runner
+	syncRoutineSchedule(runner)
+}
+
+// syncRoutineSchedule replaces a routine's scheduled jobs
+func syncRoutineSchedule(runner *routines.Runner) {
+	jobScheduler.RemovePrefix("routine:" + runner.Name() + ":")
+	for _, job := range runner.Jobs() {
+		stage := job.Stage
+		if err := jobScheduler.Set(job.ID, job.At, job.Name, job.Days, func() { runner.RunScheduled(stage) }); err != nil {
+			log.Printf("Warning: Failed to schedule %s: %v", job.Name, err)
+		}
+	}
+}
+
+// runRoutineAction performs one routine action
+func runRoutineAction(a routines.Action) error {
+	switch a.Type {
+	case routines.ActionDim:
+		return dimLights(a.Targets, a.Brightness)
+	case routines.ActionPauseMedia:
+		return pauseMedia(a.Targets)
+	case routines.ActionDoNotDisturb:
+		if notifyCenter != nil {
+			notifyCenter.SetDoNotDisturb(nextTimeOfDay(a.Until))
+		}
+		for _, id := range a.Targets {
+			if err := setEntityPower(id, true); err != nil {
+				return err
+			}
+		}
+		return nil
+	case routines.ActionScreensaver:
+		wsHub.Broadcast(websocket.Event{Type: "screensaver"})
+		return nil
+	case routines.ActionLock:
+		targets := a.Targets
+		if len(targets) == 0 {
+			targets = entitiesWithPrefix("lock.")
+		}
+		for _, id := range targets {
+			if haClient == nil {
+				return fmt.Errorf("HA not configured")
+			}
+			if err := haClient.CallService("lock", "lock", id); err != nil {
+				return err
+			}
+			recordJournal(journal.Entry{Kind: journal.KindAction, Subject: id, To: "locked", Source: "routine"})
+		}
+		return nil
+	case routines.ActionTurnOn, routines.ActionTurnOff:
+		for _, id := range a.Targets {
+			if err := setEntityPower(id, a.Type == routines.ActionTurnOn); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown action type %q", a.Type)
+}
+
+// entitiesWithPrefix returns the configured HA entities of a domain, e.g. "lock."
+func entitiesWithPrefix(prefix string) []string {
+	var result []string
+	for _, id := range configuredEntities() {
+		if strings.HasPrefix(id, prefix) {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// nextTimeOfDay returns the next occurrence of an HH:MM local time
+func nextTimeOfDay(hhmm string) time.Time {
+	at, err := time.Parse("15:04", hhmm)
+	if err != nil {
+		return time.Time{}
+	}
+	now := time.Now().In(appConfig.Timezone)
+	t := time.Date(now.Year(), now.Month(), now.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+	if !t.After(now) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t
+}
+
+// dimLights lowers lights that are on and brighter than brightness (percent).
+// Targets are light.* entities or hue:<group ID>; empty means every Hue room and
+// configured HA light.
+func dimLights(targets []string, brightness int) error {
+	if len(targets) == 0 {
+		if hueClient != nil {
+			rooms, err := hueRooms()
+			if err != nil {
+				return err
+			}
+			for _, rm := range rooms {
+				if rm.Type == "Room" {
+					targets = append(targets, "hue:"+rm.ID)
+				}
+			}
+		}
+		targets = append(targets, entitiesWithPrefix("light.")...)
+	}
+
+	bri := (brightness*254 + 50) / 100
+	for _, target := range targets {
+		if groupID, ok := strings.CutPrefix(target, "hue:"); ok {
+			if hueClient == nil {
+				return fmt.Errorf("Hue bridge not configured")
+			}
+			rooms, err := hueRooms()
+			if err != nil {
+				return err
+			}
+			for _, rm := range rooms {
+				if rm.ID != groupID {
+					continue
+				}
+				for _, light := range rm.Lights {
+					if !light.State.On || light.State.Brightness <= bri {
+						continue
+					}
+					if err := hueClient.SetLightState(light.ID, map[string]interface{}{"bri": bri, "transitiontime": 100}); err != nil {
+						return err
+					}
+				}
+			}
+			continue
+		}
+
+		if haClient == nil {
+			return fmt.Errorf("HA not configured")
+		}
+		entity, err := haClient.GetState(target)
+		if err != nil {
+			return err
+		}
+		if current, _ := entity.Attributes["brightness"].(float64); entity.State != "on" || int(current) <= brightness*255/100 {
+			continue
+		}
+		if _, err := haClient.CallServiceData("light", "turn_on", map[string]interface{}{
+			"entity_id":      target,
+			"brightness_pct": brightness,
+			"transition":     10,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pauseMedia pauses media players that are playing. Empty targets means every
+// configured media player, plus Spotify.
+func pauseMedia(targets []string) error {
+	if len(targets) == 0 {
+		targets = entitiesWithPrefix("media_player.")
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		for _, client := range spotifyClients {
+			if state, err := client.GetPlaybackState(ctx); err == nil && state != nil && state.IsPlaying {
+				if err := client.Pause(ctx, ""); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	for _, id := range targets {
+		if haClient == nil {
+			return fmt.Errorf("HA not configured")
+		}
+		entity, err := haClient.GetState(id)
+		if err != nil {
+			return err
+		}
+		if entity.State != "playing" {
+			continue
+		}
+		if err := haClient.CallService("media_player", "media_pause", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// morningEvents supplies calendar events for the morning routine when Google
+// Calendar is authorized
+func morningEvents(ctx context.Context, start, end time.Time) ([]morning.Event, error) {
+	if calClient == nil || !calClient.IsAuthorized() {
+		return nil, nil
+	}
+	events, err := calClient.GetEventsInRange(ctx, start, end)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]morning.Event, 0, len(events))
+	for _, e := range events {
+		result = append(result, morning.Event{Title: e.Title, CalendarID: e.CalendarID, Start: e.Start, AllDay: e.AllDay})
+	}
+	return result, nil
+}
+
+// runMorningStep wakes a member, sets the bathroom scene or announces a departure countdown
+func runMorningStep(step morning.Step) error {
+	recordJournal(journal.Entry{Kind: journal.KindAction, Subject: "morning." + step.Member, To: step.Kind, Source: "routine"})
+
+	switch step.Kind {
+	case morning.StepWake:
+		announceMessage(step.Message, "morning")
+		if step.Target != "" {
+			return setEntityPower(step.Target, true)
+		}
+	case morning.StepLights:
+		return activateSceneTarget(step.Target)
+	case morning.StepCountdown:
+		announceMessage(step.Message, "morning")
+	}
+	return nil
+}
+
+// announceMessage queues a message for every tablet
+func announceMessage(message, source string) {
+	if _, err := announcements.Announce(announce.Request{Message: message, Source: source}); err != nil {
+		log.Printf("Warning: Failed to announce %q: %v", message, err)
+	}
+}
+
+// sendAnnouncement shows an announcement on its kiosks (or every tablet),
+// waking the screen. Kiosks confirm at /api/announcements/{id}/played.
+func sendAnnouncement(a announce.Announcement) []announce.Delivery {
+	go wakeTablet()
+	event := websocket.Event{Type: "announcement", Payload: map[string]interface{}{
+		"id":         a.ID,
+		"message":    a.Message,
+		"chime":      a.Chime,
+		"priority":   a.Priority,
+		"durationMs": a.Duration * 1000,
+	}}
+
+	deliveries := []announce.Delivery{}
+	if len(a.Kiosks) == 0 {
+		wsHub.Broadcast(event)
+		for _, kiosk := range wsHub.Kiosks() {
+			deliveries = append(deliveries, announce.Delivery{Kiosk: kiosk, Connected: true})
+		}
+		return deliveries
+	}
+	for _, kiosk := range a.Kiosks {
+		connected := wsHub.SendTo(kiosk, event) > 0
+		if !connected {
+			log.Printf("Warning: Announcement: kiosk %q isn't connected", kiosk)
+		}
+		deliveries = append(deliveries, announce.Delivery{Kiosk: kiosk, Connected: connected})
+	}
+	return deliveries
+}
+
+func handleGetAnnouncements(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"announcements": announcements.Recent(),
+		"rooms":         announcements.Rooms(),
+		"kiosks":        wsHub.Kiosks(),
+		"chimes":        announce.Chimes,
+	})
+}
+
+func handleAnnounce(w http.ResponseWriter, r *http.Request) {
+	var req announce.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Source == "" {
+		req.Source = requestDeviceID(r)
+	}
+	a, err := announcements.Announce(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(a)
+}
+
+// AnnouncementPlayedRequest is the body for POST /api/announcements/{id}/played
+type AnnouncementPlayedRequest struct {
+	Kiosk string `json:"kiosk"`
+}
+
+func handleAnnouncementPlayed(w http.ResponseWriter, r *http.Request) {
+	var req AnnouncementPlayedRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Kiosk == "" {
+		http.Error(w, "kiosk is required", http.StatusBadRequest)
+		return
+	}
+	if err := announcements.Played(chi.URLParam(r, "id"), req.Kiosk); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// activateSceneTarget activates a Hue scene (hue:<scene ID>) or an HA scene.* entity
+func activateSceneTarget(target string) error {
+	if sceneID, ok := strings.CutPrefix(target, "hue:"); ok {
+		if hueClient == nil {
+			return fmt.Errorf("Hue bridge not configured")
+		}
+		return hueClient.ActivateScene(sceneID)
+	}
+	if haClient == nil {
+		return fmt.Errorf("HA not configured")
+	}
+	return haClient.CallService("scene", "turn_on", target)
+}
+
+// syncAlarmSchedules registers each enabled alarm with the scheduler
+func syncAlarmSchedules() {
+	jobScheduler.RemovePrefix("alarm:")
+	for _, job := range alarmManager.Jobs() {
+		run := func() {
+			if !alarmManager.Runs(job, time.Now()) {
+				return
+			}
+			if job.Sunrise {
+				runAlarmSunrise(job.Alarm)
+			} else {
+				runAlarm(job.Alarm)
+			}
+		}
+		if err := jobScheduler.Set(job.ID, job.At, job.Name, job.Days, run); err != nil {
+			log.Printf("Warning: Failed to schedule %s: %v", job.Name, err)
+		}
+	}
+}
+
+// alarmRampStep is how often an alarm raises the volume
+const alarmRampStep = 15 * time.Second
+
+// runAlarmSunrise fades an alarm's Hue scene in so it's fully on when the music starts
+func runAlarmSunrise(alarm alarms.Alarm) {
+	if hueClient == nil {
+		log.Printf("Error running alarm %s sunrise: Hue bridge not configured", alarm.Name)
+		return
+	}
+	duration := alarm.SunriseMinutes * 60 * 1000
+	brightness := 100.0
+	opts := hue.SceneOptions{Duration: &duration, Brightness: &brightness}
+	if err := hueClient.ActivateSceneWithOptions(strings.TrimPrefix(alarm.Scene, "hue:"), opts); err != nil {
+		log.Printf("Error running alarm %s sunrise: %v", alarm.Name, err)
+	}
+}
+
+// runAlarm starts an alarm's music at its start volume and ramps it up
+func runAlarm(alarm alarms.Alarm) {
+	recordJournal(journal.Entry{Kind: journal.KindAction, Subject: "alarm." + alarm.ID, To: alarm.Name, Source: "schedule"})
+
+	if alarm.Scene != "" && alarm.SunriseMinutes == 0 {
+		if err := activateSceneTarget(alarm.Scene); err != nil {
+			log.Printf("Error activating alarm %s scene: %v", alarm.Name, err)
+		}
+	}
+
+	account := alarm.Account
+	if account == "" {
+		account = defaultSpotifyAccount
+	}
+	client := spotifyClients[account]
+	if client == nil || !client.IsAuthenticated() {
+		log.Printf("Error running alarm %s: Spotify account %s not authenticated", alarm.Name, account)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	deviceID, err := alarmDevice(ctx, client, alarm.Device)
+	if err != nil {
+		log.Printf("Error running alarm %s: %v", alarm.Name, err)
+		return
+	}
+	// Alarms are exempt from the quiet hours cap: they're meant to wake someone
+	if err := client.SetVolume(ctx, deviceID, alarm.StartVolume); err != nil {
+		// Some devices only take a volume once they're playing
+		log.Printf("Warning: Failed to set alarm %s volume: %v", alarm.Name, err)
+	}
+	if err := client.SetShuffle(ctx, deviceID, alarm.Shuffle); err != nil {
+		log.Printf("Warning: Failed to set alarm %s shuffle: %v", alarm.Name, err)
+	}
+	if err := client.PlayURI(ctx, deviceID, alarm.URI, 0); err != nil {
+		log.Printf("Error starting alarm %s: %v", alarm.Name, err)
+		return
+	}
+	if poller := spotifyPollers[account]; poller != nil {
+		poller.Poke()
+	}
+	log.Printf("Alarm %s started on %s", alarm.Name, alarm.Device)
+
+	if alarm.EndVolume != alarm.StartVolume {
+		go rampAlarmVolume(client, deviceID, alarm)
+	}
+}
+
+// alarmDevice finds a Spotify device by ID or name
+func alarmDevice(ctx context.Context, client *spotify.Client, device string) (string, error) {
+	devices, err := client.GetDevices(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to list devices: %w", err)
+	}
+	for _, d := range devices {
+		if d.ID == device || strings.EqualFold(d.Name, device) || strings.EqualFold(d.OriginalName, device) {
+			return d.ID, nil
+		}
+	}
+	return "", fmt.Errorf("device %s is not available", device)
+}
+
+// rampAlarmVolume raises the volume along the alarm's ramp. It stops early once
+// playback stops, moves to another device or someone changes the volume.
+// Like the start volume, the ramp ignores quiet hours.
+func rampAlarmVolume(client *spotify.Client, deviceID string, alarm alarms.Alarm) {
+	started := time.Now()
+	last := alarm.StartVolume
+	ticker := time.NewTicker(alarmRampStep)
+	defer ticker.Stop()
+	for range ticker.C {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if state, err := client.GetPlaybackState(ctx); err == nil {
+			if state == nil || !state.IsPlaying || state.Device == nil || state.Device.ID != deviceID {
+				cancel()
+				return
+			}
+			if diff := state.Device.VolumePercent - last; diff > 2 || diff < -2 {
+				cancel()
+				return
+			}
+		}
+
+		volume := alarm.VolumeAt(time.Since(started))
+		if volume != last {
+			if err := client.SetVolume(ctx, deviceID, volume); err != nil {
+				log.Printf("Warning: Failed to raise alarm %s volume: %v", alarm.Name, err)
+			} else {
+				last = volume
+			}
+		}
+		cancel()
+		if volume == alarm.EndVolume {
+			return
+		}
+	}
+}
+
+func handleGetAlarms(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"alarms": alarmManager.List(),
+		"next":   jobScheduler.List("alarm:"),
+	})
+}
+
+func handleCreateAlarm(w http.ResponseWriter, r *http.Request) {
+	var req alarms.Alarm
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	alarm, err := alarmManager.Create(req)
+	if err != nil {
+		log.Printf("Error creating alarm: %v", err)
+		http.Error(w, "Failed to create alarm: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alarm)
+}
+
+func handleUpdateAlarm(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var req alarms.Alarm
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	alarm, err := alarmManager.Update(id, req)
+	if err != nil {
+		log.Printf("Error updating alarm: %v", err)
+		http.Error(w, "Failed to update alarm: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(alarm)
+}
+
+func handleDeleteAlarm(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := alarmManager.Delete(id); err != nil {
+		log.Printf("Error deleting alarm: %v", err)
+		http.Error(w, "Failed to delete alarm: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetMorningRoutine(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"settings": morningRoutine.Settings(),
+		"today":    morningRoutine.Plan(),
+	})
+}
+
+func handleUpdateMorningRoutine(w http.ResponseWriter, r *http.Request) {
+	var settings morning.Settings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := morningRoutine.Update(r.Context(), settings); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"settings": morningRoutine.Settings(),
+		"today":    morningRoutine.Plan(),
+	})
+}
+
+// getRoutine returns the routine named in the URL, writing a 404 if there isn't one
+func getRoutine(w http.ResponseWriter, r *http.Request) (*routines.Runner, bool) {
+	runner, ok := routineRunners[chi.URLParam(r, "name")]
+	if !ok {
+		http.Error(w, "Routine not found", http.StatusNotFound)
+	}
+	return runner, ok
+}
+
+func handleGetRoutine(w http.ResponseWriter, r *http.Request) {
+	runner, ok := getRoutine(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runner.Status())
+}
+
+func handleUpdateRoutine(w http.ResponseWriter, r *http.Request) {
+	runner, ok := getRoutine(w, r)
+	if !ok {
+		return
+	}
+
+	var routine routines.Routine
+	if err := json.NewDecoder(r.Body).Decode(&routine); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := runner.Update(routine); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runner.Status())
+}
+
+// handleRunRoutineStage runs a stage now, regardless of its schedule
+func handleRunRoutineStage(w http.ResponseWriter, r *http.Request) {
+	runner, ok := getRoutine(w, r)
+	if !ok {
+		return
+	}
+
+	stage := chi.URLParam(r, "stage")
+	if err := runner.Run(stage); err != nil {
+		log.Printf("Error running routine %s stage %s: %v", runner.Name(), stage, err)
+		http.Error(w, "Failed to run stage: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordAction(r, actions.KindEntity, "routine."+runner.Name()+"."+stage, "run")
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runner.Status())
+}
+
+// SkipRoutineStageRequest is the body for POST /api/routines/{name}/stages/{stage}/skip
+type SkipRoutineStageRequest struct {
+	Skip bool `json:"skip"`
+}
+
+// handleSkipRoutineStage skips (or un-skips) a stage's next run
+func handleSkipRoutineStage(w http.ResponseWriter, r *http.Request) {
+	runner, ok := getRoutine(w, r)
+	if !ok {
+		return
+	}
+
+	req := SkipRoutineStageRequest{Skip: true}
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	if err := runner.Skip(chi.URLParam(r, "stage"), req.Skip); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(runner.Status())
+}
+
+// ========== Button Mapping ==========
+
+// buttonSubs tracks MQTT subscriptions per topic for mapped buttons
+var buttonSubs = struct {
+	sync.Mutex
+	ids map[string]int
+}{ids: make(map[string]int)}
+
+// syncButtonSubscriptions subscribes to the MQTT topics used by button mappings
+// and drops topics no longer mapped
+func syncButtonSubscriptions(mappings []buttons.Mapping) {
+	wanted := make(map[string]bool)
+	for _, m := range mappings {
+		if m.Source == buttons.SourceMQTT {
+			wanted[m.Device] = true
+		}
+	}
+
+	buttonSubs.Lock()
+	defer buttonSubs.Unlock()
+	for topic, id := range buttonSubs.ids {
+		if !wanted[topic] {
+			mqttClient.Unsubscribe(id)
+			delete(buttonSubs.ids, topic)
+		}
+	}
+	for topic := range wanted {
+		if _, ok := buttonSubs.ids[topic]; ok {
+			continue
+		}
+		buttonSubs.ids[topic] = mqttClient.Subscribe(topic, func(topic string, payload []byte) {
+			event := buttons.ParseMQTTEvent(payload)
+			if event == "" {
+				return // state update without a button action
+			}
+			buttonManager.Handle(buttons.Press{Source: buttons.SourceMQTT, Device: topic, Event: event})
+		})
+	}
+}
+
+// initMQTTSensors maps configured MQTT topics to virtual sensors. State changes are
+// journaled and recorded in sensor history like Home Assistant sensors.
+func initMQTTSensors() {
+	mqttSensors = mqtt.NewSensorBridge(mqttClient, dataStore.Doc("settings", "mqtt_sensors", ""))
+	mqttSensors.OnChange(func(s mqtt.Sensor, previous string) {
+		if s.Value != nil {
+			sensorHistory.Record(s.EntityID, *s.Value)
+		}
+		if previous != "" {
+			recordJournal(journal.Entry{Kind: journal.KindEntity, Subject: s.EntityID, From: previous, To: s.State, Source: "mqtt"})
+		}
+		wsHub.Broadcast(websocket.Event{Type: "mqtt_sensor", Payload: s})
+	})
+}
+
+// initHueSensors follows the bridge's motion, temperature and light sensors on
+// the event stream. Readings go into sensor history, motion and darkness changes
+// are journaled so automations can trigger on them, and remote presses are
+// routed to the button mappings.
+func initHueSensors() {
+	hueSensors = hue.NewSensorMonitor(hueClient, hueEvents)
+	hueSensors.OnChange(func(s hue.Sensor, previous *hue.Sensor) {
+		switch s.Kind {
+		case hue.SensorMotion:
+			if s.Motion != nil {
+				sensorHistory.RecordChange(s.EntityID, boolToFloat(*s.Motion))
+				if previous != nil && previous.Motion != nil {
+					recordJournal(journal.Entry{Kind: journal.KindEntity, Subject: s.EntityID, From: onOff(*previous.Motion), To: onOff(*s.Motion), Source: "hue"})
+				}
+			}
+		case hue.SensorTemperature:
+			if s.Temperature != nil {
+				sensorHistory.Record(s.EntityID, *s.Temperature)
+			}
+		case hue.SensorLightLevel:
+			if s.Lux != nil {
+				sensorHistory.Record(s.EntityID, *s.Lux)
+			}
+			if s.Dark != nil && previous != nil && previous.Dark != nil && *s.Dark != *previous.Dark {
+				recordJournal(journal.Entry{Kind: journal.KindEntity, Subject: s.EntityID + "_dark", From: onOff(*previous.Dark), To: onOff(*s.Dark), Source: "hue"})
+			}
+		}
+		wsHub.Broadcast(websocket.Event{Type: "hue_sensor", Payload: s})
+	})
+	hueSensors.OnButton(func(s hue.Sensor) {
+		buttonManager.Handle(buttons.Press{Source: buttons.SourceHue, Device: s.ID, Event: strconv.Itoa(*s.ButtonEvent)})
+	})
+	go hueSensors.Run()
+}
+
+// initZigbee builds the Zigbee2MQTT device registry. On/off changes are journaled
+// and every state report is pushed to clients.
+func initZigbee(baseTopic string) {
+	zigbeeDevices = mqtt.NewZigbee(mqttClient, baseTopic)
+	zigbeeDevices.OnChange(func(d mqtt.ZigbeeDevice, previous string) {
+		if state, _ := d.State["state"].(string); previous != "" && state != previous {
+			recordJournal(journal.Entry{Kind: journal.KindEntity, Subject: "zigbee." + d.FriendlyName, From: previous, To: state, Source: "zigbee"})
+		}
+		wsHub.Broadcast(websocket.Event{Type: "zigbee_device", Payload: d})
+	})
+}
+
+// parseAppliances parses "id=topic" pairs, e.g. "coffee_maker=homeconnect/coffee_maker"
+func parseAppliances(value string) []mqtt.ApplianceConfig {
+	var result []mqtt.ApplianceConfig
+	for _, entry := range parseEntities(value) {
+		id, topic, ok := strings.Cut(entry, "=")
+		if !ok || id == "" || topic == "" {
+			log.Printf("Warning: Ignoring invalid APPLIANCES entry %q (expected id=topic)", entry)
+			continue
+		}
+		result = append(result, mqtt.ApplianceConfig{ID: strings.TrimSpace(id), Topic: strings.TrimSpace(topic)})
+	}
+	return result
+}
+
+// initAppliances subscribes to appliance state. Operation changes are journaled and
+// a finished program raises a notification ("Dishwasher finished").
+func initAppliances(configs []mqtt.ApplianceConfig) {
+	appliances = mqtt.NewAppliances(mqttClient, configs)
+	appliances.OnChange(func(a mqtt.Appliance, previous string) {
+		if previous != "" && a.Operation != previous {
+			recordJournal(journal.Entry{Kind: journal.KindEntity, Subject: "appliance." + a.ID, From: previous, To: a.Operation, Source: "appliance"})
+			if a.Operation == mqtt.ApplianceFinished && notifyCenter != nil {
+				notifyCenter.Notify(notify.Notification{
+					Source:   "appliance",
+					Key:      "appliance-" + a.ID,
+					Severity: notify.SeverityInfo,
+					Title:    a.Name + " finished",
+					Message:  a.Program,
+				})
+			}
+		}
+		wsHub.Broadcast(websocket.Event{Type: "appliance", Payload: a})
+	})
+	log.Printf("Appliances: %d configured", len(configs))
+}
+
+// runButtonAction performs the action of a matched button mapping
+func runButtonAction(m buttons.Mapping, p buttons.Press) {
+	recordJournal(journal.Entry{
+		Kind:    journal.KindButton,
+		Subject: p.Device,
+		To:      p.Event,
+		Source:  p.Source,
+		Data:    map[string]interface{}{"mapping": m.ID, "action": m.Action.Type},
+	})
+
+	var err error
+	switch m.Action.Type {
+	case buttons.ActionNavigate:
+		go wakeTablet()
+		wsHub.Broadcast(websocket.Event{Type: "navigate", Payload: map[string]string{"page": m.Action.Target}})
+	case buttons.ActionAnnounce:
+		announceMessage(m.Action.Target, "button")
+	case buttons.ActionScene:
+		if hueClient == nil {
+			err = fmt.Errorf("Hue bridge not configured")
+		} else {
+			err = hueClient.ActivateScene(m.Action.Target)
+		}
+	case buttons.ActionToggle:
+		if haClient == nil {
+			err = fmt.Errorf("HA not configured")
+		} else if strings.HasPrefix(m.Action.Target, "lock.") {
+			// Buttons can't enter a PIN, so PIN-protected locks are refused
+			if err = lockGuard.Check(m.Action.Target, ""); err == nil {
+				var service string
+				service, err = toggleEntity(m.Action.Target)
+				if service == "" {
+					service = "toggle"
+				}
+				lockGuard.Record(m.Action.Target, service, "button:"+p.Device, err)
+			} else {
+				lockGuard.Record(m.Action.Target, "toggle", "button:"+p.Device, err)
+			}
+		} else {
+			_, err = toggleEntity(m.Action.Target)
+		}
+	case buttons.ActionPublish:
+		err = publishMQTT(m.Action.Target, []byte(m.Action.Payload), 0, false)
+	case buttons.ActionStart:
+		if appliances == nil {
+			err = fmt.Errorf("appliances not configured")
+		} else {
+			err = appliances.Send(m.Action.Target, mqtt.ApplianceCommand{Action: "start", Program: m.Action.Payload})
+		}
+	case buttons.ActionHeat:
+		if poolManager == nil {
+			err = fmt.Errorf("pool not configured")
+		} else {
+			setpoint, _ := strconv.ParseFloat(m.Action.Payload, 64)
+			err = poolManager.Heat(m.Action.Target, setpoint)
+		}
+	case buttons.ActionMode:
+		err = houseMode.Set(m.Action.Target, housemode.SourceManual)
+	case buttons.ActionEgress:
+		source := "button:" + p.Device
+		if m.Action.Target == buttons.EgressClear {
+			egressMode.Clear(source)
+		} else {
+			req := egress.Request{Drill: m.Action.Target == buttons.EgressDrill, Source: source}
+			if !m.Action.UnlockDoors {
+				req.SkipDoors = egressMode.Status().Doors
+			}
+			egressMode.Start(req)
+		}
+	}
+	if err != nil {
+		log.Printf("Button action %s %s failed: %v", m.Action.Type, m.Action.Target, err)
+	}
+}
+
+func handleGetButtonMappings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buttonManager.List())
+}
+
+func handleCreateButtonMapping(w http.ResponseWriter, r *http.Request) {
+	var req buttons.Mapping
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Action.Type == buttons.ActionEgress && !isAdminRequest(r) {
+		http.Error(w, "Egress buttons need the API token or a client certificate", http.StatusForbidden)
+		return
+	}
+
+	mapping, err := buttonManager.Create(req)
+	if err != nil {
+		http.Error(w, "Failed to create mapping: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mapping)
+}
+
+func handleUpdateButtonMapping(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+
+	var req buttons.Mapping
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	existing, ok := buttonManager.Get(id)
+	if !ok {
+		http.Error(w, "Mapping not found", http.StatusNotFound)
+		return
+	}
+	if (req.Action.Type == buttons.ActionEgress || existing.Action.Type == buttons.ActionEgress) && !isAdminRequest(r) {
+		http.Error(w, "Egress buttons need the API token or a client certificate", http.StatusForbidden)
+		return
+	}
+
+	mapping, err := buttonManager.Update(id, req)
+	if err != nil {
+		http.Error(w, "Failed to update mapping: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mapping)
+}
+
+func handleDeleteButtonMapping(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if existing, ok := buttonManager.Get(id); ok && existing.Action.Type == buttons.ActionEgress && !isAdminRequest(r) {
+		http.Error(w, "Egress buttons need the API token or a client certificate", http.StatusForbidden)
+		return
+	}
+
+	if err := buttonManager.Delete(id); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleButtonPress injects a button event, e.g. from a Home Assistant automation
+func handleButtonPress(w http.ResponseWriter, r *http.Request) {
+	var press buttons.Press
+	if err := json.NewDecoder(r.Body).Decode(&press); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if press.Device == "" {
+		http.Error(w, "device is required", http.StatusBadRequest)
+		return
+	}
+	if press.Source == "" {
+		press.Source = buttons.SourceAPI
+	}
+
+	matched := buttonManager.Handle(press)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"matched": matched})
+}
+
+// MQTT publish
+
+// mqttPublishAllowed reports whether topic matches the configured publish allowlist
+func mqttPublishAllowed(topic string) bool {
+	for _, filter := range appConfig.MQTTPublishTopics {
+		if mqtt.TopicMatches(filter, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// publishMQTT publishes to an allowlisted topic on the main broker
+func publishMQTT(topic string, payload []byte, qos byte, retain bool) error {
+	if mqttClient == nil {
+		return fmt.Errorf("MQTT not configured")
+	}
+	if !mqttPublishAllowed(topic) {
+		return fmt.Errorf("topic %s is not allowed", topic)
+	}
+	return mqttClient.Publish(topic, payload, qos, retain)
+}
+
+// handlePublishMQTT sends a command to an MQTT-only device (e.g. an ESPHome relay).
+// Body: {"topic": "...", "payload": "ON" | {...}, "qos": 0-2, "retain": false}.
+// A string payload is sent as-is; any other JSON value is sent encoded.
+func handlePublishMQTT(w http.ResponseWriter, r *http.Request) {
+	if mqttClient == nil {
+		http.Error(w, "MQTT not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		Topic   string          `json:"topic"`
+		Payload json.RawMessage `json:"payload"`
+		QoS     byte            `json:"qos"`
+		Retain  bool            `json:"retain"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Topic == "" {
+		http.Error(w, "topic is required", http.StatusBadRequest)
+		return
+	}
+	if req.QoS > 2 {
+		http.Error(w, "qos must be 0, 1 or 2", http.StatusBadRequest)
+		return
+	}
+	if !mqttPublishAllowed(req.Topic) {
+		http.Error(w, fmt.Sprintf("Topic %s is not allowed", req.Topic), http.StatusForbidden)
+		return
+	}
+
+	payload := []byte(req.Payload)
+	var text string
+	if err := json.Unmarshal(req.Payload, &text); err == nil {
+		payload = []byte(text)
+	} else if len(req.Payload) == 0 || string(req.Payload) == "null" {
+		payload = nil
+	}
+
+	if err := mqttClient.Publish(req.Topic, payload, req.QoS, req.Retain); err != nil {
+		log.Printf("Error publishing to %s: %v", req.Topic, err)
+		http.Error(w, "Failed to publish: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	recordJournal(journal.Entry{
+		Kind:    journal.KindAction,
+		Subject: req.Topic,
+		To:      string(payload),
+		Source:  requestDeviceID(r),
+		Data:    map[string]interface{}{"kind": "mqtt", "retain": req.Retain},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// MQTT virtual sensor handlers
+
+func handleGetMQTTSensors(w http.ResponseWriter, r *http.Request) {
+	if mqttSensors == nil {
+		http.Error(w, "MQTT not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(mqttSensors.Sensors())
+}
+
+// handleGetMQTTSensor returns one sensor by ID or entity ID (e.g. mqtt.mailbox)
+func handleGetMQTTSensor(w http.ResponseWriter, r *http.Request) {
+	if mqttSensors == nil {
+		http.Error(w, "MQTT not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	sensor, ok := mqttSensors.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "Sensor not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sensor)
+}
+
+func handleCreateMQTTSensor(w http.ResponseWriter, r *http.Request) {
+	if mqttSensors == nil {
+		http.Error(w, "MQTT not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req mqtt.SensorConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	sensor, err := mqttSensors.Create(req)
+	if err != nil {
+		log.Printf("Error creating MQTT sensor: %v", err)
+		http.Error(w, "Failed to create sensor: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sensor)
+}
+
+func handleUpdateMQTTSensor(w http.ResponseWriter, r *http.Request) {
+	if mqttSensors == nil {
+		http.Error(w, "MQTT not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req mqtt.SensorConfig
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	sensor, err := mqttSensors.Update(chi.URLParam(r, "id"), req)
+	if err != nil {
+		log.Printf("Error updating MQTT sensor: %v", err)
+		http.Error(w, "Failed to update sensor: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sensor)
+}
+
+func handleDeleteMQTTSensor(w http.ResponseWriter, r *http.Request) {
+	if mqttSensors == nil {
+		http.Error(w, "MQTT not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	if err := mqttSensors.Delete(chi.URLParam(r, "id")); err != nil {
+		log.Printf("Error deleting MQTT sensor: %v", err)
+		http.Error(w, "Failed to delete sensor: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Zigbee2MQTT handlers
+
+func handleGetZigbeeDevices(w http.ResponseWriter, r *http.Request) {
+	if zigbeeDevices == nil {
+		http.Error(w, "Zigbee2MQTT not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(zigbeeDevices.Devices())
+}
+
+// handleGetZigbeeDevice returns one device by friendly name or IEEE address
+func handleGetZigbeeDevice(w http.ResponseWriter, r *http.Request) {
+	if zigbeeDevices == nil {
+		http.Error(w, "Zigbee2MQTT not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	device, ok := zigbeeDevices.Device(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(device)
+}
+
+// handleSetZigbeeDevice sends a command to a device.
+// Body: {"state": "ON|OFF|TOGGLE", "brightness": 0-254, "color": {"hex": "#ff8800"} | {"x": 0.3, "y": 0.3}, "colorTemp": 250, "transition": 1}
+func handleSetZigbeeDevice(w http.ResponseWriter, r *http.Request) {
+	if zigbeeDevices == nil {
+		http.Error(w, "Zigbee2MQTT not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req mqtt.ZigbeeCommand
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if _, ok := zigbeeDevices.Device(id); !ok {
+		http.Error(w, "Device not found", http.StatusNotFound)
+		return
+	}
+	if err := zigbeeDevices.Set(id, req); err != nil {
+		log.Printf("Error setting Zigbee device %s: %v", id, err)
+		http.Error(w, "Failed to set device: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	recordJournal(journal.Entry{
+		Kind:    journal.KindAction,
+		Subject: "zigbee." + id,
+		To:      req.State,
+		Source:  requestDeviceID(r),
+		Data:    map[string]interface{}{"kind": "zigbee", "command": req},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// Appliance API handlers
+
+func handleGetAppliances(w http.ResponseWriter, r *http.Request) {
+	if appliances == nil {
+		http.Error(w, "Appliances not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(appliances.List())
+}
+
+func handleGetAppliance(w http.ResponseWriter, r *http.Request) {
+	if appliances == nil {
+		http.Error(w, "Appliances not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	a, ok := appliances.Get(chi.URLParam(r, "id"))
+	if !ok {
+		http.Error(w, "Appliance not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a)
+}
+
+// handleApplianceCommand sends start (optional program/options), stop, pause,
+// resume or power ({"power":"on"}) to an appliance
+func handleApplianceCommand(w http.ResponseWriter, r *http.Request) {
+	if appliances == nil {
+		http.Error(w, "Appliances not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req mqtt.ApplianceCommand
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+	req.Action = chi.URLParam(r, "action")
+
+	id := chi.URLParam(r, "id")
+	if _, ok := appliances.Get(id); !ok {
+		http.Error(w, "Appliance not found", http.StatusNotFound)
+		return
+	}
+	if err := appliances.Send(id, req); err != nil {
+		log.Printf("Error sending %s to appliance %s: %v", req.Action, id, err)
+		http.Error(w, "Failed to send command: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	recordJournal(journal.Entry{
+		Kind:    journal.KindAction,
+		Subject: "appliance." + id,
+		To:      req.Action,
+		Source:  requestDeviceID(r),
+		Data:    map[string]interface{}{"kind": "appliance", "command": req},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]bool{"success": true})
+}
+
+// 3D printer
+
+// initPrinter3D starts polling the print host. State changes are journaled and
+// pushed to clients; finished and failed prints raise a notification.
+func initPrinter3D(cfg Config) {
+	client, err := printer3d.NewClient(cfg.Printer3DType, cfg.Printer3DURL, cfg.Printer3DAPIKey, cfg.Printer3DSnapshot, cfg.Printer3DName)
+	if err != nil {
+		log.Printf("Warning: 3D printer disabled: %v", err)
+		return
+	}
+	printer3dClient = client
+	printer3dClient.OnChange(func(s printer3d.Status, previous string) {
+		if previous != "" {
+			recordJournal(journal.Entry{Kind: journal.KindEntity, Subject: "printer3d", From: previous, To: s.State, Source: "printer3d"})
+		}
+		wsHub.Broadcast(websocket.Event{Type: "printer3d", Payload: s})
+
+		if notifyCenter == nil || (previous != printer3d.StatePrinting && previous != printer3d.StatePaused) {
+			return
+		}
+		switch s.State {
+		case printer3d.StateFinished:
+			notifyCenter.Notify(notify.Notification{
+				Source:   "printer3d",
+				Key:      "printer3d",
+				Severity: notify.SeverityInfo,
+				Title:    s.Name + " finished",
+				Message:  s.File,
+			})
+		case printer3d.StateError:
+			notifyCenter.Notify(notify.Notification{
+				Source:   "printer3d",
+				Key:      "printer3d",
+				Severity: notify.SeverityWarning,
+				Title:    s.Name + " stopped with an error",
+				Message:  s.Message,
+			})
+		}
+	})
+	go printer3dClient.Run(time.Minute)
+	log.Printf("3D printer monitoring enabled (%s at %s)", cfg.Printer3DType, cfg.Printer3DURL)
+}
+
+// handleGetPrinter3D returns the print job, progress and temperatures
+func handleGetPrinter3D(w http.ResponseWriter, r *http.Request) {
+	if printer3dClient == nil {
+		http.Error(w, "3D printer not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	status := printer3dClient.LastStatus()
+	if status == nil || r.URL.Query().Get("refresh") == "true" {
+		status = printer3dClient.GetStatus()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// handleGetPrinter3DSnapshot proxies a still from the printer's webcam
+func handleGetPrinter3DSnapshot(w http.ResponseWriter, r *http.Request) {
+	if printer3dClient == nil {
+		http.Error(w, "3D printer not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	data, err := printer3dClient.Snapshot()
+	if err != nil {
+		log.Printf("Error getting 3D printer snapshot: %v", err)
+		http.Error(w, "Failed to get snapshot: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Write(data)
+}
+
+// handlePrinter3DCommand pauses, resumes or cancels the print and returns the new status
+func handlePrinter3DCommand(w http.ResponseWriter, r *http.Request) {
+	if printer3dClient == nil {
+		http.Error(w, "3D printer not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	action := chi.URLParam(r, "action")
+	var err error
+	switch action {
+	case "pause":
+		err = printer3dClient.Pause()
+	case "resume":
+		err = printer3dClient.Resume()
+	case "cancel":
+		err = printer3dClient.Cancel()
+	default:
+		http.Error(w, "Unknown printer command: "+action, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error sending %s to 3D printer: %v", action, err)
+		http.Error(w, "Failed to "+action+" print: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordAction(r, actions.KindEntity, "printer3d", action)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(printer3dClient.GetStatus())
+}
+
+// Sensor history
+
+const sensorHistoryRetention = 30 * 24 * time.Hour
+
+// sampleSensorHistory periodically records numeric sensor and binary_sensor states
+// from the configured Home Assistant entities
+func sampleSensorHistory(entities []string) {
+	var ids []string
+	for _, id := range entities {
+		if strings.HasPrefix(id, "sensor.") || strings.HasPrefix(id, "binary_sensor.") {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	log.Printf("Recording history for %d Home Assistant sensors", len(ids))
+
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	lastState := make(map[string]string)
+	for {
+		states, err := haClient.GetStates(ids)
+		if err != nil {
+			log.Printf("Warning: Failed to sample sensor history: %v", err)
+		}
+		for _, e := range states {
+			if strings.HasPrefix(e.EntityID, "binary_sensor.") {
+				if prev, ok := lastState[e.EntityID]; ok && prev != e.State {
+					recordJournal(journal.Entry{Kind: journal.KindEntity, Subject: e.EntityID, From: prev, To: e.State, Source: "homeassistant"})
+				}
+				lastState[e.EntityID] = e.State
+				sensorHistory.RecordChange(e.EntityID, boolToFloat(e.State == "on"))
+				continue
+			}
+			if v, err := strconv.ParseFloat(e.State, 64); err == nil {
+				sensorHistory.Record(e.EntityID, v)
+			}
+		}
+		<-ticker.C
+	}
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// onOff formats a boolean reading as a binary sensor state for the journal
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
+
+// parseHistoryRange parses a range like "90m", "24h" or "7d"
+func parseHistoryRange(s string) (time.Duration, error) {
+	if s == "" {
+		return 24 * time.Hour, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid range: %s", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid range: %s", s)
+	}
+	return d, nil
+}
+
+func handleGetHistorySensors(w http.ResponseWriter, r *http.Request) {
+	if sensorHistory == nil {
+		http.Error(w, "Sensor history not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	sensors, err := sensorHistory.Sensors()
+	if err != nil {
+		log.Printf("Error listing history sensors: %v", err)
+		http.Error(w, "Failed to list sensors: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sensors)
+}
+
+// handleGetSensorHistory returns a downsampled series, e.g. /api/history/sensor/tablet.light?range=24h&points=200
+func handleGetSensorHistory(w http.ResponseWriter, r *http.Request) {
+	if sensorHistory == nil {
+		http.Error(w, "Sensor history not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	sensor := chi.URLParam(r, "id")
+	span, err := parseHistoryRange(r.URL.Query().Get("range"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	points := 200
+	if p := r.URL.Query().Get("points"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n <= 0 || n > 2000 {
+			http.Error(w, "points must be between 1 and 2000", http.StatusBadRequest)
+			return
+		}
+		points = n
+	}
+
+	to := time.Now()
+	from := to.Add(-span)
+	series, err := sensorHistory.Series(sensor, from, to, points)
+	if err != nil {
+		log.Printf("Error getting history for %s: %v", sensor, err)
+		http.Error(w, "Failed to get sensor history: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"sensor": sensor,
+		"from":   from,
+		"to":     to,
+		"points": series,
+	})
+}
+
+// Lightning alerts
+
+// initLightning connects to the strike feed and escalates notifications as strikes
+// move inside each configured radius
+func initLightning(cfg Config) {
+	lightningMonitor = lightning.NewMonitor(lightning.Config{
+		Lat:        cfg.WeatherLat,
+		Lon:        cfg.WeatherLon,
+		RadiiKm:    cfg.LightningRadiiKm,
+		ClearAfter: cfg.LightningClearAfter,
+	})
+	lightningMonitor.OnAlert(func(a lightning.Alert) {
+		wsHub.Broadcast(websocket.Event{Type: "lightning", Payload: lightningMonitor.Status()})
+
+		n := notify.Notification{Source: "lightning", Key: "lightning"}
+		switch {
+		case a.AllClear:
+			n.Severity = notify.SeverityInfo
+			n.Title = "Lightning all clear"
+			n.Message = fmt.Sprintf("No strikes within %.0f km for %d minutes", cfg.LightningRadiiKm[0], int(cfg.LightningClearAfter.Minutes()))
+		case a.Level == a.Levels-1:
+			n.Severity = notify.SeverityCritical
+			n.Title = "Lightning nearby"
+			n.Message = fmt.Sprintf("Strike %s away, stay indoors", a.Strike.Describe())
+		case a.Level == 0:
+			n.Severity = notify.SeverityInfo
+			n.Title = "Lightning in the area"
+			n.Message = fmt.Sprintf("Strike %s away", a.Strike.Describe())
+		default:
+			n.Severity = notify.SeverityWarning
+			n.Title = "Lightning approaching"
+			n.Message = fmt.Sprintf("Strike %s away, within %.0f km", a.Strike.Describe(), a.RadiusKm)
+		}
+		notifyCenter.Notify(n)
+	})
+	go lightningMonitor.Run()
+
+	client := mqtt.NewClient(mqtt.Config{
+		Host:         cfg.LightningMQTTHost,
+		Port:         1883,
+		ClientID:     fmt.Sprintf("home-control-lightning-%d", time.Now().UnixNano()%1000000),
+		SkipDoorbell: true,
+	})
+	for _, topic := range lightningMonitor.Topics() {
+		client.Subscribe(topic, lightningMonitor.HandleMessage)
+	}
+	go func() {
+		if err := client.Connect(); err != nil {
+			log.Printf("Warning: Lightning feed connection failed: %v", err)
+		}
+	}()
+	log.Printf("Lightning alerts enabled via %s (radii %v km)", cfg.LightningMQTTHost, cfg.LightningRadiiKm)
+}
+
+func handleGetLightning(w http.ResponseWriter, r *http.Request) {
+	if lightningMonitor == nil {
+		http.Error(w, "Lightning alerts not configured", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(lightningMonitor.Status())
+}
+
+// Emergency alerts
+
+// initEmergency polls local emergency feeds and posts each new alert to the notification center
+func initEmergency(cfg Config) {
+	emergencyMonitor = emergency.NewMonitor(emergency.Config{
+		Lat:      cfg.WeatherLat,
+		Lon:      cfg.WeatherLon,
+		RadiusKm: cfg.EmergencyRadiusKm,
+		NWS:      cfg.EmergencyNWS,
+		Feeds:    cfg.EmergencyFeeds,
+	})
+	emergencyMonitor.OnAlert(func(a emergency.Alert) {
+		wsHub.Broadcast(websocket.Event{Type: "emergency_alerts", Payload: emergencyMonitor.Active()})
+		if notifyCenter == nil {
+			return
+		}
+		message := a.Area
+		if a.Instruction != "" {
+			message = a.Instruction
+		}
+		notifyCenter.Notify(notify.Notification{
+			Source:   "emergency",
+			Key:      a.ID,
+			Severity: a.Severity,
+			Title:    a.Headline,
+			Message:  message,
+		})
+	})
+	go emergencyMonitor.Run()
+	log.Printf("Emergency alerts enabled (NWS: %v, %d feeds, %.0f km radius)", cfg.EmergencyNWS, len(cfg.EmergencyFeeds), cfg.EmergencyRadiusKm)
+}
+
+func handleGetEmergencyAlerts(w http.ResponseWriter, r *http.Request) {
+	if emergencyMonitor == nil {
+		http.Error(w, "Emergency alerts not configured", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(emergencyMonitor.Active())
+}
+
+// House mode
+
+// initHouseMode loads the house mode, schedules its night transitions and
+// broadcasts every change
+func initHouseMode() {
+	houseMode = housemode.NewManager(dataStore.Doc("settings", "house_mode", ""), appConfig.Timezone)
+	houseMode.OnChange(func(s housemode.State) {
+		log.Printf("House mode: %s -> %s (%s)", s.Previous, s.Mode, s.Source)
+		recordJournal(journal.Entry{
+			Kind:    journal.KindHouseMode,
+			Subject: "house",
+			From:    s.Previous,
+			To:      s.Mode,
+			Source:  s.Source,
+		})
+		wsHub.Broadcast(websocket.Event{Type: "house_mode", Payload: s})
+	})
+	syncHouseModeSchedule()
+}
+
+// syncHouseModeSchedule replaces the night start/end jobs
+func syncHouseModeSchedule() {
+	jobScheduler.RemovePrefix("mode:")
+	rules := houseMode.Rules()
+	if rules.NightStart == "" {
+		return
+	}
+	if err := jobScheduler.Set("mode:night-start", rules.NightStart, "House mode: night", nil, houseMode.NightStarted); err != nil {
+		log.Printf("Warning: Failed to schedule night mode: %v", err)
+	}
+	if err := jobScheduler.Set("mode:night-end", rules.NightEnd, "House mode: end of night", nil, houseMode.NightEnded); err != nil {
+		log.Printf("Warning: Failed to schedule end of night mode: %v", err)
+	}
+}
+
+// writeHouseMode writes the current mode, its transition rules and the valid modes
+func writeHouseMode(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"state": houseMode.Current(),
+		"rules": houseMode.Rules(),
+		"modes": housemode.Modes,
+	})
+}
+
+func handleGetHouseMode(w http.ResponseWriter, r *http.Request) {
+	writeHouseMode(w)
+}
+
+// SetHouseModeRequest is the body for PUT /api/mode
+type SetHouseModeRequest struct {
+	Mode string `json:"mode"`
+}
+
+// handleSetHouseMode switches the house mode manually
+func handleSetHouseMode(w http.ResponseWriter, r *http.Request) {
+	var req SetHouseModeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !housemode.Valid(req.Mode) {
+		http.Error(w, "Unknown mode: "+req.Mode, http.StatusBadRequest)
+		return
+	}
+	if err := houseMode.Set(req.Mode, housemode.SourceManual); err != nil {
+		log.Printf("Error setting house mode: %v", err)
+		http.Error(w, "Failed to set mode: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	recordAction(r, actions.KindEntity, "house.mode", req.Mode)
+
+	writeHouseMode(w)
+}
+
+// handleUpdateHouseModeRules replaces the automatic transition rules
+func handleUpdateHouseModeRules(w http.ResponseWriter, r *http.Request) {
+	var rules housemode.Rules
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := houseMode.SetRules(rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	syncHouseModeSchedule()
+
+	writeHouseMode(w)
+}
+
+// Presence
+
+// initPresence starts tracking who is home and broadcasts arrivals and departures
+func initPresence(cfg Config) {
+	var entities []string
+	if haClient != nil {
+		entities = cfg.PresenceEntities
+		if len(entities) == 0 {
+			for _, id := range configuredEntities() {
+				if presence.IsPresenceEntity(id) {
+					entities = append(entities, id)
+				}
+			}
+		}
+	}
+	if len(cfg.GeofencePeople) > 0 {
+		if cfg.WebhookSecret == "" {
+			log.Println("Warning: GEOFENCE_PEOPLE set but WEBHOOK_SECRET missing, geofence webhooks disabled")
+		} else {
+			geofenceManager = geofence.NewManager(dataStore.Doc("settings", "geofence", ""), cfg.WeatherLat, cfg.WeatherLon, cfg.GeofenceRadius)
+		}
+	}
+	if len(entities) == 0 && geofenceManager == nil {
+		return
+	}
+
+	presenceTracker = presence.NewTracker(haClient, entities, 30*time.Second)
+	presenceTracker.OnChange(func(c presence.Change) {
+		recordJournal(journal.Entry{
+			Kind:    journal.KindPresence,
+			Subject: c.Person.EntityID,
+			From:    c.From,
+			To:      c.To,
+			Source:  "homeassistant",
+			Data:    map[string]interface{}{"kind": c.Kind, "name": c.Person.Name},
+		})
+		wsHub.Broadcast(websocket.Event{
+			Type: "presence",
+			Payload: map[string]interface{}{
+				"change": c,
+				"people": presenceTracker.People(),
+			},
+		})
+		if c.Kind != presence.KindZone {
+			houseMode.PresenceChanged(presenceTracker.AnyoneHome())
+		}
+	})
+	go presenceTracker.Run()
+	log.Printf("Tracking presence for %d entities", len(entities))
+	if geofenceManager != nil {
+		log.Printf("Geofence webhooks enabled for %d people", len(cfg.GeofencePeople))
+	}
+}
+
+// handleGetPresence returns who is home and the state of everyone tracked
+func handleGetPresence(w http.ResponseWriter, r *http.Request) {
+	if presenceTracker == nil {
+		http.Error(w, "Presence tracking not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"people":     presenceTracker.People(),
+		"home":       presenceTracker.Home(),
+		"anyoneHome": presenceTracker.AnyoneHome(),
+	})
+}
+
+// Geofence
+
+// geofenceEntity is the presence entity ID of a geofence person
+func geofenceEntity(person string) string {
+	return "geofence." + person
+}
+
+// webhookSecretValid reports whether a request carries WEBHOOK_SECRET as a bearer
+// token, X-Webhook-Secret header or basic auth password (OwnTracks' HTTP mode)
+func webhookSecretValid(r *http.Request) bool {
+	secret := []byte(appConfig.WebhookSecret)
+	if len(secret) == 0 {
+		return false
+	}
+	candidates := []string{
+		strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "),
+		r.Header.Get("X-Webhook-Secret"),
+	}
+	if _, password, ok := r.BasicAuth(); ok {
+		candidates = append(candidates, password)
+	}
+	for _, c := range candidates {
+		if c != "" && subtle.ConstantTimeCompare([]byte(c), secret) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// isDark reports whether the sun is below the horizon at home
+func isDark(t time.Time) bool {
+	return lighting.Sun(t, appConfig.WeatherLat, appConfig.WeatherLon).Elevation < -0.833
+}
+
+// runGeofenceAutomation performs an arrival/departure automation
+func runGeofenceAutomation(a geofence.Automation) error {
+	if a.Scene != "" {
+		if err := activateSceneTarget(a.Scene); err != nil {
+			return err
+		}
+	}
+	for _, on := range []bool{true, false} {
+		targets := a.TurnOff
+		if on {
+			targets = a.TurnOn
+		}
+		for _, id := range targets {
+			if err := setEntityPower(id, on); err != nil {
+				return err
+			}
+			recordJournal(journal.Entry{Kind: journal.KindAction, Subject: id, To: onOff(on), Source: "geofence"})
+		}
+	}
+	return nil
+}
+
+// handleGeofenceWebhook takes location updates from a person's phone
+// (OwnTracks HTTP mode, the HA companion app or a plain {"event","zone"} body)
+func handleGeofenceWebhook(w http.ResponseWriter, r *http.Request) {
+	if geofenceManager == nil {
+		http.Error(w, "Geofence not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if !webhookSecretValid(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	person := chi.URLParam(r, "person")
+	name, ok := appConfig.GeofencePeople[person]
+	if !ok {
+		http.Error(w, "Unknown person", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, 64<<10))
+	if err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	report, err := geofence.Parse(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var result geofence.Result
+	if !report.Empty() {
+		result = geofenceManager.Handle(person, report)
+		presenceTracker.Report(geofenceEntity(person), name, result.Status.State)
+		wsHub.Broadcast(websocket.Event{Type: "geofence", Payload: result.Status})
+
+		dark := isDark(time.Now())
+		for _, a := range result.Triggered {
+			if a.AfterDark && !dark {
+				continue
+			}
+			log.Printf("Geofence: %s %s, running %q", name, a.Trigger, a.Name)
+			if err := runGeofenceAutomation(a); err != nil {
+				log.Printf("Warning: Geofence automation %q failed: %v", a.Name, err)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	// OwnTracks reads the response as a list of messages to show
+	if report.Source == geofence.SourceOwnTracks {
+		w.Write([]byte("[]"))
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// handleGetGeofence returns everyone's latest geofence state and the settings
+func handleGetGeofence(w http.ResponseWriter, r *http.Request) {
+	if geofenceManager == nil {
+		http.Error(w, "Geofence not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"people":   geofenceManager.Statuses(),
+		"settings": geofenceManager.Settings(),
+	})
+}
+
+func handleUpdateGeofenceSettings(w http.ResponseWriter, r *http.Request) {
+	if geofenceManager == nil {
+		http.Error(w, "Geofence not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var settings geofence.Settings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := geofenceManager.Update(settings); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(geofenceManager.Settings())
+}
+
+// Security incidents
+
+// securityArmed reports whether signals should be recorded (the house is in away
+// or vacation mode, or nobody is home)
+func securityArmed() bool {
+	switch houseMode.Mode() {
+	case housemode.Away, housemode.Vacation:
+		return true
+	}
+	return presenceTracker != nil && !presenceTracker.AnyoneHome()
+}
+
+// initSecurity watches motion/door sensors, camera person detections and the
+// doorbell while nobody is home, grouping them into incidents so the household
+// gets one notification per incident instead of one per sensor trip.
+func initSecurity(cfg Config) {
+	entities := cfg.SecurityEntities
+	if len(entities) == 0 {
+		for _, id := range configuredEntities() {
+			if strings.HasPrefix(id, "binary_sensor.") && security.ClassifyEntity(id) != "" {
+				entities = append(entities, id)
+			}
+		}
+	}
+
+	securityMonitor = security.NewMonitor(dataStore, cfg.SecurityGap, 90*24*time.Hour)
+	securityMonitor.OnIncident(func(inc security.Incident, escalated bool) {
+		wsHub.Broadcast(websocket.Event{Type: "security_incident", Payload: inc})
+		if notifyCenter == nil {
+			return
+		}
+		title := "Activity while away"
+		if escalated {
+			title = "Activity while away escalated"
+		}
+		notifyCenter.Notify(notify.Notification{
+			Source:   "security",
+			Key:      fmt.Sprintf("incident-%d", inc.ID),
+			Severity: inc.Severity,
+			Title:    title,
+			Message:  inc.Summary,
+		})
+	})
+
+	record := func(s security.Signal) {
+		if !securityArmed() {
+			return
+		}
+		if _, err := securityMonitor.Record(s); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+
+	// Home Assistant sensors are polled while away (sensor history only samples every 5 minutes)
+	var haEntities []string
+	watched := make(map[string]bool)
+	for _, id := range entities {
+		watched[id] = true
+		if strings.HasPrefix(id, "binary_sensor.") {
+			haEntities = append(haEntities, id)
+		}
+	}
+	if len(haEntities) > 0 {
+		go pollSecuritySensors(haEntities, record)
+	}
+
+	// MQTT and Zigbee sensors and the doorbell arrive through the journal
+	if stateJournal != nil {
+		entries, _ := stateJournal.Subscribe(64)
+		go func() {
+			for e := range entries {
+				switch {
+				case e.Kind == journal.KindDoorbell:
+					record(security.Signal{Time: e.Time, Kind: security.KindDoorbell, Subject: e.Subject})
+				case e.Kind == journal.KindEntity && watched[e.Subject] && !strings.HasPrefix(e.Subject, "binary_sensor."):
+					kind := security.ClassifyEntity(e.Subject)
+					if kind != "" && securityTripped(e.To) {
+						record(security.Signal{Time: e.Time, Kind: kind, Subject: e.Subject})
+					}
+				}
+			}
+		}()
+	}
+
+	// Frigate person detections
+	if mqttClient != nil && cfg.FrigateHost != "" {
+		mqttClient.Subscribe("frigate/events", func(topic string, payload []byte) {
+			var ev struct {
+				Type  string `json:"type"`
+				After struct {
+					Camera   string  `json:"camera"`
+					Label    string  `json:"label"`
+					TopScore float64 `json:"top_score"`
+				} `json:"after"`
+			}
+			if err := json.Unmarshal(payload, &ev); err != nil || ev.Type != "new" || ev.After.Label != "person" {
+				return
+			}
+			record(security.Signal{
+				Kind:    security.KindPerson,
+				Subject: ev.After.Camera,
+				Name:    strings.Title(strings.ReplaceAll(ev.After.Camera, "_", " ")),
+				Detail:  fmt.Sprintf("%.0f%% confidence", ev.After.TopScore*100),
+			})
+		})
+	}
+
+	log.Printf("Security incidents: watching %d sensors while away", len(entities))
+}
+
+// pollSecuritySensors records sensors turning on while nobody is home
+func pollSecuritySensors(entities []string, record func(security.Signal)) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	lastState := make(map[string]string)
+	for range ticker.C {
+		if !securityArmed() {
+			// Forget states so sensors already on when everyone leaves aren't reported
+			clear(lastState)
+			continue
+		}
+		states, err := haClient.GetStates(entities)
+		if err != nil {
+			log.Printf("Warning: Failed to poll security sensors: %v", err)
+			continue
+		}
+		for _, e := range states {
+			prev, seen := lastState[e.EntityID]
+			lastState[e.EntityID] = e.State
+			if !seen || prev == e.State || !securityTripped(e.State) {
+				continue
+			}
+			name, _ := e.Attributes["friendly_name"].(string)
+			record(security.Signal{Kind: security.ClassifyEntity(e.EntityID), Subject: e.EntityID, Name: name})
+		}
+	}
+}
+
+// securityTripped reports whether a sensor state means motion, occupancy or open
+func securityTripped(state string) bool {
+	switch strings.ToLower(state) {
+	case "on", "open", "true", "1", "detected", "occupied":
+		return true
+	}
+	return false
+}
+
+// handleGetSecurityIncidents returns recent incidents with their timelines,
+// e.g. /api/security/incidents?hours=48
+func handleGetSecurityIncidents(w http.ResponseWriter, r *http.Request) {
+	if securityMonitor == nil {
+		http.Error(w, "Security monitoring not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	hours := 24
+	if v := r.URL.Query().Get("hours"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 720 {
+			http.Error(w, "hours must be between 1 and 720", http.StatusBadRequest)
+			return
+		}
+		hours = n
+	}
+
+	incidents, err := securityMonitor.Incidents(time.Now().Add(-time.Duration(hours) * time.Hour))
+	if err != nil {
+		log.Printf("Error loading security incidents: %v", err)
+		http.Error(w, "Failed to load incidents: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"armed":     securityArmed(),
+		"incidents": incidents,
+	})
+}
+
+// Mailbox
+
+func initMailbox(cfg Config) {
+	sensor := cfg.MailboxSensor
+	mailboxLog = mailbox.NewLog(dataStore, cfg.Timezone, 365*24*time.Hour)
+	mailboxLog.OnDelivery(func(o mailbox.Opening) {
+		if notifyCenter == nil {
+			return
+		}
+		notifyCenter.Notify(notify.Notification{
+			Source:   "mailbox",
+			Key:      "delivery-" + o.Time.In(cfg.Timezone).Format("2006-01-02"),
+			Severity: notify.SeverityInfo,
+			Title:    "Mail's here",
+			Message:  "The mailbox was opened at " + o.Time.In(cfg.Timezone).Format("3:04 PM"),
+		})
+	})
+
+	opened := func(t time.Time) {
+		o, err := mailboxLog.Record(t)
+		if err != nil {
+			log.Printf("Warning: %v", err)
+			return
+		}
+		wsHub.Broadcast(websocket.Event{Type: "mailbox", Payload: o})
+	}
+
+	if strings.HasPrefix(sensor, "binary_sensor.") {
+		if haClient == nil {
+			log.Printf("Warning: MAILBOX_SENSOR %s needs Home Assistant", sensor)
+			return
+		}
+		go pollMailboxSensor(sensor, opened)
+	} else if stateJournal != nil {
+		// MQTT and Zigbee sensors arrive through the journal
+		entries, _ := stateJournal.Subscribe(16)
+		go func() {
+			for e := range entries {
+				if e.Kind == journal.KindEntity && e.Subject == sensor && securityTripped(e.To) {
+					opened(e.Time)
+				}
+			}
+		}()
+	}
+
+	log.Printf("Mailbox: logging deliveries from %s", sensor)
+}
+
+// pollMailboxSensor reports a Home Assistant contact sensor opening
+func pollMailboxSensor(entity string, opened func(time.Time)) {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	last := ""
+	failing := false
+	for range ticker.C {
+		state, err := haClient.GetState(entity)
+		if err != nil {
+			if !failing {
+				log.Printf("Warning: Failed to poll mailbox sensor: %v", err)
+			}
+			failing = true
+			continue
+		}
+		failing = false
+		if last != "" && last != state.State && securityTripped(state.State) {
+			opened(time.Now())
+		}
+		last = state.State
+	}
+}
+
+// handleGetMailbox returns delivery history by day, e.g. /api/mailbox?days=30
+func handleGetMailbox(w http.ResponseWriter, r *http.Request) {
+	if mailboxLog == nil {
+		http.Error(w, "Mailbox sensor not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	days := 30
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 365 {
+			http.Error(w, "days must be between 1 and 365", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	now := time.Now().In(appConfig.Timezone)
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, 1-days)
+	history, err := mailboxLog.History(start)
+	if err != nil {
+		log.Printf("Error loading mailbox history: %v", err)
+		http.Error(w, "Failed to load mailbox history: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"deliveredToday": len(history) > 0 && history[0].Date == now.Format("2006-01-02"),
+		"days":           history,
+	})
+}
+
+// Screen time
+
+// handleGetScreenTime summarizes device usage, e.g. /api/screentime?days=7&device=kids-tablet
+func handleGetScreenTime(w http.ResponseWriter, r *http.Request) {
+	days := 7
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 90 {
+			http.Error(w, "days must be between 1 and 90", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	summary, err := screenTime.Summary(days, r.URL.Query().Get("device"))
+	if err != nil {
+		log.Printf("Error loading screen time: %v", err)
+		http.Error(w, "Failed to load screen time: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
+
+// handleScreenTimeReport ingests a cumulative daily usage report from the tablet app
+// or a router script (source "router", per-device minutes online and bytes)
+func handleScreenTimeReport(w http.ResponseWriter, r *http.Request) {
+	var req screentime.Report
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	report, err := screenTime.Record(req)
+	if err != nil {
+		log.Printf("Error recording screen time: %v", err)
+		http.Error(w, "Failed to record screen time: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
+
+// Notifications
+
+// handleGetNotifications returns recent notifications, e.g. /api/notifications?source=lightning&limit=20
+func handleGetNotifications(w http.ResponseWriter, r *http.Request) {
+	if notifyCenter == nil {
+		http.Error(w, "Notifications not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 500 {
+			http.Error(w, "limit must be between 1 and 500", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	notifications, err := notifyCenter.Recent(r.URL.Query().Get("source"), limit)
+	if err != nil {
+		log.Printf("Error reading notifications: %v", err)
+		http.Error(w, "Failed to read notifications: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(notifications)
+}
+
+// Lock PINs and audit
+
+// authorizeLock checks the PIN for a lock action, recording and rejecting failures.
+// Returns false if the response has already been written.
+func authorizeLock(w http.ResponseWriter, r *http.Request, entityID, action, pin string) bool {
+	err := lockGuard.Check(entityID, pin)
+	if err == nil {
+		return true
+	}
+
+	lockGuard.Record(entityID, action, requestDeviceID(r), err)
+	switch {
+	case errors.Is(err, locks.ErrPINRequired):
+		http.Error(w, err.Error(), http.StatusPreconditionRequired)
+	case errors.Is(err, locks.ErrLockedOut):
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	default:
+		http.Error(w, err.Error(), http.StatusForbidden)
+	}
+	return false
+}
+
+// vaultSubject is the vault's key in its PIN guard and journal entries
+const vaultSubject = "vault"
+
+// isAdminRequest reports whether the request comes from an admin client: one
+// with the API_TOKEN or a verified client certificate
+func isAdminRequest(r *http.Request) bool {
+	return isAPITokenRequest(r) || (r.TLS != nil && len(r.TLS.VerifiedChains) > 0)
+}
+
+// vaultAccess reports whether the request may read the vault, answering with
+// an error if not. Admin clients always may; kiosks need an unlock token.
+func vaultAccess(w http.ResponseWriter, r *http.Request) bool {
+	if documentVault == nil {
+		http.Error(w, "Document vault not configured", http.StatusServiceUnavailable)
+		return false
+	}
+	if isAdminRequest(r) {
+		return true
+	}
+	token := r.Header.Get("X-Vault-Token")
+	if token == "" {
+		token = r.URL.Query().Get("token") // For documents opened in a frame
+	}
+	if !documentVault.Unlocked(token) {
+		http.Error(w, "Vault is locked", http.StatusUnauthorized)
+		return false
+	}
+	return true
+}
+
+// vaultAdmin reports whether the request may change the vault, answering with
+// an error if not
+func vaultAdmin(w http.ResponseWriter, r *http.Request) bool {
+	if documentVault == nil {
+		http.Error(w, "Document vault not configured", http.StatusServiceUnavailable)
+		return false
+	}
+	if !isAdminRequest(r) {
+		http.Error(w, "Changing the vault needs the API token or a client certificate", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+func handleGetVault(w http.ResponseWriter, r *http.Request) {
+	if !vaultAccess(w, r) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(documentVault.List())
+}
+
+// VaultUnlockRequest is the body for POST /api/vault/unlock
+type VaultUnlockRequest struct {
+	PIN string `json:"pin"`
+}
+
+// VaultUnlockResponse is the response of POST /api/vault/unlock
+type VaultUnlockResponse struct {
+	Token   string    `json:"token"`
+	Expires time.Time `json:"expires"`
+}
+
+func handleUnlockVault(w http.ResponseWriter, r *http.Request) {
+	if documentVault == nil {
+		http.Error(w, "Document vault not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if vaultGuard == nil {
+		http.Error(w, "VAULT_PIN not configured", http.StatusForbidden)
+		return
+	}
+
+	var req VaultUnlockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	source := requestDeviceID(r)
+	if err := vaultGuard.Check(vaultSubject, req.PIN); err != nil {
+		log.Printf("Vault: unlock refused for %s: %v", source, err)
+		switch {
+		case errors.Is(err, locks.ErrPINRequired):
+			http.Error(w, err.Error(), http.StatusPreconditionRequired)
+		case errors.Is(err, locks.ErrLockedOut):
+			http.Error(w, err.Error(), http.StatusTooManyRequests)
+		default:
+			http.Error(w, err.Error(), http.StatusForbidden)
+		}
+		return
+	}
+
+	token, expires := documentVault.Unlock()
+	recordJournal(journal.Entry{Kind: journal.KindAction, Subject: vaultSubject, To: "unlocked", Source: source})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(VaultUnlockResponse{Token: token, Expires: expires})
+}
+
+func handleLockVault(w http.ResponseWriter, r *http.Request) {
+	if documentVault == nil {
+		http.Error(w, "Document vault not configured", http.StatusServiceUnavailable)
+		return
+	}
+	documentVault.Lock(r.Header.Get("X-Vault-Token"))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleGetVaultDocument(w http.ResponseWriter, r *http.Request) {
+	if !vaultAccess(w, r) {
+		return
+	}
+
+	item, data, err := documentVault.Open(chi.URLParam(r, "id"))
+	if errors.Is(err, vault.ErrNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		log.Printf("Error opening vault document: %v", err)
+		http.Error(w, "Failed to open document: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", item.ContentType)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": item.Name}))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Write(data)
+}
+
+// handleUploadVaultDocument stores a multipart "file", named by the optional
+// "name" field (default: the file name)
+func handleUploadVaultDocument(w http.ResponseWriter, r *http.Request) {
+	if !vaultAdmin(w, r) {
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, vault.MaxFileSize+1<<20)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	name := r.FormValue("name")
+	if strings.TrimSpace(name) == "" {
+		name = header.Filename
+	}
+	contentType := header.Header.Get("Content-Type")
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = http.DetectContentType(data)
+	}
+
+	item, err := documentVault.Add(name, contentType, data)
+	if err != nil {
+		log.Printf("Error adding vault document: %v", err)
+		http.Error(w, "Failed to add document: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	recordJournal(journal.Entry{Kind: journal.KindAction, Subject: vaultSubject, To: "added " + item.Name, Source: requestDeviceID(r)})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(item)
+}
+
+func handleDeleteVaultDocument(w http.ResponseWriter, r *http.Request) {
+	if !vaultAdmin(w, r) {
+		return
+	}
+
+	id := chi.URLParam(r, "id")
+	if err := documentVault.Delete(id); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, vault.ErrNotFound) {
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	recordJournal(journal.Entry{Kind: journal.KindAction, Subject: vaultSubject, To: "deleted " + id, Source: requestDeviceID(r)})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ========== Party Mode Handlers ==========
+
+// handleGetParty returns the running party and its slideshow photos; with
+// ?pending=true the moderation queue is included
+func handleGetParty(w http.ResponseWriter, r *http.Request) {
+	status := partyMode.Status(r.URL.Query().Get("pending") == "true")
+	if status == nil {
+		http.Error(w, "No party running", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// StartPartyRequest is the body for POST /api/party
+type StartPartyRequest struct {
+	Name      string `json:"name"`
+	Hours     int    `json:"hours"`     // Default 6; photos are deleted when the party ends
+	Moderated bool   `json:"moderated"` // Photos wait for approval on a kiosk
+}
+
+func handleStartParty(w http.ResponseWriter, r *http.Request) {
+	var req StartPartyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	status, err := partyMode.Start(req.Name, req.Hours, req.Moderated)
+	if err != nil {
+		log.Printf("Error starting party: %v", err)
+		http.Error(w, "Failed to start party: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	recordJournal(journal.Entry{Kind: journal.KindAction, Subject: "party", To: "started", Source: requestDeviceID(r)})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func handleEndParty(w http.ResponseWriter, r *http.Request) {
+	partyMode.End()
+	recordJournal(journal.Entry{Kind: journal.KindAction, Subject: "party", To: "ended", Source: requestDeviceID(r)})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// partyJoinURL is the address in the QR code. Without PARTY_BASE_URL it uses
+// the host the kiosk reached the server on, which guests on the LAN can too.
+func partyJoinURL(r *http.Request, token string) string {
+	base := appConfig.PartyBaseURL
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return base + "/party/" + token
+}
+
+func handleGetPartyQR(w http.ResponseWriter, r *http.Request) {
+	token, ok := partyMode.Token()
+	if !ok {
+		http.Error(w, "No party running", http.StatusNotFound)
+		return
+	}
+	code, err := qr.Encode(partyJoinURL(r, token))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-store")
+	io.WriteString(w, code.SVG(4))
+}
+
+func handleGetPartyPhoto(w http.ResponseWriter, r *http.Request) {
+	path, contentType, err := partyMode.Path(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	http.ServeFile(w, r, path)
+}
+
+func handleApprovePartyPhoto(w http.ResponseWriter, r *http.Request) {
+	if err := partyMode.Approve(chi.URLParam(r, "id")); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleDeletePartyPhoto(w http.ResponseWriter, r *http.Request) {
+	if err := partyMode.Delete(chi.URLParam(r, "id")); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePartyJoinPage serves the guest upload page the QR code opens
+func handlePartyJoinPage(w http.ResponseWriter, r *http.Request) {
+	if !partyMode.ValidToken(chi.URLParam(r, "token")) {
+		http.Error(w, "This party has ended", http.StatusNotFound)
+		return
+	}
+	http.ServeFile(w, r, filepath.Join("static", "party.html"))
+}
+
+// handleUploadPartyPhotos stores a guest's multipart "photo" files, credited
+// to the optional "name" field
+func handleUploadPartyPhotos(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+	if !partyMode.ValidToken(token) {
+		http.Error(w, "This party has ended", http.StatusNotFound)
+		return
+	}
+
+	const maxFiles = 10
+	r.Body = http.MaxBytesReader(w, r.Body, maxFiles*party.MaxPhotoSize+1<<20)
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	files := r.MultipartForm.File["photo"]
+	if len(files) == 0 || len(files) > maxFiles {
+		http.Error(w, fmt.Sprintf("Upload between 1 and %d photos", maxFiles), http.StatusBadRequest)
+		return
+	}
+
+	var photos []*party.Photo
+	for _, header := range files {
+		file, err := header.Open()
+		if err != nil {
+			http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		data, err := io.ReadAll(file)
+		file.Close()
+		if err != nil {
+			http.Error(w, "Invalid upload: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		photo, err := partyMode.Upload(token, r.FormValue("name"), party.DetectType(header.Header.Get("Content-Type"), data), data)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		photos = append(photos, photo)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(photos)
+}
+
+// handleGetLockAudit returns recent lock actions, e.g. /api/locks/audit?entity=lock.front_door&limit=50
+func handleGetLockAudit(w http.ResponseWriter, r *http.Request) {
+	if lockGuard == nil {
+		http.Error(w, "Lock audit not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	entries, err := lockGuard.Audit(r.URL.Query().Get("entity"), limit)
+	if err != nil {
+		log.Printf("Error reading lock audit log: %v", err)
+		http.Error(w, "Failed to read lock audit log: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// Emergency egress mode
+
+func handleGetEgress(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": egressMode.Status(),
+		"plan":   egressMode.Plan(),
+	})
+}
+
+// handleStartEgress starts emergency mode: {"drill": true} for a fire drill,
+// {"dryRun": true} to test which steps would run without running them
+// EgressStartRequest is the body for POST /api/egress/start. The doors' PIN
+// may also be sent in the X-Lock-PIN header.
+type EgressStartRequest struct {
+	egress.Request
+	PIN string `json:"pin"`
+}
+
+func handleStartEgress(w http.ResponseWriter, r *http.Request) {
+	var req EgressStartRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
 		}
-		log.Printf("PS5 manager initialized with %d device(s)", len(cfg.PS5Devices))
 	}
+	req.Source = requestDeviceID(r)
+	if !req.DryRun {
+		req.SkipDoors = egressDoorsRefused(r, req.PIN)
+	}
+	status := egressMode.Start(req.Request)
+	if !req.DryRun {
+		recordJournal(journal.Entry{Kind: journal.KindAction, Subject: "egress", To: "on", Source: req.Source})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func handleClearEgress(w http.ResponseWriter, r *http.Request) {
+	status := egressMode.Clear(requestDeviceID(r))
+	recordJournal(journal.Entry{Kind: journal.KindAction, Subject: "egress", To: "off", Source: requestDeviceID(r)})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+func handleGetEgressAudit(w http.ResponseWriter, r *http.Request) {
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	entries, err := egressMode.Audit(limit)
+	if err != nil {
+		log.Printf("Error reading egress audit log: %v", err)
+		http.Error(w, "Failed to read egress audit log: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// allLightsFull turns every Hue and Home Assistant light on at full brightness
+func allLightsFull() error {
+	var errs []error
+	if hueClient != nil {
+		// Group 0 is every light on the bridge
+		if err := hueClient.SetGroupState("0", map[string]interface{}{"on": true, "bri": 254}); err != nil {
+			errs = append(errs, fmt.Errorf("hue: %w", err))
+		}
+	}
+	if haClient != nil {
+		for _, id := range entitiesWithPrefix("light.") {
+			if _, err := haClient.CallServiceData("light", "turn_on", map[string]interface{}{"entity_id": id, "brightness_pct": 100}); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", id, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// egressDoorsRefused returns the egress doors the request may not unlock.
+// Admin clients may unlock all of them; others need each door's PIN, so
+// emergency mode still runs everything else without it.
+func egressDoorsRefused(r *http.Request, pin string) []string {
+	if isAdminRequest(r) {
+		return nil
+	}
+	if pin == "" {
+		pin = r.Header.Get("X-Lock-PIN")
+	}
+	var refused []string
+	for _, door := range egressMode.Status().Doors {
+		if err := lockGuard.Check(door, pin); err != nil {
+			lockGuard.Record(door, "unlock", "egress:"+requestDeviceID(r), err)
+			refused = append(refused, door)
+		}
+	}
+	return refused
+}
+
+// unlockForEgress unlocks a door once the request is authorized for it,
+// recording it in the lock audit log
+func unlockForEgress(lockID string) error {
+	if haClient == nil {
+		return fmt.Errorf("HA not configured")
+	}
+	err := haClient.CallService("lock", "unlock", lockID)
+	lockGuard.Record(lockID, "unlock", "egress", err)
+	return err
+}
+
+// Expected visitor handlers
+
+// lockForVisitor unlocks a door for an expected visitor's window, or locks it
+// again afterwards, recording it in the lock audit log
+func lockForVisitor(lockID string, unlock bool, v visitors.Visitor) error {
+	if haClient == nil {
+		return fmt.Errorf("HA not configured")
+	}
+	action := "lock"
+	if unlock {
+		action = "unlock"
+	}
+	err := haClient.CallService("lock", action, lockID)
+	lockGuard.Record(lockID, action, "visitor:"+v.Name, err)
+	return err
+}
+
+func handleGetVisitors(w http.ResponseWriter, r *http.Request) {
+	since := time.Now()
+	if r.URL.Query().Get("past") == "true" {
+		since = time.Time{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visitorManager.List(since))
+}
+
+// decodeVisitor reads a visitor from the request body, checking the chime
+func decodeVisitor(w http.ResponseWriter, r *http.Request) (visitors.Visitor, bool) {
+	var req visitors.Visitor
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return req, false
+	}
+	if req.Chime != "" && !slices.Contains(announce.Chimes, req.Chime) {
+		http.Error(w, "Unknown chime (use "+strings.Join(announce.Chimes, ", ")+")", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// authorizeVisitorDoor checks that the request may have a door unlocked for a
+// visitor: an admin client, or the door's PIN in the X-Lock-PIN header.
+// Returns false if the response has already been written.
+func authorizeVisitorDoor(w http.ResponseWriter, r *http.Request, lockID string) bool {
+	if isAdminRequest(r) {
+		return true
+	}
+	return authorizeLock(w, r, lockID, "visitor", r.Header.Get("X-Lock-PIN"))
+}
+
+func handleCreateVisitor(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeVisitor(w, r)
+	if !ok {
+		return
+	}
+	if req.Unlock != "" && !authorizeVisitorDoor(w, r, req.Unlock) {
+		return
+	}
+
+	visitor, err := visitorManager.Create(req)
+	if err != nil {
+		log.Printf("Error creating visitor: %v", err)
+		http.Error(w, "Failed to create visitor: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visitor)
+}
+
+func handleUpdateVisitor(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	req, ok := decodeVisitor(w, r)
+	if !ok {
+		return
+	}
+	existing, found := visitorManager.Get(id)
+	if !found {
+		http.Error(w, "Visitor not found", http.StatusNotFound)
+		return
+	}
+	// Moving the window of an unlocking visit is as good as unlocking the door
+	changed := req.Unlock != existing.Unlock || !req.From.Equal(existing.From) || !req.Until.Equal(existing.Until)
+	if req.Unlock != "" && changed && !authorizeVisitorDoor(w, r, req.Unlock) {
+		return
+	}
+
+	visitor, err := visitorManager.Update(id, req)
+	if err != nil {
+		log.Printf("Error updating visitor: %v", err)
+		http.Error(w, "Failed to update visitor: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visitor)
+}
+
+func handleDeleteVisitor(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := visitorManager.Delete(id); err != nil {
+		log.Printf("Error deleting visitor: %v", err)
+		http.Error(w, "Failed to delete visitor: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleVisitorArrived logs a visitor who came in without ringing
+func handleVisitorArrived(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	by := requestDeviceID(r)
+
+	visitor, err := visitorManager.Arrived(id, by, time.Now())
+	if err != nil {
+		log.Printf("Error logging visitor arrival: %v", err)
+		http.Error(w, "Failed to log arrival: "+err.Error(), http.StatusNotFound)
+		return
+	}
+	recordJournal(journal.Entry{Kind: journal.KindPresence, Subject: visitor.Name, To: "arrived", Source: by})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(visitor)
+}
+
+// House manual handlers
+
+func handleGetManual(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(houseManual.Get())
+}
+
+// GuestModeRequest turns guest mode on or off. Hours (or an until time) ends it
+// automatically.
+type GuestModeRequest struct {
+	Active bool       `json:"active"`
+	Hours  float64    `json:"hours,omitempty"`
+	Until  *time.Time `json:"until,omitempty"`
+}
+
+func handleSetGuestMode(w http.ResponseWriter, r *http.Request) {
+	var req GuestModeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.Hours < 0 {
+		http.Error(w, "hours must not be negative", http.StatusBadRequest)
+		return
+	}
+	until := req.Until
+	if req.Hours > 0 {
+		t := time.Now().Add(time.Duration(req.Hours * float64(time.Hour)))
+		until = &t
+	}
+
+	source := requestDeviceID(r)
+	previous := houseManual.GuestMode()
+	g, err := houseManual.SetGuestMode(req.Active, until, source)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if previous.Active != g.Active {
+		log.Printf("Guest mode %s (%s)", onOff(g.Active), source)
+		recordJournal(journal.Entry{Kind: journal.KindHouseMode, Subject: "guest", From: onOff(previous.Active), To: onOff(g.Active), Source: source})
+	}
+	if g.Active {
+		go wakeTablet()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(g)
+}
+
+func handleCreateManualSection(w http.ResponseWriter, r *http.Request) {
+	var req manual.Section
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	section, err := houseManual.Create(req)
+	if err != nil {
+		log.Printf("Error creating manual section: %v", err)
+		http.Error(w, "Failed to create section: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(section)
+}
+
+func handleUpdateManualSection(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	var req manual.Section
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	section, err := houseManual.Update(id, req)
+	if err != nil {
+		log.Printf("Error updating manual section: %v", err)
+		http.Error(w, "Failed to update section: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(section)
+}
+
+func handleDeleteManualSection(w http.ResponseWriter, r *http.Request) {
+	id := chi.URLParam(r, "id")
+	if err := houseManual.Delete(id); err != nil {
+		log.Printf("Error deleting manual section: %v", err)
+		http.Error(w, "Failed to delete section: "+err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// State change journal
+
+// recordJournal appends an entry to the state journal if it is available
+func recordJournal(e journal.Entry) {
+	if stateJournal == nil {
+		return
+	}
+	stateJournal.Record(e)
+}
+
+// recordDevicePower journals a power command sent to an entertainment device
+func recordDevicePower(r *http.Request, platform, name, action string) {
+	recordJournal(journal.Entry{
+		Kind:    journal.KindDevicePower,
+		Subject: platform + "/" + name,
+		To:      action,
+		Source:  requestDeviceID(r),
+	})
+	go refreshEntertainmentState(platform, name)
+}
+
+// handleGetJournal returns journal entries after ?since= (an entry ID or RFC3339 time),
+// optionally filtered by ?kind=. With ?stream=true or Accept: text/event-stream the
+// backlog is sent as server-sent events and the connection stays open for new entries.
+func handleGetJournal(w http.ResponseWriter, r *http.Request) {
+	if stateJournal == nil {
+		http.Error(w, "Journal not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	q := r.URL.Query()
+	limit := 500
+	if l := q.Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 5000 {
+			http.Error(w, "limit must be between 1 and 5000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	kinds := make(map[string]bool)
+	for _, k := range parseEntities(q.Get("kind")) {
+		kinds[k] = true
+	}
+
+	since := q.Get("since")
+	if id := r.Header.Get("Last-Event-ID"); id != "" {
+		since = id
+	}
+
+	var entries []journal.Entry
+	var cursor int64
+	var err error
+	if since == "" {
+		entries, err = stateJournal.SinceTime(time.Now().Add(-24*time.Hour), limit)
+	} else if id, perr := strconv.ParseInt(since, 10, 64); perr == nil {
+		cursor = id
+		entries, err = stateJournal.Since(id, limit)
+	} else if t, perr := time.Parse(time.RFC3339, since); perr == nil {
+		entries, err = stateJournal.SinceTime(t, limit)
+	} else {
+		http.Error(w, "since must be an entry ID or RFC3339 time", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		log.Printf("Error reading journal: %v", err)
+		http.Error(w, "Failed to read journal: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if q.Get("stream") != "true" && !strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		filtered := make([]journal.Entry, 0, len(entries))
+		for _, e := range entries {
+			if len(kinds) == 0 || kinds[e.Kind] {
+				filtered = append(filtered, e)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(filtered)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// Subscribe, then catch up on anything recorded since the backlog was read
+	live, unsubscribe := stateJournal.Subscribe(64)
+	defer unsubscribe()
+	if len(entries) > 0 {
+		cursor = entries[len(entries)-1].ID
+	}
+	if cursor > 0 {
+		if more, err := stateJournal.Since(cursor, 0); err == nil {
+			entries = append(entries, more...)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	var lastID int64
+	send := func(e journal.Entry) {
+		if e.ID <= lastID || (len(kinds) > 0 && !kinds[e.Kind]) {
+			return
+		}
+		lastID = e.ID
+		data, _ := json.Marshal(e)
+		fmt.Fprintf(w, "id: %d\ndata: %s\n\n", e.ID, data)
+	}
+	for _, e := range entries {
+		send(e)
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(30 * time.Second)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-live:
+			if !ok {
+				return
+			}
+			send(e)
+			flusher.Flush()
+		case <-keepalive.C:
+			fmt.Fprint(w, ": keepalive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// Cache tuning
+
+// initCaches registers the tunable caches and applies persisted TTL overrides
+func initCaches() {
+	cacheRegistry.Register(calendarCacheTunable{})
+	cacheRegistry.Register(hueRoomsCache)
+	cacheRegistry.Register(playbackCache)
+	cacheRegistry.Register(syncBoxCache)
+	cacheRegistry.Register(areasCache)
+
+	cacheTTLDoc = dataStore.Doc("settings", "cache_ttls", "")
+	var overrides map[string]float64 // cache name -> TTL seconds
+	if _, err := cacheTTLDoc.Load(&overrides); err != nil {
+		log.Printf("Warning: Failed to load cache TTL overrides: %v", err)
+	}
+	for name, secs := range overrides {
+		if c, ok := cacheRegistry.Get(name); ok {
+			c.SetTTL(time.Duration(secs * float64(time.Second)))
+			log.Printf("Cache %s TTL set to %gs", name, secs)
+		}
+	}
+}
+
+func handleGetCaches(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cacheRegistry.Stats())
+}
+
+// handleSetCacheTTL changes a cache's TTL ({"ttlSeconds": 5}); 0 disables caching
+func handleSetCacheTTL(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	c, ok := cacheRegistry.Get(name)
+	if !ok {
+		http.Error(w, "Cache not found: "+name, http.StatusNotFound)
+		return
+	}
+
+	var req struct {
+		TTLSeconds *float64 `json:"ttlSeconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if req.TTLSeconds == nil || *req.TTLSeconds < 0 || *req.TTLSeconds > 86400 {
+		http.Error(w, "ttlSeconds must be between 0 and 86400", http.StatusBadRequest)
+		return
+	}
+
+	c.SetTTL(time.Duration(*req.TTLSeconds * float64(time.Second)))
+
+	// Persist all current TTLs so overrides survive restarts
+	overrides := make(map[string]float64)
+	for _, st := range cacheRegistry.Stats() {
+		overrides[st.Name] = st.TTLSeconds
+	}
+	if err := cacheTTLDoc.Save(overrides); err != nil {
+		log.Printf("Error saving cache TTL overrides: %v", err)
+		http.Error(w, "Failed to save cache TTL: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	log.Printf("Cache %s TTL set to %gs", name, *req.TTLSeconds)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(c.Stats())
 }
 
-func parseEntities(s string) []string {
-	if s == "" {
-		return nil
+func handleInvalidateCache(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	c, ok := cacheRegistry.Get(name)
+	if !ok {
+		http.Error(w, "Cache not found: "+name, http.StatusNotFound)
+		return
 	}
-	entities := strings.Split(s, ",")
-	for i := range entities {
-		entities[i] = strings.TrimSpace(entities[i])
+
+	c.InvalidateAll()
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Feature flag handlers
+
+// handleGetFlags returns the resolved flags for the requesting device
+func handleGetFlags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(featureFlags.Evaluate(requestDeviceID(r)))
+}
+
+func handleGetFlagConfig(w http.ResponseWriter, r *http.Request) {
+	flagList, profiles := featureFlags.Config()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"flags":    flagList,
+		"profiles": profiles,
+	})
+}
+
+func handleSetFlag(w http.ResponseWriter, r *http.Request) {
+	var f flags.Flag
+	if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
 	}
-	return entities
+	f.Name = chi.URLParam(r, "name")
+
+	if err := featureFlags.Set(f); err != nil {
+		log.Printf("Error setting feature flag %s: %v", f.Name, err)
+		http.Error(w, "Failed to set flag: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(f)
 }
 
-// parseSyncBoxes parses SYNC_BOXES env var format: "name:ip:token,name2:ip2:token2"
-func parseSyncBoxes(s string) []SyncBoxConfig {
-	if s == "" {
-		return nil
+func handleDeleteFlag(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	if err := featureFlags.Delete(name); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
 	}
-	var boxes []SyncBoxConfig
-	for _, entry := range strings.Split(s, ",") {
-		entry = strings.TrimSpace(entry)
-		parts := strings.SplitN(entry, ":", 3)
-		if len(parts) == 3 {
-			boxes = append(boxes, SyncBoxConfig{
-				Name:        strings.TrimSpace(parts[0]),
-				IP:          strings.TrimSpace(parts[1]),
-				AccessToken: strings.TrimSpace(parts[2]),
-			})
-		}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleSetDeviceProfile assigns a device to a targeting profile
+func handleSetDeviceProfile(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Profile string `json:"profile"`
 	}
-	return boxes
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	device := chi.URLParam(r, "device")
+	if err := featureFlags.SetProfile(device, req.Profile); err != nil {
+		log.Printf("Error setting profile for %s: %v", device, err)
+		http.Error(w, "Failed to set profile: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(featureFlags.Evaluate(device))
 }
 
-// parseSonyDevices parses format: "name:host:port:psk:type,..."
-func parseSonyDevices(s string) []SonyDeviceConfig {
-	if s == "" {
-		return nil
+// Morning briefing
+
+// briefingItem is one line of the morning briefing
+type briefingItem struct {
+	Kind     string `json:"kind"`
+	Text     string `json:"text"`
+	Severity string `json:"severity"`
+}
+
+// morningBriefing collects today's highlights worth mentioning
+func morningBriefing() []briefingItem {
+	items := []briefingItem{}
+
+	if weatherClient != nil && appConfig.UVAlertThreshold > 0 {
+		if uv, ok := weatherClient.TodayMaxUV(); ok && uv >= appConfig.UVAlertThreshold {
+			text := fmt.Sprintf("UV %.0f today", uv)
+			if appConfig.UVAlertMessage != "" {
+				text += " — " + appConfig.UVAlertMessage
+			}
+			severity := notify.SeverityInfo
+			if uv >= 8 {
+				severity = notify.SeverityWarning
+			}
+			items = append(items, briefingItem{Kind: "uv", Text: text, Severity: severity})
+		}
 	}
-	var devices []SonyDeviceConfig
-	for _, entry := range strings.Split(s, ",") {
-		entry = strings.TrimSpace(entry)
-		parts := strings.SplitN(entry, ":", 5)
-		if len(parts) >= 4 {
-			port := 10000 // default Sony port
-			if len(parts) >= 3 {
-				if p, err := strconv.Atoi(strings.TrimSpace(parts[2])); err == nil {
-					port = p
-				}
+
+	if pantryManager != nil && appConfig.PantryExpiryDays > 0 {
+		for _, item := range pantryManager.Expiring(1) {
+			items = append(items, briefingItem{Kind: "pantry", Text: item.Label(), Severity: notify.SeverityInfo})
+		}
+	}
+
+	if countdownManager != nil {
+		for _, c := range countdownManager.List(false) {
+			if c.DaysLeft <= 7 {
+				items = append(items, briefingItem{Kind: "countdown", Text: c.Label, Severity: notify.SeverityInfo})
 			}
-			deviceType := "soundbar"
-			if len(parts) >= 5 {
-				deviceType = strings.TrimSpace(parts[4])
+		}
+	}
+
+	return items
+}
+
+// runMorningBriefing sends the briefing as a notification every day at "HH:MM"
+func runMorningBriefing(at string) {
+	clock, err := time.Parse("15:04", at)
+	if err != nil {
+		log.Printf("Warning: Invalid BRIEFING_TIME %q, morning briefing disabled", at)
+		return
+	}
+
+	for {
+		now := time.Now().In(appConfig.Timezone)
+		next := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, appConfig.Timezone)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		time.Sleep(time.Until(next))
+
+		items := morningBriefing()
+		if len(items) == 0 || notifyCenter == nil {
+			continue
+		}
+		lines := make([]string, 0, len(items))
+		severity := notify.SeverityInfo
+		for _, item := range items {
+			lines = append(lines, item.Text)
+			if item.Severity == notify.SeverityWarning {
+				severity = notify.SeverityWarning
 			}
-			devices = append(devices, SonyDeviceConfig{
-				Name:       strings.TrimSpace(parts[0]),
-				Host:       strings.TrimSpace(parts[1]),
-				Port:       port,
-				PSK:        strings.TrimSpace(parts[3]),
-				DeviceType: deviceType,
-			})
 		}
+		notifyCenter.Notify(notify.Notification{
+			Source:   "briefing",
+			Key:      "briefing-" + next.Format("2006-01-02"),
+			Severity: severity,
+			Title:    "Good morning",
+			Message:  strings.Join(lines, " · "),
+		})
 	}
-	return devices
 }
 
-// parseShieldDevices parses format: "name:host:port,..."
-func parseShieldDevices(s string) []ShieldDeviceConfig {
-	if s == "" {
+func handleGetBriefing(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"date":  time.Now().In(appConfig.Timezone).Format("2006-01-02"),
+		"items": morningBriefing(),
+	})
+}
+
+// Weather API handler
+
+func handleGetWeather(w http.ResponseWriter, r *http.Request) {
+	if weatherClient == nil {
+		http.Error(w, "Weather not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	client := weatherClient
+	if name := r.URL.Query().Get("location"); name != "" {
+		var ok bool
+		if client, ok = weatherLocations.Get(name); !ok {
+			http.Error(w, "Location not found", http.StatusNotFound)
+			return
+		}
+	}
+
+	data := client.GetWeather()
+	if data == nil {
+		http.Error(w, "Weather data not available yet", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(data)
+}
+
+func handleGetWeatherLocations(w http.ResponseWriter, r *http.Request) {
+	if weatherLocations == nil {
+		http.Error(w, "Weather not configured", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(weatherLocations.List())
+}
+
+// AstronomyDay is one day of GET /api/astronomy
+type AstronomyDay struct {
+	Date string          `json:"date"`
+	Sun  lighting.SunDay `json:"sun"`
+	Moon lighting.Moon   `json:"moon"` // At local noon
+}
+
+// handleGetAstronomy returns sun times and the moon phase at WEATHER_LAT/LON for
+// ?date (default today), or a list of ?days days from it (up to 42, a month view)
+func handleGetAstronomy(w http.ResponseWriter, r *http.Request) {
+	if appConfig.WeatherLat == 0 && appConfig.WeatherLon == 0 {
+		http.Error(w, "WEATHER_LAT/WEATHER_LON not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	loc := appConfig.Timezone
+	date := time.Now().In(loc)
+	if dateStr := r.URL.Query().Get("date"); dateStr != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", dateStr, loc)
+		if err != nil {
+			http.Error(w, "Invalid date (use YYYY-MM-DD)", http.StatusBadRequest)
+			return
+		}
+		date = parsed
+	}
+	days := 0
+	if daysStr := r.URL.Query().Get("days"); daysStr != "" {
+		n, err := strconv.Atoi(daysStr)
+		if err != nil || n < 1 || n > 42 {
+			http.Error(w, "days must be between 1 and 42", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	astronomyDay := func(d time.Time) AstronomyDay {
+		y, m, dd := d.Date()
+		return AstronomyDay{
+			Date: d.Format("2006-01-02"),
+			Sun:  lighting.SunTimes(d, appConfig.WeatherLat, appConfig.WeatherLon, loc),
+			Moon: lighting.MoonPhase(time.Date(y, m, dd, 12, 0, 0, 0, loc)),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if days == 0 {
+		json.NewEncoder(w).Encode(astronomyDay(date))
+		return
+	}
+	result := make([]AstronomyDay, 0, days)
+	for i := 0; i < days; i++ {
+		result = append(result, astronomyDay(date.AddDate(0, 0, i)))
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// parseWeatherLocations parses WEATHER_LOCATIONS: "name=lat:lon,..."
+func parseWeatherLocations(s string) []weather.Location {
+	locations, err := weather.ParseLocations(s)
+	if err != nil {
+		log.Printf("Warning: Ignoring WEATHER_LOCATIONS: %v", err)
 		return nil
 	}
-	var devices []ShieldDeviceConfig
-	for _, entry := range strings.Split(s, ",") {
-		entry = strings.TrimSpace(entry)
-		parts := strings.SplitN(entry, ":", 3)
-		if len(parts) >= 2 {
-			port := 5555 // default ADB port
-			if len(parts) >= 3 {
-				if p, err := strconv.Atoi(strings.TrimSpace(parts[2])); err == nil {
-					port = p
-				}
+	return locations
+}
+
+// WebSocket handler
+func handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	wsHub.ServeWS(w, r)
+}
+
+// Camera API handlers
+func handleCameraSnapshot(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	cameraManager.ProxySnapshot(w, r, name)
+}
+
+func handleCameraStream(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+	cameraManager.ProxyMJPEG(w, r, name)
+}
+
+func handleCameraTalk(w http.ResponseWriter, r *http.Request) {
+	name := chi.URLParam(r, "name")
+
+	// Read the PCM audio data from the request body
+	// Expected format: 16-bit PCM, mono, 8kHz sample rate
+	pcmData, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "Failed to read audio data", http.StatusBadRequest)
+		return
+	}
+
+	if len(pcmData) == 0 {
+		http.Error(w, "No audio data received", http.StatusBadRequest)
+		return
+	}
+
+	// Send audio to camera
+	err = cameraManager.PostAudio(name, pcmData)
+	if err != nil {
+		log.Printf("Failed to post audio to camera %s: %v", name, err)
+		http.Error(w, fmt.Sprintf("Failed to send audio: %v", err), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Audio sent successfully"))
+}
+
+func handleGetCameras(w http.ResponseWriter, r *http.Request) {
+	cameras := cameraManager.GetCameras()
+	cameraList := make([]map[string]string, 0)
+	for _, cam := range cameras {
+		cameraList = append(cameraList, map[string]string{
+			"name": cam.Name,
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cameraList)
+}
+
+func handleTestDoorbell(w http.ResponseWriter, r *http.Request) {
+	go wakeTablet() // Wake tablet screen first
+	wsHub.BroadcastDoorbell(appConfig.DoorbellCamera)
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("Doorbell event broadcast"))
+}
+
+func handleDoorbellWebhook(w http.ResponseWriter, r *http.Request) {
+	// Check webhook secret if configured
+	if appConfig.WebhookSecret != "" {
+		// Check Authorization header (Bearer token)
+		authHeader := r.Header.Get("Authorization")
+		if authHeader != "" {
+			if authHeader != "Bearer "+appConfig.WebhookSecret {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+		} else {
+			// Also check X-Webhook-Secret header
+			secret := r.Header.Get("X-Webhook-Secret")
+			if secret != appConfig.WebhookSecret {
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
 			}
-			devices = append(devices, ShieldDeviceConfig{
-				Name: strings.TrimSpace(parts[0]),
-				Host: strings.TrimSpace(parts[1]),
-				Port: port,
-			})
 		}
 	}
-	return devices
+
+	log.Println("Doorbell webhook triggered from Home Assistant")
+	recordJournal(journal.Entry{Kind: journal.KindDoorbell, Subject: appConfig.DoorbellCamera, To: "ring", Source: "homeassistant"})
+	ringDoorbell("homeassistant")
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("OK"))
 }
 
-// parseXboxDevices parses format: "name:host:liveid,..."
-func parseXboxDevices(s string) []XboxDeviceConfig {
-	if s == "" {
-		return nil
+// ringDoorbell wakes the tablets and shows the doorbell camera. A ring while a
+// visitor is expected logs their arrival and announces them.
+func ringDoorbell(source string) {
+	go wakeTablet() // Wake tablet screen first
+	v := visitorManager.Ring(time.Now())
+	if v == nil {
+		wsHub.BroadcastDoorbell(appConfig.DoorbellCamera)
+		return
 	}
-	var devices []XboxDeviceConfig
-	for _, entry := range strings.Split(s, ",") {
-		entry = strings.TrimSpace(entry)
-		parts := strings.SplitN(entry, ":", 3)
-		if len(parts) >= 3 {
-			devices = append(devices, XboxDeviceConfig{
-				Name:   strings.TrimSpace(parts[0]),
-				Host:   strings.TrimSpace(parts[1]),
-				LiveID: strings.TrimSpace(parts[2]),
-			})
-		}
+
+	log.Printf("Doorbell: visitor %s arrived (%s)", v.Name, source)
+	recordJournal(journal.Entry{Kind: journal.KindPresence, Subject: v.Name, To: "arrived", Source: source})
+	wsHub.Broadcast(websocket.Event{Type: "doorbell", Payload: map[string]string{
+		"camera":  appConfig.DoorbellCamera,
+		"visitor": v.Name,
+	}})
+	if _, err := announcements.Announce(announce.Request{Message: v.Message(), Chime: v.Chime, Priority: announce.PriorityHigh, Source: "doorbell"}); err != nil {
+		log.Printf("Error announcing visitor %s: %v", v.Name, err)
 	}
-	return devices
 }
 
-// parsePS5Devices parses format: "name:deviceid:psnaccount,..."
-func parsePS5Devices(s string) []PS5DeviceConfig {
-	if s == "" {
-		return nil
+// Arrival cameras
+
+// initArrivalCameras shows cameras picture-in-picture on kiosks for Frigate
+// detections (cars by default) and presence/geofence arrivals
+func initArrivalCameras(cfg Config) {
+	arrivalCameras = arrivalcam.NewManager(dataStore.Doc("settings", "arrival_cameras", ""), cfg.Timezone)
+
+	if mqttClient != nil && cfg.FrigateHost != "" {
+		mqttClient.Subscribe("frigate/events", func(topic string, payload []byte) {
+			var ev struct {
+				Type  string `json:"type"`
+				After struct {
+					Camera string `json:"camera"`
+					Label  string `json:"label"`
+				} `json:"after"`
+			}
+			if err := json.Unmarshal(payload, &ev); err != nil || ev.Type != "new" {
+				return
+			}
+			showArrivalCameras(arrivalCameras.Detection(ev.After.Camera, ev.After.Label, time.Now()))
+		})
 	}
-	var devices []PS5DeviceConfig
-	for _, entry := range strings.Split(s, ",") {
-		entry = strings.TrimSpace(entry)
-		parts := strings.SplitN(entry, ":", 3)
-		if len(parts) >= 2 {
-			psnAccount := ""
-			if len(parts) >= 3 {
-				psnAccount = strings.TrimSpace(parts[2])
+	if presenceTracker != nil {
+		presenceTracker.OnChange(func(c presence.Change) {
+			if c.Kind == presence.KindArrival {
+				showArrivalCameras(arrivalCameras.Arrival(c.Person.Name, c.Time))
 			}
-			devices = append(devices, PS5DeviceConfig{
-				Name:       strings.TrimSpace(parts[0]),
-				DeviceID:   strings.TrimSpace(parts[1]),
-				PSNAccount: psnAccount,
-			})
-		}
+		})
 	}
-	return devices
 }
 
-// ========== Entertainment Device Handlers ==========
-
-// handleGetEntertainmentDevices returns all configured entertainment devices
-func handleGetEntertainmentDevices(w http.ResponseWriter, r *http.Request) {
-	devices := map[string]interface{}{
-		"sony":   []interface{}{},
-		"shield": []interface{}{},
-		"xbox":   []interface{}{},
-		"ps5":    []interface{}{},
+// showArrivalCameras sends each camera to its kiosk (or every kiosk)
+func showArrivalCameras(shows []arrivalcam.Show) {
+	if len(shows) == 0 {
+		return
 	}
-
-	if sonyManager != nil {
-		devices["sony"] = sonyManager.GetAllStates()
+	go wakeTablet()
+	for _, show := range shows {
+		log.Printf("Arrival camera: showing %s (%s)", show.Camera, show.Reason)
+		event := websocket.Event{Type: "camera_pip", Payload: show}
+		if show.Kiosk == "" {
+			wsHub.Broadcast(event)
+		} else if wsHub.SendTo(show.Kiosk, event) == 0 {
+			log.Printf("Warning: Arrival camera: kiosk %q isn't connected", show.Kiosk)
+		}
 	}
-	if shieldManager != nil {
-		devices["shield"] = shieldManager.GetAllStates()
+}
+
+// handleGetArrivalCameras returns the arrival camera rules and the connected kiosks
+func handleGetArrivalCameras(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rules":  arrivalCameras.Rules(),
+		"kiosks": wsHub.Kiosks(),
+	})
+}
+
+func handleUpdateArrivalCameras(w http.ResponseWriter, r *http.Request) {
+	var rules []arrivalcam.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
 	}
-	if xboxManager != nil {
-		devices["xbox"] = xboxManager.GetAllStates()
+	for _, rule := range rules {
+		if cameraManager.GetCamera(rule.Camera) == nil {
+			http.Error(w, "Unknown camera: "+rule.Camera, http.StatusBadRequest)
+			return
+		}
 	}
-	if ps5Manager != nil {
-		devices["ps5"] = ps5Manager.GetAllStates()
+	if err := arrivalCameras.Update(rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(devices)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"rules":  arrivalCameras.Rules(),
+		"kiosks": wsHub.Kiosks(),
+	})
 }
 
-// ========== Sony Handlers ==========
+// Hue API handlers
 
-func handleGetSonyDevices(w http.ResponseWriter, r *http.Request) {
-	if sonyManager == nil {
-		http.Error(w, "Sony devices not configured", http.StatusNotFound)
+func handleGetHueRooms(w http.ResponseWriter, r *http.Request) {
+	if hueClient == nil {
+		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(sonyManager.GetAllStates())
-}
 
-func handleGetSonyState(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	if sonyManager == nil {
-		http.Error(w, "Sony devices not configured", http.StatusNotFound)
-		return
+	// Served from the event stream cache; REST (briefly cached) while it's disconnected.
+	// ?refresh=true skips both caches and reads the bridge.
+	refresh := r.URL.Query().Get("refresh") == "true"
+	if hueEvents != nil && !refresh {
+		if rooms, ok := hueEvents.Rooms(); ok {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(rooms)
+			return
+		}
 	}
-	device := sonyManager.GetDevice(name)
-	if device == nil {
-		http.Error(w, "Device not found", http.StatusNotFound)
+
+	var rooms []*hue.Room
+	var err error
+	if refresh {
+		rooms, err = hueRoomsCache.Refresh("", hueClient.GetRoomsWithDetails)
+	} else {
+		rooms, err = hueRoomsCache.Get("", hueClient.GetRoomsWithDetails)
+	}
+	if err != nil {
+		log.Printf("Error fetching Hue rooms: %v", err)
+		http.Error(w, "Failed to fetch Hue rooms: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(device.GetState())
+	json.NewEncoder(w).Encode(rooms)
 }
 
-func handleSonyPower(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	if sonyManager == nil {
-		http.Error(w, "Sony devices not configured", http.StatusNotFound)
-		return
-	}
-	device := sonyManager.GetDevice(name)
-	if device == nil {
-		http.Error(w, "Device not found", http.StatusNotFound)
+func handleToggleHueLight(w http.ResponseWriter, r *http.Request) {
+	if hueClient == nil {
+		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	var req struct {
-		Action string `json:"action"` // "on", "off", "toggle"
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "Missing light ID", http.StatusBadRequest)
 		return
 	}
 
-	var err error
-	switch req.Action {
-	case "on":
-		err = device.PowerOn()
-	case "off":
-		err = device.PowerOff()
-	case "toggle":
-		status, _ := device.GetPowerStatus()
-		if status != nil && status.Status == "active" {
-			err = device.PowerOff()
-		} else {
-			err = device.PowerOn()
-		}
-	default:
-		http.Error(w, "Invalid action", http.StatusBadRequest)
+	if err := hueClient.ToggleLight(id); err != nil {
+		log.Printf("Error toggling Hue light %s: %v", id, err)
+		http.Error(w, "Failed to toggle light: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	recordAction(r, actions.KindLight, id, "toggle")
 
+	// Return updated light state
+	light, err := hueClient.GetLight(id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	json.NewEncoder(w).Encode(light)
 }
 
-func handleSonyVolume(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	if sonyManager == nil {
-		http.Error(w, "Sony devices not configured", http.StatusNotFound)
+type SetHueBrightnessRequest struct {
+	Brightness int `json:"brightness"` // 1-254
+}
+
+func handleSetHueLightBrightness(w http.ResponseWriter, r *http.Request) {
+	if hueClient == nil {
+		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
 		return
 	}
-	device := sonyManager.GetDevice(name)
-	if device == nil {
-		http.Error(w, "Device not found", http.StatusNotFound)
+
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "Missing light ID", http.StatusBadRequest)
 		return
 	}
 
-	var req struct {
-		Action string `json:"action"` // "set", "up", "down"
-		Value  int    `json:"value"`
-	}
+	var req SetHueBrightnessRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	var err error
-	switch req.Action {
-	case "set":
-		err = device.SetVolume(req.Value)
-	case "up":
-		step := req.Value
-		if step <= 0 {
-			step = 1
-		}
-		err = device.VolumeUp(step)
-	case "down":
-		step := req.Value
-		if step <= 0 {
-			step = 1
-		}
-		err = device.VolumeDown(step)
-	default:
-		http.Error(w, "Invalid action", http.StatusBadRequest)
+	if err := hueClient.SetLightBrightness(id, req.Brightness); err != nil {
+		log.Printf("Error setting Hue light %s brightness: %v", id, err)
+		http.Error(w, "Failed to set brightness: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	light, err := hueClient.GetLight(id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	json.NewEncoder(w).Encode(light)
 }
 
-func handleSonyMute(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	if sonyManager == nil {
-		http.Error(w, "Sony devices not configured", http.StatusNotFound)
-		return
+// SetHueColorRequest sets a color by hex RGB or by hue/saturation
+type SetHueColorRequest struct {
+	Hex        string   `json:"hex,omitempty"`        // "#ff8800"
+	Hue        *float64 `json:"hue,omitempty"`        // 0-360 degrees
+	Saturation *float64 `json:"saturation,omitempty"` // 0-100 percent
+}
+
+// xy converts the requested color to CIE xy (clamped per light by the hue client)
+func (req SetHueColorRequest) xy() (hue.XY, error) {
+	if req.Hex != "" {
+		r, g, b, err := hue.ParseHexColor(req.Hex)
+		if err != nil {
+			return hue.XY{}, err
+		}
+		return hue.RGBToXY(r, g, b), nil
 	}
-	device := sonyManager.GetDevice(name)
-	if device == nil {
-		http.Error(w, "Device not found", http.StatusNotFound)
+	if req.Hue == nil || req.Saturation == nil {
+		return hue.XY{}, fmt.Errorf("hex or hue and saturation are required")
+	}
+	if *req.Hue < 0 || *req.Hue > 360 {
+		return hue.XY{}, fmt.Errorf("hue must be between 0 and 360")
+	}
+	if *req.Saturation < 0 || *req.Saturation > 100 {
+		return hue.XY{}, fmt.Errorf("saturation must be between 0 and 100")
+	}
+	return hue.HSToXY(*req.Hue, *req.Saturation/100), nil
+}
+
+func handleSetHueLightColor(w http.ResponseWriter, r *http.Request) {
+	if hueClient == nil {
+		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	var req struct {
-		Action string `json:"action"` // "on", "off", "toggle"
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "Missing light ID", http.StatusBadRequest)
+		return
 	}
+
+	var req SetHueColorRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	xy, err := req.xy()
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var err error
-	switch req.Action {
-	case "on":
-		err = device.SetMute(true)
-	case "off":
-		err = device.SetMute(false)
-	case "toggle":
-		err = device.ToggleMute()
-	default:
-		http.Error(w, "Invalid action", http.StatusBadRequest)
+	if err := hueClient.SetLightXY(id, xy); err != nil {
+		log.Printf("Error setting Hue light %s color: %v", id, err)
+		http.Error(w, "Failed to set color: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	light, err := hueClient.GetLight(id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	json.NewEncoder(w).Encode(light)
+}
+
+type SetHueCTRequest struct {
+	CT int `json:"ct"` // Mireds, 153 (cool) - 500 (warm)
 }
 
-func handleSonyInput(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	if sonyManager == nil {
-		http.Error(w, "Sony devices not configured", http.StatusNotFound)
+func handleSetHueLightCT(w http.ResponseWriter, r *http.Request) {
+	if hueClient == nil {
+		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
 		return
 	}
-	device := sonyManager.GetDevice(name)
-	if device == nil {
-		http.Error(w, "Device not found", http.StatusNotFound)
+
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "Missing light ID", http.StatusBadRequest)
 		return
 	}
 
-	var req struct {
-		Input string `json:"input"` // HDMI port number or URI
-	}
+	var req SetHueCTRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	var err error
-	// Handle named inputs, HDMI port numbers, or raw URIs
-	switch strings.ToLower(req.Input) {
-	case "tv":
-		err = device.SetTV()
-	case "bluetooth", "bt":
-		err = device.SetBluetooth()
-	case "analog", "line":
-		err = device.SetAnalog()
-	default:
-		// Check if it's a simple HDMI port number
-		if port, parseErr := strconv.Atoi(req.Input); parseErr == nil {
-			err = device.SetHDMI(port)
-		} else {
-			// Assume it's a raw URI
-			err = device.SetInput(req.Input)
-		}
+	if err := hueClient.SetLightColorTemp(id, req.CT); err != nil {
+		log.Printf("Error setting Hue light %s color temperature: %v", id, err)
+		http.Error(w, "Failed to set color temperature: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
+	light, err := hueClient.GetLight(id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	json.NewEncoder(w).Encode(light)
 }
 
-// ========== Shield Handlers ==========
+type SetHueEffectRequest struct {
+	Effect string `json:"effect"` // candle, fire (fireplace), prism, sparkle, ... or no_effect
+}
 
-func handleGetShieldDevices(w http.ResponseWriter, r *http.Request) {
-	if shieldManager == nil {
-		http.Error(w, "Shield devices not configured", http.StatusNotFound)
+func handleGetHueSensors(w http.ResponseWriter, r *http.Request) {
+	if hueClient == nil {
+		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(shieldManager.GetAllStates())
-}
 
-func handleGetShieldState(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	if shieldManager == nil {
-		http.Error(w, "Shield devices not configured", http.StatusNotFound)
-		return
+	if hueSensors != nil {
+		if sensors, ok := hueSensors.Sensors(); ok {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(sensors)
+			return
+		}
 	}
-	device := shieldManager.GetDevice(name)
-	if device == nil {
-		http.Error(w, "Device not found", http.StatusNotFound)
+
+	sensors, err := hueClient.GetSensors()
+	if err != nil {
+		log.Printf("Error getting Hue sensors: %v", err)
+		http.Error(w, "Failed to get sensors: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(device.GetState())
+	json.NewEncoder(w).Encode(sensors)
 }
 
-func handleShieldPower(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	if shieldManager == nil {
-		http.Error(w, "Shield devices not configured", http.StatusNotFound)
-		return
+// hueRooms returns rooms from the event stream cache, or the bridge while it's disconnected
+func hueRooms() ([]*hue.Room, error) {
+	if hueEvents != nil {
+		if rooms, ok := hueEvents.Rooms(); ok {
+			return rooms, nil
+		}
 	}
-	device := shieldManager.GetDevice(name)
-	if device == nil {
-		http.Error(w, "Device not found", http.StatusNotFound)
-		return
+	return hueRoomsCache.Get("", hueClient.GetRoomsWithDetails)
+}
+
+// applyAdaptiveLighting sets color temperature and brightness on the lights of a
+// room that are on. Lights showing a color (rather than a white) are left alone,
+// so colored scenes aren't washed out.
+func applyAdaptiveLighting(room string, v lighting.Values) error {
+	transition := int(lighting.Transition / time.Second)
+
+	if groupID, ok := strings.CutPrefix(room, "hue:"); ok {
+		if hueClient == nil {
+			return fmt.Errorf("Hue bridge not configured")
+		}
+		rooms, err := hueRooms()
+		if err != nil {
+			return err
+		}
+		for _, rm := range rooms {
+			if rm.ID != groupID {
+				continue
+			}
+			for _, light := range rm.Lights {
+				if !light.State.On || (light.State.ColorMode != "ct" && light.State.ColorMode != "") {
+					continue
+				}
+				state := map[string]interface{}{
+					"bri":            (v.Brightness*254 + 50) / 100,
+					"transitiontime": transition * 10, // deciseconds
+				}
+				if light.Capabilities.Control.CT.Max > 0 {
+					state["ct"] = 1000000 / v.Kelvin
+				}
+				if err := hueClient.SetLightState(light.ID, state); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return fmt.Errorf("Hue room %s not found", groupID)
 	}
 
-	var req struct {
-		Action string `json:"action"` // "wake", "sleep"
+	if haClient == nil {
+		return fmt.Errorf("HA not configured")
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+	entity, err := haClient.GetState(room)
+	if err != nil {
+		return err
+	}
+	if entity.State != "on" {
+		return nil
+	}
+	if mode, _ := entity.Attributes["color_mode"].(string); mode != "" && mode != "color_temp" && mode != "brightness" && mode != "onoff" {
+		return nil
 	}
+	_, err = haClient.CallServiceData("light", "turn_on", map[string]interface{}{
+		"entity_id":         room,
+		"color_temp_kelvin": v.Kelvin,
+		"brightness_pct":    v.Brightness,
+		"transition":        transition,
+	})
+	return err
+}
 
-	var err error
-	switch req.Action {
-	case "wake":
-		err = device.WakeUp()
-	case "sleep":
-		err = device.Sleep()
-	default:
-		http.Error(w, "Invalid action", http.StatusBadRequest)
+// AdaptiveRoom is a room adaptive lighting can run in
+type AdaptiveRoom struct {
+	ID      string `json:"id"` // hue:<group ID> or light.* entity ID
+	Name    string `json:"name"`
+	Enabled bool   `json:"enabled"`
+}
+
+// AdaptiveLightingResponse is the response of GET /api/lighting/adaptive
+type AdaptiveLightingResponse struct {
+	Current  lighting.Values   `json:"current"`
+	Sun      lighting.SunDay   `json:"sun"` // Today's sun times the values follow
+	Settings lighting.Settings `json:"settings"`
+	Rooms    []AdaptiveRoom    `json:"rooms"`
+}
+
+// handleGetAdaptiveLighting returns the current values, ranges and rooms
+func handleGetAdaptiveLighting(w http.ResponseWriter, r *http.Request) {
+	if adaptiveLighting == nil {
+		http.Error(w, "Adaptive lighting not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	resp := AdaptiveLightingResponse{
+		Current:  adaptiveLighting.Current(),
+		Sun:      lighting.SunTimes(time.Now(), appConfig.WeatherLat, appConfig.WeatherLon, appConfig.Timezone),
+		Settings: adaptiveLighting.Settings(),
+		Rooms:    []AdaptiveRoom{},
+	}
+	if hueClient != nil {
+		rooms, err := hueRooms()
+		if err != nil {
+			log.Printf("Error fetching Hue rooms for adaptive lighting: %v", err)
+		}
+		for _, rm := range rooms {
+			id := "hue:" + rm.ID
+			resp.Rooms = append(resp.Rooms, AdaptiveRoom{ID: id, Name: rm.Name, Enabled: adaptiveLighting.Enabled(id)})
+		}
+	}
+	for _, id := range appConfig.AdaptiveLights {
+		name := id
+		if haClient != nil {
+			if entity, err := haClient.GetState(id); err == nil {
+				if friendly, ok := entity.Attributes["friendly_name"].(string); ok {
+					name = friendly
+				}
+			}
+		}
+		resp.Rooms = append(resp.Rooms, AdaptiveRoom{ID: id, Name: name, Enabled: adaptiveLighting.Enabled(id)})
 	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	json.NewEncoder(w).Encode(resp)
 }
 
-func handleShieldNavigate(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	if shieldManager == nil {
-		http.Error(w, "Shield devices not configured", http.StatusNotFound)
-		return
-	}
-	device := shieldManager.GetDevice(name)
-	if device == nil {
-		http.Error(w, "Device not found", http.StatusNotFound)
+// UpdateAdaptiveLightingRequest is the body for PUT /api/lighting/adaptive
+type UpdateAdaptiveLightingRequest struct {
+	MinKelvin     int `json:"minKelvin"`
+	MaxKelvin     int `json:"maxKelvin"`
+	MinBrightness int `json:"minBrightness"`
+	MaxBrightness int `json:"maxBrightness"`
+}
+
+// handleUpdateAdaptiveLighting changes the color temperature and brightness ranges
+func handleUpdateAdaptiveLighting(w http.ResponseWriter, r *http.Request) {
+	if adaptiveLighting == nil {
+		http.Error(w, "Adaptive lighting not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	var req struct {
-		Action string `json:"action"` // "up", "down", "left", "right", "select", "back", "home", "menu"
+	current := adaptiveLighting.Settings()
+	req := UpdateAdaptiveLightingRequest{
+		MinKelvin:     current.MinKelvin,
+		MaxKelvin:     current.MaxKelvin,
+		MinBrightness: current.MinBrightness,
+		MaxBrightness: current.MaxBrightness,
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-
-	var err error
-	switch req.Action {
-	case "up":
-		err = device.Up()
-	case "down":
-		err = device.Down()
-	case "left":
-		err = device.Left()
-	case "right":
-		err = device.Right()
-	case "select", "enter":
-		err = device.Select()
-	case "back":
-		err = device.Back()
-	case "home":
-		err = device.Home()
-	case "menu":
-		err = device.Menu()
-	default:
-		http.Error(w, "Invalid action", http.StatusBadRequest)
+	if err := adaptiveLighting.UpdateRanges(req.MinKelvin, req.MaxKelvin, req.MinBrightness, req.MaxBrightness); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	json.NewEncoder(w).Encode(adaptiveLighting.Settings())
 }
 
-func handleShieldMedia(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	if shieldManager == nil {
-		http.Error(w, "Shield devices not configured", http.StatusNotFound)
+// SetAdaptiveRoomRequest is the body for PUT /api/lighting/adaptive/{room}
+type SetAdaptiveRoomRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// handleSetAdaptiveRoom enables or disables adaptive lighting in a room
+func handleSetAdaptiveRoom(w http.ResponseWriter, r *http.Request) {
+	if adaptiveLighting == nil {
+		http.Error(w, "Adaptive lighting not configured", http.StatusServiceUnavailable)
 		return
 	}
-	device := shieldManager.GetDevice(name)
-	if device == nil {
-		http.Error(w, "Device not found", http.StatusNotFound)
+
+	room := chi.URLParam(r, "room")
+	if !strings.HasPrefix(room, "hue:") && !slices.Contains(appConfig.AdaptiveLights, room) {
+		http.Error(w, "Unknown room: "+room, http.StatusBadRequest)
 		return
 	}
 
-	var req struct {
-		Action string `json:"action"` // "play", "pause", "playpause", "stop", "next", "previous", "rewind", "forward"
-	}
+	var req SetAdaptiveRoomRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-
-	var err error
-	switch req.Action {
-	case "play":
-		err = device.Play()
-	case "pause":
-		err = device.Pause()
-	case "playpause":
-		err = device.PlayPause()
-	case "stop":
-		err = device.Stop()
-	case "next":
-		err = device.Next()
-	case "previous":
-		err = device.Previous()
-	case "rewind":
-		err = device.Rewind()
-	case "forward":
-		err = device.FastForward()
-	case "volumeup":
-		err = device.VolumeUp()
-	case "volumedown":
-		err = device.VolumeDown()
-	case "mute":
-		err = device.Mute()
-	default:
-		http.Error(w, "Invalid action", http.StatusBadRequest)
+	if err := adaptiveLighting.SetEnabled(room, req.Enabled); err != nil {
+		log.Printf("Error updating adaptive lighting: %v", err)
+		http.Error(w, "Failed to update adaptive lighting: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	service := "adaptive_off"
+	if req.Enabled {
+		service = "adaptive_on"
+	}
+	recordAction(r, actions.KindEntity, room, service)
 
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AdaptiveRoom{ID: room, Enabled: req.Enabled})
+}
+
+// shadingTemperature reads a temperature sensor for the shading rules
+func shadingTemperature(entityID string) (float64, error) {
+	entity, err := haClient.GetState(entityID)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return 0, err
+	}
+	if t, ok := entity.Attributes["current_temperature"].(float64); ok {
+		return t, nil // climate.*
+	}
+	return strconv.ParseFloat(entity.State, 64)
+}
+
+// applyShading moves a cover to its shading position, or opens it
+func applyShading(rule shading.Rule, shade bool) error {
+	if !shade {
+		if err := haClient.CallService("cover", "open_cover", rule.Cover); err != nil {
+			return err
+		}
+		recordJournal(journal.Entry{Kind: journal.KindAction, Subject: rule.Cover, To: "open", Source: "shading"})
+		return nil
+	}
+	if _, err := haClient.CallServiceData("cover", "set_cover_position", map[string]interface{}{
+		"entity_id": rule.Cover,
+		"position":  rule.Position,
+	}); err != nil {
+		return err
+	}
+	if rule.Tilt != nil {
+		if _, err := haClient.CallServiceData("cover", "set_cover_tilt_position", map[string]interface{}{
+			"entity_id":     rule.Cover,
+			"tilt_position": *rule.Tilt,
+		}); err != nil {
+			return err
+		}
+	}
+	recordJournal(journal.Entry{Kind: journal.KindAction, Subject: rule.Cover, To: "shade", Source: "shading"})
+	return nil
+}
+
+// ShadingResponse is the response of GET /api/shading
+type ShadingResponse struct {
+	Sun      lighting.SunPosition `json:"sun"`
+	Rules    []shading.Rule       `json:"rules"`
+	Statuses []shading.Status     `json:"statuses"`
+}
+
+func handleGetShading(w http.ResponseWriter, r *http.Request) {
+	if shadingManager == nil {
+		http.Error(w, "Shading not configured", http.StatusServiceUnavailable)
 		return
 	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	json.NewEncoder(w).Encode(ShadingResponse{
+		Sun:      lighting.Sun(time.Now(), appConfig.WeatherLat, appConfig.WeatherLon),
+		Rules:    shadingManager.Rules(),
+		Statuses: shadingManager.Statuses(),
+	})
 }
 
-func handleShieldApp(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	if shieldManager == nil {
-		http.Error(w, "Shield devices not configured", http.StatusNotFound)
+func handleUpdateShadingRules(w http.ResponseWriter, r *http.Request) {
+	if shadingManager == nil {
+		http.Error(w, "Shading not configured", http.StatusServiceUnavailable)
 		return
 	}
-	device := shieldManager.GetDevice(name)
-	if device == nil {
-		http.Error(w, "Device not found", http.StatusNotFound)
+
+	var rules []shading.Rule
+	if err := json.NewDecoder(r.Body).Decode(&rules); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if err := shadingManager.Update(rules); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	go shadingManager.Tick(time.Now())
 
-	var req struct {
-		Action  string `json:"action"`  // "launch", "stop"
-		Package string `json:"package"` // App name or package
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shadingManager.Rules())
+}
+
+// heatingForecast returns the current outdoor temperature followed by the
+// upcoming forecast for the heating curve
+func heatingForecast() []heating.Reading {
+	data := weatherClient.GetWeather()
+	if data == nil {
+		return nil
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	now := time.Now()
+	readings := []heating.Reading{{Time: now, Temp: data.Current.Temp}}
+	for _, h := range data.Hourly {
+		if t := time.Unix(h.Time, 0); t.After(now) {
+			readings = append(readings, heating.Reading{Time: t, Temp: h.Temp})
+		}
+	}
+	return readings
+}
+
+// HeatingCurveResponse is the response of GET /api/heating/curve
+type HeatingCurveResponse struct {
+	Settings heating.Settings   `json:"settings"`
+	Preview  []heating.ZonePlan `json:"preview"`
+}
+
+// handleGetHeatingCurve returns the zones and their planned setpoints
+func handleGetHeatingCurve(w http.ResponseWriter, r *http.Request) {
+	if heatingCurve == nil {
+		http.Error(w, "Heating curve not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(HeatingCurveResponse{
+		Settings: heatingCurve.Settings(),
+		Preview:  heatingCurve.Preview(time.Now()),
+	})
+}
+
+// handleUpdateHeatingCurve saves the zones and applies the new setpoints
+func handleUpdateHeatingCurve(w http.ResponseWriter, r *http.Request) {
+	if heatingCurve == nil {
+		http.Error(w, "Heating curve not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	var err error
-	switch req.Action {
-	case "launch":
-		err = device.LaunchApp(req.Package)
-	case "stop":
-		err = device.ForceStopApp(req.Package)
-	default:
-		http.Error(w, "Invalid action", http.StatusBadRequest)
+	var settings heating.Settings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := heatingCurve.Update(settings); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	go heatingCurve.Tick(time.Now())
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	json.NewEncoder(w).Encode(heatingCurve.Settings())
 }
 
-// ========== Xbox Handlers ==========
-
-func handleGetXboxDevices(w http.ResponseWriter, r *http.Request) {
-	if xboxManager == nil {
-		http.Error(w, "Xbox devices not configured", http.StatusNotFound)
+// handleGetHeatingPreview returns each zone's setpoints for the next 24 hours
+func handleGetHeatingPreview(w http.ResponseWriter, r *http.Request) {
+	if heatingCurve == nil {
+		http.Error(w, "Heating curve not configured", http.StatusServiceUnavailable)
 		return
 	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(xboxManager.GetAllStates())
+	json.NewEncoder(w).Encode(heatingCurve.Preview(time.Now()))
 }
 
-func handleGetXboxState(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	if xboxManager == nil {
-		http.Error(w, "Xbox devices not configured", http.StatusNotFound)
+func handleGetHueLightEffect(w http.ResponseWriter, r *http.Request) {
+	if hueClient == nil {
+		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
 		return
 	}
-	device := xboxManager.GetDevice(name)
-	if device == nil {
-		http.Error(w, "Device not found", http.StatusNotFound)
+
+	effects, err := hueClient.GetLightEffects(chi.URLParam(r, "id"))
+	if err != nil {
+		log.Printf("Error getting Hue light effects: %v", err)
+		http.Error(w, "Failed to get effects: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(device.GetState())
+	json.NewEncoder(w).Encode(effects)
 }
 
-func handleXboxPower(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	if xboxManager == nil {
-		http.Error(w, "Xbox devices not configured", http.StatusNotFound)
+func handleSetHueLightEffect(w http.ResponseWriter, r *http.Request) {
+	if hueClient == nil {
+		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
 		return
 	}
-	device := xboxManager.GetDevice(name)
-	if device == nil {
-		http.Error(w, "Device not found", http.StatusNotFound)
+
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "Missing light ID", http.StatusBadRequest)
 		return
 	}
 
-	var req struct {
-		Action string `json:"action"` // "on", "off"
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	var req SetHueEffectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Effect == "" {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	var err error
-	switch req.Action {
-	case "on":
-		err = device.PowerOn()
-	case "off":
-		err = xboxManager.PowerOffViaREST(name)
-	default:
-		http.Error(w, "Invalid action", http.StatusBadRequest)
+	if err := hueClient.SetLightEffect(id, req.Effect); err != nil {
+		log.Printf("Error setting Hue light %s effect: %v", id, err)
+		http.Error(w, "Failed to set effect: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	effects, err := hueClient.GetLightEffects(id)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	json.NewEncoder(w).Encode(effects)
 }
 
-func handleXboxInput(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	if xboxManager == nil {
-		http.Error(w, "Xbox devices not configured", http.StatusNotFound)
+func handleToggleHueGroup(w http.ResponseWriter, r *http.Request) {
+	if hueClient == nil {
+		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	var req struct {
-		Button string `json:"button"` // Button name
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "Missing group ID", http.StatusBadRequest)
+		return
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+
+	if err := hueClient.ToggleGroup(id); err != nil {
+		log.Printf("Error toggling Hue group %s: %v", id, err)
+		http.Error(w, "Failed to toggle group: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	recordAction(r, actions.KindGroup, id, "toggle")
 
-	if err := xboxManager.SendButton(name, req.Button); err != nil {
+	// Return updated rooms
+	rooms, err := hueClient.GetRoomsWithDetails()
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+	json.NewEncoder(w).Encode(rooms)
 }
 
-func handleXboxMedia(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	if xboxManager == nil {
-		http.Error(w, "Xbox devices not configured", http.StatusNotFound)
+func handleSetHueGroupBrightness(w http.ResponseWriter, r *http.Request) {
+	if hueClient == nil {
+		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	var req struct {
-		Action string `json:"action"` // "play", "pause", "playpause", "stop", "next", "previous"
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "Missing group ID", http.StatusBadRequest)
+		return
 	}
+
+	var req SetHueBrightnessRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	var err error
-	switch req.Action {
-	case "play":
-		err = xboxManager.Play(name)
-	case "pause":
-		err = xboxManager.Pause(name)
-	case "playpause":
-		err = xboxManager.PlayPause(name)
-	case "stop":
-		err = xboxManager.Stop(name)
-	case "next":
-		err = xboxManager.Next(name)
-	case "previous":
-		err = xboxManager.Previous(name)
-	default:
-		http.Error(w, "Invalid action", http.StatusBadRequest)
+	if err := hueClient.SetGroupBrightness(id, req.Brightness); err != nil {
+		log.Printf("Error setting Hue group %s brightness: %v", id, err)
+		http.Error(w, "Failed to set brightness: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	rooms, err := hueClient.GetRoomsWithDetails()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
-}
-
-// ========== PS5 Handlers ==========
 
-func handleGetPS5Devices(w http.ResponseWriter, r *http.Request) {
-	if ps5Manager == nil {
-		http.Error(w, "PS5 devices not configured", http.StatusNotFound)
-		return
-	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(ps5Manager.GetAllStates())
+	json.NewEncoder(w).Encode(rooms)
 }
 
-func handleGetPS5State(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	if ps5Manager == nil {
-		http.Error(w, "PS5 devices not configured", http.StatusNotFound)
-		return
-	}
-	state := ps5Manager.GetState(name)
-	if state == nil {
-		http.Error(w, "Device not found", http.StatusNotFound)
+func handleSetHueGroupColor(w http.ResponseWriter, r *http.Request) {
+	if hueClient == nil {
+		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(state)
-}
 
-func handlePS5Power(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	if ps5Manager == nil {
-		http.Error(w, "PS5 devices not configured", http.StatusNotFound)
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "Missing group ID", http.StatusBadRequest)
 		return
 	}
 
-	var req struct {
-		Action string `json:"action"` // "on", "off", "toggle"
-	}
+	var req SetHueColorRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	xy, err := req.xy()
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	var err error
-	switch req.Action {
-	case "on":
-		err = ps5Manager.PowerOn(name)
-	case "off":
-		err = ps5Manager.PowerOff(name)
-	case "toggle":
-		err = ps5Manager.TogglePower(name)
-	default:
-		http.Error(w, "Invalid action", http.StatusBadRequest)
+	if err := hueClient.SetGroupXY(id, xy); err != nil {
+		log.Printf("Error setting Hue group %s color: %v", id, err)
+		http.Error(w, "Failed to set color: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	rooms, err := hueClient.GetRoomsWithDetails()
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
-}
-
-// Template functions
-func formatDate(t time.Time) string {
-	return t.In(appConfig.Timezone).Format("Mon, Jan 2")
-}
-
-func formatTime(t time.Time) string {
-	return t.In(appConfig.Timezone).Format("3:04 PM")
-}
-
-func formatDateTime(t time.Time) string {
-	return t.In(appConfig.Timezone).Format("Mon, Jan 2 at 3:04 PM")
-}
 
-func isToday(t time.Time) bool {
-	now := time.Now().In(appConfig.Timezone)
-	local := t.In(appConfig.Timezone)
-	return local.Year() == now.Year() && local.YearDay() == now.YearDay()
-}
-
-func isTomorrow(t time.Time) bool {
-	tomorrow := time.Now().In(appConfig.Timezone).AddDate(0, 0, 1)
-	local := t.In(appConfig.Timezone)
-	return local.Year() == tomorrow.Year() && local.YearDay() == tomorrow.YearDay()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rooms)
 }
 
-func dayName(t time.Time) string {
-	local := t.In(appConfig.Timezone)
-	if isToday(t) {
-		return "Today"
-	}
-	if isTomorrow(t) {
-		return "Tomorrow"
+func handleSetHueGroupCT(w http.ResponseWriter, r *http.Request) {
+	if hueClient == nil {
+		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
+		return
 	}
-	return fmt.Sprintf("%s, %s %d", local.Weekday().String(), local.Month().String()[:3], local.Day())
-}
 
-// Tasks API handlers
-
-func handleGetTasks(w http.ResponseWriter, r *http.Request) {
-	if tasksClient == nil || !tasksClient.IsAuthorized() {
-		http.Error(w, "Tasks not configured or not authorized", http.StatusServiceUnavailable)
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "Missing group ID", http.StatusBadRequest)
 		return
 	}
 
-	listID := r.URL.Query().Get("listId")
-	tasksList, err := tasksClient.GetTasks(r.Context(), listID)
-	if err != nil {
-		log.Printf("Error getting tasks: %v", err)
-		http.Error(w, "Failed to get tasks: "+err.Error(), http.StatusInternalServerError)
+	var req SetHueCTRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(tasksList)
-}
-
-func handleGetTaskLists(w http.ResponseWriter, r *http.Request) {
-	if tasksClient == nil || !tasksClient.IsAuthorized() {
-		http.Error(w, "Tasks not configured or not authorized", http.StatusServiceUnavailable)
+	if err := hueClient.SetGroupColorTemp(id, req.CT); err != nil {
+		log.Printf("Error setting Hue group %s color temperature: %v", id, err)
+		http.Error(w, "Failed to set color temperature: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	lists, err := tasksClient.GetTaskLists(r.Context())
+	rooms, err := hueClient.GetRoomsWithDetails()
 	if err != nil {
-		log.Printf("Error getting task lists: %v", err)
-		http.Error(w, "Failed to get task lists: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(lists)
-}
-
-type CreateTaskRequest struct {
-	ListID string     `json:"listId"`
-	Title  string     `json:"title"`
-	Notes  string     `json:"notes"`
-	Due    *time.Time `json:"due,omitempty"`
+	json.NewEncoder(w).Encode(rooms)
 }
-
-func handleCreateTask(w http.ResponseWriter, r *http.Request) {
-	if tasksClient == nil || !tasksClient.IsAuthorized() {
-		http.Error(w, "Tasks not configured or not authorized", http.StatusServiceUnavailable)
+
+func handleActivateHueScene(w http.ResponseWriter, r *http.Request) {
+	if hueClient == nil {
+		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	var req CreateTaskRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "Missing scene ID", http.StatusBadRequest)
 		return
 	}
 
-	if req.Title == "" {
-		http.Error(w, "Title is required", http.StatusBadRequest)
+	// Optional body: {"dynamic": true, "speed": 0.3, "brightness": 60, "duration": 2000}
+	var opts hue.SceneOptions
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := hueClient.ActivateSceneWithOptions(id, opts); err != nil {
+		log.Printf("Error activating Hue scene %s: %v", id, err)
+		http.Error(w, "Failed to activate scene: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	recordAction(r, actions.KindScene, id, "activate")
 
-	task, err := tasksClient.CreateTask(r.Context(), req.ListID, req.Title, req.Notes, req.Due)
+	// Return updated rooms
+	rooms, err := hueClient.GetRoomsWithDetails()
 	if err != nil {
-		log.Printf("Error creating task: %v", err)
-		http.Error(w, "Failed to create task: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(task)
+	json.NewEncoder(w).Encode(rooms)
 }
 
-func handleToggleTask(w http.ResponseWriter, r *http.Request) {
-	if tasksClient == nil || !tasksClient.IsAuthorized() {
-		http.Error(w, "Tasks not configured or not authorized", http.StatusServiceUnavailable)
+func handleActivateEntertainment(w http.ResponseWriter, r *http.Request) {
+	if hueClient == nil {
+		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	listID := chi.URLParam(r, "listID")
-	taskID := chi.URLParam(r, "taskID")
-
-	task, err := tasksClient.ToggleTask(r.Context(), listID, taskID)
-	if err != nil {
-		log.Printf("Error toggling task: %v", err)
-		http.Error(w, "Failed to toggle task: "+err.Error(), http.StatusInternalServerError)
+	id := chi.URLParam(r, "id")
+	if id == "" {
+		http.Error(w, "Missing entertainment area ID", http.StatusBadRequest)
 		return
 	}
 
+	// Just select the area locally (informational only)
+	// Actual streaming control is done via Sync Box API
+	if hueStreamer != nil {
+		if err := hueStreamer.SelectArea(id); err != nil {
+			log.Printf("Error selecting entertainment area %s: %v", id, err)
+			http.Error(w, "Failed to select entertainment area: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(task)
+	json.NewEncoder(w).Encode(map[string]string{"status": "selected", "id": id})
 }
 
-func handleDeleteTask(w http.ResponseWriter, r *http.Request) {
-	if tasksClient == nil || !tasksClient.IsAuthorized() {
-		http.Error(w, "Tasks not configured or not authorized", http.StatusServiceUnavailable)
+func handleDeactivateEntertainment(w http.ResponseWriter, r *http.Request) {
+	if hueStreamer == nil {
+		http.Error(w, "Entertainment streaming not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	listID := chi.URLParam(r, "listID")
-	taskID := chi.URLParam(r, "taskID")
-
-	if err := tasksClient.DeleteTask(r.Context(), listID, taskID); err != nil {
-		log.Printf("Error deleting task: %v", err)
-		http.Error(w, "Failed to delete task: "+err.Error(), http.StatusInternalServerError)
+	if err := hueStreamer.Deactivate(); err != nil {
+		log.Printf("Error deactivating entertainment: %v", err)
+		http.Error(w, "Failed to deactivate entertainment: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "deactivated"})
 }
 
-func handleClearCompleted(w http.ResponseWriter, r *http.Request) {
-	if tasksClient == nil || !tasksClient.IsAuthorized() {
-		http.Error(w, "Tasks not configured or not authorized", http.StatusServiceUnavailable)
-		return
+func handleGetEntertainmentStatus(w http.ResponseWriter, r *http.Request) {
+	status := map[string]interface{}{
+		"streaming":  false,
+		"activeArea": "",
+		"enabled":    hueStreamer != nil,
 	}
 
-	listID := chi.URLParam(r, "listID")
-
-	if err := tasksClient.ClearCompleted(r.Context(), listID); err != nil {
-		log.Printf("Error clearing completed tasks: %v", err)
-		http.Error(w, "Failed to clear completed tasks: "+err.Error(), http.StatusInternalServerError)
-		return
+	if hueStreamer != nil {
+		status["streaming"] = hueStreamer.IsStreaming()
+		status["activeArea"] = hueStreamer.GetActiveArea()
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
 }
 
-// Weather API handler
+// Sync Box handlers
 
-func handleGetWeather(w http.ResponseWriter, r *http.Request) {
-	if weatherClient == nil {
-		http.Error(w, "Weather not configured", http.StatusServiceUnavailable)
-		return
+func handleGetSyncBoxes(w http.ResponseWriter, r *http.Request) {
+	type SyncBoxInfo struct {
+		Index int    `json:"index"`
+		Name  string `json:"name"`
+		IP    string `json:"ip"`
 	}
 
-	data := weatherClient.GetWeather()
-	if data == nil {
-		http.Error(w, "Weather data not available yet", http.StatusServiceUnavailable)
-		return
+	syncBoxMu.RLock()
+	boxes := make([]SyncBoxInfo, len(syncBoxClients))
+	for i, client := range syncBoxClients {
+		boxes[i] = SyncBoxInfo{
+			Index: i,
+			Name:  client.GetName(),
+			IP:    client.GetIP(),
+		}
 	}
+	syncBoxMu.RUnlock()
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(data)
+	json.NewEncoder(w).Encode(boxes)
 }
 
-// WebSocket handler
-func handleWebSocket(w http.ResponseWriter, r *http.Request) {
-	wsHub.ServeWS(w, r)
+// addSyncBox creates a client for a Sync Box and starts its poller, which keeps
+// syncBoxCache warm and pushes execution changes (sync, mode, input) to tablets
+// as "syncbox" events. Returns the box's index.
+func addSyncBox(cfg SyncBoxConfig) int {
+	client := syncbox.NewClient(cfg.IP, cfg.AccessToken, cfg.Name)
+
+	syncBoxMu.Lock()
+	index := len(syncBoxClients)
+	key := strconv.Itoa(index)
+	poller := syncbox.NewPoller(func() (*syncbox.Status, error) {
+		return syncBoxCache.Refresh(key, client.GetStatus)
+	}, syncbox.PollInterval)
+	poller.OnChange(func(s, previous *syncbox.Status) {
+		wsHub.Broadcast(websocket.Event{Type: "syncbox", Payload: map[string]interface{}{
+			"index":  index,
+			"name":   cfg.Name,
+			"status": s,
+		}})
+	})
+	syncBoxClients = append(syncBoxClients, client)
+	syncBoxPollers = append(syncBoxPollers, poller)
+	syncBoxMu.Unlock()
+
+	go poller.Run()
+	return index
 }
 
-// Camera API handlers
-func handleCameraSnapshot(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	cameraManager.ProxySnapshot(w, r, name)
+// syncBoxPoller returns the poller for the {index} URL parameter, or nil
+func syncBoxPoller(r *http.Request) *syncbox.Poller {
+	index, err := strconv.Atoi(chi.URLParam(r, "index"))
+	syncBoxMu.RLock()
+	defer syncBoxMu.RUnlock()
+	if err != nil || index < 0 || index >= len(syncBoxPollers) {
+		return nil
+	}
+	return syncBoxPollers[index]
 }
 
-func handleCameraStream(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
-	cameraManager.ProxyMJPEG(w, r, name)
+// pokeSyncBox re-polls a Sync Box after a control request so the change is broadcast right away
+func pokeSyncBox(r *http.Request) {
+	if poller := syncBoxPoller(r); poller != nil {
+		poller.Poke()
+	}
 }
 
-func handleCameraTalk(w http.ResponseWriter, r *http.Request) {
-	name := chi.URLParam(r, "name")
+func getSyncBoxClient(r *http.Request) (*syncbox.Client, error) {
+	indexStr := chi.URLParam(r, "index")
+	index, err := strconv.Atoi(indexStr)
+	syncBoxMu.RLock()
+	defer syncBoxMu.RUnlock()
+	if err != nil || index < 0 || index >= len(syncBoxClients) {
+		return nil, fmt.Errorf("invalid sync box index")
+	}
+	return syncBoxClients[index], nil
+}
 
-	// Read the PCM audio data from the request body
-	// Expected format: 16-bit PCM, mono, 8kHz sample rate
-	pcmData, err := io.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, "Failed to read audio data", http.StatusBadRequest)
+// RegisterSyncBoxRequest is the body for POST /api/syncbox/register
+type RegisterSyncBoxRequest struct {
+	IP   string `json:"ip"`
+	Name string `json:"name"` // Display name (default: the box's own name)
+}
+
+// handleRegisterSyncBox obtains an access token from a Sync Box, saves it and adds
+// the box. Hold the button on the box (~3s, until the LED blinks green) first or
+// within 30 seconds of calling this.
+func handleRegisterSyncBox(w http.ResponseWriter, r *http.Request) {
+	var req RegisterSyncBoxRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-
-	if len(pcmData) == 0 {
-		http.Error(w, "No audio data received", http.StatusBadRequest)
+	req.IP = strings.TrimSpace(req.IP)
+	if net.ParseIP(req.IP) == nil {
+		http.Error(w, "ip must be an IP address", http.StatusBadRequest)
 		return
 	}
 
-	// Send audio to camera
-	err = cameraManager.PostAudio(name, pcmData)
+	syncBoxMu.RLock()
+	for _, client := range syncBoxClients {
+		if client.GetIP() == req.IP {
+			syncBoxMu.RUnlock()
+			http.Error(w, "Sync Box already configured", http.StatusConflict)
+			return
+		}
+	}
+	syncBoxMu.RUnlock()
+
+	client := syncbox.NewClient(req.IP, "", req.Name)
+	reg, err := client.AwaitRegistration("home_control", "kiosk", 30*time.Second)
 	if err != nil {
-		log.Printf("Failed to post audio to camera %s: %v", name, err)
-		http.Error(w, fmt.Sprintf("Failed to send audio: %v", err), http.StatusInternalServerError)
+		log.Printf("Error registering sync box %s: %v", req.IP, err)
+		http.Error(w, "Failed to register: "+err.Error(), http.StatusGatewayTimeout)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Audio sent successfully"))
-}
-
-func handleGetCameras(w http.ResponseWriter, r *http.Request) {
-	cameras := cameraManager.GetCameras()
-	cameraList := make([]map[string]string, 0)
-	for _, cam := range cameras {
-		cameraList = append(cameraList, map[string]string{
-			"name": cam.Name,
-		})
+	cfg := SyncBoxConfig{Name: strings.TrimSpace(req.Name), IP: req.IP, AccessToken: reg.AccessToken}
+	if cfg.Name == "" {
+		cfg.Name = req.IP
+		if device, err := client.GetDevice(); err == nil && device.Name != "" {
+			cfg.Name = device.Name
+		}
 	}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(cameraList)
-}
 
-func handleTestDoorbell(w http.ResponseWriter, r *http.Request) {
-	go wakeTablet() // Wake tablet screen first
-	wsHub.BroadcastDoorbell(appConfig.DoorbellCamera)
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("Doorbell event broadcast"))
-}
-
-func handleDoorbellWebhook(w http.ResponseWriter, r *http.Request) {
-	// Check webhook secret if configured
-	if appConfig.WebhookSecret != "" {
-		// Check Authorization header (Bearer token)
-		authHeader := r.Header.Get("Authorization")
-		if authHeader != "" {
-			if authHeader != "Bearer "+appConfig.WebhookSecret {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-		} else {
-			// Also check X-Webhook-Secret header
-			secret := r.Header.Get("X-Webhook-Secret")
-			if secret != appConfig.WebhookSecret {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
-				return
-			}
-		}
+	var registered []SyncBoxConfig
+	if _, err := syncBoxDoc.Load(&registered); err != nil {
+		log.Printf("Warning: Failed to load registered Sync Boxes: %v", err)
+	}
+	registered = append(registered, cfg)
+	if err := syncBoxDoc.Save(registered); err != nil {
+		log.Printf("Error saving sync box token: %v", err)
+		http.Error(w, "Failed to save token: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	log.Println("Doorbell webhook triggered from Home Assistant")
-	go wakeTablet() // Wake tablet screen first
-	wsHub.BroadcastDoorbell(appConfig.DoorbellCamera)
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("OK"))
+	index := addSyncBox(cfg)
+	log.Printf("Hue Sync Box registered: %s (%s)", cfg.Name, cfg.IP)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"index": index,
+		"name":  cfg.Name,
+		"ip":    cfg.IP,
+	})
 }
 
-// Hue API handlers
+func handleGetSyncBoxStatus(w http.ResponseWriter, r *http.Request) {
+	client, err := getSyncBoxClient(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
 
-func handleGetHueRooms(w http.ResponseWriter, r *http.Request) {
-	if hueClient == nil {
-		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
+	// Served from the poller; the cache covers the moments before its first poll
+	if status, ok := syncBoxPoller(r).Status(); ok {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(status)
 		return
 	}
 
-	rooms, err := hueClient.GetRoomsWithDetails()
+	status, err := syncBoxCache.Get(chi.URLParam(r, "index"), client.GetStatus)
 	if err != nil {
-		log.Printf("Error fetching Hue rooms: %v", err)
-		http.Error(w, "Failed to fetch Hue rooms: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Error getting sync box status: %v", err)
+		http.Error(w, "Failed to get sync box status: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(rooms)
+	json.NewEncoder(w).Encode(status)
 }
 
-func handleToggleHueLight(w http.ResponseWriter, r *http.Request) {
-	if hueClient == nil {
-		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
+func handleSetSyncBoxSync(w http.ResponseWriter, r *http.Request) {
+	client, err := getSyncBoxClient(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	id := chi.URLParam(r, "id")
-	if id == "" {
-		http.Error(w, "Missing light ID", http.StatusBadRequest)
-		return
+	var req struct {
+		Active bool `json:"active"`
 	}
-
-	if err := hueClient.ToggleLight(id); err != nil {
-		log.Printf("Error toggling Hue light %s: %v", id, err)
-		http.Error(w, "Failed to toggle light: "+err.Error(), http.StatusInternalServerError)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Return updated light state
-	light, err := hueClient.GetLight(id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := client.SetSyncActive(req.Active); err != nil {
+		log.Printf("Error setting sync box sync state: %v", err)
+		http.Error(w, "Failed to set sync state: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	pokeSyncBox(r)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(light)
-}
-
-type SetHueBrightnessRequest struct {
-	Brightness int `json:"brightness"` // 1-254
+	json.NewEncoder(w).Encode(map[string]bool{"active": req.Active})
 }
 
-func handleSetHueLightBrightness(w http.ResponseWriter, r *http.Request) {
-	if hueClient == nil {
-		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
+func handleSetSyncBoxArea(w http.ResponseWriter, r *http.Request) {
+	client, err := getSyncBoxClient(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	id := chi.URLParam(r, "id")
-	if id == "" {
-		http.Error(w, "Missing light ID", http.StatusBadRequest)
-		return
+	var req struct {
+		GroupID string `json:"groupId"`
 	}
-
-	var req SetHueBrightnessRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if err := hueClient.SetLightBrightness(id, req.Brightness); err != nil {
-		log.Printf("Error setting Hue light %s brightness: %v", id, err)
-		http.Error(w, "Failed to set brightness: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	light, err := hueClient.GetLight(id)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := client.SetEntertainmentArea(req.GroupID); err != nil {
+		log.Printf("Error setting sync box entertainment area: %v", err)
+		http.Error(w, "Failed to set entertainment area: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	pokeSyncBox(r)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(light)
+	json.NewEncoder(w).Encode(map[string]string{"groupId": req.GroupID})
 }
 
-func handleToggleHueGroup(w http.ResponseWriter, r *http.Request) {
-	if hueClient == nil {
-		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
+func handleSetSyncBoxMode(w http.ResponseWriter, r *http.Request) {
+	client, err := getSyncBoxClient(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	id := chi.URLParam(r, "id")
-	if id == "" {
-		http.Error(w, "Missing group ID", http.StatusBadRequest)
-		return
+	var req struct {
+		Mode string `json:"mode"`
 	}
-
-	if err := hueClient.ToggleGroup(id); err != nil {
-		log.Printf("Error toggling Hue group %s: %v", id, err)
-		http.Error(w, "Failed to toggle group: "+err.Error(), http.StatusInternalServerError)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Return updated rooms
-	rooms, err := hueClient.GetRoomsWithDetails()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := client.SetMode(req.Mode); err != nil {
+		log.Printf("Error setting sync box mode: %v", err)
+		http.Error(w, "Failed to set mode: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	pokeSyncBox(r)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(rooms)
+	json.NewEncoder(w).Encode(map[string]string{"mode": req.Mode})
 }
 
-func handleSetHueGroupBrightness(w http.ResponseWriter, r *http.Request) {
-	if hueClient == nil {
-		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
+func handleSetSyncBoxBrightness(w http.ResponseWriter, r *http.Request) {
+	client, err := getSyncBoxClient(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	id := chi.URLParam(r, "id")
-	if id == "" {
-		http.Error(w, "Missing group ID", http.StatusBadRequest)
-		return
+	var req struct {
+		Brightness int `json:"brightness"`
 	}
-
-	var req SetHueBrightnessRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	if err := hueClient.SetGroupBrightness(id, req.Brightness); err != nil {
-		log.Printf("Error setting Hue group %s brightness: %v", id, err)
+	if err := client.SetBrightness(req.Brightness); err != nil {
+		log.Printf("Error setting sync box brightness: %v", err)
 		http.Error(w, "Failed to set brightness: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	rooms, err := hueClient.GetRoomsWithDetails()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
+	pokeSyncBox(r)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(rooms)
+	json.NewEncoder(w).Encode(map[string]int{"brightness": req.Brightness})
 }
 
-func handleActivateHueScene(w http.ResponseWriter, r *http.Request) {
-	if hueClient == nil {
-		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
+// handleSetSyncBoxIntensity sets the intensity of the current sync mode.
+// The Hue app's "extreme" is the API's "intense".
+func handleSetSyncBoxIntensity(w http.ResponseWriter, r *http.Request) {
+	client, err := getSyncBoxClient(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	id := chi.URLParam(r, "id")
-	if id == "" {
-		http.Error(w, "Missing scene ID", http.StatusBadRequest)
+	var req struct {
+		Intensity string `json:"intensity"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-
-	if err := hueClient.ActivateScene(id); err != nil {
-		log.Printf("Error activating Hue scene %s: %v", id, err)
-		http.Error(w, "Failed to activate scene: "+err.Error(), http.StatusInternalServerError)
+	if req.Intensity == "extreme" {
+		req.Intensity = "intense"
+	}
+	if !syncbox.ValidIntensity(req.Intensity) {
+		http.Error(w, "intensity must be subtle, moderate, high or intense", http.StatusBadRequest)
 		return
 	}
 
-	// Return updated rooms
-	rooms, err := hueClient.GetRoomsWithDetails()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := client.SetIntensity(req.Intensity); err != nil {
+		log.Printf("Error setting sync box intensity: %v", err)
+		http.Error(w, "Failed to set intensity: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	pokeSyncBox(r)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(rooms)
+	json.NewEncoder(w).Encode(map[string]string{"intensity": req.Intensity})
 }
 
-func handleActivateEntertainment(w http.ResponseWriter, r *http.Request) {
-	if hueClient == nil {
-		http.Error(w, "Hue bridge not configured", http.StatusServiceUnavailable)
+func handleSetSyncBoxInput(w http.ResponseWriter, r *http.Request) {
+	client, err := getSyncBoxClient(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	id := chi.URLParam(r, "id")
-	if id == "" {
-		http.Error(w, "Missing entertainment area ID", http.StatusBadRequest)
+	var req struct {
+		HDMISource string `json:"hdmiSource"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
 
-	// Just select the area locally (informational only)
-	// Actual streaming control is done via Sync Box API
-	if hueStreamer != nil {
-		if err := hueStreamer.SelectArea(id); err != nil {
-			log.Printf("Error selecting entertainment area %s: %v", id, err)
-			http.Error(w, "Failed to select entertainment area: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
+	if err := client.SetHDMISource(req.HDMISource); err != nil {
+		log.Printf("Error setting sync box HDMI source: %v", err)
+		http.Error(w, "Failed to set HDMI source: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
+	pokeSyncBox(r)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "selected", "id": id})
+	json.NewEncoder(w).Encode(map[string]string{"hdmiSource": req.HDMISource})
 }
 
-func handleDeactivateEntertainment(w http.ResponseWriter, r *http.Request) {
-	if hueStreamer == nil {
-		http.Error(w, "Entertainment streaming not configured", http.StatusServiceUnavailable)
+// Google Drive handlers for photos (screensaver and background use same folder)
+
+func handleGetDrivePhotos(w http.ResponseWriter, r *http.Request) {
+	if driveClient == nil {
+		http.Error(w, "Drive client not initialized", http.StatusServiceUnavailable)
 		return
 	}
 
-	if err := hueStreamer.Deactivate(); err != nil {
-		log.Printf("Error deactivating entertainment: %v", err)
-		http.Error(w, "Failed to deactivate entertainment: "+err.Error(), http.StatusInternalServerError)
+	photos, err := driveClient.GetPhotos(r.Context())
+	if err != nil {
+		log.Printf("Error fetching photos: %v", err)
+		http.Error(w, "Failed to fetch photos: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "deactivated"})
+	json.NewEncoder(w).Encode(photos)
 }
 
-func handleGetEntertainmentStatus(w http.ResponseWriter, r *http.Request) {
-	status := map[string]interface{}{
-		"streaming":  false,
-		"activeArea": "",
-		"enabled":    hueStreamer != nil,
+func handleGetRandomDrivePhoto(w http.ResponseWriter, r *http.Request) {
+	if driveClient == nil {
+		http.Error(w, "Drive client not initialized", http.StatusServiceUnavailable)
+		return
 	}
 
-	if hueStreamer != nil {
-		status["streaming"] = hueStreamer.IsStreaming()
-		status["activeArea"] = hueStreamer.GetActiveArea()
+	photo, err := driveClient.GetRandomPhoto(r.Context())
+	if err != nil {
+		log.Printf("Error fetching random photo: %v", err)
+		http.Error(w, "Failed to fetch photo: "+err.Error(), http.StatusInternalServerError)
+		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(status)
-}
-
-// Sync Box handlers
-
-func handleGetSyncBoxes(w http.ResponseWriter, r *http.Request) {
-	type SyncBoxInfo struct {
-		Index int    `json:"index"`
-		Name  string `json:"name"`
-		IP    string `json:"ip"`
+	if photo == nil {
+		http.Error(w, "No photos available", http.StatusNotFound)
+		return
 	}
 
-	boxes := make([]SyncBoxInfo, len(syncBoxClients))
-	for i, client := range syncBoxClients {
-		boxes[i] = SyncBoxInfo{
-			Index: i,
-			Name:  client.GetName(),
-			IP:    client.GetIP(),
-		}
+	// Return the photo with a direct URL
+	response := map[string]interface{}{
+		"id":   photo.ID,
+		"name": photo.Name,
+		"url":  driveClient.GetPhotoURL(photo.ID),
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(boxes)
+	json.NewEncoder(w).Encode(response)
 }
 
-func getSyncBoxClient(r *http.Request) (*syncbox.Client, error) {
-	indexStr := chi.URLParam(r, "index")
-	index, err := strconv.Atoi(indexStr)
-	if err != nil || index < 0 || index >= len(syncBoxClients) {
-		return nil, fmt.Errorf("invalid sync box index")
+func handleGetDrivePhoto(w http.ResponseWriter, r *http.Request) {
+	if driveClient == nil {
+		http.Error(w, "Drive client not initialized", http.StatusServiceUnavailable)
+		return
 	}
-	return syncBoxClients[index], nil
-}
 
-func handleGetSyncBoxStatus(w http.ResponseWriter, r *http.Request) {
-	client, err := getSyncBoxClient(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	photoID := chi.URLParam(r, "id")
+	if photoID == "" {
+		http.Error(w, "Photo ID required", http.StatusBadRequest)
 		return
 	}
 
-	status, err := client.GetStatus()
+	// Download and proxy the image through our authenticated client
+	data, contentType, err := driveClient.GetFileContent(r.Context(), photoID)
 	if err != nil {
-		log.Printf("Error getting sync box status: %v", err)
-		http.Error(w, "Failed to get sync box status: "+err.Error(), http.StatusInternalServerError)
+		log.Printf("Error fetching photo %s: %v", photoID, err)
+		http.Error(w, "Failed to fetch photo", http.StatusInternalServerError)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(status)
+	// Set cache headers for performance
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write(data)
 }
 
-func handleSetSyncBoxSync(w http.ResponseWriter, r *http.Request) {
-	client, err := getSyncBoxClient(r)
+// drivePhotos adapts the Drive photos folder to photos.Source
+type drivePhotos struct {
+	client *drive.Client
+}
+
+func (d drivePhotos) GetPhotos(ctx context.Context) ([]photos.Photo, error) {
+	list, err := d.client.GetPhotos(ctx)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
-		return
+		return nil, err
 	}
+	result := make([]photos.Photo, 0, len(list))
+	for _, p := range list {
+		result = append(result, photos.Photo{ID: p.ID, Name: p.Name, MimeType: p.MimeType})
+	}
+	return result, nil
+}
 
-	var req struct {
-		Active bool `json:"active"`
+func (d drivePhotos) GetRandomPhoto(ctx context.Context) (*photos.Photo, error) {
+	p, err := d.client.GetRandomPhoto(ctx)
+	if err != nil || p == nil {
+		return nil, err
 	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	return &photos.Photo{ID: p.ID, Name: p.Name, MimeType: p.MimeType}, nil
+}
+
+func (d drivePhotos) GetPhotoContent(ctx context.Context, id string) ([]byte, string, error) {
+	return d.client.GetFileContent(ctx, id)
+}
+
+// screensaverSource picks the screensaver's photo provider: a picked Google
+// Photos album, then PHOTOS_DIR, then the Drive folder. Returns nil if none.
+func screensaverSource() (photos.Source, string) {
+	switch {
+	case photosClient != nil && photosClient.AlbumID() != "":
+		return photosClient, "google_photos"
+	case photoFolder != nil:
+		return photoFolder, "folder"
+	case driveClient != nil && driveClient.HasPhotosFolder():
+		return drivePhotos{driveClient}, "drive"
+	}
+	return nil, ""
+}
+
+// Screensaver photo handlers, served from whichever source is active
+
+func handleGetScreensaverPhotos(w http.ResponseWriter, r *http.Request) {
+	source, _ := screensaverSource()
+	if source == nil {
+		http.Error(w, "No photo source configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	if err := client.SetSyncActive(req.Active); err != nil {
-		log.Printf("Error setting sync box sync state: %v", err)
-		http.Error(w, "Failed to set sync state: "+err.Error(), http.StatusInternalServerError)
+	list, err := source.GetPhotos(r.Context())
+	if err != nil {
+		log.Printf("Error fetching screensaver photos: %v", err)
+		http.Error(w, "Failed to fetch photos: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]bool{"active": req.Active})
+	json.NewEncoder(w).Encode(list)
 }
 
-func handleSetSyncBoxArea(w http.ResponseWriter, r *http.Request) {
-	client, err := getSyncBoxClient(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+func handleGetRandomScreensaverPhoto(w http.ResponseWriter, r *http.Request) {
+	source, _ := screensaverSource()
+	if source == nil {
+		http.Error(w, "No photo source configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	var req struct {
-		GroupID string `json:"groupId"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	photo, err := source.GetRandomPhoto(r.Context())
+	if err != nil {
+		log.Printf("Error fetching random screensaver photo: %v", err)
+		http.Error(w, "Failed to fetch photo: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-
-	if err := client.SetEntertainmentArea(req.GroupID); err != nil {
-		log.Printf("Error setting sync box entertainment area: %v", err)
-		http.Error(w, "Failed to set entertainment area: "+err.Error(), http.StatusInternalServerError)
+	if photo == nil {
+		http.Error(w, "No photos available", http.StatusNotFound)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"groupId": req.GroupID})
+	json.NewEncoder(w).Encode(photo)
 }
 
-func handleSetSyncBoxMode(w http.ResponseWriter, r *http.Request) {
-	client, err := getSyncBoxClient(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+func handleGetScreensaverPhoto(w http.ResponseWriter, r *http.Request) {
+	source, _ := screensaverSource()
+	if source == nil {
+		http.Error(w, "No photo source configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	var req struct {
-		Mode string `json:"mode"`
-	}
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	photoID := chi.URLParam(r, "id")
+	data, contentType, err := source.GetPhotoContent(r.Context(), photoID)
+	if err != nil {
+		log.Printf("Error fetching screensaver photo %s: %v", photoID, err)
+		http.Error(w, "Failed to fetch photo", http.StatusInternalServerError)
 		return
 	}
 
-	if err := client.SetMode(req.Mode); err != nil {
-		log.Printf("Error setting sync box mode: %v", err)
-		http.Error(w, "Failed to set mode: "+err.Error(), http.StatusInternalServerError)
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	w.Write(data)
+}
+
+// Google Photos handlers (screensaver source once an album is picked)
+
+func handleGetPhotoAlbums(w http.ResponseWriter, r *http.Request) {
+	if photosClient == nil {
+		http.Error(w, "Google Photos not configured", http.StatusServiceUnavailable)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"mode": req.Mode})
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"albums":   photosClient.Albums(),
+		"selected": photosClient.AlbumID(),
+	})
 }
 
-func handleSetSyncBoxBrightness(w http.ResponseWriter, r *http.Request) {
-	client, err := getSyncBoxClient(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+// CreatePhotoAlbumRequest is the body for POST /api/photos/albums
+type CreatePhotoAlbumRequest struct {
+	Title string `json:"title"`
+}
+
+// handleCreatePhotoAlbum starts picking an album; open its pickerUri to choose
+// the photos in Google Photos
+func handleCreatePhotoAlbum(w http.ResponseWriter, r *http.Request) {
+	if photosClient == nil {
+		http.Error(w, "Google Photos not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	var req struct {
-		Brightness int `json:"brightness"`
-	}
+	var req CreatePhotoAlbumRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-
-	if err := client.SetBrightness(req.Brightness); err != nil {
-		log.Printf("Error setting sync box brightness: %v", err)
-		http.Error(w, "Failed to set brightness: "+err.Error(), http.StatusInternalServerError)
+	if strings.TrimSpace(req.Title) == "" {
+		http.Error(w, "title is required", http.StatusBadRequest)
+		return
+	}
+	album, err := photosClient.CreateAlbum(r.Context(), req.Title)
+	if err != nil {
+		log.Printf("Error starting Google Photos picker: %v", err)
+		http.Error(w, "Failed to start picker: "+err.Error(), http.StatusBadGateway)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]int{"brightness": req.Brightness})
+	json.NewEncoder(w).Encode(album)
 }
 
-func handleSetSyncBoxInput(w http.ResponseWriter, r *http.Request) {
-	client, err := getSyncBoxClient(r)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+func handleDeletePhotoAlbum(w http.ResponseWriter, r *http.Request) {
+	if photosClient == nil {
+		http.Error(w, "Google Photos not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	var req struct {
-		HDMISource string `json:"hdmiSource"`
+	if err := photosClient.DeleteAlbum(chi.URLParam(r, "id")); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetPhotoAlbumRequest is the body for PUT /api/photos/album
+type SetPhotoAlbumRequest struct {
+	AlbumID string `json:"albumId"` // Empty switches the screensaver back to Drive
+}
+
+func handleSetPhotoAlbum(w http.ResponseWriter, r *http.Request) {
+	if photosClient == nil {
+		http.Error(w, "Google Photos not configured", http.StatusServiceUnavailable)
+		return
 	}
+
+	var req SetPhotoAlbumRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-
-	if err := client.SetHDMISource(req.HDMISource); err != nil {
-		log.Printf("Error setting sync box HDMI source: %v", err)
-		http.Error(w, "Failed to set HDMI source: "+err.Error(), http.StatusInternalServerError)
+	if err := photosClient.SetAlbum(req.AlbumID); err != nil {
+		log.Printf("Error setting Google Photos album: %v", err)
+		http.Error(w, "Failed to set album: "+err.Error(), http.StatusBadRequest)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"hdmiSource": req.HDMISource})
+	json.NewEncoder(w).Encode(map[string]string{"albumId": photosClient.AlbumID()})
 }
 
-// Google Drive handlers for photos (screensaver and background use same folder)
-
-func handleGetDrivePhotos(w http.ResponseWriter, r *http.Request) {
-	if driveClient == nil {
-		http.Error(w, "Drive client not initialized", http.StatusServiceUnavailable)
+func handleGetGooglePhotos(w http.ResponseWriter, r *http.Request) {
+	if photosClient == nil {
+		http.Error(w, "Google Photos not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	photos, err := driveClient.GetPhotos(r.Context())
+	list, err := photosClient.GetPhotos(r.Context())
+	if errors.Is(err, photos.ErrNoAlbum) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		log.Printf("Error fetching photos: %v", err)
+		log.Printf("Error fetching Google Photos: %v", err)
 		http.Error(w, "Failed to fetch photos: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(photos)
+	json.NewEncoder(w).Encode(list)
 }
 
-func handleGetRandomDrivePhoto(w http.ResponseWriter, r *http.Request) {
-	if driveClient == nil {
-		http.Error(w, "Drive client not initialized", http.StatusServiceUnavailable)
+func handleGetRandomGooglePhoto(w http.ResponseWriter, r *http.Request) {
+	if photosClient == nil {
+		http.Error(w, "Google Photos not configured", http.StatusServiceUnavailable)
 		return
 	}
 
-	photo, err := driveClient.GetRandomPhoto(r.Context())
+	photo, err := photosClient.GetRandomPhoto(r.Context())
+	if errors.Is(err, photos.ErrNoAlbum) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
 	if err != nil {
-		log.Printf("Error fetching random photo: %v", err)
+		log.Printf("Error fetching random Google Photo: %v", err)
 		http.Error(w, "Failed to fetch photo: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
-
 	if photo == nil {
 		http.Error(w, "No photos available", http.StatusNotFound)
 		return
 	}
 
-	// Return the photo with a direct URL
-	response := map[string]interface{}{
-		"id":   photo.ID,
-		"name": photo.Name,
-		"url":  driveClient.GetPhotoURL(photo.ID),
-	}
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	json.NewEncoder(w).Encode(photo)
 }
 
-func handleGetDrivePhoto(w http.ResponseWriter, r *http.Request) {
-	if driveClient == nil {
-		http.Error(w, "Drive client not initialized", http.StatusServiceUnavailable)
+func handleGetGooglePhoto(w http.ResponseWriter, r *http.Request) {
+	if photosClient == nil {
+		http.Error(w, "Google Photos not configured", http.StatusServiceUnavailable)
 		return
 	}
 
 	photoID := chi.URLParam(r, "id")
-	if photoID == "" {
-		http.Error(w, "Photo ID required", http.StatusBadRequest)
-		return
-	}
-
-	// Download and proxy the image through our authenticated client
-	data, contentType, err := driveClient.GetFileContent(r.Context(), photoID)
+	data, contentType, err := photosClient.GetPhotoContent(r.Context(), photoID)
 	if err != nil {
-		log.Printf("Error fetching photo %s: %v", photoID, err)
+		log.Printf("Error fetching Google Photo %s: %v", photoID, err)
 		http.Error(w, "Failed to fetch photo", http.StatusInternalServerError)
 		return
 	}
 
-	// Set cache headers for performance
 	w.Header().Set("Content-Type", contentType)
 	w.Header().Set("Cache-Control", "public, max-age=3600")
 	w.Write(data)
 }
 
+// Classroom API handler
+
+// handleGetClassroomUpcoming lists open assignments due soon.
+// Query: days (default 7, max 60), student (display name, default everyone)
+func handleGetClassroomUpcoming(w http.ResponseWriter, r *http.Request) {
+	if classroomClient == nil {
+		http.Error(w, "Classroom not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	days := 7
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > 60 {
+			http.Error(w, "days must be between 1 and 60", http.StatusBadRequest)
+			return
+		}
+		days = n
+	}
+
+	assignments, err := classroomClient.Upcoming(r.Context(), r.URL.Query().Get("student"), days)
+	if err != nil {
+		log.Printf("Error fetching classroom assignments: %v", err)
+		http.Error(w, "Failed to fetch assignments: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"students":    classroomClient.Students(),
+		"assignments": assignments,
+	})
+}
+
 func handleGetScreensaverConfig(w http.ResponseWriter, r *http.Request) {
+	source, name := screensaverSource()
 	config := map[string]interface{}{
 		"timeout":         appConfig.ScreensaverTimeout,
-		"hasPhotosFolder": driveClient != nil && driveClient.HasPhotosFolder(),
+		"hasPhotosFolder": source != nil,
+		"photoSource":     name,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(config)
 }
 
+// Spotify accounts
+
+// defaultSpotifyAccount names the account used when a request has no ?account=
+const defaultSpotifyAccount = "default"
+
+// parseSpotifyAccounts parses SPOTIFY_ACCOUNTS, e.g. "partner,kids". Names end
+// up in token file names, so only letters, digits, - and _ are allowed.
+func parseSpotifyAccounts(s string) []string {
+	var accounts []string
+	for _, name := range parseEntities(s) {
+		name = strings.ToLower(name)
+		valid := name != "" && name != defaultSpotifyAccount
+		for _, c := range name {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+				valid = false
+			}
+		}
+		if !valid {
+			log.Printf("Warning: Ignoring invalid Spotify account name %q", name)
+			continue
+		}
+		accounts = append(accounts, name)
+	}
+	return accounts
+}
+
+// addSpotifyAccount creates the client for an account, with its own saved token
+func addSpotifyAccount(cfg Config, account string) *spotify.Client {
+	dataDir := getEnv("DATA_DIR", "data")
+	redirectURL := cfg.BaseURL + "/auth/spotify/callback"
+	client := spotify.NewClient(cfg.SpotifyClientID, cfg.SpotifyClientSecret, redirectURL)
+
+	// The default account keeps the original token names
+	key, file := "spotify", "spotify_token.json"
+	if account != defaultSpotifyAccount {
+		key, file = "spotify_"+account, "spotify_token_"+account+".json"
+	}
+	tokenDoc := dataStore.Doc("tokens", key, filepath.Join(dataDir, file))
+	client.SetTokenSaveCallback(func(token *spotify.Token) error {
+		return tokenDoc.Save(token)
+	})
+
+	// Try to load existing token
+	var token spotify.Token
+	if ok, err := tokenDoc.Load(&token); err != nil {
+		log.Printf("Warning: Failed to load Spotify token for %s: %v", account, err)
+	} else if ok {
+		client.SetToken(&token)
+		log.Printf("Spotify account %s initialized with saved token", account)
+	} else {
+		log.Printf("Spotify account %s initialized. Visit /auth/spotify?account=%s to authorize.", account, account)
+	}
+
+	spotifyClients[account] = client
+	spotifyAccountNames = append(spotifyAccountNames, account)
+	return client
+}
+
+// spotifyAccountName returns the account a request is for (?account=, default
+// when absent)
+func spotifyAccountName(r *http.Request) string {
+	if account := strings.ToLower(r.URL.Query().Get("account")); account != "" {
+		return account
+	}
+	return defaultSpotifyAccount
+}
+
+// spotifyClientFor returns the client for a request's account, or nil when
+// Spotify isn't configured or the account is unknown
+func spotifyClientFor(r *http.Request) *spotify.Client {
+	return spotifyClients[spotifyAccountName(r)]
+}
+
+func handleSpotifyAccounts(w http.ResponseWriter, r *http.Request) {
+	accounts := make([]map[string]interface{}, 0, len(spotifyAccountNames))
+	for _, name := range spotifyAccountNames {
+		accounts = append(accounts, map[string]interface{}{
+			"name":          name,
+			"authenticated": spotifyClients[name].IsAuthenticated(),
+			"authUrl":       "/auth/spotify?account=" + name,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(accounts)
+}
+
 // Spotify handlers
 
 func handleSpotifyAuth(w http.ResponseWriter, r *http.Request) {
+	client := spotifyClientFor(r)
 	if spotifyClient == nil {
 		http.Error(w, "Spotify not configured", http.StatusServiceUnavailable)
 		return
 	}
+	if client == nil {
+		http.Error(w, "Unknown Spotify account", http.StatusNotFound)
+		return
+	}
 
-	state := fmt.Sprintf("%d", time.Now().UnixNano())
-	authURL := spotifyClient.GetAuthURL(state)
+	// The callback URL is registered once, so the account rides along in state
+	state := fmt.Sprintf("%s:%d", spotifyAccountName(r), time.Now().UnixNano())
+	authURL := client.GetAuthURL(state)
 	http.Redirect(w, r, authURL, http.StatusTemporaryRedirect)
 }
 
@@ -3698,6 +13688,13 @@ func handleSpotifyCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	account, _, _ := strings.Cut(r.URL.Query().Get("state"), ":")
+	client := spotifyClients[account]
+	if client == nil {
+		// State from before accounts existed
+		account, client = defaultSpotifyAccount, spotifyClient
+	}
+
 	code := r.URL.Query().Get("code")
 	if code == "" {
 		errMsg := r.URL.Query().Get("error")
@@ -3705,34 +13702,74 @@ func handleSpotifyCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_, err := spotifyClient.Exchange(r.Context(), code)
+	_, err := client.Exchange(r.Context(), code)
 	if err != nil {
 		log.Printf("Spotify token exchange failed: %v", err)
 		http.Error(w, "Token exchange failed: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Spotify authorization successful")
+	log.Printf("Spotify authorization successful for %s", account)
 	http.Redirect(w, r, "/home", http.StatusTemporaryRedirect)
 }
 
 func handleSpotifyStatus(w http.ResponseWriter, r *http.Request) {
+	client := spotifyClientFor(r)
 	status := map[string]interface{}{
 		"configured":    spotifyClient != nil,
-		"authenticated": spotifyClient != nil && spotifyClient.IsAuthenticated(),
+		"authenticated": client != nil && client.IsAuthenticated(),
+		"account":       spotifyAccountName(r),
+		"accounts":      spotifyAccountNames,
+		"playerName":    appConfig.SpotifyPlayerName,
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(status)
 }
 
+// handleSpotifyToken hands the kiosk a short-lived access token for the Web
+// Playback SDK, so the tablet itself can be a Spotify Connect device
+func handleSpotifyToken(w http.ResponseWriter, r *http.Request) {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
+		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
+		return
+	}
+	if appConfig.SpotifyPlayerName == "" {
+		http.Error(w, "Spotify web player not enabled", http.StatusServiceUnavailable)
+		return
+	}
+	if !client.HasScope("streaming") {
+		http.Error(w, "Spotify authorization lacks the streaming scope; visit /auth/spotify to re-authorize", http.StatusForbidden)
+		return
+	}
+
+	token, expiresAt, err := client.AccessToken(r.Context())
+	if err != nil {
+		log.Printf("Error getting Spotify access token: %v", err)
+		http.Error(w, "Failed to get access token: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"accessToken": token,
+		"expiresAt":   expiresAt,
+		"deviceName":  appConfig.SpotifyPlayerName,
+	})
+}
+
 func handleSpotifyPlayback(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
 
-	state, err := spotifyClient.GetPlaybackState(r.Context())
+	state, err := playbackCache.Get(spotifyAccountName(r), func() (*spotify.PlaybackState, error) {
+		return fetchSpotifyPlayback(r.Context(), client)
+	})
 	if err != nil {
 		log.Printf("Error getting playback state: %v", err)
 		http.Error(w, "Failed to get playback state: "+err.Error(), http.StatusInternalServerError)
@@ -3743,25 +13780,99 @@ func handleSpotifyPlayback(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(state)
 }
 
+// fetchSpotifyPlayback reads the playback state with device aliases applied
+func fetchSpotifyPlayback(ctx context.Context, client *spotify.Client) (*spotify.PlaybackState, error) {
+	state, err := client.GetPlaybackState(ctx)
+	if err == nil && state != nil {
+		spotifyDeviceSettings.ApplyDevice(state.Device)
+	}
+	return state, err
+}
+
+// startSpotifyPoller polls an account's playback (every second while playing,
+// every 30 seconds when idle), keeping playbackCache warm and pushing changes
+// to tablets as "spotify_playback" events so they don't each poll
+// /api/spotify/playback
+func startSpotifyPoller(account string) {
+	client := spotifyClients[account]
+	poller := spotify.NewPoller(func() (*spotify.PlaybackState, error) {
+		if !client.IsAuthenticated() {
+			return nil, nil
+		}
+		return playbackCache.Refresh(account, func() (*spotify.PlaybackState, error) {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			return fetchSpotifyPlayback(ctx, client)
+		})
+	})
+	poller.OnChange(func(s *spotify.PlaybackState) {
+		wsHub.Broadcast(websocket.Event{Type: "spotify_playback", Payload: map[string]interface{}{
+			"account": account,
+			"state":   s,
+		}})
+	})
+	spotifyPollers[account] = poller
+	go poller.Run()
+	log.Printf("Spotify playback poller started for %s", account)
+}
+
 func handleSpotifyDevices(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
 
-	devices, err := spotifyClient.GetDevices(r.Context())
+	devices, err := client.GetDevices(r.Context())
 	if err != nil {
 		log.Printf("Error getting devices: %v", err)
 		http.Error(w, "Failed to get devices: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// ?all=true includes hidden devices (for the settings screen)
+	includeHidden := r.URL.Query().Get("all") == "true"
+	devices = spotifyDeviceSettings.ApplyDevices(devices, includeHidden)
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(devices)
 }
 
+func handleGetSpotifyDeviceSettings(w http.ResponseWriter, r *http.Request) {
+	if spotifyDeviceSettings == nil {
+		http.Error(w, "Spotify not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(spotifyDeviceSettings.Get())
+}
+
+func handleUpdateSpotifyDeviceSettings(w http.ResponseWriter, r *http.Request) {
+	if spotifyDeviceSettings == nil {
+		http.Error(w, "Spotify not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	var settings spotify.DeviceSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if err := spotifyDeviceSettings.Set(settings); err != nil {
+		log.Printf("Error saving Spotify device settings: %v", err)
+		http.Error(w, "Failed to save device settings: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(spotifyDeviceSettings.Get())
+}
+
 func handleSpotifyPlay(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -3775,9 +13886,9 @@ func handleSpotifyPlay(w http.ResponseWriter, r *http.Request) {
 
 	var err error
 	if req.URI != "" {
-		err = spotifyClient.PlayURI(r.Context(), req.DeviceID, req.URI, req.Position)
+		err = client.PlayURI(r.Context(), req.DeviceID, req.URI, req.Position)
 	} else {
-		err = spotifyClient.Play(r.Context(), req.DeviceID)
+		err = client.Play(r.Context(), req.DeviceID)
 	}
 
 	if err != nil {
@@ -3785,12 +13896,18 @@ func handleSpotifyPlay(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to start playback: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	// Only contexts (playlists, albums, shows) are worth suggesting, not single tracks
+	if req.URI != "" && !strings.Contains(req.URI, ":track:") {
+		recordAction(r, actions.KindPlaylist, req.URI, "play")
+	}
+	broadcastSpotifyQueue(r)
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
 func handleSpotifyPause(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -3800,7 +13917,7 @@ func handleSpotifyPause(w http.ResponseWriter, r *http.Request) {
 	}
 	json.NewDecoder(r.Body).Decode(&req)
 
-	if err := spotifyClient.Pause(r.Context(), req.DeviceID); err != nil {
+	if err := client.Pause(r.Context(), req.DeviceID); err != nil {
 		log.Printf("Error pausing playback: %v", err)
 		http.Error(w, "Failed to pause: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -3810,7 +13927,30 @@ func handleSpotifyPause(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyNext(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
+		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		DeviceID string `json:"device_id"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	if err := client.Next(r.Context(), req.DeviceID); err != nil {
+		log.Printf("Error skipping to next: %v", err)
+		http.Error(w, "Failed to skip: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	broadcastSpotifyQueue(r)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleSpotifyPrevious(w http.ResponseWriter, r *http.Request) {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -3820,37 +13960,83 @@ func handleSpotifyNext(w http.ResponseWriter, r *http.Request) {
 	}
 	json.NewDecoder(r.Body).Decode(&req)
 
-	if err := spotifyClient.Next(r.Context(), req.DeviceID); err != nil {
-		log.Printf("Error skipping to next: %v", err)
-		http.Error(w, "Failed to skip: "+err.Error(), http.StatusInternalServerError)
+	if err := client.Previous(r.Context(), req.DeviceID); err != nil {
+		log.Printf("Error going to previous: %v", err)
+		http.Error(w, "Failed to go to previous: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	broadcastSpotifyQueue(r)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleSpotifyQueue(w http.ResponseWriter, r *http.Request) {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
+		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	queue, err := client.GetQueue(r.Context())
+	if err != nil {
+		log.Printf("Error getting queue: %v", err)
+		http.Error(w, "Failed to get queue: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(queue)
 }
 
-func handleSpotifyPrevious(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+func handleSpotifyAddToQueue(w http.ResponseWriter, r *http.Request) {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
 
 	var req struct {
 		DeviceID string `json:"device_id"`
+		URI      string `json:"uri"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.URI == "" {
+		http.Error(w, "uri is required", http.StatusBadRequest)
+		return
 	}
-	json.NewDecoder(r.Body).Decode(&req)
 
-	if err := spotifyClient.Previous(r.Context(), req.DeviceID); err != nil {
-		log.Printf("Error going to previous: %v", err)
-		http.Error(w, "Failed to go to previous: "+err.Error(), http.StatusInternalServerError)
+	if err := client.AddToQueue(r.Context(), req.DeviceID, req.URI); err != nil {
+		log.Printf("Error adding to queue: %v", err)
+		http.Error(w, "Failed to add to queue: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	broadcastSpotifyQueue(r)
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// broadcastSpotifyQueue sends the queue to clients after it changes. Spotify
+// takes a moment to reflect skips and additions, so it's read after a short delay.
+func broadcastSpotifyQueue(r *http.Request) {
+	account, client := spotifyAccountName(r), spotifyClientFor(r)
+	go func() {
+		time.Sleep(time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		queue, err := client.GetQueue(ctx)
+		if err != nil {
+			log.Printf("Warning: Failed to read Spotify queue: %v", err)
+			return
+		}
+		wsHub.Broadcast(websocket.Event{Type: "spotify_queue", Payload: map[string]interface{}{
+			"account": account,
+			"queue":   queue,
+		}})
+	}()
+}
+
 func handleSpotifyVolume(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -3864,7 +14050,8 @@ func handleSpotifyVolume(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := spotifyClient.SetVolume(r.Context(), req.DeviceID, req.VolumePercent); err != nil {
+	volume := clampVolume(w, "spotify", req.VolumePercent)
+	if err := client.SetVolume(r.Context(), req.DeviceID, volume); err != nil {
 		log.Printf("Error setting volume: %v", err)
 		http.Error(w, "Failed to set volume: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -3874,7 +14061,8 @@ func handleSpotifyVolume(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifySeek(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -3888,7 +14076,7 @@ func handleSpotifySeek(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := spotifyClient.Seek(r.Context(), req.DeviceID, req.PositionMS); err != nil {
+	if err := client.Seek(r.Context(), req.DeviceID, req.PositionMS); err != nil {
 		log.Printf("Error seeking: %v", err)
 		http.Error(w, "Failed to seek: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -3898,7 +14086,8 @@ func handleSpotifySeek(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyShuffle(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -3912,7 +14101,7 @@ func handleSpotifyShuffle(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := spotifyClient.SetShuffle(r.Context(), req.DeviceID, req.State); err != nil {
+	if err := client.SetShuffle(r.Context(), req.DeviceID, req.State); err != nil {
 		log.Printf("Error setting shuffle: %v", err)
 		http.Error(w, "Failed to set shuffle: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -3922,7 +14111,8 @@ func handleSpotifyShuffle(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyRepeat(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -3936,7 +14126,7 @@ func handleSpotifyRepeat(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := spotifyClient.SetRepeat(r.Context(), req.DeviceID, req.State); err != nil {
+	if err := client.SetRepeat(r.Context(), req.DeviceID, req.State); err != nil {
 		log.Printf("Error setting repeat: %v", err)
 		http.Error(w, "Failed to set repeat: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -3946,7 +14136,8 @@ func handleSpotifyRepeat(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyTransfer(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -3965,7 +14156,7 @@ func handleSpotifyTransfer(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := spotifyClient.TransferPlayback(r.Context(), req.DeviceID, req.Play); err != nil {
+	if err := client.TransferPlayback(r.Context(), req.DeviceID, req.Play); err != nil {
 		log.Printf("Error transferring playback: %v", err)
 		http.Error(w, "Failed to transfer playback: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -3975,7 +14166,8 @@ func handleSpotifyTransfer(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyPlaylists(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -3993,7 +14185,7 @@ func handleSpotifyPlaylists(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	playlists, total, err := spotifyClient.GetPlaylists(r.Context(), limit, offset)
+	playlists, total, err := client.GetPlaylists(r.Context(), limit, offset)
 	if err != nil {
 		log.Printf("Error getting playlists: %v", err)
 		http.Error(w, "Failed to get playlists: "+err.Error(), http.StatusInternalServerError)
@@ -4012,7 +14204,8 @@ func handleSpotifyPlaylists(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyPlaylistTracks(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4036,7 +14229,7 @@ func handleSpotifyPlaylistTracks(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	tracks, total, err := spotifyClient.GetPlaylistTracks(r.Context(), playlistID, limit, offset)
+	tracks, total, err := client.GetPlaylistTracks(r.Context(), playlistID, limit, offset)
 	if err != nil {
 		log.Printf("Error getting playlist tracks: %v", err)
 		http.Error(w, "Failed to get tracks: "+err.Error(), http.StatusInternalServerError)
@@ -4054,8 +14247,160 @@ func handleSpotifyPlaylistTracks(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
+// spotifyCanEditPlaylists checks the grant includes playlist editing, writing a 403 if not
+func spotifyCanEditPlaylists(w http.ResponseWriter, client *spotify.Client) bool {
+	if !client.HasScope("playlist-modify-private") {
+		http.Error(w, "Spotify authorization lacks the playlist-modify scopes; visit /auth/spotify to re-authorize", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// CreateSpotifyPlaylistRequest is the body for POST /api/spotify/playlists
+type CreateSpotifyPlaylistRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Public      bool   `json:"public"`
+}
+
+func handleSpotifyCreatePlaylist(w http.ResponseWriter, r *http.Request) {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
+		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
+		return
+	}
+	if !spotifyCanEditPlaylists(w, client) {
+		return
+	}
+
+	var req CreateSpotifyPlaylistRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(req.Name) == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
+		return
+	}
+
+	playlist, err := client.CreatePlaylist(r.Context(), req.Name, req.Description, req.Public)
+	if err != nil {
+		log.Printf("Error creating playlist: %v", err)
+		http.Error(w, "Failed to create playlist: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(playlist)
+}
+
+// SpotifyPlaylistTracksRequest is the body for POST/DELETE/PUT /api/spotify/playlist/{id}/tracks.
+// POST adds URIs (at Position, default the end), DELETE removes URIs and PUT moves
+// RangeLength tracks from RangeStart to before InsertBefore.
+type SpotifyPlaylistTracksRequest struct {
+	URIs         []string `json:"uris"`
+	Position     *int     `json:"position"`
+	RangeStart   int      `json:"range_start"`
+	InsertBefore int      `json:"insert_before"`
+	RangeLength  int      `json:"range_length"`
+	SnapshotID   string   `json:"snapshot_id"`
+}
+
+// decodePlaylistTracksRequest checks auth and scope and decodes the body, writing
+// an error if anything is wrong
+func decodePlaylistTracksRequest(w http.ResponseWriter, r *http.Request, needURIs bool) (SpotifyPlaylistTracksRequest, bool) {
+	var req SpotifyPlaylistTracksRequest
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
+		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
+		return req, false
+	}
+	if !spotifyCanEditPlaylists(w, client) {
+		return req, false
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return req, false
+	}
+	if needURIs && (len(req.URIs) == 0 || len(req.URIs) > spotify.MaxPlaylistItems) {
+		http.Error(w, fmt.Sprintf("uris must have 1 to %d items", spotify.MaxPlaylistItems), http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
+
+// writeSnapshot writes the playlist's new snapshot ID
+func writeSnapshot(w http.ResponseWriter, snapshotID string) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"snapshot_id": snapshotID})
+}
+
+func handleSpotifyAddPlaylistTracks(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodePlaylistTracksRequest(w, r, true)
+	if !ok {
+		return
+	}
+	client := spotifyClientFor(r)
+
+	position := -1
+	if req.Position != nil {
+		position = *req.Position
+	}
+	snapshot, err := client.AddTracksToPlaylist(r.Context(), chi.URLParam(r, "id"), req.URIs, position)
+	if err != nil {
+		log.Printf("Error adding playlist tracks: %v", err)
+		http.Error(w, "Failed to add tracks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeSnapshot(w, snapshot)
+}
+
+func handleSpotifyRemovePlaylistTracks(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodePlaylistTracksRequest(w, r, true)
+	if !ok {
+		return
+	}
+	client := spotifyClientFor(r)
+
+	snapshot, err := client.RemovePlaylistTracks(r.Context(), chi.URLParam(r, "id"), req.URIs, req.SnapshotID)
+	if err != nil {
+		log.Printf("Error removing playlist tracks: %v", err)
+		http.Error(w, "Failed to remove tracks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeSnapshot(w, snapshot)
+}
+
+func handleSpotifyReorderPlaylistTracks(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodePlaylistTracksRequest(w, r, false)
+	if !ok {
+		return
+	}
+	client := spotifyClientFor(r)
+	if req.RangeLength == 0 {
+		req.RangeLength = 1
+	}
+	if req.RangeStart < 0 || req.InsertBefore < 0 || req.RangeLength < 1 {
+		http.Error(w, "range_start and insert_before must be >= 0 and range_length >= 1", http.StatusBadRequest)
+		return
+	}
+
+	snapshot, err := client.ReorderPlaylistTracks(r.Context(), chi.URLParam(r, "id"), req.RangeStart, req.InsertBefore, req.RangeLength, req.SnapshotID)
+	if err != nil {
+		log.Printf("Error reordering playlist tracks: %v", err)
+		http.Error(w, "Failed to reorder tracks: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeSnapshot(w, snapshot)
+}
+
 func handleSpotifySearch(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4078,7 +14423,7 @@ func handleSpotifySearch(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	results, err := spotifyClient.Search(r.Context(), query, types, limit)
+	results, err := client.Search(r.Context(), query, types, limit)
 	if err != nil {
 		log.Printf("Error searching: %v", err)
 		http.Error(w, "Search failed: "+err.Error(), http.StatusInternalServerError)
@@ -4090,7 +14435,8 @@ func handleSpotifySearch(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyRecentlyPlayed(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4102,7 +14448,7 @@ func handleSpotifyRecentlyPlayed(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	items, err := spotifyClient.GetRecentlyPlayed(r.Context(), limit)
+	items, err := client.GetRecentlyPlayed(r.Context(), limit)
 	if err != nil {
 		log.Printf("Error getting recently played: %v", err)
 		http.Error(w, "Failed to get recently played: "+err.Error(), http.StatusInternalServerError)
@@ -4116,7 +14462,8 @@ func handleSpotifyRecentlyPlayed(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyTopArtists(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4133,7 +14480,7 @@ func handleSpotifyTopArtists(w http.ResponseWriter, r *http.Request) {
 		timeRange = "medium_term"
 	}
 
-	artists, err := spotifyClient.GetTopArtists(r.Context(), limit, timeRange)
+	artists, err := client.GetTopArtists(r.Context(), limit, timeRange)
 	if err != nil {
 		log.Printf("Error getting top artists: %v", err)
 		http.Error(w, "Failed to get top artists: "+err.Error(), http.StatusInternalServerError)
@@ -4147,7 +14494,8 @@ func handleSpotifyTopArtists(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyTopTracks(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4164,7 +14512,7 @@ func handleSpotifyTopTracks(w http.ResponseWriter, r *http.Request) {
 		timeRange = "medium_term"
 	}
 
-	tracks, err := spotifyClient.GetTopTracks(r.Context(), limit, timeRange)
+	tracks, err := client.GetTopTracks(r.Context(), limit, timeRange)
 	if err != nil {
 		log.Printf("Error getting top tracks: %v", err)
 		http.Error(w, "Failed to get top tracks: "+err.Error(), http.StatusInternalServerError)
@@ -4178,7 +14526,8 @@ func handleSpotifyTopTracks(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyAlbum(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4189,7 +14538,7 @@ func handleSpotifyAlbum(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	album, err := spotifyClient.GetAlbum(r.Context(), albumID)
+	album, err := client.GetAlbum(r.Context(), albumID)
 	if err != nil {
 		log.Printf("Error getting album: %v", err)
 		http.Error(w, "Failed to get album: "+err.Error(), http.StatusInternalServerError)
@@ -4201,7 +14550,8 @@ func handleSpotifyAlbum(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyArtist(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4212,7 +14562,7 @@ func handleSpotifyArtist(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	artist, err := spotifyClient.GetArtist(r.Context(), artistID)
+	artist, err := client.GetArtist(r.Context(), artistID)
 	if err != nil {
 		log.Printf("Error getting artist: %v", err)
 		http.Error(w, "Failed to get artist: "+err.Error(), http.StatusInternalServerError)
@@ -4224,7 +14574,8 @@ func handleSpotifyArtist(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyArtistAlbums(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4242,7 +14593,7 @@ func handleSpotifyArtistAlbums(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	albums, err := spotifyClient.GetArtistAlbums(r.Context(), artistID, limit)
+	albums, err := client.GetArtistAlbums(r.Context(), artistID, limit)
 	if err != nil {
 		log.Printf("Error getting artist albums: %v", err)
 		http.Error(w, "Failed to get artist albums: "+err.Error(), http.StatusInternalServerError)
@@ -4256,7 +14607,8 @@ func handleSpotifyArtistAlbums(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyArtistTopTracks(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4272,7 +14624,7 @@ func handleSpotifyArtistTopTracks(w http.ResponseWriter, r *http.Request) {
 		market = "US"
 	}
 
-	tracks, err := spotifyClient.GetArtistTopTracks(r.Context(), artistID, market)
+	tracks, err := client.GetArtistTopTracks(r.Context(), artistID, market)
 	if err != nil {
 		log.Printf("Error getting artist top tracks: %v", err)
 		http.Error(w, "Failed to get artist top tracks: "+err.Error(), http.StatusInternalServerError)
@@ -4286,7 +14638,8 @@ func handleSpotifyArtistTopTracks(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyAlbumSaved(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4297,7 +14650,7 @@ func handleSpotifyAlbumSaved(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	saved, err := spotifyClient.CheckAlbumSaved(r.Context(), albumID)
+	saved, err := client.CheckAlbumSaved(r.Context(), albumID)
 	if err != nil {
 		log.Printf("Error checking album saved: %v", err)
 		http.Error(w, "Failed to check album saved: "+err.Error(), http.StatusInternalServerError)
@@ -4309,7 +14662,8 @@ func handleSpotifyAlbumSaved(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyAlbumSave(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4320,7 +14674,7 @@ func handleSpotifyAlbumSave(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := spotifyClient.SaveAlbum(r.Context(), albumID); err != nil {
+	if err := client.SaveAlbum(r.Context(), albumID); err != nil {
 		log.Printf("Error saving album: %v", err)
 		http.Error(w, "Failed to save album: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -4330,7 +14684,8 @@ func handleSpotifyAlbumSave(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyAlbumRemove(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4341,7 +14696,7 @@ func handleSpotifyAlbumRemove(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := spotifyClient.RemoveAlbum(r.Context(), albumID); err != nil {
+	if err := client.RemoveAlbum(r.Context(), albumID); err != nil {
 		log.Printf("Error removing album: %v", err)
 		http.Error(w, "Failed to remove album: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -4351,7 +14706,8 @@ func handleSpotifyAlbumRemove(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyArtistFollowing(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4362,7 +14718,7 @@ func handleSpotifyArtistFollowing(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	following, err := spotifyClient.CheckFollowingArtist(r.Context(), artistID)
+	following, err := client.CheckFollowingArtist(r.Context(), artistID)
 	if err != nil {
 		log.Printf("Error checking artist following: %v", err)
 		http.Error(w, "Failed to check artist following: "+err.Error(), http.StatusInternalServerError)
@@ -4374,7 +14730,8 @@ func handleSpotifyArtistFollowing(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyArtistFollow(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4385,7 +14742,7 @@ func handleSpotifyArtistFollow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := spotifyClient.FollowArtist(r.Context(), artistID); err != nil {
+	if err := client.FollowArtist(r.Context(), artistID); err != nil {
 		log.Printf("Error following artist: %v", err)
 		http.Error(w, "Failed to follow artist: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -4395,7 +14752,8 @@ func handleSpotifyArtistFollow(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyArtistUnfollow(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4406,7 +14764,7 @@ func handleSpotifyArtistUnfollow(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := spotifyClient.UnfollowArtist(r.Context(), artistID); err != nil {
+	if err := client.UnfollowArtist(r.Context(), artistID); err != nil {
 		log.Printf("Error unfollowing artist: %v", err)
 		http.Error(w, "Failed to unfollow artist: "+err.Error(), http.StatusInternalServerError)
 		return
@@ -4416,7 +14774,8 @@ func handleSpotifyArtistUnfollow(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyLibraryAlbums(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4434,7 +14793,7 @@ func handleSpotifyLibraryAlbums(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	albums, total, err := spotifyClient.GetSavedAlbums(r.Context(), limit, offset)
+	albums, total, err := client.GetSavedAlbums(r.Context(), limit, offset)
 	if err != nil {
 		log.Printf("Error getting saved albums: %v", err)
 		http.Error(w, "Failed to get saved albums: "+err.Error(), http.StatusInternalServerError)
@@ -4451,7 +14810,8 @@ func handleSpotifyLibraryAlbums(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyLibraryArtists(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4464,7 +14824,7 @@ func handleSpotifyLibraryArtists(w http.ResponseWriter, r *http.Request) {
 	}
 	after := r.URL.Query().Get("after")
 
-	artists, nextAfter, err := spotifyClient.GetFollowedArtists(r.Context(), limit, after)
+	artists, nextAfter, err := client.GetFollowedArtists(r.Context(), limit, after)
 	if err != nil {
 		log.Printf("Error getting followed artists: %v", err)
 		http.Error(w, "Failed to get followed artists: "+err.Error(), http.StatusInternalServerError)
@@ -4479,7 +14839,8 @@ func handleSpotifyLibraryArtists(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyLibraryTracks(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4497,7 +14858,7 @@ func handleSpotifyLibraryTracks(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	tracks, total, err := spotifyClient.GetLikedSongs(r.Context(), limit, offset)
+	tracks, total, err := client.GetLikedSongs(r.Context(), limit, offset)
 	if err != nil {
 		log.Printf("Error getting liked songs: %v", err)
 		http.Error(w, "Failed to get liked songs: "+err.Error(), http.StatusInternalServerError)
@@ -4514,7 +14875,8 @@ func handleSpotifyLibraryTracks(w http.ResponseWriter, r *http.Request) {
 }
 
 func handleSpotifyLibraryShows(w http.ResponseWriter, r *http.Request) {
-	if spotifyClient == nil || !spotifyClient.IsAuthenticated() {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
 		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
 		return
 	}
@@ -4532,7 +14894,7 @@ func handleSpotifyLibraryShows(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	shows, total, err := spotifyClient.GetSavedShows(r.Context(), limit, offset)
+	shows, total, err := client.GetSavedShows(r.Context(), limit, offset)
 	if err != nil {
 		log.Printf("Error getting saved shows: %v", err)
 		http.Error(w, "Failed to get saved shows: "+err.Error(), http.StatusInternalServerError)
@@ -4548,45 +14910,116 @@ func handleSpotifyLibraryShows(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// loadCalendarPrefs loads calendar preferences from disk
-func loadCalendarPrefs() *CalendarPrefs {
-	prefs := &CalendarPrefs{
-		Calendars: make(map[string]CalendarPref),
+func handleSpotifyShowEpisodes(w http.ResponseWriter, r *http.Request) {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
+		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
+		return
 	}
 
-	if calendarPrefsFile == "" {
-		return prefs
+	limit := 50
+	offset := 0
+	if l := r.URL.Query().Get("limit"); l != "" {
+		if parsed, err := strconv.Atoi(l); err == nil {
+			limit = parsed
+		}
+	}
+	if o := r.URL.Query().Get("offset"); o != "" {
+		if parsed, err := strconv.Atoi(o); err == nil {
+			offset = parsed
+		}
 	}
 
-	data, err := os.ReadFile(calendarPrefsFile)
+	episodes, total, err := client.GetShowEpisodes(r.Context(), chi.URLParam(r, "id"), limit, offset)
 	if err != nil {
-		if !os.IsNotExist(err) {
-			log.Printf("Warning: Failed to read calendar prefs: %v", err)
-		}
+		log.Printf("Error getting show episodes: %v", err)
+		http.Error(w, "Failed to get episodes: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"items":  episodes,
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	})
+}
+
+func handleSpotifyEpisode(w http.ResponseWriter, r *http.Request) {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
+		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	episode, err := client.GetEpisode(r.Context(), chi.URLParam(r, "id"))
+	if err != nil {
+		log.Printf("Error getting episode: %v", err)
+		http.Error(w, "Failed to get episode: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(episode)
+}
+
+// handleSpotifyResumeEpisode plays an episode from its resume point
+func handleSpotifyResumeEpisode(w http.ResponseWriter, r *http.Request) {
+	client := spotifyClientFor(r)
+	if client == nil || !client.IsAuthenticated() {
+		http.Error(w, "Spotify not authenticated", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		DeviceID string `json:"device_id"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	episode, err := client.ResumeEpisode(r.Context(), req.DeviceID, chi.URLParam(r, "id"))
+	if err != nil {
+		log.Printf("Error resuming episode: %v", err)
+		http.Error(w, "Failed to resume episode: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if episode.Show != nil {
+		recordAction(r, actions.KindPlaylist, episode.Show.URI, "play")
+	}
+	broadcastSpotifyQueue(r)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// loadCalendarPrefs loads calendar preferences from the data store
+func loadCalendarPrefs() *CalendarPrefs {
+	prefs := &CalendarPrefs{
+		Calendars: make(map[string]CalendarPref),
+	}
+
+	if calendarPrefsDoc == nil {
 		return prefs
 	}
 
-	if err := json.Unmarshal(data, prefs); err != nil {
-		log.Printf("Warning: Failed to parse calendar prefs: %v", err)
+	if _, err := calendarPrefsDoc.Load(prefs); err != nil {
+		log.Printf("Warning: Failed to load calendar prefs: %v", err)
 		return &CalendarPrefs{Calendars: make(map[string]CalendarPref)}
 	}
+	if prefs.Calendars == nil {
+		prefs.Calendars = make(map[string]CalendarPref)
+	}
 
 	return prefs
 }
 
-// saveCalendarPrefs saves calendar preferences to disk
+// saveCalendarPrefs saves calendar preferences to the data store
 func saveCalendarPrefs() error {
-	if calendarPrefsFile == "" {
-		return fmt.Errorf("calendar prefs file not configured")
+	if calendarPrefsDoc == nil {
+		return fmt.Errorf("calendar prefs not configured")
 	}
 
-	data, err := json.MarshalIndent(calendarPrefs, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal calendar prefs: %w", err)
-	}
-
-	if err := os.WriteFile(calendarPrefsFile, data, 0644); err != nil {
-		return fmt.Errorf("failed to write calendar prefs: %w", err)
+	if err := calendarPrefsDoc.Save(calendarPrefs); err != nil {
+		return fmt.Errorf("failed to save calendar prefs: %w", err)
 	}
 
 	return nil
@@ -4598,13 +15031,16 @@ func getCachedCalendarsWithPrefs(ctx context.Context) ([]CalendarWithPrefs, erro
 	if time.Since(calendarCache.CalendarsUpdatedAt) < calendarCache.CacheDuration && len(calendarCache.Calendars) > 0 {
 		result := calendarCache.Calendars
 		calendarCache.RUnlock()
+		calendarCache.Counters.Hit()
 		return result, nil
 	}
 	calendarCache.RUnlock()
+	calendarCache.Counters.Miss()
 
 	// Cache miss or expired - fetch fresh data
 	calendars, err := getCalendarsWithPrefs(ctx)
 	if err != nil {
+		calendarCache.Counters.Error()
 		return nil, err
 	}
 
@@ -4638,9 +15074,11 @@ func getCachedEventsInRange(ctx context.Context, start, end time.Time) ([]*calen
 			}
 		}
 		calendarCache.RUnlock()
+		calendarCache.Counters.Hit()
 		return filtered, nil
 	}
 	calendarCache.RUnlock()
+	calendarCache.Counters.Miss()
 
 	// Cache miss - fetch fresh data with a wider range for future requests
 	// Always fetch 30 days to maximize cache hits
@@ -4653,6 +15091,7 @@ func getCachedEventsInRange(ctx context.Context, start, end time.Time) ([]*calen
 
 	events, err := calClient.GetEventsInRange(ctx, wideStart, wideEnd)
 	if err != nil {
+		calendarCache.Counters.Error()
 		return nil, err
 	}
 
@@ -4723,6 +15162,213 @@ func getCalendarsWithPrefs(ctx context.Context) ([]CalendarWithPrefs, error) {
 
 // Tablet ADB control handlers
 
+// handleSupport renders the remote support view
+func handleSupport(w http.ResponseWriter, r *http.Request) {
+	data := map[string]interface{}{
+		"Title":            "Support",
+		"TabletConfigured": tabletClient != nil,
+	}
+	getTemplate("support").ExecuteTemplate(w, "base", data)
+}
+
+// handleTabletScreenshot returns a PNG of the tablet's current screen
+func handleTabletScreenshot(w http.ResponseWriter, r *http.Request) {
+	if tabletClient == nil {
+		http.Error(w, "Tablet not configured", http.StatusServiceUnavailable)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+	defer cancel()
+
+	png, err := tabletClient.Screenshot(ctx)
+	if err != nil {
+		log.Printf("Error capturing tablet screenshot: %v", err)
+		http.Error(w, "Failed to capture screenshot: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(png)
+}
+
+// handleTabletClientLog receives the kiosk page's current location and recent JS errors
+func handleTabletClientLog(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Page     string              `json:"page"`
+		Viewport string              `json:"viewport"`
+		Errors   []tabletClientError `json:"errors"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid JSON: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	device := requestDeviceID(r)
+	now := time.Now()
+
+	tabletClients.Lock()
+	report, ok := tabletClients.reports[device]
+	if !ok {
+		report = &tabletClientReport{Device: device}
+		tabletClients.reports[device] = report
+	}
+	if req.Page != "" {
+		report.Page = req.Page
+	}
+	if req.Viewport != "" {
+		report.Viewport = req.Viewport
+	}
+	report.UserAgent = r.UserAgent()
+	report.LastSeen = now
+	for _, e := range req.Errors {
+		if e.Time.IsZero() {
+			e.Time = now
+		}
+		report.Errors = append(report.Errors, e)
+	}
+	if len(report.Errors) > maxTabletClientErrors {
+		report.Errors = append([]tabletClientError{}, report.Errors[len(report.Errors)-maxTabletClientErrors:]...)
+	}
+	tabletClients.Unlock()
+
+	if len(req.Errors) > 0 && clientLog != nil {
+		reports := make([]clientlog.Report, 0, len(req.Errors))
+		for _, e := range req.Errors {
+			reports = append(reports, clientlog.Report{
+				Time:      e.Time,
+				Platform:  clientlog.PlatformWeb,
+				Message:   e.Message,
+				Source:    e.Source,
+				Line:      e.Line,
+				Column:    e.Column,
+				Stack:     e.Stack,
+				Page:      req.Page,
+				UserAgent: r.UserAgent(),
+			})
+		}
+		if len(reports) > clientlog.MaxBatch {
+			reports = reports[len(reports)-clientlog.MaxBatch:]
+		}
+		if err := clientLog.Record(device, reports); err != nil {
+			log.Printf("Error recording tablet client errors: %v", err)
+		}
+	}
+
+	if len(req.Errors) > 0 {
+		log.Printf("Tablet client %s reported %d error(s) on %s: %s", device, len(req.Errors), req.Page, req.Errors[0].Message)
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePostClientLog ingests a batch of JS or Android error reports
+func handlePostClientLog(w http.ResponseWriter, r *http.Request) {
+	if clientLog == nil {
+		http.Error(w, "Client log not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req struct {
+		Device  string             `json:"device"`
+		Reports []clientlog.Report `json:"reports"`
+	}
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	device := req.Device
+	if device == "" {
+		device = requestDeviceID(r)
+	}
+	for i := range req.Reports {
+		if req.Reports[i].UserAgent == "" {
+			req.Reports[i].UserAgent = r.UserAgent()
+		}
+	}
+
+	if err := clientLog.Record(device, req.Reports); err != nil {
+		log.Printf("Error recording client log: %v", err)
+		http.Error(w, "Failed to record client log: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"accepted": len(req.Reports)})
+}
+
+// handleGetClientLog returns recent reports, optionally for one device (?device=&limit=)
+func handleGetClientLog(w http.ResponseWriter, r *http.Request) {
+	if clientLog == nil {
+		http.Error(w, "Client log not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	limit := 100
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 || n > 1000 {
+			http.Error(w, "limit must be between 1 and 1000", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(clientLog.Recent(r.URL.Query().Get("device"), limit))
+}
+
+// handleGetTabletSupport combines device status, metrics, sensors and client reports for remote debugging
+func handleGetTabletSupport(w http.ResponseWriter, r *http.Request) {
+	response := map[string]interface{}{
+		"configured": tabletClient != nil,
+	}
+
+	if tabletClient != nil {
+		ctx, cancel := context.WithTimeout(r.Context(), 15*time.Second)
+		defer cancel()
+
+		if status, err := tabletClient.GetStatus(ctx); err != nil {
+			response["statusError"] = err.Error()
+		} else {
+			response["status"] = status
+		}
+		if metrics, err := tabletClient.GetMetrics(ctx); err != nil {
+			response["metricsError"] = err.Error()
+		} else {
+			response["metrics"] = metrics
+		}
+	}
+
+	sensorState.RLock()
+	response["sensors"] = map[string]interface{}{
+		"proximityNear":   sensorState.ProximityNear,
+		"lightLevel":      sensorState.LightLevel,
+		"lastProximityAt": sensorState.LastProximityAt,
+		"lastLightAt":     sensorState.LastLightAt,
+		"screenIdleAt":    sensorState.ScreenIdleAt,
+	}
+	sensorState.RUnlock()
+
+	tabletClients.RLock()
+	clients := make([]tabletClientReport, 0, len(tabletClients.reports))
+	for _, report := range tabletClients.reports {
+		c := *report
+		c.Errors = append([]tabletClientError{}, report.Errors...)
+		clients = append(clients, c)
+	}
+	tabletClients.RUnlock()
+	sort.Slice(clients, func(i, j int) bool {
+		return clients[i].LastSeen.After(clients[j].LastSeen)
+	})
+	response["clients"] = clients
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
 func handleGetTabletStatus(w http.ResponseWriter, r *http.Request) {
 	if tabletClient == nil {
 		http.Error(w, "Tablet not configured", http.StatusServiceUnavailable)
@@ -4845,6 +15491,10 @@ func handleTabletProximity(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if sensorHistory != nil {
+		sensorHistory.RecordChange("tablet.proximity", boolToFloat(req.Near))
+	}
+
 	sensorState.Lock()
 	wasNear := sensorState.ProximityNear
 	sensorState.ProximityNear = req.Near
@@ -4854,6 +15504,14 @@ func handleTabletProximity(w http.ResponseWriter, r *http.Request) {
 		tabletIdleTimeout = time.Duration(req.IdleTimeout) * time.Second
 	}
 
+	if req.Near != wasNear {
+		from, to := "far", "near"
+		if !req.Near {
+			from, to = to, from
+		}
+		recordJournal(journal.Entry{Kind: journal.KindTablet, Subject: "tablet.proximity", From: from, To: to, Source: requestDeviceID(r)})
+	}
+
 	// Handle screen wake/sleep based on proximity
 	if req.Near && !wasNear {
 		// Someone approached - wake the screen
@@ -4914,6 +15572,10 @@ func handleTabletLight(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if sensorHistory != nil {
+		sensorHistory.Record("tablet.light", req.Lux)
+	}
+
 	sensorState.Lock()
 	sensorState.LightLevel = req.Lux
 	sensorState.LastLightAt = time.Now()
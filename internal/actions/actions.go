@@ -0,0 +1,91 @@
+package actions
+
+import (
+	"encoding/json"
+	"log"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Action kinds
+const (
+	KindEntity   = "entity"   // Home Assistant entity
+	KindLight    = "light"    // Hue light
+	KindGroup    = "group"    // Hue room/zone
+	KindScene    = "scene"    // Hue scene
+	KindPlaylist = "playlist" // Spotify context URI
+)
+
+// Action is a single user-initiated control action
+type Action struct {
+	Time    time.Time `json:"time"`
+	Device  string    `json:"device,omitempty"`  // Which kiosk/phone triggered it
+	Kind    string    `json:"kind"`              // entity, light, group, scene, playlist
+	Target  string    `json:"target"`            // Entity ID, Hue ID or Spotify URI
+	Service string    `json:"service,omitempty"` // e.g. toggle, lock, activate, play
+}
+
+// eventType is the store event type used for action history
+const eventType = "action"
+
+// Log keeps the action history in the store's event table
+type Log struct {
+	store     *store.Store
+	retention time.Duration
+}
+
+// NewLog creates an action log, pruning entries older than retention
+func NewLog(st *store.Store, retention time.Duration) *Log {
+	l := &Log{store: st, retention: retention}
+	if retention > 0 {
+		if n, err := st.PruneEvents(eventType, time.Now().Add(-retention)); err != nil {
+			log.Printf("Warning: Failed to prune action history: %v", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d old actions from history", n)
+		}
+	}
+	return l
+}
+
+// Record appends an action to the history
+func (l *Log) Record(a Action) {
+	if a.Time.IsZero() {
+		a.Time = time.Now()
+	}
+	if _, err := l.store.AppendEvent(a.Time, eventType, a.Device, a); err != nil {
+		log.Printf("Warning: Failed to record action: %v", err)
+	}
+}
+
+// Since returns all actions at or after t, oldest first
+func (l *Log) Since(t time.Time) []Action {
+	events, err := l.store.Events(eventType, t, 0)
+	if err != nil {
+		log.Printf("Warning: Failed to read action history: %v", err)
+		return nil
+	}
+	return decode(events)
+}
+
+// Recent returns up to limit of the most recent actions, newest first
+func (l *Log) Recent(limit int) []Action {
+	events, err := l.store.RecentEvents(eventType, limit)
+	if err != nil {
+		log.Printf("Warning: Failed to read action history: %v", err)
+		return []Action{}
+	}
+	return decode(events)
+}
+
+func decode(events []store.Event) []Action {
+	result := make([]Action, 0, len(events))
+	for _, e := range events {
+		var a Action
+		if err := json.Unmarshal(e.Data, &a); err != nil {
+			continue
+		}
+		result = append(result, a)
+	}
+	return result
+}
@@ -34,6 +34,15 @@ type DeviceStatus struct {
 	LightLevel      float64 `json:"lightLevel"`
 }
 
+// DeviceMetrics holds diagnostic information used for remote support
+type DeviceMetrics struct {
+	Uptime         string `json:"uptime"`
+	MemTotalKB     int    `json:"memTotalKb"`
+	MemAvailableKB int    `json:"memAvailableKb"`
+	ForegroundApp  string `json:"foregroundApp"` // package/activity currently resumed
+	WifiRSSI       int    `json:"wifiRssi,omitempty"`
+}
+
 // NewClient creates a new ADB client for the given device
 func NewClient(deviceAddr string) *Client {
 	return &Client{
@@ -312,6 +321,25 @@ func (c *Client) exec(ctx context.Context, args ...string) (string, error) {
 	return strings.TrimSpace(stdout.String()), nil
 }
 
+// execRaw runs an ADB command and returns the untrimmed binary output
+func (c *Client) execRaw(ctx context.Context, args ...string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	fullArgs := append([]string{"-s", c.deviceAddr}, args...)
+	cmd := exec.CommandContext(ctx, "adb", fullArgs...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("adb command failed: %v, stderr: %s", err, stderr.String())
+	}
+
+	return stdout.Bytes(), nil
+}
+
 // shell runs a shell command on the device
 func (c *Client) shell(ctx context.Context, cmd string) (string, error) {
 	return c.exec(ctx, "shell", cmd)
@@ -375,6 +403,79 @@ func (c *Client) GetStatus(ctx context.Context) (*DeviceStatus, error) {
 	return status, nil
 }
 
+// Screenshot captures the current screen as PNG
+func (c *Client) Screenshot(ctx context.Context) ([]byte, error) {
+	png, err := c.execRaw(ctx, "exec-out", "screencap", "-p")
+	if err != nil {
+		return nil, err
+	}
+	if len(png) == 0 {
+		return nil, fmt.Errorf("empty screenshot")
+	}
+	return png, nil
+}
+
+// GetMetrics returns uptime, memory, foreground app and Wi-Fi signal.
+// Individual values are left empty if the device doesn't report them.
+func (c *Client) GetMetrics(ctx context.Context) (*DeviceMetrics, error) {
+	if !c.IsConnected(ctx) {
+		return nil, fmt.Errorf("device not connected")
+	}
+	metrics := &DeviceMetrics{}
+
+	// Uptime: "12345.67 23456.78"
+	out, err := c.shell(ctx, "cat /proc/uptime")
+	if err == nil {
+		if fields := strings.Fields(out); len(fields) > 0 {
+			if secs, err := strconv.ParseFloat(fields[0], 64); err == nil {
+				metrics.Uptime = (time.Duration(secs) * time.Second).String()
+			}
+		}
+	}
+
+	// Memory: "MemTotal:  3825472 kB"
+	out, err = c.shell(ctx, "cat /proc/meminfo")
+	if err == nil {
+		for _, line := range strings.Split(out, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) < 2 {
+				continue
+			}
+			kb, _ := strconv.Atoi(fields[1])
+			switch fields[0] {
+			case "MemTotal:":
+				metrics.MemTotalKB = kb
+			case "MemAvailable:":
+				metrics.MemAvailableKB = kb
+			}
+		}
+	}
+
+	// Foreground app: "mResumedActivity: ActivityRecord{abc u0 com.example/.MainActivity t12}"
+	out, err = c.shell(ctx, "dumpsys activity activities | grep -m1 mResumedActivity")
+	if err == nil {
+		for _, field := range strings.Fields(out) {
+			if strings.Contains(field, "/") {
+				metrics.ForegroundApp = strings.TrimSuffix(field, "}")
+				break
+			}
+		}
+	}
+
+	// Wi-Fi signal: "... RSSI: -55, ..."
+	out, err = c.shell(ctx, "dumpsys wifi | grep -m1 'RSSI:'")
+	if err == nil {
+		if idx := strings.Index(out, "RSSI:"); idx >= 0 {
+			fields := strings.FieldsFunc(out[idx+len("RSSI:"):], func(r rune) bool { return r == ',' || r == ' ' })
+			if len(fields) > 0 {
+				metrics.WifiRSSI, _ = strconv.Atoi(fields[0])
+			}
+		}
+	}
+
+	return metrics, nil
+}
+
 // WakeScreen turns the screen on
 func (c *Client) WakeScreen(ctx context.Context) error {
 	_, err := c.shell(ctx, "input keyevent KEYCODE_WAKEUP")
@@ -0,0 +1,327 @@
+package alarms
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/daycontext"
+	"home_control/internal/store"
+)
+
+// timeLayout is the format of Alarm.At
+const timeLayout = "15:04"
+
+// Defaults for a new alarm's volume ramp
+const (
+	DefaultStartVolume = 10
+	DefaultEndVolume   = 50
+	DefaultRampMinutes = 10
+)
+
+// MaxSunriseMinutes caps the sunrise fade; Hue transitions can't run longer
+const MaxSunriseMinutes = 60
+
+// Alarm is a wake-up that starts Spotify on a device at a low volume and
+// raises it gradually, optionally fading in a Hue scene beforehand
+type Alarm struct {
+	ID             string         `json:"id"`
+	Name           string         `json:"name"`
+	Enabled        bool           `json:"enabled"`
+	At             string         `json:"at"`                 // HH:MM the music starts
+	Days           []time.Weekday `json:"days,omitempty"`     // Empty = every day
+	DayKinds       []string       `json:"dayKinds,omitempty"` // Day kinds or tags (workday, school_day, ...) it goes off on; empty = any
+	Account        string         `json:"account,omitempty"`  // Spotify account; empty = default
+	Device         string         `json:"device"`             // Spotify device ID or name
+	URI            string         `json:"uri"`                // Playlist, album or track URI
+	Shuffle        bool           `json:"shuffle"`
+	StartVolume    int            `json:"startVolume"`              // Percent
+	EndVolume      int            `json:"endVolume"`                // Percent
+	RampMinutes    int            `json:"rampMinutes"`              // Time to go from StartVolume to EndVolume
+	Scene          string         `json:"scene,omitempty"`          // hue:<sceneID> or scene.*
+	SunriseMinutes int            `json:"sunriseMinutes,omitempty"` // Fade a hue: scene in over this long, ending at At
+}
+
+// Job is a scheduler entry for an alarm
+type Job struct {
+	ID      string // "alarm:<id>" or "alarm:<id>:sunrise"
+	At      string
+	Days    []time.Weekday
+	Name    string
+	Alarm   Alarm
+	Sunrise bool // Start the sunrise rather than the music
+}
+
+// Manager keeps the alarm list
+type Manager struct {
+	mu       sync.RWMutex
+	doc      *store.Doc
+	alarms   []Alarm
+	day      func(t time.Time) []string
+	onChange func()
+}
+
+// NewManager loads alarms from the store
+func NewManager(doc *store.Doc) *Manager {
+	m := &Manager{doc: doc, alarms: []Alarm{}}
+	if _, err := doc.Load(&m.alarms); err != nil {
+		log.Printf("Warning: Failed to load alarms: %v", err)
+	}
+	return m
+}
+
+// SetDaySource sets the function that reports a date's day kind and tags,
+// checked against Alarm.DayKinds by Runs
+func (m *Manager) SetDaySource(fn func(t time.Time) []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.day = fn
+}
+
+// Runs reports whether a scheduled job should go off at now under its alarm's
+// DayKinds. A sunrise is checked against the day the music starts, which is
+// the next day when it begins before midnight.
+func (m *Manager) Runs(job Job, now time.Time) bool {
+	m.mu.RLock()
+	day := m.day
+	m.mu.RUnlock()
+	if len(job.Alarm.DayKinds) == 0 || day == nil {
+		return true
+	}
+	if job.Sunrise {
+		now = now.Add(time.Duration(job.Alarm.SunriseMinutes) * time.Minute)
+	}
+	if tags := day(now); !daycontext.Matches(job.Alarm.DayKinds, tags) {
+		log.Printf("Alarm %s: skipped on a %s", job.Alarm.Name, strings.Join(tags, "/"))
+		return false
+	}
+	return true
+}
+
+// OnChange registers a callback invoked after alarms are modified
+func (m *Manager) OnChange(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// List returns alarms ordered by time
+func (m *Manager) List() []Alarm {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := append([]Alarm{}, m.alarms...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].At < result[j].At
+	})
+	return result
+}
+
+// Get returns an alarm by ID
+func (m *Manager) Get(id string) (Alarm, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if idx := m.indexOf(id); idx >= 0 {
+		return m.alarms[idx], true
+	}
+	return Alarm{}, false
+}
+
+// Create adds a new alarm
+func (m *Manager) Create(a Alarm) (*Alarm, error) {
+	if err := validate(&a); err != nil {
+		return nil, err
+	}
+	a.ID = newID()
+
+	m.mu.Lock()
+	m.alarms = append(m.alarms, a)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &a, nil
+}
+
+// Update replaces an alarm
+func (m *Manager) Update(id string, a Alarm) (*Alarm, error) {
+	if err := validate(&a); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("alarm not found: %s", id)
+	}
+	a.ID = id
+	m.alarms[idx] = a
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &a, nil
+}
+
+// Delete removes an alarm
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("alarm not found: %s", id)
+	}
+	m.alarms = append(m.alarms[:idx], m.alarms[idx+1:]...)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.notify()
+	return nil
+}
+
+// Jobs returns the scheduler entries for enabled alarms: one for the music and,
+// with a sunrise, one SunriseMinutes earlier for the lights. A sunrise that
+// starts before midnight runs on the previous day.
+func (m *Manager) Jobs() []Job {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var jobs []Job
+	for _, a := range m.alarms {
+		if !a.Enabled {
+			continue
+		}
+		jobs = append(jobs, Job{ID: "alarm:" + a.ID, At: a.At, Days: a.Days, Name: "Alarm: " + a.Name, Alarm: a})
+		if a.SunriseMinutes > 0 {
+			at, days := sunriseStart(a.At, a.Days, a.SunriseMinutes)
+			jobs = append(jobs, Job{ID: "alarm:" + a.ID + ":sunrise", At: at, Days: days, Name: "Alarm: " + a.Name + " (sunrise)", Alarm: a, Sunrise: true})
+		}
+	}
+	return jobs
+}
+
+// VolumeAt returns the volume an alarm's ramp has reached after elapsed
+func (a Alarm) VolumeAt(elapsed time.Duration) int {
+	ramp := time.Duration(a.RampMinutes) * time.Minute
+	if ramp <= 0 || elapsed >= ramp {
+		return a.EndVolume
+	}
+	if elapsed <= 0 {
+		return a.StartVolume
+	}
+	return a.StartVolume + int(float64(a.EndVolume-a.StartVolume)*float64(elapsed)/float64(ramp))
+}
+
+// validate checks an alarm and fills in defaults
+func validate(a *Alarm) error {
+	a.Name = strings.TrimSpace(a.Name)
+	if a.Name == "" {
+		a.Name = "Alarm"
+	}
+	if t, err := time.Parse(timeLayout, a.At); err != nil || t.Format(timeLayout) != a.At {
+		return fmt.Errorf("at must be HH:MM")
+	}
+	for _, d := range a.Days {
+		if d < time.Sunday || d > time.Saturday {
+			return fmt.Errorf("invalid day %d", d)
+		}
+	}
+	for _, kind := range a.DayKinds {
+		if !daycontext.Valid(kind) {
+			return fmt.Errorf("unknown day kind %q", kind)
+		}
+	}
+	if a.URI == "" {
+		return fmt.Errorf("uri is required")
+	}
+	if !strings.HasPrefix(a.URI, "spotify:") {
+		return fmt.Errorf("uri must be a Spotify URI")
+	}
+	if a.Device == "" {
+		return fmt.Errorf("device is required")
+	}
+
+	if a.StartVolume == 0 && a.EndVolume == 0 {
+		a.StartVolume, a.EndVolume = DefaultStartVolume, DefaultEndVolume
+	}
+	if a.StartVolume < 0 || a.StartVolume > 100 || a.EndVolume < 0 || a.EndVolume > 100 {
+		return fmt.Errorf("volumes must be between 0 and 100")
+	}
+	if a.RampMinutes == 0 && a.StartVolume != a.EndVolume {
+		a.RampMinutes = DefaultRampMinutes
+	}
+	if a.RampMinutes < 0 || a.RampMinutes > 120 {
+		return fmt.Errorf("rampMinutes must be between 0 and 120")
+	}
+
+	if a.SunriseMinutes < 0 || a.SunriseMinutes > MaxSunriseMinutes {
+		return fmt.Errorf("sunriseMinutes must be between 0 and %d", MaxSunriseMinutes)
+	}
+	if a.SunriseMinutes > 0 && !strings.HasPrefix(a.Scene, "hue:") {
+		return fmt.Errorf("a sunrise needs a hue: scene")
+	}
+	return nil
+}
+
+// sunriseStart returns when a sunrise of minutes must start to finish at at,
+// shifting the days back when it starts the evening before
+func sunriseStart(at string, days []time.Weekday, minutes int) (string, []time.Weekday) {
+	t, _ := time.Parse(timeLayout, at)
+	start := t.Add(-time.Duration(minutes) * time.Minute)
+	if start.Day() == t.Day() || len(days) == 0 {
+		return start.Format(timeLayout), days
+	}
+	shifted := make([]time.Weekday, len(days))
+	for i, d := range days {
+		shifted[i] = (d + 6) % 7
+	}
+	return start.Format(timeLayout), shifted
+}
+
+// indexOf finds an alarm by ID (caller must hold the lock)
+func (m *Manager) indexOf(id string) int {
+	for i, a := range m.alarms {
+		if a.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save persists alarms (caller must hold the lock)
+func (m *Manager) save() error {
+	if err := m.doc.Save(m.alarms); err != nil {
+		return fmt.Errorf("failed to save alarms: %w", err)
+	}
+	return nil
+}
+
+func (m *Manager) notify() {
+	m.mu.RLock()
+	fn := m.onChange
+	m.mu.RUnlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// newID generates a short random alarm ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
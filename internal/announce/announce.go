@@ -0,0 +1,320 @@
+package announce
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Priorities; a higher priority announcement jumps the queue but never cuts
+// one already playing short
+const (
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
+var priorityRank = map[string]int{PriorityNormal: 0, PriorityHigh: 1, PriorityUrgent: 2}
+
+// ChimeNone announces without a chime
+const ChimeNone = "none"
+
+// Chimes are the pre-chime sounds the kiosks can play
+var Chimes = []string{"chime", "ding", "bell", ChimeNone}
+
+// DefaultDuration is how long an announcement stays on screen, in seconds
+const DefaultDuration = 15
+
+// MaxDuration caps how long an announcement holds the queue, in seconds
+const MaxDuration = 120
+
+// chimeTime is allowed for the chime before the message shows
+const chimeTime = 2 * time.Second
+
+// historySize is how many announcements are kept for delivery status
+const historySize = 20
+
+// Announcement statuses
+const (
+	StatusQueued  = "queued"
+	StatusPlaying = "playing"
+	StatusDone    = "done"
+)
+
+// Request is an announcement to make
+type Request struct {
+	Message  string   `json:"message"`
+	Rooms    []string `json:"rooms,omitempty"`    // Rooms from ANNOUNCE_ROOMS
+	Kiosks   []string `json:"kiosks,omitempty"`   // Kiosk names; no rooms or kiosks = every tablet
+	Chime    string   `json:"chime,omitempty"`    // Pre-chime sound (default from config), or "none"
+	Priority string   `json:"priority,omitempty"` // normal (default), high or urgent
+	Duration int      `json:"duration,omitempty"` // Seconds on screen (default 15)
+	Source   string   `json:"source,omitempty"`   // Who or what announced it
+}
+
+// Delivery is an announcement's delivery to one kiosk
+type Delivery struct {
+	Kiosk     string     `json:"kiosk"`
+	Connected bool       `json:"connected"` // Kiosk was connected when it was sent
+	PlayedAt  *time.Time `json:"playedAt,omitempty"`
+}
+
+// Announcement is a queued or delivered announcement
+type Announcement struct {
+	ID         string     `json:"id"`
+	Message    string     `json:"message"`
+	Kiosks     []string   `json:"kiosks,omitempty"` // Targets; empty = every tablet
+	Chime      string     `json:"chime"`
+	Priority   string     `json:"priority"`
+	Duration   int        `json:"duration"`
+	Source     string     `json:"source,omitempty"`
+	Status     string     `json:"status"`
+	QueuedAt   time.Time  `json:"queuedAt"`
+	SentAt     *time.Time `json:"sentAt,omitempty"`
+	Deliveries []Delivery `json:"deliveries"`
+}
+
+// Sender delivers an announcement to its kiosks (every connected one when it
+// has no targets) and reports where it went
+type Sender func(a Announcement) []Delivery
+
+// Queue plays announcements one at a time, in priority order
+type Queue struct {
+	rooms        map[string][]string
+	defaultChime string
+	send         Sender
+
+	mu       sync.Mutex
+	queue    []*Announcement
+	history  []*Announcement // Sent, newest last
+	wake     chan struct{}
+	onChange func(Announcement)
+}
+
+// NewQueue creates an announcement queue. rooms maps room names to kiosk names.
+func NewQueue(rooms map[string][]string, defaultChime string, send Sender) *Queue {
+	if defaultChime == "" || !slices.Contains(Chimes, defaultChime) {
+		defaultChime = Chimes[0]
+	}
+	return &Queue{
+		rooms:        rooms,
+		defaultChime: defaultChime,
+		send:         send,
+		wake:         make(chan struct{}, 1),
+	}
+}
+
+// OnChange registers a callback invoked when an announcement is queued, sent,
+// played on a kiosk or finished
+func (q *Queue) OnChange(fn func(Announcement)) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.onChange = fn
+}
+
+// Rooms returns the configured rooms and their kiosks
+func (q *Queue) Rooms() map[string][]string {
+	return q.rooms
+}
+
+// Announce validates and queues an announcement
+func (q *Queue) Announce(req Request) (Announcement, error) {
+	req.Message = strings.TrimSpace(req.Message)
+	if req.Message == "" {
+		return Announcement{}, fmt.Errorf("message is required")
+	}
+	if req.Priority == "" {
+		req.Priority = PriorityNormal
+	}
+	if _, ok := priorityRank[req.Priority]; !ok {
+		return Announcement{}, fmt.Errorf("priority must be normal, high or urgent")
+	}
+	if req.Chime == "" {
+		req.Chime = q.defaultChime
+	}
+	if !slices.Contains(Chimes, req.Chime) {
+		return Announcement{}, fmt.Errorf("unknown chime %q (use %s)", req.Chime, strings.Join(Chimes, ", "))
+	}
+	if req.Duration == 0 {
+		req.Duration = DefaultDuration
+	}
+	if req.Duration < 1 || req.Duration > MaxDuration {
+		return Announcement{}, fmt.Errorf("duration must be between 1 and %d seconds", MaxDuration)
+	}
+
+	kiosks := append([]string{}, req.Kiosks...)
+	for _, room := range req.Rooms {
+		members, ok := q.rooms[room]
+		if !ok {
+			return Announcement{}, fmt.Errorf("unknown room %q", room)
+		}
+		kiosks = append(kiosks, members...)
+	}
+	sort.Strings(kiosks)
+	kiosks = slices.Compact(kiosks)
+
+	a := &Announcement{
+		ID:         newID(),
+		Message:    req.Message,
+		Kiosks:     kiosks,
+		Chime:      req.Chime,
+		Priority:   req.Priority,
+		Duration:   req.Duration,
+		Source:     req.Source,
+		Status:     StatusQueued,
+		QueuedAt:   time.Now(),
+		Deliveries: []Delivery{},
+	}
+
+	q.mu.Lock()
+	// Behind everything of the same or higher priority
+	i := len(q.queue)
+	for i > 0 && priorityRank[q.queue[i-1].Priority] < priorityRank[a.Priority] {
+		i--
+	}
+	q.queue = slices.Insert(q.queue, i, a)
+	snapshot := a.copy()
+	q.mu.Unlock()
+
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	q.notify(snapshot)
+	return snapshot, nil
+}
+
+// Played records that a kiosk played an announcement
+func (q *Queue) Played(id, kiosk string) error {
+	q.mu.Lock()
+	a := q.find(id)
+	if a == nil {
+		q.mu.Unlock()
+		return fmt.Errorf("announcement not found")
+	}
+	now := time.Now()
+	i := slices.IndexFunc(a.Deliveries, func(d Delivery) bool { return d.Kiosk == kiosk })
+	if i < 0 {
+		a.Deliveries = append(a.Deliveries, Delivery{Kiosk: kiosk, Connected: true})
+		i = len(a.Deliveries) - 1
+	}
+	if a.Deliveries[i].PlayedAt == nil {
+		a.Deliveries[i].PlayedAt = &now
+	}
+	snapshot := a.copy()
+	q.mu.Unlock()
+
+	q.notify(snapshot)
+	return nil
+}
+
+// Recent returns the queued announcements followed by the latest sent ones,
+// newest first
+func (q *Queue) Recent() []Announcement {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	result := make([]Announcement, 0, len(q.queue)+len(q.history))
+	for _, a := range q.queue {
+		result = append(result, a.copy())
+	}
+	for i := len(q.history) - 1; i >= 0; i-- {
+		result = append(result, q.history[i].copy())
+	}
+	return result
+}
+
+// Run sends queued announcements, waiting for each to finish before the next.
+// It blocks, so call it in a goroutine.
+func (q *Queue) Run() {
+	for {
+		q.mu.Lock()
+		if len(q.queue) == 0 {
+			q.mu.Unlock()
+			<-q.wake
+			continue
+		}
+		a := q.queue[0]
+		q.queue = q.queue[1:]
+		q.mu.Unlock()
+
+		deliveries := q.send(a.copy())
+		now := time.Now()
+
+		q.mu.Lock()
+		a.Status = StatusPlaying
+		a.SentAt = &now
+		// Keep acknowledgements that raced in ahead of the send returning
+		for _, d := range deliveries {
+			if !slices.ContainsFunc(a.Deliveries, func(existing Delivery) bool { return existing.Kiosk == d.Kiosk }) {
+				a.Deliveries = append(a.Deliveries, d)
+			}
+		}
+		q.history = append(q.history, a)
+		if len(q.history) > historySize {
+			q.history = q.history[len(q.history)-historySize:]
+		}
+		snapshot := a.copy()
+		q.mu.Unlock()
+		q.notify(snapshot)
+		log.Printf("Announcement: %q to %s", a.Message, describeTargets(a.Kiosks))
+
+		wait := time.Duration(a.Duration) * time.Second
+		if a.Chime != ChimeNone {
+			wait += chimeTime
+		}
+		time.Sleep(wait)
+
+		q.mu.Lock()
+		a.Status = StatusDone
+		snapshot = a.copy()
+		q.mu.Unlock()
+		q.notify(snapshot)
+	}
+}
+
+// find returns a queued or sent announcement (caller must hold the lock)
+func (q *Queue) find(id string) *Announcement {
+	for _, list := range [][]*Announcement{q.queue, q.history} {
+		for _, a := range list {
+			if a.ID == id {
+				return a
+			}
+		}
+	}
+	return nil
+}
+
+func (q *Queue) notify(a Announcement) {
+	q.mu.Lock()
+	fn := q.onChange
+	q.mu.Unlock()
+	if fn != nil {
+		fn(a)
+	}
+}
+
+func (a *Announcement) copy() Announcement {
+	c := *a
+	c.Kiosks = slices.Clone(a.Kiosks)
+	c.Deliveries = slices.Clone(a.Deliveries)
+	return c
+}
+
+func describeTargets(kiosks []string) string {
+	if len(kiosks) == 0 {
+		return "every tablet"
+	}
+	return strings.Join(kiosks, ", ")
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
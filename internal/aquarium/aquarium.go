@@ -0,0 +1,502 @@
+package aquarium
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Tank kinds
+const (
+	KindAquarium  = "aquarium"
+	KindTerrarium = "terrarium"
+)
+
+// Reading statuses
+const (
+	StatusOK      = "ok"
+	StatusLow     = "low"
+	StatusHigh    = "high"
+	StatusUnknown = "unknown" // No reading yet, or the sensor is unavailable
+)
+
+// Probe is a sensor watched against a safe range. Entity is a Home Assistant
+// sensor or an MQTT virtual sensor (mqtt.<id>) for controllers that publish
+// over MQTT.
+type Probe struct {
+	Name   string   `json:"name"`           // e.g. "Water temperature"
+	Kind   string   `json:"kind,omitempty"` // temperature, ph, humidity, ...
+	Entity string   `json:"entity"`
+	Unit   string   `json:"unit,omitempty"`
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+}
+
+// Window is a daily on period, e.g. lights 09:00-21:00. Off before On runs past midnight.
+type Window struct {
+	On  string `json:"on"`  // HH:MM
+	Off string `json:"off"` // HH:MM
+}
+
+// Device is a light, pump, heater or mister switched on a schedule
+type Device struct {
+	ID      string   `json:"id"` // Slug, derived from the name when empty
+	Name    string   `json:"name"`
+	Entity  string   `json:"entity"` // HA switch/light entity
+	Windows []Window `json:"windows,omitempty"`
+}
+
+// Tank is an aquarium or terrarium
+type Tank struct {
+	ID      string   `json:"id"`
+	Name    string   `json:"name"`
+	Kind    string   `json:"kind"`
+	Probes  []Probe  `json:"probes,omitempty"`
+	Devices []Device `json:"devices,omitempty"`
+}
+
+// Reading is a probe's latest value and how it compares to its range
+type Reading struct {
+	Probe
+	Value     *float64  `json:"value,omitempty"`
+	Status    string    `json:"status"`
+	UpdatedAt time.Time `json:"updatedAt,omitempty"`
+}
+
+// TankStatus is a tank with its current readings, as returned by the API
+type TankStatus struct {
+	Tank
+	Readings []Reading `json:"readings"`
+}
+
+// Switch is a scheduled device change, registered with the scheduler
+type Switch struct {
+	ID     string `json:"id"` // aquarium:<tank>:<device>:<n>:on|off
+	Name   string `json:"name"`
+	Entity string `json:"entity"`
+	At     string `json:"at"`
+	On     bool   `json:"on"`
+}
+
+// Manager keeps tanks, polls their probes and raises threshold alerts
+type Manager struct {
+	mu       sync.RWMutex
+	doc      *store.Doc
+	tanks    []Tank
+	readings map[string]Reading // tank ID + "/" + probe entity
+	read     func(entity string) (float64, error)
+	onChange func()
+	onAlert  func(tank Tank, r Reading, previous string)
+}
+
+// NewManager loads tanks from the store
+func NewManager(doc *store.Doc) *Manager {
+	m := &Manager{
+		doc:      doc,
+		readings: make(map[string]Reading),
+	}
+	if _, err := doc.Load(&m.tanks); err != nil {
+		log.Printf("Warning: Failed to load aquariums: %v", err)
+	}
+	return m
+}
+
+// SetReader sets the function that reads a probe entity's numeric value
+func (m *Manager) SetReader(fn func(entity string) (float64, error)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.read = fn
+}
+
+// OnChange registers a callback invoked after tanks are modified
+func (m *Manager) OnChange(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// OnAlert registers a callback invoked when a probe goes out of range or comes
+// back into it. previous is the status before the change.
+func (m *Manager) OnAlert(fn func(tank Tank, r Reading, previous string)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onAlert = fn
+}
+
+// List returns all tanks with their readings, ordered by name
+func (m *Manager) List() []TankStatus {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]TankStatus, 0, len(m.tanks))
+	for _, t := range m.tanks {
+		result = append(result, m.status(t))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+	})
+	return result
+}
+
+// Get returns a tank with its readings
+func (m *Manager) Get(id string) (*TankStatus, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		return nil, false
+	}
+	s := m.status(m.tanks[idx])
+	return &s, true
+}
+
+// Device returns a tank's device by ID
+func (m *Manager) Device(tankID, deviceID string) (*Device, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	idx := m.indexOf(tankID)
+	if idx < 0 {
+		return nil, false
+	}
+	for _, d := range m.tanks[idx].Devices {
+		if d.ID == deviceID {
+			return &d, true
+		}
+	}
+	return nil, false
+}
+
+// Create adds a tank
+func (m *Manager) Create(t Tank) (*Tank, error) {
+	if err := validate(&t); err != nil {
+		return nil, err
+	}
+	t.ID = newID()
+
+	m.mu.Lock()
+	m.tanks = append(m.tanks, t)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &t, nil
+}
+
+// Update replaces a tank's settings, keeping its ID
+func (m *Manager) Update(id string, t Tank) (*Tank, error) {
+	if err := validate(&t); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("tank not found: %s", id)
+	}
+	t.ID = id
+	m.tanks[idx] = t
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &t, nil
+}
+
+// Delete removes a tank
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("tank not found: %s", id)
+	}
+	m.tanks = append(m.tanks[:idx], m.tanks[idx+1:]...)
+	for key := range m.readings {
+		if strings.HasPrefix(key, id+"/") {
+			delete(m.readings, key)
+		}
+	}
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.notify()
+	return nil
+}
+
+// Switches returns the scheduled on/off changes of every device
+func (m *Manager) Switches() []Switch {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []Switch
+	for _, t := range m.tanks {
+		for _, d := range t.Devices {
+			for i, w := range d.Windows {
+				prefix := fmt.Sprintf("aquarium:%s:%s:%d", t.ID, d.ID, i)
+				name := t.Name + " " + d.Name
+				result = append(result,
+					Switch{ID: prefix + ":on", Name: name + " on", Entity: d.Entity, At: w.On, On: true},
+					Switch{ID: prefix + ":off", Name: name + " off", Entity: d.Entity, At: w.Off, On: false})
+			}
+		}
+	}
+	return result
+}
+
+// Desired returns whether each scheduled device should be on at t, used to
+// catch up after a restart. Devices without windows are left out.
+func (m *Manager) Desired(t time.Time) map[string]bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := t.Format("15:04")
+	result := make(map[string]bool)
+	for _, tank := range m.tanks {
+		for _, d := range tank.Devices {
+			if len(d.Windows) == 0 {
+				continue
+			}
+			on := result[d.Entity]
+			for _, w := range d.Windows {
+				if w.On <= w.Off {
+					on = on || (now >= w.On && now < w.Off)
+				} else {
+					on = on || now >= w.On || now < w.Off
+				}
+			}
+			result[d.Entity] = on
+		}
+	}
+	return result
+}
+
+// Run polls every probe each interval. It blocks, so call it in a goroutine.
+func (m *Manager) Run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.poll()
+		<-ticker.C
+	}
+}
+
+// poll reads all probes and reports range changes
+func (m *Manager) poll() {
+	m.mu.RLock()
+	read := m.read
+	tanks := append([]Tank{}, m.tanks...)
+	m.mu.RUnlock()
+	if read == nil {
+		return
+	}
+
+	type alert struct {
+		tank     Tank
+		reading  Reading
+		previous string
+	}
+	var alerts []alert
+	for _, t := range tanks {
+		for _, p := range t.Probes {
+			r := Reading{Probe: p, Status: StatusUnknown}
+			if v, err := read(p.Entity); err == nil {
+				r.Value = &v
+				r.Status = rangeStatus(p, v)
+				r.UpdatedAt = time.Now()
+			}
+
+			key := t.ID + "/" + p.Entity
+			m.mu.Lock()
+			previous, known := m.readings[key]
+			if r.Status == StatusUnknown && known {
+				// Keep the last value through a missed read
+				r.Value, r.Status, r.UpdatedAt = previous.Value, previous.Status, previous.UpdatedAt
+			}
+			m.readings[key] = r
+			m.mu.Unlock()
+
+			prevStatus := StatusOK
+			if known && previous.Status != StatusUnknown {
+				prevStatus = previous.Status
+			}
+			if r.Status != StatusUnknown && r.Status != prevStatus {
+				alerts = append(alerts, alert{tank: t, reading: r, previous: prevStatus})
+			}
+		}
+	}
+
+	m.mu.RLock()
+	fn := m.onAlert
+	m.mu.RUnlock()
+	if fn == nil {
+		return
+	}
+	for _, a := range alerts {
+		fn(a.tank, a.reading, a.previous)
+	}
+}
+
+// Label describes a reading, e.g. "Water temperature 23.5 °C (low)"
+func (r Reading) Label() string {
+	if r.Value == nil {
+		return r.Name + " unavailable"
+	}
+	label := strings.TrimSpace(fmt.Sprintf("%s %.1f %s", r.Name, *r.Value, r.Unit))
+	switch r.Status {
+	case StatusLow:
+		label += fmt.Sprintf(" (below %.1f)", *r.Min)
+	case StatusHigh:
+		label += fmt.Sprintf(" (above %.1f)", *r.Max)
+	}
+	return label
+}
+
+func rangeStatus(p Probe, v float64) string {
+	switch {
+	case p.Min != nil && v < *p.Min:
+		return StatusLow
+	case p.Max != nil && v > *p.Max:
+		return StatusHigh
+	}
+	return StatusOK
+}
+
+// status attaches current readings to a tank (caller must hold the lock)
+func (m *Manager) status(t Tank) TankStatus {
+	s := TankStatus{Tank: t, Readings: make([]Reading, 0, len(t.Probes))}
+	for _, p := range t.Probes {
+		r, ok := m.readings[t.ID+"/"+p.Entity]
+		if !ok {
+			r = Reading{Status: StatusUnknown}
+		}
+		r.Probe = p // Settings may have changed since the reading
+		if r.Value != nil {
+			r.Status = rangeStatus(p, *r.Value)
+		}
+		s.Readings = append(s.Readings, r)
+	}
+	return s
+}
+
+func validate(t *Tank) error {
+	t.Name = strings.TrimSpace(t.Name)
+	if t.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	switch t.Kind {
+	case "":
+		t.Kind = KindAquarium
+	case KindAquarium, KindTerrarium:
+	default:
+		return fmt.Errorf("invalid kind: %s", t.Kind)
+	}
+
+	for i := range t.Probes {
+		p := &t.Probes[i]
+		if p.Entity == "" {
+			return fmt.Errorf("probe entity is required")
+		}
+		if p.Name == "" {
+			p.Name = p.Entity
+		}
+		if p.Min != nil && p.Max != nil && *p.Min > *p.Max {
+			return fmt.Errorf("%s: min must be below max", p.Name)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i := range t.Devices {
+		d := &t.Devices[i]
+		d.Name = strings.TrimSpace(d.Name)
+		if d.Name == "" || d.Entity == "" {
+			return fmt.Errorf("device name and entity are required")
+		}
+		if d.ID == "" {
+			d.ID = slug(d.Name)
+		}
+		if seen[d.ID] {
+			return fmt.Errorf("duplicate device: %s", d.ID)
+		}
+		seen[d.ID] = true
+		for j := range d.Windows {
+			w := &d.Windows[j]
+			// Stored zero-padded so times compare as strings
+			for _, at := range []*string{&w.On, &w.Off} {
+				parsed, err := time.Parse("15:04", *at)
+				if err != nil {
+					return fmt.Errorf("%s: invalid time (use HH:MM): %s", d.Name, *at)
+				}
+				*at = parsed.Format("15:04")
+			}
+			if w.On == w.Off {
+				return fmt.Errorf("%s: on and off times must differ", d.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// slug turns "Main Light" into "main_light"
+func slug(name string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(name) {
+		switch {
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'):
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "_"):
+			b.WriteByte('_')
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+// indexOf finds a tank by ID (caller must hold the lock)
+func (m *Manager) indexOf(id string) int {
+	for i, t := range m.tanks {
+		if t.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save persists tanks (caller must hold the lock)
+func (m *Manager) save() error {
+	if err := m.doc.Save(m.tanks); err != nil {
+		return fmt.Errorf("failed to save aquariums: %w", err)
+	}
+	return nil
+}
+
+func (m *Manager) notify() {
+	m.mu.RLock()
+	fn := m.onChange
+	m.mu.RUnlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// newID generates a short random tank ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,175 @@
+package arrivalcam
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// DefaultSeconds is how long a camera is shown when a rule doesn't say
+const DefaultSeconds = 60
+
+// DefaultLabels are the detection labels that count as an arrival
+var DefaultLabels = []string{"car"}
+
+// timeLayout is the format of Rule.From and Rule.To
+const timeLayout = "15:04"
+
+// Rule shows a camera picture-in-picture on a kiosk when an arrival is detected
+type Rule struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	Enabled  bool     `json:"enabled"`
+	Camera   string   `json:"camera"`           // Camera to show, and to watch for detections
+	Kiosk    string   `json:"kiosk,omitempty"`  // Kiosk name (?kiosk= on the tablet); empty = every kiosk
+	Seconds  int      `json:"seconds"`          // How long to show it
+	From     string   `json:"from,omitempty"`   // HH:MM; with To, only show inside this window
+	To       string   `json:"to,omitempty"`     // HH:MM; may wrap past midnight
+	Labels   []string `json:"labels,omitempty"` // Detection labels from Camera (default car)
+	Arrivals bool     `json:"arrivals"`         // Also show on presence/geofence arrivals
+}
+
+// Show is a camera to put on screen
+type Show struct {
+	Rule    string `json:"rule"`
+	Camera  string `json:"camera"`
+	Kiosk   string `json:"kiosk,omitempty"`
+	Seconds int    `json:"seconds"`
+	Reason  string `json:"reason"`
+}
+
+// Manager holds the rules and decides which cameras to show
+type Manager struct {
+	timezone *time.Location
+
+	mu    sync.Mutex
+	doc   *store.Doc
+	rules []Rule
+	shown map[string]time.Time // Rule ID -> last shown, so one arrival shows once
+}
+
+// NewManager loads the rules from the store
+func NewManager(doc *store.Doc, timezone *time.Location) *Manager {
+	if timezone == nil {
+		timezone = time.Local
+	}
+	m := &Manager{
+		timezone: timezone,
+		doc:      doc,
+		rules:    []Rule{},
+		shown:    make(map[string]time.Time),
+	}
+	if _, err := doc.Load(&m.rules); err != nil {
+		log.Printf("Warning: Failed to load arrival camera rules: %v", err)
+	}
+	return m
+}
+
+// Rules returns all rules
+func (m *Manager) Rules() []Rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Rule{}, m.rules...)
+}
+
+// Update validates and replaces the rules. Rules without an ID get one.
+func (m *Manager) Update(rules []Rule) error {
+	if rules == nil {
+		rules = []Rule{}
+	}
+	for i := range rules {
+		r := &rules[i]
+		if r.Camera == "" {
+			return fmt.Errorf("rule %q: camera is required", r.Name)
+		}
+		if r.Seconds == 0 {
+			r.Seconds = DefaultSeconds
+		}
+		if r.Seconds < 5 || r.Seconds > 600 {
+			return fmt.Errorf("rule %q: seconds must be between 5 and 600", r.Name)
+		}
+		if (r.From == "") != (r.To == "") {
+			return fmt.Errorf("rule %q: set both from and to, or neither", r.Name)
+		}
+		for _, t := range []string{r.From, r.To} {
+			if _, err := time.Parse(timeLayout, t); t != "" && (err != nil || len(t) != len(timeLayout)) {
+				return fmt.Errorf("rule %q: times must be HH:MM", r.Name)
+			}
+		}
+		if r.ID == "" {
+			r.ID = newID()
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.doc.Save(rules); err != nil {
+		return fmt.Errorf("failed to save arrival camera rules: %w", err)
+	}
+	m.rules = rules
+	return nil
+}
+
+// Detection returns the cameras to show for an object detected by a camera
+func (m *Manager) Detection(camera, label string, t time.Time) []Show {
+	return m.match(t, "Detected "+label+" on "+camera, func(r Rule) bool {
+		labels := r.Labels
+		if len(labels) == 0 {
+			labels = DefaultLabels
+		}
+		return r.Camera == camera && slices.ContainsFunc(labels, func(l string) bool {
+			return strings.EqualFold(l, label)
+		})
+	})
+}
+
+// Arrival returns the cameras to show when someone arrives home
+func (m *Manager) Arrival(name string, t time.Time) []Show {
+	return m.match(t, name+" arrived", func(r Rule) bool { return r.Arrivals })
+}
+
+// match returns a Show for each enabled rule that matches, is inside its time
+// window and hasn't just been shown
+func (m *Manager) match(t time.Time, reason string, matches func(Rule) bool) []Show {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var shows []Show
+	for _, r := range m.rules {
+		if !r.Enabled || !matches(r) || !m.inWindow(r, t) {
+			continue
+		}
+		// A car pulling in usually fires both a detection and an arrival
+		if last, ok := m.shown[r.ID]; ok && t.Sub(last) < time.Duration(r.Seconds)*time.Second {
+			continue
+		}
+		m.shown[r.ID] = t
+		shows = append(shows, Show{Rule: r.ID, Camera: r.Camera, Kiosk: r.Kiosk, Seconds: r.Seconds, Reason: reason})
+	}
+	return shows
+}
+
+// inWindow reports whether t is inside a rule's From-To window
+func (m *Manager) inWindow(r Rule, t time.Time) bool {
+	if r.From == "" {
+		return true
+	}
+	now := t.In(m.timezone).Format(timeLayout)
+	if r.From <= r.To {
+		return now >= r.From && now < r.To
+	}
+	return now >= r.From || now < r.To
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
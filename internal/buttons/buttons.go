@@ -0,0 +1,310 @@
+package buttons
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+
+	"home_control/internal/housemode"
+	"home_control/internal/store"
+)
+
+// Button event sources
+const (
+	SourceMQTT = "mqtt" // Zigbee2MQTT / Aqara etc. (device = topic)
+	SourceHue  = "hue"  // Hue dimmer/smart button (device = sensor ID)
+	SourceAPI  = "api"  // POST /api/buttons/press (e.g. from HA automations)
+)
+
+// Action types a button can trigger
+const (
+	ActionNavigate = "navigate" // Kiosk page navigation (target = path, e.g. /home)
+	ActionScene    = "scene"    // Activate a Hue scene (target = scene ID)
+	ActionAnnounce = "announce" // Show an announcement on kiosks (target = message)
+	ActionToggle   = "toggle"   // Toggle a Home Assistant entity (target = entity ID)
+	ActionPublish  = "publish"  // Publish an MQTT message (target = topic, payload = message)
+	ActionStart    = "start"    // Start an appliance program (target = appliance ID, payload = program)
+	ActionHeat     = "heat"     // Heat a pool or hot tub (target = pool ID, payload = optional setpoint)
+	ActionMode     = "mode"     // Set the house mode (target = home, away, night or vacation)
+	ActionEgress   = "egress"   // Emergency egress mode (target = start, drill or clear)
+)
+
+// Egress action targets
+const (
+	EgressStart = "start"
+	EgressDrill = "drill"
+	EgressClear = "clear"
+)
+
+// Action is what happens when a mapped button is pressed
+type Action struct {
+	Type    string `json:"type"`
+	Target  string `json:"target"`
+	Payload string `json:"payload,omitempty"` // Message body for publish actions
+
+	// UnlockDoors lets an egress start or drill unlock the egress doors;
+	// without it the door steps are skipped, since buttons can't enter a PIN
+	UnlockDoors bool `json:"unlockDoors,omitempty"`
+}
+
+// Mapping ties a button event to an action
+type Mapping struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name,omitempty"`
+	Source string   `json:"source"`          // mqtt, hue, api
+	Device string   `json:"device"`          // MQTT topic, Hue sensor ID or free-form name
+	Event  string   `json:"event,omitempty"` // e.g. "single", "1002"; empty matches any event
+	Modes  []string `json:"modes,omitempty"` // House modes the mapping applies in; empty = any
+	Action Action   `json:"action"`
+}
+
+// Press is a button event received from a source
+type Press struct {
+	Source string `json:"source"`
+	Device string `json:"device"`
+	Event  string `json:"event"`
+}
+
+// Manager holds the mapping table and dispatches presses to the action handler
+type Manager struct {
+	mu       sync.RWMutex
+	doc      *store.Doc
+	mappings []Mapping
+	handler  func(Mapping, Press)
+	mode     func() string
+	onChange func([]Mapping)
+}
+
+// NewManager loads button mappings from the store
+func NewManager(doc *store.Doc) *Manager {
+	m := &Manager{doc: doc}
+	if _, err := doc.Load(&m.mappings); err != nil {
+		log.Printf("Warning: Failed to load button mappings: %v", err)
+	}
+	return m
+}
+
+// SetActionHandler sets the function that performs mapped actions
+func (m *Manager) SetActionHandler(fn func(Mapping, Press)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.handler = fn
+}
+
+// SetModeSource sets the function that reports the current house mode, checked
+// against Mapping.Modes when a button is pressed
+func (m *Manager) SetModeSource(fn func() string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.mode = fn
+}
+
+// OnChange registers a callback invoked with the new table after mappings change
+// (used to resync MQTT subscriptions)
+func (m *Manager) OnChange(fn func([]Mapping)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// List returns all mappings
+func (m *Manager) List() []Mapping {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Mapping{}, m.mappings...)
+}
+
+// Get returns a mapping by ID
+func (m *Manager) Get(id string) (*Mapping, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		return nil, false
+	}
+	mapping := m.mappings[idx]
+	return &mapping, true
+}
+
+// Create adds a mapping
+func (m *Manager) Create(mapping Mapping) (*Mapping, error) {
+	if err := validate(mapping); err != nil {
+		return nil, err
+	}
+	mapping.ID = newID()
+
+	m.mu.Lock()
+	m.mappings = append(m.mappings, mapping)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &mapping, nil
+}
+
+// Update replaces a mapping
+func (m *Manager) Update(id string, mapping Mapping) (*Mapping, error) {
+	if err := validate(mapping); err != nil {
+		return nil, err
+	}
+	mapping.ID = id
+
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("mapping not found: %s", id)
+	}
+	m.mappings[idx] = mapping
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &mapping, nil
+}
+
+// Delete removes a mapping
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("mapping not found: %s", id)
+	}
+	m.mappings = append(m.mappings[:idx], m.mappings[idx+1:]...)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.notify()
+	return nil
+}
+
+// Handle runs every mapping matching the press. Returns the number of actions run.
+func (m *Manager) Handle(p Press) int {
+	m.mu.RLock()
+	handler := m.handler
+	mode := ""
+	if m.mode != nil {
+		mode = m.mode()
+	}
+	var matched []Mapping
+	for _, mapping := range m.mappings {
+		if mapping.Source == p.Source && mapping.Device == p.Device &&
+			(mapping.Event == "" || strings.EqualFold(mapping.Event, p.Event)) &&
+			(len(mapping.Modes) == 0 || mode == "" || slices.Contains(mapping.Modes, mode)) {
+			matched = append(matched, mapping)
+		}
+	}
+	m.mu.RUnlock()
+
+	if handler == nil {
+		return 0
+	}
+	for _, mapping := range matched {
+		log.Printf("Button %s/%s %q -> %s %s", p.Source, p.Device, p.Event, mapping.Action.Type, mapping.Action.Target)
+		handler(mapping, p)
+	}
+	return len(matched)
+}
+
+// ParseMQTTEvent extracts the button event from an MQTT payload. Zigbee2MQTT publishes
+// plain strings on <device>/action or JSON with an "action" (or "click") field on <device>.
+func ParseMQTTEvent(payload []byte) string {
+	var data map[string]interface{}
+	if err := json.Unmarshal(payload, &data); err == nil {
+		for _, field := range []string{"action", "click", "event"} {
+			if v, ok := data[field].(string); ok && v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	return strings.TrimSpace(string(payload))
+}
+
+func validate(mapping Mapping) error {
+	switch mapping.Source {
+	case SourceMQTT, SourceHue, SourceAPI:
+	default:
+		return fmt.Errorf("invalid source: %s", mapping.Source)
+	}
+	if mapping.Device == "" {
+		return fmt.Errorf("device is required")
+	}
+	switch mapping.Action.Type {
+	case ActionNavigate, ActionScene, ActionAnnounce, ActionToggle, ActionPublish, ActionStart, ActionHeat:
+	case ActionMode:
+		if !housemode.Valid(mapping.Action.Target) {
+			return fmt.Errorf("invalid house mode: %s", mapping.Action.Target)
+		}
+	case ActionEgress:
+		switch mapping.Action.Target {
+		case EgressStart, EgressDrill, EgressClear:
+		default:
+			return fmt.Errorf("invalid egress target: %s", mapping.Action.Target)
+		}
+	default:
+		return fmt.Errorf("invalid action type: %s", mapping.Action.Type)
+	}
+	if mapping.Action.Target == "" {
+		return fmt.Errorf("action target is required")
+	}
+	if mapping.Action.UnlockDoors && mapping.Action.Type != ActionEgress {
+		return fmt.Errorf("unlockDoors only applies to egress actions")
+	}
+	for _, mode := range mapping.Modes {
+		if !housemode.Valid(mode) {
+			return fmt.Errorf("invalid house mode: %s", mode)
+		}
+	}
+	return nil
+}
+
+// indexOf finds a mapping by ID (caller must hold the lock)
+func (m *Manager) indexOf(id string) int {
+	for i, mapping := range m.mappings {
+		if mapping.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save persists mappings (caller must hold the lock)
+func (m *Manager) save() error {
+	if err := m.doc.Save(m.mappings); err != nil {
+		return fmt.Errorf("failed to save button mappings: %w", err)
+	}
+	return nil
+}
+
+func (m *Manager) notify() {
+	m.mu.RLock()
+	fn := m.onChange
+	mappings := append([]Mapping{}, m.mappings...)
+	m.mu.RUnlock()
+	if fn != nil {
+		fn(mappings)
+	}
+}
+
+// newID generates a short random mapping ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
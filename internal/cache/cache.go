@@ -0,0 +1,206 @@
+package cache
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Stats describes a cache's configuration and effectiveness
+type Stats struct {
+	Name        string    `json:"name"`
+	TTLSeconds  float64   `json:"ttlSeconds"`
+	Hits        uint64    `json:"hits"`
+	Misses      uint64    `json:"misses"`
+	Errors      uint64    `json:"errors"` // Failed fetches (not cached)
+	HitRatio    float64   `json:"hitRatio"`
+	Entries     int       `json:"entries"`
+	LastRefresh time.Time `json:"lastRefresh,omitempty"`
+}
+
+// Tunable is a cache that can be observed and retuned at runtime
+type Tunable interface {
+	Name() string
+	Stats() Stats
+	SetTTL(ttl time.Duration)
+	InvalidateAll()
+}
+
+// Counters tracks hits/misses for caches that manage their own storage
+type Counters struct {
+	hits, misses, errors atomic.Uint64
+}
+
+// Hit records a cache hit
+func (c *Counters) Hit() { c.hits.Add(1) }
+
+// Miss records a cache miss
+func (c *Counters) Miss() { c.misses.Add(1) }
+
+// Error records a failed fetch
+func (c *Counters) Error() { c.errors.Add(1) }
+
+// Fill copies the counters into s and computes the hit ratio
+func (c *Counters) Fill(s *Stats) {
+	s.Hits = c.hits.Load()
+	s.Misses = c.misses.Load()
+	s.Errors = c.errors.Load()
+	if total := s.Hits + s.Misses; total > 0 {
+		s.HitRatio = float64(s.Hits) / float64(total)
+	}
+}
+
+type entry[V any] struct {
+	value   V
+	fetched time.Time
+}
+
+// TTL is a keyed cache whose entries expire after a tunable duration.
+// A TTL of zero disables caching (every Get fetches). Concurrent misses for
+// the same key share a single fetch.
+type TTL[V any] struct {
+	name string
+	Counters
+	group singleflight.Group
+
+	mu          sync.Mutex
+	ttl         time.Duration
+	entries     map[string]entry[V]
+	lastRefresh time.Time
+}
+
+// New creates a TTL cache
+func New[V any](name string, ttl time.Duration) *TTL[V] {
+	return &TTL[V]{
+		name:    name,
+		ttl:     ttl,
+		entries: make(map[string]entry[V]),
+	}
+}
+
+// Name returns the cache name
+func (c *TTL[V]) Name() string {
+	return c.name
+}
+
+// Get returns the cached value for key, calling fetch if it is missing or expired.
+// Fetch errors are returned and not cached.
+func (c *TTL[V]) Get(key string, fetch func() (V, error)) (V, error) {
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	ttl := c.ttl
+	c.mu.Unlock()
+
+	if ok && ttl > 0 && time.Since(e.fetched) < ttl {
+		c.Hit()
+		return e.value, nil
+	}
+	c.Miss()
+	return c.load(key, fetch)
+}
+
+// Refresh fetches and caches a fresh value for key regardless of its age.
+// A fetch already in flight for key is joined rather than repeated.
+func (c *TTL[V]) Refresh(key string, fetch func() (V, error)) (V, error) {
+	c.Miss()
+	return c.load(key, fetch)
+}
+
+// load runs fetch once for all concurrent callers of key and stores the result
+func (c *TTL[V]) load(key string, fetch func() (V, error)) (V, error) {
+	v, err, _ := c.group.Do(key, func() (interface{}, error) {
+		value, err := fetch()
+		if err != nil {
+			c.Error()
+			return value, err
+		}
+
+		now := time.Now()
+		c.mu.Lock()
+		c.entries[key] = entry[V]{value: value, fetched: now}
+		c.lastRefresh = now
+		c.mu.Unlock()
+		return value, nil
+	})
+	value, _ := v.(V)
+	return value, err
+}
+
+// Invalidate drops a single entry
+func (c *TTL[V]) Invalidate(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
+
+// InvalidateAll drops every entry
+func (c *TTL[V]) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = make(map[string]entry[V])
+}
+
+// SetTTL changes how long entries stay fresh
+func (c *TTL[V]) SetTTL(ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ttl = ttl
+}
+
+// Stats returns the cache's current statistics
+func (c *TTL[V]) Stats() Stats {
+	c.mu.Lock()
+	s := Stats{
+		Name:        c.name,
+		TTLSeconds:  c.ttl.Seconds(),
+		Entries:     len(c.entries),
+		LastRefresh: c.lastRefresh,
+	}
+	c.mu.Unlock()
+	c.Fill(&s)
+	return s
+}
+
+// Registry holds the caches exposed for tuning
+type Registry struct {
+	mu     sync.RWMutex
+	caches map[string]Tunable
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{caches: make(map[string]Tunable)}
+}
+
+// Register adds a cache to the registry
+func (r *Registry) Register(c Tunable) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.caches[c.Name()] = c
+}
+
+// Get returns a registered cache by name
+func (r *Registry) Get(name string) (Tunable, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	c, ok := r.caches[name]
+	return c, ok
+}
+
+// Stats returns statistics for every registered cache, sorted by name
+func (r *Registry) Stats() []Stats {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	result := make([]Stats, 0, len(r.caches))
+	for _, c := range r.caches {
+		result = append(result, c.Stats())
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
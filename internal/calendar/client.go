@@ -2,11 +2,9 @@ package calendar
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"sort"
 	"sync"
 	"time"
@@ -15,11 +13,13 @@ import (
 	"golang.org/x/oauth2/google"
 	gcal "google.golang.org/api/calendar/v3"
 	"google.golang.org/api/option"
+
+	"home_control/internal/store"
 )
 
 type Client struct {
 	config      *oauth2.Config
-	tokenFile   string
+	tokenDoc    *store.Doc
 	service     *gcal.Service
 	calendarIDs []string
 	timezone    *time.Location
@@ -69,7 +69,7 @@ type CalendarInfo struct {
 	Color string `json:"color,omitempty"`
 }
 
-func NewClient(clientID, clientSecret, redirectURL, tokenFile string, calendarIDs []string, timezone *time.Location) *Client {
+func NewClient(clientID, clientSecret, redirectURL string, tokenDoc *store.Doc, calendarIDs []string, timezone *time.Location) *Client {
 	config := &oauth2.Config{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
@@ -84,7 +84,7 @@ func NewClient(clientID, clientSecret, redirectURL, tokenFile string, calendarID
 
 	return &Client{
 		config:      config,
-		tokenFile:   tokenFile,
+		tokenDoc:    tokenDoc,
 		calendarIDs: calendarIDs,
 		timezone:    timezone,
 		eventCache:  make(map[string]*eventCacheEntry),
@@ -92,6 +92,12 @@ func NewClient(clientID, clientSecret, redirectURL, tokenFile string, calendarID
 	}
 }
 
+// AddScopes requests additional OAuth scopes (e.g. Classroom) for services sharing
+// the Google token. Takes effect the next time the user authorizes at /auth/google.
+func (c *Client) AddScopes(scopes ...string) {
+	c.config.Scopes = append(c.config.Scopes, scopes...)
+}
+
 // GetAuthURL returns the URL for user to authorize the app
 func (c *Client) GetAuthURL(state string) string {
 	return c.config.AuthCodeURL(state, oauth2.AccessTypeOffline, oauth2.ApprovalForce)
@@ -872,24 +878,17 @@ func (c *Client) GetConfiguredCalendars(ctx context.Context) ([]CalendarInfo, er
 }
 
 func (c *Client) saveToken(token *oauth2.Token) error {
-	f, err := os.Create(c.tokenFile)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	return json.NewEncoder(f).Encode(token)
+	return c.tokenDoc.Save(token)
 }
 
 func (c *Client) loadToken() (*oauth2.Token, error) {
-	f, err := os.Open(c.tokenFile)
+	var token oauth2.Token
+	ok, err := c.tokenDoc.Load(&token)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-
-	var token oauth2.Token
-	if err := json.NewDecoder(f).Decode(&token); err != nil {
-		return nil, err
+	if !ok {
+		return nil, fmt.Errorf("no Google token stored")
 	}
 	return &token, nil
 }
@@ -897,8 +896,7 @@ func (c *Client) loadToken() (*oauth2.Token, error) {
 // ClearToken removes the stored token to force re-authorization
 func (c *Client) ClearToken() error {
 	c.service = nil
-	err := os.Remove(c.tokenFile)
-	if err != nil && !os.IsNotExist(err) {
+	if err := c.tokenDoc.Delete(); err != nil {
 		return err
 	}
 	log.Println("Token cleared, re-authorization required")
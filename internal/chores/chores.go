@@ -0,0 +1,322 @@
+package chores
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+	"home_control/internal/tasks"
+)
+
+// ListID is the pseudo task list chores appear under in the tasks panel
+const ListID = "chores"
+
+// Chore is a recurring household job rotated between family members
+type Chore struct {
+	ID         string           `json:"id"`
+	Title      string           `json:"title"`
+	Notes      string           `json:"notes,omitempty"`
+	Members    []string         `json:"members"`    // Rotation order
+	Current    int              `json:"current"`    // Index into Members of who is up
+	Recurrence tasks.Recurrence `json:"recurrence"` // How often the chore comes due
+	Due        time.Time        `json:"due"`
+	LastDoneAt time.Time        `json:"lastDoneAt,omitempty"`
+	LastDoneBy string           `json:"lastDoneBy,omitempty"`
+}
+
+// Assignee returns who is currently responsible for the chore
+func (c Chore) Assignee() string {
+	if len(c.Members) == 0 {
+		return ""
+	}
+	return c.Members[c.Current%len(c.Members)]
+}
+
+// NextAssignee returns who is up after the current assignee
+func (c Chore) NextAssignee() string {
+	if len(c.Members) == 0 {
+		return ""
+	}
+	return c.Members[(c.Current+1)%len(c.Members)]
+}
+
+// Task converts the chore into the tasks panel model so both can be rendered together
+func (c Chore) Task() tasks.Task {
+	title := c.Title
+	if assignee := c.Assignee(); assignee != "" {
+		title = fmt.Sprintf("%s (%s)", c.Title, assignee)
+	}
+	rule := c.Recurrence
+	return tasks.Task{
+		ID:         c.ID,
+		ListID:     ListID,
+		Title:      title,
+		Notes:      c.Notes,
+		Due:        c.Due,
+		Recurrence: &rule,
+	}
+}
+
+// Status is a chore with its rotation resolved, as returned by the API
+type Status struct {
+	Chore
+	Assignee     string `json:"assignee"`
+	NextAssignee string `json:"nextAssignee"`
+	Overdue      bool   `json:"overdue"`
+}
+
+// Manager keeps the chore list
+type Manager struct {
+	mu       sync.RWMutex
+	doc      *store.Doc
+	chores   []Chore
+	timezone *time.Location
+	onChange func()
+}
+
+// NewManager loads chores from the store
+func NewManager(doc *store.Doc, timezone *time.Location) *Manager {
+	if timezone == nil {
+		timezone = time.Local
+	}
+	m := &Manager{doc: doc, timezone: timezone}
+
+	if _, err := doc.Load(&m.chores); err != nil {
+		log.Printf("Warning: Failed to load chores: %v", err)
+	}
+	return m
+}
+
+// OnChange registers a callback invoked after chores are modified
+func (m *Manager) OnChange(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// List returns all chores ordered by due date
+func (m *Manager) List() []Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	today := m.today()
+	result := make([]Status, 0, len(m.chores))
+	for _, c := range m.chores {
+		result = append(result, Status{
+			Chore:        c,
+			Assignee:     c.Assignee(),
+			NextAssignee: c.NextAssignee(),
+			Overdue:      c.Due.Before(today),
+		})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Due.Before(result[j].Due)
+	})
+	return result
+}
+
+// Tasks returns all chores in the tasks panel model
+func (m *Manager) Tasks() []tasks.Task {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]tasks.Task, 0, len(m.chores))
+	for _, c := range m.chores {
+		result = append(result, c.Task())
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Due.Before(result[j].Due)
+	})
+	return result
+}
+
+// Get returns a chore by ID
+func (m *Manager) Get(id string) (*Chore, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	idx := m.indexOf(id)
+	if idx < 0 {
+		return nil, fmt.Errorf("chore not found: %s", id)
+	}
+	c := m.chores[idx]
+	return &c, nil
+}
+
+// Create adds a new chore. If Due is zero the chore is due today.
+func (m *Manager) Create(c Chore) (*Chore, error) {
+	if err := m.validate(&c); err != nil {
+		return nil, err
+	}
+	c.ID = newID()
+	if c.Due.IsZero() {
+		c.Due = m.today()
+	}
+	c.Recurrence.Anchor(c.Due)
+
+	m.mu.Lock()
+	m.chores = append(m.chores, c)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &c, nil
+}
+
+// Update replaces a chore's definition, keeping its ID and completion history
+func (m *Manager) Update(id string, c Chore) (*Chore, error) {
+	if err := m.validate(&c); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("chore not found: %s", id)
+	}
+	existing := m.chores[idx]
+	c.ID = existing.ID
+	c.LastDoneAt = existing.LastDoneAt
+	c.LastDoneBy = existing.LastDoneBy
+	if c.Due.IsZero() {
+		c.Due = existing.Due
+		if c.Recurrence.AnchorDay == 0 {
+			c.Recurrence.AnchorDay = existing.Recurrence.AnchorDay // Due may already be clamped
+		}
+	}
+	c.Recurrence.Anchor(c.Due)
+	if len(c.Members) > 0 {
+		c.Current = c.Current % len(c.Members)
+	}
+	m.chores[idx] = c
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &c, nil
+}
+
+// Delete removes a chore
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("chore not found: %s", id)
+	}
+	m.chores = append(m.chores[:idx], m.chores[idx+1:]...)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.notify()
+	return nil
+}
+
+// MarkDone records a completion, hands the chore to the next member and schedules
+// the next due date. by defaults to the current assignee.
+func (m *Manager) MarkDone(id, by string) (*Chore, error) {
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("chore not found: %s", id)
+	}
+	c := &m.chores[idx]
+
+	if by == "" {
+		by = c.Assignee()
+	}
+	c.LastDoneAt = time.Now()
+	c.LastDoneBy = by
+	if len(c.Members) > 0 {
+		c.Current = (c.Current + 1) % len(c.Members)
+	}
+
+	// Advance the due date, skipping occurrences missed while overdue. Chores
+	// saved before anchoring are anchored on their current due date.
+	c.Recurrence.Anchor(c.Due)
+	today := m.today()
+	next := c.Recurrence.Next(c.Due)
+	for next.Before(today) {
+		next = c.Recurrence.Next(next)
+	}
+	c.Due = next
+
+	done := *c
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Chore %q done by %s, next: %s on %s", done.Title, by, done.Assignee(), done.Due.Format("2006-01-02"))
+	m.notify()
+	return &done, nil
+}
+
+func (m *Manager) validate(c *Chore) error {
+	if c.Title == "" {
+		return fmt.Errorf("title is required")
+	}
+	if c.Current < 0 {
+		return fmt.Errorf("invalid current member index: %d", c.Current)
+	}
+	if c.Recurrence.Frequency == "" {
+		c.Recurrence.Frequency = tasks.FrequencyWeekly
+	}
+	return c.Recurrence.Validate()
+}
+
+func (m *Manager) today() time.Time {
+	now := time.Now().In(m.timezone)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, m.timezone)
+}
+
+// indexOf finds a chore by ID (caller must hold the lock)
+func (m *Manager) indexOf(id string) int {
+	for i, c := range m.chores {
+		if c.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save persists chores (caller must hold the lock)
+func (m *Manager) save() error {
+	if err := m.doc.Save(m.chores); err != nil {
+		return fmt.Errorf("failed to save chores: %w", err)
+	}
+	return nil
+}
+
+func (m *Manager) notify() {
+	m.mu.RLock()
+	fn := m.onChange
+	m.mu.RUnlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// newID generates a short random chore ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
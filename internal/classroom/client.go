@@ -0,0 +1,213 @@
+package classroom
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/api/classroom/v1"
+	"google.golang.org/api/option"
+)
+
+// Scopes are the read-only OAuth scopes needed to list courses and coursework.
+// They are requested alongside the Calendar scopes (shared Google token).
+var Scopes = []string{
+	classroom.ClassroomCoursesReadonlyScope,
+	classroom.ClassroomCourseworkMeReadonlyScope,
+	classroom.ClassroomCourseworkStudentsReadonlyScope,
+}
+
+// Student is a kid whose assignments are tracked
+type Student struct {
+	Name string `json:"name"`
+	ID   string `json:"id"` // Classroom user ID, email, or "me" for the authorized account
+}
+
+// Assignment is a piece of coursework due soon that hasn't been turned in
+type Assignment struct {
+	ID       string    `json:"id"`
+	Student  string    `json:"student"`
+	CourseID string    `json:"courseId"`
+	Course   string    `json:"course"`
+	Title    string    `json:"title"`
+	Due      time.Time `json:"due"`
+	DueDate  string    `json:"dueDate"` // YYYY-MM-DD in local time, for the calendar views
+	AllDay   bool      `json:"allDay"`  // No due time was set
+	State    string    `json:"state,omitempty"`
+	Late     bool      `json:"late,omitempty"`
+	Link     string    `json:"link,omitempty"`
+}
+
+// Client lists upcoming Google Classroom assignments per student
+type Client struct {
+	service       *classroom.Service
+	students      []Student
+	timezone      *time.Location
+	mu            sync.RWMutex
+	assignments   []Assignment
+	lastFetch     time.Time
+	cacheDuration time.Duration
+}
+
+// NewClient creates a Classroom client from an authorized Google HTTP client
+func NewClient(httpClient *http.Client, students []Student, timezone *time.Location) (*Client, error) {
+	service, err := classroom.NewService(context.Background(), option.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create classroom service: %w", err)
+	}
+	if timezone == nil {
+		timezone = time.Local
+	}
+
+	return &Client{
+		service:       service,
+		students:      students,
+		timezone:      timezone,
+		cacheDuration: 15 * time.Minute,
+	}, nil
+}
+
+// Students returns the tracked students
+func (c *Client) Students() []Student {
+	return append([]Student{}, c.students...)
+}
+
+// Upcoming returns assignments due within the next days (plus overdue work still
+// open from the past week), ordered by due date. An empty student returns everyone.
+func (c *Client) Upcoming(ctx context.Context, student string, days int) ([]Assignment, error) {
+	all, err := c.getAssignments(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().In(c.timezone)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, c.timezone)
+	end := today.AddDate(0, 0, days+1)
+	result := []Assignment{}
+	for _, a := range all {
+		if student != "" && a.Student != student {
+			continue
+		}
+		if a.Due.Before(end) {
+			result = append(result, a)
+		}
+	}
+	return result, nil
+}
+
+// getAssignments returns cached assignments, refreshing when stale
+func (c *Client) getAssignments(ctx context.Context) ([]Assignment, error) {
+	c.mu.RLock()
+	if time.Since(c.lastFetch) < c.cacheDuration {
+		assignments := c.assignments
+		c.mu.RUnlock()
+		return assignments, nil
+	}
+	c.mu.RUnlock()
+
+	var all []Assignment
+	for _, s := range c.students {
+		assignments, err := c.fetchStudent(ctx, s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch assignments for %s: %w", s.Name, err)
+		}
+		all = append(all, assignments...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Due.Before(all[j].Due)
+	})
+
+	c.mu.Lock()
+	c.assignments = all
+	c.lastFetch = time.Now()
+	c.mu.Unlock()
+	return all, nil
+}
+
+// fetchStudent lists open coursework with a due date from a week ago onwards
+// across the student's active courses
+func (c *Client) fetchStudent(ctx context.Context, s Student) ([]Assignment, error) {
+	since := time.Now().AddDate(0, 0, -7)
+
+	var courses []*classroom.Course
+	err := c.service.Courses.List().StudentId(s.ID).CourseStates("ACTIVE").
+		Pages(ctx, func(resp *classroom.ListCoursesResponse) error {
+			courses = append(courses, resp.Courses...)
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list courses: %w", err)
+	}
+
+	var result []Assignment
+	for _, course := range courses {
+		var work []*classroom.CourseWork
+		err := c.service.Courses.CourseWork.List(course.Id).CourseWorkStates("PUBLISHED").
+			Pages(ctx, func(resp *classroom.ListCourseWorkResponse) error {
+				work = append(work, resp.CourseWork...)
+				return nil
+			})
+		if err != nil {
+			log.Printf("Classroom: Failed to list coursework for %s: %v", course.Name, err)
+			continue
+		}
+
+		// Submission state per coursework for this student ("-" = all coursework)
+		submissions := make(map[string]*classroom.StudentSubmission)
+		err = c.service.Courses.CourseWork.StudentSubmissions.List(course.Id, "-").UserId(s.ID).
+			Pages(ctx, func(resp *classroom.ListStudentSubmissionsResponse) error {
+				for _, sub := range resp.StudentSubmissions {
+					submissions[sub.CourseWorkId] = sub
+				}
+				return nil
+			})
+		if err != nil {
+			log.Printf("Classroom: Failed to list submissions for %s: %v", course.Name, err)
+		}
+
+		for _, cw := range work {
+			due, allDay, ok := c.dueTime(cw)
+			if !ok || due.Before(since) {
+				continue
+			}
+			a := Assignment{
+				ID:       cw.Id,
+				Student:  s.Name,
+				CourseID: course.Id,
+				Course:   course.Name,
+				Title:    cw.Title,
+				Due:      due,
+				DueDate:  due.In(c.timezone).Format("2006-01-02"),
+				AllDay:   allDay,
+				Link:     cw.AlternateLink,
+			}
+			if sub, ok := submissions[cw.Id]; ok {
+				if sub.State == "TURNED_IN" || sub.State == "RETURNED" {
+					continue
+				}
+				a.State = sub.State
+				a.Late = sub.Late
+			}
+			result = append(result, a)
+		}
+	}
+	return result, nil
+}
+
+// dueTime converts Classroom's UTC due date/time. Work without a due time is
+// due at the end of the day in local time.
+func (c *Client) dueTime(cw *classroom.CourseWork) (time.Time, bool, bool) {
+	d := cw.DueDate
+	if d == nil || d.Year == 0 {
+		return time.Time{}, false, false
+	}
+	if cw.DueTime == nil {
+		return time.Date(int(d.Year), time.Month(d.Month), int(d.Day), 23, 59, 0, 0, c.timezone), true, true
+	}
+	t := cw.DueTime
+	return time.Date(int(d.Year), time.Month(d.Month), int(d.Day), int(t.Hours), int(t.Minutes), 0, 0, time.UTC).In(c.timezone), false, true
+}
@@ -0,0 +1,129 @@
+package clientlog
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Platforms reporting errors
+const (
+	PlatformWeb     = "web"     // Browser / kiosk WebView JS
+	PlatformAndroid = "android" // Companion app
+)
+
+// Levels
+const (
+	LevelError   = "error"
+	LevelWarning = "warning"
+	LevelCrash   = "crash"
+)
+
+// MaxBatch is the largest number of reports accepted in one batch
+const MaxBatch = 100
+
+// maxFieldLen caps message and stack lengths so a runaway client can't bloat the store
+const maxFieldLen = 8192
+
+// Report is a single client-side error or crash
+type Report struct {
+	Time       time.Time `json:"time"`
+	Device     string    `json:"device"`
+	Platform   string    `json:"platform"` // web, android
+	Level      string    `json:"level"`    // error, warning, crash
+	Message    string    `json:"message"`
+	Source     string    `json:"source,omitempty"` // Script URL or Java class
+	Line       int       `json:"line,omitempty"`
+	Column     int       `json:"column,omitempty"`
+	Stack      string    `json:"stack,omitempty"`
+	Page       string    `json:"page,omitempty"`
+	AppVersion string    `json:"appVersion,omitempty"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+}
+
+// eventType is the store event type used for client reports
+const eventType = "clientlog"
+
+// Log keeps client reports in the store's event table
+type Log struct {
+	store *store.Store
+}
+
+// NewLog creates a client log, pruning reports older than retention
+func NewLog(st *store.Store, retention time.Duration) *Log {
+	if retention > 0 {
+		if n, err := st.PruneEvents(eventType, time.Now().Add(-retention)); err != nil {
+			log.Printf("Warning: Failed to prune client log: %v", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d old client log reports", n)
+		}
+	}
+	return &Log{store: st}
+}
+
+// Record stores a batch of reports from a device, filling in defaults
+func (l *Log) Record(device string, reports []Report) error {
+	if len(reports) > MaxBatch {
+		return fmt.Errorf("too many reports in batch (max %d)", MaxBatch)
+	}
+
+	now := time.Now()
+	for _, r := range reports {
+		if r.Message == "" {
+			return fmt.Errorf("report message is required")
+		}
+		r.Device = device
+		if r.Time.IsZero() || r.Time.After(now) {
+			r.Time = now
+		}
+		if r.Platform == "" {
+			r.Platform = PlatformWeb
+		}
+		if r.Level == "" {
+			r.Level = LevelError
+		}
+		r.Message = truncate(r.Message)
+		r.Stack = truncate(r.Stack)
+
+		if _, err := l.store.AppendEvent(r.Time, eventType, device, r); err != nil {
+			return fmt.Errorf("failed to record client report: %w", err)
+		}
+	}
+	return nil
+}
+
+// Recent returns up to limit of the newest reports, newest first.
+// If device is empty, reports from all devices are returned.
+func (l *Log) Recent(device string, limit int) []Report {
+	var events []store.Event
+	var err error
+	if device == "" {
+		events, err = l.store.RecentEvents(eventType, limit)
+	} else {
+		events, err = l.store.RecentEventsFrom(eventType, device, limit)
+	}
+	if err != nil {
+		log.Printf("Warning: Failed to read client log: %v", err)
+		return []Report{}
+	}
+
+	result := make([]Report, 0, len(events))
+	for _, e := range events {
+		var r Report
+		if err := json.Unmarshal(e.Data, &r); err != nil {
+			continue
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+func truncate(s string) string {
+	if len(s) <= maxFieldLen {
+		return s
+	}
+	return s[:maxFieldLen] + "…"
+}
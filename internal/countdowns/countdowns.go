@@ -0,0 +1,314 @@
+package countdowns
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Countdown sources
+const (
+	SourceManual   = "manual"
+	SourceCalendar = "calendar"
+)
+
+// DateLayout is the format of Countdown.Date
+const DateLayout = "2006-01-02"
+
+// Countdown is a big upcoming event shown as "12 days until vacation"
+type Countdown struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Date    string `json:"date"` // YYYY-MM-DD
+	Icon    string `json:"icon,omitempty"`
+	Source  string `json:"source"`            // manual or calendar
+	EventID string `json:"eventId,omitempty"` // Calendar event the countdown was created from
+}
+
+// Status is a countdown with the days remaining resolved, as returned by the API
+type Status struct {
+	Countdown
+	DaysLeft int    `json:"daysLeft"`
+	Label    string `json:"label"`
+}
+
+// Event is a calendar event considered for automatic countdowns
+type Event struct {
+	ID          string
+	Title       string
+	Description string
+	Start       time.Time
+}
+
+// Manager keeps the countdown list
+type Manager struct {
+	mu         sync.RWMutex
+	doc        *store.Doc
+	countdowns []Countdown
+	timezone   *time.Location
+	onChange   func()
+}
+
+// NewManager loads countdowns from the store
+func NewManager(doc *store.Doc, timezone *time.Location) *Manager {
+	if timezone == nil {
+		timezone = time.Local
+	}
+	m := &Manager{doc: doc, timezone: timezone}
+
+	if _, err := doc.Load(&m.countdowns); err != nil {
+		log.Printf("Warning: Failed to load countdowns: %v", err)
+	}
+	return m
+}
+
+// OnChange registers a callback invoked after countdowns are modified
+func (m *Manager) OnChange(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// List returns countdowns ordered by date. Events that have passed are only
+// included when includePast is set.
+func (m *Manager) List(includePast bool) []Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	today := m.today()
+	result := make([]Status, 0, len(m.countdowns))
+	for _, c := range m.countdowns {
+		date, err := time.ParseInLocation(DateLayout, c.Date, m.timezone)
+		if err != nil {
+			continue
+		}
+		days := int(math.Round(date.Sub(today).Hours() / 24)) // Round to absorb DST shifts
+		if days < 0 && !includePast {
+			continue
+		}
+		result = append(result, Status{Countdown: c, DaysLeft: days, Label: label(c.Name, days)})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Date < result[j].Date
+	})
+	return result
+}
+
+// Create adds a new manual countdown
+func (m *Manager) Create(c Countdown) (*Countdown, error) {
+	if err := validate(&c); err != nil {
+		return nil, err
+	}
+	c.ID = newID()
+	c.Source = SourceManual
+	c.EventID = ""
+
+	m.mu.Lock()
+	m.countdowns = append(m.countdowns, c)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &c, nil
+}
+
+// Update changes a countdown's name, date and icon. Calendar countdowns keep
+// following their event, so only the icon sticks across syncs.
+func (m *Manager) Update(id string, c Countdown) (*Countdown, error) {
+	if err := validate(&c); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("countdown not found: %s", id)
+	}
+	existing := m.countdowns[idx]
+	c.ID = existing.ID
+	c.Source = existing.Source
+	c.EventID = existing.EventID
+	m.countdowns[idx] = c
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &c, nil
+}
+
+// Delete removes a countdown. A deleted calendar countdown comes back on the
+// next sync while its event is still tagged.
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("countdown not found: %s", id)
+	}
+	m.countdowns = append(m.countdowns[:idx], m.countdowns[idx+1:]...)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.notify()
+	return nil
+}
+
+// SyncCalendar creates countdowns for events whose title or description contains
+// keyword (case-insensitive) and drops calendar countdowns whose event is gone or
+// no longer tagged. The keyword is stripped from the countdown name.
+func (m *Manager) SyncCalendar(events []Event, keyword string) error {
+	keyword = strings.TrimSpace(keyword)
+	if keyword == "" {
+		return nil
+	}
+	lower := strings.ToLower(keyword)
+
+	tagged := make(map[string]Countdown)
+	for _, e := range events {
+		if e.ID == "" || !strings.Contains(strings.ToLower(e.Title+"\n"+e.Description), lower) {
+			continue
+		}
+		if _, ok := tagged[e.ID]; ok {
+			continue
+		}
+		tagged[e.ID] = Countdown{
+			Name:    stripKeyword(e.Title, keyword),
+			Date:    e.Start.In(m.timezone).Format(DateLayout),
+			Source:  SourceCalendar,
+			EventID: e.ID,
+		}
+	}
+
+	m.mu.Lock()
+	changed := false
+	kept := make([]Countdown, 0, len(m.countdowns)+len(tagged))
+	for _, c := range m.countdowns {
+		if c.Source != SourceCalendar {
+			kept = append(kept, c)
+			continue
+		}
+		want, ok := tagged[c.EventID]
+		if !ok {
+			changed = true
+			continue
+		}
+		delete(tagged, c.EventID)
+		if c.Name != want.Name || c.Date != want.Date {
+			c.Name, c.Date = want.Name, want.Date
+			changed = true
+		}
+		kept = append(kept, c)
+	}
+	for _, c := range tagged {
+		c.ID = newID()
+		kept = append(kept, c)
+		changed = true
+	}
+	if !changed {
+		m.mu.Unlock()
+		return nil
+	}
+	m.countdowns = kept
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.notify()
+	return nil
+}
+
+func validate(c *Countdown) error {
+	c.Name = strings.TrimSpace(c.Name)
+	if c.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if _, err := time.Parse(DateLayout, c.Date); err != nil {
+		return fmt.Errorf("date must be YYYY-MM-DD: %s", c.Date)
+	}
+	return nil
+}
+
+// label phrases the days remaining for the home screen
+func label(name string, days int) string {
+	switch {
+	case days < 0:
+		return name + " has passed"
+	case days == 0:
+		return name + " is today"
+	case days == 1:
+		return "1 day until " + name
+	default:
+		return fmt.Sprintf("%d days until %s", days, name)
+	}
+}
+
+// stripKeyword removes the tag from an event title, e.g. "Vacation #countdown" -> "Vacation"
+func stripKeyword(title, keyword string) string {
+	idx := strings.Index(strings.ToLower(title), strings.ToLower(keyword))
+	if idx >= 0 {
+		title = title[:idx] + title[idx+len(keyword):]
+	}
+	if name := strings.Join(strings.Fields(title), " "); name != "" {
+		return name
+	}
+	return "Event"
+}
+
+func (m *Manager) today() time.Time {
+	now := time.Now().In(m.timezone)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, m.timezone)
+}
+
+// indexOf finds a countdown by ID (caller must hold the lock)
+func (m *Manager) indexOf(id string) int {
+	for i, c := range m.countdowns {
+		if c.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save persists countdowns (caller must hold the lock)
+func (m *Manager) save() error {
+	if err := m.doc.Save(m.countdowns); err != nil {
+		return fmt.Errorf("failed to save countdowns: %w", err)
+	}
+	return nil
+}
+
+func (m *Manager) notify() {
+	m.mu.RLock()
+	fn := m.onChange
+	m.mu.RUnlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// newID generates a short random countdown ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,312 @@
+package daycontext
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Day kinds, in order of precedence
+const (
+	KindHoliday   = "holiday"
+	KindWeekend   = "weekend"
+	KindSchoolDay = "school_day"
+	KindWorkday   = "workday"
+	KindDayOff    = "day_off"
+)
+
+// Extra tags that can apply alongside the kind
+const (
+	TagWFH = "wfh"
+)
+
+// Tags are every kind and tag a day can have, for conditions in schedules
+var Tags = []string{KindHoliday, KindWeekend, KindSchoolDay, KindWorkday, KindDayOff, TagWFH}
+
+// Valid reports whether tag is a known kind or tag
+func Valid(tag string) bool {
+	return slices.Contains(Tags, tag)
+}
+
+// Matches reports whether a day with tags satisfies a condition on kinds: any
+// one of them applies, or kinds is empty
+func Matches(kinds, tags []string) bool {
+	if len(kinds) == 0 {
+		return true
+	}
+	for _, k := range kinds {
+		if slices.Contains(tags, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the part of a calendar event used for classification
+type Event struct {
+	Title      string
+	CalendarID string
+	AllDay     bool
+}
+
+// EventSource returns the calendar events between start and end
+type EventSource func(ctx context.Context, start, end time.Time) ([]Event, error)
+
+// Term is a school term; school days only fall inside terms when any are configured
+type Term struct {
+	Start string `json:"start"` // YYYY-MM-DD
+	End   string `json:"end"`   // YYYY-MM-DD, inclusive
+}
+
+// Rules configures how days are classified. Weekdays are 0 (Sunday) to 6 (Saturday).
+type Rules struct {
+	SchoolDays       []int    `json:"schoolDays"`
+	WorkDays         []int    `json:"workDays"`
+	WFHDays          []int    `json:"wfhDays"`
+	USHolidays       bool     `json:"usHolidays"`       // Treat US federal holidays as holidays
+	Holidays         []string `json:"holidays"`         // Extra holiday dates, YYYY-MM-DD
+	SchoolTerms      []Term   `json:"schoolTerms"`      // Empty = school all year on school days
+	SchoolCalendar   string   `json:"schoolCalendar"`   // Only this calendar's events cancel school (empty = any)
+	NoSchoolKeywords []string `json:"noSchoolKeywords"` // All-day event titles that mean no school
+	DayOffKeywords   []string `json:"dayOffKeywords"`   // All-day event titles that mean no work
+	WFHKeywords      []string `json:"wfhKeywords"`      // Event titles that mean working from home
+}
+
+// DefaultRules returns a Monday-Friday school and work week with US holidays
+func DefaultRules() Rules {
+	weekdays := []int{1, 2, 3, 4, 5}
+	return Rules{
+		SchoolDays:       weekdays,
+		WorkDays:         weekdays,
+		WFHDays:          []int{},
+		USHolidays:       true,
+		Holidays:         []string{},
+		SchoolTerms:      []Term{},
+		NoSchoolKeywords: []string{"no school", "school closed", "teacher workday", "snow day", "break"},
+		DayOffKeywords:   []string{"vacation", "pto", "day off", "out of office"},
+		WFHKeywords:      []string{"wfh", "work from home", "remote"},
+	}
+}
+
+// Day is the classification of a single date
+type Day struct {
+	Date      string   `json:"date"` // YYYY-MM-DD
+	Weekday   string   `json:"weekday"`
+	Kind      string   `json:"kind"`
+	Tags      []string `json:"tags"` // Kind plus every other label that applies
+	Holiday   string   `json:"holiday,omitempty"`
+	Weekend   bool     `json:"weekend"`
+	SchoolDay bool     `json:"schoolDay"`
+	Workday   bool     `json:"workday"`
+	WFH       bool     `json:"wfh"`
+	Reasons   []string `json:"reasons"` // Why, e.g. "calendar: Spring Break"
+}
+
+// Is reports whether the day has a kind or tag, e.g. Is("school_day")
+func (d Day) Is(tag string) bool {
+	for _, t := range d.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Service classifies days from the rules and calendar events
+type Service struct {
+	mu       sync.RWMutex
+	doc      *store.Doc
+	rules    Rules
+	timezone *time.Location
+	events   EventSource
+}
+
+// NewService loads the rules from the store. events may be nil if no calendar is configured.
+func NewService(doc *store.Doc, timezone *time.Location, events EventSource) *Service {
+	if timezone == nil {
+		timezone = time.Local
+	}
+	s := &Service{doc: doc, rules: DefaultRules(), timezone: timezone, events: events}
+
+	if _, err := doc.Load(&s.rules); err != nil {
+		log.Printf("Warning: Failed to load day context rules: %v", err)
+	}
+	return s
+}
+
+// Rules returns the current rules
+func (s *Service) Rules() Rules {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rules
+}
+
+// SetRules replaces the rules
+func (s *Service) SetRules(r Rules) error {
+	for _, wd := range append(append(append([]int{}, r.SchoolDays...), r.WorkDays...), r.WFHDays...) {
+		if wd < 0 || wd > 6 {
+			return fmt.Errorf("invalid weekday: %d", wd)
+		}
+	}
+	for _, d := range r.Holidays {
+		if _, err := time.Parse("2006-01-02", d); err != nil {
+			return fmt.Errorf("invalid holiday date: %s", d)
+		}
+	}
+	for _, t := range r.SchoolTerms {
+		start, err1 := time.Parse("2006-01-02", t.Start)
+		end, err2 := time.Parse("2006-01-02", t.End)
+		if err1 != nil || err2 != nil || end.Before(start) {
+			return fmt.Errorf("invalid school term: %s to %s", t.Start, t.End)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = r
+	if err := s.doc.Save(s.rules); err != nil {
+		return fmt.Errorf("failed to save day context rules: %w", err)
+	}
+	return nil
+}
+
+// Today classifies the current day
+func (s *Service) Today(ctx context.Context) Day {
+	return s.Classify(ctx, time.Now().In(s.timezone))
+}
+
+// Classify classifies a date using the rules and that day's calendar events.
+// Calendar errors are logged and the day is classified from the rules alone.
+func (s *Service) Classify(ctx context.Context, date time.Time) Day {
+	date = date.In(s.timezone)
+	start := time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, s.timezone)
+
+	var events []Event
+	if s.events != nil {
+		var err error
+		events, err = s.events(ctx, start, start.AddDate(0, 0, 1))
+		if err != nil {
+			log.Printf("Warning: Failed to get calendar events for day context: %v", err)
+		}
+	}
+	return s.classify(start, events)
+}
+
+func (s *Service) classify(date time.Time, events []Event) Day {
+	rules := s.Rules()
+	wd := int(date.Weekday())
+	dateStr := date.Format("2006-01-02")
+
+	day := Day{
+		Date:    dateStr,
+		Weekday: date.Weekday().String(),
+		Weekend: date.Weekday() == time.Saturday || date.Weekday() == time.Sunday,
+		Reasons: []string{},
+	}
+
+	// Holidays: built-in, configured dates
+	if rules.USHolidays {
+		if name := usHoliday(date); name != "" {
+			day.Holiday = name
+			day.Reasons = append(day.Reasons, "US holiday: "+name)
+		}
+	}
+	for _, h := range rules.Holidays {
+		if h == dateStr && day.Holiday == "" {
+			day.Holiday = "Holiday"
+			day.Reasons = append(day.Reasons, "configured holiday")
+		}
+	}
+
+	// Calendar events that cancel school or work, or mark WFH
+	noSchool, dayOff := false, false
+	wfhEvent := ""
+	for _, e := range events {
+		if e.AllDay && matchesAny(e.Title, rules.NoSchoolKeywords) &&
+			(rules.SchoolCalendar == "" || e.CalendarID == rules.SchoolCalendar) {
+			noSchool = true
+			day.Reasons = append(day.Reasons, "no school: "+e.Title)
+		}
+		if e.AllDay && matchesAny(e.Title, rules.DayOffKeywords) {
+			dayOff = true
+			day.Reasons = append(day.Reasons, "day off: "+e.Title)
+		}
+		if matchesAny(e.Title, rules.WFHKeywords) {
+			wfhEvent = e.Title
+		}
+	}
+
+	holiday := day.Holiday != ""
+	day.Workday = containsDay(rules.WorkDays, wd) && !holiday && !dayOff
+	day.WFH = day.Workday && (wfhEvent != "" || containsDay(rules.WFHDays, wd))
+	if day.WFH && wfhEvent != "" {
+		day.Reasons = append(day.Reasons, "working from home: "+wfhEvent)
+	}
+	day.SchoolDay = containsDay(rules.SchoolDays, wd) && !holiday && !noSchool && inTerm(rules.SchoolTerms, dateStr)
+	if containsDay(rules.SchoolDays, wd) && !inTerm(rules.SchoolTerms, dateStr) {
+		day.Reasons = append(day.Reasons, "outside school term")
+	}
+
+	switch {
+	case holiday:
+		day.Kind = KindHoliday
+	case day.Weekend:
+		day.Kind = KindWeekend
+	case day.SchoolDay:
+		day.Kind = KindSchoolDay
+	case day.Workday:
+		day.Kind = KindWorkday
+	default:
+		day.Kind = KindDayOff
+	}
+
+	day.Tags = []string{day.Kind}
+	addTag := func(ok bool, tag string) {
+		if ok && tag != day.Kind {
+			day.Tags = append(day.Tags, tag)
+		}
+	}
+	addTag(day.Weekend, KindWeekend)
+	addTag(day.SchoolDay, KindSchoolDay)
+	addTag(day.Workday, KindWorkday)
+	addTag(day.WFH, TagWFH)
+	return day
+}
+
+func containsDay(days []int, wd int) bool {
+	for _, d := range days {
+		if d == wd {
+			return true
+		}
+	}
+	return false
+}
+
+// inTerm reports whether date falls inside a school term (always true with no terms)
+func inTerm(terms []Term, date string) bool {
+	if len(terms) == 0 {
+		return true
+	}
+	for _, t := range terms {
+		if date >= t.Start && date <= t.End {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAny(title string, keywords []string) bool {
+	lower := strings.ToLower(title)
+	for _, k := range keywords {
+		if k != "" && strings.Contains(lower, strings.ToLower(k)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package daycontext
+
+import "time"
+
+// usHoliday returns the name of the US federal holiday on date, if any.
+// Mirrors the holidays shown on the calendar (static/js/holidays.js).
+func usHoliday(date time.Time) string {
+	y, m, d := date.Date()
+	wd := date.Weekday()
+
+	switch {
+	case m == time.January && d == 1:
+		return "New Year's Day"
+	case m == time.January && wd == time.Monday && nth(d) == 3:
+		return "Martin Luther King Jr. Day"
+	case m == time.February && wd == time.Monday && nth(d) == 3:
+		return "Presidents' Day"
+	case m == time.May && wd == time.Monday && d+7 > daysIn(y, m):
+		return "Memorial Day"
+	case m == time.June && d == 19:
+		return "Juneteenth"
+	case m == time.July && d == 4:
+		return "Independence Day"
+	case m == time.September && wd == time.Monday && nth(d) == 1:
+		return "Labor Day"
+	case m == time.October && wd == time.Monday && nth(d) == 2:
+		return "Columbus Day"
+	case m == time.November && d == 11:
+		return "Veterans Day"
+	case m == time.November && wd == time.Thursday && nth(d) == 4:
+		return "Thanksgiving"
+	case m == time.December && d == 25:
+		return "Christmas Day"
+	}
+	return ""
+}
+
+// nth returns which occurrence of its weekday a day of the month is (1-5)
+func nth(day int) int {
+	return (day-1)/7 + 1
+}
+
+func daysIn(year int, month time.Month) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
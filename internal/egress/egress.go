@@ -0,0 +1,230 @@
+package egress
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Step kinds, in the order they run
+const (
+	StepDisplay = "display" // Egress instructions on every tablet
+	StepLights  = "lights"  // Every light on at full brightness
+	StepUnlock  = "unlock"  // Unlock a door
+	StepMedia   = "media"   // Stop media
+)
+
+// Audit actions
+const (
+	ActionStart = "start"
+	ActionClear = "clear"
+)
+
+// DefaultInstructions are shown when none are configured
+const DefaultInstructions = "Leave the house now by the nearest exit. Don't stop for belongings. Meet at the assembly point and call emergency services."
+
+// eventType is the store event type used for the audit log
+const eventType = "egress"
+
+// Config is what emergency mode does
+type Config struct {
+	Doors        []string // lock.* entities to unlock
+	Instructions string   // Shown on every tablet
+}
+
+// Actions carry out the steps. Display shows the status on the tablets, or
+// clears it when the status isn't active.
+type Actions struct {
+	Lights    func() error
+	Unlock    func(lock string) error
+	StopMedia func() error
+	Display   func(s Status)
+}
+
+// Request starts emergency mode
+type Request struct {
+	Drill  bool   `json:"drill"`  // A fire drill: everything runs, and the tablets say it's a drill
+	DryRun bool   `json:"dryRun"` // Test: list the steps without running any
+	Source string `json:"-"`
+
+	// SkipDoors are doors the requester isn't allowed to unlock; their
+	// unlock steps are reported as skipped instead of run
+	SkipDoors []string `json:"-"`
+}
+
+// StepResult is one step's outcome
+type StepResult struct {
+	Kind    string `json:"kind"`
+	Target  string `json:"target,omitempty"`
+	Error   string `json:"error,omitempty"`
+	Skipped bool   `json:"skipped,omitempty"`
+}
+
+// Status is whether emergency mode is on and what it did
+type Status struct {
+	Active       bool         `json:"active"`
+	Drill        bool         `json:"drill,omitempty"`
+	Since        *time.Time   `json:"since,omitempty"`
+	Source       string       `json:"source,omitempty"`
+	Instructions string       `json:"instructions"`
+	Doors        []string     `json:"doors"`
+	Steps        []StepResult `json:"steps,omitempty"`
+}
+
+// AuditEntry is a start, dry run or clear in the audit log
+type AuditEntry struct {
+	ID     int64        `json:"id"`
+	Time   time.Time    `json:"time"`
+	Action string       `json:"action"` // start or clear
+	Source string       `json:"source"`
+	Drill  bool         `json:"drill,omitempty"`
+	DryRun bool         `json:"dryRun,omitempty"`
+	Steps  []StepResult `json:"steps,omitempty"`
+}
+
+// Manager runs emergency egress mode
+type Manager struct {
+	store   *store.Store
+	config  Config
+	actions Actions
+
+	mu     sync.Mutex
+	status Status
+}
+
+// NewManager creates the emergency mode manager. Starts and clears are
+// recorded in the store's event table.
+func NewManager(st *store.Store, cfg Config, actions Actions) *Manager {
+	if cfg.Instructions == "" {
+		cfg.Instructions = DefaultInstructions
+	}
+	if cfg.Doors == nil {
+		cfg.Doors = []string{}
+	}
+	return &Manager{
+		store:   st,
+		config:  cfg,
+		actions: actions,
+		status:  Status{Instructions: cfg.Instructions, Doors: cfg.Doors},
+	}
+}
+
+// Status returns the current state
+func (m *Manager) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.status
+	s.Steps = slices.Clone(m.status.Steps)
+	return s
+}
+
+// Plan returns the steps emergency mode runs
+func (m *Manager) Plan() []StepResult {
+	steps := []StepResult{{Kind: StepDisplay}, {Kind: StepLights}}
+	for _, door := range m.config.Doors {
+		steps = append(steps, StepResult{Kind: StepUnlock, Target: door})
+	}
+	return append(steps, StepResult{Kind: StepMedia})
+}
+
+// Start turns emergency mode on. Every step runs even if an earlier one fails;
+// a failed step is reported in the result. Starting again while active re-runs
+// the steps. A dry run only reports the plan.
+func (m *Manager) Start(req Request) Status {
+	now := time.Now()
+	if req.DryRun {
+		plan := m.Plan()
+		m.audit(AuditEntry{Time: now, Action: ActionStart, Source: req.Source, Drill: req.Drill, DryRun: true, Steps: plan})
+		return Status{Drill: req.Drill, Instructions: m.config.Instructions, Doors: m.config.Doors, Steps: plan}
+	}
+
+	kind := "EMERGENCY"
+	if req.Drill {
+		kind = "Drill"
+	}
+	log.Printf("%s: egress mode started by %s", kind, req.Source)
+
+	// Tablets first, so the instructions are up while the rest runs
+	m.mu.Lock()
+	m.status = Status{Active: true, Drill: req.Drill, Since: &now, Source: req.Source, Instructions: m.config.Instructions, Doors: m.config.Doors}
+	m.mu.Unlock()
+
+	var steps []StepResult
+	for _, step := range m.Plan() {
+		if step.Kind == StepUnlock && slices.Contains(req.SkipDoors, step.Target) {
+			log.Printf("Egress: not unlocking %s, %s isn't authorized for it", step.Target, req.Source)
+			step.Skipped = true
+			steps = append(steps, step)
+			continue
+		}
+		var err error
+		switch step.Kind {
+		case StepDisplay:
+			m.actions.Display(m.Status())
+		case StepLights:
+			err = m.actions.Lights()
+		case StepUnlock:
+			err = m.actions.Unlock(step.Target)
+		case StepMedia:
+			err = m.actions.StopMedia()
+		}
+		if err != nil {
+			log.Printf("Error: Egress step %s %s failed: %v", step.Kind, step.Target, err)
+			step.Error = err.Error()
+		}
+		steps = append(steps, step)
+	}
+
+	m.mu.Lock()
+	m.status.Steps = steps
+	m.mu.Unlock()
+
+	m.audit(AuditEntry{Time: now, Action: ActionStart, Source: req.Source, Drill: req.Drill, Steps: steps})
+	return m.Status()
+}
+
+// Clear ends emergency mode and takes the instructions off the tablets. Doors
+// are left unlocked; lock them once everyone is back.
+func (m *Manager) Clear(source string) Status {
+	m.mu.Lock()
+	wasActive := m.status.Active
+	m.status = Status{Instructions: m.config.Instructions, Doors: m.config.Doors}
+	m.mu.Unlock()
+	s := m.Status()
+
+	if wasActive {
+		log.Printf("Egress mode cleared by %s", source)
+		m.actions.Display(s)
+		m.audit(AuditEntry{Time: time.Now(), Action: ActionClear, Source: source})
+	}
+	return s
+}
+
+// Audit returns recent starts and clears, newest first
+func (m *Manager) Audit(limit int) ([]AuditEntry, error) {
+	events, err := m.store.RecentEvents(eventType, limit)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]AuditEntry, 0, len(events))
+	for _, ev := range events {
+		var e AuditEntry
+		if err := json.Unmarshal(ev.Data, &e); err != nil {
+			return nil, fmt.Errorf("failed to decode egress audit entry %d: %w", ev.ID, err)
+		}
+		e.ID = ev.ID
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (m *Manager) audit(e AuditEntry) {
+	if _, err := m.store.AppendEvent(e.Time, eventType, e.Source, e); err != nil {
+		log.Printf("Warning: Failed to record egress audit entry: %v", err)
+	}
+}
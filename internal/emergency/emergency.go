@@ -0,0 +1,513 @@
+package emergency
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/lightning"
+	"home_control/internal/notify"
+)
+
+// nwsAlertsURL lists active NWS alerts for a point (US only)
+const nwsAlertsURL = "https://api.weather.gov/alerts/active"
+
+// userAgent identifies the app to api.weather.gov, which rejects requests without one
+const userAgent = "home_control (kiosk emergency alerts)"
+
+// Alert is an active emergency alert near home
+type Alert struct {
+	ID          string    `json:"id"`
+	Source      string    `json:"source"` // "nws" or the feed's host
+	Event       string    `json:"event"`  // e.g. "Civil Emergency Message", "Boil Water Notice"
+	Category    string    `json:"category,omitempty"`
+	Severity    string    `json:"severity"` // notify severity: info, warning, critical
+	Headline    string    `json:"headline"`
+	Description string    `json:"description,omitempty"`
+	Instruction string    `json:"instruction,omitempty"`
+	Area        string    `json:"area,omitempty"`
+	DistanceKm  *float64  `json:"distanceKm,omitempty"` // To the alert area, when it has coordinates
+	Sent        time.Time `json:"sent"`
+	Expires     time.Time `json:"expires,omitempty"`
+	URL         string    `json:"url,omitempty"`
+}
+
+// Config holds the monitor settings
+type Config struct {
+	Lat      float64
+	Lon      float64
+	RadiusKm float64       // Alerts with an area farther than this are ignored
+	NWS      bool          // Include non-weather NWS alerts (civil emergencies, hazmat, abductions...)
+	Feeds    []string      // CAP or Atom-of-CAP feed URLs, e.g. county/Nixle feeds
+	Interval time.Duration // Poll interval (default 5m)
+}
+
+// Monitor polls emergency feeds and reports new alerts
+type Monitor struct {
+	cfg        Config
+	httpClient *http.Client
+
+	mu      sync.RWMutex
+	active  []Alert
+	seen    map[string]time.Time // alert ID -> expiry, so alerts are reported once
+	onAlert func(Alert)
+}
+
+// NewMonitor creates an emergency feed monitor
+func NewMonitor(cfg Config) *Monitor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 5 * time.Minute
+	}
+	if cfg.RadiusKm <= 0 {
+		cfg.RadiusKm = 25
+	}
+	return &Monitor{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 20 * time.Second},
+		seen:       make(map[string]time.Time),
+	}
+}
+
+// OnAlert registers a callback invoked once for each new alert
+func (m *Monitor) OnAlert(fn func(Alert)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onAlert = fn
+}
+
+// Active returns current alerts, most severe first
+func (m *Monitor) Active() []Alert {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Alert{}, m.active...)
+}
+
+// Run polls the feeds until the process exits. It blocks, so call it in a goroutine.
+func (m *Monitor) Run() {
+	m.Poll()
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.Poll()
+	}
+}
+
+// Poll fetches every feed once and reports alerts not seen before
+func (m *Monitor) Poll() {
+	var alerts []Alert
+	if m.cfg.NWS {
+		nws, err := m.fetchNWS()
+		if err != nil {
+			log.Printf("Emergency: Failed to fetch NWS alerts: %v", err)
+		}
+		alerts = append(alerts, nws...)
+	}
+	for _, feed := range m.cfg.Feeds {
+		feedAlerts, err := m.fetchCAP(feed)
+		if err != nil {
+			log.Printf("Emergency: Failed to fetch %s: %v", feed, err)
+			continue
+		}
+		alerts = append(alerts, feedAlerts...)
+	}
+
+	now := time.Now()
+	var nearby []Alert
+	for _, a := range alerts {
+		if !a.Expires.IsZero() && a.Expires.Before(now) {
+			continue
+		}
+		if a.DistanceKm != nil && *a.DistanceKm > m.cfg.RadiusKm {
+			continue
+		}
+		nearby = append(nearby, a)
+	}
+	sort.SliceStable(nearby, func(i, j int) bool {
+		ri, rj := severityRank(nearby[i].Severity), severityRank(nearby[j].Severity)
+		if ri != rj {
+			return ri > rj
+		}
+		return nearby[i].Sent.After(nearby[j].Sent)
+	})
+
+	m.mu.Lock()
+	var fresh []Alert
+	for _, a := range nearby {
+		if _, ok := m.seen[a.ID]; !ok {
+			fresh = append(fresh, a)
+		}
+		expiry := a.Expires
+		if expiry.IsZero() {
+			expiry = now.Add(24 * time.Hour)
+		}
+		m.seen[a.ID] = expiry
+	}
+	for id, expiry := range m.seen {
+		if expiry.Before(now.Add(-time.Hour)) {
+			delete(m.seen, id)
+		}
+	}
+	m.active = nearby
+	fn := m.onAlert
+	m.mu.Unlock()
+
+	if fn == nil {
+		return
+	}
+	for _, a := range fresh {
+		fn(a)
+	}
+}
+
+// fetchNWS returns active non-meteorological NWS alerts for home
+func (m *Monitor) fetchNWS() ([]Alert, error) {
+	u := fmt.Sprintf("%s?point=%.4f,%.4f", nwsAlertsURL, m.cfg.Lat, m.cfg.Lon)
+	body, err := m.get(u, "application/geo+json")
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		Features []struct {
+			Properties struct {
+				ID          string    `json:"id"`
+				AreaDesc    string    `json:"areaDesc"`
+				Sent        time.Time `json:"sent"`
+				Expires     time.Time `json:"expires"`
+				Ends        time.Time `json:"ends"`
+				Category    string    `json:"category"`
+				Severity    string    `json:"severity"`
+				Event       string    `json:"event"`
+				Headline    string    `json:"headline"`
+				Description string    `json:"description"`
+				Instruction string    `json:"instruction"`
+				Web         string    `json:"@id"`
+			} `json:"properties"`
+		} `json:"features"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse NWS alerts: %w", err)
+	}
+
+	var alerts []Alert
+	for _, f := range resp.Features {
+		p := f.Properties
+		if p.Category == "Met" {
+			continue // Weather is covered by the weather card
+		}
+		expires := p.Ends
+		if expires.IsZero() {
+			expires = p.Expires
+		}
+		alerts = append(alerts, Alert{
+			ID:          p.ID,
+			Source:      "nws",
+			Event:       p.Event,
+			Category:    p.Category,
+			Severity:    MapSeverity(p.Severity),
+			Headline:    firstNonEmpty(p.Headline, p.Event),
+			Description: p.Description,
+			Instruction: p.Instruction,
+			Area:        p.AreaDesc,
+			Sent:        p.Sent,
+			Expires:     expires,
+			URL:         p.Web,
+		})
+	}
+	return alerts, nil
+}
+
+// capAlert is a CAP 1.2 alert
+type capAlert struct {
+	Identifier string    `xml:"identifier"`
+	Sender     string    `xml:"sender"`
+	Sent       string    `xml:"sent"`
+	Status     string    `xml:"status"`
+	MsgType    string    `xml:"msgType"`
+	Info       []capInfo `xml:"info"`
+}
+
+type capInfo struct {
+	Language    string    `xml:"language"`
+	Category    []string  `xml:"category"`
+	Event       string    `xml:"event"`
+	Severity    string    `xml:"severity"`
+	Expires     string    `xml:"expires"`
+	Headline    string    `xml:"headline"`
+	Description string    `xml:"description"`
+	Instruction string    `xml:"instruction"`
+	Web         string    `xml:"web"`
+	Area        []capArea `xml:"area"`
+}
+
+type capArea struct {
+	AreaDesc string   `xml:"areaDesc"`
+	Polygon  []string `xml:"polygon"` // "lat,lon lat,lon ..."
+	Circle   []string `xml:"circle"`  // "lat,lon radiusKm"
+}
+
+// atomFeed is an Atom feed whose entries embed CAP alerts (or just describe them)
+type atomFeed struct {
+	Entries []struct {
+		ID      string `xml:"id"`
+		Title   string `xml:"title"`
+		Summary string `xml:"summary"`
+		Updated string `xml:"updated"`
+		Point   string `xml:"point"` // georss:point "lat lon"
+		Link    struct {
+			Href string `xml:"href,attr"`
+		} `xml:"link"`
+		Alert   *capAlert `xml:"alert"`
+		Content struct {
+			Alert *capAlert `xml:"alert"`
+		} `xml:"content"`
+	} `xml:"entry"`
+}
+
+// fetchCAP reads a single CAP alert or an Atom feed of CAP alerts
+func (m *Monitor) fetchCAP(feedURL string) ([]Alert, error) {
+	body, err := m.get(feedURL, "application/atom+xml, application/xml, text/xml")
+	if err != nil {
+		return nil, err
+	}
+	source := feedURL
+	if u, err := url.Parse(feedURL); err == nil && u.Host != "" {
+		source = u.Host
+	}
+
+	var root struct {
+		XMLName xml.Name
+	}
+	if err := xml.Unmarshal(body, &root); err != nil {
+		return nil, fmt.Errorf("failed to parse feed: %w", err)
+	}
+
+	if root.XMLName.Local == "alert" {
+		var a capAlert
+		if err := xml.Unmarshal(body, &a); err != nil {
+			return nil, fmt.Errorf("failed to parse CAP alert: %w", err)
+		}
+		if alert, ok := m.fromCAP(a, source, ""); ok {
+			return []Alert{alert}, nil
+		}
+		return nil, nil
+	}
+
+	var feed atomFeed
+	if err := xml.Unmarshal(body, &feed); err != nil {
+		return nil, fmt.Errorf("failed to parse Atom feed: %w", err)
+	}
+	var alerts []Alert
+	for _, e := range feed.Entries {
+		embedded := e.Alert
+		if embedded == nil {
+			embedded = e.Content.Alert
+		}
+		if embedded != nil {
+			if alert, ok := m.fromCAP(*embedded, source, e.Link.Href); ok {
+				alerts = append(alerts, alert)
+			}
+			continue
+		}
+
+		// Plain entry (e.g. Nixle): title and summary, optionally with a georss point
+		alert := Alert{
+			ID:          firstNonEmpty(e.ID, e.Link.Href, e.Title),
+			Source:      source,
+			Event:       e.Title,
+			Severity:    notify.SeverityWarning,
+			Headline:    e.Title,
+			Description: e.Summary,
+			Sent:        parseTime(e.Updated),
+			URL:         e.Link.Href,
+		}
+		if lat, lon, ok := parsePoint(e.Point, " "); ok {
+			d := lightning.Distance(m.cfg.Lat, m.cfg.Lon, lat, lon)
+			alert.DistanceKm = &d
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, nil
+}
+
+// fromCAP converts the first (English, if present) info block of an actual CAP alert
+func (m *Monitor) fromCAP(a capAlert, source, link string) (Alert, bool) {
+	if len(a.Info) == 0 || (a.Status != "" && a.Status != "Actual") || a.MsgType == "Cancel" {
+		return Alert{}, false
+	}
+	info := a.Info[0]
+	for _, i := range a.Info {
+		if strings.HasPrefix(strings.ToLower(i.Language), "en") {
+			info = i
+			break
+		}
+	}
+	for _, c := range info.Category {
+		if c == "Met" {
+			return Alert{}, false
+		}
+	}
+
+	alert := Alert{
+		ID:          firstNonEmpty(a.Identifier, a.Sender+a.Sent),
+		Source:      source,
+		Event:       info.Event,
+		Category:    strings.Join(info.Category, ", "),
+		Severity:    MapSeverity(info.Severity),
+		Headline:    firstNonEmpty(info.Headline, info.Event),
+		Description: info.Description,
+		Instruction: info.Instruction,
+		Sent:        parseTime(a.Sent),
+		Expires:     parseTime(info.Expires),
+		URL:         firstNonEmpty(info.Web, link),
+	}
+	var areas []string
+	for _, area := range info.Area {
+		areas = append(areas, area.AreaDesc)
+		if d, ok := m.areaDistance(area); ok && (alert.DistanceKm == nil || d < *alert.DistanceKm) {
+			alert.DistanceKm = &d
+		}
+	}
+	alert.Area = strings.Join(areas, "; ")
+	return alert, true
+}
+
+// areaDistance is 0 when home is inside a CAP area, otherwise the distance to
+// its edge (circles) or nearest vertex (polygons)
+func (m *Monitor) areaDistance(area capArea) (float64, bool) {
+	best, found := 0.0, false
+	consider := func(d float64) {
+		if d < 0 {
+			d = 0
+		}
+		if !found || d < best {
+			best, found = d, true
+		}
+	}
+
+	for _, c := range area.Circle {
+		parts := strings.Fields(c)
+		if len(parts) != 2 {
+			continue
+		}
+		lat, lon, ok := parsePoint(parts[0], ",")
+		radius, err := strconv.ParseFloat(parts[1], 64)
+		if !ok || err != nil {
+			continue
+		}
+		consider(lightning.Distance(m.cfg.Lat, m.cfg.Lon, lat, lon) - radius)
+	}
+
+	for _, p := range area.Polygon {
+		var lats, lons []float64
+		for _, pair := range strings.Fields(p) {
+			if lat, lon, ok := parsePoint(pair, ","); ok {
+				lats = append(lats, lat)
+				lons = append(lons, lon)
+			}
+		}
+		if len(lats) < 3 {
+			continue
+		}
+		if pointInPolygon(m.cfg.Lat, m.cfg.Lon, lats, lons) {
+			consider(0)
+			continue
+		}
+		for i := range lats {
+			consider(lightning.Distance(m.cfg.Lat, m.cfg.Lon, lats[i], lons[i]))
+		}
+	}
+	return best, found
+}
+
+func (m *Monitor) get(u, accept string) ([]byte, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", accept)
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 5<<20))
+}
+
+// MapSeverity maps CAP severity to a notification severity
+func MapSeverity(capSeverity string) string {
+	switch capSeverity {
+	case "Extreme", "Severe":
+		return notify.SeverityCritical
+	case "Moderate":
+		return notify.SeverityWarning
+	default:
+		return notify.SeverityInfo
+	}
+}
+
+func severityRank(s string) int {
+	switch s {
+	case notify.SeverityCritical:
+		return 2
+	case notify.SeverityWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// pointInPolygon uses ray casting (fine at the scale of an alert area)
+func pointInPolygon(lat, lon float64, lats, lons []float64) bool {
+	inside := false
+	for i, j := 0, len(lats)-1; i < len(lats); j, i = i, i+1 {
+		if (lats[i] > lat) != (lats[j] > lat) &&
+			lon < (lons[j]-lons[i])*(lat-lats[i])/(lats[j]-lats[i])+lons[i] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// parsePoint parses "lat<sep>lon"
+func parsePoint(s, sep string) (float64, float64, bool) {
+	parts := strings.Split(strings.TrimSpace(s), sep)
+	if len(parts) != 2 {
+		return 0, 0, false
+	}
+	lat, err1 := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	lon, err2 := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	return lat, lon, err1 == nil && err2 == nil
+}
+
+func parseTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, strings.TrimSpace(s))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
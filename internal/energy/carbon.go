@@ -0,0 +1,234 @@
+package energy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Carbon intensity providers
+const (
+	ProviderCarbonIntensity = "carbonintensity" // National Grid ESO, Great Britain; region is a postcode (optional)
+	ProviderElectricityMaps = "electricitymaps" // Worldwide; region is a zone like "DE", needs an API key
+)
+
+// carbonCacheTTL is how long a fetched forecast is reused. Both providers
+// publish in 30-60 minute steps.
+const carbonCacheTTL = 30 * time.Minute
+
+// GreenerMinSaving is the smallest saving worth suggesting a later start for
+const GreenerMinSaving = 0.1
+
+// greenerMinDelay is the smallest delay worth suggesting
+const greenerMinDelay = 30 * time.Minute
+
+// Carbon fetches the grid's carbon intensity forecast in gCO2/kWh
+type Carbon struct {
+	provider   string
+	region     string
+	apiKey     string
+	httpClient *http.Client
+
+	mu       sync.Mutex
+	forecast []Slot
+	fetched  time.Time
+}
+
+// NewCarbon creates a carbon intensity client
+func NewCarbon(provider, region, apiKey string) (*Carbon, error) {
+	switch provider {
+	case ProviderCarbonIntensity:
+	case ProviderElectricityMaps:
+		if region == "" || apiKey == "" {
+			return nil, fmt.Errorf("electricitymaps needs a zone and an API key")
+		}
+	default:
+		return nil, fmt.Errorf("unknown carbon intensity provider %q (use %s or %s)", provider, ProviderCarbonIntensity, ProviderElectricityMaps)
+	}
+	return &Carbon{
+		provider:   provider,
+		region:     region,
+		apiKey:     apiKey,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Provider returns the provider name
+func (c *Carbon) Provider() string {
+	return c.provider
+}
+
+// Region returns the configured postcode or zone
+func (c *Carbon) Region() string {
+	return c.region
+}
+
+// Forecast returns the intensity forecast from now on, cached for
+// carbonCacheTTL
+func (c *Carbon) Forecast() ([]Slot, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.forecast != nil && time.Since(c.fetched) < carbonCacheTTL {
+		return c.forecast, nil
+	}
+
+	var slots []Slot
+	var err error
+	switch c.provider {
+	case ProviderCarbonIntensity:
+		slots, err = c.fetchCarbonIntensity()
+	case ProviderElectricityMaps:
+		slots, err = c.fetchElectricityMaps()
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Start.Before(slots[j].Start) })
+	c.forecast = slots
+	c.fetched = time.Now()
+	return slots, nil
+}
+
+// fetchCarbonIntensity reads the 48 hour forecast from carbonintensity.org.uk,
+// for the postcode's region when one is set
+func (c *Carbon) fetchCarbonIntensity() ([]Slot, error) {
+	from := time.Now().UTC().Format("2006-01-02T15:04Z")
+	endpoint := "https://api.carbonintensity.org.uk/intensity/" + from + "/fw48h"
+	if c.region != "" {
+		// The API wants the outward part of the postcode only ("SW1A")
+		outward := strings.Fields(strings.ToUpper(c.region))[0]
+		endpoint = "https://api.carbonintensity.org.uk/regional/intensity/" + from + "/fw48h/postcode/" + url.PathEscape(outward)
+	}
+
+	type period struct {
+		From      string `json:"from"`
+		To        string `json:"to"`
+		Intensity struct {
+			Forecast float64 `json:"forecast"`
+		} `json:"intensity"`
+	}
+	// National: data is the periods. Regional: data is the region (or a list
+	// of one) with the periods in its own data.
+	type region struct {
+		period
+		Data []period `json:"data"`
+	}
+	var resp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := c.getJSON(endpoint, nil, &resp); err != nil {
+		return nil, err
+	}
+	var entries []region
+	if strings.HasPrefix(strings.TrimSpace(string(resp.Data)), "{") {
+		entries = make([]region, 1)
+		if err := json.Unmarshal(resp.Data, &entries[0]); err != nil {
+			return nil, fmt.Errorf("unexpected carbon intensity response: %w", err)
+		}
+	} else if err := json.Unmarshal(resp.Data, &entries); err != nil {
+		return nil, fmt.Errorf("unexpected carbon intensity response: %w", err)
+	}
+	var periods []period
+	for _, e := range entries {
+		if len(e.Data) > 0 {
+			periods = append(periods, e.Data...)
+		} else {
+			periods = append(periods, e.period)
+		}
+	}
+
+	slots := make([]Slot, 0, len(periods))
+	for _, p := range periods {
+		start, err1 := time.Parse("2006-01-02T15:04Z", p.From)
+		end, err2 := time.Parse("2006-01-02T15:04Z", p.To)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		slots = append(slots, Slot{Start: start, End: end, Value: p.Intensity.Forecast})
+	}
+	return slots, nil
+}
+
+// fetchElectricityMaps reads the hourly forecast for the zone
+func (c *Carbon) fetchElectricityMaps() ([]Slot, error) {
+	endpoint := "https://api.electricitymap.org/v3/carbon-intensity/forecast?zone=" + url.QueryEscape(c.region)
+	var resp struct {
+		Forecast []struct {
+			CarbonIntensity float64   `json:"carbonIntensity"`
+			Datetime        time.Time `json:"datetime"`
+		} `json:"forecast"`
+	}
+	if err := c.getJSON(endpoint, map[string]string{"auth-token": c.apiKey}, &resp); err != nil {
+		return nil, err
+	}
+
+	slots := make([]Slot, 0, len(resp.Forecast))
+	for _, f := range resp.Forecast {
+		slots = append(slots, Slot{Start: f.Datetime, End: f.Datetime.Add(time.Hour), Value: f.CarbonIntensity})
+	}
+	return slots, nil
+}
+
+func (c *Carbon) getJSON(endpoint string, headers map[string]string, v interface{}) error {
+	req, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, val := range headers {
+		req.Header.Set(k, val)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("carbon intensity request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("carbon intensity API returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// At returns the slot covering t
+func At(slots []Slot, t time.Time) (Slot, bool) {
+	for _, s := range slots {
+		if !t.Before(s.Start) && t.Before(s.End) {
+			return s, true
+		}
+	}
+	return Slot{}, false
+}
+
+// Hint suggests starting later, when the grid is cleaner
+type Hint struct {
+	Start   time.Time `json:"start"`
+	Saving  float64   `json:"saving"`            // Fraction less CO2 than starting as planned, e.g. 0.25
+	Message string    `json:"message,omitempty"` // e.g. "Greener in 2 hours" (set by the caller)
+}
+
+// Greener looks for a start between start and latest where a run of length d
+// would have a lower average intensity than starting at start. It returns
+// nil unless the saving is at least GreenerMinSaving and the delay is worth it.
+func Greener(slots []Slot, start, latest time.Time, d time.Duration) *Hint {
+	planned, ok := Average(slots, start, start.Add(d))
+	if !ok || planned <= 0 {
+		return nil
+	}
+	best, avg, ok := Cheapest(slots, start, latest.Add(d), d)
+	if !ok || best.Sub(start) < greenerMinDelay {
+		return nil
+	}
+	saving := (planned - avg) / planned
+	if saving < GreenerMinSaving {
+		return nil
+	}
+	return &Hint{Start: best, Saving: saving}
+}
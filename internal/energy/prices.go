@@ -0,0 +1,198 @@
+package energy
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeLayout is the format of tariff window times
+const timeLayout = "15:04"
+
+// Slot is a value over a period of a forecast, such as an hour's electricity
+// price
+type Slot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Value float64   `json:"value"`
+}
+
+// TariffWindow is a fixed time-of-use price, e.g. 00:00-07:00 at 0.08
+type TariffWindow struct {
+	Start string  `json:"start"` // HH:MM
+	End   string  `json:"end"`   // HH:MM; 00:00 = midnight
+	Price float64 `json:"price"`
+}
+
+// Tariff is a daily time-of-use tariff, used when there's no price forecast
+type Tariff []TariffWindow
+
+// ParseTariff parses "00:00-07:00=0.08,07:00-16:00=0.22,16:00-00:00=0.30".
+// Windows that end before they start run past midnight.
+func ParseTariff(s string) (Tariff, error) {
+	var tariff Tariff
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		span, price, ok := strings.Cut(entry, "=")
+		start, end, ok2 := strings.Cut(span, "-")
+		if !ok || !ok2 {
+			return nil, fmt.Errorf("invalid tariff window %q (use HH:MM-HH:MM=price)", entry)
+		}
+		w := TariffWindow{Start: strings.TrimSpace(start), End: strings.TrimSpace(end)}
+		for _, hhmm := range []string{w.Start, w.End} {
+			if _, err := time.Parse(timeLayout, hhmm); err != nil {
+				return nil, fmt.Errorf("invalid time %q in tariff window %q", hhmm, entry)
+			}
+		}
+		p, err := strconv.ParseFloat(strings.TrimSpace(price), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid price in tariff window %q", entry)
+		}
+		w.Price = p
+		tariff = append(tariff, w)
+	}
+	return tariff, nil
+}
+
+// Forecast expands the tariff into slots covering from until from+d
+func (t Tariff) Forecast(from time.Time, d time.Duration, loc *time.Location) []Slot {
+	if loc == nil {
+		loc = time.Local
+	}
+	from = from.In(loc)
+	until := from.Add(d)
+
+	var slots []Slot
+	// Start a day early for windows running past midnight into today
+	first := time.Date(from.Year(), from.Month(), from.Day()-1, 0, 0, 0, 0, loc)
+	for day := first; day.Before(until); day = day.AddDate(0, 0, 1) {
+		for _, w := range t {
+			start := at(day, w.Start)
+			end := at(day, w.End)
+			if !end.After(start) {
+				end = end.AddDate(0, 0, 1)
+			}
+			if end.After(from) && start.Before(until) {
+				slots = append(slots, Slot{Start: start, End: end, Value: w.Price})
+			}
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Start.Before(slots[j].Start) })
+	return slots
+}
+
+func at(day time.Time, hhmm string) time.Time {
+	t, _ := time.Parse(timeLayout, hhmm)
+	return time.Date(day.Year(), day.Month(), day.Day(), t.Hour(), t.Minute(), 0, 0, day.Location())
+}
+
+// ParseHAForecast reads a price forecast from a Home Assistant sensor's
+// attributes. It understands Nord Pool's raw_today/raw_tomorrow and the
+// prices/forecast lists used by other integrations, with start/end (or
+// start_time/end_time) and value (or price) per entry.
+func ParseHAForecast(attrs map[string]interface{}) []Slot {
+	var slots []Slot
+	for _, key := range []string{"raw_today", "raw_tomorrow", "prices", "forecast"} {
+		list, _ := attrs[key].([]interface{})
+		for _, item := range list {
+			entry, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			start, ok1 := parseAttrTime(entry, "start", "start_time", "startsAt")
+			end, ok2 := parseAttrTime(entry, "end", "end_time", "endsAt")
+			value, ok3 := parseAttrFloat(entry, "value", "price", "total")
+			if !ok1 || !ok3 {
+				continue
+			}
+			if !ok2 {
+				end = start.Add(time.Hour)
+			}
+			slots = append(slots, Slot{Start: start, End: end, Value: value})
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].Start.Before(slots[j].Start) })
+	return slots
+}
+
+func parseAttrTime(entry map[string]interface{}, keys ...string) (time.Time, bool) {
+	for _, key := range keys {
+		if s, ok := entry[key].(string); ok {
+			if t, err := time.Parse(time.RFC3339, s); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+func parseAttrFloat(entry map[string]interface{}, keys ...string) (float64, bool) {
+	for _, key := range keys {
+		switch v := entry[key].(type) {
+		case float64:
+			return v, true
+		case string:
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				return f, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Cheapest finds the start between from and deadline-d where a run of length
+// d has the lowest average value. ok is false when no such run is fully
+// covered by the slots. Ties go to the earliest start.
+func Cheapest(slots []Slot, from, deadline time.Time, d time.Duration) (start time.Time, avg float64, ok bool) {
+	latest := deadline.Add(-d)
+	candidates := []time.Time{from}
+	if latest.After(from) {
+		candidates = append(candidates, latest)
+	}
+	for _, s := range slots {
+		if s.Start.After(from) && !s.Start.After(latest) {
+			candidates = append(candidates, s.Start)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Before(candidates[j]) })
+	for _, c := range candidates {
+		a, covered := Average(slots, c, c.Add(d))
+		if covered && (!ok || a < avg) {
+			start, avg, ok = c, a, true
+		}
+	}
+	return start, avg, ok
+}
+
+// Average returns the time-weighted average value between from and until,
+// and whether the slots cover all of it
+func Average(slots []Slot, from, until time.Time) (float64, bool) {
+	var sum float64
+	covered := from
+	for _, s := range slots {
+		if !s.End.After(covered) || !s.Start.Before(until) {
+			continue
+		}
+		if s.Start.After(covered) {
+			return 0, false // Gap in the forecast
+		}
+		end := s.End
+		if end.After(until) {
+			end = until
+		}
+		sum += s.Value * end.Sub(covered).Seconds()
+		covered = end
+		if !covered.Before(until) {
+			break
+		}
+	}
+	if covered.Before(until) || !until.After(from) {
+		return 0, false
+	}
+	return sum / until.Sub(from).Seconds(), true
+}
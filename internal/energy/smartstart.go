@@ -0,0 +1,435 @@
+package energy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// TickInterval is how often the queue starts and stops loads
+const TickInterval = time.Minute
+
+// replanInterval is how often waiting jobs are re-planned against a fresh
+// forecast
+const replanInterval = 15 * time.Minute
+
+// keepFinished is how long finished jobs stay in the queue status
+const keepFinished = 7 * 24 * time.Hour
+
+// Job statuses
+const (
+	StatusScheduled = "scheduled"
+	StatusRunning   = "running"
+	StatusDone      = "done"
+	StatusCancelled = "cancelled"
+	StatusFailed    = "failed"
+)
+
+// Load is a deferrable appliance, switched on for its run time
+type Load struct {
+	Name    string `json:"name"`
+	Entity  string `json:"entity"`  // switch.* or other on/off entity
+	Minutes int    `json:"minutes"` // Default run time
+}
+
+// ParseLoads parses "EV=switch.ev_charger:240,Dishwasher=switch.dishwasher:150"
+func ParseLoads(s string) ([]Load, error) {
+	var loads []Load
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, rest, ok := strings.Cut(entry, "=")
+		entity, minutes, ok2 := strings.Cut(rest, ":")
+		if !ok || !ok2 || strings.TrimSpace(name) == "" || !strings.Contains(entity, ".") {
+			return nil, fmt.Errorf("invalid load %q (use Name=entity:minutes)", entry)
+		}
+		m, err := strconv.Atoi(strings.TrimSpace(minutes))
+		if err != nil || m < 1 {
+			return nil, fmt.Errorf("invalid run time in load %q", entry)
+		}
+		loads = append(loads, Load{Name: strings.TrimSpace(name), Entity: strings.TrimSpace(entity), Minutes: m})
+	}
+	return loads, nil
+}
+
+// Job is a queued run of a load that has to finish by Deadline
+type Job struct {
+	ID        string     `json:"id"`
+	Load      string     `json:"load"`
+	Entity    string     `json:"entity"`
+	Minutes   int        `json:"minutes"`
+	Deadline  time.Time  `json:"deadline"`
+	Status    string     `json:"status"`
+	Start     *time.Time `json:"start,omitempty"`    // Planned start
+	AvgPrice  *float64   `json:"avgPrice,omitempty"` // Forecast average over the planned run; nil = no forecast
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	EndedAt   *time.Time `json:"endedAt,omitempty"`
+	Error     string     `json:"error,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+	Greener   *Hint      `json:"greener,omitempty"` // A cleaner start before the deadline (set by the caller)
+}
+
+// JobRequest queues a load. Minutes defaults to the load's run time.
+type JobRequest struct {
+	Load     string    `json:"load"`
+	Deadline time.Time `json:"deadline"`
+	Minutes  int       `json:"minutes,omitempty"`
+}
+
+// SmartStart runs deferrable loads in the cheapest forecast window before
+// their deadlines
+type SmartStart struct {
+	loads    []Load
+	forecast func() ([]Slot, error)
+	control  func(entity string, on bool) error
+
+	mu       sync.Mutex
+	doc      *store.Doc
+	jobs     []Job
+	planned  time.Time
+	onChange func()
+}
+
+// NewSmartStart loads the queue. forecast returns upcoming prices; control
+// switches a load's entity.
+func NewSmartStart(doc *store.Doc, loads []Load, forecast func() ([]Slot, error), control func(entity string, on bool) error) *SmartStart {
+	m := &SmartStart{loads: loads, forecast: forecast, control: control, doc: doc, jobs: []Job{}}
+	if _, err := doc.Load(&m.jobs); err != nil {
+		log.Printf("Warning: Failed to load smart start queue: %v", err)
+	}
+	return m
+}
+
+// OnChange registers a callback invoked when a job is queued, planned,
+// started, finished or cancelled
+func (m *SmartStart) OnChange(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// Loads returns the configured loads
+func (m *SmartStart) Loads() []Load {
+	return m.loads
+}
+
+// Jobs returns the queue: open jobs by planned start, then finished ones
+// newest first
+func (m *SmartStart) Jobs() []Job {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]Job, len(m.jobs))
+	copy(result, m.jobs)
+	sort.SliceStable(result, func(i, j int) bool {
+		oi, oj := open(result[i].Status), open(result[j].Status)
+		if oi != oj {
+			return oi
+		}
+		if oi {
+			return startOf(result[i]).Before(startOf(result[j]))
+		}
+		return result[i].CreatedAt.After(result[j].CreatedAt)
+	})
+	return result
+}
+
+// Add queues a load and plans its start
+func (m *SmartStart) Add(req JobRequest) (*Job, error) {
+	var load *Load
+	for i := range m.loads {
+		if strings.EqualFold(m.loads[i].Name, req.Load) {
+			load = &m.loads[i]
+		}
+	}
+	if load == nil {
+		return nil, fmt.Errorf("unknown load %q", req.Load)
+	}
+	minutes := req.Minutes
+	if minutes == 0 {
+		minutes = load.Minutes
+	}
+	if minutes < 1 {
+		return nil, fmt.Errorf("minutes must be positive")
+	}
+	now := time.Now()
+	if req.Deadline.Before(now.Add(time.Duration(minutes) * time.Minute)) {
+		return nil, fmt.Errorf("deadline is too soon to run %s for %d minutes", load.Name, minutes)
+	}
+
+	job := Job{
+		ID:        newID(),
+		Load:      load.Name,
+		Entity:    load.Entity,
+		Minutes:   minutes,
+		Deadline:  req.Deadline,
+		Status:    StatusScheduled,
+		CreatedAt: now,
+	}
+	slots := m.fetchForecast()
+
+	m.mu.Lock()
+	for _, j := range m.jobs {
+		if open(j.Status) && j.Entity == job.Entity {
+			m.mu.Unlock()
+			return nil, fmt.Errorf("%s is already queued", load.Name)
+		}
+	}
+	plan(&job, slots, now)
+	m.jobs = append(m.jobs, job)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Smart start: %s queued to start %s", job.Load, job.Start.Format(time.RFC3339))
+	m.notify()
+	return &job, nil
+}
+
+// Cancel removes a job from the queue, switching the load off if it's running
+func (m *SmartStart) Cancel(id string) (*Job, error) {
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("job not found: %s", id)
+	}
+	job := m.jobs[idx]
+	if !open(job.Status) {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("job already %s", job.Status)
+	}
+	m.mu.Unlock()
+
+	if job.Status == StatusRunning {
+		if err := m.control(job.Entity, false); err != nil {
+			return nil, fmt.Errorf("failed to switch off %s: %w", job.Entity, err)
+		}
+	}
+	now := time.Now()
+	job.Status = StatusCancelled
+	job.EndedAt = &now
+	if err := m.update(job); err != nil {
+		return nil, err
+	}
+	m.notify()
+	return &job, nil
+}
+
+// Run starts and stops loads as their windows come round. It blocks, so call
+// it in a goroutine.
+func (m *SmartStart) Run() {
+	ticker := time.NewTicker(TickInterval)
+	defer ticker.Stop()
+	for {
+		m.Tick(time.Now())
+		<-ticker.C
+	}
+}
+
+// Tick re-plans waiting jobs every replanInterval, then starts jobs whose
+// planned start has come and stops jobs that have run their time
+func (m *SmartStart) Tick(now time.Time) {
+	changed := false
+	m.mu.Lock()
+	if n := len(m.jobs); m.prune(now) < n {
+		changed = true
+		if err := m.save(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+	}
+	replan := now.Sub(m.planned) >= replanInterval && m.hasWaiting()
+	m.mu.Unlock()
+	if replan {
+		// Without a forecast the existing plans stand
+		slots := m.fetchForecast()
+		m.mu.Lock()
+		m.planned = now
+		for i := range m.jobs {
+			if m.jobs[i].Status == StatusScheduled && len(slots) > 0 {
+				before := m.jobs[i].Start
+				plan(&m.jobs[i], slots, now)
+				changed = changed || before == nil || !before.Equal(*m.jobs[i].Start)
+			}
+		}
+		if changed {
+			if err := m.save(); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+		m.mu.Unlock()
+	}
+
+	m.mu.Lock()
+	var due []Job
+	for _, j := range m.jobs {
+		switch {
+		case j.Status == StatusScheduled && !now.Before(*j.Start):
+			due = append(due, j)
+		case j.Status == StatusRunning && !now.Before(j.StartedAt.Add(time.Duration(j.Minutes)*time.Minute)):
+			due = append(due, j)
+		}
+	}
+	m.mu.Unlock()
+
+	for _, j := range due {
+		// Skip jobs cancelled since
+		m.mu.Lock()
+		idx := m.indexOf(j.ID)
+		current := idx >= 0 && m.jobs[idx].Status == j.Status
+		m.mu.Unlock()
+		if !current {
+			continue
+		}
+
+		starting := j.Status == StatusScheduled
+		err := m.control(j.Entity, starting)
+		at := now
+		switch {
+		case err != nil:
+			log.Printf("Error: Smart start failed to switch %s %s: %v", j.Entity, onOff(starting), err)
+			j.Status = StatusFailed
+			j.Error = err.Error()
+			j.EndedAt = &at
+		case starting:
+			log.Printf("Smart start: %s started", j.Load)
+			j.Status = StatusRunning
+			j.StartedAt = &at
+		default:
+			log.Printf("Smart start: %s finished", j.Load)
+			j.Status = StatusDone
+			j.EndedAt = &at
+		}
+		if err := m.update(j); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		changed = true
+	}
+	if changed {
+		m.notify()
+	}
+}
+
+// fetchForecast returns the price forecast, or nil if there isn't one
+func (m *SmartStart) fetchForecast() []Slot {
+	if m.forecast == nil {
+		return nil
+	}
+	slots, err := m.forecast()
+	if err != nil {
+		log.Printf("Warning: Smart start price forecast unavailable: %v", err)
+		return nil
+	}
+	return slots
+}
+
+// plan sets a job's start to the cheapest window before its deadline. Without
+// a forecast covering the run it starts now.
+func plan(j *Job, slots []Slot, now time.Time) {
+	d := time.Duration(j.Minutes) * time.Minute
+	start, avg, ok := Cheapest(slots, now, j.Deadline, d)
+	if !ok {
+		j.Start, j.AvgPrice = &now, nil
+		return
+	}
+	j.Start, j.AvgPrice = &start, &avg
+}
+
+// update replaces a job by ID and saves
+func (m *SmartStart) update(j Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if idx := m.indexOf(j.ID); idx >= 0 {
+		m.jobs[idx] = j
+	}
+	return m.save()
+}
+
+// prune drops jobs that finished more than keepFinished ago and returns how
+// many are left (caller must hold the lock)
+func (m *SmartStart) prune(now time.Time) int {
+	kept := m.jobs[:0]
+	for _, j := range m.jobs {
+		if open(j.Status) || j.EndedAt == nil || now.Sub(*j.EndedAt) < keepFinished {
+			kept = append(kept, j)
+		}
+	}
+	m.jobs = kept
+	return len(kept)
+}
+
+// hasWaiting reports whether a job is waiting to start (caller must hold the
+// lock)
+func (m *SmartStart) hasWaiting() bool {
+	for _, j := range m.jobs {
+		if j.Status == StatusScheduled {
+			return true
+		}
+	}
+	return false
+}
+
+// indexOf returns the position of a job (caller must hold the lock)
+func (m *SmartStart) indexOf(id string) int {
+	for i, j := range m.jobs {
+		if j.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save persists the queue (caller must hold the lock)
+func (m *SmartStart) save() error {
+	if err := m.doc.Save(m.jobs); err != nil {
+		return fmt.Errorf("failed to save smart start queue: %w", err)
+	}
+	return nil
+}
+
+func (m *SmartStart) notify() {
+	m.mu.Lock()
+	fn := m.onChange
+	m.mu.Unlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+func open(status string) bool {
+	return status == StatusScheduled || status == StatusRunning
+}
+
+func startOf(j Job) time.Time {
+	if j.StartedAt != nil {
+		return *j.StartedAt
+	}
+	if j.Start != nil {
+		return *j.Start
+	}
+	return j.CreatedAt
+}
+
+func onOff(on bool) string {
+	if on {
+		return "on"
+	}
+	return "off"
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
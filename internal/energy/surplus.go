@@ -0,0 +1,321 @@
+package energy
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// SurplusInterval is how often the surplus rules are evaluated
+const SurplusInterval = time.Minute
+
+// Surplus rule defaults
+const (
+	DefaultSurplusForMinutes = 10 // Export must stay above the threshold this long
+	DefaultSurplusOffMinutes = 5  // Export must stay below the stop level this long
+	DefaultSurplusMinRun     = 15 // Minutes a load runs at least once started
+)
+
+// SurplusRule runs a load while the house exports solar power. Starting and
+// stopping at different levels, each only after it has held for a while,
+// keeps passing clouds from flapping the relay. Stop below the start level
+// minus the load's own draw, since running it lowers the export.
+type SurplusRule struct {
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	Enabled    bool    `json:"enabled"`
+	Entity     string  `json:"entity"`               // On/off entity, e.g. switch.pool_pump
+	Above      float64 `json:"above"`                // Start when exporting more than this many watts...
+	ForMinutes int     `json:"forMinutes,omitempty"` // ...for this long
+	Below      float64 `json:"below"`                // Stop when exporting less than this many watts (negative = importing)...
+	OffMinutes int     `json:"offMinutes,omitempty"` // ...for this long
+	MinRun     int     `json:"minRun,omitempty"`     // Minutes to run at least once started
+}
+
+// SurplusStatus is a rule's latest evaluation
+type SurplusStatus struct {
+	Rule      string     `json:"rule"`
+	Name      string     `json:"name"`
+	Entity    string     `json:"entity"`
+	Export    *float64   `json:"export,omitempty"` // Watts
+	Running   bool       `json:"running"`
+	Since     *time.Time `json:"since,omitempty"` // When the export crossed the pending start or stop level
+	Reason    string     `json:"reason"`
+	Evaluated time.Time  `json:"evaluated"`
+}
+
+// surplusState tracks a rule between evaluations
+type surplusState struct {
+	entity     string
+	running    bool
+	startedAt  time.Time
+	aboveSince time.Time
+	belowSince time.Time
+}
+
+// Surplus evaluates the solar surplus rules against the grid export
+type Surplus struct {
+	export  func() (float64, error)
+	control func(entity string, on bool) error
+
+	tick     sync.Mutex // Serializes evaluations
+	mu       sync.RWMutex
+	doc      *store.Doc
+	rules    []SurplusRule
+	states   map[string]*surplusState
+	statuses map[string]SurplusStatus
+	onChange func()
+}
+
+// NewSurplus loads the rules. export reads the current grid export in watts
+// (negative while importing); control switches a rule's load.
+func NewSurplus(doc *store.Doc, export func() (float64, error), control func(entity string, on bool) error) *Surplus {
+	s := &Surplus{
+		export:   export,
+		control:  control,
+		doc:      doc,
+		rules:    []SurplusRule{},
+		states:   make(map[string]*surplusState),
+		statuses: make(map[string]SurplusStatus),
+	}
+	if _, err := doc.Load(&s.rules); err != nil {
+		log.Printf("Warning: Failed to load solar surplus rules: %v", err)
+	}
+	return s
+}
+
+// OnChange registers a callback invoked after a load is started or stopped
+func (s *Surplus) OnChange(fn func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = fn
+}
+
+// Rules returns all rules
+func (s *Surplus) Rules() []SurplusRule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]SurplusRule{}, s.rules...)
+}
+
+// Update validates and replaces the rules. Rules without an ID get one. Loads
+// of removed rules are stopped on the next evaluation.
+func (s *Surplus) Update(rules []SurplusRule) error {
+	if rules == nil {
+		rules = []SurplusRule{}
+	}
+	for i := range rules {
+		r := &rules[i]
+		if r.Entity == "" {
+			return fmt.Errorf("rule %q: entity is required", r.Name)
+		}
+		if r.Above <= 0 {
+			return fmt.Errorf("rule %q: above must be more than 0 watts", r.Name)
+		}
+		if r.Below >= r.Above {
+			return fmt.Errorf("rule %q: below must be less than above", r.Name)
+		}
+		if r.ForMinutes == 0 {
+			r.ForMinutes = DefaultSurplusForMinutes
+		}
+		if r.OffMinutes == 0 {
+			r.OffMinutes = DefaultSurplusOffMinutes
+		}
+		if r.MinRun == 0 {
+			r.MinRun = DefaultSurplusMinRun
+		}
+		if r.ForMinutes < 0 || r.OffMinutes < 0 || r.MinRun < 0 {
+			return fmt.Errorf("rule %q: minutes can't be negative", r.Name)
+		}
+		if r.Name == "" {
+			r.Name = r.Entity
+		}
+		if r.ID == "" {
+			r.ID = newID()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.doc.Save(rules); err != nil {
+		return fmt.Errorf("failed to save solar surplus rules: %w", err)
+	}
+	s.rules = rules
+	return nil
+}
+
+// Statuses returns each enabled rule's latest evaluation
+func (s *Surplus) Statuses() []SurplusStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]SurplusStatus, 0, len(s.rules))
+	for _, r := range s.rules {
+		if st, ok := s.statuses[r.ID]; ok {
+			result = append(result, st)
+		}
+	}
+	return result
+}
+
+// Run evaluates the rules every SurplusInterval. It blocks, so call it in a
+// goroutine.
+func (s *Surplus) Run() {
+	ticker := time.NewTicker(SurplusInterval)
+	defer ticker.Stop()
+	for {
+		s.Tick(time.Now())
+		<-ticker.C
+	}
+}
+
+// Tick reads the export once and starts or stops each rule's load. Loads are
+// only switched on a decision change, so turning one off by hand sticks until
+// the surplus comes back.
+func (s *Surplus) Tick(now time.Time) {
+	s.tick.Lock()
+	defer s.tick.Unlock()
+
+	rules := s.Rules()
+	changed := s.stopOrphans(rules)
+
+	export, err := s.export()
+	for _, r := range rules {
+		if !r.Enabled {
+			continue
+		}
+		s.mu.Lock()
+		st := s.states[r.ID]
+		if st == nil {
+			st = &surplusState{}
+			s.states[r.ID] = st
+		}
+		st.entity = r.Entity
+		s.mu.Unlock()
+
+		status := SurplusStatus{Rule: r.ID, Name: r.Name, Entity: r.Entity, Running: st.running, Evaluated: now}
+		if err != nil {
+			status.Reason = "Export unavailable: " + err.Error()
+			s.setStatus(status)
+			continue
+		}
+		status.Export = &export
+
+		if export > r.Above {
+			if st.aboveSince.IsZero() {
+				st.aboveSince = now
+			}
+		} else {
+			st.aboveSince = time.Time{}
+		}
+		if export < r.Below {
+			if st.belowSince.IsZero() {
+				st.belowSince = now
+			}
+		} else {
+			st.belowSince = time.Time{}
+		}
+
+		want := st.running
+		switch {
+		case !st.running && st.aboveSince.IsZero():
+			status.Reason = fmt.Sprintf("Exporting %s, starts above %s", watts(export), watts(r.Above))
+		case !st.running && now.Sub(st.aboveSince) < time.Duration(r.ForMinutes)*time.Minute:
+			status.Since = timePtr(st.aboveSince)
+			status.Reason = fmt.Sprintf("Exporting %s, waiting for %d minutes of surplus", watts(export), r.ForMinutes)
+		case !st.running:
+			want = true
+			status.Reason = fmt.Sprintf("Exporting over %s for %d minutes", watts(r.Above), r.ForMinutes)
+		case st.belowSince.IsZero():
+			status.Reason = fmt.Sprintf("Exporting %s, stops below %s", watts(export), watts(r.Below))
+		case now.Sub(st.belowSince) < time.Duration(r.OffMinutes)*time.Minute:
+			status.Since = timePtr(st.belowSince)
+			status.Reason = fmt.Sprintf("Exporting %s, stopping after %d minutes", watts(export), r.OffMinutes)
+		case now.Sub(st.startedAt) < time.Duration(r.MinRun)*time.Minute:
+			status.Reason = fmt.Sprintf("Surplus gone, running at least %d minutes", r.MinRun)
+		default:
+			want = false
+			status.Reason = fmt.Sprintf("Exporting under %s for %d minutes", watts(r.Below), r.OffMinutes)
+		}
+
+		if want != st.running {
+			if err := s.control(r.Entity, want); err != nil {
+				log.Printf("Warning: Failed to switch %s for solar surplus: %v", r.Entity, err)
+				status.Reason = "Failed to switch load: " + err.Error()
+			} else {
+				log.Printf("Solar surplus: %s %s (%s)", r.Name, onOff(want), status.Reason)
+				s.mu.Lock()
+				st.running = want
+				if want {
+					st.startedAt = now
+				}
+				s.mu.Unlock()
+				status.Running = want
+				changed = true
+			}
+		}
+		s.setStatus(status)
+	}
+
+	if changed {
+		s.notify()
+	}
+}
+
+// stopOrphans stops loads whose rule was disabled or removed while running,
+// reporting whether any were stopped
+func (s *Surplus) stopOrphans(rules []SurplusRule) bool {
+	enabled := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		enabled[r.ID] = r.Enabled
+	}
+
+	stopped := false
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, st := range s.states {
+		if enabled[id] {
+			continue
+		}
+		if st.running {
+			if err := s.control(st.entity, false); err != nil {
+				log.Printf("Warning: Failed to stop %s after its surplus rule went away: %v", st.entity, err)
+				continue
+			}
+			stopped = true
+		}
+		delete(s.states, id)
+		delete(s.statuses, id)
+	}
+	return stopped
+}
+
+func (s *Surplus) setStatus(st SurplusStatus) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[st.Rule] = st
+}
+
+func (s *Surplus) notify() {
+	s.mu.RLock()
+	fn := s.onChange
+	s.mu.RUnlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// watts formats a power reading, e.g. "1.2 kW" or "350 W"
+func watts(w float64) string {
+	if math.Abs(w) >= 1000 {
+		return fmt.Sprintf("%.1f kW", w/1000)
+	}
+	return fmt.Sprintf("%.0f W", w)
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,194 @@
+package entertainment
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Step platforms handled by the runner itself rather than the executor
+const (
+	StepWait  = "wait"  // wait:<seconds>, e.g. for a TV to finish powering on
+	StepScene = "scene" // scene:hue:<scene ID> or scene:scene.<entity>
+)
+
+// MaxWait caps a wait step
+const MaxWait = 60 * time.Second
+
+// Errors returned by ActivityRunner.Run
+var (
+	ErrUnknownActivity = errors.New("unknown activity")
+	ErrActivityRunning = errors.New("another activity is starting")
+)
+
+// Step is one action of an activity, e.g. "sony:tv:input:hdmi2" or
+// "syncbox:0:mode:video"
+type Step struct {
+	Platform string `json:"platform"`         // sony, shield, xbox, ps5, syncbox, scene, wait
+	Device   string `json:"device,omitempty"` // Device name, or sync box index/name
+	Action   string `json:"action,omitempty"` // power, input, mode, sync
+	Value    string `json:"value,omitempty"`  // on/off, hdmi2, video, a scene, seconds to wait
+}
+
+// String returns the step in its config form
+func (s Step) String() string {
+	switch s.Platform {
+	case StepWait, StepScene:
+		return s.Platform + ":" + s.Value
+	}
+	str := s.Platform + ":" + s.Device + ":" + s.Action
+	if s.Value != "" {
+		str += ":" + s.Value
+	}
+	return str
+}
+
+// ParseStep parses a step in its config form: "wait:<seconds>",
+// "scene:<target>" or "<platform>:<device>:<action>[:<value>]"
+func ParseStep(s string) (Step, error) {
+	s = strings.TrimSpace(s)
+	platform, rest, _ := strings.Cut(s, ":")
+	switch platform {
+	case StepWait:
+		seconds, err := strconv.Atoi(rest)
+		if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > MaxWait {
+			return Step{}, fmt.Errorf("invalid wait %q (1-%d seconds)", rest, int(MaxWait.Seconds()))
+		}
+		return Step{Platform: StepWait, Value: rest}, nil
+	case StepScene:
+		if rest == "" {
+			return Step{}, fmt.Errorf("scene step needs a scene")
+		}
+		return Step{Platform: StepScene, Value: rest}, nil
+	}
+
+	parts := strings.SplitN(s, ":", 4)
+	if len(parts) < 3 || parts[1] == "" || parts[2] == "" {
+		return Step{}, fmt.Errorf("invalid step %q (use platform:device:action[:value])", s)
+	}
+	step := Step{Platform: parts[0], Device: parts[1], Action: parts[2]}
+	if len(parts) == 4 {
+		step.Value = parts[3]
+	}
+	return step, nil
+}
+
+// Activity is a named sequence of steps, like "Movie Night"
+type Activity struct {
+	ID    string `json:"id"` // Name in lowercase with dashes, for URLs
+	Name  string `json:"name"`
+	Steps []Step `json:"steps"`
+}
+
+// NewActivity creates an activity, deriving its ID from the name
+func NewActivity(name string, steps []Step) Activity {
+	id := strings.ToLower(strings.Join(strings.Fields(name), "-"))
+	return Activity{ID: id, Name: name, Steps: steps}
+}
+
+// Executor performs a device step. It returns a function that undoes the step
+// if a later one fails, or nil when there's nothing to undo.
+type Executor func(ctx context.Context, step Step) (undo func() error, err error)
+
+// ActivityResult reports a completed activity
+type ActivityResult struct {
+	Activity string        `json:"activity"`
+	Steps    int           `json:"steps"`
+	Took     time.Duration `json:"took"`
+}
+
+// ActivityRunner runs activities one at a time, undoing the completed steps
+// in reverse order when a step fails
+type ActivityRunner struct {
+	activities []Activity
+	exec       Executor
+	scene      func(target string) error
+
+	mu sync.Mutex // Held while an activity runs
+}
+
+// NewActivityRunner creates a runner. exec performs device steps; scene
+// activates a scene target.
+func NewActivityRunner(activities []Activity, exec Executor, scene func(target string) error) *ActivityRunner {
+	return &ActivityRunner{activities: activities, exec: exec, scene: scene}
+}
+
+// Activities returns the configured activities
+func (r *ActivityRunner) Activities() []Activity {
+	return r.activities
+}
+
+// Find returns an activity by ID or name
+func (r *ActivityRunner) Find(name string) (Activity, bool) {
+	for _, a := range r.activities {
+		if a.ID == name || strings.EqualFold(a.Name, name) {
+			return a, true
+		}
+	}
+	return Activity{}, false
+}
+
+// Run performs an activity's steps in order. If one fails, the steps already
+// done are undone and the error names the failed step.
+func (r *ActivityRunner) Run(ctx context.Context, name string) (ActivityResult, error) {
+	activity, ok := r.Find(name)
+	if !ok {
+		return ActivityResult{}, ErrUnknownActivity
+	}
+	if !r.mu.TryLock() {
+		return ActivityResult{}, ErrActivityRunning
+	}
+	defer r.mu.Unlock()
+
+	start := time.Now()
+	var undos []func() error
+	for i, step := range activity.Steps {
+		undo, err := r.runStep(ctx, step)
+		if err != nil {
+			rolledBack := rollback(undos)
+			return ActivityResult{}, fmt.Errorf("step %d (%s): %w; rolled back %d step(s)", i+1, step, err, rolledBack)
+		}
+		if undo != nil {
+			undos = append(undos, undo)
+		}
+	}
+
+	log.Printf("Activity %s: %d step(s) in %s", activity.Name, len(activity.Steps), time.Since(start).Round(time.Millisecond))
+	return ActivityResult{Activity: activity.Name, Steps: len(activity.Steps), Took: time.Since(start)}, nil
+}
+
+func (r *ActivityRunner) runStep(ctx context.Context, step Step) (func() error, error) {
+	switch step.Platform {
+	case StepWait:
+		seconds, _ := strconv.Atoi(step.Value)
+		select {
+		case <-time.After(time.Duration(seconds) * time.Second):
+			return nil, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	case StepScene:
+		return nil, r.scene(step.Value)
+	default:
+		return r.exec(ctx, step)
+	}
+}
+
+// rollback runs undos in reverse, logging failures, and returns how many
+// succeeded
+func rollback(undos []func() error) int {
+	done := 0
+	for i := len(undos) - 1; i >= 0; i-- {
+		if err := undos[i](); err != nil {
+			log.Printf("Warning: Activity rollback step failed: %v", err)
+			continue
+		}
+		done++
+	}
+	return done
+}
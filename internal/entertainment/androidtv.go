@@ -0,0 +1,543 @@
+package entertainment
+
+import (
+	"bufio"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Android TV Remote protocol v2, the protocol Google TV's phone remote uses.
+// Pairing runs once on port 6467 (the TV shows a code to confirm); commands
+// then go over a persistent TLS connection on port 6466 authenticated by the
+// client certificate the TV remembers.
+const (
+	atvRemotePort  = 6466
+	atvPairingPort = 6467
+	atvTimeout     = 5 * time.Second
+	atvClientName  = "Home Control"
+
+	// RemoteConfigure/RemoteSetActive feature code (ping, key, power, volume, app link)
+	atvFeatures = 622
+
+	atvDirectionShort = 3
+)
+
+// Pairing message fields and status
+const (
+	pairStatusOK         = 200
+	pairRequest          = 10
+	pairRequestAck       = 11
+	pairOption           = 20
+	pairConfiguration    = 30
+	pairConfigurationAck = 31
+	pairSecret           = 40
+	pairSecretAck        = 41
+	pairEncodingHex      = 3
+	pairRoleInput        = 1
+	pairCodeLength       = 6
+)
+
+// Remote message fields
+const (
+	remoteConfigure    = 1
+	remoteSetActive    = 2
+	remoteError        = 3
+	remotePingRequest  = 8
+	remotePingResponse = 9
+	remoteKeyInject    = 10
+	remoteImeKeyInject = 20
+	remoteStart        = 40
+	remoteAppLink      = 90
+)
+
+// ========== Client Identity ==========
+
+// atvIdentity is the client certificate TVs pair with. It's created on first
+// use and stored, as re-pairing is needed whenever it changes.
+type atvIdentity struct {
+	doc  *store.Doc
+	mu   sync.Mutex
+	cert *tls.Certificate
+}
+
+type atvIdentityPEM struct {
+	Cert string `json:"cert"`
+	Key  string `json:"key"`
+}
+
+func (id *atvIdentity) certificate() (*tls.Certificate, error) {
+	id.mu.Lock()
+	defer id.mu.Unlock()
+	if id.cert != nil {
+		return id.cert, nil
+	}
+	if id.doc == nil {
+		return nil, errors.New("no storage for the Android TV remote certificate")
+	}
+
+	var stored atvIdentityPEM
+	if ok, err := id.doc.Load(&stored); err != nil {
+		return nil, fmt.Errorf("failed to load Android TV remote certificate: %w", err)
+	} else if ok {
+		cert, err := tls.X509KeyPair([]byte(stored.Cert), []byte(stored.Key))
+		if err != nil {
+			return nil, fmt.Errorf("invalid Android TV remote certificate: %w", err)
+		}
+		id.cert = &cert
+		return id.cert, nil
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "home_control"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(20, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	stored = atvIdentityPEM{
+		Cert: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		Key:  string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})),
+	}
+	if err := id.doc.Save(stored); err != nil {
+		return nil, fmt.Errorf("failed to save Android TV remote certificate: %w", err)
+	}
+	cert, err := tls.X509KeyPair([]byte(stored.Cert), []byte(stored.Key))
+	if err != nil {
+		return nil, err
+	}
+	id.cert = &cert
+	return id.cert, nil
+}
+
+// dial opens a TLS connection presenting the client certificate. TVs use
+// self-signed certificates, so the server isn't verified.
+func (id *atvIdentity) dial(host string, port int) (*tls.Conn, error) {
+	cert, err := id.certificate()
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: atvTimeout}
+	return tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, strconv.Itoa(port)), &tls.Config{
+		Certificates:       []tls.Certificate{*cert},
+		InsecureSkipVerify: true,
+	})
+}
+
+// ========== Pairing ==========
+
+// atvPairing is a pairing in progress; the TV shows a code until it's finished
+type atvPairing struct {
+	conn   *tls.Conn
+	reader *bufio.Reader
+}
+
+// StartPairing asks the TV to show a pairing code, to pass to FinishPairing
+func (d *ShieldDevice) StartPairing() error {
+	if d.identity == nil {
+		return errors.New("Android TV remote not available")
+	}
+	conn, err := d.identity.dial(d.Host, atvPairingPort)
+	if err != nil {
+		return fmt.Errorf("failed to connect for pairing: %w", err)
+	}
+	p := &atvPairing{conn: conn, reader: bufio.NewReader(conn)}
+
+	encoding := pbMsg{}.varint(1, pairEncodingHex).varint(2, pairCodeLength)
+	steps := []struct {
+		field int
+		msg   pbMsg
+		reply int
+	}{
+		{pairRequest, pbMsg{}.str(1, "atvremote").str(2, atvClientName), pairRequestAck},
+		{pairOption, pbMsg{}.msg(1, encoding).varint(3, pairRoleInput), pairOption},
+		{pairConfiguration, pbMsg{}.msg(1, encoding).varint(2, pairRoleInput), pairConfigurationAck},
+	}
+	for _, step := range steps {
+		if err := p.exchange(step.field, step.msg, step.reply); err != nil {
+			conn.Close()
+			return err
+		}
+	}
+
+	d.pairMu.Lock()
+	if d.pairing != nil {
+		d.pairing.conn.Close()
+	}
+	d.pairing = p
+	d.pairMu.Unlock()
+	return nil
+}
+
+// FinishPairing sends the code the TV shows, completing pairing. A mistyped
+// code can be retried while the TV still shows it.
+func (d *ShieldDevice) FinishPairing(code string) error {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	codeBytes, err := hex.DecodeString(code)
+	if err != nil || len(code) != pairCodeLength {
+		return fmt.Errorf("pairing code must be %d hex digits", pairCodeLength)
+	}
+
+	d.pairMu.Lock()
+	defer d.pairMu.Unlock()
+	p := d.pairing
+	if p == nil {
+		return errors.New("no pairing in progress")
+	}
+
+	peers := p.conn.ConnectionState().PeerCertificates
+	cert, err := d.identity.certificate()
+	if err != nil {
+		return err
+	}
+	client, ok1 := cert.Leaf.PublicKey.(*rsa.PublicKey)
+	var server *rsa.PublicKey
+	ok2 := false
+	if len(peers) > 0 {
+		server, ok2 = peers[0].PublicKey.(*rsa.PublicKey)
+	}
+	if !ok1 || !ok2 {
+		return errors.New("unsupported certificate key")
+	}
+
+	// The secret hashes both public keys with the code's last two bytes; the
+	// first byte lets us reject a mistyped code before the TV does
+	h := sha256.New()
+	h.Write(client.N.Bytes())
+	h.Write(big.NewInt(int64(client.E)).Bytes())
+	h.Write(server.N.Bytes())
+	h.Write(big.NewInt(int64(server.E)).Bytes())
+	h.Write(codeBytes[1:])
+	secret := h.Sum(nil)
+	if secret[0] != codeBytes[0] {
+		return errors.New("wrong pairing code")
+	}
+
+	d.pairing = nil
+	defer p.conn.Close()
+	if err := p.exchange(pairSecret, pbMsg{}.bytes(1, secret), pairSecretAck); err != nil {
+		return err
+	}
+	log.Printf("Paired Android TV remote with %s", d.Name)
+	d.disconnectRemote()
+	return nil
+}
+
+// exchange sends a pairing message and checks the reply
+func (p *atvPairing) exchange(field int, msg pbMsg, reply int) error {
+	out := pbMsg{}.varint(1, 2).varint(2, pairStatusOK).msg(field, msg)
+	p.conn.SetDeadline(time.Now().Add(atvTimeout))
+	if err := writeDelimited(p.conn, out); err != nil {
+		return err
+	}
+	data, err := readDelimited(p.reader)
+	if err != nil {
+		return err
+	}
+	fields, err := pbDecode(data)
+	if err != nil {
+		return err
+	}
+	if status, _ := pbFind(fields, 2); status.varint != pairStatusOK {
+		return fmt.Errorf("pairing rejected (status %d)", status.varint)
+	}
+	if _, ok := pbFind(fields, reply); !ok {
+		return errors.New("unexpected pairing response")
+	}
+	return nil
+}
+
+// ========== Remote Connection ==========
+
+// atvRemote is the command connection. A goroutine answers the TV's pings and
+// tracks power and the foreground app until the connection drops.
+type atvRemote struct {
+	conn    *tls.Conn
+	writeMu sync.Mutex
+	ready   chan struct{}
+	done    chan struct{}
+
+	mu  sync.Mutex
+	on  *bool
+	app string
+}
+
+// remoteConn returns the command connection, connecting if needed
+func (d *ShieldDevice) remoteConn() (*atvRemote, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.remote != nil {
+		select {
+		case <-d.remote.done:
+			d.remote = nil
+		default:
+			return d.remote, nil
+		}
+	}
+	if d.identity == nil {
+		return nil, errors.New("Android TV remote not available")
+	}
+
+	conn, err := d.identity.dial(d.Host, atvRemotePort)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Android TV remote (pair the device first): %w", err)
+	}
+	rc := &atvRemote{conn: conn, ready: make(chan struct{}), done: make(chan struct{})}
+	go rc.run(d.Name)
+
+	select {
+	case <-rc.ready:
+	case <-rc.done:
+		return nil, errors.New("Android TV remote connection rejected (pair the device first)")
+	case <-time.After(atvTimeout):
+		conn.Close()
+		return nil, errors.New("Android TV remote did not respond")
+	}
+	d.remote = rc
+	return rc, nil
+}
+
+// disconnectRemote closes the command connection, e.g. after re-pairing
+func (d *ShieldDevice) disconnectRemote() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.remote != nil {
+		d.remote.conn.Close()
+		d.remote = nil
+	}
+}
+
+// run reads messages until the connection drops
+func (rc *atvRemote) run(name string) {
+	defer close(rc.done)
+	defer rc.conn.Close()
+	reader := bufio.NewReader(rc.conn)
+	for {
+		data, err := readDelimited(reader)
+		if err != nil {
+			return
+		}
+		fields, err := pbDecode(data)
+		if err != nil {
+			continue
+		}
+		for _, f := range fields {
+			if err := rc.handle(f); err != nil {
+				log.Printf("Android TV remote %s: %v", name, err)
+				return
+			}
+		}
+	}
+}
+
+func (rc *atvRemote) handle(f pbField) error {
+	switch f.num {
+	case remoteConfigure:
+		info := pbMsg{}.str(1, "home_control").str(2, "home_control").varint(3, 1).str(4, "1").str(5, "home_control").str(6, "1.0.0")
+		return rc.send(pbMsg{}.msg(remoteConfigure, pbMsg{}.varint(1, atvFeatures).msg(2, info)))
+	case remoteSetActive:
+		if err := rc.send(pbMsg{}.msg(remoteSetActive, pbMsg{}.varint(1, atvFeatures))); err != nil {
+			return err
+		}
+		select {
+		case <-rc.ready:
+		default:
+			close(rc.ready)
+		}
+	case remotePingRequest:
+		fields, _ := pbDecode(f.data)
+		val, _ := pbFind(fields, 1)
+		return rc.send(pbMsg{}.msg(remotePingResponse, pbMsg{}.varint(1, val.varint)))
+	case remoteStart:
+		fields, _ := pbDecode(f.data)
+		started, _ := pbFind(fields, 1)
+		on := started.varint != 0
+		rc.mu.Lock()
+		rc.on = &on
+		rc.mu.Unlock()
+	case remoteImeKeyInject:
+		// app_info (1) carries the foreground package (12)
+		fields, _ := pbDecode(f.data)
+		if info, ok := pbFind(fields, 1); ok {
+			infoFields, _ := pbDecode(info.data)
+			if pkg, ok := pbFind(infoFields, 12); ok && len(pkg.data) > 0 {
+				rc.mu.Lock()
+				rc.app = string(pkg.data)
+				rc.mu.Unlock()
+			}
+		}
+	case remoteError:
+		return errors.New("TV reported an error")
+	}
+	return nil
+}
+
+// send writes a remote message
+func (rc *atvRemote) send(msg pbMsg) error {
+	rc.writeMu.Lock()
+	defer rc.writeMu.Unlock()
+	rc.conn.SetWriteDeadline(time.Now().Add(atvTimeout))
+	if err := writeDelimited(rc.conn, msg); err != nil {
+		rc.conn.Close()
+		return err
+	}
+	return nil
+}
+
+// status returns the last reported power state (nil until reported) and app
+func (rc *atvRemote) status() (*bool, string) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.on, rc.app
+}
+
+// remoteKey presses an Android key code; the protocol uses the same codes
+func (d *ShieldDevice) remoteKey(keyCode int) error {
+	rc, err := d.remoteConn()
+	if err != nil {
+		return err
+	}
+	return rc.send(pbMsg{}.msg(remoteKeyInject, pbMsg{}.varint(1, uint64(keyCode)).varint(2, atvDirectionShort)))
+}
+
+// remoteLaunch opens an app by package name, or any app link URL
+func (d *ShieldDevice) remoteLaunch(link string) error {
+	if !strings.Contains(link, "://") {
+		link = "market://launch?id=" + link
+	}
+	rc, err := d.remoteConn()
+	if err != nil {
+		return err
+	}
+	return rc.send(pbMsg{}.msg(remoteAppLink, pbMsg{}.str(1, link)))
+}
+
+// ========== Protobuf Encoding ==========
+
+// pbMsg is an encoded protobuf message, built field by field
+type pbMsg []byte
+
+func (m pbMsg) varint(num int, v uint64) pbMsg {
+	m = binary.AppendUvarint(m, uint64(num)<<3)
+	return binary.AppendUvarint(m, v)
+}
+
+func (m pbMsg) bytes(num int, b []byte) pbMsg {
+	m = binary.AppendUvarint(m, uint64(num)<<3|2)
+	m = binary.AppendUvarint(m, uint64(len(b)))
+	return append(m, b...)
+}
+
+func (m pbMsg) str(num int, s string) pbMsg { return m.bytes(num, []byte(s)) }
+
+func (m pbMsg) msg(num int, sub pbMsg) pbMsg { return m.bytes(num, sub) }
+
+// pbField is a decoded field: varint for wire type 0, data for wire type 2
+type pbField struct {
+	num    int
+	varint uint64
+	data   []byte
+}
+
+// pbDecode splits a message into its fields
+func pbDecode(b []byte) ([]pbField, error) {
+	var fields []pbField
+	for len(b) > 0 {
+		key, n := binary.Uvarint(b)
+		if n <= 0 {
+			return nil, errors.New("invalid protobuf key")
+		}
+		b = b[n:]
+		f := pbField{num: int(key >> 3)}
+		switch key & 7 {
+		case 0:
+			f.varint, n = binary.Uvarint(b)
+			if n <= 0 {
+				return nil, errors.New("invalid protobuf varint")
+			}
+			b = b[n:]
+		case 2:
+			length, n := binary.Uvarint(b)
+			if n <= 0 || uint64(len(b)-n) < length {
+				return nil, errors.New("invalid protobuf length")
+			}
+			f.data = b[n : n+int(length)]
+			b = b[n+int(length):]
+		case 1:
+			if len(b) < 8 {
+				return nil, errors.New("truncated protobuf field")
+			}
+			b = b[8:]
+		case 5:
+			if len(b) < 4 {
+				return nil, errors.New("truncated protobuf field")
+			}
+			b = b[4:]
+		default:
+			return nil, fmt.Errorf("unsupported protobuf wire type %d", key&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+// pbFind returns the first field with the given number
+func pbFind(fields []pbField, num int) (pbField, bool) {
+	for _, f := range fields {
+		if f.num == num {
+			return f, true
+		}
+	}
+	return pbField{}, false
+}
+
+// writeDelimited writes a varint length-prefixed message
+func writeDelimited(w io.Writer, msg pbMsg) error {
+	_, err := w.Write(append(binary.AppendUvarint(nil, uint64(len(msg))), msg...))
+	return err
+}
+
+// readDelimited reads a varint length-prefixed message
+func readDelimited(r *bufio.Reader) ([]byte, error) {
+	length, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	if length > 64*1024 {
+		return nil, errors.New("message too large")
+	}
+	data := make([]byte, length)
+	_, err = io.ReadFull(r, data)
+	return data, err
+}
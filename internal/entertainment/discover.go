@@ -0,0 +1,191 @@
+package entertainment
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ssdpAddr is the SSDP multicast group
+const ssdpAddr = "239.255.255.250:1900"
+
+// sonyServiceType is advertised by devices with the Scalar Web API used by SonyDevice
+const sonyServiceType = "urn:schemas-sony-com:service:ScalarWebAPI:1"
+
+// DiscoveredSony is a Sony TV or soundbar found on the LAN
+type DiscoveredSony struct {
+	Name       string `json:"name"` // Friendly name set on the device
+	IP         string `json:"ip"`
+	Port       int    `json:"port"`
+	Model      string `json:"model"`
+	Type       string `json:"type"`       // "tv" or "soundbar"
+	Config     string `json:"config"`     // Suggested SONY_DEVICES entry; add the PSK for TVs
+	Configured bool   `json:"configured"` // Already in SONY_DEVICES (set by the caller)
+}
+
+// sonyDescription is the part of the UPnP device description we read
+type sonyDescription struct {
+	Device struct {
+		FriendlyName string `xml:"friendlyName"`
+		Manufacturer string `xml:"manufacturer"`
+		ModelName    string `xml:"modelName"`
+		DeviceInfo   struct {
+			BaseURL string `xml:"X_ScalarWebAPI_BaseURL"`
+		} `xml:"X_ScalarWebAPI_DeviceInfo"`
+	} `xml:"device"`
+}
+
+// DiscoverSony searches the LAN for Sony devices over SSDP, waiting up to
+// timeout for replies, and reads each one's UPnP description
+func DiscoverSony(ctx context.Context, timeout time.Duration) ([]DiscoveredSony, error) {
+	locations, err := ssdpSearch(ctx, sonyServiceType, timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	found := []DiscoveredSony{}
+	seen := make(map[string]bool)
+	for _, location := range locations {
+		d, err := describeSony(ctx, client, location)
+		if err != nil || seen[d.IP] {
+			continue
+		}
+		seen[d.IP] = true
+		found = append(found, d)
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].IP < found[j].IP })
+	return found, nil
+}
+
+// ssdpSearch sends an M-SEARCH and returns the LOCATION of every reply
+func ssdpSearch(ctx context.Context, serviceType string, timeout time.Duration) ([]string, error) {
+	group, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open SSDP socket: %w", err)
+	}
+	defer conn.Close()
+
+	mx := int(timeout.Seconds())
+	if mx < 1 {
+		mx = 1
+	}
+	search := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddr + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: " + strconv.Itoa(mx) + "\r\n" +
+		"ST: " + serviceType + "\r\n\r\n"
+	// UDP can drop the request, so send it twice
+	for i := 0; i < 2; i++ {
+		if _, err := conn.WriteToUDP([]byte(search), group); err != nil {
+			return nil, fmt.Errorf("failed to send SSDP search: %w", err)
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+
+	var locations []string
+	seen := make(map[string]bool)
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			// The read deadline ends the search
+			break
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		location := resp.Header.Get("Location")
+		if location != "" && !seen[location] {
+			seen[location] = true
+			locations = append(locations, location)
+		}
+	}
+	return locations, nil
+}
+
+// describeSony fetches a device description and turns it into a candidate
+func describeSony(ctx context.Context, client *http.Client, location string) (DiscoveredSony, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", location, nil)
+	if err != nil {
+		return DiscoveredSony{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return DiscoveredSony{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return DiscoveredSony{}, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	var desc sonyDescription
+	if err := xml.NewDecoder(resp.Body).Decode(&desc); err != nil {
+		return DiscoveredSony{}, fmt.Errorf("failed to parse device description: %w", err)
+	}
+	if !strings.Contains(strings.ToLower(desc.Device.Manufacturer), "sony") {
+		return DiscoveredSony{}, fmt.Errorf("not a Sony device")
+	}
+
+	d := DiscoveredSony{
+		Name:  desc.Device.FriendlyName,
+		Model: desc.Device.ModelName,
+		Port:  10000, // Soundbars; the base URL says otherwise for TVs
+		Type:  sonyType(desc.Device.FriendlyName, desc.Device.ModelName),
+	}
+	if u, err := url.Parse(location); err == nil {
+		d.IP = u.Hostname()
+	}
+	if u, err := url.Parse(desc.Device.DeviceInfo.BaseURL); err == nil && u.Host != "" {
+		d.IP = u.Hostname()
+		if port, err := strconv.Atoi(u.Port()); err == nil {
+			d.Port = port
+		} else {
+			d.Port = 80
+		}
+	}
+	if d.IP == "" {
+		return DiscoveredSony{}, fmt.Errorf("no address in %s", location)
+	}
+
+	name := strings.ToLower(strings.Join(strings.Fields(d.Name), "-"))
+	if name == "" {
+		name = d.Type
+	}
+	d.Config = fmt.Sprintf("%s:%s:%d::%s", name, d.IP, d.Port, d.Type)
+	return d, nil
+}
+
+// sonyType guesses whether a device is a Bravia TV from its name and model
+// (KD-65X85J, XR-55A80K, KDL-50W660F) or otherwise a soundbar
+func sonyType(name, model string) string {
+	if strings.Contains(strings.ToUpper(name), "BRAVIA") {
+		return "tv"
+	}
+	for _, prefix := range []string{"KD-", "KDL-", "XR-", "KJ-", "FW-"} {
+		if strings.HasPrefix(strings.ToUpper(model), prefix) {
+			return "tv"
+		}
+	}
+	return "soundbar"
+}
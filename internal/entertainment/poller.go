@@ -0,0 +1,143 @@
+package entertainment
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Poll intervals: reachable devices are checked often so power changes show up
+// quickly; unreachable ones (unplugged, or off at the wall) back off, since each
+// check waits out a connection timeout
+const (
+	PollInterval            = 15 * time.Second
+	UnreachablePollInterval = time.Minute
+)
+
+// Unreachable is the power state reported for a device that doesn't answer
+const Unreachable = "unreachable"
+
+// StatePoller checks devices in the background so handlers can answer from
+// the cached states instead of waiting on a device that may be off
+type StatePoller[S any] struct {
+	fetch func(name string) S
+	power func(S) string // Power state, or Unreachable
+
+	mu       sync.RWMutex
+	names    []string
+	states   map[string]S
+	powers   map[string]string
+	checked  map[string]time.Time
+	onChange func(name, power string, s S)
+}
+
+// NewPoller creates a poller for the named devices. fetch reads a device's
+// state; power reduces a state to its power state ("active", "standby", "on")
+// or Unreachable.
+func NewPoller[S any](names []string, fetch func(name string) S, power func(S) string) *StatePoller[S] {
+	sorted := append([]string{}, names...)
+	sort.Strings(sorted)
+	return &StatePoller[S]{
+		fetch:   fetch,
+		power:   power,
+		names:   sorted,
+		states:  make(map[string]S),
+		powers:  make(map[string]string),
+		checked: make(map[string]time.Time),
+	}
+}
+
+// OnChange registers a callback invoked when a device's power state changes,
+// including becoming unreachable or reachable again
+func (p *StatePoller[S]) OnChange(fn func(name, power string, s S)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onChange = fn
+}
+
+// States returns the cached state of every device that has been checked, by name
+func (p *StatePoller[S]) States() []S {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	states := make([]S, 0, len(p.names))
+	for _, name := range p.names {
+		if s, ok := p.states[name]; ok {
+			states = append(states, s)
+		}
+	}
+	return states
+}
+
+// State returns a device's cached state, checking it now if it hasn't been yet
+func (p *StatePoller[S]) State(name string) S {
+	p.mu.RLock()
+	s, ok := p.states[name]
+	p.mu.RUnlock()
+	if ok {
+		return s
+	}
+	return p.Refresh(name)
+}
+
+// Reachable reports whether a device answered its last check. Devices not
+// checked yet count as reachable.
+func (p *StatePoller[S]) Reachable(name string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.powers[name] != Unreachable
+}
+
+// Refresh checks a device now, e.g. right after controlling it
+func (p *StatePoller[S]) Refresh(name string) S {
+	s := p.fetch(name)
+	power := p.power(s)
+
+	p.mu.Lock()
+	previous, seen := p.powers[name]
+	p.states[name] = s
+	p.powers[name] = power
+	p.checked[name] = time.Now()
+	fn := p.onChange
+	p.mu.Unlock()
+
+	if fn != nil && seen && previous != power {
+		fn(name, power, s)
+	}
+	return s
+}
+
+// Run checks each device when it's due, concurrently so one slow device
+// doesn't hold up the rest. It blocks, so call it in a goroutine.
+func (p *StatePoller[S]) Run() {
+	var wg sync.WaitGroup
+	for _, name := range p.names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Refresh(name)
+		}()
+	}
+	wg.Wait()
+
+	ticker := time.NewTicker(PollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		for _, name := range p.names {
+			if p.due(name) {
+				go p.Refresh(name)
+			}
+		}
+	}
+}
+
+// due reports whether a device should be checked again
+func (p *StatePoller[S]) due(name string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	interval := PollInterval
+	if p.powers[name] == Unreachable {
+		interval = UnreachablePollInterval
+	}
+	// Allow a little slack so a check finishing late doesn't skip a tick
+	return time.Since(p.checked[name]) >= interval-time.Second
+}
@@ -25,6 +25,8 @@ type PS5State struct {
 	DeviceID   string `json:"device_id"`
 	Power      string `json:"power"`       // "STANDBY", "AWAKE", "UNKNOWN"
 	Activity   string `json:"activity"`    // Current activity/game
+	TitleID    string `json:"title_id,omitempty"`
+	TitleImage string `json:"title_image,omitempty"`
 	Online     bool   `json:"online"`
 	LastUpdate time.Time `json:"last_update"`
 	Error      string `json:"error,omitempty"`
@@ -35,24 +37,42 @@ type PS5Manager struct {
 	devices     map[string]*PS5Device
 	mqttClient  mqtt.Client
 	baseTopic   string // e.g., "homeassistant" or custom discovery topic
+	stateTopic  string // PS5-MQTT's own device state prefix, e.g. "ps5-mqtt"
 	mu          sync.RWMutex
+	onChange    func(name string, state *PS5State)
 }
 
 // PS5-MQTT topic structure:
 // {baseTopic}/switch/{device_id}/set - Power control (ON/OFF)
 // {baseTopic}/sensor/{device_id}/state - State updates
 // {baseTopic}/switch/{device_id}/power/state - Power state (ON/OFF)
+// {stateTopic}/{device_id} - Device state JSON (power, online status, activity)
+
+// ps5DeviceState is the JSON PS5-MQTT publishes on {stateTopic}/{device_id}
+type ps5DeviceState struct {
+	Power        string `json:"power"`
+	DeviceStatus string `json:"device_status"` // "online", "offline"
+	Activity     *struct {
+		TitleID    string `json:"title_id"`
+		TitleName  string `json:"title_name"`
+		TitleImage string `json:"title_image"`
+	} `json:"activity"`
+}
 
 // NewPS5Manager creates a new PS5 manager
-func NewPS5Manager(mqttClient mqtt.Client, baseTopic string) *PS5Manager {
+func NewPS5Manager(mqttClient mqtt.Client, baseTopic, stateTopic string) *PS5Manager {
 	if baseTopic == "" {
 		baseTopic = "homeassistant"
 	}
+	if stateTopic == "" {
+		stateTopic = "ps5-mqtt"
+	}
 
 	mgr := &PS5Manager{
 		devices:    make(map[string]*PS5Device),
 		mqttClient: mqttClient,
 		baseTopic:  baseTopic,
+		stateTopic: stateTopic,
 	}
 
 	// Subscribe to PS5 state updates if MQTT client is connected
@@ -82,6 +102,14 @@ func (m *PS5Manager) AddDevice(name, deviceID, psnAccount string) {
 	log.Printf("Added PS5: %s (Device ID: %s)", name, deviceID)
 }
 
+// OnChange registers a callback invoked when a device's power, online status
+// or activity changes
+func (m *PS5Manager) OnChange(fn func(name string, state *PS5State)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
 // GetDevice returns a device by name
 func (m *PS5Manager) GetDevice(name string) *PS5Device {
 	m.mu.RLock()
@@ -106,7 +134,70 @@ func (m *PS5Manager) subscribeToStates() {
 	topic = fmt.Sprintf("%s/sensor/+/state", m.baseTopic)
 	m.mqttClient.Subscribe(topic, 0, m.handleActivityState)
 
-	log.Printf("PS5 MQTT: Subscribed to state topics under %s", m.baseTopic)
+	// Subscribe to PS5-MQTT's device state, which carries the running title
+	topic = fmt.Sprintf("%s/+", m.stateTopic)
+	m.mqttClient.Subscribe(topic, 0, m.handleDeviceState)
+
+	log.Printf("PS5 MQTT: Subscribed to state topics under %s and %s", m.baseTopic, m.stateTopic)
+}
+
+// handleDeviceState handles PS5-MQTT device state messages
+func (m *PS5Manager) handleDeviceState(client mqtt.Client, msg mqtt.Message) {
+	var ds ps5DeviceState
+	if err := json.Unmarshal(msg.Payload(), &ds); err != nil {
+		return // Not a device state (e.g. a retained status string)
+	}
+
+	m.updateDevice(msg.Topic(), func(device *PS5Device) string {
+		return fmt.Sprintf("%s/%s", m.stateTopic, device.DeviceID)
+	}, func(state *PS5State) {
+		if ds.Power != "" {
+			state.Power = ds.Power
+		}
+		switch ds.DeviceStatus {
+		case "online":
+			state.Online = true
+		case "offline":
+			state.Online = false
+		}
+		state.Activity, state.TitleID, state.TitleImage = "", "", ""
+		if ds.Activity != nil {
+			state.Activity = ds.Activity.TitleName
+			state.TitleID = ds.Activity.TitleID
+			state.TitleImage = ds.Activity.TitleImage
+		}
+	})
+}
+
+// updateDevice applies an update to the device whose topic matches and
+// notifies if its power, online status or activity changed
+func (m *PS5Manager) updateDevice(topic string, deviceTopic func(*PS5Device) string, update func(*PS5State)) {
+	m.mu.RLock()
+	var device *PS5Device
+	for _, d := range m.devices {
+		if deviceTopic(d) == topic {
+			device = d
+			break
+		}
+	}
+	fn := m.onChange
+	m.mu.RUnlock()
+	if device == nil {
+		return
+	}
+
+	device.mu.Lock()
+	before := *device.state
+	update(device.state)
+	device.state.LastUpdate = time.Now()
+	after := *device.state
+	device.mu.Unlock()
+
+	changed := before.Power != after.Power || before.Online != after.Online ||
+		before.Activity != after.Activity || before.TitleID != after.TitleID
+	if changed && fn != nil {
+		fn(device.Name, &after)
+	}
 }
 
 // handlePowerState handles power state MQTT messages
@@ -118,25 +209,16 @@ func (m *PS5Manager) handlePowerState(client mqtt.Client, msg mqtt.Message) {
 
 	log.Printf("PS5 MQTT power state: %s = %s", topic, payload)
 
-	// Find matching device and update state
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	for _, device := range m.devices {
-		expectedTopic := fmt.Sprintf("%s/switch/%s/power/state", m.baseTopic, device.DeviceID)
-		if topic == expectedTopic {
-			device.mu.Lock()
-			if payload == "ON" {
-				device.state.Power = "AWAKE"
-			} else {
-				device.state.Power = "STANDBY"
-			}
-			device.state.Online = true
-			device.state.LastUpdate = time.Now()
-			device.mu.Unlock()
-			break
+	m.updateDevice(topic, func(device *PS5Device) string {
+		return fmt.Sprintf("%s/switch/%s/power/state", m.baseTopic, device.DeviceID)
+	}, func(state *PS5State) {
+		if payload == "ON" {
+			state.Power = "AWAKE"
+		} else {
+			state.Power = "STANDBY"
 		}
-	}
+		state.Online = true
+	})
 }
 
 // handleActivityState handles activity/sensor state MQTT messages
@@ -146,21 +228,11 @@ func (m *PS5Manager) handleActivityState(client mqtt.Client, msg mqtt.Message) {
 
 	log.Printf("PS5 MQTT activity state: %s = %s", topic, payload)
 
-	// Find matching device and update activity
-	m.mu.RLock()
-	defer m.mu.RUnlock()
-
-	for _, device := range m.devices {
-		// Check for activity sensor
-		expectedTopic := fmt.Sprintf("%s/sensor/%s/state", m.baseTopic, device.DeviceID)
-		if topic == expectedTopic {
-			device.mu.Lock()
-			device.state.Activity = payload
-			device.state.LastUpdate = time.Now()
-			device.mu.Unlock()
-			break
-		}
-	}
+	m.updateDevice(topic, func(device *PS5Device) string {
+		return fmt.Sprintf("%s/sensor/%s/state", m.baseTopic, device.DeviceID)
+	}, func(state *PS5State) {
+		state.Activity = payload
+	})
 }
 
 // ========== Power Control ==========
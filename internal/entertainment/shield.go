@@ -6,9 +6,12 @@ import (
 	"log"
 	"net"
 	"os/exec"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"home_control/internal/store"
 )
 
 // ShieldDevice represents an Nvidia Shield TV
@@ -16,15 +19,30 @@ type ShieldDevice struct {
 	Name    string
 	Host    string
 	Port    int // Default 5555 for ADB
+	// Transport for keys, power and app launches: TransportADB or TransportRemote
+	Transport string
 	mu      sync.Mutex
 	conn    net.Conn
+
+	identity *atvIdentity
+	remote   *atvRemote // Android TV remote connection, guarded by mu
+	pairMu   sync.Mutex
+	pairing  *atvPairing
 }
 
 // ShieldManager manages Nvidia Shield devices
 type ShieldManager struct {
-	devices map[string]*ShieldDevice
+	devices  map[string]*ShieldDevice
+	identity *atvIdentity
 }
 
+// Shield control transports. ADB also reads the now playing state; the Android
+// TV remote protocol survives reboots without debugging prompts.
+const (
+	TransportADB    = "adb"
+	TransportRemote = "remote"
+)
+
 // Common key event codes for Android
 const (
 	KeyHome       = 3
@@ -65,24 +83,31 @@ var ShieldApps = map[string]string{
 	"gamestream":  "com.nvidia.tegrazone3",
 }
 
-// NewShieldManager creates a new Shield manager
-func NewShieldManager() *ShieldManager {
+// NewShieldManager creates a new Shield manager. identityDoc stores the client
+// certificate devices using the Android TV remote transport pair with.
+func NewShieldManager(identityDoc *store.Doc) *ShieldManager {
 	return &ShieldManager{
-		devices: make(map[string]*ShieldDevice),
+		devices:  make(map[string]*ShieldDevice),
+		identity: &atvIdentity{doc: identityDoc},
 	}
 }
 
-// AddDevice adds a Shield device
-func (m *ShieldManager) AddDevice(name, host string, port int) {
+// AddDevice adds a Shield device using the given transport (ADB if empty)
+func (m *ShieldManager) AddDevice(name, host string, port int, transport string) {
 	if port <= 0 {
 		port = 5555
 	}
+	if transport == "" {
+		transport = TransportADB
+	}
 	m.devices[name] = &ShieldDevice{
-		Name: name,
-		Host: host,
-		Port: port,
+		Name:      name,
+		Host:      host,
+		Port:      port,
+		Transport: transport,
+		identity:  m.identity,
 	}
-	log.Printf("Added Nvidia Shield: %s (%s:%d)", name, host, port)
+	log.Printf("Added Nvidia Shield: %s (%s:%d, %s)", name, host, port, transport)
 }
 
 // GetDevice returns a device by name
@@ -175,8 +200,27 @@ func (d *ShieldDevice) execADB(args ...string) error {
 	return nil
 }
 
+// adbOutput runs an ADB command and returns its output. Unlike execADB it
+// only logs failures, as it's used for polling.
+func (d *ShieldDevice) adbOutput(args ...string) (string, error) {
+	addr := fmt.Sprintf("%s:%d", d.Host, d.Port)
+	exec.Command("adb", "connect", addr).Run()
+
+	fullArgs := append([]string{"-s", addr}, args...)
+	output, err := exec.Command("adb", fullArgs...).CombinedOutput()
+	if err != nil {
+		log.Printf("ADB command for %s failed: %v, output: %s", d.Name, err, strings.TrimSpace(string(output)))
+		return "", fmt.Errorf("adb command failed: %w", err)
+	}
+	return string(output), nil
+}
+
 // SendKeyEvent sends a key event to the Shield
 func (d *ShieldDevice) SendKeyEvent(keyCode int) error {
+	if d.Transport == TransportRemote {
+		log.Printf("Shield %s remote key: %d", d.Name, keyCode)
+		return d.remoteKey(keyCode)
+	}
 	cmd := fmt.Sprintf("input keyevent %d", keyCode)
 	return d.Shell(cmd)
 }
@@ -308,12 +352,16 @@ func (d *ShieldDevice) LaunchApp(packageName string) error {
 	if pkg, ok := ShieldApps[strings.ToLower(packageName)]; ok {
 		packageName = pkg
 	}
+	if d.Transport == TransportRemote {
+		return d.remoteLaunch(packageName)
+	}
 
 	cmd := fmt.Sprintf("monkey -p %s -c android.intent.category.LAUNCHER 1", packageName)
 	return d.Shell(cmd)
 }
 
-// ForceStopApp force stops an app
+// ForceStopApp force stops an app. This always needs ADB, as the remote
+// protocol can't stop apps.
 func (d *ShieldDevice) ForceStopApp(packageName string) error {
 	if pkg, ok := ShieldApps[strings.ToLower(packageName)]; ok {
 		packageName = pkg
@@ -323,27 +371,242 @@ func (d *ShieldDevice) ForceStopApp(packageName string) error {
 	return d.Shell(cmd)
 }
 
-// GetCurrentApp returns the currently focused app
+// GetCurrentApp returns the package name of the focused app
 func (d *ShieldDevice) GetCurrentApp() (string, error) {
-	// This would need to parse output of: dumpsys window | grep mCurrentFocus
-	return "", fmt.Errorf("not implemented - requires adb shell output parsing")
+	output, err := d.adbOutput("shell", "dumpsys window windows | grep -E 'mCurrentFocus|mFocusedApp'")
+	if err != nil {
+		return "", err
+	}
+	if app := parseFocusedApp(output); app != "" {
+		return app, nil
+	}
+	return "", fmt.Errorf("no focused app")
+}
+
+// AppName returns the short name of a known app package (see ShieldApps), or
+// the package itself
+func AppName(packageName string) string {
+	for name, pkg := range ShieldApps {
+		if pkg == packageName {
+			return name
+		}
+	}
+	return packageName
+}
+
+// parseFocusedApp reads the package from a line like
+// "mCurrentFocus=Window{1a2b u0 com.netflix.ninja/com.netflix.ninja.MainActivity}"
+func parseFocusedApp(output string) string {
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "mCurrentFocus=") && !strings.HasPrefix(line, "mFocusedApp=") {
+			continue
+		}
+		for _, field := range strings.Fields(line) {
+			if pkg, _, ok := strings.Cut(field, "/"); ok && strings.Contains(pkg, ".") {
+				return strings.TrimLeft(pkg, "{")
+			}
+		}
+	}
+	return ""
+}
+
+// ========== Now Playing ==========
+
+// Playback states reported in ShieldNowPlaying.State
+const (
+	PlaybackPlaying   = "playing"
+	PlaybackPaused    = "paused"
+	PlaybackStopped   = "stopped"
+	PlaybackBuffering = "buffering"
+)
+
+// playbackStates maps android.media.session.PlaybackState codes
+var playbackStates = map[int]string{
+	1: PlaybackStopped,
+	2: PlaybackPaused,
+	3: PlaybackPlaying,
+	4: PlaybackPlaying, // Fast forwarding
+	5: PlaybackPlaying, // Rewinding
+	6: PlaybackBuffering,
+	8: PlaybackBuffering, // Connecting
+}
+
+// ShieldNowPlaying is the active media session on a Shield
+type ShieldNowPlaying struct {
+	App      string    `json:"app"`     // Package name
+	AppName  string    `json:"appName"` // Short name from ShieldApps, or the package
+	Title    string    `json:"title,omitempty"`
+	Artist   string    `json:"artist,omitempty"`
+	State    string    `json:"state"`
+	Position int64     `json:"position"` // Milliseconds, as of At
+	At       time.Time `json:"at"`       // When the position was read; add the elapsed time while playing
+}
+
+// mediaSession is one session from dumpsys media_session
+type mediaSession struct {
+	pkg      string
+	active   bool
+	state    int
+	position int64
+	updated  int64 // elapsedRealtime of the position, ms
+	speed    float64
+	title    string
+	artist   string
+}
+
+// GetNowPlaying reads the active media session from dumpsys media_session.
+// It returns nil when nothing is playing or paused.
+func (d *ShieldDevice) GetNowPlaying() (*ShieldNowPlaying, error) {
+	// Uptime first, to work out the position from the session's last update
+	output, err := d.adbOutput("shell", "cat /proc/uptime; dumpsys media_session")
+	if err != nil {
+		return nil, err
+	}
+	return parseNowPlaying(output, time.Now()), nil
+}
+
+// parseNowPlaying picks the session to show from a media session dump:
+// the first one playing, or else the first active one with a title
+func parseNowPlaying(output string, now time.Time) *ShieldNowPlaying {
+	lines := strings.Split(output, "\n")
+	var uptime int64
+	if len(lines) > 0 {
+		if fields := strings.Fields(lines[0]); len(fields) > 0 {
+			if secs, err := strconv.ParseFloat(fields[0], 64); err == nil {
+				uptime = int64(secs * 1000)
+			}
+		}
+	}
+
+	var sessions []*mediaSession
+	var current *mediaSession
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "package="):
+			current = &mediaSession{pkg: strings.TrimPrefix(line, "package="), speed: 1}
+			sessions = append(sessions, current)
+		case current == nil:
+		case strings.HasPrefix(line, "active="):
+			current.active = line == "active=true"
+		case strings.HasPrefix(line, "state=PlaybackState {"):
+			fields := playbackFields(line)
+			current.state, _ = strconv.Atoi(fields["state"])
+			current.position, _ = strconv.ParseInt(fields["position"], 10, 64)
+			current.updated, _ = strconv.ParseInt(fields["updated"], 10, 64)
+			if speed, err := strconv.ParseFloat(fields["speed"], 64); err == nil {
+				current.speed = speed
+			}
+		case strings.HasPrefix(line, "metadata:"):
+			// "metadata:size=7, description=Title, Artist, Album" (null when unset)
+			if _, desc, ok := strings.Cut(line, "description="); ok {
+				parts := strings.SplitN(desc, ", ", 3)
+				current.title = nullToEmpty(parts[0])
+				if len(parts) > 1 {
+					current.artist = nullToEmpty(parts[1])
+				}
+			}
+		}
+	}
+
+	var pick *mediaSession
+	for _, sess := range sessions {
+		if playbackStates[sess.state] == PlaybackPlaying {
+			pick = sess
+			break
+		}
+	}
+	if pick == nil {
+		for _, sess := range sessions {
+			if sess.active && sess.title != "" && playbackStates[sess.state] != "" {
+				pick = sess
+				break
+			}
+		}
+	}
+	if pick == nil {
+		return nil
+	}
+
+	np := &ShieldNowPlaying{
+		App:      pick.pkg,
+		AppName:  AppName(pick.pkg),
+		Title:    pick.title,
+		Artist:   pick.artist,
+		State:    playbackStates[pick.state],
+		Position: pick.position,
+		At:       now,
+	}
+	if np.State == PlaybackPlaying && uptime > 0 && pick.updated > 0 && uptime > pick.updated {
+		np.Position += int64(float64(uptime-pick.updated) * pick.speed)
+	}
+	return np
+}
+
+// playbackFields splits "state=PlaybackState {state=3, position=1234, ...}"
+// into its key=value pairs
+func playbackFields(line string) map[string]string {
+	fields := make(map[string]string)
+	_, body, _ := strings.Cut(line, "{")
+	body = strings.TrimSuffix(body, "}")
+	for _, part := range strings.Split(body, ", ") {
+		if k, v, ok := strings.Cut(part, "="); ok {
+			fields[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return fields
+}
+
+func nullToEmpty(s string) string {
+	s = strings.TrimSpace(s)
+	if s == "null" {
+		return ""
+	}
+	return s
 }
 
 // ========== Device State ==========
 
 // ShieldState represents the state of a Shield device
 type ShieldState struct {
-	Name    string `json:"name"`
-	Host    string `json:"host"`
-	Online  bool   `json:"online"`
-	Error   string `json:"error,omitempty"`
-}
-
-// GetState returns the current state
+	Name       string            `json:"name"`
+	Host       string            `json:"host"`
+	Online     bool              `json:"online"`
+	Transport  string            `json:"transport"`
+	Power      string            `json:"power,omitempty"` // "on" or "standby", reported by the remote transport
+	App        string            `json:"app,omitempty"` // Focused app package
+	NowPlaying *ShieldNowPlaying `json:"nowPlaying,omitempty"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// GetState returns the current state, with the focused app and media session
+// when the device is online and adb is available
 func (d *ShieldDevice) GetState() *ShieldState {
 	state := &ShieldState{
-		Name: d.Name,
-		Host: d.Host,
+		Name:      d.Name,
+		Host:      d.Host,
+		Transport: d.Transport,
+	}
+
+	// The remote connection reports power and the foreground app; polling
+	// ADB as well would bring back the debugging prompts it avoids
+	if d.Transport == TransportRemote {
+		rc, err := d.remoteConn()
+		if err != nil {
+			state.Error = err.Error()
+			return state
+		}
+		state.Online = true
+		on, app := rc.status()
+		if on != nil {
+			state.Power = "standby"
+			if *on {
+				state.Power = "on"
+			}
+		}
+		state.App = app
+		return state
 	}
 
 	// Try to connect to check if online
@@ -357,6 +620,17 @@ func (d *ShieldDevice) GetState() *ShieldState {
 		conn.Close()
 	}
 
+	if state.Online {
+		if np, err := d.GetNowPlaying(); err == nil {
+			state.NowPlaying = np
+		}
+		if app, err := d.GetCurrentApp(); err == nil {
+			state.App = app
+		} else if state.NowPlaying != nil {
+			state.App = state.NowPlaying.App
+		}
+	}
+
 	return state
 }
 
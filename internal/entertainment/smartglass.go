@@ -0,0 +1,575 @@
+package entertainment
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"time"
+)
+
+// Native SmartGlass protocol: discovery and power on are plain UDP packets;
+// power off and input need an encrypted session. Sessions are anonymous, so
+// the console must allow connections from any SmartGlass device
+// (Settings > Devices & connections > Remote features > Xbox app preferences).
+
+// SmartGlass packet types
+const (
+	sgConnectRequest    = 0xCC00
+	sgConnectResponse   = 0xCC01
+	sgDiscoveryRequest  = 0xDD00
+	sgDiscoveryResponse = 0xDD01
+	sgPowerOnRequest    = 0xDD02
+	sgMessage           = 0xD00D
+)
+
+// SmartGlass message types
+const (
+	sgMsgAcknowledge          = 0x01
+	sgMsgLocalJoin            = 0x03
+	sgMsgStartChannelRequest  = 0x26
+	sgMsgStartChannelResponse = 0x27
+	sgMsgDisconnect           = 0x2A
+	sgMsgPowerOff             = 0x39
+	sgMsgGamepad              = 0xF0A
+)
+
+// Channels
+const (
+	sgChannelCore = 0
+	sgChannelAck  = 0x1000000000000000
+)
+
+// sgClientAndroid is the client type we announce ourselves as
+const sgClientAndroid = 8
+
+// sgHeaderSize is the size of a message packet header
+const sgHeaderSize = 26
+
+// sgTimeout bounds each step of a session
+const sgTimeout = 3 * time.Second
+
+// sgServiceInput is the system input channel's service ID
+var sgServiceInput = mustHex("fa20b8ca66fb46e0adb60b978a59d35f")
+
+// Key derivation salt around the ECDH secret
+var (
+	sgSaltPrefix = []byte{0xD6, 0x37, 0xF0, 0xAA, 0xE2, 0xF0, 0x41, 0x8C}
+	sgSaltSuffix = []byte{0xA8, 0xF8, 0x1A, 0x57, 0x4E, 0x22, 0x8A, 0xB7}
+)
+
+// Gamepad buttons
+var sgButtons = map[string]uint16{
+	"nexus":            0x0002,
+	"menu":             0x0004,
+	"view":             0x0008,
+	"a":                0x0010,
+	"b":                0x0020,
+	"x":                0x0040,
+	"y":                0x0080,
+	"dpad_up":          0x0100,
+	"dpad_down":        0x0200,
+	"dpad_left":        0x0400,
+	"dpad_right":       0x0800,
+	"left_shoulder":    0x1000,
+	"right_shoulder":   0x2000,
+	"left_thumbstick":  0x4000,
+	"right_thumbstick": 0x8000,
+}
+
+// sgConsole is a discovery response
+type sgConsole struct {
+	Name   string
+	UUID   string
+	LiveID string // From the certificate's common name
+	Cert   *x509.Certificate
+}
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// ========== Packet encoding ==========
+
+// sgWriter builds big-endian SmartGlass payloads
+type sgWriter struct {
+	bytes.Buffer
+}
+
+func (w *sgWriter) u16(v uint16) { binary.Write(w, binary.BigEndian, v) }
+func (w *sgWriter) u32(v uint32) { binary.Write(w, binary.BigEndian, v) }
+func (w *sgWriter) u64(v uint64) { binary.Write(w, binary.BigEndian, v) }
+func (w *sgWriter) f32(v float32) {
+	binary.Write(w, binary.BigEndian, math.Float32bits(v))
+}
+
+// str writes a length-prefixed, null-terminated string
+func (w *sgWriter) str(s string) {
+	w.u16(uint16(len(s)))
+	w.WriteString(s)
+	w.WriteByte(0)
+}
+
+// sgReader reads big-endian SmartGlass payloads
+type sgReader struct {
+	buf []byte
+	err error
+}
+
+func (r *sgReader) take(n int) []byte {
+	if r.err != nil || len(r.buf) < n {
+		r.err = errors.New("short SmartGlass packet")
+		return make([]byte, n)
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *sgReader) u16() uint16 { return binary.BigEndian.Uint16(r.take(2)) }
+func (r *sgReader) u32() uint32 { return binary.BigEndian.Uint32(r.take(4)) }
+func (r *sgReader) u64() uint64 { return binary.BigEndian.Uint64(r.take(8)) }
+
+func (r *sgReader) str() string {
+	s := string(r.take(int(r.u16())))
+	r.take(1) // Null terminator
+	return s
+}
+
+// sgSimplePacket frames a discovery or power on packet
+func sgSimplePacket(pktType uint16, payload []byte) []byte {
+	var w sgWriter
+	w.u16(pktType)
+	w.u16(uint16(len(payload)))
+	w.u16(0) // Version
+	w.Write(payload)
+	return w.Bytes()
+}
+
+// buildDiscoveryPacket creates a SmartGlass discovery request packet
+func buildDiscoveryPacket() []byte {
+	var p sgWriter
+	p.u32(0) // Flags
+	p.u16(sgClientAndroid)
+	p.u16(0) // Minimum version
+	p.u16(2) // Maximum version
+	return sgSimplePacket(sgDiscoveryRequest, p.Bytes())
+}
+
+// buildPowerOnPacket creates a SmartGlass power on packet
+func buildPowerOnPacket(liveID string) []byte {
+	var p sgWriter
+	p.str(liveID)
+	return sgSimplePacket(sgPowerOnRequest, p.Bytes())
+}
+
+// parseDiscoveryResponse reads a console's name, ID and certificate
+func parseDiscoveryResponse(pkt []byte) (*sgConsole, error) {
+	r := &sgReader{buf: pkt}
+	if r.u16() != sgDiscoveryResponse {
+		return nil, errors.New("not a discovery response")
+	}
+	r.u16() // Payload length
+	r.u16() // Version
+	r.u32() // Flags
+	r.u16() // Console type
+	console := &sgConsole{Name: r.str(), UUID: r.str()}
+	r.u32() // Last error
+	der := r.take(int(r.u16()))
+	if r.err != nil {
+		return nil, r.err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid console certificate: %w", err)
+	}
+	console.Cert = cert
+	console.LiveID = cert.Subject.CommonName
+	return console, nil
+}
+
+// sgDiscover asks one console to identify itself
+func sgDiscover(host string) (*sgConsole, error) {
+	conn, err := net.DialUDP("udp4", nil, &net.UDPAddr{IP: net.ParseIP(host), Port: sgDiscoveryPort})
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(sgTimeout))
+
+	packet := buildDiscoveryPacket()
+	buf := make([]byte, 2048)
+	for attempt := 0; attempt < 3; attempt++ {
+		if _, err := conn.Write(packet); err != nil {
+			return nil, err
+		}
+		conn.SetReadDeadline(time.Now().Add(sgTimeout / 3))
+		n, err := conn.Read(buf)
+		if err != nil {
+			continue
+		}
+		return parseDiscoveryResponse(buf[:n])
+	}
+	return nil, fmt.Errorf("no discovery response from %s", host)
+}
+
+// ========== Session ==========
+
+// sgSession is an authenticated, encrypted connection to a console
+type sgSession struct {
+	conn          *net.UDPConn
+	console       *sgConsole
+	block         cipher.Block // Payload encryption
+	ivBlock       cipher.Block // Derives message IVs from headers
+	hashKey       []byte
+	participantID uint32
+	seq           uint32
+}
+
+// sgConnect discovers the console, exchanges keys and joins as an anonymous
+// client
+func sgConnect(host string) (*sgSession, error) {
+	console, err := sgDiscover(host)
+	if err != nil {
+		return nil, err
+	}
+	consoleKey, ok := console.Cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("console certificate has no EC public key")
+	}
+	peer, err := consoleKey.ECDH()
+	if err != nil {
+		return nil, err
+	}
+	var keyType uint16
+	switch peer.Curve() {
+	case ecdh.P256():
+		keyType = 0
+	case ecdh.P384():
+		keyType = 1
+	case ecdh.P521():
+		keyType = 2
+	default:
+		return nil, errors.New("unsupported console key curve")
+	}
+	priv, err := peer.Curve().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	secret, err := priv.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+	key := sha512.Sum512(append(append(append([]byte{}, sgSaltPrefix...), secret...), sgSaltSuffix...))
+
+	s := &sgSession{console: console, hashKey: key[32:]}
+	if s.block, err = aes.NewCipher(key[:16]); err != nil {
+		return nil, err
+	}
+	if s.ivBlock, err = aes.NewCipher(key[16:32]); err != nil {
+		return nil, err
+	}
+	if s.conn, err = net.DialUDP("udp4", nil, &net.UDPAddr{IP: net.ParseIP(host), Port: sgControlPort}); err != nil {
+		return nil, err
+	}
+
+	if err := s.handshake(keyType, priv.PublicKey().Bytes()[1:]); err != nil {
+		s.conn.Close()
+		return nil, err
+	}
+	if err := s.send(sgChannelCore, sgMsgLocalJoin, localJoinPayload(), false); err != nil {
+		s.conn.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// handshake sends the connect request and reads our participant ID
+func (s *sgSession) handshake(keyType uint16, publicKey []byte) error {
+	iv := make([]byte, 16)
+	clientID := make([]byte, 16)
+	rand.Read(iv)
+	rand.Read(clientID)
+
+	var unprotected sgWriter
+	unprotected.Write(clientID)
+	unprotected.u16(keyType)
+	unprotected.Write(publicKey)
+	unprotected.Write(iv)
+
+	// Anonymous: no user hash or token, in a single request
+	var protected sgWriter
+	protected.str("")
+	protected.str("")
+	protected.u32(0) // Request number
+	protected.u32(0) // Group start
+	protected.u32(1) // Group end
+
+	var pkt sgWriter
+	pkt.u16(sgConnectRequest)
+	pkt.u16(uint16(unprotected.Len()))
+	pkt.u16(uint16(protected.Len()))
+	pkt.u16(2) // Version
+	pkt.Write(unprotected.Bytes())
+	pkt.Write(s.encrypt(iv, protected.Bytes()))
+	if _, err := s.conn.Write(s.sign(pkt.Bytes())); err != nil {
+		return fmt.Errorf("failed to send connect request: %w", err)
+	}
+
+	buf := make([]byte, 2048)
+	s.conn.SetReadDeadline(time.Now().Add(sgTimeout))
+	for {
+		n, err := s.conn.Read(buf)
+		if err != nil {
+			return fmt.Errorf("no connect response (is SmartGlass allowed from any device?): %w", err)
+		}
+		pkt := buf[:n]
+		if n < 8+16+32 || binary.BigEndian.Uint16(pkt) != sgConnectResponse {
+			continue
+		}
+		if !s.verify(pkt) {
+			return errors.New("connect response failed authentication")
+		}
+		protectedLen := int(binary.BigEndian.Uint16(pkt[4:6]))
+		respIV := pkt[8:24]
+		plain, err := s.decrypt(respIV, pkt[24:n-32], protectedLen)
+		if err != nil {
+			return err
+		}
+		r := &sgReader{buf: plain}
+		result := r.u16()
+		r.u16() // Pairing state
+		s.participantID = r.u32()
+		if r.err != nil {
+			return r.err
+		}
+		if result != 0 {
+			return fmt.Errorf("console refused connection (result %d)", result)
+		}
+		return nil
+	}
+}
+
+// send encrypts and sends a message
+func (s *sgSession) send(channel uint64, msgType uint16, payload []byte, needAck bool) error {
+	s.seq++
+	flags := uint16(2)<<14 | msgType
+	if needAck {
+		flags |= 1 << 13
+	}
+
+	var header sgWriter
+	header.u16(sgMessage)
+	header.u16(uint16(len(payload)))
+	header.u32(s.seq)
+	header.u32(0) // Target: the console
+	header.u32(s.participantID)
+	header.u16(flags)
+	header.u64(channel)
+
+	iv := make([]byte, 16)
+	s.ivBlock.Encrypt(iv, header.Bytes()[:16])
+	pkt := append(header.Bytes(), s.encrypt(iv, payload)...)
+	_, err := s.conn.Write(s.sign(pkt))
+	return err
+}
+
+// receive reads messages until one of msgType arrives, acknowledging any
+// that ask for it
+func (s *sgSession) receive(msgType uint16) ([]byte, error) {
+	buf := make([]byte, 4096)
+	s.conn.SetReadDeadline(time.Now().Add(sgTimeout))
+	for {
+		n, err := s.conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		pkt := buf[:n]
+		if n < sgHeaderSize+32 || binary.BigEndian.Uint16(pkt) != sgMessage || !s.verify(pkt) {
+			continue
+		}
+		protectedLen := int(binary.BigEndian.Uint16(pkt[2:4]))
+		seq := binary.BigEndian.Uint32(pkt[4:8])
+		flags := binary.BigEndian.Uint16(pkt[16:18])
+
+		iv := make([]byte, 16)
+		s.ivBlock.Encrypt(iv, pkt[:16])
+		payload, err := s.decrypt(iv, pkt[sgHeaderSize:n-32], protectedLen)
+		if err != nil {
+			continue
+		}
+		if flags&(1<<13) != 0 {
+			s.ack(seq)
+		}
+		if flags&0x0FFF == msgType {
+			return payload, nil
+		}
+	}
+}
+
+// ack acknowledges a message from the console
+func (s *sgSession) ack(seq uint32) {
+	var p sgWriter
+	p.u32(seq) // Low watermark
+	p.u32(1)   // Processed
+	p.u32(seq)
+	p.u32(0) // Rejected
+	s.send(sgChannelAck, sgMsgAcknowledge, p.Bytes(), false)
+}
+
+// startChannel opens a service channel and returns its ID
+func (s *sgSession) startChannel(service []byte) (uint64, error) {
+	const requestID = 1
+	var p sgWriter
+	p.u32(requestID)
+	p.u32(0) // Title ID
+	p.Write(service)
+	p.u32(0) // Activity ID
+	if err := s.send(sgChannelCore, sgMsgStartChannelRequest, p.Bytes(), true); err != nil {
+		return 0, err
+	}
+
+	for {
+		payload, err := s.receive(sgMsgStartChannelResponse)
+		if err != nil {
+			return 0, fmt.Errorf("no channel response: %w", err)
+		}
+		r := &sgReader{buf: payload}
+		id := r.u32()
+		channel := r.u64()
+		result := r.u32()
+		if r.err != nil || id != requestID {
+			continue
+		}
+		if result != 0 {
+			return 0, fmt.Errorf("console refused channel (result %d)", result)
+		}
+		return channel, nil
+	}
+}
+
+// powerOff turns the console off
+func (s *sgSession) powerOff(liveID string) error {
+	var p sgWriter
+	p.str(liveID)
+	return s.send(sgChannelCore, sgMsgPowerOff, p.Bytes(), true)
+}
+
+// pressButton presses and releases a gamepad button on the input channel
+func (s *sgSession) pressButton(button string) error {
+	var buttons uint16
+	var leftTrigger, rightTrigger float32
+	switch button {
+	case "left_trigger":
+		leftTrigger = 1
+	case "right_trigger":
+		rightTrigger = 1
+	default:
+		b, ok := sgButtons[button]
+		if !ok {
+			return fmt.Errorf("unknown Xbox button: %s", button)
+		}
+		buttons = b
+	}
+
+	channel, err := s.startChannel(sgServiceInput)
+	if err != nil {
+		return err
+	}
+	if err := s.send(channel, sgMsgGamepad, gamepadPayload(buttons, leftTrigger, rightTrigger), false); err != nil {
+		return err
+	}
+	time.Sleep(100 * time.Millisecond)
+	return s.send(channel, sgMsgGamepad, gamepadPayload(0, 0, 0), false)
+}
+
+// Close says goodbye to the console and closes the socket
+func (s *sgSession) Close() {
+	var p sgWriter
+	p.u32(0) // Reason: unspecified
+	p.u32(0) // Error code
+	s.send(sgChannelCore, sgMsgDisconnect, p.Bytes(), false)
+	s.conn.Close()
+}
+
+func localJoinPayload() []byte {
+	var p sgWriter
+	p.u16(sgClientAndroid)
+	p.u16(1080) // Native width
+	p.u16(1920) // Native height
+	p.u16(240)  // DPI X
+	p.u16(240)  // DPI Y
+	p.u64(math.MaxUint64)
+	p.u32(15) // Client version
+	p.u32(6)  // OS major version
+	p.u32(0)  // OS minor version
+	p.str("Home Control")
+	return p.Bytes()
+}
+
+func gamepadPayload(buttons uint16, leftTrigger, rightTrigger float32) []byte {
+	var p sgWriter
+	p.u64(0) // Timestamp
+	p.u16(buttons)
+	p.f32(leftTrigger)
+	p.f32(rightTrigger)
+	for i := 0; i < 4; i++ {
+		p.f32(0) // Thumbsticks
+	}
+	return p.Bytes()
+}
+
+// ========== Crypto ==========
+
+// encrypt pads with PKCS#7 (only when not block aligned, as the console
+// expects) and encrypts with AES-CBC
+func (s *sgSession) encrypt(iv, plain []byte) []byte {
+	pad := (aes.BlockSize - len(plain)%aes.BlockSize) % aes.BlockSize
+	data := append(append([]byte{}, plain...), bytes.Repeat([]byte{byte(pad)}, pad)...)
+	out := make([]byte, len(data))
+	cipher.NewCBCEncrypter(s.block, iv).CryptBlocks(out, data)
+	return out
+}
+
+// decrypt decrypts with AES-CBC and trims to the payload length
+func (s *sgSession) decrypt(iv, data []byte, length int) ([]byte, error) {
+	if len(data)%aes.BlockSize != 0 || length > len(data) {
+		return nil, errors.New("invalid SmartGlass ciphertext")
+	}
+	out := make([]byte, len(data))
+	cipher.NewCBCDecrypter(s.block, iv).CryptBlocks(out, data)
+	return out[:length], nil
+}
+
+// sign appends the packet's HMAC
+func (s *sgSession) sign(pkt []byte) []byte {
+	mac := hmac.New(sha256.New, s.hashKey)
+	mac.Write(pkt)
+	return mac.Sum(pkt)
+}
+
+// verify checks a received packet's HMAC
+func (s *sgSession) verify(pkt []byte) bool {
+	if len(pkt) < 32 {
+		return false
+	}
+	mac := hmac.New(sha256.New, s.hashKey)
+	mac.Write(pkt[:len(pkt)-32])
+	return hmac.Equal(mac.Sum(nil), pkt[len(pkt)-32:])
+}
@@ -2,11 +2,14 @@ package entertainment
 
 import (
 	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -62,15 +65,37 @@ type SoundSetting struct {
 
 // Input source
 type InputSource struct {
-	URI   string `json:"uri"`
-	Title string `json:"title"`
-	Icon  string `json:"icon,omitempty"`
+	URI        string `json:"uri"`
+	Title      string `json:"title"`
+	Label      string `json:"label,omitempty"` // User-assigned name, e.g. "Xbox"
+	Icon       string `json:"icon,omitempty"`
+	Connection bool   `json:"connection"` // Something is plugged in
+	Status     string `json:"status,omitempty"`
 }
 
 // PlayingContent info
 type PlayingContent struct {
-	URI   string `json:"uri"`
+	URI          string `json:"uri"`
+	Title        string `json:"title"`
+	Source       string `json:"source,omitempty"`       // e.g. "tv:dvbt" or "extInput:hdmi"
+	DispNum      string `json:"dispNum,omitempty"`      // Channel number
+	ProgramTitle string `json:"programTitle,omitempty"` // Current programme on a TV channel
+}
+
+// App installed on a Bravia TV
+type App struct {
+	ID    string `json:"id"` // Short stable ID derived from the URI, for URLs
 	Title string `json:"title"`
+	URI   string `json:"uri"`
+	Icon  string `json:"icon,omitempty"`
+}
+
+// Channel is a TV channel from the tuner's channel list
+type Channel struct {
+	URI     string `json:"uri"`
+	Title   string `json:"title"`
+	DispNum string `json:"dispNum"`
+	Source  string `json:"source"` // e.g. "tv:dvbt"
 }
 
 // NewSonyManager creates a new Sony device manager
@@ -427,6 +452,120 @@ func (d *SonyDevice) SetSoundField(mode string) error {
 	return err
 }
 
+// ========== Apps and Channels (TV) ==========
+
+// maxChannels caps the channels read per tuner source
+const maxChannels = 500
+
+// GetApps returns the installed apps
+func (d *SonyDevice) GetApps() ([]App, error) {
+	resp, err := d.call("appControl", "getApplicationList", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var results [][]App
+	if err := json.Unmarshal(resp.Result, &results); err != nil {
+		return nil, fmt.Errorf("failed to parse application list: %w", err)
+	}
+	if len(results) == 0 {
+		return []App{}, nil
+	}
+
+	apps := results[0]
+	for i := range apps {
+		apps[i].ID = appID(apps[i].URI)
+	}
+	return apps, nil
+}
+
+// LaunchApp starts an app by ID, title (case-insensitive) or URI
+func (d *SonyDevice) LaunchApp(app string) error {
+	uri := app
+	if !strings.Contains(app, "://") {
+		apps, err := d.GetApps()
+		if err != nil {
+			return err
+		}
+		uri = ""
+		for _, a := range apps {
+			if a.ID == app || strings.EqualFold(a.Title, app) {
+				uri = a.URI
+				break
+			}
+		}
+		if uri == "" {
+			return fmt.Errorf("app not found: %s", app)
+		}
+	}
+
+	params := []interface{}{
+		map[string]interface{}{
+			"uri": uri,
+		},
+	}
+	_, err := d.call("appControl", "setActiveApp", params)
+	return err
+}
+
+// GetChannels returns the channels of every tuner source (antenna, cable,
+// satellite)
+func (d *SonyDevice) GetChannels() ([]Channel, error) {
+	params := []interface{}{
+		map[string]interface{}{
+			"scheme": "tv",
+		},
+	}
+	resp, err := d.call("avContent", "getSourceList", params)
+	if err != nil {
+		return nil, err
+	}
+
+	var sources [][]struct {
+		Source string `json:"source"`
+	}
+	if err := json.Unmarshal(resp.Result, &sources); err != nil {
+		return nil, fmt.Errorf("failed to parse source list: %w", err)
+	}
+
+	channels := []Channel{}
+	if len(sources) == 0 {
+		return channels, nil
+	}
+	for _, src := range sources[0] {
+		params := []interface{}{
+			map[string]interface{}{
+				"source": src.Source,
+				"stIdx":  0,
+				"cnt":    maxChannels,
+			},
+		}
+		resp, err := d.callV("avContent", "getContentList", "1.5", params)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s channels: %w", src.Source, err)
+		}
+
+		var results [][]Channel
+		if err := json.Unmarshal(resp.Result, &results); err != nil {
+			return nil, fmt.Errorf("failed to parse channel list: %w", err)
+		}
+		if len(results) == 0 {
+			continue
+		}
+		for _, ch := range results[0] {
+			ch.Source = src.Source
+			channels = append(channels, ch)
+		}
+	}
+	return channels, nil
+}
+
+// appID derives a short stable ID from an app URI
+func appID(uri string) string {
+	sum := sha1.Sum([]byte(uri))
+	return hex.EncodeToString(sum[:4])
+}
+
 // ========== Remote Control (IRCC) ==========
 
 // Common IRCC codes
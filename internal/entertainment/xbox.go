@@ -3,7 +3,6 @@ package entertainment
 import (
 	"bytes"
 	"crypto/rand"
-	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -26,7 +25,7 @@ type XboxDevice struct {
 // XboxManager manages Xbox consoles
 type XboxManager struct {
 	devices    map[string]*XboxDevice
-	restServer string // Optional: URL to xbox-smartglass-rest server
+	restServer string // Optional: URL to xbox-smartglass-rest server, for media and apps
 	httpClient *http.Client
 }
 
@@ -37,13 +36,6 @@ const (
 	sgMessagePort   = 5051
 )
 
-// Message types
-const (
-	msgDiscoveryRequest  = 0xDD00
-	msgDiscoveryResponse = 0xDD01
-	msgPowerOn           = 0xDD02
-)
-
 // NewXboxManager creates a new Xbox manager
 func NewXboxManager(restServerURL string) *XboxManager {
 	return &XboxManager{
@@ -81,7 +73,7 @@ func (m *XboxManager) GetDevices() map[string]*XboxDevice {
 // restCall makes a call to the SmartGlass REST server
 func (m *XboxManager) restCall(method, endpoint string, body interface{}) ([]byte, error) {
 	if m.restServer == "" {
-		return nil, fmt.Errorf("REST server not configured")
+		return nil, fmt.Errorf("XBOX_REST_SERVER not configured")
 	}
 
 	url := m.restServer + endpoint
@@ -139,41 +131,6 @@ func (m *XboxManager) DiscoverDevices() ([]map[string]interface{}, error) {
 
 // ========== Direct SmartGlass Protocol ==========
 
-// buildDiscoveryPacket creates a SmartGlass discovery request packet
-func buildDiscoveryPacket() []byte {
-	buf := new(bytes.Buffer)
-
-	// Header
-	binary.Write(buf, binary.BigEndian, uint16(msgDiscoveryRequest))
-	binary.Write(buf, binary.BigEndian, uint16(2)) // Payload length
-
-	// Flags
-	binary.Write(buf, binary.BigEndian, uint16(0))
-
-	return buf.Bytes()
-}
-
-// buildPowerOnPacket creates a SmartGlass power on packet
-func buildPowerOnPacket(liveID string) []byte {
-	buf := new(bytes.Buffer)
-
-	// Header: Type (2) + Payload length (2)
-	binary.Write(buf, binary.BigEndian, uint16(msgPowerOn))
-
-	// Live ID length + Live ID + padding
-	liveIDBytes := []byte(liveID)
-	payloadLen := uint16(2 + len(liveIDBytes))
-	binary.Write(buf, binary.BigEndian, payloadLen)
-
-	// Unprotected payload length
-	binary.Write(buf, binary.BigEndian, uint16(len(liveIDBytes)))
-
-	// Live ID
-	buf.Write(liveIDBytes)
-
-	return buf.Bytes()
-}
-
 // Discover discovers Xbox devices on the local network
 func (m *XboxManager) Discover(timeout time.Duration) ([]*XboxDevice, error) {
 	// Create UDP socket
@@ -202,7 +159,7 @@ func (m *XboxManager) Discover(timeout time.Duration) ([]*XboxDevice, error) {
 
 	// Collect responses
 	devices := make([]*XboxDevice, 0)
-	buf := make([]byte, 1024)
+	buf := make([]byte, 2048)
 
 	for {
 		n, addr, err := conn.ReadFromUDP(buf)
@@ -211,27 +168,18 @@ func (m *XboxManager) Discover(timeout time.Duration) ([]*XboxDevice, error) {
 			break
 		}
 
-		if n < 4 {
-			continue
-		}
-
-		// Parse response
-		msgType := binary.BigEndian.Uint16(buf[0:2])
-		if msgType != msgDiscoveryResponse {
+		console, err := parseDiscoveryResponse(buf[:n])
+		if err != nil {
 			continue
 		}
 
-		// Extract device info from response
-		// This is a simplified parsing - full protocol is more complex
 		device := &XboxDevice{
-			Name: fmt.Sprintf("Xbox-%s", addr.IP.String()),
-			Host: addr.IP.String(),
+			Name:   console.Name,
+			Host:   addr.IP.String(),
+			LiveID: console.LiveID,
 		}
-
-		// Try to extract Live ID from payload
-		if n > 20 {
-			// Live ID is usually in the payload
-			// Format varies by Xbox version
+		if device.Name == "" {
+			device.Name = fmt.Sprintf("Xbox-%s", addr.IP.String())
 		}
 
 		devices = append(devices, device)
@@ -273,12 +221,55 @@ func (d *XboxDevice) PowerOn() error {
 	return nil
 }
 
-// PowerOff requires an established SmartGlass connection
-// For now, this is a placeholder
+// PowerOff connects to the Xbox over SmartGlass and turns it off
 func (d *XboxDevice) PowerOff() error {
-	// Power off requires authenticated SmartGlass connection
-	// which is significantly more complex
-	return fmt.Errorf("power off requires SmartGlass REST server")
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	session, err := sgConnect(d.Host)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer session.Close()
+
+	liveID := d.LiveID
+	if liveID == "" {
+		liveID = session.console.LiveID
+	}
+	if err := session.powerOff(liveID); err != nil {
+		return fmt.Errorf("failed to send power off: %w", err)
+	}
+
+	log.Printf("Sent power off to Xbox %s (Live ID: %s)", d.Name, liveID)
+	return nil
+}
+
+// PressButton connects to the Xbox over SmartGlass and presses a gamepad
+// button (SmartGlass name, e.g. "dpad_up")
+func (d *XboxDevice) PressButton(button string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	session, err := sgConnect(d.Host)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer session.Close()
+
+	return session.pressButton(button)
+}
+
+// PowerOff turns an Xbox off, via the REST server if configured and natively
+// otherwise
+func (m *XboxManager) PowerOff(deviceName string) error {
+	if m.restServer != "" {
+		return m.PowerOffViaREST(deviceName)
+	}
+	device := m.devices[deviceName]
+	if device == nil {
+		return fmt.Errorf("device not found: %s", deviceName)
+	}
+	return device.PowerOff()
 }
 
 // PowerOnViaREST uses the REST server to power on
@@ -348,7 +339,14 @@ func (m *XboxManager) SendButton(deviceName, button string) error {
 	if mappedButton, ok := XboxButtons[button]; ok {
 		button = mappedButton
 	}
-	return m.Input(deviceName, button)
+	if m.restServer != "" {
+		return m.Input(deviceName, button)
+	}
+	device := m.devices[deviceName]
+	if device == nil {
+		return fmt.Errorf("device not found: %s", deviceName)
+	}
+	return device.PressButton(button)
 }
 
 // Navigate sends navigation commands
@@ -466,13 +464,16 @@ func (d *XboxDevice) GetState() *XboxState {
 		packet := buildDiscoveryPacket()
 		conn.Write(packet)
 
-		buf := make([]byte, 256)
+		buf := make([]byte, 2048)
 		n, err := conn.Read(buf)
 		if err != nil || n == 0 {
 			state.Online = false
 			state.Error = "No response"
 		} else {
 			state.Online = true
+			if console, err := parseDiscoveryResponse(buf[:n]); err == nil && state.LiveID == "" {
+				state.LiveID = console.LiveID
+			}
 		}
 		conn.Close()
 	}
@@ -0,0 +1,160 @@
+package favorites
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"home_control/internal/actions"
+	"home_control/internal/store"
+)
+
+// DefaultDevice is used when a request doesn't identify its device
+const DefaultDevice = "default"
+
+// Favorite is an entity, scene or playlist pinned to the quick-action bar
+type Favorite struct {
+	Kind   string `json:"kind"`           // entity, light, group, scene, playlist (see actions.Kind*)
+	Target string `json:"target"`         // Entity ID, Hue ID or Spotify URI
+	Name   string `json:"name,omitempty"` // Display label
+	Icon   string `json:"icon,omitempty"`
+}
+
+// Suggestion is a frequently used target that isn't a favorite yet
+type Suggestion struct {
+	Kind   string `json:"kind"`
+	Target string `json:"target"`
+	Count  int    `json:"count"`
+}
+
+// Store persists per-device favorites
+type Store struct {
+	mu        sync.RWMutex
+	doc       *store.Doc
+	favorites map[string][]Favorite // device ID -> favorites in display order
+}
+
+// NewStore loads favorites from the store
+func NewStore(doc *store.Doc) *Store {
+	s := &Store{doc: doc, favorites: make(map[string][]Favorite)}
+
+	if _, err := doc.Load(&s.favorites); err != nil {
+		log.Printf("Warning: Failed to load favorites: %v", err)
+	}
+	if s.favorites == nil {
+		s.favorites = make(map[string][]Favorite)
+	}
+	return s
+}
+
+// Get returns the favorites for a device
+func (s *Store) Get(device string) []Favorite {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]Favorite{}, s.favorites[device]...)
+}
+
+// Set replaces the favorites for a device
+func (s *Store) Set(device string, favs []Favorite) error {
+	for _, f := range favs {
+		if f.Kind == "" || f.Target == "" {
+			return fmt.Errorf("favorite requires kind and target")
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.favorites[device] = favs
+	return s.save()
+}
+
+// Add appends a favorite for a device (no-op if already present)
+func (s *Store) Add(device string, fav Favorite) error {
+	if fav.Kind == "" || fav.Target == "" {
+		return fmt.Errorf("favorite requires kind and target")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, f := range s.favorites[device] {
+		if f.Kind == fav.Kind && f.Target == fav.Target {
+			return nil
+		}
+	}
+	s.favorites[device] = append(s.favorites[device], fav)
+	return s.save()
+}
+
+// Remove deletes a favorite from a device
+func (s *Store) Remove(device, kind, target string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	favs := s.favorites[device]
+	for i, f := range favs {
+		if f.Kind == kind && f.Target == target {
+			s.favorites[device] = append(favs[:i:i], favs[i+1:]...)
+			return s.save()
+		}
+	}
+	return fmt.Errorf("favorite not found: %s/%s", kind, target)
+}
+
+// Suggest ranks targets from the action history by how often they were used over the
+// last 30 days, excluding existing favorites. Actions from this device count double.
+func (s *Store) Suggest(device string, history []actions.Action, limit int) []Suggestion {
+	existing := make(map[string]bool)
+	for _, f := range s.Get(device) {
+		existing[f.Kind+"|"+f.Target] = true
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -30)
+	scores := make(map[string]*Suggestion)
+	weights := make(map[string]int)
+	for _, a := range history {
+		if a.Time.Before(cutoff) {
+			continue
+		}
+		key := a.Kind + "|" + a.Target
+		if existing[key] {
+			continue
+		}
+		sg, ok := scores[key]
+		if !ok {
+			sg = &Suggestion{Kind: a.Kind, Target: a.Target}
+			scores[key] = sg
+		}
+		sg.Count++
+		weights[key]++
+		if a.Device == device {
+			weights[key]++
+		}
+	}
+
+	result := make([]Suggestion, 0, len(scores))
+	for _, sg := range scores {
+		result = append(result, *sg)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		wi := weights[result[i].Kind+"|"+result[i].Target]
+		wj := weights[result[j].Kind+"|"+result[j].Target]
+		if wi != wj {
+			return wi > wj
+		}
+		return result[i].Target < result[j].Target
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// save persists favorites (caller must hold the lock)
+func (s *Store) save() error {
+	if err := s.doc.Save(s.favorites); err != nil {
+		return fmt.Errorf("failed to save favorites: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package flags
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"sync"
+
+	"home_control/internal/store"
+)
+
+// Flag is an experimental feature that can be rolled out per device or profile.
+// Resolution order: device override, then profile override, then Default.
+type Flag struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Default     bool            `json:"default"`
+	Profiles    map[string]bool `json:"profiles,omitempty"` // profile -> enabled
+	Devices     map[string]bool `json:"devices,omitempty"`  // device ID -> enabled
+}
+
+// Config is the full flag configuration as stored
+type Config struct {
+	Flags    map[string]Flag   `json:"flags"`
+	Profiles map[string]string `json:"profiles"` // device ID -> profile (e.g. kiosk, phone, kids)
+}
+
+// Evaluation is the resolved flag set for one device
+type Evaluation struct {
+	Device  string          `json:"device"`
+	Profile string          `json:"profile,omitempty"`
+	Flags   map[string]bool `json:"flags"`
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+// Service keeps feature flags and device profile assignments
+type Service struct {
+	mu  sync.RWMutex
+	doc *store.Doc
+	cfg Config
+}
+
+// NewService loads flags from the store
+func NewService(doc *store.Doc) *Service {
+	s := &Service{doc: doc}
+
+	if _, err := doc.Load(&s.cfg); err != nil {
+		log.Printf("Warning: Failed to load feature flags: %v", err)
+	}
+	if s.cfg.Flags == nil {
+		s.cfg.Flags = make(map[string]Flag)
+	}
+	if s.cfg.Profiles == nil {
+		s.cfg.Profiles = make(map[string]string)
+	}
+	return s
+}
+
+// Evaluate resolves every flag for a device
+func (s *Service) Evaluate(device string) Evaluation {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	profile := s.cfg.Profiles[device]
+	result := Evaluation{Device: device, Profile: profile, Flags: make(map[string]bool, len(s.cfg.Flags))}
+	for name, f := range s.cfg.Flags {
+		result.Flags[name] = resolve(f, device, profile)
+	}
+	return result
+}
+
+// Enabled reports whether a flag is on for a device (unknown flags are off)
+func (s *Service) Enabled(name, device string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	f, ok := s.cfg.Flags[name]
+	if !ok {
+		return false
+	}
+	return resolve(f, device, s.cfg.Profiles[device])
+}
+
+// Config returns a copy of the full configuration, flags sorted by name
+func (s *Service) Config() ([]Flag, map[string]string) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	flags := make([]Flag, 0, len(s.cfg.Flags))
+	for _, f := range s.cfg.Flags {
+		flags = append(flags, f)
+	}
+	sort.Slice(flags, func(i, j int) bool {
+		return flags[i].Name < flags[j].Name
+	})
+
+	profiles := make(map[string]string, len(s.cfg.Profiles))
+	for device, profile := range s.cfg.Profiles {
+		profiles[device] = profile
+	}
+	return flags, profiles
+}
+
+// Set creates or replaces a flag
+func (s *Service) Set(f Flag) error {
+	if !validName.MatchString(f.Name) {
+		return fmt.Errorf("invalid flag name: %q", f.Name)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.cfg.Flags[f.Name] = f
+	return s.save()
+}
+
+// Delete removes a flag
+func (s *Service) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.cfg.Flags[name]; !ok {
+		return fmt.Errorf("flag not found: %s", name)
+	}
+	delete(s.cfg.Flags, name)
+	return s.save()
+}
+
+// SetProfile assigns a device to a profile (empty profile clears the assignment)
+func (s *Service) SetProfile(device, profile string) error {
+	if device == "" {
+		return fmt.Errorf("device is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if profile == "" {
+		delete(s.cfg.Profiles, device)
+	} else {
+		s.cfg.Profiles[device] = profile
+	}
+	return s.save()
+}
+
+func resolve(f Flag, device, profile string) bool {
+	if v, ok := f.Devices[device]; ok {
+		return v
+	}
+	if profile != "" {
+		if v, ok := f.Profiles[profile]; ok {
+			return v
+		}
+	}
+	return f.Default
+}
+
+// save persists the configuration (caller must hold the lock)
+func (s *Service) save() error {
+	if err := s.doc.Save(s.cfg); err != nil {
+		return fmt.Errorf("failed to save feature flags: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,375 @@
+package geofence
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Automation triggers
+const (
+	TriggerApproaching = "approaching" // ETA home drops to Minutes or less
+	TriggerArrive      = "arrive"
+	TriggerLeave       = "leave"
+)
+
+// Report sources
+const (
+	SourceOwnTracks     = "owntracks"
+	SourceHomeAssistant = "homeassistant"
+	SourceGeneric       = "generic"
+)
+
+// Events carried by a Report
+const (
+	EventEnter = "enter"
+	EventLeave = "leave"
+)
+
+// DefaultHomeRadius is the home zone radius in meters when none is configured
+const DefaultHomeRadius = 100
+
+// Report is a location update from a phone app, normalized from OwnTracks,
+// the Home Assistant companion app or a plain {"event","zone"} body
+type Report struct {
+	Source  string    `json:"source"`
+	Event   string    `json:"event,omitempty"` // enter or leave a zone; empty for a plain location
+	Zone    string    `json:"zone,omitempty"`
+	Lat     float64   `json:"lat,omitempty"`
+	Lon     float64   `json:"lon,omitempty"`
+	Located bool      `json:"located"`         // Lat/Lon are set
+	Speed   float64   `json:"speed,omitempty"` // m/s; 0 = unknown
+	Time    time.Time `json:"time"`
+}
+
+// Automation runs when a person approaches, arrives or leaves
+type Automation struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Enabled   bool     `json:"enabled"`
+	Person    string   `json:"person,omitempty"`  // Person ID; empty = anyone
+	Trigger   string   `json:"trigger"`           // approaching, arrive, leave
+	Minutes   int      `json:"minutes,omitempty"` // ETA for approaching
+	AfterDark bool     `json:"afterDark"`         // Only between sunset and sunrise
+	TurnOn    []string `json:"turnOn,omitempty"`  // Entity IDs
+	TurnOff   []string `json:"turnOff,omitempty"`
+	Scene     string   `json:"scene,omitempty"` // hue:<sceneID> or scene.*
+}
+
+// Settings configures the home zone and automations
+type Settings struct {
+	HomeRadius  int          `json:"homeRadius"` // Meters
+	Automations []Automation `json:"automations"`
+}
+
+// Status is a person's latest location as seen by the geofence
+type Status struct {
+	Person     string    `json:"person"`
+	State      string    `json:"state"`                // home, not_home or a zone name
+	Distance   *float64  `json:"distance,omitempty"`   // Meters from home
+	ETAMinutes *float64  `json:"etaMinutes,omitempty"` // While heading home
+	Updated    time.Time `json:"updated"`
+}
+
+// Result is what a report changed
+type Result struct {
+	Status    Status       `json:"status"`
+	Triggered []Automation `json:"triggered"`
+}
+
+type track struct {
+	status   Status
+	lat, lon float64
+	located  bool
+	at       time.Time
+	fired    map[string]bool // Approaching automations already run on this trip
+}
+
+// Manager turns location reports into presence states and runs arrival automations
+type Manager struct {
+	homeLat, homeLon float64
+
+	mu       sync.Mutex
+	doc      *store.Doc
+	settings Settings
+	tracks   map[string]*track
+}
+
+// NewManager loads the settings. homeLat/homeLon are the center of the home zone;
+// without them only zone events are used, not raw locations.
+func NewManager(doc *store.Doc, homeLat, homeLon float64, defaultRadius int) *Manager {
+	if defaultRadius <= 0 {
+		defaultRadius = DefaultHomeRadius
+	}
+	m := &Manager{
+		homeLat:  homeLat,
+		homeLon:  homeLon,
+		doc:      doc,
+		settings: Settings{HomeRadius: defaultRadius, Automations: []Automation{}},
+		tracks:   make(map[string]*track),
+	}
+	if _, err := doc.Load(&m.settings); err != nil {
+		log.Printf("Warning: Failed to load geofence settings: %v", err)
+	}
+	return m
+}
+
+// Settings returns the home zone and automations
+func (m *Manager) Settings() Settings {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.settings
+}
+
+// Update validates and saves the settings. Automations without an ID get one.
+func (m *Manager) Update(settings Settings) error {
+	if settings.HomeRadius < 10 {
+		return fmt.Errorf("homeRadius must be at least 10 meters")
+	}
+	if settings.Automations == nil {
+		settings.Automations = []Automation{}
+	}
+	for i := range settings.Automations {
+		a := &settings.Automations[i]
+		switch a.Trigger {
+		case TriggerApproaching:
+			if a.Minutes < 1 {
+				return fmt.Errorf("automation %q: approaching needs minutes", a.Name)
+			}
+		case TriggerArrive, TriggerLeave:
+		default:
+			return fmt.Errorf("automation %q: unknown trigger %q", a.Name, a.Trigger)
+		}
+		if len(a.TurnOn) == 0 && len(a.TurnOff) == 0 && a.Scene == "" {
+			return fmt.Errorf("automation %q does nothing", a.Name)
+		}
+		if a.ID == "" {
+			a.ID = newID()
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.doc.Save(settings); err != nil {
+		return fmt.Errorf("failed to save geofence settings: %w", err)
+	}
+	m.settings = settings
+	return nil
+}
+
+// Statuses returns everyone's latest location
+func (m *Manager) Statuses() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	result := make([]Status, 0, len(m.tracks))
+	for _, t := range m.tracks {
+		result = append(result, t.status)
+	}
+	return result
+}
+
+// Handle applies a report for a person, returning their new state and the
+// automations it triggered (not yet filtered for darkness)
+func (m *Manager) Handle(person string, r Report) Result {
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.tracks[person]
+	if !ok {
+		t = &track{status: Status{Person: person}, fired: make(map[string]bool)}
+		m.tracks[person] = t
+	}
+	previous := t.status.State
+	radius := float64(m.settings.HomeRadius)
+
+	state := previous
+	switch {
+	case r.Event == EventEnter:
+		state = zoneState(r.Zone)
+	case r.Event == EventLeave:
+		if zone := zoneState(r.Zone); zone == "home" || zone == previous {
+			state = "not_home"
+		}
+	case r.Zone != "":
+		state = zoneState(r.Zone)
+	}
+
+	t.status.Distance, t.status.ETAMinutes = nil, nil
+	if r.Located && (m.homeLat != 0 || m.homeLon != 0) {
+		distance := haversine(r.Lat, r.Lon, m.homeLat, m.homeLon)
+		t.status.Distance = &distance
+		if r.Event == "" && r.Zone == "" {
+			if distance <= radius {
+				state = "home"
+			} else if previous == "home" || previous == "" {
+				state = "not_home"
+			}
+		}
+
+		speed := r.Speed
+		closer := false
+		if t.located && r.Time.After(t.at) {
+			before := haversine(t.lat, t.lon, m.homeLat, m.homeLon)
+			closer = distance < before
+			if speed <= 0 {
+				speed = (before - distance) / r.Time.Sub(t.at).Seconds()
+			}
+		}
+		if state != "home" && closer && speed > 1 {
+			eta := (distance - radius) / speed / 60
+			t.status.ETAMinutes = &eta
+		}
+		t.lat, t.lon, t.located, t.at = r.Lat, r.Lon, true, r.Time
+	}
+	if state == "" {
+		state = "not_home"
+	}
+	t.status.State = state
+	t.status.Updated = r.Time
+
+	var triggered []Automation
+	for _, a := range m.settings.Automations {
+		if !a.Enabled || (a.Person != "" && a.Person != person) {
+			continue
+		}
+		switch a.Trigger {
+		case TriggerArrive:
+			// The first report after a restart isn't an arrival
+			if state == "home" && previous != "home" && previous != "" {
+				triggered = append(triggered, a)
+			}
+		case TriggerLeave:
+			if state != "home" && previous == "home" {
+				triggered = append(triggered, a)
+			}
+		case TriggerApproaching:
+			if !t.fired[a.ID] && t.status.ETAMinutes != nil && *t.status.ETAMinutes <= float64(a.Minutes) {
+				t.fired[a.ID] = true
+				triggered = append(triggered, a)
+			}
+		}
+	}
+	// A new trip starts on every arrival and departure
+	if state == "home" || previous == "home" {
+		clear(t.fired)
+	}
+
+	return Result{Status: t.status, Triggered: triggered}
+}
+
+// Parse reads a webhook body. OwnTracks sends {"_type":"transition","event":"enter","desc":"Home"}
+// and {"_type":"location","lat":..,"lon":..,"vel":km/h,"inregions":[..]}; the Home Assistant
+// companion app's update_location sends {"type":"update_location","data":{"gps":[lat,lon],
+// "location_name":"home","speed":m/s}}. Anything else is read as {"event","zone","lat","lon"}.
+func Parse(body []byte) (Report, error) {
+	var raw struct {
+		Type      string   `json:"_type"`
+		HAType    string   `json:"type"`
+		Event     string   `json:"event"`
+		Desc      string   `json:"desc"`
+		Zone      string   `json:"zone"`
+		Lat       *float64 `json:"lat"`
+		Lon       *float64 `json:"lon"`
+		Vel       float64  `json:"vel"`
+		Tst       int64    `json:"tst"`
+		InRegions []string `json:"inregions"`
+		Data      struct {
+			GPS          []float64 `json:"gps"`
+			LocationName string    `json:"location_name"`
+			Speed        float64   `json:"speed"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return Report{}, fmt.Errorf("invalid payload: %w", err)
+	}
+
+	r := Report{Source: SourceGeneric}
+	if raw.Tst > 0 {
+		r.Time = time.Unix(raw.Tst, 0)
+	}
+	if raw.Type != "" {
+		r.Source = SourceOwnTracks
+	}
+	switch {
+	case raw.Type == "transition":
+		r.Event, r.Zone = raw.Event, raw.Desc
+	case raw.Type == "location":
+		r.Speed = raw.Vel / 3.6
+		for _, region := range raw.InRegions {
+			if zoneState(region) == "home" {
+				r.Zone = region
+			}
+		}
+	case raw.Type != "":
+		// OwnTracks also posts cards, waypoints, etc.; nothing to do for those
+		return Report{Source: SourceOwnTracks}, nil
+	case raw.HAType == "update_location":
+		r.Source = SourceHomeAssistant
+		if len(raw.Data.GPS) == 2 {
+			r.Lat, r.Lon, r.Located = raw.Data.GPS[0], raw.Data.GPS[1], true
+		}
+		r.Zone, r.Speed = raw.Data.LocationName, raw.Data.Speed
+		return r, nil
+	default:
+		r.Event, r.Zone = raw.Event, raw.Zone
+	}
+	if raw.Lat != nil && raw.Lon != nil {
+		r.Lat, r.Lon, r.Located = *raw.Lat, *raw.Lon, true
+	}
+
+	switch r.Event {
+	case "", EventEnter, EventLeave:
+	case "exit":
+		r.Event = EventLeave
+	default:
+		return Report{}, fmt.Errorf("unknown event %q", r.Event)
+	}
+	if r.Event != "" && r.Zone == "" {
+		return Report{}, fmt.Errorf("%s event without a zone", r.Event)
+	}
+	return r, nil
+}
+
+// Empty reports whether a parsed report carries nothing to apply
+func (r Report) Empty() bool {
+	return r.Event == "" && r.Zone == "" && !r.Located
+}
+
+// zoneState maps a zone name to a presence state: "Home" -> home, "not_home"
+// stays, anything else is lowercased
+func zoneState(zone string) string {
+	z := strings.ToLower(strings.TrimSpace(zone))
+	if z == "home" || z == "zone.home" {
+		return "home"
+	}
+	return strings.TrimPrefix(z, "zone.")
+}
+
+// haversine returns the distance between two points in meters
+func haversine(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371000
+	rad := math.Pi / 180
+	dLat := (lat2 - lat1) * rad
+	dLon := (lon2 - lon1) * rad
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1*rad)*math.Cos(lat2*rad)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
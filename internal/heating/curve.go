@@ -0,0 +1,277 @@
+package heating
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Interval is how often zone setpoints are recalculated
+const Interval = 15 * time.Minute
+
+// PreviewHours is how far ahead Preview plans
+const PreviewHours = 24
+
+// step is the setpoint resolution; thermostats rarely take finer steps
+const step = 0.5
+
+// Point is a point on a heating curve: with Outdoor degrees outside the room
+// target is Setpoint. Temperatures use the weather units (°F).
+type Point struct {
+	Outdoor  float64 `json:"outdoor"`
+	Setpoint float64 `json:"setpoint"`
+}
+
+// Zone is a climate entity whose target follows the outdoor temperature
+type Zone struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	Enabled   bool    `json:"enabled"`
+	Entity    string  `json:"entity"`              // climate.*
+	Curve     []Point `json:"curve"`               // At least two points; flat beyond the ends
+	LeadHours int     `json:"leadHours,omitempty"` // Use the forecast this far ahead, since radiators are slow to respond
+}
+
+// Settings are the weather-compensated zones
+type Settings struct {
+	Zones []Zone `json:"zones"`
+}
+
+// Reading is an outdoor temperature at a time, current or forecast
+type Reading struct {
+	Time time.Time
+	Temp float64
+}
+
+// Planned is a zone's setpoint for an hour
+type Planned struct {
+	Time     time.Time `json:"time"`
+	Outdoor  float64   `json:"outdoor"` // Forecast the setpoint is based on, LeadHours later
+	Setpoint float64   `json:"setpoint"`
+}
+
+// ZonePlan is a zone's setpoints for the next PreviewHours
+type ZonePlan struct {
+	Zone    string    `json:"zone"`
+	Name    string    `json:"name"`
+	Entity  string    `json:"entity"`
+	Current *float64  `json:"current,omitempty"` // Setpoint applied last
+	Hours   []Planned `json:"hours"`
+}
+
+// Manager calculates weather-compensated setpoints and applies them
+type Manager struct {
+	forecast func() []Reading
+
+	mu       sync.RWMutex
+	doc      *store.Doc
+	settings Settings
+	apply    func(entity string, setpoint float64) error
+	applied  map[string]float64 // Zone ID -> setpoint applied last
+}
+
+// NewManager loads the zones. forecast returns outdoor temperatures ordered by
+// time, starting with the current one.
+func NewManager(doc *store.Doc, forecast func() []Reading) *Manager {
+	m := &Manager{
+		forecast: forecast,
+		doc:      doc,
+		settings: Settings{Zones: []Zone{}},
+		applied:  make(map[string]float64),
+	}
+	if _, err := doc.Load(&m.settings); err != nil {
+		log.Printf("Warning: Failed to load heating curve settings: %v", err)
+	}
+	return m
+}
+
+// SetApplier sets the function that sets a climate entity's target
+func (m *Manager) SetApplier(fn func(entity string, setpoint float64) error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.apply = fn
+}
+
+// Settings returns the zones
+func (m *Manager) Settings() Settings {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.settings
+}
+
+// Update validates and saves the zones. Zones without an ID get one.
+func (m *Manager) Update(settings Settings) error {
+	if settings.Zones == nil {
+		settings.Zones = []Zone{}
+	}
+	for i := range settings.Zones {
+		z := &settings.Zones[i]
+		if !strings.HasPrefix(z.Entity, "climate.") {
+			return fmt.Errorf("zone %q: entity must be a climate entity", z.Name)
+		}
+		if len(z.Curve) < 2 {
+			return fmt.Errorf("zone %q: the curve needs at least two points", z.Name)
+		}
+		sort.Slice(z.Curve, func(a, b int) bool { return z.Curve[a].Outdoor < z.Curve[b].Outdoor })
+		for j := 1; j < len(z.Curve); j++ {
+			if z.Curve[j].Outdoor == z.Curve[j-1].Outdoor {
+				return fmt.Errorf("zone %q: two points at %.1f° outdoors", z.Name, z.Curve[j].Outdoor)
+			}
+		}
+		if z.LeadHours < 0 || z.LeadHours > 12 {
+			return fmt.Errorf("zone %q: leadHours must be between 0 and 12", z.Name)
+		}
+		if z.Name == "" {
+			z.Name = z.Entity
+		}
+		if z.ID == "" {
+			z.ID = newID()
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.doc.Save(settings); err != nil {
+		return fmt.Errorf("failed to save heating curve settings: %w", err)
+	}
+	m.settings = settings
+	return nil
+}
+
+// Setpoint returns the target on a curve for an outdoor temperature,
+// interpolating between points and rounded to the thermostat step
+func Setpoint(curve []Point, outdoor float64) float64 {
+	if len(curve) == 0 {
+		return 0
+	}
+	if outdoor <= curve[0].Outdoor {
+		return round(curve[0].Setpoint)
+	}
+	for i := 1; i < len(curve); i++ {
+		a, b := curve[i-1], curve[i]
+		if outdoor <= b.Outdoor {
+			f := (outdoor - a.Outdoor) / (b.Outdoor - a.Outdoor)
+			return round(a.Setpoint + f*(b.Setpoint-a.Setpoint))
+		}
+	}
+	return round(curve[len(curve)-1].Setpoint)
+}
+
+// Preview returns each enabled zone's planned setpoint for the next PreviewHours
+func (m *Manager) Preview(now time.Time) []ZonePlan {
+	readings := m.forecast()
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	start := now.Truncate(time.Hour)
+	result := make([]ZonePlan, 0, len(m.settings.Zones))
+	for _, z := range m.settings.Zones {
+		if !z.Enabled {
+			continue
+		}
+		plan := ZonePlan{Zone: z.ID, Name: z.Name, Entity: z.Entity, Hours: []Planned{}}
+		if sp, ok := m.applied[z.ID]; ok {
+			plan.Current = &sp
+		}
+		for h := 0; h < PreviewHours; h++ {
+			at := start.Add(time.Duration(h) * time.Hour)
+			outdoor, ok := outdoorAt(readings, at.Add(time.Duration(z.LeadHours)*time.Hour))
+			if !ok {
+				break
+			}
+			plan.Hours = append(plan.Hours, Planned{Time: at, Outdoor: math.Round(outdoor*10) / 10, Setpoint: Setpoint(z.Curve, outdoor)})
+		}
+		result = append(result, plan)
+	}
+	return result
+}
+
+// Run recalculates setpoints every Interval. It blocks, so call it in a goroutine.
+func (m *Manager) Run() {
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+	for {
+		m.Tick(time.Now())
+		<-ticker.C
+	}
+}
+
+// Tick applies each enabled zone's current setpoint. A zone is only set when
+// its setpoint changes, so a manual adjustment holds until the weather moves
+// the curve.
+func (m *Manager) Tick(now time.Time) {
+	readings := m.forecast()
+
+	m.mu.RLock()
+	zones := append([]Zone{}, m.settings.Zones...)
+	fn := m.apply
+	m.mu.RUnlock()
+	if fn == nil {
+		return
+	}
+
+	for _, z := range zones {
+		if !z.Enabled {
+			continue
+		}
+		outdoor, ok := outdoorAt(readings, now.Add(time.Duration(z.LeadHours)*time.Hour))
+		if !ok {
+			return
+		}
+		sp := Setpoint(z.Curve, outdoor)
+
+		m.mu.RLock()
+		last, applied := m.applied[z.ID]
+		m.mu.RUnlock()
+		if applied && last == sp {
+			continue
+		}
+		if err := fn(z.Entity, sp); err != nil {
+			log.Printf("Warning: Failed to set %s to %.1f°: %v", z.Entity, sp, err)
+			continue
+		}
+		log.Printf("Heating curve: %s set to %.1f° for %.1f° outdoors", z.Name, sp, outdoor)
+
+		m.mu.Lock()
+		m.applied[z.ID] = sp
+		m.mu.Unlock()
+	}
+}
+
+// outdoorAt interpolates the outdoor temperature at t from readings ordered by
+// time, holding the first and last readings beyond either end
+func outdoorAt(readings []Reading, t time.Time) (float64, bool) {
+	if len(readings) == 0 {
+		return 0, false
+	}
+	if !t.After(readings[0].Time) {
+		return readings[0].Temp, true
+	}
+	for i := 1; i < len(readings); i++ {
+		a, b := readings[i-1], readings[i]
+		if !t.After(b.Time) {
+			f := float64(t.Sub(a.Time)) / float64(b.Time.Sub(a.Time))
+			return a.Temp + f*(b.Temp-a.Temp), true
+		}
+	}
+	return readings[len(readings)-1].Temp, true
+}
+
+func round(v float64) float64 {
+	return math.Round(v/step) * step
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,151 @@
+package history
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Point is one downsampled bucket of a sensor's time series
+type Point struct {
+	Time  time.Time `json:"time"` // Bucket start
+	Avg   float64   `json:"avg"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Count int       `json:"count"`
+}
+
+// Recorder persists sensor readings and serves downsampled series
+type Recorder struct {
+	store       *store.Store
+	minInterval time.Duration // Minimum spacing of Record calls per sensor
+
+	mu   sync.Mutex
+	last map[string]reading
+}
+
+type reading struct {
+	time  time.Time
+	value float64
+}
+
+// NewRecorder creates a recorder that stores at most one reading per sensor per
+// minInterval via Record (RecordChange is not throttled)
+func NewRecorder(st *store.Store, minInterval time.Duration) *Recorder {
+	return &Recorder{
+		store:       st,
+		minInterval: minInterval,
+		last:        make(map[string]reading),
+	}
+}
+
+// Record stores a reading for a continuously sampled sensor (e.g. light level),
+// dropping readings that arrive faster than the recorder's minimum interval
+func (r *Recorder) Record(sensor string, value float64) {
+	now := time.Now()
+	r.mu.Lock()
+	prev, ok := r.last[sensor]
+	if ok && now.Sub(prev.time) < r.minInterval {
+		r.mu.Unlock()
+		return
+	}
+	r.last[sensor] = reading{time: now, value: value}
+	r.mu.Unlock()
+
+	r.insert(sensor, now, value)
+}
+
+// RecordChange stores a reading only when it differs from the previous one
+// (for state sensors such as proximity or binary sensors)
+func (r *Recorder) RecordChange(sensor string, value float64) {
+	now := time.Now()
+	r.mu.Lock()
+	prev, ok := r.last[sensor]
+	if ok && prev.value == value {
+		r.mu.Unlock()
+		return
+	}
+	r.last[sensor] = reading{time: now, value: value}
+	r.mu.Unlock()
+
+	r.insert(sensor, now, value)
+}
+
+func (r *Recorder) insert(sensor string, t time.Time, value float64) {
+	_, err := r.store.DB().Exec(`INSERT INTO sensor_readings (sensor, time, value) VALUES (?, ?, ?)`,
+		sensor, t.UnixMilli(), value)
+	if err != nil {
+		log.Printf("Warning: Failed to record %s reading: %v", sensor, err)
+	}
+}
+
+// Series returns readings for a sensor between from and to, averaged into at most
+// buckets points
+func (r *Recorder) Series(sensor string, from, to time.Time, buckets int) ([]Point, error) {
+	if buckets <= 0 {
+		buckets = 200
+	}
+	span := to.Sub(from).Milliseconds()
+	if span <= 0 {
+		return nil, fmt.Errorf("invalid time range")
+	}
+	bucketMs := span / int64(buckets)
+	if bucketMs < 1 {
+		bucketMs = 1
+	}
+
+	rows, err := r.store.DB().Query(`
+		SELECT (time - ?) / ? AS bucket, AVG(value), MIN(value), MAX(value), COUNT(*)
+		FROM sensor_readings
+		WHERE sensor = ? AND time >= ? AND time < ?
+		GROUP BY bucket
+		ORDER BY bucket`,
+		from.UnixMilli(), bucketMs, sensor, from.UnixMilli(), to.UnixMilli())
+	if err != nil {
+		return nil, fmt.Errorf("failed to query sensor history: %w", err)
+	}
+	defer rows.Close()
+
+	points := []Point{}
+	for rows.Next() {
+		var bucket int64
+		var p Point
+		if err := rows.Scan(&bucket, &p.Avg, &p.Min, &p.Max, &p.Count); err != nil {
+			return nil, fmt.Errorf("failed to scan sensor history: %w", err)
+		}
+		p.Time = time.UnixMilli(from.UnixMilli() + bucket*bucketMs)
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
+
+// Sensors returns the IDs of all sensors with recorded history
+func (r *Recorder) Sensors() ([]string, error) {
+	rows, err := r.store.DB().Query(`SELECT DISTINCT sensor FROM sensor_readings ORDER BY sensor`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sensors: %w", err)
+	}
+	defer rows.Close()
+
+	sensors := []string{}
+	for rows.Next() {
+		var s string
+		if err := rows.Scan(&s); err != nil {
+			return nil, err
+		}
+		sensors = append(sensors, s)
+	}
+	return sensors, rows.Err()
+}
+
+// Prune deletes readings older than before
+func (r *Recorder) Prune(before time.Time) (int64, error) {
+	res, err := r.store.DB().Exec(`DELETE FROM sensor_readings WHERE time < ?`, before.UnixMilli())
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune sensor history: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -20,6 +20,8 @@ const (
 	CardTypeLock     CardType = "lock"
 	CardTypeFan      CardType = "fan"
 	CardTypeVacuum   CardType = "vacuum"
+	CardTypeMedia    CardType = "media_player"
+	CardTypeCover    CardType = "cover"
 	CardTypeUnknown  CardType = "unknown"
 )
 
@@ -36,6 +38,47 @@ type Card struct {
 	Attributes   map[string]interface{} `json:"attributes"`
 	IsLightGroup bool                   `json:"isLightGroup"` // True if this is a light group with member lights
 	Members      []*Card                `json:"members"`      // Member lights for light groups
+	Media        *MediaInfo             `json:"media,omitempty"` // Now-playing details for media players
+	Cover        *CoverInfo             `json:"cover,omitempty"` // Position details for covers/blinds
+	Vacuum       *VacuumInfo            `json:"vacuum,omitempty"` // Battery and status for robot vacuums
+}
+
+// VacuumInfo holds battery, status and fan speed for a vacuum entity
+type VacuumInfo struct {
+	Status       string   `json:"status"`            // Integration status text, falls back to state
+	Battery      *int     `json:"battery,omitempty"` // Percent
+	Charging     bool     `json:"charging"`
+	FanSpeed     string   `json:"fanSpeed,omitempty"`
+	FanSpeedList []string `json:"fanSpeedList,omitempty"`
+	Cleaning     bool     `json:"cleaning"`
+}
+
+// Cover supported_features bits
+const (
+	coverSupportSetPosition     = 4
+	coverSupportSetTiltPosition = 128
+)
+
+// CoverInfo holds position and tilt for a cover entity (0 = closed, 100 = open)
+type CoverInfo struct {
+	Position         *int `json:"position,omitempty"`
+	Tilt             *int `json:"tilt,omitempty"`
+	SupportsPosition bool `json:"supportsPosition"`
+	SupportsTilt     bool `json:"supportsTilt"`
+}
+
+// MediaInfo holds now-playing metadata and controls for a media_player entity
+type MediaInfo struct {
+	Title      string   `json:"title,omitempty"`
+	Artist     string   `json:"artist,omitempty"`
+	Album      string   `json:"album,omitempty"`
+	App        string   `json:"app,omitempty"`
+	Picture    string   `json:"picture,omitempty"` // Relative to the HA base URL
+	Volume     float64  `json:"volume"`            // 0-1
+	Muted      bool     `json:"muted"`
+	Source     string   `json:"source,omitempty"`
+	SourceList []string `json:"sourceList,omitempty"`
+	Playing    bool     `json:"playing"`
 }
 
 // CardGroup holds cards organized by group
@@ -72,22 +115,125 @@ func (e *Entity) ToCard() *Card {
 	// Check if entity is "on"
 	card.IsOn = e.State == "on" || e.State == "home" || e.State == "open"
 
+	if card.Type == CardTypeMedia {
+		card.IsOn = e.State != "off" && e.State != "standby" && e.State != "unavailable"
+		card.Media = mediaInfo(e)
+	}
+	if card.Type == CardTypeCover {
+		card.IsOn = e.State == "open" || e.State == "opening"
+		card.Cover = coverInfo(e)
+	}
+	if card.Type == CardTypeVacuum {
+		card.IsOn = e.State == "cleaning"
+		card.Vacuum = vacuumInfo(e)
+	}
+
 	// Determine group
 	card.Group = detectGroup(e.EntityID, card.Type)
 
 	return card
 }
 
+// mediaInfo extracts now-playing attributes from a media_player entity
+func mediaInfo(e *Entity) *MediaInfo {
+	attr := func(key string) string {
+		v, _ := e.Attributes[key].(string)
+		return v
+	}
+
+	info := &MediaInfo{
+		Title:   attr("media_title"),
+		Artist:  attr("media_artist"),
+		Album:   attr("media_album_name"),
+		App:     attr("app_name"),
+		Picture: attr("entity_picture"),
+		Source:  attr("source"),
+		Playing: e.State == "playing",
+	}
+	if info.Artist == "" {
+		info.Artist = attr("media_series_title")
+	}
+	if vol, ok := e.Attributes["volume_level"].(float64); ok {
+		info.Volume = vol
+	}
+	if muted, ok := e.Attributes["is_volume_muted"].(bool); ok {
+		info.Muted = muted
+	}
+	if sources, ok := e.Attributes["source_list"].([]interface{}); ok {
+		for _, src := range sources {
+			if name, ok := src.(string); ok {
+				info.SourceList = append(info.SourceList, name)
+			}
+		}
+	}
+	return info
+}
+
+// coverInfo extracts position, tilt and capabilities from a cover entity
+func coverInfo(e *Entity) *CoverInfo {
+	info := &CoverInfo{}
+	if v, ok := e.Attributes["current_position"].(float64); ok {
+		pos := int(v)
+		info.Position = &pos
+	}
+	if v, ok := e.Attributes["current_tilt_position"].(float64); ok {
+		tilt := int(v)
+		info.Tilt = &tilt
+	}
+	if features, ok := e.Attributes["supported_features"].(float64); ok {
+		info.SupportsPosition = int(features)&coverSupportSetPosition != 0
+		info.SupportsTilt = int(features)&coverSupportSetTiltPosition != 0
+	} else {
+		// Older integrations don't report features; infer from attributes
+		info.SupportsPosition = info.Position != nil
+		info.SupportsTilt = info.Tilt != nil
+	}
+	return info
+}
+
+// vacuumInfo extracts battery, status and fan speed from a vacuum entity
+func vacuumInfo(e *Entity) *VacuumInfo {
+	info := &VacuumInfo{
+		Status:   e.State,
+		Cleaning: e.State == "cleaning",
+	}
+	if status, ok := e.Attributes["status"].(string); ok && status != "" {
+		info.Status = status
+	}
+	if v, ok := e.Attributes["battery_level"].(float64); ok {
+		battery := int(v)
+		info.Battery = &battery
+	}
+	if icon, ok := e.Attributes["battery_icon"].(string); ok {
+		info.Charging = strings.Contains(icon, "charging")
+	}
+	if e.State == "docked" && strings.Contains(strings.ToLower(info.Status), "charg") {
+		info.Charging = true
+	}
+	if speed, ok := e.Attributes["fan_speed"].(string); ok {
+		info.FanSpeed = speed
+	}
+	if speeds, ok := e.Attributes["fan_speed_list"].([]interface{}); ok {
+		for _, s := range speeds {
+			if name, ok := s.(string); ok {
+				info.FanSpeedList = append(info.FanSpeedList, name)
+			}
+		}
+	}
+	return info
+}
+
 // GroupCards organizes cards into groups
 func GroupCards(cards []*Card) []*CardGroup {
 	// Define group order and icons
-	groupOrder := []string{"Lights", "Climate", "Security", "Tesla", "Home", "Other"}
+	groupOrder := []string{"Lights", "Climate", "Security", "Media", "Tesla", "Home", "Other"}
 	groupIcons := map[string]string{
 		"Lights":   "💡",
 		"Climate":  "🌡️",
 		"Security": "🔒",
 		"Tesla":    "🚗",
 		"Home":     "🏠",
+		"Media":    "📺",
 		"Other":    "📦",
 	}
 
@@ -168,6 +314,10 @@ func detectGroup(entityID string, cardType CardType) string {
 		return "Security"
 	case CardTypeFan:
 		return "Climate"
+	case CardTypeMedia:
+		return "Media"
+	case CardTypeCover, CardTypeVacuum:
+		return "Home"
 	default:
 		return "Other"
 	}
@@ -202,6 +352,10 @@ func detectCardType(entityID string) CardType {
 		return CardTypeFan
 	case "vacuum":
 		return CardTypeVacuum
+	case "media_player":
+		return CardTypeMedia
+	case "cover":
+		return CardTypeCover
 	default:
 		return CardTypeUnknown
 	}
@@ -299,6 +453,16 @@ func getIcon(cardType CardType, state string, attrs map[string]interface{}) stri
 			return "🧹"
 		}
 		return "🤖"
+	case CardTypeCover:
+		if state == "open" || state == "opening" {
+			return "🪟"
+		}
+		return "🟫"
+	case CardTypeMedia:
+		if dc, ok := attrs["device_class"].(string); ok && (dc == "speaker" || dc == "receiver") {
+			return "🔊"
+		}
+		return "📺"
 	default:
 		return "❓"
 	}
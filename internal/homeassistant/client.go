@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 )
 
@@ -111,6 +112,65 @@ func (c *Client) CallService(domain, service, entityID string) error {
 	return nil
 }
 
+// CallServiceData calls a Home Assistant service with an arbitrary service-data payload
+// and returns the entity states that changed as a result
+func (c *Client) CallServiceData(domain, service string, data map[string]interface{}) ([]*Entity, error) {
+	url := fmt.Sprintf("%s/api/services/%s/%s", c.baseURL, domain, service)
+
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode service data: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", url, io.NopCloser(
+		io.Reader(stringReader(body)),
+	))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("HA service call failed %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	changed := []*Entity{}
+	if err := json.NewDecoder(resp.Body).Decode(&changed); err != nil {
+		// Some services return no body; the call itself still succeeded
+		return []*Entity{}, nil
+	}
+	return changed, nil
+}
+
+// SetCoverPosition moves a cover to a position (0 = closed, 100 = open)
+func (c *Client) SetCoverPosition(entityID string, position int) error {
+	_, err := c.CallServiceData("cover", "set_cover_position", map[string]interface{}{
+		"entity_id": entityID,
+		"position":  position,
+	})
+	return err
+}
+
+// SetCoverTiltPosition sets a cover's slat tilt (0 = closed, 100 = open)
+func (c *Client) SetCoverTiltPosition(entityID string, tilt int) error {
+	_, err := c.CallServiceData("cover", "set_cover_tilt_position", map[string]interface{}{
+		"entity_id":     entityID,
+		"tilt_position": tilt,
+	})
+	return err
+}
+
 type stringReader string
 
 func (s stringReader) Read(p []byte) (n int, err error) {
@@ -233,3 +293,36 @@ func (c *Client) SetClimateFanMode(entityID string, fanMode string) error {
 
 	return nil
 }
+
+// GetEntityImage fetches the current picture of a camera or image entity (e.g. a
+// vacuum map) and returns it with its content type
+func (c *Client) GetEntityImage(entityID string) ([]byte, string, error) {
+	domain, _, _ := strings.Cut(entityID, ".")
+	if domain != "camera" && domain != "image" {
+		return nil, "", fmt.Errorf("not a camera or image entity: %s", entityID)
+	}
+	url := fmt.Sprintf("%s/api/%s_proxy/%s", c.baseURL, domain, entityID)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, "", fmt.Errorf("HA API error %d: %s", resp.StatusCode, string(body))
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, resp.Header.Get("Content-Type"), nil
+}
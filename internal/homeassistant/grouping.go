@@ -0,0 +1,244 @@
+package homeassistant
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"home_control/internal/store"
+)
+
+// Grouping schemes
+const (
+	GroupByDefault = "default" // Lights, Climate, Security, Media, ... (see GroupCards)
+	GroupByArea    = "area"    // Home Assistant areas
+	GroupByDomain  = "domain"  // Entity domain (light, switch, sensor, ...)
+	GroupByCustom  = "custom"  // Groups listed in Grouping.Groups
+)
+
+// otherGroup holds cards no group claims
+const otherGroup = "Other"
+
+// CustomGroup is a named group of entities for the custom scheme
+type CustomGroup struct {
+	Name     string   `json:"name"`
+	Icon     string   `json:"icon,omitempty"`
+	Entities []string `json:"entities"` // Entity IDs or globs like "light.kitchen_*", in display order
+}
+
+// Grouping configures how dashboard cards are grouped
+type Grouping struct {
+	Scheme string        `json:"scheme"`
+	Order  []string      `json:"order,omitempty"`  // Area/domain groups shown first, in this order; the rest follow by name
+	Groups []CustomGroup `json:"groups,omitempty"` // Custom scheme only
+}
+
+// domainGroups names and icons the common domains for the domain scheme
+var domainGroups = map[string]struct{ name, icon string }{
+	"light":         {"Lights", "💡"},
+	"switch":        {"Switches", "🔌"},
+	"climate":       {"Climate", "🌡️"},
+	"fan":           {"Fans", "🌀"},
+	"sensor":        {"Sensors", "📈"},
+	"binary_sensor": {"Binary Sensors", "🚪"},
+	"lock":          {"Locks", "🔒"},
+	"cover":         {"Covers", "🪟"},
+	"media_player":  {"Media", "📺"},
+	"vacuum":        {"Vacuums", "🧹"},
+	"person":        {"People", "🧑"},
+	"camera":        {"Cameras", "📷"},
+}
+
+// Validate checks the scheme and custom groups
+func (g Grouping) Validate() error {
+	switch g.Scheme {
+	case GroupByDefault, GroupByArea, GroupByDomain:
+	case GroupByCustom:
+		seen := make(map[string]bool)
+		for _, cg := range g.Groups {
+			if cg.Name == "" {
+				return fmt.Errorf("custom groups need a name")
+			}
+			if seen[cg.Name] {
+				return fmt.Errorf("duplicate group %q", cg.Name)
+			}
+			seen[cg.Name] = true
+			for _, pattern := range cg.Entities {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return fmt.Errorf("group %q: invalid pattern %q", cg.Name, pattern)
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("unknown grouping scheme %q", g.Scheme)
+	}
+	return nil
+}
+
+// GroupCardsBy organizes cards with a grouping scheme. areas maps entity IDs to
+// area names and is only used by the area scheme; cards without an area, and
+// cards no custom group claims, go to Other at the end.
+func GroupCardsBy(cards []*Card, g Grouping, areas map[string]string) []*CardGroup {
+	switch g.Scheme {
+	case GroupByArea:
+		return groupByKey(cards, g.Order, func(c *Card) (string, string) {
+			return areas[c.EntityID], "🏠"
+		})
+	case GroupByDomain:
+		return groupByKey(cards, g.Order, func(c *Card) (string, string) {
+			domain, _, _ := strings.Cut(c.EntityID, ".")
+			if dg, ok := domainGroups[domain]; ok {
+				return dg.name, dg.icon
+			}
+			return strings.Title(strings.ReplaceAll(domain, "_", " ")), "📦"
+		})
+	case GroupByCustom:
+		return groupCustom(cards, g.Groups)
+	}
+	return GroupCards(cards)
+}
+
+// groupByKey groups cards by the name key returns, ordering groups by order
+// and then by name
+func groupByKey(cards []*Card, order []string, key func(c *Card) (name, icon string)) []*CardGroup {
+	groups := make(map[string]*CardGroup)
+	for _, card := range cards {
+		name, icon := key(card)
+		if name == "" {
+			name, icon = otherGroup, "📦"
+		}
+		group, ok := groups[name]
+		if !ok {
+			group = &CardGroup{Name: name, Icon: icon}
+			groups[name] = group
+		}
+		group.Cards = append(group.Cards, card)
+	}
+
+	rank := make(map[string]int, len(order))
+	for i, name := range order {
+		rank[name] = i + 1
+	}
+	result := make([]*CardGroup, 0, len(groups))
+	for _, group := range groups {
+		result = append(result, group)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, b := result[i], result[j]
+		if (a.Name == otherGroup) != (b.Name == otherGroup) {
+			return b.Name == otherGroup
+		}
+		ra, rb := rank[a.Name], rank[b.Name]
+		if ra != rb {
+			if ra == 0 || rb == 0 {
+				return rb == 0
+			}
+			return ra < rb
+		}
+		return a.Name < b.Name
+	})
+	return result
+}
+
+// groupCustom puts each card in the first custom group with a matching entity,
+// ordered as the group lists them
+func groupCustom(cards []*Card, custom []CustomGroup) []*CardGroup {
+	claimed := make(map[*Card]bool)
+	var result []*CardGroup
+	for _, cg := range custom {
+		group := &CardGroup{Name: cg.Name, Icon: cg.Icon}
+		if group.Icon == "" {
+			group.Icon = "📦"
+		}
+		for _, pattern := range cg.Entities {
+			for _, card := range cards {
+				if ok, _ := path.Match(pattern, card.EntityID); ok && !claimed[card] {
+					claimed[card] = true
+					group.Cards = append(group.Cards, card)
+				}
+			}
+		}
+		if len(group.Cards) > 0 {
+			result = append(result, group)
+		}
+	}
+
+	other := &CardGroup{Name: otherGroup, Icon: "📦"}
+	for _, card := range cards {
+		if !claimed[card] {
+			other.Cards = append(other.Cards, card)
+		}
+	}
+	if len(other.Cards) > 0 {
+		result = append(result, other)
+	}
+	return result
+}
+
+// EntityAreas returns the area name of each entity that has one
+func (c *Client) EntityAreas(entityIDs []string) (map[string]string, error) {
+	ids, _ := json.Marshal(entityIDs)
+	out, err := c.RenderTemplate(fmt.Sprintf("{{ dict(zip(%s, %s | map('area_name') | list)) | tojson }}", ids, ids))
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]*string
+	if err := json.Unmarshal([]byte(out), &raw); err != nil {
+		return nil, fmt.Errorf("unexpected template result %q", out)
+	}
+	areas := make(map[string]string, len(raw))
+	for id, area := range raw {
+		if area != nil && *area != "" {
+			areas[id] = *area
+		}
+	}
+	return areas, nil
+}
+
+// GroupingStore persists the card grouping
+type GroupingStore struct {
+	mu       sync.RWMutex
+	doc      *store.Doc
+	grouping Grouping
+}
+
+// NewGroupingStore loads the card grouping, defaulting to the built-in groups
+func NewGroupingStore(doc *store.Doc) *GroupingStore {
+	s := &GroupingStore{doc: doc, grouping: Grouping{Scheme: GroupByDefault}}
+	if _, err := doc.Load(&s.grouping); err != nil {
+		log.Printf("Warning: Failed to load card grouping: %v", err)
+	}
+	if s.grouping.Scheme == "" {
+		s.grouping.Scheme = GroupByDefault
+	}
+	return s
+}
+
+// Get returns the current grouping
+func (s *GroupingStore) Get() Grouping {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.grouping
+}
+
+// Set validates and saves a grouping
+func (s *GroupingStore) Set(g Grouping) error {
+	if g.Scheme == "" {
+		g.Scheme = GroupByDefault
+	}
+	if err := g.Validate(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.doc.Save(g); err != nil {
+		return fmt.Errorf("failed to save card grouping: %w", err)
+	}
+	s.grouping = g
+	return nil
+}
@@ -0,0 +1,89 @@
+package homeassistant
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Activatable is a Home Assistant scene or script that can be triggered with one tap
+type Activatable struct {
+	EntityID string `json:"entityId"`
+	Name     string `json:"name"`
+	Icon     string `json:"icon,omitempty"`
+}
+
+// GetAllStates fetches every entity state known to Home Assistant
+func (c *Client) GetAllStates() ([]*Entity, error) {
+	url := fmt.Sprintf("%s/api/states", c.baseURL)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("HA API error %d: %s", resp.StatusCode, string(body))
+	}
+
+	var entities []*Entity
+	if err := json.NewDecoder(resp.Body).Decode(&entities); err != nil {
+		return nil, err
+	}
+	return entities, nil
+}
+
+// GetScenes returns all scenes defined in Home Assistant, sorted by name
+func (c *Client) GetScenes() ([]Activatable, error) {
+	return c.getActivatable("scene")
+}
+
+// GetScripts returns all scripts defined in Home Assistant, sorted by name
+func (c *Client) GetScripts() ([]Activatable, error) {
+	return c.getActivatable("script")
+}
+
+// ActivateScene turns on a scene
+func (c *Client) ActivateScene(entityID string) error {
+	if !strings.HasPrefix(entityID, "scene.") {
+		entityID = "scene." + entityID
+	}
+	return c.CallService("scene", "turn_on", entityID)
+}
+
+func (c *Client) getActivatable(domain string) ([]Activatable, error) {
+	entities, err := c.GetAllStates()
+	if err != nil {
+		return nil, err
+	}
+
+	result := []Activatable{}
+	for _, e := range entities {
+		if !strings.HasPrefix(e.EntityID, domain+".") {
+			continue
+		}
+		item := Activatable{EntityID: e.EntityID, Name: e.EntityID}
+		if name, ok := e.Attributes["friendly_name"].(string); ok && name != "" {
+			item.Name = name
+		}
+		if icon, ok := e.Attributes["icon"].(string); ok {
+			item.Icon = icon
+		}
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
@@ -0,0 +1,140 @@
+package homeassistant
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Selector prefixes accepted in entity lists alongside plain entity IDs
+const (
+	SelectorDomain = "domain:" // domain:light -> every light.* entity
+	SelectorArea   = "area:"   // area:kitchen -> every entity in the area (by area ID or name)
+	SelectorLabel  = "label:"  // label:dashboard -> every entity with the label
+)
+
+// IsSelector reports whether s is a domain:, area: or label: selector rather
+// than an entity ID
+func IsSelector(s string) bool {
+	return strings.HasPrefix(s, SelectorDomain) || strings.HasPrefix(s, SelectorArea) || strings.HasPrefix(s, SelectorLabel)
+}
+
+// ResolveEntities expands selectors into entity IDs, in order and without
+// duplicates. Plain entity IDs are kept as they are. Each exclusion is an
+// entity ID, a glob such as "sensor.*_battery", or a selector.
+func (c *Client) ResolveEntities(selectors, exclude []string) ([]string, error) {
+	var states []*Entity
+	expand := func(s string) ([]string, error) {
+		switch {
+		case strings.HasPrefix(s, SelectorDomain):
+			if states == nil {
+				var err error
+				if states, err = c.GetAllStates(); err != nil {
+					return nil, err
+				}
+			}
+			prefix := strings.TrimPrefix(s, SelectorDomain) + "."
+			var ids []string
+			for _, e := range states {
+				if strings.HasPrefix(e.EntityID, prefix) {
+					ids = append(ids, e.EntityID)
+				}
+			}
+			return ids, nil
+		case strings.HasPrefix(s, SelectorArea):
+			return c.templateEntities("area_entities", strings.TrimPrefix(s, SelectorArea))
+		case strings.HasPrefix(s, SelectorLabel):
+			return c.templateEntities("label_entities", strings.TrimPrefix(s, SelectorLabel))
+		}
+		return []string{s}, nil
+	}
+
+	excluded := make(map[string]bool)
+	var patterns []string
+	for _, x := range exclude {
+		if !IsSelector(x) {
+			patterns = append(patterns, x)
+			continue
+		}
+		ids, err := expand(x)
+		if err != nil {
+			return nil, fmt.Errorf("exclusion %s: %w", x, err)
+		}
+		for _, id := range ids {
+			excluded[id] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	var result []string
+	for _, s := range selectors {
+		ids, err := expand(s)
+		if err != nil {
+			return nil, fmt.Errorf("selector %s: %w", s, err)
+		}
+		for _, id := range ids {
+			if seen[id] || excluded[id] || matchesAny(patterns, id) {
+				continue
+			}
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result, nil
+}
+
+// templateEntities renders an entity-list template function such as
+// area_entities('kitchen'); the registries behind areas and labels aren't
+// exposed by the REST API otherwise
+func (c *Client) templateEntities(function, arg string) ([]string, error) {
+	quoted, _ := json.Marshal(arg)
+	out, err := c.RenderTemplate(fmt.Sprintf("{{ %s(%s) | tojson }}", function, quoted))
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	if err := json.Unmarshal([]byte(out), &ids); err != nil {
+		return nil, fmt.Errorf("unexpected template result %q", out)
+	}
+	return ids, nil
+}
+
+// RenderTemplate renders a Jinja template on the Home Assistant server
+func (c *Client) RenderTemplate(template string) (string, error) {
+	body, err := json.Marshal(map[string]string{"template": template})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", fmt.Sprintf("%s/api/template", c.baseURL), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	out, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("HA API error %d: %s", resp.StatusCode, string(out))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func matchesAny(patterns []string, id string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, id); ok || p == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,331 @@
+package hotwater
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// DefaultBoostMinutes is the boost length when none is given
+const DefaultBoostMinutes = 30
+
+// MaxBoostMinutes caps a boost
+const MaxBoostMinutes = 240
+
+// tickInterval is how often the remaining boost is reported
+const tickInterval = time.Minute
+
+// timeLayout is the format of Window.On and Window.Off
+const timeLayout = "15:04"
+
+// Window is a scheduled heating period, e.g. 06:00-07:30 on weekdays. Off
+// before On runs past midnight.
+type Window struct {
+	On   string         `json:"on"`             // HH:MM
+	Off  string         `json:"off"`            // HH:MM
+	Days []time.Weekday `json:"days,omitempty"` // Empty = every day
+}
+
+// Settings are the weekly schedule and any running boost
+type Settings struct {
+	Schedule   []Window   `json:"schedule"`
+	BoostUntil *time.Time `json:"boostUntil,omitempty"`
+}
+
+// Status is the hot water state as shown to clients
+type Status struct {
+	Entity         string     `json:"entity"`
+	Scheduled      bool       `json:"scheduled"` // Inside a schedule window
+	Boosting       bool       `json:"boosting"`
+	BoostUntil     *time.Time `json:"boostUntil,omitempty"`
+	BoostRemaining int        `json:"boostRemaining"` // Seconds
+	Schedule       []Window   `json:"schedule"`
+}
+
+// Run is a scheduled switch, registered with the scheduler
+type Run struct {
+	ID   string // hotwater:<n>:on|off
+	Name string
+	At   string
+	Days []time.Weekday
+	On   bool
+}
+
+// Manager runs the boiler's hot water on a weekly schedule with on-demand boosts
+type Manager struct {
+	entity   string
+	timezone *time.Location
+	control  func(on bool) error
+
+	mu       sync.RWMutex
+	doc      *store.Doc
+	settings Settings
+	timer    *time.Timer
+	onChange func(Status)
+}
+
+// NewManager loads the schedule. control switches the hot water entity.
+func NewManager(entity string, doc *store.Doc, timezone *time.Location, control func(on bool) error) *Manager {
+	if timezone == nil {
+		timezone = time.Local
+	}
+	m := &Manager{
+		entity:   entity,
+		timezone: timezone,
+		control:  control,
+		doc:      doc,
+		settings: Settings{Schedule: []Window{}},
+	}
+	if _, err := doc.Load(&m.settings); err != nil {
+		log.Printf("Warning: Failed to load hot water settings: %v", err)
+	}
+	return m
+}
+
+// OnChange registers a callback invoked when a boost starts or ends, every
+// minute while it runs, and when the schedule changes
+func (m *Manager) OnChange(fn func(Status)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// Run resumes a boost that was running at shutdown and reports the remaining
+// time while boosting. It blocks, so call it in a goroutine.
+func (m *Manager) Run() {
+	m.mu.Lock()
+	until := m.settings.BoostUntil
+	m.mu.Unlock()
+	if until != nil {
+		if remaining := time.Until(*until); remaining > 0 {
+			m.mu.Lock()
+			m.timer = time.AfterFunc(remaining, m.boostEnded)
+			m.mu.Unlock()
+		} else {
+			m.boostEnded()
+		}
+	}
+
+	ticker := time.NewTicker(tickInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if s := m.Status(); s.Boosting {
+			m.notify(s)
+		}
+	}
+}
+
+// Status returns the current state
+func (m *Manager) Status() Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.status(time.Now())
+}
+
+// Boost turns the hot water on for minutes, extending or shortening a boost
+// already running
+func (m *Manager) Boost(minutes int) (Status, error) {
+	if minutes == 0 {
+		minutes = DefaultBoostMinutes
+	}
+	if minutes < 1 || minutes > MaxBoostMinutes {
+		return Status{}, fmt.Errorf("minutes must be between 1 and %d", MaxBoostMinutes)
+	}
+	if err := m.control(true); err != nil {
+		return Status{}, fmt.Errorf("failed to turn on hot water: %w", err)
+	}
+
+	until := time.Now().Add(time.Duration(minutes) * time.Minute)
+	m.mu.Lock()
+	if m.timer != nil {
+		m.timer.Stop()
+	}
+	m.timer = time.AfterFunc(time.Until(until), m.boostEnded)
+	m.settings.BoostUntil = &until
+	err := m.save()
+	s := m.status(time.Now())
+	m.mu.Unlock()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	log.Printf("Hot water boost for %d minutes", minutes)
+	m.notify(s)
+	return s, nil
+}
+
+// CancelBoost ends a boost early
+func (m *Manager) CancelBoost() (Status, error) {
+	m.mu.Lock()
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+	boosting := m.settings.BoostUntil != nil
+	m.mu.Unlock()
+	if !boosting {
+		return m.Status(), nil
+	}
+	if err := m.endBoost(); err != nil {
+		return Status{}, err
+	}
+	return m.Status(), nil
+}
+
+// SetSchedule validates and replaces the weekly schedule
+func (m *Manager) SetSchedule(windows []Window) error {
+	if windows == nil {
+		windows = []Window{}
+	}
+	for i := range windows {
+		w := &windows[i]
+		for _, at := range []*string{&w.On, &w.Off} {
+			parsed, err := time.Parse(timeLayout, *at)
+			if err != nil {
+				return fmt.Errorf("invalid time (use HH:MM): %s", *at)
+			}
+			*at = parsed.Format(timeLayout)
+		}
+		if w.On == w.Off {
+			return fmt.Errorf("on and off times must differ")
+		}
+		for _, d := range w.Days {
+			if d < time.Sunday || d > time.Saturday {
+				return fmt.Errorf("invalid day %d", d)
+			}
+		}
+	}
+
+	m.mu.Lock()
+	m.settings.Schedule = windows
+	err := m.save()
+	s := m.status(time.Now())
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.notify(s)
+	return nil
+}
+
+// Runs returns the scheduled switches for every window
+func (m *Manager) Runs() []Run {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []Run
+	for i, w := range m.settings.Schedule {
+		prefix := "hotwater:" + strconv.Itoa(i)
+		// The off time of a window past midnight falls on the next day
+		offDays := w.Days
+		if w.Off < w.On && len(w.Days) > 0 {
+			offDays = make([]time.Weekday, len(w.Days))
+			for j, d := range w.Days {
+				offDays[j] = (d + 1) % 7
+			}
+		}
+		result = append(result,
+			Run{ID: prefix + ":on", Name: "Hot water on", At: w.On, Days: w.Days, On: true},
+			Run{ID: prefix + ":off", Name: "Hot water off", At: w.Off, Days: offDays, On: false})
+	}
+	return result
+}
+
+// Scheduled switches the hot water for a schedule window. A window ending
+// doesn't cut a boost short.
+func (m *Manager) Scheduled(on bool) error {
+	if !on && m.Status().Boosting {
+		return nil
+	}
+	if err := m.control(on); err != nil {
+		return err
+	}
+	m.notify(m.Status())
+	return nil
+}
+
+// boostEnded runs when a boost's time is up
+func (m *Manager) boostEnded() {
+	if err := m.endBoost(); err != nil {
+		log.Printf("Error ending hot water boost: %v", err)
+	}
+}
+
+// endBoost clears the boost and turns the hot water off unless the schedule
+// keeps it on
+func (m *Manager) endBoost() error {
+	m.mu.Lock()
+	m.settings.BoostUntil = nil
+	m.timer = nil
+	err := m.save()
+	s := m.status(time.Now())
+	m.mu.Unlock()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	if !s.Scheduled {
+		if err := m.control(false); err != nil {
+			return fmt.Errorf("failed to turn off hot water: %w", err)
+		}
+	}
+	log.Printf("Hot water boost ended")
+	m.notify(s)
+	return nil
+}
+
+// status builds the current state (caller must hold the lock)
+func (m *Manager) status(now time.Time) Status {
+	s := Status{
+		Entity:   m.entity,
+		Schedule: m.settings.Schedule,
+	}
+	if until := m.settings.BoostUntil; until != nil && until.After(now) {
+		s.Boosting = true
+		s.BoostUntil = until
+		s.BoostRemaining = int(until.Sub(now).Seconds())
+	}
+	local := now.In(m.timezone)
+	for _, w := range m.settings.Schedule {
+		if inWindow(w, local) {
+			s.Scheduled = true
+			break
+		}
+	}
+	return s
+}
+
+// inWindow reports whether t falls inside a window on one of its days
+func inWindow(w Window, t time.Time) bool {
+	now := t.Format(timeLayout)
+	day := t.Weekday()
+	onDay := func(d time.Weekday) bool { return len(w.Days) == 0 || slices.Contains(w.Days, d) }
+	if w.On < w.Off {
+		return onDay(day) && now >= w.On && now < w.Off
+	}
+	// Past midnight: the evening part belongs to today, the morning part to yesterday
+	return (onDay(day) && now >= w.On) || (onDay((day+6)%7) && now < w.Off)
+}
+
+// save persists settings (caller must hold the lock)
+func (m *Manager) save() error {
+	if err := m.doc.Save(m.settings); err != nil {
+		return fmt.Errorf("failed to save hot water settings: %w", err)
+	}
+	return nil
+}
+
+func (m *Manager) notify(s Status) {
+	m.mu.RLock()
+	fn := m.onChange
+	m.mu.RUnlock()
+	if fn != nil {
+		fn(s)
+	}
+}
@@ -0,0 +1,227 @@
+package housemode
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Modes
+const (
+	Home     = "home"
+	Away     = "away"
+	Night    = "night"
+	Vacation = "vacation"
+)
+
+// Modes lists every mode
+var Modes = []string{Home, Away, Night, Vacation}
+
+// Transition sources
+const (
+	SourceManual   = "manual"
+	SourcePresence = "presence"
+	SourceSchedule = "schedule"
+)
+
+// Rules configure the automatic transitions
+type Rules struct {
+	Presence   bool   `json:"presence"`   // Away when everyone leaves, home when someone arrives
+	NightStart string `json:"nightStart"` // HH:MM home -> night (empty = no automatic night)
+	NightEnd   string `json:"nightEnd"`   // HH:MM night -> home
+}
+
+// State is the current mode and how it was reached
+type State struct {
+	Mode     string    `json:"mode"`
+	Previous string    `json:"previous,omitempty"`
+	Source   string    `json:"source"`
+	Since    time.Time `json:"since"`
+}
+
+// saved is the persisted document
+type saved struct {
+	State State `json:"state"`
+	Rules Rules `json:"rules"`
+}
+
+// Manager holds the house mode and applies the transition rules:
+//
+//   - manual: any mode, any time
+//   - presence: home/night -> away when everyone leaves; away/vacation -> home
+//     (or night, inside the night window) when someone arrives. Vacation is
+//     only ever entered manually and is kept while nobody is home.
+//   - schedule: home -> night at NightStart, night -> home at NightEnd
+type Manager struct {
+	timezone *time.Location
+
+	mu       sync.RWMutex
+	doc      *store.Doc
+	state    State
+	rules    Rules
+	onChange func(s State)
+}
+
+// NewManager loads the mode and rules from the store
+func NewManager(doc *store.Doc, timezone *time.Location) *Manager {
+	if timezone == nil {
+		timezone = time.Local
+	}
+	m := &Manager{
+		timezone: timezone,
+		doc:      doc,
+		state:    State{Mode: Home, Source: SourceManual, Since: time.Now()},
+		rules:    Rules{Presence: true, NightStart: "22:30", NightEnd: "06:30"},
+	}
+	data := saved{State: m.state, Rules: m.rules}
+	if _, err := doc.Load(&data); err != nil {
+		log.Printf("Warning: Failed to load house mode: %v", err)
+	} else if Valid(data.State.Mode) {
+		m.state, m.rules = data.State, data.Rules
+	}
+	return m
+}
+
+// Valid reports whether mode is a known mode
+func Valid(mode string) bool {
+	return slices.Contains(Modes, mode)
+}
+
+// OnChange registers a callback invoked after the mode changes
+func (m *Manager) OnChange(fn func(s State)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// Current returns the current state
+func (m *Manager) Current() State {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.state
+}
+
+// Mode returns the current mode
+func (m *Manager) Mode() string {
+	return m.Current().Mode
+}
+
+// Is reports whether the current mode is one of modes. No modes matches any mode,
+// so an empty condition list means "always".
+func (m *Manager) Is(modes []string) bool {
+	return len(modes) == 0 || slices.Contains(modes, m.Mode())
+}
+
+// Rules returns the transition rules
+func (m *Manager) Rules() Rules {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.rules
+}
+
+// SetRules validates and saves the transition rules
+func (m *Manager) SetRules(rules Rules) error {
+	for _, t := range []string{rules.NightStart, rules.NightEnd} {
+		if t == "" {
+			continue
+		}
+		if _, err := time.Parse("15:04", t); err != nil || len(t) != 5 {
+			return fmt.Errorf("night times must be HH:MM")
+		}
+	}
+	if (rules.NightStart == "") != (rules.NightEnd == "") {
+		return fmt.Errorf("set both nightStart and nightEnd, or neither")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rules = rules
+	return m.save()
+}
+
+// Set changes the mode
+func (m *Manager) Set(mode, source string) error {
+	if !Valid(mode) {
+		return fmt.Errorf("unknown mode %q", mode)
+	}
+
+	m.mu.Lock()
+	if m.state.Mode == mode {
+		m.mu.Unlock()
+		return nil
+	}
+	m.state = State{Mode: mode, Previous: m.state.Mode, Source: source, Since: time.Now()}
+	err := m.save()
+	s, fn := m.state, m.onChange
+	m.mu.Unlock()
+
+	if fn != nil {
+		fn(s)
+	}
+	return err
+}
+
+// PresenceChanged applies the presence rule after someone arrives or leaves
+func (m *Manager) PresenceChanged(anyoneHome bool) {
+	rules, mode := m.Rules(), m.Mode()
+	if !rules.Presence {
+		return
+	}
+
+	switch {
+	case !anyoneHome && (mode == Home || mode == Night):
+		m.apply(Away, SourcePresence)
+	case anyoneHome && (mode == Away || mode == Vacation):
+		next := Home
+		if m.inNight(time.Now()) {
+			next = Night
+		}
+		m.apply(next, SourcePresence)
+	}
+}
+
+// NightStarted applies the schedule rule at NightStart
+func (m *Manager) NightStarted() {
+	if m.Mode() == Home {
+		m.apply(Night, SourceSchedule)
+	}
+}
+
+// NightEnded applies the schedule rule at NightEnd
+func (m *Manager) NightEnded() {
+	if m.Mode() == Night {
+		m.apply(Home, SourceSchedule)
+	}
+}
+
+// apply sets a mode from an automatic rule, logging failures
+func (m *Manager) apply(mode, source string) {
+	if err := m.Set(mode, source); err != nil {
+		log.Printf("Warning: Failed to set house mode %s: %v", mode, err)
+	}
+}
+
+// inNight reports whether t falls between NightStart and NightEnd
+func (m *Manager) inNight(t time.Time) bool {
+	rules := m.Rules()
+	if rules.NightStart == "" {
+		return false
+	}
+	now := t.In(m.timezone).Format("15:04")
+	if rules.NightStart <= rules.NightEnd {
+		return now >= rules.NightStart && now < rules.NightEnd
+	}
+	return now >= rules.NightStart || now < rules.NightEnd
+}
+
+// save persists the state and rules (caller must hold the lock)
+func (m *Manager) save() error {
+	if err := m.doc.Save(saved{State: m.state, Rules: m.rules}); err != nil {
+		return fmt.Errorf("failed to save house mode: %w", err)
+	}
+	return nil
+}
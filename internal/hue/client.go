@@ -101,6 +101,9 @@ type Room struct {
 	Lights          []*Light `json:"lights"`
 	Scenes          []*Scene `json:"scenes"`
 	StreamingActive bool     `json:"streamingActive,omitempty"`
+	CTMin           int      `json:"ctMin,omitempty"` // Warmth range across the room's white-ambiance lights (mireds)
+	CTMax           int      `json:"ctMax,omitempty"`
+	CT              int      `json:"ct,omitempty"` // Average color temperature of lights that are on
 }
 
 // NewClient creates a new Hue Bridge client
@@ -249,12 +252,26 @@ func (c *Client) SetLightBrightness(id string, brightness int) error {
 	})
 }
 
-// SetLightColor sets the color of a light using hue and saturation
+// SetLightColor sets the color of a light from hue (0-65535) and saturation (0-254),
+// converted to xy within the light's gamut
 func (c *Client) SetLightColor(id string, hue, saturation int) error {
+	return c.SetLightXY(id, HSToXY(float64(hue)*360/65536, float64(saturation)/254))
+}
+
+// SetLightXY sets the color of a light, clamping xy to the light's gamut
+func (c *Client) SetLightXY(id string, xy XY) error {
+	light, err := c.GetLight(id)
+	if err != nil {
+		return err
+	}
+	gamutType := light.Capabilities.Control.ColorGamutType
+	if gamutType == "" {
+		return fmt.Errorf("light %s does not support color", id)
+	}
+	p := GamutFor(gamutType).Clamp(xy)
 	return c.SetLightState(id, map[string]interface{}{
-		"on":  true,
-		"hue": hue,
-		"sat": saturation,
+		"on": true,
+		"xy": []float64{p.X, p.Y},
 	})
 }
 
@@ -377,6 +394,69 @@ func (c *Client) SetGroupBrightness(id string, brightness int) error {
 	})
 }
 
+// SetGroupColorTemp sets the color temperature for all lights in a group (153-500 mireds).
+// The bridge clamps the value to each light's own range.
+func (c *Client) SetGroupColorTemp(id string, ct int) error {
+	if ct < 153 {
+		ct = 153
+	}
+	if ct > 500 {
+		ct = 500
+	}
+	return c.SetGroupState(id, map[string]interface{}{
+		"on": true,
+		"ct": ct,
+	})
+}
+
+// SetGroupXY sets the color of every color light in a group. Each light is set
+// individually so xy is clamped to its own gamut; white-only lights are skipped.
+func (c *Client) SetGroupXY(id string, xy XY) error {
+	groups, err := c.GetGroups()
+	if err != nil {
+		return err
+	}
+	var group *Group
+	for _, g := range groups {
+		if g.ID == id {
+			group = g
+			break
+		}
+	}
+	if group == nil {
+		return fmt.Errorf("group %s not found", id)
+	}
+
+	lights, err := c.GetLights()
+	if err != nil {
+		return err
+	}
+	byID := make(map[string]*Light, len(lights))
+	for _, l := range lights {
+		byID[l.ID] = l
+	}
+
+	colored := 0
+	for _, lightID := range group.Lights {
+		light, ok := byID[lightID]
+		if !ok || light.Capabilities.Control.ColorGamutType == "" {
+			continue
+		}
+		colored++
+		p := GamutFor(light.Capabilities.Control.ColorGamutType).Clamp(xy)
+		if err := c.SetLightState(lightID, map[string]interface{}{
+			"on": true,
+			"xy": []float64{p.X, p.Y},
+		}); err != nil {
+			return fmt.Errorf("failed to set %s: %w", light.Name, err)
+		}
+	}
+	if colored == 0 {
+		return fmt.Errorf("group %s has no color lights", id)
+	}
+	return nil
+}
+
 // GetScenes returns all scenes from the bridge
 func (c *Client) GetScenes() ([]*Scene, error) {
 	body, err := c.get("/scenes")
@@ -474,6 +554,31 @@ func (c *Client) ActivateEntertainmentArea(groupID string) error {
 	})
 }
 
+// updateWarmth sets the warmth range and current color temperature from the room's lights
+func (r *Room) updateWarmth() {
+	r.CTMin, r.CTMax, r.CT = 0, 0, 0
+	ctSum, ctCount := 0, 0
+	for _, light := range r.Lights {
+		ct := light.Capabilities.Control.CT
+		if ct.Max == 0 {
+			continue
+		}
+		if r.CTMin == 0 || ct.Min < r.CTMin {
+			r.CTMin = ct.Min
+		}
+		if ct.Max > r.CTMax {
+			r.CTMax = ct.Max
+		}
+		if light.State.On && light.State.CT > 0 {
+			ctSum += light.State.CT
+			ctCount++
+		}
+	}
+	if ctCount > 0 {
+		r.CT = ctSum / ctCount
+	}
+}
+
 // GetRoomsWithDetails returns rooms with their lights and scenes populated
 func (c *Client) GetRoomsWithDetails() ([]*Room, error) {
 	groups, err := c.GetGroups()
@@ -525,6 +630,7 @@ func (c *Client) GetRoomsWithDetails() ([]*Room, error) {
 				room.Lights = append(room.Lights, light)
 			}
 		}
+		room.updateWarmth()
 
 		// Add scenes for this room
 		for _, s := range scenes {
@@ -0,0 +1,163 @@
+package hue
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// XY is a point in the CIE 1931 color space
+type XY struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Gamut is the triangle of colors a light can reproduce
+type Gamut struct {
+	Red   XY
+	Green XY
+	Blue  XY
+}
+
+// Gamuts by the colorgamuttype reported in a light's capabilities
+var (
+	GamutA = Gamut{Red: XY{0.704, 0.296}, Green: XY{0.2151, 0.7106}, Blue: XY{0.138, 0.08}} // LivingColors, Bloom, Aura
+	GamutB = Gamut{Red: XY{0.675, 0.322}, Green: XY{0.409, 0.518}, Blue: XY{0.167, 0.04}}   // Older Hue bulbs
+	GamutC = Gamut{Red: XY{0.6915, 0.3038}, Green: XY{0.17, 0.7}, Blue: XY{0.1532, 0.0475}} // Newer Hue bulbs, Lightstrip Plus
+)
+
+// whitePoint is D65, used for black where no chromaticity exists
+var whitePoint = XY{0.3127, 0.329}
+
+// GamutFor returns the gamut for a colorgamuttype ("A", "B", "C"). Unknown
+// types (e.g. "other" on third-party lights) get gamut C.
+func GamutFor(gamutType string) Gamut {
+	switch strings.ToUpper(gamutType) {
+	case "A":
+		return GamutA
+	case "B":
+		return GamutB
+	default:
+		return GamutC
+	}
+}
+
+// Contains reports whether p lies inside the gamut triangle
+func (g Gamut) Contains(p XY) bool {
+	d1 := cross(p, g.Red, g.Green)
+	d2 := cross(p, g.Green, g.Blue)
+	d3 := cross(p, g.Blue, g.Red)
+	hasNeg := d1 < 0 || d2 < 0 || d3 < 0
+	hasPos := d1 > 0 || d2 > 0 || d3 > 0
+	return !(hasNeg && hasPos)
+}
+
+// Clamp moves p to the closest reproducible color when it's outside the gamut
+func (g Gamut) Clamp(p XY) XY {
+	if g.Contains(p) {
+		return p
+	}
+	best := closestOnLine(g.Red, g.Green, p)
+	bestDist := distance(p, best)
+	for _, edge := range [][2]XY{{g.Green, g.Blue}, {g.Blue, g.Red}} {
+		q := closestOnLine(edge[0], edge[1], p)
+		if d := distance(p, q); d < bestDist {
+			best, bestDist = q, d
+		}
+	}
+	return best
+}
+
+// RGBToXY converts an sRGB color to CIE xy (wide gamut D65 conversion)
+func RGBToXY(r, g, b uint8) XY {
+	rl, gl, bl := linearize(r), linearize(g), linearize(b)
+	x := rl*0.664511 + gl*0.154324 + bl*0.162028
+	y := rl*0.283881 + gl*0.668433 + bl*0.047685
+	z := rl*0.000088 + gl*0.072310 + bl*0.986039
+	sum := x + y + z
+	if sum == 0 {
+		return whitePoint
+	}
+	return XY{X: round4(x / sum), Y: round4(y / sum)}
+}
+
+// HSToXY converts hue (0-360 degrees) and saturation (0-1) at full value to CIE xy
+func HSToXY(hue, saturation float64) XY {
+	r, g, b := hsvToRGB(hue, saturation, 1)
+	return RGBToXY(r, g, b)
+}
+
+// ParseHexColor parses "#rrggbb", "rrggbb" or "#rgb"
+func ParseHexColor(s string) (r, g, b uint8, err error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "#")
+	if len(s) == 3 {
+		s = string([]byte{s[0], s[0], s[1], s[1], s[2], s[2]})
+	}
+	if len(s) != 6 {
+		return 0, 0, 0, fmt.Errorf("invalid hex color: %q", s)
+	}
+	v, err := strconv.ParseUint(s, 16, 32)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid hex color: %q", s)
+	}
+	return uint8(v >> 16), uint8(v >> 8), uint8(v), nil
+}
+
+// linearize applies the inverse sRGB gamma to a channel
+func linearize(c uint8) float64 {
+	v := float64(c) / 255
+	if v > 0.04045 {
+		return math.Pow((v+0.055)/1.055, 2.4)
+	}
+	return v / 12.92
+}
+
+func hsvToRGB(h, s, v float64) (uint8, uint8, uint8) {
+	h = math.Mod(h, 360)
+	if h < 0 {
+		h += 360
+	}
+	s = math.Max(0, math.Min(1, s))
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	return uint8(math.Round((r + m) * 255)), uint8(math.Round((g + m) * 255)), uint8(math.Round((b + m) * 255))
+}
+
+// cross is the z component of (a-p) x (b-p); its sign tells which side of ab p is on
+func cross(p, a, b XY) float64 {
+	return (a.X-p.X)*(b.Y-p.Y) - (b.X-p.X)*(a.Y-p.Y)
+}
+
+// closestOnLine returns the point on segment ab closest to p
+func closestOnLine(a, b, p XY) XY {
+	ab := XY{b.X - a.X, b.Y - a.Y}
+	t := ((p.X-a.X)*ab.X + (p.Y-a.Y)*ab.Y) / (ab.X*ab.X + ab.Y*ab.Y)
+	t = math.Max(0, math.Min(1, t))
+	return XY{X: round4(a.X + t*ab.X), Y: round4(a.Y + t*ab.Y)}
+}
+
+func distance(a, b XY) float64 {
+	return math.Hypot(a.X-b.X, a.Y-b.Y)
+}
+
+// round4 rounds to the bridge's xy precision
+func round4(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
@@ -0,0 +1,429 @@
+package hue
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fullRefreshInterval re-reads everything over REST as a safety net for missed events
+const fullRefreshInterval = 10 * time.Minute
+
+// EventStream keeps an in-memory copy of rooms, lights and scenes up to date from
+// the bridge's CLIP v2 event stream, so reading room state needs no REST calls.
+type EventStream struct {
+	client     *Client
+	httpClient *http.Client // No timeout: the stream stays open
+	refresh    chan struct{}
+
+	mu        sync.RWMutex
+	rooms     []*Room
+	lights    map[string]*Light // V1 light ID -> light shared by every room containing it
+	connected bool
+	onChange  func(rooms []*Room)
+	onSensor  func(e SensorEvent)
+}
+
+// streamEvent is one entry of an event stream message
+type streamEvent struct {
+	Type string           `json:"type"` // add, update, delete, error
+	Data []streamResource `json:"data"`
+}
+
+// streamResource is a changed CLIP v2 resource; IDV1 links it to the V1 API ("/lights/3")
+type streamResource struct {
+	Type string `json:"type"`
+	IDV1 string `json:"id_v1"`
+	On   *struct {
+		On bool `json:"on"`
+	} `json:"on"`
+	Dimming *struct {
+		Brightness float64 `json:"brightness"` // Percent
+	} `json:"dimming"`
+	ColorTemperature *struct {
+		Mirek *int `json:"mirek"`
+	} `json:"color_temperature"`
+	Color *struct {
+		XY struct {
+			X float64 `json:"x"`
+			Y float64 `json:"y"`
+		} `json:"xy"`
+	} `json:"color"`
+	Status string `json:"status"` // Entertainment configuration: active/inactive
+
+	// Sensor readings; newer firmware reports them in the *_report objects
+	Motion *struct {
+		Motion *bool `json:"motion"`
+		Report *struct {
+			Motion bool `json:"motion"`
+		} `json:"motion_report"`
+	} `json:"motion"`
+	Temperature *struct {
+		Temperature *float64 `json:"temperature"`
+		Report      *struct {
+			Temperature float64 `json:"temperature"`
+		} `json:"temperature_report"`
+	} `json:"temperature"`
+	Light  *json.RawMessage `json:"light"`
+	Button *json.RawMessage `json:"button"`
+}
+
+// SensorEvent is a sensor update from the event stream. Motion and temperature
+// carry their reading; light level and button updates only say the sensor
+// changed, since the stream lacks the dark threshold and button number.
+type SensorEvent struct {
+	ID          string // V1 sensor ID
+	Kind        string
+	Motion      *bool
+	Temperature *float64 // °C
+}
+
+// NewEventStream creates an event stream for the client's bridge
+func NewEventStream(client *Client) *EventStream {
+	return &EventStream{
+		client: client,
+		httpClient: &http.Client{
+			Transport: client.httpClient.Transport,
+		},
+		refresh: make(chan struct{}, 1),
+		lights:  make(map[string]*Light),
+	}
+}
+
+// OnChange registers a callback invoked with a snapshot of all rooms after each change
+func (s *EventStream) OnChange(fn func(rooms []*Room)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = fn
+}
+
+// OnSensor registers a callback invoked for each motion, temperature, light
+// level and button update
+func (s *EventStream) OnSensor(fn func(e SensorEvent)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onSensor = fn
+}
+
+// Connected reports whether the stream is connected and its cache loaded
+func (s *EventStream) Connected() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.connected
+}
+
+// Rooms returns a snapshot of the cached rooms. ok is false until the cache is
+// loaded and while the stream is disconnected, when callers should use REST.
+func (s *EventStream) Rooms() ([]*Room, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if !s.connected || s.rooms == nil {
+		return nil, false
+	}
+	return s.snapshot(), true
+}
+
+// Refresh reloads the cache over REST
+func (s *EventStream) Refresh() error {
+	rooms, err := s.client.GetRoomsWithDetails()
+	if err != nil {
+		return err
+	}
+	lights := make(map[string]*Light)
+	for _, r := range rooms {
+		for _, l := range r.Lights {
+			lights[l.ID] = l
+		}
+	}
+
+	s.mu.Lock()
+	s.rooms = rooms
+	s.lights = lights
+	s.mu.Unlock()
+	s.notify()
+	return nil
+}
+
+// Run keeps the stream connected, reconnecting with backoff. It blocks, so call it in a goroutine.
+func (s *EventStream) Run() {
+	go s.refreshLoop()
+
+	backoff := time.Second
+	for {
+		start := time.Now()
+		err := s.stream()
+
+		s.mu.Lock()
+		s.connected = false
+		s.mu.Unlock()
+
+		if time.Since(start) > time.Minute {
+			backoff = time.Second
+		}
+		log.Printf("Hue event stream disconnected: %v (reconnecting in %v)", err, backoff)
+		time.Sleep(backoff)
+		if backoff < time.Minute {
+			backoff *= 2
+		}
+	}
+}
+
+// refreshLoop performs requested and periodic full refreshes
+func (s *EventStream) refreshLoop() {
+	ticker := time.NewTicker(fullRefreshInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.refresh:
+			time.Sleep(2 * time.Second) // Let related add/delete events settle
+		case <-ticker.C:
+		}
+		if err := s.Refresh(); err != nil {
+			log.Printf("Warning: Failed to refresh Hue rooms: %v", err)
+		}
+	}
+}
+
+// requestRefresh schedules a full refresh (rooms, scenes or lights were added or removed)
+func (s *EventStream) requestRefresh() {
+	select {
+	case s.refresh <- struct{}{}:
+	default:
+	}
+}
+
+// stream reads the event stream until it fails
+func (s *EventStream) stream() error {
+	req, err := http.NewRequest("GET", fmt.Sprintf("https://%s/eventstream/clip/v2", s.client.bridgeIP), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("hue-application-key", s.client.username)
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("event stream returned status %d", resp.StatusCode)
+	}
+
+	// Load state after connecting so no change falls between the snapshot and the stream
+	if err := s.Refresh(); err != nil {
+		return fmt.Errorf("initial load failed: %w", err)
+	}
+	s.mu.Lock()
+	s.connected = true
+	s.mu.Unlock()
+	log.Printf("Hue event stream connected")
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	var data bytes.Buffer
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		case line == "":
+			if data.Len() > 0 {
+				s.handleMessage(data.Bytes())
+				data.Reset()
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return fmt.Errorf("stream closed")
+}
+
+// handleMessage applies a batch of events to the cache
+func (s *EventStream) handleMessage(payload []byte) {
+	var events []streamEvent
+	if err := json.Unmarshal(payload, &events); err != nil {
+		log.Printf("Warning: Failed to parse Hue event: %v", err)
+		return
+	}
+
+	changed := false
+	var sensors []SensorEvent
+	s.mu.Lock()
+	for _, ev := range events {
+		if ev.Type == "add" || ev.Type == "delete" {
+			s.requestRefresh()
+			continue
+		}
+		if ev.Type != "update" {
+			continue
+		}
+		for _, res := range ev.Data {
+			if e, ok := res.sensorEvent(); ok {
+				sensors = append(sensors, e)
+				continue
+			}
+			if s.apply(res) {
+				changed = true
+			}
+		}
+	}
+	onSensor := s.onSensor
+	s.mu.Unlock()
+
+	if changed {
+		s.notify()
+	}
+	if onSensor != nil {
+		for _, e := range sensors {
+			onSensor(e)
+		}
+	}
+}
+
+// sensorEvent converts a motion, temperature, light level or button resource
+func (res streamResource) sensorEvent() (SensorEvent, bool) {
+	id, ok := strings.CutPrefix(res.IDV1, "/sensors/")
+	if !ok {
+		return SensorEvent{}, false
+	}
+	e := SensorEvent{ID: id}
+	switch {
+	case res.Type == "motion" && res.Motion != nil:
+		e.Kind = SensorMotion
+		e.Motion = res.Motion.Motion
+		if res.Motion.Report != nil {
+			e.Motion = &res.Motion.Report.Motion
+		}
+	case res.Type == "temperature" && res.Temperature != nil:
+		e.Kind = SensorTemperature
+		e.Temperature = res.Temperature.Temperature
+		if res.Temperature.Report != nil {
+			e.Temperature = &res.Temperature.Report.Temperature
+		}
+	case res.Type == "light_level" && res.Light != nil:
+		e.Kind = SensorLightLevel
+	case res.Type == "button" && res.Button != nil:
+		e.Kind = SensorButton
+	default:
+		return SensorEvent{}, false
+	}
+	return e, true
+}
+
+// apply updates the cache from one resource (caller must hold the lock)
+func (s *EventStream) apply(res streamResource) bool {
+	switch {
+	case res.Type == "light" && strings.HasPrefix(res.IDV1, "/lights/"):
+		light, ok := s.lights[strings.TrimPrefix(res.IDV1, "/lights/")]
+		if !ok {
+			return false
+		}
+		if res.On != nil {
+			light.State.On = res.On.On
+		}
+		if res.Dimming != nil {
+			light.State.Brightness = int(math.Max(1, math.Round(res.Dimming.Brightness*254/100)))
+		}
+		if res.ColorTemperature != nil && res.ColorTemperature.Mirek != nil {
+			light.State.CT = *res.ColorTemperature.Mirek
+			light.State.ColorMode = "ct"
+		}
+		if res.Color != nil {
+			light.State.XY = []float64{res.Color.XY.X, res.Color.XY.Y}
+			light.State.ColorMode = "xy"
+		}
+		for _, r := range s.rooms {
+			if r.hasLight(light.ID) {
+				r.IsOn = r.anyLightOn()
+				r.updateWarmth()
+			}
+		}
+		return true
+
+	case res.Type == "grouped_light" && res.On != nil:
+		for _, r := range s.rooms {
+			if "/groups/"+r.ID == res.IDV1 {
+				r.IsOn = res.On.On
+				return true
+			}
+		}
+
+	case res.Type == "entertainment_configuration" && res.Status != "":
+		for _, r := range s.rooms {
+			if "/groups/"+r.ID == res.IDV1 {
+				r.StreamingActive = res.Status == "active"
+				return true
+			}
+		}
+
+	case res.Type == "scene", res.Type == "room", res.Type == "zone":
+		// Renames and membership changes: cheaper to reload than to patch
+		s.requestRefresh()
+	}
+	return false
+}
+
+// notify hands a snapshot to the change callback
+func (s *EventStream) notify() {
+	s.mu.RLock()
+	fn := s.onChange
+	var rooms []*Room
+	if fn != nil {
+		rooms = s.snapshot()
+	}
+	s.mu.RUnlock()
+
+	if fn != nil {
+		fn(rooms)
+	}
+}
+
+// snapshot deep-copies rooms and lights so callers can encode them while events
+// keep arriving (caller must hold the lock). Scenes are replaced, never modified,
+// so they are shared.
+func (s *EventStream) snapshot() []*Room {
+	lights := make(map[string]*Light, len(s.lights))
+	rooms := make([]*Room, 0, len(s.rooms))
+	for _, r := range s.rooms {
+		room := *r
+		room.Lights = make([]*Light, 0, len(r.Lights))
+		for _, l := range r.Lights {
+			c, ok := lights[l.ID]
+			if !ok {
+				copied := *l
+				c = &copied
+				lights[l.ID] = c
+			}
+			room.Lights = append(room.Lights, c)
+		}
+		rooms = append(rooms, &room)
+	}
+	return rooms
+}
+
+func (r *Room) hasLight(id string) bool {
+	for _, l := range r.Lights {
+		if l.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Room) anyLightOn() bool {
+	for _, l := range r.Lights {
+		if l.State.On {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,359 @@
+package hue
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sensor kinds
+const (
+	SensorMotion      = "motion"
+	SensorTemperature = "temperature"
+	SensorLightLevel  = "light_level"
+	SensorButton      = "button" // Dimmer switch, smart button, Tap
+)
+
+// sensorKinds maps V1 sensor types to kinds; other types (daylight, CLIP) are ignored
+var sensorKinds = map[string]string{
+	"ZLLPresence":    SensorMotion,
+	"ZLLTemperature": SensorTemperature,
+	"ZLLLightLevel":  SensorLightLevel,
+	"ZLLSwitch":      SensorButton,
+	"ZGPSwitch":      SensorButton,
+}
+
+// Sensor is a parsed bridge sensor. Only the fields of its kind are set.
+type Sensor struct {
+	ID          string    `json:"id"`
+	EntityID    string    `json:"entityId"` // hue.<name>, used in sensor history and the journal
+	Name        string    `json:"name"`
+	Kind        string    `json:"kind"`
+	ModelID     string    `json:"modelId,omitempty"`
+	UniqueID    string    `json:"uniqueId,omitempty"` // Shared by the sensors of one physical device
+	Motion      *bool     `json:"motion,omitempty"`
+	Temperature *float64  `json:"temperature,omitempty"` // °C
+	Lux         *float64  `json:"lux,omitempty"`
+	Dark        *bool     `json:"dark,omitempty"`
+	ButtonEvent *int      `json:"buttonEvent,omitempty"` // e.g. 1002 = button 1 short release
+	Battery     *int      `json:"battery,omitempty"`     // Percent
+	Reachable   bool      `json:"reachable"`
+	LastUpdated time.Time `json:"lastUpdated,omitempty"`
+}
+
+// v1Sensor is a sensor as returned by the V1 /sensors endpoint
+type v1Sensor struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	ModelID  string `json:"modelid"`
+	UniqueID string `json:"uniqueid"`
+	State    struct {
+		Presence    *bool  `json:"presence"`
+		Temperature *int   `json:"temperature"` // 0.01 °C
+		LightLevel  *int   `json:"lightlevel"`  // 10000*log10(lux)+1
+		Dark        *bool  `json:"dark"`
+		ButtonEvent *int   `json:"buttonevent"`
+		LastUpdated string `json:"lastupdated"` // UTC, "none" before the first report
+	} `json:"state"`
+	Config struct {
+		Battery   *int `json:"battery"`
+		Reachable bool `json:"reachable"`
+	} `json:"config"`
+}
+
+// sensorFallbackInterval is how often sensors are polled while the event
+// stream is disconnected
+const sensorFallbackInterval = 30 * time.Second
+
+// GetSensors returns the motion, temperature, light level and button sensors
+func (c *Client) GetSensors() ([]*Sensor, error) {
+	body, err := c.get("/sensors")
+	if err != nil {
+		return nil, err
+	}
+
+	var sensorsMap map[string]v1Sensor
+	if err := json.Unmarshal(body, &sensorsMap); err != nil {
+		return nil, fmt.Errorf("failed to parse sensors: %w", err)
+	}
+
+	sensors := make([]*Sensor, 0, len(sensorsMap))
+	for id, raw := range sensorsMap {
+		if s, ok := raw.parse(id); ok {
+			sensors = append(sensors, s)
+		}
+	}
+
+	sort.Slice(sensors, func(i, j int) bool {
+		return sensors[i].Name < sensors[j].Name
+	})
+	return sensors, nil
+}
+
+// GetSensor returns one sensor by V1 ID
+func (c *Client) GetSensor(id string) (*Sensor, error) {
+	body, err := c.get("/sensors/" + id)
+	if err != nil {
+		return nil, err
+	}
+
+	var raw v1Sensor
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse sensor: %w", err)
+	}
+	s, ok := raw.parse(id)
+	if !ok {
+		return nil, fmt.Errorf("unsupported sensor type %q", raw.Type)
+	}
+	return s, nil
+}
+
+// parse converts a V1 sensor; ok is false for types that aren't monitored
+func (raw v1Sensor) parse(id string) (*Sensor, bool) {
+	kind, ok := sensorKinds[raw.Type]
+	if !ok {
+		return nil, false
+	}
+	s := &Sensor{
+		ID:        id,
+		EntityID:  "hue." + entitySlug(raw.Name),
+		Name:      raw.Name,
+		Kind:      kind,
+		ModelID:   raw.ModelID,
+		UniqueID:  raw.UniqueID,
+		Battery:   raw.Config.Battery,
+		Reachable: raw.Config.Reachable,
+	}
+	if t, err := time.Parse("2006-01-02T15:04:05", raw.State.LastUpdated); err == nil {
+		s.LastUpdated = t
+	}
+	switch kind {
+	case SensorMotion:
+		s.Motion = raw.State.Presence
+	case SensorTemperature:
+		if raw.State.Temperature != nil {
+			celsius := float64(*raw.State.Temperature) / 100
+			s.Temperature = &celsius
+		}
+	case SensorLightLevel:
+		if raw.State.LightLevel != nil {
+			lux := math.Round(math.Pow(10, float64(*raw.State.LightLevel-1)/10000)*10) / 10
+			s.Lux = &lux
+		}
+		s.Dark = raw.State.Dark
+	case SensorButton:
+		s.ButtonEvent = raw.State.ButtonEvent
+	}
+	return s, true
+}
+
+// SensorMonitor keeps the bridge's sensor readings from the event stream and
+// reports changes. REST is only used for the initial snapshot, for light level
+// and button updates the stream doesn't fully describe, and for polling every
+// sensorFallbackInterval while the stream is disconnected.
+type SensorMonitor struct {
+	client *Client
+	events *EventStream
+
+	mu       sync.RWMutex
+	sensors  map[string]*Sensor
+	onChange func(s Sensor, previous *Sensor)
+	onButton func(s Sensor)
+}
+
+// NewSensorMonitor creates a monitor fed by the event stream; with a nil
+// stream it polls every sensorFallbackInterval
+func NewSensorMonitor(client *Client, events *EventStream) *SensorMonitor {
+	m := &SensorMonitor{
+		client:  client,
+		events:  events,
+		sensors: make(map[string]*Sensor),
+	}
+	if events != nil {
+		events.OnSensor(m.handleEvent)
+	}
+	return m
+}
+
+// OnChange registers a callback invoked when a motion, temperature or light reading
+// changes. previous is nil for the first reading after startup.
+func (m *SensorMonitor) OnChange(fn func(s Sensor, previous *Sensor)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// OnButton registers a callback invoked for each new button event
+func (m *SensorMonitor) OnButton(fn func(s Sensor)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onButton = fn
+}
+
+// Sensors returns the latest readings, ordered by name. ok is false until the first poll.
+func (m *SensorMonitor) Sensors() ([]Sensor, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if len(m.sensors) == 0 {
+		return nil, false
+	}
+	result := make([]Sensor, 0, len(m.sensors))
+	for _, s := range m.sensors {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, true
+}
+
+// Run takes a snapshot of the sensors, then polls only while the event stream
+// is disconnected, and once more after it reconnects to catch up on missed
+// events. It blocks, so call it in a goroutine.
+func (m *SensorMonitor) Run() {
+	ticker := time.NewTicker(sensorFallbackInterval)
+	defer ticker.Stop()
+	failing := false
+	streaming := false
+	for {
+		connected := m.events != nil && m.events.Connected()
+		if !connected || !streaming {
+			if err := m.poll(); err != nil {
+				if !failing {
+					log.Printf("Warning: Failed to poll Hue sensors: %v", err)
+				}
+				failing = true
+				connected = false // Snapshot again next time
+			} else {
+				failing = false
+			}
+		}
+		streaming = connected
+		<-ticker.C
+	}
+}
+
+// handleEvent applies a sensor update from the event stream. Light level and
+// button updates are read back over REST for the dark flag and button event.
+func (m *SensorMonitor) handleEvent(e SensorEvent) {
+	if e.Kind == SensorLightLevel || e.Kind == SensorButton {
+		s, err := m.client.GetSensor(e.ID)
+		if err != nil {
+			log.Printf("Warning: Failed to read Hue sensor %s: %v", e.ID, err)
+			return
+		}
+		m.update([]*Sensor{s})
+		return
+	}
+
+	m.mu.RLock()
+	prev, known := m.sensors[e.ID]
+	var s Sensor
+	if known {
+		s = *prev
+	}
+	m.mu.RUnlock()
+	if !known {
+		return // Not in the snapshot yet
+	}
+	if e.Motion != nil {
+		s.Motion = e.Motion
+	}
+	if e.Temperature != nil {
+		celsius := math.Round(*e.Temperature*100) / 100
+		s.Temperature = &celsius
+	}
+	s.Reachable = true
+	s.LastUpdated = time.Now().UTC()
+	m.update([]*Sensor{&s})
+}
+
+// poll reads all sensors and dispatches changes
+func (m *SensorMonitor) poll() error {
+	sensors, err := m.client.GetSensors()
+	if err != nil {
+		return err
+	}
+	m.update(sensors)
+	return nil
+}
+
+// update stores new readings and dispatches changes and button presses
+func (m *SensorMonitor) update(sensors []*Sensor) {
+	type change struct {
+		sensor   Sensor
+		previous *Sensor
+	}
+	var changes []change
+	var presses []Sensor
+
+	m.mu.Lock()
+	for _, s := range sensors {
+		prev, known := m.sensors[s.ID]
+		m.sensors[s.ID] = s
+		if !known {
+			if s.Kind != SensorButton {
+				changes = append(changes, change{sensor: *s})
+			}
+			continue
+		}
+		if s.Kind == SensorButton {
+			// A repeated press of the same button only changes lastupdated
+			if s.ButtonEvent != nil && !s.LastUpdated.IsZero() && s.LastUpdated.After(prev.LastUpdated) {
+				presses = append(presses, *s)
+			}
+			continue
+		}
+		if readingChanged(prev, s) {
+			p := *prev
+			changes = append(changes, change{sensor: *s, previous: &p})
+		}
+	}
+	onChange, onButton := m.onChange, m.onButton
+	m.mu.Unlock()
+
+	for _, c := range changes {
+		if onChange != nil {
+			onChange(c.sensor, c.previous)
+		}
+	}
+	for _, s := range presses {
+		if onButton != nil {
+			onButton(s)
+		}
+	}
+}
+
+func readingChanged(a, b *Sensor) bool {
+	return !eqBool(a.Motion, b.Motion) || !eqFloat(a.Temperature, b.Temperature) ||
+		!eqFloat(a.Lux, b.Lux) || !eqBool(a.Dark, b.Dark)
+}
+
+func eqBool(a, b *bool) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func eqFloat(a, b *float64) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+// entitySlug turns "Hue motion sensor 1" into "hue_motion_sensor_1"
+func entitySlug(name string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			underscore = false
+		} else if !underscore && b.Len() > 0 {
+			b.WriteByte('_')
+			underscore = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
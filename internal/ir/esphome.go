@@ -0,0 +1,61 @@
+package ir
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ESPHome sends raw timings through a Home Assistant API service the node
+// defines, named esphome.<node>_send_raw:
+//
+//	api:
+//	  services:
+//	    - service: send_raw
+//	      variables:
+//	        command: int[]
+//	      then:
+//	        - remote_transmitter.transmit_raw:
+//	            code: !lambda 'return command;'
+//	            carrier_frequency: 38kHz
+type ESPHome struct {
+	node        string
+	callService func(domain, service string, data map[string]interface{}) error
+}
+
+// NewESPHome creates a transmitter for an ESPHome node
+func NewESPHome(node string, callService func(domain, service string, data map[string]interface{}) error) *ESPHome {
+	return &ESPHome{node: node, callService: callService}
+}
+
+// Send transmits raw timings in microseconds, positive for pulses and
+// negative for gaps, separated by commas or spaces (as ESPHome's
+// remote_receiver dumps them)
+func (e *ESPHome) Send(code string) error {
+	if e.callService == nil {
+		return fmt.Errorf("ESPHome transmitters need Home Assistant")
+	}
+	timings, err := ParseRaw(code)
+	if err != nil {
+		return err
+	}
+	return e.callService("esphome", e.node+"_send_raw", map[string]interface{}{"command": timings})
+}
+
+// ParseRaw parses raw timings like "9000, -4500, 560, -560"
+func ParseRaw(code string) ([]int, error) {
+	code = strings.Trim(strings.TrimSpace(code), "[]")
+	fields := strings.FieldsFunc(code, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\t' })
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("empty IR code")
+	}
+	timings := make([]int, len(fields))
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n == 0 {
+			return nil, fmt.Errorf("invalid raw IR timing %q", f)
+		}
+		timings[i] = n
+	}
+	return timings, nil
+}
@@ -0,0 +1,233 @@
+package ir
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Transmitter kinds
+const (
+	KindBroadlink = "broadlink" // Broadlink RM over the local network; target is its IP
+	KindESPHome   = "esphome"   // ESPHome remote_transmitter via a Home Assistant API service; target is the node name
+)
+
+// MaxRepeat caps how many times a command is sent in one request
+const MaxRepeat = 10
+
+// repeatGap is the pause between repeated sends
+const repeatGap = 150 * time.Millisecond
+
+// Transmitter sends IR codes in its own format
+type Transmitter interface {
+	Send(code string) error
+}
+
+// Learner is a transmitter that can also record codes from a remote
+type Learner interface {
+	Learn(timeout time.Duration) (string, error)
+}
+
+// Device is a device without network control, like a receiver or projector,
+// reached through an IR blaster
+type Device struct {
+	Name   string `json:"name"`
+	Kind   string `json:"kind"`
+	Target string `json:"target"`
+}
+
+// DeviceInfo is a device with its known commands
+type DeviceInfo struct {
+	Device
+	Commands []string `json:"commands"`
+	CanLearn bool     `json:"canLearn"`
+}
+
+// ParseDevices parses "receiver=broadlink:192.168.1.50,projector=esphome:living_room_ir"
+func ParseDevices(s string) ([]Device, error) {
+	var devices []Device
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(entry, "=")
+		kind, target, ok2 := strings.Cut(spec, ":")
+		name, kind, target = strings.TrimSpace(name), strings.TrimSpace(kind), strings.TrimSpace(target)
+		if !ok || !ok2 || name == "" || target == "" {
+			return nil, fmt.Errorf("invalid IR device %q (use name=broadlink:<ip> or name=esphome:<node>)", entry)
+		}
+		if kind != KindBroadlink && kind != KindESPHome {
+			return nil, fmt.Errorf("unknown IR transmitter %q in %q (use %s or %s)", kind, entry, KindBroadlink, KindESPHome)
+		}
+		devices = append(devices, Device{Name: name, Kind: kind, Target: target})
+	}
+	return devices, nil
+}
+
+// Manager sends learned codes to IR devices. Codes are stored per device and
+// command, e.g. receiver/power_on.
+type Manager struct {
+	devices      map[string]Device
+	transmitters map[string]Transmitter // Device name -> transmitter; devices on one blaster share it
+
+	mu    sync.RWMutex
+	doc   *store.Doc
+	codes map[string]map[string]string // Device -> command -> code
+}
+
+// NewManager loads the codes. callService calls a Home Assistant service for
+// ESPHome transmitters; it may be nil when none are configured.
+func NewManager(devices []Device, doc *store.Doc, callService func(domain, service string, data map[string]interface{}) error) *Manager {
+	m := &Manager{
+		devices:      make(map[string]Device),
+		transmitters: make(map[string]Transmitter),
+		doc:          doc,
+		codes:        make(map[string]map[string]string),
+	}
+	if _, err := doc.Load(&m.codes); err != nil {
+		log.Printf("Warning: Failed to load IR codes: %v", err)
+	}
+
+	blasters := make(map[string]Transmitter)
+	for _, d := range devices {
+		key := d.Kind + ":" + d.Target
+		t, ok := blasters[key]
+		if !ok {
+			switch d.Kind {
+			case KindBroadlink:
+				t = NewBroadlink(d.Target)
+			case KindESPHome:
+				t = NewESPHome(d.Target, callService)
+			}
+			blasters[key] = t
+		}
+		m.devices[d.Name] = d
+		m.transmitters[d.Name] = t
+	}
+	return m
+}
+
+// Devices returns the devices and their commands, sorted by name
+func (m *Manager) Devices() []DeviceInfo {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]DeviceInfo, 0, len(m.devices))
+	for name, d := range m.devices {
+		commands := make([]string, 0, len(m.codes[name]))
+		for cmd := range m.codes[name] {
+			commands = append(commands, cmd)
+		}
+		sort.Strings(commands)
+		_, canLearn := m.transmitters[name].(Learner)
+		result = append(result, DeviceInfo{Device: d, Commands: commands, CanLearn: canLearn})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// HasDevice reports whether a device is configured
+func (m *Manager) HasDevice(device string) bool {
+	_, ok := m.devices[device]
+	return ok
+}
+
+// HasCommand reports whether a device has a code for a command
+func (m *Manager) HasCommand(device, command string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.codes[device][command]
+	return ok
+}
+
+// Send transmits a command's code, repeat times (1 if 0)
+func (m *Manager) Send(device, command string, repeat int) error {
+	t, ok := m.transmitters[device]
+	if !ok {
+		return fmt.Errorf("unknown IR device: %s", device)
+	}
+	m.mu.RLock()
+	code, ok := m.codes[device][command]
+	m.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no IR code for %s %s", device, command)
+	}
+	if repeat < 1 {
+		repeat = 1
+	}
+	if repeat > MaxRepeat {
+		return fmt.Errorf("repeat must be at most %d", MaxRepeat)
+	}
+
+	for i := 0; i < repeat; i++ {
+		if i > 0 {
+			time.Sleep(repeatGap)
+		}
+		if err := t.Send(code); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetCode stores a code for a command, replacing any existing one
+func (m *Manager) SetCode(device, command, code string) error {
+	if !m.HasDevice(device) {
+		return fmt.Errorf("unknown IR device: %s", device)
+	}
+	command = strings.TrimSpace(command)
+	code = strings.TrimSpace(code)
+	if command == "" || code == "" {
+		return fmt.Errorf("command and code are required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.codes[device] == nil {
+		m.codes[device] = make(map[string]string)
+	}
+	m.codes[device][command] = code
+	return m.save()
+}
+
+// DeleteCode removes a command's code
+func (m *Manager) DeleteCode(device, command string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.codes[device][command]; !ok {
+		return fmt.Errorf("no IR code for %s %s", device, command)
+	}
+	delete(m.codes[device], command)
+	return m.save()
+}
+
+// Learn records a code from a remote pointed at the device's blaster and
+// stores it for the command
+func (m *Manager) Learn(device, command string, timeout time.Duration) (string, error) {
+	t, ok := m.transmitters[device]
+	if !ok {
+		return "", fmt.Errorf("unknown IR device: %s", device)
+	}
+	learner, ok := t.(Learner)
+	if !ok {
+		return "", fmt.Errorf("%s transmitters can't learn codes; add the code instead", m.devices[device].Kind)
+	}
+	code, err := learner.Learn(timeout)
+	if err != nil {
+		return "", err
+	}
+	return code, m.SetCode(device, command, code)
+}
+
+// save persists the codes (caller must hold the lock)
+func (m *Manager) save() error {
+	if err := m.doc.Save(m.codes); err != nil {
+		return fmt.Errorf("failed to save IR codes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,370 @@
+package items
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+const (
+	// switchWindow is how long a room holds an item against weaker sightings
+	// from other rooms, so a tag between two receivers doesn't flap
+	switchWindow = 30 * time.Second
+	// saveInterval throttles persisting sightings that don't change the room
+	saveInterval = 5 * time.Minute
+	// tagWindow is how long unassigned tags are listed for setup
+	tagWindow = 10 * time.Minute
+	maxTags   = 100
+)
+
+// Item is a tracked valuable, like keys or a wallet, with its BLE tag
+type Item struct {
+	ID         string `json:"id"`
+	Name       string `json:"name"`
+	Tag        string `json:"tag"` // BLE tag ID as the receivers report it (MAC, iBeacon or ESPresense ID)
+	Icon       string `json:"icon,omitempty"`
+	RingEntity string `json:"ringEntity,omitempty"` // HA button/script that makes the tag beep
+}
+
+// Sighting is a tag heard by a receiver in a room
+type Sighting struct {
+	Tag      string    `json:"tag"`
+	Room     string    `json:"room"`
+	RSSI     int       `json:"rssi,omitempty"`
+	Distance float64   `json:"distance,omitempty"` // Meters, when the receiver estimates it
+	Source   string    `json:"source,omitempty"`   // mqtt or the reporting tablet
+	At       time.Time `json:"at"`
+}
+
+// Status is an item with where it was last seen, as returned by the API
+type Status struct {
+	Item
+	LastSeen *Sighting `json:"lastSeen,omitempty"`
+	CanRing  bool      `json:"canRing"`
+}
+
+// state is the stored document
+type state struct {
+	Items []Item               `json:"items"`
+	Seen  map[string]*Sighting `json:"seen"` // Tag -> last sighting
+}
+
+// Tracker keeps items and their last sightings
+type Tracker struct {
+	mu       sync.RWMutex
+	doc      *store.Doc
+	state    state
+	lastSave time.Time
+	tags     map[string]Sighting // Recent sightings of tags not assigned to an item
+	onChange func()
+}
+
+// NewTracker loads items and sightings from the store
+func NewTracker(doc *store.Doc) *Tracker {
+	t := &Tracker{doc: doc, tags: make(map[string]Sighting)}
+	if _, err := doc.Load(&t.state); err != nil {
+		log.Printf("Warning: Failed to load items: %v", err)
+	}
+	if t.state.Seen == nil {
+		t.state.Seen = make(map[string]*Sighting)
+	}
+	return t
+}
+
+// OnChange registers a callback invoked after items are modified or one is
+// seen in another room
+func (t *Tracker) OnChange(fn func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onChange = fn
+}
+
+// List returns items ordered by name
+func (t *Tracker) List() []Status {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	result := make([]Status, 0, len(t.state.Items))
+	for _, item := range t.state.Items {
+		result = append(result, t.status(item))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+	})
+	return result
+}
+
+// Get returns an item by ID
+func (t *Tracker) Get(id string) (Status, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	idx := t.indexOf(id)
+	if idx < 0 {
+		return Status{}, false
+	}
+	return t.status(t.state.Items[idx]), true
+}
+
+// Create adds an item
+func (t *Tracker) Create(item Item) (*Item, error) {
+	if err := validate(&item); err != nil {
+		return nil, err
+	}
+	item.ID = newID()
+
+	t.mu.Lock()
+	if t.tagInUse(item.Tag, "") {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("tag %s is already tracked", item.Tag)
+	}
+	t.state.Items = append(t.state.Items, item)
+	delete(t.tags, item.Tag)
+	err := t.save()
+	t.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	t.notify()
+	return &item, nil
+}
+
+// Update changes an item's name, tag, icon and ring entity
+func (t *Tracker) Update(id string, item Item) (*Item, error) {
+	if err := validate(&item); err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	idx := t.indexOf(id)
+	if idx < 0 {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("item not found: %s", id)
+	}
+	if t.tagInUse(item.Tag, id) {
+		t.mu.Unlock()
+		return nil, fmt.Errorf("tag %s is already tracked", item.Tag)
+	}
+	item.ID = id
+	t.state.Items[idx] = item
+	err := t.save()
+	t.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	t.notify()
+	return &item, nil
+}
+
+// Delete removes an item and forgets where its tag was seen
+func (t *Tracker) Delete(id string) error {
+	t.mu.Lock()
+	idx := t.indexOf(id)
+	if idx < 0 {
+		t.mu.Unlock()
+		return fmt.Errorf("item not found: %s", id)
+	}
+	delete(t.state.Seen, t.state.Items[idx].Tag)
+	t.state.Items = append(t.state.Items[:idx], t.state.Items[idx+1:]...)
+	err := t.save()
+	t.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	t.notify()
+	return nil
+}
+
+// Record handles a sighting. A fresh sighting in another room only moves the
+// item when its signal is stronger than the current room's.
+func (t *Tracker) Record(s Sighting) {
+	s.Tag = normalizeTag(s.Tag)
+	s.Room = strings.TrimSpace(s.Room)
+	if s.Tag == "" || s.Room == "" {
+		return
+	}
+	if s.At.IsZero() {
+		s.At = time.Now()
+	}
+
+	t.mu.Lock()
+	if !t.tagInUse(s.Tag, "") {
+		t.rememberTag(s)
+		t.mu.Unlock()
+		return
+	}
+
+	prev := t.state.Seen[s.Tag]
+	moved := prev == nil || prev.Room != s.Room
+	if moved && prev != nil && s.At.Sub(prev.At) < switchWindow && !stronger(s, *prev) {
+		t.mu.Unlock()
+		return
+	}
+	t.state.Seen[s.Tag] = &s
+
+	var err error
+	if moved || time.Since(t.lastSave) >= saveInterval {
+		err = t.save()
+	}
+	t.mu.Unlock()
+
+	if err != nil {
+		log.Printf("Items: %v", err)
+	}
+	if moved {
+		t.notify()
+	}
+}
+
+// Tags returns recently heard tags that aren't assigned to an item, strongest
+// first, to pick from when adding an item
+func (t *Tracker) Tags() []Sighting {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	cutoff := time.Now().Add(-tagWindow)
+	result := make([]Sighting, 0, len(t.tags))
+	for _, s := range t.tags {
+		if s.At.After(cutoff) {
+			result = append(result, s)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return stronger(result[i], result[j]) })
+	return result
+}
+
+// ParseMQTT parses an ESPresense-style sighting published to
+// <prefix>/<tag>/<room> with a {"id", "rssi", "distance"} payload. ESPHome
+// trackers can publish the same format.
+func ParseMQTT(prefix, topic string, payload []byte) (Sighting, bool) {
+	rest, ok := strings.CutPrefix(topic, strings.TrimSuffix(prefix, "/")+"/")
+	if !ok {
+		return Sighting{}, false
+	}
+	tag, room, ok := strings.Cut(rest, "/")
+	if !ok || strings.Contains(room, "/") {
+		return Sighting{}, false
+	}
+
+	var data struct {
+		ID       string  `json:"id"`
+		RSSI     float64 `json:"rssi"`
+		Distance float64 `json:"distance"`
+	}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return Sighting{}, false
+	}
+	if data.ID != "" {
+		tag = data.ID
+	}
+	return Sighting{Tag: tag, Room: room, RSSI: int(data.RSSI), Distance: data.Distance, Source: "mqtt", At: time.Now()}, true
+}
+
+// stronger reports whether a is a closer sighting than b, by distance when
+// both have one and otherwise by signal strength
+func stronger(a, b Sighting) bool {
+	if a.Distance > 0 && b.Distance > 0 {
+		return a.Distance < b.Distance
+	}
+	return a.RSSI > b.RSSI
+}
+
+// rememberTag keeps a recent unassigned tag, dropping the oldest when full
+// (caller must hold the lock)
+func (t *Tracker) rememberTag(s Sighting) {
+	if prev, ok := t.tags[s.Tag]; ok && prev.Room != s.Room && s.At.Sub(prev.At) < switchWindow && !stronger(s, prev) {
+		return
+	}
+	if _, ok := t.tags[s.Tag]; !ok && len(t.tags) >= maxTags {
+		oldest := ""
+		for tag, seen := range t.tags {
+			if oldest == "" || seen.At.Before(t.tags[oldest].At) {
+				oldest = tag
+			}
+		}
+		delete(t.tags, oldest)
+	}
+	t.tags[s.Tag] = s
+}
+
+// status resolves an item's last sighting (caller must hold the lock)
+func (t *Tracker) status(item Item) Status {
+	st := Status{Item: item, CanRing: item.RingEntity != ""}
+	if seen := t.state.Seen[item.Tag]; seen != nil {
+		s := *seen
+		st.LastSeen = &s
+	}
+	return st
+}
+
+func (t *Tracker) tagInUse(tag, exceptID string) bool {
+	for _, item := range t.state.Items {
+		if item.Tag == tag && item.ID != exceptID {
+			return true
+		}
+	}
+	return false
+}
+
+func (t *Tracker) indexOf(id string) int {
+	for i, item := range t.state.Items {
+		if item.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (t *Tracker) notify() {
+	t.mu.RLock()
+	fn := t.onChange
+	t.mu.RUnlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// save persists items and sightings (caller must hold the lock)
+func (t *Tracker) save() error {
+	if err := t.doc.Save(t.state); err != nil {
+		return fmt.Errorf("failed to save items: %w", err)
+	}
+	t.lastSave = time.Now()
+	return nil
+}
+
+func validate(item *Item) error {
+	item.Name = strings.TrimSpace(item.Name)
+	item.Tag = normalizeTag(item.Tag)
+	item.Icon = strings.TrimSpace(item.Icon)
+	item.RingEntity = strings.TrimSpace(item.RingEntity)
+	if item.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if item.Tag == "" {
+		return fmt.Errorf("tag is required")
+	}
+	if item.RingEntity != "" && !strings.Contains(item.RingEntity, ".") {
+		return fmt.Errorf("invalid ring entity: %s", item.RingEntity)
+	}
+	return nil
+}
+
+// normalizeTag makes MAC addresses and IDs comparable across receivers
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
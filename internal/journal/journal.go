@@ -0,0 +1,135 @@
+package journal
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Entry kinds
+const (
+	KindAction      = "action"       // User-initiated control (toggle, scene, service call)
+	KindEntity      = "entity"       // Observed Home Assistant state change
+	KindDevicePower = "device_power" // Entertainment device power on/off
+	KindTablet      = "tablet"       // Kiosk sensor transitions (proximity)
+	KindButton      = "button"       // Physical button press
+	KindDoorbell    = "doorbell"
+	KindPresence    = "presence"   // Arrivals, departures and zone changes
+	KindHouseMode   = "house_mode" // Home/away/night/vacation transitions
+)
+
+// eventType is the store event type used for journal entries
+const eventType = "journal"
+
+// Entry is a single recorded state transition
+type Entry struct {
+	ID      int64                  `json:"id"`
+	Time    time.Time              `json:"time"`
+	Kind    string                 `json:"kind"`
+	Subject string                 `json:"subject"`          // Entity ID, device name, button ID...
+	From    string                 `json:"from,omitempty"`   // Previous state, if known
+	To      string                 `json:"to,omitempty"`     // New state or service
+	Source  string                 `json:"source,omitempty"` // Device ID, "mqtt", "system"...
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+// Journal is an append-only log of state transitions with live subscribers
+type Journal struct {
+	store *store.Store
+
+	mu     sync.Mutex
+	subs   map[int]chan Entry
+	nextID int
+}
+
+// New creates a journal, pruning entries older than retention (0 = keep forever)
+func New(st *store.Store, retention time.Duration) *Journal {
+	if retention > 0 {
+		if n, err := st.PruneEvents(eventType, time.Now().Add(-retention)); err != nil {
+			log.Printf("Warning: Failed to prune journal: %v", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d old journal entries", n)
+		}
+	}
+	return &Journal{store: st, subs: make(map[int]chan Entry)}
+}
+
+// Record appends an entry and delivers it to subscribers. Time defaults to now.
+func (j *Journal) Record(e Entry) Entry {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	id, err := j.store.AppendEvent(e.Time, eventType, e.Kind, e)
+	if err != nil {
+		log.Printf("Warning: Failed to record journal entry: %v", err)
+		return e
+	}
+	e.ID = id
+
+	j.mu.Lock()
+	for _, ch := range j.subs {
+		select {
+		case ch <- e:
+		default:
+			// Slow subscriber; it can catch up with Since
+		}
+	}
+	j.mu.Unlock()
+	return e
+}
+
+// Since returns entries with an ID greater than afterID, oldest first
+func (j *Journal) Since(afterID int64, limit int) ([]Entry, error) {
+	events, err := j.store.EventsAfter(eventType, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return decode(events)
+}
+
+// SinceTime returns entries at or after t, oldest first
+func (j *Journal) SinceTime(t time.Time, limit int) ([]Entry, error) {
+	events, err := j.store.Events(eventType, t, limit)
+	if err != nil {
+		return nil, err
+	}
+	return decode(events)
+}
+
+// Subscribe returns a channel receiving new entries and a function to unsubscribe
+func (j *Journal) Subscribe(buffer int) (<-chan Entry, func()) {
+	ch := make(chan Entry, buffer)
+
+	j.mu.Lock()
+	id := j.nextID
+	j.nextID++
+	j.subs[id] = ch
+	j.mu.Unlock()
+
+	return ch, func() {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		if _, ok := j.subs[id]; ok {
+			delete(j.subs, id)
+			close(ch)
+		}
+	}
+}
+
+func decode(events []store.Event) ([]Entry, error) {
+	result := make([]Entry, 0, len(events))
+	for _, ev := range events {
+		var e Entry
+		if err := json.Unmarshal(ev.Data, &e); err != nil {
+			return nil, fmt.Errorf("failed to decode journal entry %d: %w", ev.ID, err)
+		}
+		e.ID = ev.ID
+		result = append(result, e)
+	}
+	return result, nil
+}
@@ -0,0 +1,226 @@
+package lighting
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Interval is how often enabled rooms are adjusted. Each step fades over
+// Transition so changes through the day are imperceptible.
+const (
+	Interval   = 5 * time.Minute
+	Transition = time.Minute
+)
+
+// Settings are the adaptive lighting ranges and the rooms it runs in. Rooms are
+// "hue:<group ID>" for Hue rooms or light.* entity IDs for HA lights.
+type Settings struct {
+	Rooms         map[string]bool `json:"rooms"`
+	MinKelvin     int             `json:"minKelvin"`     // Warmest, at night
+	MaxKelvin     int             `json:"maxKelvin"`     // Coolest, at solar noon
+	MinBrightness int             `json:"minBrightness"` // Percent, at night
+	MaxBrightness int             `json:"maxBrightness"` // Percent, at solar noon
+}
+
+// Values are the color temperature and brightness for a moment of the day
+type Values struct {
+	Kelvin     int         `json:"kelvin"`
+	Brightness int         `json:"brightness"` // Percent
+	Sun        SunPosition `json:"sun"`
+}
+
+// Scheduler adjusts enabled rooms to follow the sun
+type Scheduler struct {
+	lat, lon float64
+
+	mu       sync.RWMutex
+	doc      *store.Doc
+	settings Settings
+	apply    func(room string, v Values) error
+	failing  map[string]bool
+}
+
+// NewScheduler loads settings from the store
+func NewScheduler(doc *store.Doc, lat, lon float64) *Scheduler {
+	s := &Scheduler{
+		lat:      lat,
+		lon:      lon,
+		doc:      doc,
+		settings: defaultSettings(),
+		failing:  make(map[string]bool),
+	}
+	if _, err := doc.Load(&s.settings); err != nil {
+		log.Printf("Warning: Failed to load adaptive lighting settings: %v", err)
+	}
+	if s.settings.Rooms == nil {
+		s.settings.Rooms = make(map[string]bool)
+	}
+	return s
+}
+
+func defaultSettings() Settings {
+	return Settings{
+		Rooms:         make(map[string]bool),
+		MinKelvin:     2200,
+		MaxKelvin:     5000,
+		MinBrightness: 40,
+		MaxBrightness: 100,
+	}
+}
+
+// SetApplier sets the function that applies values to a room. It should only
+// adjust lights that are already on.
+func (s *Scheduler) SetApplier(fn func(room string, v Values) error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.apply = fn
+}
+
+// Settings returns the current settings
+func (s *Scheduler) Settings() Settings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := s.settings
+	result.Rooms = make(map[string]bool, len(s.settings.Rooms))
+	for k, v := range s.settings.Rooms {
+		result.Rooms[k] = v
+	}
+	return result
+}
+
+// Enabled reports whether adaptive lighting runs in a room
+func (s *Scheduler) Enabled(room string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.settings.Rooms[room]
+}
+
+// UpdateRanges changes the color temperature and brightness ranges
+func (s *Scheduler) UpdateRanges(minKelvin, maxKelvin, minBrightness, maxBrightness int) error {
+	if minKelvin < 2000 || maxKelvin > 6500 || minKelvin > maxKelvin {
+		return fmt.Errorf("kelvin range must be between 2000 and 6500")
+	}
+	if minBrightness < 1 || maxBrightness > 100 || minBrightness > maxBrightness {
+		return fmt.Errorf("brightness range must be between 1 and 100")
+	}
+
+	s.mu.Lock()
+	s.settings.MinKelvin, s.settings.MaxKelvin = minKelvin, maxKelvin
+	s.settings.MinBrightness, s.settings.MaxBrightness = minBrightness, maxBrightness
+	err := s.save()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.Tick()
+	return nil
+}
+
+// SetEnabled turns adaptive lighting on or off for a room. A room that's
+// turned on is adjusted right away.
+func (s *Scheduler) SetEnabled(room string, enabled bool) error {
+	s.mu.Lock()
+	if enabled {
+		s.settings.Rooms[room] = true
+	} else {
+		delete(s.settings.Rooms, room)
+	}
+	err := s.save()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if enabled {
+		s.applyRoom(room, s.Current())
+	}
+	return nil
+}
+
+// Current returns the values for now
+func (s *Scheduler) Current() Values {
+	return s.At(time.Now())
+}
+
+// At returns the values for a moment. The curve runs from the night values at
+// civil dusk (sun 6° below the horizon) to the day values at solar noon, eased
+// so mornings and evenings change gently.
+func (s *Scheduler) At(t time.Time) Values {
+	s.mu.RLock()
+	settings := s.settings
+	s.mu.RUnlock()
+
+	sun := Sun(t, s.lat, s.lon)
+	f := 0.0
+	if span := sun.NoonElevation + 6; span > 0 {
+		f = math.Max(0, math.Min(1, (sun.Elevation+6)/span))
+	}
+	f = f * f * (3 - 2*f) // smoothstep
+
+	return Values{
+		Kelvin:     settings.MinKelvin + int(math.Round(f*float64(settings.MaxKelvin-settings.MinKelvin))),
+		Brightness: settings.MinBrightness + int(math.Round(f*float64(settings.MaxBrightness-settings.MinBrightness))),
+		Sun:        sun,
+	}
+}
+
+// Run adjusts enabled rooms every Interval. It blocks, so call it in a goroutine.
+func (s *Scheduler) Run() {
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+	for {
+		s.Tick()
+		<-ticker.C
+	}
+}
+
+// Tick adjusts every enabled room once
+func (s *Scheduler) Tick() {
+	v := s.Current()
+	s.mu.RLock()
+	rooms := make([]string, 0, len(s.settings.Rooms))
+	for room, on := range s.settings.Rooms {
+		if on {
+			rooms = append(rooms, room)
+		}
+	}
+	s.mu.RUnlock()
+
+	sort.Strings(rooms)
+	for _, room := range rooms {
+		s.applyRoom(room, v)
+	}
+}
+
+// applyRoom applies values to a room, logging only the first of repeated failures
+func (s *Scheduler) applyRoom(room string, v Values) {
+	s.mu.RLock()
+	fn := s.apply
+	s.mu.RUnlock()
+	if fn == nil {
+		return
+	}
+
+	err := fn(room, v)
+	s.mu.Lock()
+	if err != nil && !s.failing[room] {
+		log.Printf("Warning: Adaptive lighting failed for %s: %v", room, err)
+	}
+	s.failing[room] = err != nil
+	s.mu.Unlock()
+}
+
+// save persists settings (caller must hold the lock)
+func (s *Scheduler) save() error {
+	if err := s.doc.Save(s.settings); err != nil {
+		return fmt.Errorf("failed to save adaptive lighting settings: %w", err)
+	}
+	return nil
+}
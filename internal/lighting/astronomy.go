@@ -0,0 +1,150 @@
+package lighting
+
+import (
+	"math"
+	"time"
+)
+
+// Sun elevations that mark the day's events, in degrees
+const (
+	horizonElevation    = -0.833 // Sunrise and sunset, allowing for refraction and the sun's radius
+	civilElevation      = -6     // Civil dawn and dusk
+	goldenHourElevation = 6      // Golden hour is the sun below this and above the horizon
+)
+
+const synodicMonth = 29.53058770576 // Days from new moon to new moon
+
+// referenceNewMoon is a known new moon that phases are counted from
+var referenceNewMoon = time.Date(2000, 1, 6, 18, 14, 0, 0, time.UTC)
+
+// SunDay is the sun's schedule for one day at a place. Events the sun doesn't
+// reach that day (polar day or night) are omitted.
+type SunDay struct {
+	Dawn          *time.Time `json:"dawn,omitempty"` // Civil dawn, sun 6° below the horizon
+	Sunrise       *time.Time `json:"sunrise,omitempty"`
+	GoldenHourEnd *time.Time `json:"goldenHourEnd,omitempty"` // Morning golden hour ends
+	SolarNoon     time.Time  `json:"solarNoon"`
+	GoldenHour    *time.Time `json:"goldenHour,omitempty"` // Evening golden hour starts
+	Sunset        *time.Time `json:"sunset,omitempty"`
+	Dusk          *time.Time `json:"dusk,omitempty"`
+	DayLength     int64      `json:"dayLength"` // Seconds from sunrise to sunset
+}
+
+// Moon is the moon's phase at a time
+type Moon struct {
+	Phase        float64   `json:"phase"`        // 0 new, 0.25 first quarter, 0.5 full, 0.75 last quarter
+	Age          float64   `json:"age"`          // Days since the new moon
+	Illumination float64   `json:"illumination"` // Fraction of the disc lit
+	Name         string    `json:"name"`
+	Icon         string    `json:"icon"`
+	NextNew      time.Time `json:"nextNew"`
+	NextFull     time.Time `json:"nextFull"`
+}
+
+// SunTimes finds the sun's events on date's day in loc by stepping through
+// the day and refining each crossing to the second
+func SunTimes(date time.Time, lat, lon float64, loc *time.Location) SunDay {
+	y, m, d := date.In(loc).Date()
+	start := time.Date(y, m, d, 0, 0, 0, 0, loc)
+	end := start.AddDate(0, 0, 1)
+	elevation := func(t time.Time) float64 { return Sun(t, lat, lon).Elevation }
+
+	// Solar noon is the highest sample, refined by ternary search
+	const step = 10 * time.Minute
+	noon := start
+	for t := start; t.Before(end); t = t.Add(step) {
+		if elevation(t) > elevation(noon) {
+			noon = t
+		}
+	}
+	lo, hi := noon.Add(-step), noon.Add(step)
+	for hi.Sub(lo) > time.Second {
+		third := hi.Sub(lo) / 3
+		if elevation(lo.Add(third)) < elevation(hi.Add(-third)) {
+			lo = lo.Add(third)
+		} else {
+			hi = hi.Add(-third)
+		}
+	}
+	noon = lo.Truncate(time.Second)
+
+	day := SunDay{SolarNoon: noon}
+	day.Dawn = crossing(elevation, start, noon, civilElevation, true)
+	day.Sunrise = crossing(elevation, start, noon, horizonElevation, true)
+	day.GoldenHourEnd = crossing(elevation, start, noon, goldenHourElevation, true)
+	day.GoldenHour = crossing(elevation, noon, end, goldenHourElevation, false)
+	day.Sunset = crossing(elevation, noon, end, horizonElevation, false)
+	day.Dusk = crossing(elevation, noon, end, civilElevation, false)
+
+	switch {
+	case day.Sunrise != nil && day.Sunset != nil:
+		day.DayLength = int64(day.Sunset.Sub(*day.Sunrise).Seconds())
+	case day.Sunrise != nil:
+		day.DayLength = int64(end.Sub(*day.Sunrise).Seconds())
+	case day.Sunset != nil:
+		day.DayLength = int64(day.Sunset.Sub(start).Seconds())
+	case elevation(noon) > horizonElevation:
+		day.DayLength = int64(end.Sub(start).Seconds()) // Midnight sun
+	}
+	return day
+}
+
+// crossing finds when the elevation passes target between from and to, rising
+// or setting, or nil if it doesn't
+func crossing(elevation func(time.Time) float64, from, to time.Time, target float64, rising bool) *time.Time {
+	const step = 10 * time.Minute
+	above := func(t time.Time) bool { return elevation(t) >= target }
+	for t := from; t.Before(to); t = t.Add(step) {
+		next := t.Add(step)
+		if next.After(to) {
+			next = to
+		}
+		if above(t) == rising || above(next) != rising {
+			continue
+		}
+		lo, hi := t, next
+		for hi.Sub(lo) > time.Second {
+			mid := lo.Add(hi.Sub(lo) / 2)
+			if above(mid) == rising {
+				hi = mid
+			} else {
+				lo = mid
+			}
+		}
+		result := hi.Truncate(time.Second)
+		return &result
+	}
+	return nil
+}
+
+// MoonPhase computes the moon's phase at t from the mean synodic month, which
+// is within about half a day of the true phase
+func MoonPhase(t time.Time) Moon {
+	age := math.Mod(t.Sub(referenceNewMoon).Hours()/24, synodicMonth)
+	if age < 0 {
+		age += synodicMonth
+	}
+	phase := age / synodicMonth
+	days := func(d float64) time.Duration { return time.Duration(d * 24 * float64(time.Hour)) }
+
+	moon := Moon{
+		Phase:        phase,
+		Age:          age,
+		Illumination: (1 - math.Cos(2*math.Pi*phase)) / 2,
+		NextNew:      t.Add(days(synodicMonth - age)).Truncate(time.Minute),
+	}
+	untilFull := synodicMonth/2 - age
+	if untilFull < 0 {
+		untilFull += synodicMonth
+	}
+	moon.NextFull = t.Add(days(untilFull)).Truncate(time.Minute)
+
+	// Eight phases, each centered on its point in the cycle
+	names := []struct{ name, icon string }{
+		{"New Moon", "🌑"}, {"Waxing Crescent", "🌒"}, {"First Quarter", "🌓"}, {"Waxing Gibbous", "🌔"},
+		{"Full Moon", "🌕"}, {"Waning Gibbous", "🌖"}, {"Last Quarter", "🌗"}, {"Waning Crescent", "🌘"},
+	}
+	i := int(math.Floor(phase*8+0.5)) % 8
+	moon.Name, moon.Icon = names[i].name, names[i].icon
+	return moon
+}
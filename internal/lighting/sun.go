@@ -0,0 +1,71 @@
+package lighting
+
+import (
+	"math"
+	"time"
+)
+
+// SunPosition is the sun's height above the horizon and compass direction at
+// a place and time
+type SunPosition struct {
+	Elevation     float64 `json:"elevation"`     // Degrees, negative below the horizon
+	Azimuth       float64 `json:"azimuth"`       // Degrees clockwise from north
+	NoonElevation float64 `json:"noonElevation"` // Highest elevation of the day
+}
+
+// Sun computes the sun's position with the NOAA solar equations, accurate to
+// well under a degree, which is plenty for lighting
+func Sun(t time.Time, lat, lon float64) SunPosition {
+	jd := float64(t.Unix())/86400 + 2440587.5
+	jc := (jd - 2451545) / 36525
+
+	meanLong := math.Mod(280.46646+jc*(36000.76983+jc*0.0003032), 360)
+	meanAnom := 357.52911 + jc*(35999.05029-0.0001537*jc)
+	ecc := 0.016708634 - jc*(0.000042037+0.0000001267*jc)
+	center := math.Sin(rad(meanAnom))*(1.914602-jc*(0.004817+0.000014*jc)) +
+		math.Sin(rad(2*meanAnom))*(0.019993-0.000101*jc) +
+		math.Sin(rad(3*meanAnom))*0.000289
+	omega := 125.04 - 1934.136*jc
+	appLong := meanLong + center - 0.00569 - 0.00478*math.Sin(rad(omega))
+	meanObliq := 23 + (26+(21.448-jc*(46.815+jc*(0.00059-jc*0.001813)))/60)/60
+	obliq := meanObliq + 0.00256*math.Cos(rad(omega))
+	decl := deg(math.Asin(math.Sin(rad(obliq)) * math.Sin(rad(appLong))))
+
+	// Equation of time (minutes) shifts solar noon away from 12:00 local mean time
+	y := math.Pow(math.Tan(rad(obliq/2)), 2)
+	eqTime := 4 * deg(y*math.Sin(2*rad(meanLong))-2*ecc*math.Sin(rad(meanAnom))+
+		4*ecc*y*math.Sin(rad(meanAnom))*math.Cos(2*rad(meanLong))-
+		0.5*y*y*math.Sin(4*rad(meanLong))-1.25*ecc*ecc*math.Sin(2*rad(meanAnom)))
+
+	utc := t.UTC()
+	minutes := float64(utc.Hour()*60+utc.Minute()) + float64(utc.Second())/60
+	solarTime := math.Mod(minutes+eqTime+4*lon, 1440)
+	if solarTime < 0 {
+		solarTime += 1440
+	}
+	hourAngle := solarTime/4 - 180
+
+	cosZenith := math.Sin(rad(lat))*math.Sin(rad(decl)) + math.Cos(rad(lat))*math.Cos(rad(decl))*math.Cos(rad(hourAngle))
+	zenith := deg(math.Acos(math.Max(-1, math.Min(1, cosZenith))))
+
+	var azimuth float64
+	if sinZenith := math.Sin(rad(zenith)) * math.Cos(rad(lat)); math.Abs(sinZenith) > 1e-9 {
+		cosAz := (math.Sin(rad(lat))*math.Cos(rad(zenith)) - math.Sin(rad(decl))) / sinZenith
+		a := deg(math.Acos(math.Max(-1, math.Min(1, cosAz))))
+		if hourAngle > 0 {
+			azimuth = math.Mod(a+180, 360)
+		} else {
+			azimuth = math.Mod(540-a, 360)
+		}
+	}
+
+	return SunPosition{
+		Elevation:     90 - zenith,
+		Azimuth:       azimuth,
+		NoonElevation: 90 - math.Abs(lat-decl),
+	}
+}
+
+func rad(d float64) float64 { return d * math.Pi / 180 }
+
+func deg(r float64) float64 { return r * 180 / math.Pi }
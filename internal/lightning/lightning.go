@@ -0,0 +1,221 @@
+package lightning
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBroker is the public Blitzortung MQTT relay used by the Home Assistant integration
+const DefaultBroker = "blitzortung.ha.sed.pl"
+
+// strikeWindow is how long strikes are kept for the status view
+const strikeWindow = time.Hour
+
+// Strike is a single lightning strike relative to home
+type Strike struct {
+	Time       time.Time `json:"time"`
+	Lat        float64   `json:"lat"`
+	Lon        float64   `json:"lon"`
+	DistanceKm float64   `json:"distanceKm"`
+	Direction  string    `json:"direction"` // Compass direction from home, e.g. "NW"
+}
+
+// Alert is raised when strikes move inside a tighter radius, or when the storm has passed
+type Alert struct {
+	Level    int     `json:"level"`    // Index into the radii, 0 = outermost; -1 for all clear
+	Levels   int     `json:"levels"`   // Number of configured radii
+	RadiusKm float64 `json:"radiusKm"` // Radius that was breached
+	Strike   *Strike `json:"strike,omitempty"`
+	AllClear bool    `json:"allClear"`
+}
+
+// Status summarizes recent activity near home
+type Status struct {
+	Level      int       `json:"level"` // -1 when no radius is breached
+	RadiusKm   float64   `json:"radiusKm,omitempty"`
+	RadiiKm    []float64 `json:"radiiKm"`
+	Nearest    *Strike   `json:"nearest,omitempty"`
+	LastStrike *Strike   `json:"lastStrike,omitempty"`
+	Count      int       `json:"count"` // Strikes within the outer radius in the last hour
+	Strikes    []Strike  `json:"strikes"`
+}
+
+// Config holds the monitor settings
+type Config struct {
+	Lat        float64
+	Lon        float64
+	RadiiKm    []float64     // Alert radii; sorted outermost first
+	ClearAfter time.Duration // Quiet period before all clear (default 30m)
+}
+
+// Monitor tracks strikes around home and escalates as they get closer
+type Monitor struct {
+	cfg Config
+
+	mu      sync.Mutex
+	strikes []Strike
+	level   int
+	last    time.Time
+	onAlert func(Alert)
+}
+
+// NewMonitor creates a lightning monitor
+func NewMonitor(cfg Config) *Monitor {
+	radii := append([]float64{}, cfg.RadiiKm...)
+	sort.Sort(sort.Reverse(sort.Float64Slice(radii)))
+	cfg.RadiiKm = radii
+	if cfg.ClearAfter <= 0 {
+		cfg.ClearAfter = 30 * time.Minute
+	}
+	return &Monitor{cfg: cfg, level: -1}
+}
+
+// OnAlert registers a callback for escalations and all clears
+func (m *Monitor) OnAlert(fn func(Alert)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onAlert = fn
+}
+
+// Topics returns the Blitzortung MQTT topic filters covering the area around home:
+// the geohash cell containing home plus its neighbours
+func (m *Monitor) Topics() []string {
+	const precision = 3
+	const cell = 1.40625 // Degrees per precision-3 geohash cell in each direction
+
+	seen := make(map[string]bool)
+	var topics []string
+	for _, dLat := range []float64{-cell, 0, cell} {
+		for _, dLon := range []float64{-cell, 0, cell} {
+			hash := Geohash(m.cfg.Lat+dLat, m.cfg.Lon+dLon, precision)
+			if seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			topics = append(topics, "blitzortung/1.1/"+strings.Join(strings.Split(hash, ""), "/")+"/#")
+		}
+	}
+	return topics
+}
+
+// HandleMessage parses a Blitzortung strike message (time in nanoseconds)
+func (m *Monitor) HandleMessage(topic string, payload []byte) {
+	var msg struct {
+		Time int64   `json:"time"`
+		Lat  float64 `json:"lat"`
+		Lon  float64 `json:"lon"`
+	}
+	if err := json.Unmarshal(payload, &msg); err != nil || msg.Time == 0 {
+		return
+	}
+	m.Add(time.Unix(0, msg.Time), msg.Lat, msg.Lon)
+}
+
+// Add records a strike, raising an alert if it is inside a tighter radius than before
+func (m *Monitor) Add(t time.Time, lat, lon float64) {
+	if len(m.cfg.RadiiKm) == 0 {
+		return
+	}
+	dist := Distance(m.cfg.Lat, m.cfg.Lon, lat, lon)
+	if dist > m.cfg.RadiiKm[0] {
+		return
+	}
+	strike := Strike{
+		Time:       t,
+		Lat:        lat,
+		Lon:        lon,
+		DistanceKm: dist,
+		Direction:  Compass(Bearing(m.cfg.Lat, m.cfg.Lon, lat, lon)),
+	}
+
+	level := 0
+	for i, r := range m.cfg.RadiiKm {
+		if dist <= r {
+			level = i
+		}
+	}
+
+	m.mu.Lock()
+	m.strikes = append(m.strikes, strike)
+	m.prune(time.Now())
+	m.last = time.Now()
+	var alert *Alert
+	if level > m.level {
+		m.level = level
+		alert = &Alert{Level: level, Levels: len(m.cfg.RadiiKm), RadiusKm: m.cfg.RadiiKm[level], Strike: &strike}
+	}
+	fn := m.onAlert
+	m.mu.Unlock()
+
+	if alert != nil && fn != nil {
+		fn(*alert)
+	}
+}
+
+// Run raises the all clear once no strikes have been seen inside the outer radius
+// for the quiet period. It blocks, so call it in a goroutine.
+func (m *Monitor) Run() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.mu.Lock()
+		m.prune(time.Now())
+		var alert *Alert
+		if m.level >= 0 && time.Since(m.last) >= m.cfg.ClearAfter {
+			m.level = -1
+			alert = &Alert{Level: -1, Levels: len(m.cfg.RadiiKm), AllClear: true}
+		}
+		fn := m.onAlert
+		m.mu.Unlock()
+
+		if alert != nil && fn != nil {
+			fn(*alert)
+		}
+	}
+}
+
+// Status returns recent activity near home
+func (m *Monitor) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(time.Now())
+
+	st := Status{
+		Level:   m.level,
+		RadiiKm: m.cfg.RadiiKm,
+		Count:   len(m.strikes),
+		Strikes: append([]Strike{}, m.strikes...),
+	}
+	if m.level >= 0 {
+		st.RadiusKm = m.cfg.RadiiKm[m.level]
+	}
+	for i := range st.Strikes {
+		s := st.Strikes[i]
+		if st.Nearest == nil || s.DistanceKm < st.Nearest.DistanceKm {
+			st.Nearest = &s
+		}
+	}
+	if n := len(st.Strikes); n > 0 {
+		st.LastStrike = &st.Strikes[n-1]
+	}
+	return st
+}
+
+// Describe formats a strike for notifications, e.g. "7 km NW"
+func (s Strike) Describe() string {
+	return fmt.Sprintf("%.0f km %s", s.DistanceKm, s.Direction)
+}
+
+// prune drops strikes older than the window (caller must hold the lock)
+func (m *Monitor) prune(now time.Time) {
+	cutoff := now.Add(-strikeWindow)
+	i := 0
+	for i < len(m.strikes) && m.strikes[i].Time.Before(cutoff) {
+		i++
+	}
+	m.strikes = m.strikes[i:]
+}
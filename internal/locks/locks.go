@@ -0,0 +1,178 @@
+package locks
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// AllLocks is the PIN map key that applies to every lock without its own PIN
+const AllLocks = "*"
+
+// Audit results
+const (
+	ResultOK        = "ok"
+	ResultDenied    = "denied"     // Missing or wrong PIN
+	ResultLockedOut = "locked_out" // Too many failed attempts on the lock
+	ResultError     = "error"      // Home Assistant call failed
+)
+
+// Failed attempt limits per lock before further attempts are refused. They are
+// counted per lock, not per device: device IDs come from the client, so a
+// guesser could pick a new one for every attempt.
+const (
+	maxFailures   = 5
+	failureWindow = 5 * time.Minute
+)
+
+// eventType is the store event type used for audit entries
+const eventType = "lock_audit"
+
+var (
+	ErrPINRequired = errors.New("PIN required")
+	ErrInvalidPIN  = errors.New("invalid PIN")
+	ErrLockedOut   = errors.New("too many failed PIN attempts, try again later")
+)
+
+// AuditEntry records a single lock action attempt
+type AuditEntry struct {
+	ID       int64     `json:"id"`
+	Time     time.Time `json:"time"`
+	EntityID string    `json:"entityId"`
+	Action   string    `json:"action"` // lock, unlock, toggle
+	Source   string    `json:"source"` // Device ID or "button:<device>"
+	Result   string    `json:"result"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// Guard enforces per-lock PINs and keeps the audit log
+type Guard struct {
+	store *store.Store
+	pins  map[string]string // entity ID (or AllLocks) -> PIN
+
+	mu       sync.Mutex
+	failures map[string][]time.Time // entity ID -> recent failed attempts
+}
+
+// NewGuard creates a guard with the configured PINs, pruning audit entries older
+// than retention (0 = keep forever)
+func NewGuard(st *store.Store, pins map[string]string, retention time.Duration) *Guard {
+	if retention > 0 {
+		if n, err := st.PruneEvents(eventType, time.Now().Add(-retention)); err != nil {
+			log.Printf("Warning: Failed to prune lock audit log: %v", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d old lock audit entries", n)
+		}
+	}
+	if pins == nil {
+		pins = make(map[string]string)
+	}
+	return &Guard{store: st, pins: pins, failures: make(map[string][]time.Time)}
+}
+
+// Required reports whether a PIN is needed to operate the lock
+func (g *Guard) Required(entityID string) bool {
+	return g.pin(entityID) != ""
+}
+
+// Check verifies the PIN for a lock. After repeated failures on the lock, every
+// attempt is refused with ErrLockedOut until the window passes.
+func (g *Guard) Check(entityID, pin string) error {
+	want := g.pin(entityID)
+	if want == "" {
+		return nil
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	cutoff := time.Now().Add(-failureWindow)
+	recent := g.failures[entityID][:0]
+	for _, t := range g.failures[entityID] {
+		if t.After(cutoff) {
+			recent = append(recent, t)
+		}
+	}
+	g.failures[entityID] = recent
+	if len(recent) >= maxFailures {
+		return ErrLockedOut
+	}
+
+	if pin == "" {
+		return ErrPINRequired
+	}
+	if subtle.ConstantTimeCompare([]byte(pin), []byte(want)) != 1 {
+		g.failures[entityID] = append(recent, time.Now())
+		return ErrInvalidPIN
+	}
+	delete(g.failures, entityID)
+	return nil
+}
+
+// Record adds an attempt to the audit log; the result is derived from err
+func (g *Guard) Record(entityID, action, source string, err error) {
+	entry := AuditEntry{
+		Time:     time.Now(),
+		EntityID: entityID,
+		Action:   action,
+		Source:   source,
+		Result:   ResultOK,
+	}
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrPINRequired), errors.Is(err, ErrInvalidPIN):
+		entry.Result = ResultDenied
+		entry.Error = err.Error()
+	case errors.Is(err, ErrLockedOut):
+		entry.Result = ResultLockedOut
+		entry.Error = err.Error()
+	default:
+		entry.Result = ResultError
+		entry.Error = err.Error()
+	}
+
+	if _, err := g.store.AppendEvent(entry.Time, eventType, entityID, entry); err != nil {
+		log.Printf("Warning: Failed to record lock audit entry: %v", err)
+	}
+	if entry.Result != ResultOK {
+		log.Printf("Lock %s %s from %s: %s", entityID, action, source, entry.Result)
+	}
+}
+
+// Audit returns recent audit entries, newest first, optionally for one lock
+func (g *Guard) Audit(entityID string, limit int) ([]AuditEntry, error) {
+	var events []store.Event
+	var err error
+	if entityID != "" {
+		events, err = g.store.RecentEventsFrom(eventType, entityID, limit)
+	} else {
+		events, err = g.store.RecentEvents(eventType, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]AuditEntry, 0, len(events))
+	for _, ev := range events {
+		var e AuditEntry
+		if err := json.Unmarshal(ev.Data, &e); err != nil {
+			return nil, fmt.Errorf("failed to decode lock audit entry %d: %w", ev.ID, err)
+		}
+		e.ID = ev.ID
+		result = append(result, e)
+	}
+	return result, nil
+}
+
+func (g *Guard) pin(entityID string) string {
+	if pin, ok := g.pins[entityID]; ok {
+		return pin
+	}
+	return g.pins[AllLocks]
+}
@@ -0,0 +1,120 @@
+package mailbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// eventType is the store event type used for mailbox openings
+const eventType = "mailbox"
+
+// dateLayout is the format of Day.Date
+const dateLayout = "2006-01-02"
+
+// Opening is one opening of the mailbox. The first opening of a day is taken
+// as the delivery; later ones are someone collecting the mail.
+type Opening struct {
+	Time     time.Time `json:"time"`
+	Delivery bool      `json:"delivery"`
+}
+
+// Day summarizes the openings on one local date
+type Day struct {
+	Date      string      `json:"date"` // YYYY-MM-DD
+	Delivered time.Time   `json:"delivered"`
+	Openings  []time.Time `json:"openings"`
+}
+
+// Log records mailbox openings and detects the daily delivery
+type Log struct {
+	store    *store.Store
+	timezone *time.Location
+
+	mu         sync.Mutex
+	lastDate   string // Date of the latest delivery
+	onDelivery func(o Opening)
+}
+
+// NewLog creates a log. Openings older than retention are pruned.
+func NewLog(st *store.Store, timezone *time.Location, retention time.Duration) *Log {
+	if timezone == nil {
+		timezone = time.Local
+	}
+	if retention > 0 {
+		if n, err := st.PruneEvents(eventType, time.Now().Add(-retention)); err != nil {
+			log.Printf("Warning: Failed to prune mailbox openings: %v", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d old mailbox openings", n)
+		}
+	}
+
+	l := &Log{store: st, timezone: timezone}
+	// Remember today's delivery across restarts so it isn't announced twice
+	if events, err := st.RecentEvents(eventType, 1); err == nil && len(events) > 0 {
+		l.lastDate = events[0].Time.In(timezone).Format(dateLayout)
+	}
+	return l
+}
+
+// OnDelivery registers a callback invoked for the first opening of each day
+func (l *Log) OnDelivery(fn func(o Opening)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.onDelivery = fn
+}
+
+// Record stores an opening. Time defaults to now.
+func (l *Log) Record(t time.Time) (Opening, error) {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	date := t.In(l.timezone).Format(dateLayout)
+
+	l.mu.Lock()
+	o := Opening{Time: t, Delivery: date != l.lastDate}
+	if _, err := l.store.AppendEvent(t, eventType, "", o); err != nil {
+		l.mu.Unlock()
+		return Opening{}, fmt.Errorf("failed to record mailbox opening: %w", err)
+	}
+	l.lastDate = date
+	fn := l.onDelivery
+	l.mu.Unlock()
+
+	if fn != nil && o.Delivery {
+		fn(o)
+	}
+	return o, nil
+}
+
+// History returns the days with openings since the given time, newest first
+func (l *Log) History(since time.Time) ([]Day, error) {
+	events, err := l.store.Events(eventType, since, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load mailbox openings: %w", err)
+	}
+
+	var days []*Day
+	for _, e := range events {
+		var o Opening
+		if err := json.Unmarshal(e.Data, &o); err != nil {
+			continue
+		}
+		date := o.Time.In(l.timezone).Format(dateLayout)
+		if len(days) == 0 || days[len(days)-1].Date != date {
+			days = append(days, &Day{Date: date, Delivered: o.Time})
+		}
+		day := days[len(days)-1]
+		day.Openings = append(day.Openings, o.Time)
+	}
+
+	result := make([]Day, 0, len(days))
+	for i := len(days) - 1; i >= 0; i-- {
+		result = append(result, *days[i])
+	}
+	return result, nil
+}
@@ -0,0 +1,226 @@
+package manual
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Section is one topic of the house manual, e.g. "Wi-Fi" or "Emergency contacts"
+type Section struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Body      string    `json:"body"`
+	Order     int       `json:"order"` // Lower first
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// GuestMode is on while a babysitter or house guest is in charge. The tablets
+// show the manual while it's on.
+type GuestMode struct {
+	Active bool       `json:"active"`
+	Since  *time.Time `json:"since,omitempty"`
+	Until  *time.Time `json:"until,omitempty"` // Turns itself off; nil = until turned off
+	Source string     `json:"source,omitempty"`
+}
+
+// Manual is guest mode and the sections in order
+type Manual struct {
+	GuestMode GuestMode `json:"guestMode"`
+	Sections  []Section `json:"sections"`
+}
+
+// Manager keeps the house manual
+type Manager struct {
+	mu       sync.RWMutex
+	doc      *store.Doc
+	manual   Manual
+	onChange func()
+}
+
+// NewManager loads the manual
+func NewManager(doc *store.Doc) *Manager {
+	m := &Manager{doc: doc, manual: Manual{Sections: []Section{}}}
+	if _, err := doc.Load(&m.manual); err != nil {
+		log.Printf("Warning: Failed to load house manual: %v", err)
+	}
+	return m
+}
+
+// OnChange registers a callback invoked after the manual or guest mode changes
+func (m *Manager) OnChange(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// Get returns the manual with guest mode as of now
+func (m *Manager) Get() Manual {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := Manual{GuestMode: m.guestMode(time.Now()), Sections: make([]Section, len(m.manual.Sections))}
+	copy(result.Sections, m.manual.Sections)
+	sort.SliceStable(result.Sections, func(i, j int) bool {
+		return result.Sections[i].Order < result.Sections[j].Order
+	})
+	return result
+}
+
+// GuestMode returns whether guest mode is on now
+func (m *Manager) GuestMode() GuestMode {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.guestMode(time.Now())
+}
+
+// SetGuestMode turns guest mode on (optionally until a time) or off
+func (m *Manager) SetGuestMode(active bool, until *time.Time, source string) (GuestMode, error) {
+	now := time.Now()
+	g := GuestMode{}
+	if active {
+		if until != nil && !until.After(now) {
+			return GuestMode{}, fmt.Errorf("until must be in the future")
+		}
+		g = GuestMode{Active: true, Since: &now, Until: until, Source: source}
+	}
+
+	m.mu.Lock()
+	m.manual.GuestMode = g
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return GuestMode{}, err
+	}
+
+	m.notify()
+	return g, nil
+}
+
+// Create adds a section. Without an order it goes last.
+func (m *Manager) Create(s Section) (*Section, error) {
+	if err := validate(&s); err != nil {
+		return nil, err
+	}
+	s.ID = newID()
+	s.UpdatedAt = time.Now()
+
+	m.mu.Lock()
+	if s.Order == 0 {
+		for _, existing := range m.manual.Sections {
+			if existing.Order >= s.Order {
+				s.Order = existing.Order + 1
+			}
+		}
+	}
+	m.manual.Sections = append(m.manual.Sections, s)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &s, nil
+}
+
+// Update replaces a section's title, body and order
+func (m *Manager) Update(id string, s Section) (*Section, error) {
+	if err := validate(&s); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("section not found: %s", id)
+	}
+	s.ID = id
+	s.UpdatedAt = time.Now()
+	m.manual.Sections[idx] = s
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &s, nil
+}
+
+// Delete removes a section
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("section not found: %s", id)
+	}
+	m.manual.Sections = append(m.manual.Sections[:idx], m.manual.Sections[idx+1:]...)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.notify()
+	return nil
+}
+
+// guestMode returns guest mode at t, off once its end has passed (caller
+// must hold the lock)
+func (m *Manager) guestMode(t time.Time) GuestMode {
+	g := m.manual.GuestMode
+	if g.Active && g.Until != nil && !t.Before(*g.Until) {
+		return GuestMode{}
+	}
+	return g
+}
+
+func validate(s *Section) error {
+	s.Title = strings.TrimSpace(s.Title)
+	if s.Title == "" {
+		return fmt.Errorf("title is required")
+	}
+	return nil
+}
+
+// indexOf returns the position of a section (caller must hold the lock)
+func (m *Manager) indexOf(id string) int {
+	for i, s := range m.manual.Sections {
+		if s.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save persists the manual (caller must hold the lock)
+func (m *Manager) save() error {
+	if err := m.doc.Save(m.manual); err != nil {
+		return fmt.Errorf("failed to save house manual: %w", err)
+	}
+	return nil
+}
+
+func (m *Manager) notify() {
+	m.mu.RLock()
+	fn := m.onChange
+	m.mu.RUnlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
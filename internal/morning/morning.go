@@ -0,0 +1,406 @@
+package morning
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/daycontext"
+	"home_control/internal/store"
+)
+
+// Step kinds
+const (
+	StepWake      = "wake"      // Alarm for the member
+	StepLights    = "lights"    // Bathroom light scene
+	StepCountdown = "countdown" // Departure announcement, e.g. "bus in 10 minutes"
+)
+
+// replanInterval is how often today's plan is rebuilt from the calendar
+const replanInterval = 15 * time.Minute
+
+// Event is the part of a calendar event used for planning
+type Event struct {
+	Title      string
+	CalendarID string
+	Start      time.Time
+	AllDay     bool
+}
+
+// EventSource returns the calendar events between start and end
+type EventSource func(ctx context.Context, start, end time.Time) ([]Event, error)
+
+// Member is a household member whose morning is keyed to their first event of the day
+type Member struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Calendars []string `json:"calendars,omitempty"` // Calendar IDs whose events are theirs
+	Keywords  []string `json:"keywords,omitempty"`  // Or events whose title contains one of these
+	Earliest  string   `json:"earliest,omitempty"`  // HH:MM; earlier events are ignored (default 05:00)
+	DayKinds  []string `json:"dayKinds,omitempty"`  // Day kinds or tags (school_day, workday, ...) they're planned on; empty = any
+
+	WakeMinutes   int    `json:"wakeMinutes"`             // Wake this long before the first event
+	Alarm         string `json:"alarm,omitempty"`         // Entity turned on to wake them (script.*, switch.*, ...)
+	BathroomScene string `json:"bathroomScene,omitempty"` // hue:<scene ID> or scene.* activated at wake
+
+	DepartMinutes int    `json:"departMinutes,omitempty"` // Leave this long before the first event (0 = no countdown)
+	DepartLabel   string `json:"departLabel,omitempty"`   // e.g. "bus" (default "leaving")
+	Countdowns    []int  `json:"countdowns,omitempty"`    // Minutes before departure to announce (default 10, 5, 0)
+}
+
+// Settings are the members and how far apart their alarms are staggered
+type Settings struct {
+	Members        []Member `json:"members"`
+	StaggerMinutes int      `json:"staggerMinutes"` // Minimum gap between alarms (shared bathroom)
+}
+
+// Step is one scheduled action of today's plan
+type Step struct {
+	ID      string    `json:"id"` // <member>:<kind>[:<minutes>]
+	Member  string    `json:"member"`
+	Kind    string    `json:"kind"`
+	At      time.Time `json:"at"`
+	Message string    `json:"message,omitempty"` // Announcement text
+	Target  string    `json:"target,omitempty"`  // Alarm entity or scene
+	Done    bool      `json:"done"`
+}
+
+// MemberPlan is a member's first event and derived times for the day
+type MemberPlan struct {
+	Member     string     `json:"member"`
+	Name       string     `json:"name"`
+	FirstEvent string     `json:"firstEvent,omitempty"`
+	EventStart *time.Time `json:"eventStart,omitempty"`
+	Wake       *time.Time `json:"wake,omitempty"`
+	Depart     *time.Time `json:"depart,omitempty"`
+	Skipped    bool       `json:"skipped,omitempty"` // Today isn't one of their DayKinds
+}
+
+// Plan is the morning for one date
+type Plan struct {
+	Date    string       `json:"date"` // YYYY-MM-DD
+	Members []MemberPlan `json:"members"`
+	Steps   []Step       `json:"steps"`
+}
+
+// Routine plans each morning from the calendar and runs its steps
+type Routine struct {
+	events   EventSource
+	timezone *time.Location
+
+	mu       sync.Mutex
+	doc      *store.Doc
+	settings Settings
+	plan     Plan
+	exec     func(s Step) error
+	day      func(t time.Time) []string
+}
+
+// New loads settings from the store
+func New(doc *store.Doc, timezone *time.Location, events EventSource) *Routine {
+	if timezone == nil {
+		timezone = time.Local
+	}
+	r := &Routine{
+		events:   events,
+		timezone: timezone,
+		doc:      doc,
+		settings: Settings{Members: []Member{}, StaggerMinutes: 10},
+	}
+	if _, err := doc.Load(&r.settings); err != nil {
+		log.Printf("Warning: Failed to load morning routine: %v", err)
+	}
+	return r
+}
+
+// SetExecutor sets the function that performs steps
+func (r *Routine) SetExecutor(fn func(s Step) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exec = fn
+}
+
+// SetDaySource sets the function that reports a date's day kind and tags,
+// checked against Member.DayKinds when planning
+func (r *Routine) SetDaySource(fn func(t time.Time) []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.day = fn
+}
+
+// Settings returns the members and stagger
+func (r *Routine) Settings() Settings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.settings
+}
+
+// Update validates and saves settings, then replans today
+func (r *Routine) Update(ctx context.Context, settings Settings) error {
+	if settings.StaggerMinutes < 0 || settings.StaggerMinutes > 60 {
+		return fmt.Errorf("staggerMinutes must be between 0 and 60")
+	}
+	seen := make(map[string]bool)
+	for i := range settings.Members {
+		m := &settings.Members[i]
+		if m.ID == "" || strings.Contains(m.ID, ":") {
+			return fmt.Errorf("member ID is required and can't contain ':'")
+		}
+		if seen[m.ID] {
+			return fmt.Errorf("duplicate member %s", m.ID)
+		}
+		seen[m.ID] = true
+		if m.Name == "" {
+			m.Name = m.ID
+		}
+		if len(m.Calendars) == 0 && len(m.Keywords) == 0 {
+			return fmt.Errorf("%s needs calendars or keywords to find their events", m.Name)
+		}
+		if m.Earliest != "" {
+			if _, err := time.Parse("15:04", m.Earliest); err != nil {
+				return fmt.Errorf("%s: earliest must be HH:MM", m.Name)
+			}
+		}
+		for _, kind := range m.DayKinds {
+			if !daycontext.Valid(kind) {
+				return fmt.Errorf("%s: unknown day kind %q", m.Name, kind)
+			}
+		}
+		if m.WakeMinutes < 0 || m.WakeMinutes > 240 || m.DepartMinutes < 0 || m.DepartMinutes > 240 {
+			return fmt.Errorf("%s: wake and departure must be 0-240 minutes before the event", m.Name)
+		}
+		for _, c := range m.Countdowns {
+			if c < 0 || c > 120 {
+				return fmt.Errorf("%s: countdowns must be 0-120 minutes", m.Name)
+			}
+		}
+	}
+
+	r.mu.Lock()
+	r.settings = settings
+	err := r.doc.Save(settings)
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to save morning routine: %w", err)
+	}
+
+	r.Replan(ctx)
+	return nil
+}
+
+// Plan returns today's plan
+func (r *Routine) Plan() Plan {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	plan := r.plan
+	plan.Steps = append([]Step(nil), r.plan.Steps...)
+	return plan
+}
+
+// Replan rebuilds today's plan from the calendar. Steps that already ran stay done.
+func (r *Routine) Replan(ctx context.Context) {
+	now := time.Now().In(r.timezone)
+	r.mu.Lock()
+	settings, day := r.settings, r.day
+	r.mu.Unlock()
+
+	var tags []string
+	if day != nil {
+		tags = day(now)
+	}
+	plan, err := r.build(ctx, now, settings, tags)
+	if err != nil {
+		log.Printf("Warning: Failed to plan morning routine: %v", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.plan.Date == plan.Date {
+		done := make(map[string]bool)
+		for _, s := range r.plan.Steps {
+			done[s.ID] = s.Done
+		}
+		for i := range plan.Steps {
+			plan.Steps[i].Done = done[plan.Steps[i].ID]
+		}
+	}
+	r.plan = plan
+}
+
+// Run replans periodically and runs steps as they come due. It blocks, so
+// call it in a goroutine.
+func (r *Routine) Run() {
+	r.Replan(context.Background())
+	lastPlan := time.Now()
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		if now.Sub(lastPlan) >= replanInterval || now.In(r.timezone).Format("2006-01-02") != r.Plan().Date {
+			r.Replan(context.Background())
+			lastPlan = now
+		}
+		r.runDue(now)
+	}
+}
+
+// runDue performs steps whose time has come. Steps more than five minutes
+// overdue (e.g. after a restart) are marked done without running.
+func (r *Routine) runDue(now time.Time) {
+	r.mu.Lock()
+	var due []Step
+	for i := range r.plan.Steps {
+		s := &r.plan.Steps[i]
+		if s.Done || s.At.After(now) {
+			continue
+		}
+		s.Done = true
+		if now.Sub(s.At) <= 5*time.Minute {
+			due = append(due, *s)
+		}
+	}
+	exec := r.exec
+	r.mu.Unlock()
+
+	if exec == nil {
+		return
+	}
+	for _, s := range due {
+		if err := exec(s); err != nil {
+			log.Printf("Warning: Morning routine step %s failed: %v", s.ID, err)
+		}
+	}
+}
+
+// build plans a date from each member's first event. Members whose DayKinds
+// don't match the day's tags are skipped; nil tags skip nobody.
+func (r *Routine) build(ctx context.Context, now time.Time, settings Settings, tags []string) (Plan, error) {
+	dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, r.timezone)
+	plan := Plan{Date: dayStart.Format("2006-01-02"), Members: []MemberPlan{}, Steps: []Step{}}
+	if len(settings.Members) == 0 {
+		return plan, nil
+	}
+
+	var events []Event
+	if r.events != nil {
+		var err error
+		events, err = r.events(ctx, dayStart, dayStart.AddDate(0, 0, 1))
+		if err != nil {
+			return Plan{}, err
+		}
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Start.Before(events[j].Start) })
+
+	type wake struct {
+		plan *MemberPlan
+		m    Member
+	}
+	var wakes []wake
+	for _, m := range settings.Members {
+		mp := MemberPlan{Member: m.ID, Name: m.Name}
+		if tags != nil && !daycontext.Matches(m.DayKinds, tags) {
+			mp.Skipped = true
+		} else if ev, ok := firstEvent(m, events, dayStart); ok {
+			start := ev.Start
+			mp.FirstEvent = ev.Title
+			mp.EventStart = &start
+			if m.WakeMinutes > 0 {
+				w := start.Add(-time.Duration(m.WakeMinutes) * time.Minute)
+				mp.Wake = &w
+			}
+			if m.DepartMinutes > 0 {
+				d := start.Add(-time.Duration(m.DepartMinutes) * time.Minute)
+				mp.Depart = &d
+			}
+		}
+		plan.Members = append(plan.Members, mp)
+	}
+	for i := range plan.Members {
+		if plan.Members[i].Wake != nil {
+			wakes = append(wakes, wake{plan: &plan.Members[i], m: settings.Members[i]})
+		}
+	}
+
+	// Stagger alarms: working back from the latest, nobody wakes within the
+	// stagger of the next person
+	sort.Slice(wakes, func(i, j int) bool { return wakes[i].plan.Wake.Before(*wakes[j].plan.Wake) })
+	gap := time.Duration(settings.StaggerMinutes) * time.Minute
+	for i := len(wakes) - 2; i >= 0 && gap > 0; i-- {
+		if limit := wakes[i+1].plan.Wake.Add(-gap); wakes[i].plan.Wake.After(limit) {
+			*wakes[i].plan.Wake = limit
+		}
+	}
+
+	for i, mp := range plan.Members {
+		m := settings.Members[i]
+		if mp.Wake != nil {
+			plan.Steps = append(plan.Steps, Step{
+				ID: m.ID + ":" + StepWake, Member: m.ID, Kind: StepWake, At: *mp.Wake, Target: m.Alarm,
+				Message: fmt.Sprintf("Good morning %s", m.Name),
+			})
+			if m.BathroomScene != "" {
+				plan.Steps = append(plan.Steps, Step{
+					ID: m.ID + ":" + StepLights, Member: m.ID, Kind: StepLights, At: *mp.Wake, Target: m.BathroomScene,
+				})
+			}
+		}
+		if mp.Depart != nil {
+			label := m.DepartLabel
+			if label == "" {
+				label = "leaving"
+			}
+			countdowns := m.Countdowns
+			if len(countdowns) == 0 {
+				countdowns = []int{10, 5, 0}
+			}
+			for _, mins := range countdowns {
+				msg := fmt.Sprintf("%s, %s in %d minutes", m.Name, label, mins)
+				if mins == 0 {
+					msg = fmt.Sprintf("%s, time to go", m.Name)
+				}
+				plan.Steps = append(plan.Steps, Step{
+					ID: fmt.Sprintf("%s:%s:%d", m.ID, StepCountdown, mins), Member: m.ID, Kind: StepCountdown,
+					At: mp.Depart.Add(-time.Duration(mins) * time.Minute), Message: msg,
+				})
+			}
+		}
+	}
+	sort.SliceStable(plan.Steps, func(i, j int) bool { return plan.Steps[i].At.Before(plan.Steps[j].At) })
+	return plan, nil
+}
+
+// firstEvent returns a member's first timed event of the day after their earliest time
+func firstEvent(m Member, events []Event, dayStart time.Time) (Event, bool) {
+	earliest := dayStart.Add(5 * time.Hour)
+	if t, err := time.Parse("15:04", m.Earliest); err == nil && m.Earliest != "" {
+		earliest = dayStart.Add(time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute)
+	}
+	for _, e := range events {
+		if e.AllDay || e.Start.Before(earliest) || !e.Start.Before(dayStart.AddDate(0, 0, 1)) {
+			continue
+		}
+		if belongsTo(m, e) {
+			return e, true
+		}
+	}
+	return Event{}, false
+}
+
+func belongsTo(m Member, e Event) bool {
+	for _, c := range m.Calendars {
+		if c == e.CalendarID {
+			return true
+		}
+	}
+	title := strings.ToLower(e.Title)
+	for _, k := range m.Keywords {
+		if k != "" && strings.Contains(title, strings.ToLower(k)) {
+			return true
+		}
+	}
+	return false
+}
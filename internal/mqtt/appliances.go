@@ -0,0 +1,259 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Appliance operation states, normalized from Home Connect style values
+// ("BSH.Common.EnumType.OperationState.Run" -> "run")
+const (
+	ApplianceReady    = "ready"
+	ApplianceRun      = "run"
+	AppliancePause    = "pause"
+	ApplianceFinished = "finished"
+	ApplianceOff      = "inactive"
+)
+
+// ApplianceConfig maps an appliance to its MQTT bridge topic. State is read from
+// <topic>/state and commands are published to <topic>/set, the layout used by
+// Home Connect bridges such as hcpy; anything else can be adapted in Node-RED.
+type ApplianceConfig struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Topic string `json:"topic"` // e.g. homeconnect/coffee_maker
+}
+
+// Appliance is a configured appliance with its latest reported state
+type Appliance struct {
+	ApplianceConfig
+	Power            string                 `json:"power,omitempty"`     // on, off, standby
+	Operation        string                 `json:"operation,omitempty"` // ready, run, pause, finished, ...
+	Program          string                 `json:"program,omitempty"`   // Active or selected program
+	RemainingSeconds *int                   `json:"remainingSeconds,omitempty"`
+	Door             string                 `json:"door,omitempty"` // open, closed
+	State            map[string]interface{} `json:"state,omitempty"`
+	UpdatedAt        time.Time              `json:"updatedAt,omitempty"`
+}
+
+// ApplianceCommand is published to an appliance's set topic
+type ApplianceCommand struct {
+	Action  string                 `json:"action"` // start, stop, pause, resume, power
+	Program string                 `json:"program,omitempty"`
+	Options map[string]interface{} `json:"options,omitempty"` // Program options, e.g. {"BeanAmount": "Strong"}
+	Power   string                 `json:"power,omitempty"`   // on/off for the power action
+}
+
+// Appliances tracks appliance state reported over MQTT and sends commands
+type Appliances struct {
+	client *Client
+
+	mu         sync.RWMutex
+	appliances map[string]*Appliance
+	order      []string
+	onChange   func(a Appliance, previous string)
+}
+
+// NewAppliances subscribes to the state topic of each configured appliance
+func NewAppliances(client *Client, configs []ApplianceConfig) *Appliances {
+	a := &Appliances{
+		client:     client,
+		appliances: make(map[string]*Appliance),
+	}
+	for _, cfg := range configs {
+		cfg.Topic = strings.TrimSuffix(cfg.Topic, "/")
+		if cfg.ID == "" || cfg.Topic == "" {
+			continue
+		}
+		if cfg.Name == "" {
+			cfg.Name = displayName(cfg.ID)
+		}
+		a.appliances[cfg.ID] = &Appliance{ApplianceConfig: cfg}
+		a.order = append(a.order, cfg.ID)
+
+		id := cfg.ID
+		client.Subscribe(cfg.Topic+"/state", func(topic string, payload []byte) {
+			a.handleState(id, payload)
+		})
+	}
+	sort.Strings(a.order)
+	return a
+}
+
+// OnChange registers a callback invoked when an appliance reports state.
+// previous is the prior operation state, empty if unknown.
+func (a *Appliances) OnChange(fn func(app Appliance, previous string)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.onChange = fn
+}
+
+// List returns all appliances ordered by ID
+func (a *Appliances) List() []Appliance {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	result := make([]Appliance, 0, len(a.order))
+	for _, id := range a.order {
+		result = append(result, copyAppliance(a.appliances[id]))
+	}
+	return result
+}
+
+// Get returns an appliance by ID
+func (a *Appliances) Get(id string) (Appliance, bool) {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	app, ok := a.appliances[id]
+	if !ok {
+		return Appliance{}, false
+	}
+	return copyAppliance(app), true
+}
+
+// Send publishes a command to an appliance
+func (a *Appliances) Send(id string, cmd ApplianceCommand) error {
+	app, ok := a.Get(id)
+	if !ok {
+		return fmt.Errorf("appliance not found: %s", id)
+	}
+
+	switch cmd.Action {
+	case "start":
+		if app.Door == "open" {
+			return fmt.Errorf("%s door is open", app.Name)
+		}
+	case "stop", "pause", "resume":
+	case "power":
+		if cmd.Power != "on" && cmd.Power != "off" {
+			return fmt.Errorf("power must be on or off")
+		}
+	default:
+		return fmt.Errorf("invalid action: %s", cmd.Action)
+	}
+
+	payload, err := json.Marshal(cmd)
+	if err != nil {
+		return err
+	}
+	log.Printf("Appliance %s: %s %s", app.Name, cmd.Action, cmd.Program)
+	return a.client.Publish(app.Topic+"/set", payload, 0, false)
+}
+
+// handleState records a state report and normalizes the well-known fields
+func (a *Appliances) handleState(id string, payload []byte) {
+	var state map[string]interface{}
+	if err := json.Unmarshal(payload, &state); err != nil {
+		log.Printf("Appliance %s: Failed to parse state: %v", id, err)
+		return
+	}
+
+	a.mu.Lock()
+	app, ok := a.appliances[id]
+	if !ok {
+		a.mu.Unlock()
+		return
+	}
+	previous := app.Operation
+	if app.State == nil {
+		app.State = make(map[string]interface{})
+	}
+	// Bridges may send partial updates, so merge into the last known state
+	for k, v := range state {
+		app.State[k] = v
+	}
+	app.Power = enumValue(lookupKey(app.State, "powerstate", "power"))
+	app.Operation = enumValue(lookupKey(app.State, "operationstate", "operation", "state"))
+	app.Program = enumValue(lookupKey(app.State, "activeprogram", "selectedprogram", "program"))
+	app.Door = enumValue(lookupKey(app.State, "doorstate", "door"))
+	app.RemainingSeconds = nil
+	if v := lookupKey(app.State, "remainingprogramtime", "remaining"); v != nil {
+		if secs, ok := toSeconds(v); ok {
+			app.RemainingSeconds = &secs
+		}
+	}
+	app.UpdatedAt = time.Now()
+	updated := copyAppliance(app)
+	fn := a.onChange
+	a.mu.Unlock()
+
+	if fn != nil {
+		fn(updated, previous)
+	}
+}
+
+// lookupKey finds the first key whose last dotted segment matches one of names,
+// ignoring case and underscores ("BSH.Common.Status.OperationState", "operation_state")
+func lookupKey(state map[string]interface{}, names ...string) interface{} {
+	for _, name := range names {
+		for k, v := range state {
+			key := k
+			if i := strings.LastIndex(key, "."); i >= 0 {
+				key = key[i+1:]
+			}
+			if strings.EqualFold(strings.ReplaceAll(key, "_", ""), name) {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// enumValue reduces a Home Connect enum to its last segment, lowercased
+func enumValue(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		if i := strings.LastIndex(t, "."); i >= 0 {
+			t = t[i+1:]
+		}
+		return strings.ToLower(t)
+	case bool:
+		if t {
+			return "on"
+		}
+		return "off"
+	}
+	return ""
+}
+
+func toSeconds(v interface{}) (int, bool) {
+	switch t := v.(type) {
+	case float64:
+		return int(t), true
+	case string:
+		n, err := strconv.Atoi(t)
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// displayName turns an ID like "coffee_maker" into "Coffee Maker"
+func displayName(id string) string {
+	words := strings.Fields(strings.NewReplacer("_", " ", "-", " ").Replace(id))
+	for i, w := range words {
+		words[i] = strings.ToUpper(w[:1]) + w[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// copyAppliance copies an appliance so its state map can be handed out safely
+func copyAppliance(app *Appliance) Appliance {
+	c := *app
+	if app.State != nil {
+		c.State = make(map[string]interface{}, len(app.State))
+		for k, v := range app.State {
+			c.State[k] = v
+		}
+	}
+	if app.RemainingSeconds != nil {
+		secs := *app.RemainingSeconds
+		c.RemainingSeconds = &secs
+	}
+	return c
+}
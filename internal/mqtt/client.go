@@ -21,6 +21,9 @@ type Client struct {
 	mu              sync.RWMutex
 	connected       bool
 	customTopics    []string
+	skipDoorbell    bool
+	subs            []subscription
+	nextSubID       int
 }
 
 // Config holds MQTT connection settings
@@ -31,12 +34,14 @@ type Config struct {
 	Password       string
 	ClientID       string
 	DoorbellTopics []string // Custom doorbell topics (optional)
+	SkipDoorbell   bool     // Only use Subscribe handlers (e.g. for third-party brokers)
 }
 
 // NewClient creates a new MQTT client
 func NewClient(cfg Config) *Client {
 	c := &Client{
 		customTopics: cfg.DoorbellTopics,
+		skipDoorbell: cfg.SkipDoorbell,
 	}
 
 	opts := paho.NewClientOptions()
@@ -54,7 +59,10 @@ func NewClient(cfg Config) *Client {
 		c.mu.Lock()
 		c.connected = true
 		c.mu.Unlock()
-		c.subscribeToDoorbellTopics()
+		if !c.skipDoorbell {
+			c.subscribeToDoorbellTopics()
+		}
+		c.resubscribe()
 	})
 
 	opts.SetConnectionLostHandler(func(client paho.Client, err error) {
@@ -200,6 +208,28 @@ func (c *Client) handleDoorbellMessage(client paho.Client, msg paho.Message) {
 	}
 }
 
+// Publish sends a message and waits for the broker to accept it
+func (c *Client) Publish(topic string, payload []byte, qos byte, retain bool) error {
+	if strings.ContainsAny(topic, "+#") {
+		return fmt.Errorf("cannot publish to wildcard topic: %s", topic)
+	}
+	if qos > 2 {
+		return fmt.Errorf("invalid QoS: %d", qos)
+	}
+	if !c.IsConnected() {
+		return fmt.Errorf("MQTT not connected")
+	}
+
+	token := c.client.Publish(topic, qos, retain, payload)
+	if !token.WaitTimeout(10 * time.Second) {
+		return fmt.Errorf("publish to %s timed out", topic)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("publish to %s failed: %w", topic, err)
+	}
+	return nil
+}
+
 // IsConnected returns the connection status
 func (c *Client) IsConnected() bool {
 	c.mu.RLock()
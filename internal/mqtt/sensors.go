@@ -0,0 +1,374 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// SensorEntityPrefix prefixes the entity ID of every virtual MQTT sensor
+const SensorEntityPrefix = "mqtt."
+
+// SensorConfig maps an MQTT topic to a virtual sensor
+type SensorConfig struct {
+	ID    string `json:"id"`             // Slug, derived from the name when empty
+	Name  string `json:"name"`           // Display name, e.g. "Mailbox"
+	Topic string `json:"topic"`          // Topic to read, e.g. zigbee2mqtt/mailbox
+	Path  string `json:"path,omitempty"` // Dot path into a JSON payload, e.g. "contact" or "sensors.0.temp"; empty uses the raw payload
+	Unit  string `json:"unit,omitempty"` // e.g. °C, %
+}
+
+// Sensor is a configured virtual sensor with its latest reading
+type Sensor struct {
+	SensorConfig
+	EntityID  string    `json:"entityId"`
+	State     string    `json:"state"`           // Empty until the first message arrives
+	Value     *float64  `json:"value,omitempty"` // Numeric reading (booleans are 1/0)
+	UpdatedAt time.Time `json:"updatedAt,omitempty"`
+}
+
+// SensorBridge turns MQTT topics into virtual sensors
+type SensorBridge struct {
+	mu       sync.RWMutex
+	client   *Client
+	doc      *store.Doc
+	configs  []SensorConfig
+	readings map[string]Sensor // sensor ID -> latest reading
+	subs     map[string]int    // topic -> subscription ID
+	onChange func(s Sensor, previous string)
+}
+
+// NewSensorBridge loads sensor mappings from the store and subscribes to their topics
+func NewSensorBridge(client *Client, doc *store.Doc) *SensorBridge {
+	b := &SensorBridge{
+		client:   client,
+		doc:      doc,
+		readings: make(map[string]Sensor),
+		subs:     make(map[string]int),
+	}
+	if _, err := doc.Load(&b.configs); err != nil {
+		log.Printf("Warning: Failed to load MQTT sensors: %v", err)
+	}
+	b.syncSubscriptions()
+	return b
+}
+
+// OnChange registers a callback invoked when a sensor's state changes.
+// previous is empty for the first reading.
+func (b *SensorBridge) OnChange(fn func(s Sensor, previous string)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.onChange = fn
+}
+
+// Sensors returns all configured sensors with their latest readings
+func (b *SensorBridge) Sensors() []Sensor {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	result := make([]Sensor, 0, len(b.configs))
+	for _, cfg := range b.configs {
+		result = append(result, b.sensor(cfg))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Get returns a sensor by ID or entity ID
+func (b *SensorBridge) Get(id string) (Sensor, bool) {
+	id = strings.TrimPrefix(id, SensorEntityPrefix)
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	idx := b.indexOf(id)
+	if idx < 0 {
+		return Sensor{}, false
+	}
+	return b.sensor(b.configs[idx]), true
+}
+
+// Create adds a sensor mapping
+func (b *SensorBridge) Create(cfg SensorConfig) (*SensorConfig, error) {
+	if err := validateSensor(&cfg); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	if cfg.ID == "" {
+		cfg.ID = b.uniqueID(slugify(cfg.Name))
+	} else if b.indexOf(cfg.ID) >= 0 {
+		b.mu.Unlock()
+		return nil, fmt.Errorf("sensor already exists: %s", cfg.ID)
+	}
+	b.configs = append(b.configs, cfg)
+	err := b.save()
+	b.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	b.syncSubscriptions()
+	return &cfg, nil
+}
+
+// Update replaces a sensor mapping, keeping its ID
+func (b *SensorBridge) Update(id string, cfg SensorConfig) (*SensorConfig, error) {
+	if err := validateSensor(&cfg); err != nil {
+		return nil, err
+	}
+	cfg.ID = id
+
+	b.mu.Lock()
+	idx := b.indexOf(id)
+	if idx < 0 {
+		b.mu.Unlock()
+		return nil, fmt.Errorf("sensor not found: %s", id)
+	}
+	prev := b.configs[idx]
+	b.configs[idx] = cfg
+	if prev.Topic != cfg.Topic || prev.Path != cfg.Path {
+		delete(b.readings, id)
+	}
+	err := b.save()
+	b.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	b.syncSubscriptions()
+	return &cfg, nil
+}
+
+// Delete removes a sensor mapping
+func (b *SensorBridge) Delete(id string) error {
+	b.mu.Lock()
+	idx := b.indexOf(id)
+	if idx < 0 {
+		b.mu.Unlock()
+		return fmt.Errorf("sensor not found: %s", id)
+	}
+	b.configs = append(b.configs[:idx], b.configs[idx+1:]...)
+	delete(b.readings, id)
+	err := b.save()
+	b.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	b.syncSubscriptions()
+	return nil
+}
+
+// syncSubscriptions subscribes to mapped topics and drops topics no longer used
+func (b *SensorBridge) syncSubscriptions() {
+	b.mu.Lock()
+	wanted := make(map[string]bool)
+	for _, cfg := range b.configs {
+		wanted[cfg.Topic] = true
+	}
+	var drop []int
+	for topic, id := range b.subs {
+		if !wanted[topic] {
+			drop = append(drop, id)
+			delete(b.subs, topic)
+		}
+	}
+	var add []string
+	for topic := range wanted {
+		if _, ok := b.subs[topic]; !ok {
+			add = append(add, topic)
+			b.subs[topic] = 0
+		}
+	}
+	b.mu.Unlock()
+
+	// Subscribe outside the lock: retained messages are delivered straight away
+	for _, id := range drop {
+		b.client.Unsubscribe(id)
+	}
+	for _, topic := range add {
+		id := b.client.Subscribe(topic, b.handleMessage)
+		b.mu.Lock()
+		_, stillWanted := b.subs[topic]
+		if stillWanted {
+			b.subs[topic] = id
+		}
+		b.mu.Unlock()
+		if !stillWanted {
+			b.client.Unsubscribe(id)
+		}
+	}
+}
+
+// handleMessage updates every sensor reading from the message's topic
+func (b *SensorBridge) handleMessage(topic string, payload []byte) {
+	type change struct {
+		sensor   Sensor
+		previous string
+	}
+	var changes []change
+
+	b.mu.Lock()
+	for _, cfg := range b.configs {
+		if !TopicMatches(cfg.Topic, topic) {
+			continue
+		}
+		state, value, ok := extractReading(payload, cfg.Path)
+		if !ok {
+			continue
+		}
+		prev := b.readings[cfg.ID]
+		s := Sensor{
+			SensorConfig: cfg,
+			EntityID:     SensorEntityPrefix + cfg.ID,
+			State:        state,
+			Value:        value,
+			UpdatedAt:    time.Now(),
+		}
+		b.readings[cfg.ID] = s
+		if prev.State != state {
+			changes = append(changes, change{sensor: s, previous: prev.State})
+		}
+	}
+	fn := b.onChange
+	b.mu.Unlock()
+
+	if fn == nil {
+		return
+	}
+	for _, c := range changes {
+		fn(c.sensor, c.previous)
+	}
+}
+
+// sensor combines a mapping with its latest reading (caller must hold the lock)
+func (b *SensorBridge) sensor(cfg SensorConfig) Sensor {
+	s := b.readings[cfg.ID]
+	s.SensorConfig = cfg
+	s.EntityID = SensorEntityPrefix + cfg.ID
+	return s
+}
+
+// indexOf finds a mapping by ID (caller must hold the lock)
+func (b *SensorBridge) indexOf(id string) int {
+	for i, cfg := range b.configs {
+		if cfg.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// uniqueID appends a counter to base until no mapping uses it (caller must hold the lock)
+func (b *SensorBridge) uniqueID(base string) string {
+	id := base
+	for n := 2; b.indexOf(id) >= 0; n++ {
+		id = fmt.Sprintf("%s_%d", base, n)
+	}
+	return id
+}
+
+// save persists mappings (caller must hold the lock)
+func (b *SensorBridge) save() error {
+	if err := b.doc.Save(b.configs); err != nil {
+		return fmt.Errorf("failed to save MQTT sensors: %w", err)
+	}
+	return nil
+}
+
+func validateSensor(cfg *SensorConfig) error {
+	cfg.Name = strings.TrimSpace(cfg.Name)
+	cfg.Topic = strings.TrimSpace(cfg.Topic)
+	if cfg.Name == "" || cfg.Topic == "" {
+		return fmt.Errorf("sensor requires name and topic")
+	}
+	if cfg.ID != "" && cfg.ID != slugify(cfg.ID) {
+		return fmt.Errorf("invalid sensor ID %q (use lowercase letters, digits and _)", cfg.ID)
+	}
+	return nil
+}
+
+// slugify turns a display name into a sensor ID, e.g. "Garage Door" -> "garage_door"
+func slugify(name string) string {
+	var sb strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(name) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			sb.WriteRune(r)
+			underscore = false
+		} else if !underscore && sb.Len() > 0 {
+			sb.WriteByte('_')
+			underscore = true
+		}
+	}
+	if slug := strings.TrimSuffix(sb.String(), "_"); slug != "" {
+		return slug
+	}
+	return "sensor"
+}
+
+// extractReading reads the value at path from a payload. Numbers and booleans
+// also yield a numeric value; booleans become "on"/"off" like binary sensors.
+func extractReading(payload []byte, path string) (string, *float64, bool) {
+	raw := strings.TrimSpace(string(payload))
+	if path == "" {
+		if v, err := strconv.ParseFloat(raw, 64); err == nil {
+			return raw, &v, true
+		}
+		return raw, nil, raw != ""
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(payload, &data); err != nil {
+		return "", nil, false
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := data.(type) {
+		case map[string]interface{}:
+			v, ok := node[key]
+			if !ok {
+				return "", nil, false
+			}
+			data = v
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return "", nil, false
+			}
+			data = node[i]
+		default:
+			return "", nil, false
+		}
+	}
+
+	switch v := data.(type) {
+	case nil:
+		return "", nil, false
+	case string:
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return v, &f, true
+		}
+		return v, nil, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), &v, true
+	case bool:
+		f := 0.0
+		state := "off"
+		if v {
+			f, state = 1, "on"
+		}
+		return state, &f, true
+	default:
+		encoded, _ := json.Marshal(v)
+		return string(encoded), nil, true
+	}
+}
@@ -0,0 +1,126 @@
+package mqtt
+
+import (
+	"log"
+	"strings"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// MessageHandler is called for each message on a subscribed topic
+type MessageHandler func(topic string, payload []byte)
+
+type subscription struct {
+	id      int
+	filter  string
+	handler MessageHandler
+}
+
+// Subscribe registers a handler for a topic filter (MQTT wildcards allowed).
+// Subscriptions survive reconnects. Returns an ID for Unsubscribe.
+func (c *Client) Subscribe(filter string, handler MessageHandler) int {
+	c.mu.Lock()
+	c.nextSubID++
+	id := c.nextSubID
+	alreadySubscribed := c.filterInUse(filter)
+	c.subs = append(c.subs, subscription{id: id, filter: filter, handler: handler})
+	connected := c.connected
+	c.mu.Unlock()
+
+	if connected && !alreadySubscribed {
+		c.subscribeFilter(filter)
+	}
+	return id
+}
+
+// Unsubscribe removes a handler registered with Subscribe
+func (c *Client) Unsubscribe(id int) {
+	c.mu.Lock()
+	var filter string
+	for i, sub := range c.subs {
+		if sub.id == id {
+			filter = sub.filter
+			c.subs = append(c.subs[:i], c.subs[i+1:]...)
+			break
+		}
+	}
+	stillUsed := filter == "" || c.filterInUse(filter)
+	connected := c.connected
+	c.mu.Unlock()
+
+	if connected && !stillUsed {
+		c.client.Unsubscribe(filter)
+	}
+}
+
+// resubscribe restores all handler subscriptions after (re)connecting
+func (c *Client) resubscribe() {
+	c.mu.RLock()
+	seen := make(map[string]bool)
+	var filters []string
+	for _, sub := range c.subs {
+		if !seen[sub.filter] {
+			seen[sub.filter] = true
+			filters = append(filters, sub.filter)
+		}
+	}
+	c.mu.RUnlock()
+
+	for _, filter := range filters {
+		c.subscribeFilter(filter)
+	}
+}
+
+func (c *Client) subscribeFilter(filter string) {
+	token := c.client.Subscribe(filter, 1, c.dispatch)
+	token.Wait()
+	if err := token.Error(); err != nil {
+		log.Printf("Failed to subscribe to %s: %v", filter, err)
+	} else {
+		log.Printf("Subscribed to MQTT topic: %s", filter)
+	}
+}
+
+// dispatch delivers a message to every handler whose filter matches its topic
+func (c *Client) dispatch(client paho.Client, msg paho.Message) {
+	c.mu.RLock()
+	var handlers []MessageHandler
+	for _, sub := range c.subs {
+		if TopicMatches(sub.filter, msg.Topic()) {
+			handlers = append(handlers, sub.handler)
+		}
+	}
+	c.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(msg.Topic(), msg.Payload())
+	}
+}
+
+// filterInUse reports whether any subscription uses filter (caller must hold the lock)
+func (c *Client) filterInUse(filter string) bool {
+	for _, sub := range c.subs {
+		if sub.filter == filter {
+			return true
+		}
+	}
+	return false
+}
+
+// TopicMatches reports whether topic matches an MQTT filter with + and # wildcards
+func TopicMatches(filter, topic string) bool {
+	fp := strings.Split(filter, "/")
+	tp := strings.Split(topic, "/")
+	for i, f := range fp {
+		if f == "#" {
+			return true
+		}
+		if i >= len(tp) {
+			return false
+		}
+		if f != "+" && f != tp[i] {
+			return false
+		}
+	}
+	return len(fp) == len(tp)
+}
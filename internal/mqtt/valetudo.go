@@ -0,0 +1,128 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"home_control/internal/vacuum"
+)
+
+// Valetudo tracks the room segments of Valetudo robots and sends segment and
+// zone cleaning commands over their MQTT interface. Robots are keyed by the
+// vacuum.* entity that represents them in Home Assistant.
+type Valetudo struct {
+	client *Client
+
+	mu       sync.RWMutex
+	topics   map[string]string // entity ID -> base topic, e.g. valetudo/RobotName
+	segments map[string][]vacuum.Segment
+}
+
+// NewValetudo subscribes to the segment list of each robot
+func NewValetudo(client *Client, robots map[string]string) *Valetudo {
+	v := &Valetudo{
+		client:   client,
+		topics:   make(map[string]string),
+		segments: make(map[string][]vacuum.Segment),
+	}
+	for entityID, topic := range robots {
+		topic = strings.TrimSuffix(topic, "/")
+		v.topics[entityID] = topic
+
+		id := entityID
+		client.Subscribe(topic+"/MapData/segments", func(topic string, payload []byte) {
+			v.handleSegments(id, payload)
+		})
+	}
+	return v
+}
+
+// Has reports whether a vacuum entity is a configured Valetudo robot
+func (v *Valetudo) Has(entityID string) bool {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	_, ok := v.topics[entityID]
+	return ok
+}
+
+// Segments returns a robot's rooms ordered by name
+func (v *Valetudo) Segments(entityID string) []vacuum.Segment {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	return append([]vacuum.Segment{}, v.segments[entityID]...)
+}
+
+// CleanSegments starts cleaning the given rooms in order
+func (v *Valetudo) CleanSegments(entityID string, segments []string, repeats int) error {
+	return v.publish(entityID, "/MapSegmentationCapability/clean/set", map[string]interface{}{
+		"segment_ids": segments,
+		"iterations":  repeats,
+		"customOrder": true,
+	})
+}
+
+// CleanZones starts cleaning rectangular zones
+func (v *Valetudo) CleanZones(entityID string, zones []vacuum.Zone, repeats int) error {
+	type point struct {
+		X int `json:"x"`
+		Y int `json:"y"`
+	}
+	list := make([]interface{}, 0, len(zones))
+	for _, z := range zones {
+		list = append(list, map[string]interface{}{
+			"points": map[string]point{
+				"pA": {z.X1, z.Y1},
+				"pB": {z.X2, z.Y1},
+				"pC": {z.X2, z.Y2},
+				"pD": {z.X1, z.Y2},
+			},
+		})
+	}
+	return v.publish(entityID, "/ZoneCleaningCapability/start/set", map[string]interface{}{
+		"zones":      list,
+		"iterations": repeats,
+	})
+}
+
+func (v *Valetudo) publish(entityID, suffix string, body interface{}) error {
+	v.mu.RLock()
+	topic, ok := v.topics[entityID]
+	v.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("not a Valetudo robot: %s", entityID)
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	return v.client.Publish(topic+suffix, payload, 0, false)
+}
+
+// handleSegments parses the retained {"id": "name"} segment map
+func (v *Valetudo) handleSegments(entityID string, payload []byte) {
+	var raw map[string]string
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		log.Printf("Valetudo %s: Failed to parse segments: %v", entityID, err)
+		return
+	}
+
+	segments := make([]vacuum.Segment, 0, len(raw))
+	for id, name := range raw {
+		if name == "" {
+			name = "Room " + id
+		}
+		segments = append(segments, vacuum.Segment{ID: id, Name: name})
+	}
+	sort.Slice(segments, func(i, j int) bool {
+		return segments[i].Name < segments[j].Name
+	})
+
+	v.mu.Lock()
+	v.segments[entityID] = segments
+	v.mu.Unlock()
+}
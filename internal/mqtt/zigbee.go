@@ -0,0 +1,337 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultZigbeeBaseTopic is Zigbee2MQTT's default base topic
+const DefaultZigbeeBaseTopic = "zigbee2mqtt"
+
+// ZigbeeDevice is a device from the Zigbee2MQTT bridge registry with its last state
+type ZigbeeDevice struct {
+	IEEEAddress   string                 `json:"ieeeAddress"`
+	FriendlyName  string                 `json:"friendlyName"`
+	Type          string                 `json:"type"` // Coordinator, Router, EndDevice
+	Model         string                 `json:"model,omitempty"`
+	Vendor        string                 `json:"vendor,omitempty"`
+	Description   string                 `json:"description,omitempty"`
+	PowerSource   string                 `json:"powerSource,omitempty"`
+	Supported     bool                   `json:"supported"`
+	OnOff         bool                   `json:"onOff"`
+	Brightness    bool                   `json:"brightness"`
+	BrightnessMax int                    `json:"brightnessMax,omitempty"`
+	Color         bool                   `json:"color"`
+	ColorTemp     bool                   `json:"colorTemp"`
+	Available     string                 `json:"available,omitempty"` // online/offline when availability is enabled
+	State         map[string]interface{} `json:"state,omitempty"`
+	LastSeen      time.Time              `json:"lastSeen,omitempty"`
+}
+
+// ZigbeeColor sets a color by hex ("#ff8800") or CIE xy
+type ZigbeeColor struct {
+	Hex string   `json:"hex,omitempty"`
+	X   *float64 `json:"x,omitempty"`
+	Y   *float64 `json:"y,omitempty"`
+}
+
+// ZigbeeCommand is a set command for a device. Nil/empty fields are left unchanged.
+type ZigbeeCommand struct {
+	State      string       `json:"state,omitempty"`      // ON, OFF, TOGGLE
+	Brightness *int         `json:"brightness,omitempty"` // 0..BrightnessMax (usually 254)
+	Color      *ZigbeeColor `json:"color,omitempty"`
+	ColorTemp  *int         `json:"colorTemp,omitempty"` // Mireds
+	Transition *float64     `json:"transition,omitempty"`
+}
+
+// Zigbee keeps a registry of Zigbee2MQTT devices and sends them commands
+type Zigbee struct {
+	client *Client
+	base   string
+
+	mu       sync.RWMutex
+	devices  map[string]*ZigbeeDevice // friendly name -> device
+	onChange func(d ZigbeeDevice, previous string)
+}
+
+// NewZigbee subscribes to the bridge device list and device state topics under baseTopic
+func NewZigbee(client *Client, baseTopic string) *Zigbee {
+	if baseTopic == "" {
+		baseTopic = DefaultZigbeeBaseTopic
+	}
+	z := &Zigbee{
+		client:  client,
+		base:    strings.TrimSuffix(baseTopic, "/"),
+		devices: make(map[string]*ZigbeeDevice),
+	}
+	client.Subscribe(z.base+"/bridge/devices", z.handleDevices)
+	client.Subscribe(z.base+"/#", z.handleState)
+	return z
+}
+
+// OnChange registers a callback invoked when a device reports new state.
+// previous is the device's prior on/off state ("ON"/"OFF"), empty if unknown.
+func (z *Zigbee) OnChange(fn func(d ZigbeeDevice, previous string)) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.onChange = fn
+}
+
+// Devices returns all devices except the coordinator, ordered by name
+func (z *Zigbee) Devices() []ZigbeeDevice {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+
+	result := make([]ZigbeeDevice, 0, len(z.devices))
+	for _, d := range z.devices {
+		if d.Type == "Coordinator" {
+			continue
+		}
+		result = append(result, copyDevice(d))
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].FriendlyName < result[j].FriendlyName
+	})
+	return result
+}
+
+// Device finds a device by friendly name or IEEE address
+func (z *Zigbee) Device(id string) (ZigbeeDevice, bool) {
+	z.mu.RLock()
+	defer z.mu.RUnlock()
+	d := z.find(id)
+	if d == nil {
+		return ZigbeeDevice{}, false
+	}
+	return copyDevice(d), true
+}
+
+// Set sends a command to a device, checking it against the device's capabilities
+func (z *Zigbee) Set(id string, cmd ZigbeeCommand) error {
+	z.mu.RLock()
+	d := z.find(id)
+	var device ZigbeeDevice
+	if d != nil {
+		device = *d
+	}
+	z.mu.RUnlock()
+	if d == nil {
+		return fmt.Errorf("zigbee device not found: %s", id)
+	}
+
+	payload := make(map[string]interface{})
+	if cmd.State != "" {
+		state := strings.ToUpper(cmd.State)
+		if state != "ON" && state != "OFF" && state != "TOGGLE" {
+			return fmt.Errorf("invalid state: %s", cmd.State)
+		}
+		if !device.OnOff {
+			return fmt.Errorf("%s does not support on/off", device.FriendlyName)
+		}
+		payload["state"] = state
+	}
+	if cmd.Brightness != nil {
+		if !device.Brightness {
+			return fmt.Errorf("%s does not support brightness", device.FriendlyName)
+		}
+		max := device.BrightnessMax
+		if max == 0 {
+			max = 254
+		}
+		if *cmd.Brightness < 0 || *cmd.Brightness > max {
+			return fmt.Errorf("brightness must be between 0 and %d", max)
+		}
+		payload["brightness"] = *cmd.Brightness
+	}
+	if cmd.Color != nil {
+		if !device.Color {
+			return fmt.Errorf("%s does not support color", device.FriendlyName)
+		}
+		switch {
+		case cmd.Color.Hex != "":
+			payload["color"] = map[string]interface{}{"hex": cmd.Color.Hex}
+		case cmd.Color.X != nil && cmd.Color.Y != nil:
+			payload["color"] = map[string]interface{}{"x": *cmd.Color.X, "y": *cmd.Color.Y}
+		default:
+			return fmt.Errorf("color requires hex or x/y")
+		}
+	}
+	if cmd.ColorTemp != nil {
+		if !device.ColorTemp {
+			return fmt.Errorf("%s does not support color temperature", device.FriendlyName)
+		}
+		payload["color_temp"] = *cmd.ColorTemp
+	}
+	if len(payload) == 0 {
+		return fmt.Errorf("command is empty")
+	}
+	if cmd.Transition != nil {
+		payload["transition"] = *cmd.Transition
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return z.client.Publish(z.base+"/"+device.FriendlyName+"/set", encoded, 0, false)
+}
+
+// bridgeDevice is an entry of the zigbee2mqtt/bridge/devices list
+type bridgeDevice struct {
+	IEEEAddress  string `json:"ieee_address"`
+	FriendlyName string `json:"friendly_name"`
+	Type         string `json:"type"`
+	Supported    bool   `json:"supported"`
+	Disabled     bool   `json:"disabled"`
+	PowerSource  string `json:"power_source"`
+	Definition   *struct {
+		Model       string   `json:"model"`
+		Vendor      string   `json:"vendor"`
+		Description string   `json:"description"`
+		Exposes     []expose `json:"exposes"`
+	} `json:"definition"`
+}
+
+// expose describes a device capability; composite types (light, switch) nest features
+type expose struct {
+	Type     string   `json:"type"`
+	Name     string   `json:"name"`
+	Property string   `json:"property"`
+	ValueMax *float64 `json:"value_max"`
+	Features []expose `json:"features"`
+}
+
+// handleDevices rebuilds the registry from the retained bridge device list,
+// keeping the last known state of existing devices
+func (z *Zigbee) handleDevices(topic string, payload []byte) {
+	var list []bridgeDevice
+	if err := json.Unmarshal(payload, &list); err != nil {
+		log.Printf("Zigbee2MQTT: Failed to parse device list: %v", err)
+		return
+	}
+
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	devices := make(map[string]*ZigbeeDevice, len(list))
+	for _, bd := range list {
+		if bd.Disabled || bd.FriendlyName == "" {
+			continue
+		}
+		d := &ZigbeeDevice{
+			IEEEAddress:  bd.IEEEAddress,
+			FriendlyName: bd.FriendlyName,
+			Type:         bd.Type,
+			Supported:    bd.Supported,
+			PowerSource:  bd.PowerSource,
+		}
+		if bd.Definition != nil {
+			d.Model = bd.Definition.Model
+			d.Vendor = bd.Definition.Vendor
+			d.Description = bd.Definition.Description
+			applyExposes(d, bd.Definition.Exposes)
+		}
+		if prev, ok := z.devices[bd.FriendlyName]; ok {
+			d.State = prev.State
+			d.Available = prev.Available
+			d.LastSeen = prev.LastSeen
+		}
+		devices[bd.FriendlyName] = d
+	}
+	z.devices = devices
+	log.Printf("Zigbee2MQTT: %d devices", len(devices))
+}
+
+// applyExposes derives controllable capabilities from a device's exposes
+func applyExposes(d *ZigbeeDevice, exposes []expose) {
+	for _, e := range exposes {
+		switch {
+		case e.Property == "state" && e.Type == "binary":
+			d.OnOff = true
+		case e.Property == "brightness":
+			d.Brightness = true
+			if e.ValueMax != nil {
+				d.BrightnessMax = int(*e.ValueMax)
+			}
+		case e.Property == "color" || e.Name == "color_xy" || e.Name == "color_hs":
+			d.Color = true
+		case e.Property == "color_temp":
+			d.ColorTemp = true
+		}
+		if len(e.Features) > 0 && e.Type != "composite" {
+			applyExposes(d, e.Features)
+		}
+	}
+}
+
+// handleState records device state (<base>/<name>) and availability (<base>/<name>/availability)
+func (z *Zigbee) handleState(topic string, payload []byte) {
+	name := strings.TrimPrefix(topic, z.base+"/")
+	if strings.HasPrefix(name, "bridge/") || len(payload) == 0 {
+		return
+	}
+
+	z.mu.Lock()
+	if d, ok := z.devices[strings.TrimSuffix(name, "/availability")]; ok && strings.HasSuffix(name, "/availability") {
+		// Payload is {"state":"online"} (or plain "online" on older versions)
+		var avail struct {
+			State string `json:"state"`
+		}
+		if err := json.Unmarshal(payload, &avail); err == nil {
+			d.Available = avail.State
+		} else {
+			d.Available = strings.TrimSpace(string(payload))
+		}
+		z.mu.Unlock()
+		return
+	}
+	d, ok := z.devices[name]
+	if !ok {
+		z.mu.Unlock()
+		return // set/get commands or topics of unknown devices
+	}
+	var state map[string]interface{}
+	if err := json.Unmarshal(payload, &state); err != nil {
+		z.mu.Unlock()
+		return
+	}
+	previous, _ := d.State["state"].(string)
+	d.State = state
+	d.LastSeen = time.Now()
+	updated := copyDevice(d)
+	fn := z.onChange
+	z.mu.Unlock()
+
+	if fn != nil {
+		fn(updated, previous)
+	}
+}
+
+// find looks a device up by friendly name or IEEE address (caller must hold the lock)
+func (z *Zigbee) find(id string) *ZigbeeDevice {
+	if d, ok := z.devices[id]; ok {
+		return d
+	}
+	for _, d := range z.devices {
+		if strings.EqualFold(d.IEEEAddress, id) {
+			return d
+		}
+	}
+	return nil
+}
+
+// copyDevice copies a device so its state map can be handed out safely
+func copyDevice(d *ZigbeeDevice) ZigbeeDevice {
+	c := *d
+	if d.State != nil {
+		c.State = make(map[string]interface{}, len(d.State))
+		for k, v := range d.State {
+			c.State[k] = v
+		}
+	}
+	return c
+}
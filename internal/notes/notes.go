@@ -0,0 +1,226 @@
+package notes
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Built-in lists
+const (
+	ListShopping = "shopping"
+	ListNotes    = "notes"
+)
+
+// Item is a single shopping list entry or quick note
+type Item struct {
+	ID        string    `json:"id"`
+	List      string    `json:"list"`
+	Text      string    `json:"text"`
+	Done      bool      `json:"done"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Store keeps local lists, independent of Google Tasks
+type Store struct {
+	mu       sync.RWMutex
+	doc      *store.Doc
+	items    []Item
+	onChange func(list string)
+}
+
+// NewStore loads items from the store
+func NewStore(doc *store.Doc) *Store {
+	s := &Store{doc: doc}
+
+	if _, err := doc.Load(&s.items); err != nil {
+		log.Printf("Warning: Failed to load notes: %v", err)
+	}
+	return s
+}
+
+// OnChange registers a callback invoked after a list is modified
+func (s *Store) OnChange(fn func(list string)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onChange = fn
+}
+
+// Lists returns the names of all lists that have items, plus the built-in lists
+func (s *Store) Lists() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	seen := map[string]bool{ListShopping: true, ListNotes: true}
+	lists := []string{ListShopping, ListNotes}
+	for _, item := range s.items {
+		if !seen[item.List] {
+			seen[item.List] = true
+			lists = append(lists, item.List)
+		}
+	}
+	return lists
+}
+
+// List returns items in a list: open items first, then done, each oldest first
+func (s *Store) List(list string) []Item {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := []Item{}
+	for _, item := range s.items {
+		if item.List == list {
+			result = append(result, item)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Done != result[j].Done {
+			return !result[i].Done
+		}
+		return result[i].CreatedAt.Before(result[j].CreatedAt)
+	})
+	return result
+}
+
+// Add creates a new item in a list
+func (s *Store) Add(list, text string) (*Item, error) {
+	if text == "" {
+		return nil, fmt.Errorf("text is required")
+	}
+	if list == "" {
+		list = ListShopping
+	}
+
+	now := time.Now()
+	item := Item{
+		ID:        newID(),
+		List:      list,
+		Text:      text,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	s.mu.Lock()
+	s.items = append(s.items, item)
+	err := s.save()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	s.notify(list)
+	return &item, nil
+}
+
+// Update changes the text and/or done state of an item (nil = unchanged)
+func (s *Store) Update(id string, text *string, done *bool) (*Item, error) {
+	s.mu.Lock()
+	idx := s.indexOf(id)
+	if idx < 0 {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("item not found: %s", id)
+	}
+	item := &s.items[idx]
+	if text != nil {
+		if *text == "" {
+			s.mu.Unlock()
+			return nil, fmt.Errorf("text cannot be empty")
+		}
+		item.Text = *text
+	}
+	if done != nil {
+		item.Done = *done
+	}
+	item.UpdatedAt = time.Now()
+	updated := *item
+	err := s.save()
+	s.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	s.notify(updated.List)
+	return &updated, nil
+}
+
+// Delete removes an item
+func (s *Store) Delete(id string) error {
+	s.mu.Lock()
+	idx := s.indexOf(id)
+	if idx < 0 {
+		s.mu.Unlock()
+		return fmt.Errorf("item not found: %s", id)
+	}
+	list := s.items[idx].List
+	s.items = append(s.items[:idx], s.items[idx+1:]...)
+	err := s.save()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.notify(list)
+	return nil
+}
+
+// ClearDone removes all done items from a list
+func (s *Store) ClearDone(list string) error {
+	s.mu.Lock()
+	kept := s.items[:0]
+	for _, item := range s.items {
+		if item.List == list && item.Done {
+			continue
+		}
+		kept = append(kept, item)
+	}
+	s.items = kept
+	err := s.save()
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	s.notify(list)
+	return nil
+}
+
+// indexOf finds an item by ID (caller must hold the lock)
+func (s *Store) indexOf(id string) int {
+	for i, item := range s.items {
+		if item.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save persists items (caller must hold the lock)
+func (s *Store) save() error {
+	if err := s.doc.Save(s.items); err != nil {
+		return fmt.Errorf("failed to save notes: %w", err)
+	}
+	return nil
+}
+
+func (s *Store) notify(list string) {
+	s.mu.RLock()
+	fn := s.onChange
+	s.mu.RUnlock()
+	if fn != nil {
+		fn(list)
+	}
+}
+
+// newID generates a short random item ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
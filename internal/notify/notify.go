@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Severities, lowest to highest
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
+// eventType is the store event type used for notifications
+const eventType = "notification"
+
+// Notification is a message raised by a subsystem for the household
+type Notification struct {
+	ID       int64     `json:"id"`
+	Time     time.Time `json:"time"`
+	Source   string    `json:"source"`        // Subsystem that raised it, e.g. "lightning"
+	Key      string    `json:"key,omitempty"` // Groups related notifications, e.g. an escalation
+	Severity string    `json:"severity"`
+	Title    string    `json:"title"`
+	Message  string    `json:"message,omitempty"`
+}
+
+// Center records notifications and hands them to a delivery callback
+type Center struct {
+	store *store.Store
+
+	mu       sync.RWMutex
+	onNotify func(Notification)
+	dndUntil time.Time
+}
+
+// NewCenter creates a notification center, pruning entries older than retention
+func NewCenter(st *store.Store, retention time.Duration) *Center {
+	if retention > 0 {
+		if n, err := st.PruneEvents(eventType, time.Now().Add(-retention)); err != nil {
+			log.Printf("Warning: Failed to prune notifications: %v", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d old notifications", n)
+		}
+	}
+	return &Center{store: st}
+}
+
+// OnNotify registers the callback that delivers notifications (e.g. WebSocket broadcast)
+func (c *Center) OnNotify(fn func(Notification)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onNotify = fn
+}
+
+// SetDoNotDisturb holds back info notifications until the given time (zero turns
+// it off). They are still recorded; warnings and critical ones are always delivered.
+func (c *Center) SetDoNotDisturb(until time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.dndUntil = until
+}
+
+// DoNotDisturb returns when do not disturb ends, or zero when it's off
+func (c *Center) DoNotDisturb() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if time.Now().After(c.dndUntil) {
+		return time.Time{}
+	}
+	return c.dndUntil
+}
+
+// Notify records a notification and delivers it. Severity defaults to info.
+func (c *Center) Notify(n Notification) Notification {
+	if n.Time.IsZero() {
+		n.Time = time.Now()
+	}
+	if n.Severity == "" {
+		n.Severity = SeverityInfo
+	}
+
+	id, err := c.store.AppendEvent(n.Time, eventType, n.Source, n)
+	if err != nil {
+		log.Printf("Warning: Failed to record notification: %v", err)
+	}
+	n.ID = id
+	log.Printf("Notification [%s] %s: %s", n.Severity, n.Source, n.Title)
+
+	c.mu.RLock()
+	fn := c.onNotify
+	held := n.Severity == SeverityInfo && n.Time.Before(c.dndUntil)
+	c.mu.RUnlock()
+	if held {
+		log.Printf("Notification held back (do not disturb): %s", n.Title)
+		return n
+	}
+	if fn != nil {
+		fn(n)
+	}
+	return n
+}
+
+// Recent returns the newest notifications, optionally from one source, newest first
+func (c *Center) Recent(source string, limit int) ([]Notification, error) {
+	var events []store.Event
+	var err error
+	if source != "" {
+		events, err = c.store.RecentEventsFrom(eventType, source, limit)
+	} else {
+		events, err = c.store.RecentEvents(eventType, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Notification, 0, len(events))
+	for _, ev := range events {
+		var n Notification
+		if err := json.Unmarshal(ev.Data, &n); err != nil {
+			log.Printf("Warning: Skipping malformed notification %d: %v", ev.ID, err)
+			continue
+		}
+		n.ID = ev.ID
+		result = append(result, n)
+	}
+	return result, nil
+}
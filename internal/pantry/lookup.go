@@ -0,0 +1,90 @@
+package pantry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// openFoodFactsURL is the product endpoint of the Open Food Facts API
+const openFoodFactsURL = "https://world.openfoodfacts.org/api/v2/product/%s.json?fields=product_name,generic_name,brands,quantity,image_front_small_url"
+
+// userAgent identifies the app, as Open Food Facts asks of API clients
+const userAgent = "home_control/1.0 (pantry barcode lookup)"
+
+// Product is what Open Food Facts knows about a barcode
+type Product struct {
+	Barcode string `json:"barcode"`
+	Name    string `json:"name"`
+	Brand   string `json:"brand,omitempty"`
+	Size    string `json:"size,omitempty"` // Package size, e.g. "400 g"
+	Image   string `json:"image,omitempty"`
+}
+
+// ErrProductNotFound is returned when a barcode isn't in Open Food Facts
+var ErrProductNotFound = errors.New("product not found")
+
+// Lookup finds a product by barcode (EAN/UPC) in Open Food Facts
+func (m *Manager) Lookup(barcode string) (*Product, error) {
+	barcode = strings.TrimSpace(barcode)
+	for _, c := range barcode {
+		if c < '0' || c > '9' {
+			return nil, fmt.Errorf("invalid barcode: %s", barcode)
+		}
+	}
+	if len(barcode) < 8 || len(barcode) > 14 {
+		return nil, fmt.Errorf("invalid barcode: %s", barcode)
+	}
+
+	req, err := http.NewRequest("GET", fmt.Sprintf(openFoodFactsURL, barcode), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("barcode lookup failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrProductNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("barcode lookup failed: status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Status  int `json:"status"` // 1 found, 0 not found
+		Product struct {
+			ProductName string `json:"product_name"`
+			GenericName string `json:"generic_name"`
+			Brands      string `json:"brands"`
+			Quantity    string `json:"quantity"`
+			Image       string `json:"image_front_small_url"`
+		} `json:"product"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse barcode lookup: %w", err)
+	}
+	if result.Status != 1 {
+		return nil, ErrProductNotFound
+	}
+
+	p := &Product{
+		Barcode: barcode,
+		Name:    strings.TrimSpace(result.Product.ProductName),
+		Size:    strings.TrimSpace(result.Product.Quantity),
+		Image:   result.Product.Image,
+	}
+	if p.Name == "" {
+		p.Name = strings.TrimSpace(result.Product.GenericName)
+	}
+	// brands is a comma-separated list; the first is the label on the package
+	if brands := strings.Split(result.Product.Brands, ","); len(brands) > 0 {
+		p.Brand = strings.TrimSpace(brands[0])
+	}
+	return p, nil
+}
@@ -0,0 +1,397 @@
+package pantry
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// DateLayout is the format of Item.Expires
+const DateLayout = "2006-01-02"
+
+// Storage locations
+const (
+	LocationPantry  = "pantry"
+	LocationFridge  = "fridge"
+	LocationFreezer = "freezer"
+)
+
+// Item is something on the shelf
+type Item struct {
+	ID       string    `json:"id"`
+	Name     string    `json:"name"`
+	Brand    string    `json:"brand,omitempty"`
+	Barcode  string    `json:"barcode,omitempty"`
+	Image    string    `json:"image,omitempty"`
+	Quantity float64   `json:"quantity"`          // Units on hand, e.g. 2 cans
+	Unit     string    `json:"unit,omitempty"`    // e.g. "can", "g"
+	Location string    `json:"location"`          // pantry, fridge or freezer
+	Expires  string    `json:"expires,omitempty"` // YYYY-MM-DD
+	Reminded bool      `json:"reminded,omitempty"`
+	AddedAt  time.Time `json:"addedAt"`
+}
+
+// Status is an item with its expiry resolved, as returned by the API
+type Status struct {
+	Item
+	DaysLeft *int `json:"daysLeft,omitempty"` // Nil when the item has no expiry date
+	Expired  bool `json:"expired"`
+}
+
+// Manager keeps the pantry inventory
+type Manager struct {
+	httpClient *http.Client
+
+	mu        sync.RWMutex
+	doc       *store.Doc
+	items     []Item
+	timezone  *time.Location
+	onChange  func()
+	onUsedUp  func(item Item)
+	onExpires func(items []Status)
+}
+
+// NewManager loads the inventory from the store
+func NewManager(doc *store.Doc, timezone *time.Location) *Manager {
+	if timezone == nil {
+		timezone = time.Local
+	}
+	m := &Manager{
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		doc:        doc,
+		timezone:   timezone,
+	}
+
+	if _, err := doc.Load(&m.items); err != nil {
+		log.Printf("Warning: Failed to load pantry: %v", err)
+	}
+	return m
+}
+
+// OnChange registers a callback invoked after the inventory is modified
+func (m *Manager) OnChange(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// OnUsedUp registers a callback invoked when the last of an item is used
+func (m *Manager) OnUsedUp(fn func(item Item)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onUsedUp = fn
+}
+
+// OnExpiring registers a callback invoked with items that entered the reminder
+// window. Each item is reminded about once (again if its date changes).
+func (m *Manager) OnExpiring(fn func(items []Status)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onExpires = fn
+}
+
+// List returns the inventory, soonest expiry first, items without a date last.
+// location filters by storage location when not empty.
+func (m *Manager) List(location string) []Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	today := m.today()
+	result := make([]Status, 0, len(m.items))
+	for _, item := range m.items {
+		if location != "" && item.Location != location {
+			continue
+		}
+		result = append(result, m.status(item, today))
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		a, b := result[i].DaysLeft, result[j].DaysLeft
+		switch {
+		case a == nil || b == nil:
+			return a != nil && b == nil
+		case *a != *b:
+			return *a < *b
+		default:
+			return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+		}
+	})
+	return result
+}
+
+// Expiring returns items expiring within the given number of days, including expired ones
+func (m *Manager) Expiring(days int) []Status {
+	var result []Status
+	for _, s := range m.List("") {
+		if s.DaysLeft != nil && *s.DaysLeft <= days {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// Add puts an item on the shelf. Adding a barcode that's already stocked at the
+// same location and expiry tops up its quantity instead of creating a duplicate.
+func (m *Manager) Add(item Item) (*Item, error) {
+	if err := validate(&item); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	for i := range m.items {
+		existing := &m.items[i]
+		if item.Barcode != "" && existing.Barcode == item.Barcode && existing.Location == item.Location && existing.Expires == item.Expires {
+			existing.Quantity += item.Quantity
+			updated := *existing
+			err := m.save()
+			m.mu.Unlock()
+			if err != nil {
+				return nil, err
+			}
+			m.notify()
+			return &updated, nil
+		}
+	}
+	item.ID = newID()
+	item.AddedAt = time.Now()
+	item.Reminded = false
+	m.items = append(m.items, item)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &item, nil
+}
+
+// Update replaces an item's details, keeping its ID
+func (m *Manager) Update(id string, item Item) (*Item, error) {
+	if err := validate(&item); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("pantry item not found: %s", id)
+	}
+	existing := m.items[idx]
+	item.ID = existing.ID
+	item.AddedAt = existing.AddedAt
+	item.Reminded = existing.Reminded && existing.Expires == item.Expires
+	m.items[idx] = item
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &item, nil
+}
+
+// Use takes amount off an item's quantity (all of it when amount is 0). An item
+// that runs out is removed and handed to the used-up callback; usedUp reports that.
+func (m *Manager) Use(id string, amount float64) (item *Item, usedUp bool, err error) {
+	if amount < 0 {
+		return nil, false, fmt.Errorf("amount cannot be negative")
+	}
+
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return nil, false, fmt.Errorf("pantry item not found: %s", id)
+	}
+	current := &m.items[idx]
+	if amount == 0 || amount >= current.Quantity {
+		current.Quantity = 0
+	} else {
+		current.Quantity = math.Round((current.Quantity-amount)*1000) / 1000
+	}
+	updated := *current
+	if updated.Quantity == 0 {
+		m.items = append(m.items[:idx], m.items[idx+1:]...)
+	}
+	err = m.save()
+	fn := m.onUsedUp
+	m.mu.Unlock()
+	if err != nil {
+		return nil, false, err
+	}
+
+	usedUp = updated.Quantity == 0
+	if usedUp {
+		log.Printf("Pantry: used up %s", updated.Name)
+		if fn != nil {
+			fn(updated)
+		}
+	}
+	m.notify()
+	return &updated, usedUp, nil
+}
+
+// Delete removes an item without treating it as used up (e.g. thrown away)
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("pantry item not found: %s", id)
+	}
+	m.items = append(m.items[:idx], m.items[idx+1:]...)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.notify()
+	return nil
+}
+
+// RunReminders checks hourly for items expiring within days and hands new ones
+// to the expiring callback. It blocks, so call it in a goroutine.
+func (m *Manager) RunReminders(days int) {
+	for {
+		m.remind(days)
+		time.Sleep(time.Hour)
+	}
+}
+
+// remind marks and reports items that entered the reminder window
+func (m *Manager) remind(days int) {
+	m.mu.Lock()
+	today := m.today()
+	var due []Status
+	for i := range m.items {
+		s := m.status(m.items[i], today)
+		if s.DaysLeft == nil || *s.DaysLeft > days || m.items[i].Reminded {
+			continue
+		}
+		m.items[i].Reminded = true
+		s.Reminded = true
+		due = append(due, s)
+	}
+	if len(due) == 0 {
+		m.mu.Unlock()
+		return
+	}
+	if err := m.save(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	fn := m.onExpires
+	m.mu.Unlock()
+
+	if fn != nil {
+		fn(due)
+	}
+	m.notify()
+}
+
+// Label describes when an item expires, e.g. "Milk expires tomorrow"
+func (s Status) Label() string {
+	switch {
+	case s.DaysLeft == nil:
+		return s.Name
+	case *s.DaysLeft < 0:
+		return s.Name + " has expired"
+	case *s.DaysLeft == 0:
+		return s.Name + " expires today"
+	case *s.DaysLeft == 1:
+		return s.Name + " expires tomorrow"
+	default:
+		return fmt.Sprintf("%s expires in %d days", s.Name, *s.DaysLeft)
+	}
+}
+
+func (m *Manager) status(item Item, today time.Time) Status {
+	s := Status{Item: item}
+	if item.Expires == "" {
+		return s
+	}
+	date, err := time.ParseInLocation(DateLayout, item.Expires, m.timezone)
+	if err != nil {
+		return s
+	}
+	days := int(math.Round(date.Sub(today).Hours() / 24))
+	s.DaysLeft = &days
+	s.Expired = days < 0
+	return s
+}
+
+func validate(item *Item) error {
+	item.Name = strings.TrimSpace(item.Name)
+	if item.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if item.Quantity < 0 {
+		return fmt.Errorf("quantity cannot be negative")
+	}
+	if item.Quantity == 0 {
+		item.Quantity = 1
+	}
+	switch item.Location {
+	case "":
+		item.Location = LocationPantry
+	case LocationPantry, LocationFridge, LocationFreezer:
+	default:
+		return fmt.Errorf("invalid location: %s", item.Location)
+	}
+	if item.Expires != "" {
+		if _, err := time.Parse(DateLayout, item.Expires); err != nil {
+			return fmt.Errorf("invalid expiry date (use YYYY-MM-DD): %s", item.Expires)
+		}
+	}
+	return nil
+}
+
+func (m *Manager) today() time.Time {
+	now := time.Now().In(m.timezone)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, m.timezone)
+}
+
+// indexOf finds an item by ID (caller must hold the lock)
+func (m *Manager) indexOf(id string) int {
+	for i, item := range m.items {
+		if item.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save persists the inventory (caller must hold the lock)
+func (m *Manager) save() error {
+	if err := m.doc.Save(m.items); err != nil {
+		return fmt.Errorf("failed to save pantry: %w", err)
+	}
+	return nil
+}
+
+func (m *Manager) notify() {
+	m.mu.RLock()
+	fn := m.onChange
+	m.mu.RUnlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// newID generates a short random item ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,351 @@
+package party
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxPhotoSize is the largest photo a guest can upload
+	MaxPhotoSize = 15 << 20
+	// MaxPhotos caps the album of one party
+	MaxPhotos = 500
+	// DefaultHours is how long a party runs when no length is given
+	DefaultHours = 6
+	// MaxHours is the longest a party can run before it ends on its own
+	MaxHours = 48
+	// maxUploaderName limits the name guests can put on their photos
+	maxUploaderName = 40
+)
+
+// ErrNotFound is returned for unknown photo IDs
+var ErrNotFound = errors.New("photo not found")
+
+// ErrNoParty is returned when no party is running
+var ErrNoParty = errors.New("no party running")
+
+// imageTypes are the formats guests can upload
+var imageTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Session is a running party. Guests join with the token in the QR code.
+type Session struct {
+	Name      string    `json:"name"`
+	Token     string    `json:"-"`
+	Moderated bool      `json:"moderated"` // Photos wait for approval before they're shown
+	Started   time.Time `json:"started"`
+	Ends      time.Time `json:"ends"`
+}
+
+// Photo is a guest upload
+type Photo struct {
+	ID          string    `json:"id"`
+	Uploader    string    `json:"uploader,omitempty"`
+	ContentType string    `json:"contentType"`
+	Approved    bool      `json:"approved"`
+	UploadedAt  time.Time `json:"uploadedAt"`
+}
+
+// Status is the running party with its album, as returned by the API
+type Status struct {
+	Session
+	Photos  []Photo `json:"photos"`
+	Pending int     `json:"pending"`
+}
+
+// Manager runs party mode: a temporary album guests upload to from their
+// phones. Photos live in dir only while the party runs and are deleted when it
+// ends, on its own at the end time or when the server restarts.
+type Manager struct {
+	dir string
+
+	mu       sync.RWMutex
+	session  *Session
+	photos   []Photo
+	onChange func()
+	onPhoto  func(Photo)
+}
+
+// NewManager removes any album left from a party that was running when the
+// server stopped
+func NewManager(dir string) *Manager {
+	if err := os.RemoveAll(dir); err != nil {
+		log.Printf("Warning: Failed to clean up party photos: %v", err)
+	}
+	return &Manager{dir: dir}
+}
+
+// OnChange registers a callback invoked when a party starts or ends, or the
+// moderation queue changes
+func (m *Manager) OnChange(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// OnPhoto registers a callback invoked when a photo becomes visible in the
+// slideshow (on upload, or on approval when moderated)
+func (m *Manager) OnPhoto(fn func(Photo)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onPhoto = fn
+}
+
+// Start begins a party lasting hours (DefaultHours if 0), replacing any running one
+func (m *Manager) Start(name string, hours int, moderated bool) (*Status, error) {
+	if hours == 0 {
+		hours = DefaultHours
+	}
+	if hours < 1 || hours > MaxHours {
+		return nil, fmt.Errorf("hours must be between 1 and %d", MaxHours)
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Party"
+	}
+
+	m.End()
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create party album: %w", err)
+	}
+	now := time.Now()
+	session := &Session{Name: name, Token: newToken(), Moderated: moderated, Started: now, Ends: now.Add(time.Duration(hours) * time.Hour)}
+	m.mu.Lock()
+	m.session = session
+	m.photos = nil
+	m.mu.Unlock()
+
+	log.Printf("Party mode started: %s until %s", name, session.Ends.Format("15:04"))
+	m.notify()
+	return m.Status(true), nil
+}
+
+// End stops the party and deletes its photos
+func (m *Manager) End() {
+	m.mu.Lock()
+	running := m.session != nil
+	m.session = nil
+	m.photos = nil
+	m.mu.Unlock()
+	if !running {
+		return
+	}
+
+	if err := os.RemoveAll(m.dir); err != nil {
+		log.Printf("Warning: Failed to delete party photos: %v", err)
+	}
+	log.Println("Party mode ended")
+	m.notify()
+}
+
+// Status returns the running party, or nil. Pending photos are only listed
+// when includePending is set; the slideshow gets approved photos only.
+func (m *Manager) Status(includePending bool) *Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.session == nil {
+		return nil
+	}
+	st := &Status{Session: *m.session, Photos: []Photo{}}
+	for _, p := range m.photos {
+		if !p.Approved {
+			st.Pending++
+			if !includePending {
+				continue
+			}
+		}
+		st.Photos = append(st.Photos, p)
+	}
+	return st
+}
+
+// Token returns the running party's join token
+func (m *Manager) Token() (string, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if m.session == nil {
+		return "", false
+	}
+	return m.session.Token, true
+}
+
+// ValidToken reports whether token joins the running party
+func (m *Manager) ValidToken(token string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.session != nil && subtle.ConstantTimeCompare([]byte(token), []byte(m.session.Token)) == 1
+}
+
+// Upload adds a guest's photo to the party joined with token
+func (m *Manager) Upload(token, uploader, contentType string, data []byte) (*Photo, error) {
+	if !m.ValidToken(token) {
+		return nil, ErrNoParty
+	}
+	ext, ok := imageTypes[contentType]
+	if !ok {
+		return nil, fmt.Errorf("only JPEG, PNG, GIF and WebP photos can be uploaded")
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("photo is empty")
+	}
+	if len(data) > MaxPhotoSize {
+		return nil, fmt.Errorf("photo is larger than %d MB", MaxPhotoSize>>20)
+	}
+	uploader = strings.TrimSpace(uploader)
+	if len([]rune(uploader)) > maxUploaderName {
+		uploader = string([]rune(uploader)[:maxUploaderName])
+	}
+
+	m.mu.Lock()
+	if m.session == nil {
+		m.mu.Unlock()
+		return nil, ErrNoParty
+	}
+	if len(m.photos) >= MaxPhotos {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("the album is full")
+	}
+	photo := Photo{ID: newID() + ext, Uploader: uploader, ContentType: contentType, Approved: !m.session.Moderated, UploadedAt: time.Now()}
+	if err := os.WriteFile(filepath.Join(m.dir, photo.ID), data, 0600); err != nil {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("failed to save photo: %w", err)
+	}
+	m.photos = append(m.photos, photo)
+	m.mu.Unlock()
+
+	if photo.Approved {
+		m.shown(photo)
+	} else {
+		m.notify()
+	}
+	return &photo, nil
+}
+
+// Approve shows a pending photo in the slideshow
+func (m *Manager) Approve(id string) error {
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	already := m.photos[idx].Approved
+	m.photos[idx].Approved = true
+	photo := m.photos[idx]
+	m.mu.Unlock()
+
+	if !already {
+		m.shown(photo)
+		m.notify()
+	}
+	return nil
+}
+
+// Delete rejects a pending photo or removes a shown one
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return ErrNotFound
+	}
+	m.photos = append(m.photos[:idx], m.photos[idx+1:]...)
+	m.mu.Unlock()
+
+	if err := os.Remove(filepath.Join(m.dir, id)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: Failed to remove party photo %s: %v", id, err)
+	}
+	m.notify()
+	return nil
+}
+
+// Path returns a photo's file and content type
+func (m *Manager) Path(id string) (string, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		return "", "", ErrNotFound
+	}
+	return filepath.Join(m.dir, id), m.photos[idx].ContentType, nil
+}
+
+// Run ends the party at its end time
+func (m *Manager) Run() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.mu.RLock()
+		expired := m.session != nil && time.Now().After(m.session.Ends)
+		m.mu.RUnlock()
+		if expired {
+			m.End()
+		}
+	}
+}
+
+// DetectType returns the image type of an upload, sniffing the contents when
+// the browser didn't say
+func DetectType(declared string, data []byte) string {
+	if _, ok := imageTypes[declared]; ok {
+		return declared
+	}
+	sniffed := http.DetectContentType(data)
+	if _, ok := imageTypes[sniffed]; ok {
+		return sniffed
+	}
+	return declared
+}
+
+func (m *Manager) shown(p Photo) {
+	m.mu.RLock()
+	fn := m.onPhoto
+	m.mu.RUnlock()
+	if fn != nil {
+		fn(p)
+	}
+}
+
+func (m *Manager) notify() {
+	m.mu.RLock()
+	fn := m.onChange
+	m.mu.RUnlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+func (m *Manager) indexOf(id string) int {
+	for i, p := range m.photos {
+		if p.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func newToken() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
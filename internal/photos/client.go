@@ -0,0 +1,479 @@
+package photos
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Scope lets the app read the photos picked in the Google Photos picker,
+// requested alongside the Calendar scopes (shared Google token). The Library
+// API's read-only scope no longer reaches the user's albums.
+const Scope = "https://www.googleapis.com/auth/photospicker.mediaitems.readonly"
+
+const apiBase = "https://photospicker.googleapis.com/v1"
+
+// importTimeout bounds copying one album's photos
+const importTimeout = 10 * time.Minute
+
+// Album states
+const (
+	AlbumPicking = "picking" // Waiting for photos to be picked at PickerURI
+	AlbumReady   = "ready"
+	AlbumFailed  = "failed"
+)
+
+// ErrNoAlbum is returned when no album has been picked
+var ErrNoAlbum = errors.New("no Google Photos album selected")
+
+// pickedTypes are the formats kept from the picker, with their file extension
+var pickedTypes = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// Album is a set of photos picked in Google Photos. The photos are copied
+// into the data directory, since the picker only lends them for a while.
+type Album struct {
+	ID        string    `json:"id"`
+	Title     string    `json:"title"`
+	Count     int       `json:"count"`
+	State     string    `json:"state"`
+	PickerURI string    `json:"pickerUri,omitempty"` // Open on a phone to pick the photos
+	ExpiresAt time.Time `json:"expiresAt,omitempty"` // When the picker stops waiting
+	Error     string    `json:"error,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+	SessionID string    `json:"sessionId,omitempty"`
+}
+
+// Photo represents a photo in the selected album
+type Photo struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	MimeType string `json:"mimeType"`
+}
+
+// session is a Picker API session
+type session struct {
+	ID            string    `json:"id"`
+	PickerURI     string    `json:"pickerUri"`
+	ExpireTime    time.Time `json:"expireTime"`
+	MediaItemsSet bool      `json:"mediaItemsSet"`
+	PollingConfig struct {
+		PollInterval string `json:"pollInterval"` // e.g. "5s"
+	} `json:"pollingConfig"`
+}
+
+// pollInterval is how long Google asks to wait between polls, or fallback
+// when it doesn't say
+func (s session) pollInterval(fallback time.Duration) time.Duration {
+	if d, err := time.ParseDuration(s.PollingConfig.PollInterval); err == nil && d > 0 {
+		return d
+	}
+	return fallback
+}
+
+// settings is the stored document
+type settings struct {
+	AlbumID string  `json:"albumId"`
+	Albums  []Album `json:"albums,omitempty"`
+}
+
+// Client picks photos from Google Photos into albums and serves the selected one
+type Client struct {
+	httpClient *http.Client
+	doc        *store.Doc
+	dir        string
+	mu         sync.RWMutex
+	settings   settings
+	folder     *Folder // The selected album's photos
+}
+
+// NewClient creates a Google Photos client from an authorized Google HTTP
+// client, keeping picked photos under dir. Albums still being picked when
+// the server stopped are picked up again.
+func NewClient(httpClient *http.Client, doc *store.Doc, dir string) *Client {
+	c := &Client{
+		httpClient: httpClient,
+		doc:        doc,
+		dir:        dir,
+	}
+	if _, err := doc.Load(&c.settings); err != nil {
+		log.Printf("Warning: Failed to load Google Photos settings: %v", err)
+	}
+	if c.settings.AlbumID != "" {
+		c.folder = NewFolder(c.albumDir(c.settings.AlbumID))
+	}
+	for _, a := range c.settings.Albums {
+		if a.State == AlbumPicking {
+			go c.poll(a.ID, a.SessionID, 5*time.Second, a.ExpiresAt)
+		}
+	}
+	return c
+}
+
+// AlbumID returns the selected album, or "" when none is picked
+func (c *Client) AlbumID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.settings.AlbumID
+}
+
+// Albums lists the picked albums
+func (c *Client) Albums() []Album {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]Album{}, c.settings.Albums...)
+}
+
+// CreateAlbum starts a picker session. Once photos are picked at the album's
+// PickerURI they are copied in and the album becomes ready.
+func (c *Client) CreateAlbum(ctx context.Context, title string) (*Album, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return nil, fmt.Errorf("title is required")
+	}
+
+	body, err := c.do(ctx, http.MethodPost, apiBase+"/sessions", []byte("{}"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start picker: %w", err)
+	}
+	var s session
+	if err := json.Unmarshal(body, &s); err != nil {
+		return nil, fmt.Errorf("failed to decode picker session: %w", err)
+	}
+
+	album := Album{
+		ID:        newID(),
+		Title:     title,
+		State:     AlbumPicking,
+		PickerURI: s.PickerURI,
+		ExpiresAt: s.ExpireTime,
+		CreatedAt: time.Now(),
+		SessionID: s.ID,
+	}
+	c.mu.Lock()
+	c.settings.Albums = append(c.settings.Albums, album)
+	err = c.save()
+	c.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	go c.poll(album.ID, s.ID, s.pollInterval(5*time.Second), s.ExpireTime)
+	return &album, nil
+}
+
+// SetAlbum picks the album the screensaver shows; "" clears it
+func (c *Client) SetAlbum(albumID string) error {
+	albumID = strings.TrimSpace(albumID)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if albumID != "" {
+		idx := c.indexOf(albumID)
+		if idx < 0 {
+			return fmt.Errorf("album not found: %s", albumID)
+		}
+		if c.settings.Albums[idx].State != AlbumReady {
+			return fmt.Errorf("album %s is not ready", albumID)
+		}
+	}
+
+	prev := c.settings.AlbumID
+	c.settings.AlbumID = albumID
+	if err := c.save(); err != nil {
+		c.settings.AlbumID = prev
+		return err
+	}
+	c.folder = nil
+	if albumID != "" {
+		c.folder = NewFolder(c.albumDir(albumID))
+	}
+	return nil
+}
+
+// DeleteAlbum removes an album and its photos
+func (c *Client) DeleteAlbum(albumID string) error {
+	c.mu.Lock()
+	idx := c.indexOf(albumID)
+	if idx < 0 {
+		c.mu.Unlock()
+		return fmt.Errorf("album not found: %s", albumID)
+	}
+	c.settings.Albums = append(c.settings.Albums[:idx], c.settings.Albums[idx+1:]...)
+	if c.settings.AlbumID == albumID {
+		c.settings.AlbumID = ""
+		c.folder = nil
+	}
+	err := c.save()
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.RemoveAll(c.albumDir(albumID)); err != nil {
+		log.Printf("Warning: Failed to remove Google Photos album %s: %v", albumID, err)
+	}
+	return nil
+}
+
+// GetPhotos returns the selected album's photos
+func (c *Client) GetPhotos(ctx context.Context) ([]Photo, error) {
+	folder := c.selected()
+	if folder == nil {
+		return nil, ErrNoAlbum
+	}
+	return folder.GetPhotos(ctx)
+}
+
+// GetRandomPhoto returns a random photo
+func (c *Client) GetRandomPhoto(ctx context.Context) (*Photo, error) {
+	folder := c.selected()
+	if folder == nil {
+		return nil, ErrNoAlbum
+	}
+	return folder.GetRandomPhoto(ctx)
+}
+
+// GetPhotoContent reads a photo of the selected album
+func (c *Client) GetPhotoContent(ctx context.Context, id string) ([]byte, string, error) {
+	folder := c.selected()
+	if folder == nil {
+		return nil, "", ErrNoAlbum
+	}
+	return folder.GetPhotoContent(ctx, id)
+}
+
+func (c *Client) selected() *Folder {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.folder
+}
+
+// poll waits for the album's photos to be picked, then copies them in. The
+// session is deleted either way, as the Picker API asks.
+func (c *Client) poll(albumID, sessionID string, interval time.Duration, deadline time.Time) {
+	defer c.deleteSession(sessionID)
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+		if !c.hasAlbum(albumID) {
+			return // Deleted while picking
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		body, err := c.do(ctx, http.MethodGet, apiBase+"/sessions/"+url.PathEscape(sessionID), nil)
+		cancel()
+		if err != nil {
+			log.Printf("Warning: Failed to poll Google Photos picker: %v", err)
+			continue
+		}
+		var s session
+		if err := json.Unmarshal(body, &s); err != nil {
+			log.Printf("Warning: Failed to decode Google Photos picker session: %v", err)
+			continue
+		}
+		if !s.MediaItemsSet {
+			interval = s.pollInterval(interval)
+			continue
+		}
+
+		ctx, cancel = context.WithTimeout(context.Background(), importTimeout)
+		count, err := c.importAlbum(ctx, albumID, sessionID)
+		cancel()
+		c.finish(albumID, count, err)
+		return
+	}
+	c.finish(albumID, 0, fmt.Errorf("no photos were picked before the picker expired"))
+}
+
+// importAlbum copies a session's picked photos, sized for a kiosk screen,
+// into the album's directory. Videos are skipped.
+func (c *Client) importAlbum(ctx context.Context, albumID, sessionID string) (int, error) {
+	dir := c.albumDir(albumID)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create album directory: %w", err)
+	}
+
+	count := 0
+	pageToken := ""
+	for {
+		query := url.Values{"sessionId": {sessionID}, "pageSize": {"100"}}
+		if pageToken != "" {
+			query.Set("pageToken", pageToken)
+		}
+		body, err := c.do(ctx, http.MethodGet, apiBase+"/mediaItems?"+query.Encode(), nil)
+		if err != nil {
+			return count, fmt.Errorf("failed to list picked photos: %w", err)
+		}
+		var resp struct {
+			MediaItems []struct {
+				ID        string `json:"id"`
+				Type      string `json:"type"`
+				MediaFile struct {
+					BaseURL  string `json:"baseUrl"`
+					MimeType string `json:"mimeType"`
+				} `json:"mediaFile"`
+			} `json:"mediaItems"`
+			NextPageToken string `json:"nextPageToken"`
+		}
+		if err := json.Unmarshal(body, &resp); err != nil {
+			return count, fmt.Errorf("failed to decode picked photos: %w", err)
+		}
+		for _, item := range resp.MediaItems {
+			if item.Type != "PHOTO" {
+				continue
+			}
+			data, contentType, err := c.download(ctx, item.MediaFile.BaseURL+"=w1920-h1920")
+			if err != nil {
+				log.Printf("Warning: Failed to copy Google Photo %s: %v", item.ID, err)
+				continue
+			}
+			ext, ok := pickedTypes[contentType]
+			if !ok {
+				log.Printf("Warning: Skipping Google Photo %s: unsupported type %s", item.ID, contentType)
+				continue
+			}
+			sum := sha1.Sum([]byte(item.ID))
+			if err := os.WriteFile(filepath.Join(dir, hex.EncodeToString(sum[:8])+ext), data, 0644); err != nil {
+				return count, fmt.Errorf("failed to save photo: %w", err)
+			}
+			count++
+		}
+		pageToken = resp.NextPageToken
+		if pageToken == "" {
+			break
+		}
+	}
+	return count, nil
+}
+
+// finish records the outcome of picking an album
+func (c *Client) finish(albumID string, count int, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	idx := c.indexOf(albumID)
+	if idx < 0 {
+		os.RemoveAll(c.albumDir(albumID)) // Deleted while copying
+		return
+	}
+	album := &c.settings.Albums[idx]
+	album.Count = count
+	album.PickerURI, album.SessionID = "", ""
+	album.State = AlbumReady
+	album.Error = ""
+	if err != nil {
+		log.Printf("Error picking Google Photos album %s: %v", album.Title, err)
+		album.State = AlbumFailed
+		album.Error = err.Error()
+	} else {
+		log.Printf("Google Photos album %s: %d photos copied", album.Title, count)
+	}
+	if err := c.save(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+func (c *Client) deleteSession(sessionID string) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := c.do(ctx, http.MethodDelete, apiBase+"/sessions/"+url.PathEscape(sessionID), nil); err != nil {
+		log.Printf("Warning: Failed to delete Google Photos picker session: %v", err)
+	}
+}
+
+func (c *Client) hasAlbum(albumID string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.indexOf(albumID) >= 0
+}
+
+// indexOf finds an album; callers hold mu
+func (c *Client) indexOf(albumID string) int {
+	for i, a := range c.settings.Albums {
+		if a.ID == albumID {
+			return i
+		}
+	}
+	return -1
+}
+
+func (c *Client) albumDir(albumID string) string {
+	return filepath.Join(c.dir, albumID)
+}
+
+// save stores the settings; callers hold mu
+func (c *Client) save() error {
+	if err := c.doc.Save(c.settings); err != nil {
+		return fmt.Errorf("failed to save Google Photos settings: %w", err)
+	}
+	return nil
+}
+
+// download fetches a picked photo; base URLs need the OAuth token too
+func (c *Client) download(ctx context.Context, url string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	contentType, _, _ := strings.Cut(resp.Header.Get("Content-Type"), ";")
+	return data, strings.TrimSpace(contentType), nil
+}
+
+func (c *Client) do(ctx context.Context, method, url string, body []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(data))
+	}
+	return data, nil
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
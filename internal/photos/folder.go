@@ -0,0 +1,299 @@
+package photos
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"io/fs"
+	"log"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source is a screensaver photo provider
+type Source interface {
+	GetPhotos(ctx context.Context) ([]Photo, error)
+	GetRandomPhoto(ctx context.Context) (*Photo, error)
+	GetPhotoContent(ctx context.Context, id string) ([]byte, string, error)
+}
+
+// folderTypes are the formats browsers can show, by extension
+var folderTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// rotatedCacheSize is how many rotated JPEGs are kept, so a slideshow cycling
+// through a few kiosks doesn't decode the same photo for each
+const rotatedCacheSize = 16
+
+// Folder serves photos from a local directory, such as an SMB or NFS share
+// bind-mounted into the container. Subfolders are scanned too.
+type Folder struct {
+	dir           string
+	mu            sync.RWMutex
+	photos        []Photo
+	paths         map[string]string // ID -> path
+	lastFetch     time.Time
+	cacheDuration time.Duration
+
+	rotatedMu sync.Mutex
+	rotated   map[string][]byte // ID -> JPEG re-encoded upright
+	order     []string          // Rotated IDs, oldest first
+}
+
+// NewFolder creates a local folder source
+func NewFolder(dir string) *Folder {
+	return &Folder{
+		dir:           dir,
+		cacheDuration: 10 * time.Minute, // Network shares are slow to walk
+		rotated:       make(map[string][]byte),
+	}
+}
+
+// Dir returns the scanned directory
+func (f *Folder) Dir() string {
+	return f.dir
+}
+
+// GetPhotos returns the folder's photos, rescanning when the cache is stale
+func (f *Folder) GetPhotos(ctx context.Context) ([]Photo, error) {
+	f.mu.RLock()
+	if time.Since(f.lastFetch) < f.cacheDuration && f.photos != nil {
+		photos := f.photos
+		f.mu.RUnlock()
+		return photos, nil
+	}
+	f.mu.RUnlock()
+
+	photos := []Photo{}
+	paths := make(map[string]string)
+	err := filepath.WalkDir(f.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == f.dir {
+				return err
+			}
+			log.Printf("Warning: Skipping %s: %v", path, err)
+			return nil
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if d.IsDir() {
+			if path != f.dir && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir // .thumbnails, .@__thumb and the like
+			}
+			return nil
+		}
+		mimeType, ok := folderTypes[strings.ToLower(filepath.Ext(path))]
+		if !ok || strings.HasPrefix(d.Name(), ".") {
+			return nil
+		}
+		rel, err := filepath.Rel(f.dir, path)
+		if err != nil {
+			return nil
+		}
+		sum := sha1.Sum([]byte(filepath.ToSlash(rel)))
+		id := hex.EncodeToString(sum[:8])
+		paths[id] = path
+		photos = append(photos, Photo{ID: id, Name: filepath.ToSlash(rel), MimeType: mimeType})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan %s: %w", f.dir, err)
+	}
+	sort.Slice(photos, func(i, j int) bool { return photos[i].Name < photos[j].Name })
+
+	f.mu.Lock()
+	f.photos = photos
+	f.paths = paths
+	f.lastFetch = time.Now()
+	f.mu.Unlock()
+	return photos, nil
+}
+
+// GetRandomPhoto returns a random photo
+func (f *Folder) GetRandomPhoto(ctx context.Context) (*Photo, error) {
+	photos, err := f.GetPhotos(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(photos) == 0 {
+		return nil, nil
+	}
+	return &photos[rand.Intn(len(photos))], nil
+}
+
+// GetPhotoContent reads a photo, turning JPEGs upright by their EXIF orientation
+func (f *Folder) GetPhotoContent(ctx context.Context, id string) ([]byte, string, error) {
+	if _, err := f.GetPhotos(ctx); err != nil {
+		return nil, "", err
+	}
+	f.mu.RLock()
+	path, ok := f.paths[id]
+	f.mu.RUnlock()
+	if !ok {
+		return nil, "", fmt.Errorf("photo not in folder: %s", id)
+	}
+	mimeType := folderTypes[strings.ToLower(filepath.Ext(path))]
+
+	if mimeType == "image/jpeg" {
+		f.rotatedMu.Lock()
+		data, ok := f.rotated[id]
+		f.rotatedMu.Unlock()
+		if ok {
+			return data, mimeType, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read photo: %w", err)
+	}
+	if mimeType != "image/jpeg" {
+		return data, mimeType, nil
+	}
+	orientation := exifOrientation(data)
+	if orientation < 2 || orientation > 8 {
+		return data, mimeType, nil
+	}
+
+	upright, err := rotateJPEG(data, orientation)
+	if err != nil {
+		log.Printf("Warning: Failed to rotate %s: %v", path, err)
+		return data, mimeType, nil
+	}
+	f.rotatedMu.Lock()
+	if _, ok := f.rotated[id]; !ok {
+		if len(f.order) >= rotatedCacheSize {
+			delete(f.rotated, f.order[0])
+			f.order = f.order[1:]
+		}
+		f.order = append(f.order, id)
+	}
+	f.rotated[id] = upright
+	f.rotatedMu.Unlock()
+	return upright, mimeType, nil
+}
+
+// exifOrientation reads the EXIF orientation tag of a JPEG (1-8), or 0 if it
+// has none
+func exifOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 0
+	}
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return 0
+		}
+		marker := data[pos+1]
+		if marker == 0xDA || marker == 0xD9 {
+			return 0 // Image data starts; no EXIF before it
+		}
+		size := int(binary.BigEndian.Uint16(data[pos+2:]))
+		if size < 2 || pos+2+size > len(data) {
+			return 0
+		}
+		segment := data[pos+4 : pos+2+size]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		pos += 2 + size
+	}
+	return 0
+}
+
+// tiffOrientation finds tag 0x0112 in the first IFD of a TIFF header
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd+2 > len(tiff) {
+		return 0
+	}
+	count := int(order.Uint16(tiff[ifd:]))
+	for i := 0; i < count; i++ {
+		entry := ifd + 2 + i*12
+		if entry+12 > len(tiff) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			return int(order.Uint16(tiff[entry+8:]))
+		}
+	}
+	return 0
+}
+
+// rotateJPEG decodes a JPEG, applies an EXIF orientation and re-encodes it.
+// The result has no EXIF, so browsers won't rotate it again.
+func rotateJPEG(data []byte, orientation int) ([]byte, error) {
+	img, err := jpeg.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	w, h := b.Dx(), b.Dy()
+
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w // Quarter turns swap width and height
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // Mirrored
+				sx, sy = w-1-x, y
+			case 3: // Upside down
+				sx, sy = w-1-x, h-1-y
+			case 4: // Upside down, mirrored
+				sx, sy = x, h-1-y
+			case 5: // Transposed
+				sx, sy = y, x
+			case 6: // Turned left; rotate clockwise
+				sx, sy = y, h-1-x
+			case 7: // Transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // Turned right; rotate counterclockwise
+				sx, sy = w-1-y, x
+			}
+			si := src.PixOffset(sx, sy)
+			di := dst.PixOffset(x, y)
+			copy(dst.Pix[di:di+4], src.Pix[si:si+4])
+		}
+	}
+
+	var out bytes.Buffer
+	if err := jpeg.Encode(&out, dst, &jpeg.Options{Quality: 90}); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,405 @@
+package pool
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/homeassistant"
+	"home_control/internal/store"
+)
+
+// DefaultHeatRate is the assumed heating rate (degrees per hour) until one has been
+// measured. Hot tubs typically manage 3-6 °F an hour, pools far less.
+const DefaultHeatRate = 2.0
+
+// Body is a pool or hot tub. Equipment is reached through Home Assistant, which
+// has integrations for Pentair (ScreenLogic/IntelliCenter), Balboa and most others.
+type Body struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Heater string   `json:"heater"`          // climate.* or water_heater.* entity
+	Pumps  []string `json:"pumps,omitempty"` // switch.* entities (circulation, jets)
+}
+
+// Window is a daily pump run, e.g. 08:00-12:00. Off before On runs past midnight.
+type Window struct {
+	On  string `json:"on"`  // HH:MM
+	Off string `json:"off"` // HH:MM
+}
+
+// Settings are the per-body preferences kept in the store
+type Settings struct {
+	HeatTo    float64             `json:"heatTo,omitempty"`    // Setpoint for "heat the hot tub"
+	HeatRate  float64             `json:"heatRate,omitempty"`  // Measured degrees per hour
+	Schedules map[string][]Window `json:"schedules,omitempty"` // Pump entity -> run windows
+}
+
+// Pump is a pump's current state
+type Pump struct {
+	Entity  string   `json:"entity"`
+	Name    string   `json:"name"`
+	On      bool     `json:"on"`
+	Windows []Window `json:"windows,omitempty"`
+}
+
+// Status is a body's current state with a time-to-temperature estimate
+type Status struct {
+	Body
+	WaterTemp     *float64   `json:"waterTemp,omitempty"`
+	Setpoint      *float64   `json:"setpoint,omitempty"`
+	Mode          string     `json:"mode"` // Heater state, e.g. heat, off
+	Heating       bool       `json:"heating"`
+	HeatTo        float64    `json:"heatTo"`
+	HeatRate      float64    `json:"heatRate"` // Degrees per hour used for the estimate
+	MinutesToTemp *int       `json:"minutesToTemp,omitempty"`
+	ReadyAt       *time.Time `json:"readyAt,omitempty"`
+	PumpStates    []Pump     `json:"pumpStates"`
+}
+
+// sample is a water temperature reading taken while heating
+type sample struct {
+	temp float64
+	at   time.Time
+}
+
+// Manager reads and controls pool and hot tub equipment
+type Manager struct {
+	client *homeassistant.Client
+	bodies []Body
+
+	mu       sync.RWMutex
+	doc      *store.Doc
+	settings map[string]*Settings
+	heating  map[string]sample // Start of the current heating run per body
+}
+
+// NewManager creates a manager for the configured bodies
+func NewManager(client *homeassistant.Client, bodies []Body, doc *store.Doc) *Manager {
+	m := &Manager{
+		client:   client,
+		bodies:   bodies,
+		doc:      doc,
+		settings: make(map[string]*Settings),
+		heating:  make(map[string]sample),
+	}
+	if _, err := doc.Load(&m.settings); err != nil {
+		log.Printf("Warning: Failed to load pool settings: %v", err)
+	}
+	for _, b := range bodies {
+		if m.settings[b.ID] == nil {
+			m.settings[b.ID] = &Settings{}
+		}
+	}
+	return m
+}
+
+// Bodies returns the configured bodies
+func (m *Manager) Bodies() []Body {
+	return m.bodies
+}
+
+// Body returns a body by ID
+func (m *Manager) Body(id string) (Body, bool) {
+	for _, b := range m.bodies {
+		if b.ID == id {
+			return b, true
+		}
+	}
+	return Body{}, false
+}
+
+// Status reads a body's heater and pumps
+func (m *Manager) Status(id string) (*Status, error) {
+	body, ok := m.Body(id)
+	if !ok {
+		return nil, fmt.Errorf("pool not found: %s", id)
+	}
+
+	heater, err := m.client.GetState(body.Heater)
+	if err != nil {
+		return nil, err
+	}
+	s := &Status{Body: body, Mode: heater.State, PumpStates: []Pump{}}
+	if v, ok := heater.Attributes["current_temperature"].(float64); ok {
+		s.WaterTemp = &v
+	}
+	if v, ok := heater.Attributes["temperature"].(float64); ok {
+		s.Setpoint = &v
+	}
+	if action, ok := heater.Attributes["hvac_action"].(string); ok {
+		s.Heating = action == "heating"
+	} else {
+		s.Heating = heater.State != "off" && s.WaterTemp != nil && s.Setpoint != nil && *s.WaterTemp < *s.Setpoint
+	}
+
+	m.mu.RLock()
+	settings := *m.settings[id]
+	m.mu.RUnlock()
+	s.HeatTo = settings.HeatTo
+	if s.HeatTo == 0 {
+		s.HeatTo = defaultHeatTo(s.Setpoint)
+	}
+	s.HeatRate = settings.HeatRate
+	if s.HeatRate == 0 {
+		s.HeatRate = DefaultHeatRate
+	}
+
+	if s.WaterTemp != nil && s.Setpoint != nil && heater.State != "off" && *s.WaterTemp < *s.Setpoint {
+		minutes := int(math.Ceil((*s.Setpoint - *s.WaterTemp) / s.HeatRate * 60))
+		ready := time.Now().Add(time.Duration(minutes) * time.Minute)
+		s.MinutesToTemp = &minutes
+		s.ReadyAt = &ready
+	}
+
+	for _, entity := range body.Pumps {
+		p := Pump{Entity: entity, Name: entity, Windows: settings.Schedules[entity]}
+		if e, err := m.client.GetState(entity); err == nil {
+			p.On = e.State == "on"
+			if name, ok := e.Attributes["friendly_name"].(string); ok {
+				p.Name = name
+			}
+		}
+		s.PumpStates = append(s.PumpStates, p)
+	}
+	return s, nil
+}
+
+// SetSetpoint sets the heater's target temperature and turns it on
+func (m *Manager) SetSetpoint(id string, setpoint float64) error {
+	body, ok := m.Body(id)
+	if !ok {
+		return fmt.Errorf("pool not found: %s", id)
+	}
+	if setpoint < 10 || setpoint > 106 {
+		return fmt.Errorf("setpoint must be between 10 and 106")
+	}
+
+	domain, _, _ := strings.Cut(body.Heater, ".")
+	data := map[string]interface{}{"entity_id": body.Heater, "temperature": setpoint}
+	if domain == "climate" {
+		data["hvac_mode"] = "heat"
+	} else {
+		// water_heater has no mode in set_temperature on every integration, so turn it on first
+		if _, err := m.client.CallServiceData(domain, "turn_on", map[string]interface{}{"entity_id": body.Heater}); err != nil {
+			log.Printf("Warning: Failed to turn on %s: %v", body.Heater, err)
+		}
+	}
+	_, err := m.client.CallServiceData(domain, "set_temperature", data)
+	return err
+}
+
+// TurnOffHeater turns a body's heater off
+func (m *Manager) TurnOffHeater(id string) error {
+	body, ok := m.Body(id)
+	if !ok {
+		return fmt.Errorf("pool not found: %s", id)
+	}
+	domain, _, _ := strings.Cut(body.Heater, ".")
+	return m.client.CallService(domain, "turn_off", body.Heater)
+}
+
+// Heat is the "heat the hot tub" scene: the heater goes to the body's HeatTo
+// setpoint (or the given one, which becomes the new default) and its pumps start
+func (m *Manager) Heat(id string, setpoint float64) error {
+	body, ok := m.Body(id)
+	if !ok {
+		return fmt.Errorf("pool not found: %s", id)
+	}
+
+	if setpoint == 0 {
+		s, err := m.Status(id)
+		if err != nil {
+			return err
+		}
+		setpoint = s.HeatTo
+	}
+	if err := m.SetSetpoint(id, setpoint); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	m.settings[id].HeatTo = setpoint
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		log.Printf("Warning: %v", err)
+	}
+
+	// Circulation must run for the heater to fire
+	if len(body.Pumps) > 0 {
+		if err := m.SetPump(id, body.Pumps[0], true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetPump turns one of a body's pumps on or off
+func (m *Manager) SetPump(id, entity string, on bool) error {
+	body, ok := m.Body(id)
+	if !ok {
+		return fmt.Errorf("pool not found: %s", id)
+	}
+	if !containsString(body.Pumps, entity) {
+		return fmt.Errorf("pump not found: %s", entity)
+	}
+	domain, _, _ := strings.Cut(entity, ".")
+	service := "turn_off"
+	if on {
+		service = "turn_on"
+	}
+	return m.client.CallService(domain, service, entity)
+}
+
+// SetSchedule replaces a pump's daily run windows
+func (m *Manager) SetSchedule(id, entity string, windows []Window) error {
+	body, ok := m.Body(id)
+	if !ok {
+		return fmt.Errorf("pool not found: %s", id)
+	}
+	if !containsString(body.Pumps, entity) {
+		return fmt.Errorf("pump not found: %s", entity)
+	}
+	for i := range windows {
+		w := &windows[i]
+		for _, at := range []*string{&w.On, &w.Off} {
+			parsed, err := time.Parse("15:04", *at)
+			if err != nil {
+				return fmt.Errorf("invalid time (use HH:MM): %s", *at)
+			}
+			*at = parsed.Format("15:04")
+		}
+		if w.On == w.Off {
+			return fmt.Errorf("on and off times must differ")
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	settings := m.settings[id]
+	if settings.Schedules == nil {
+		settings.Schedules = make(map[string][]Window)
+	}
+	if len(windows) == 0 {
+		delete(settings.Schedules, entity)
+	} else {
+		settings.Schedules[entity] = windows
+	}
+	return m.save()
+}
+
+// PumpRun is a scheduled pump change, registered with the scheduler
+type PumpRun struct {
+	ID     string // pool:<body>:<pump>:<n>:on|off
+	Name   string
+	Body   string
+	Entity string
+	At     string
+	On     bool
+}
+
+// Runs returns the scheduled pump changes of every body
+func (m *Manager) Runs() []PumpRun {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []PumpRun
+	for _, b := range m.bodies {
+		entities := make([]string, 0, len(m.settings[b.ID].Schedules))
+		for entity := range m.settings[b.ID].Schedules {
+			entities = append(entities, entity)
+		}
+		sort.Strings(entities)
+		for _, entity := range entities {
+			for i, w := range m.settings[b.ID].Schedules[entity] {
+				prefix := "pool:" + b.ID + ":" + entity + ":" + strconv.Itoa(i)
+				result = append(result,
+					PumpRun{ID: prefix + ":on", Name: b.Name + " pump on", Body: b.ID, Entity: entity, At: w.On, On: true},
+					PumpRun{ID: prefix + ":off", Name: b.Name + " pump off", Body: b.ID, Entity: entity, At: w.Off, On: false})
+			}
+		}
+	}
+	return result
+}
+
+// Run measures how fast each body heats so estimates improve over time. It
+// blocks, so call it in a goroutine.
+func (m *Manager) Run() {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+	for {
+		for _, b := range m.bodies {
+			m.measure(b.ID)
+		}
+		<-ticker.C
+	}
+}
+
+// measure tracks a heating run and folds its rate into the body's average once
+// the water has warmed by at least a degree
+func (m *Manager) measure(id string) {
+	s, err := m.Status(id)
+	if err != nil || s.WaterTemp == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	start, running := m.heating[id]
+	if !s.Heating {
+		delete(m.heating, id)
+		return
+	}
+	now := sample{temp: *s.WaterTemp, at: time.Now()}
+	if !running || now.temp < start.temp {
+		m.heating[id] = now
+		return
+	}
+
+	hours := now.at.Sub(start.at).Hours()
+	if delta := now.temp - start.temp; delta >= 1 && hours > 0 {
+		rate := delta / hours
+		settings := m.settings[id]
+		if settings.HeatRate == 0 {
+			settings.HeatRate = rate
+		} else {
+			settings.HeatRate = settings.HeatRate*0.7 + rate*0.3
+		}
+		settings.HeatRate = math.Round(settings.HeatRate*100) / 100
+		if err := m.save(); err != nil {
+			log.Printf("Warning: %v", err)
+		}
+		m.heating[id] = now
+	}
+}
+
+// defaultHeatTo picks a hot tub temperature in the unit the heater appears to use
+func defaultHeatTo(setpoint *float64) float64 {
+	if setpoint != nil && *setpoint > 50 {
+		return 102 // °F
+	}
+	return 38.5 // °C
+}
+
+// save persists settings (caller must hold the lock)
+func (m *Manager) save() error {
+	if err := m.doc.Save(m.settings); err != nil {
+		return fmt.Errorf("failed to save pool settings: %w", err)
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
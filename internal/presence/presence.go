@@ -0,0 +1,222 @@
+package presence
+
+import (
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/homeassistant"
+)
+
+// Change kinds
+const (
+	KindArrival   = "arrival"
+	KindDeparture = "departure"
+	KindZone      = "zone" // Moved between away zones (e.g. work -> school)
+)
+
+// Person is the presence state of a tracked person or device
+type Person struct {
+	EntityID string    `json:"entityId"`
+	Name     string    `json:"name"`
+	State    string    `json:"state"` // home, not_home or a zone name
+	Home     bool      `json:"home"`
+	Since    time.Time `json:"since"`
+	Picture  string    `json:"picture,omitempty"` // Relative to the HA base URL
+}
+
+// Change is an arrival, departure or zone change
+type Change struct {
+	Kind   string    `json:"kind"`
+	Person Person    `json:"person"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Time   time.Time `json:"time"`
+}
+
+// Tracker polls person/device_tracker entities and reports changes
+type Tracker struct {
+	client   *homeassistant.Client
+	entities []string
+	interval time.Duration
+
+	mu        sync.RWMutex
+	people    map[string]*Person
+	listeners []func(Change)
+}
+
+// NewTracker creates a presence tracker for the given entities
+func NewTracker(client *homeassistant.Client, entities []string, interval time.Duration) *Tracker {
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	return &Tracker{
+		client:   client,
+		entities: entities,
+		interval: interval,
+		people:   make(map[string]*Person),
+	}
+}
+
+// IsPresenceEntity reports whether an entity ID can be tracked
+func IsPresenceEntity(entityID string) bool {
+	return strings.HasPrefix(entityID, "person.") || strings.HasPrefix(entityID, "device_tracker.")
+}
+
+// OnChange registers a listener for arrivals, departures and zone changes.
+// Listeners are called from the polling goroutine.
+func (t *Tracker) OnChange(fn func(Change)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.listeners = append(t.listeners, fn)
+}
+
+// Run polls until the process exits. It blocks, so call it in a goroutine.
+func (t *Tracker) Run() {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+	for {
+		t.Poll()
+		<-ticker.C
+	}
+}
+
+// Poll refreshes all entities once. The first sighting of an entity only
+// establishes its state; changes are reported from then on.
+func (t *Tracker) Poll() {
+	if t.client == nil || len(t.entities) == 0 {
+		return
+	}
+	entities, err := t.client.GetStates(t.entities)
+	if err != nil {
+		log.Printf("Warning: Failed to poll presence: %v", err)
+		return
+	}
+
+	var changes []Change
+	t.mu.Lock()
+	for _, e := range entities {
+		// Keep the last known state while HA can't reach the tracker
+		if e.State == "unavailable" || e.State == "unknown" {
+			continue
+		}
+		if c, ok := t.update(toPerson(e), false); ok {
+			changes = append(changes, c)
+		}
+	}
+	listeners := append([]func(Change){}, t.listeners...)
+	t.mu.Unlock()
+
+	dispatch(changes, listeners)
+}
+
+// Report sets a person's state from a push source such as a phone geofence
+// webhook. Unlike polled entities, the first report of a person counts as a
+// change, since it was triggered by an actual zone transition.
+func (t *Tracker) Report(id, name, state string) (Change, bool) {
+	p := Person{EntityID: id, Name: name, State: state, Home: state == "home", Since: time.Now()}
+
+	t.mu.Lock()
+	c, ok := t.update(p, true)
+	listeners := append([]func(Change){}, t.listeners...)
+	t.mu.Unlock()
+
+	if ok {
+		dispatch([]Change{c}, listeners)
+	}
+	return c, ok
+}
+
+// update stores a person's new state and returns the resulting change, if any
+// (caller must hold the lock)
+func (t *Tracker) update(p Person, pushed bool) (Change, bool) {
+	prev, known := t.people[p.EntityID]
+	if known && prev.State == p.State {
+		// Polls refresh the name and picture; repeated reports keep Since
+		if !pushed {
+			t.people[p.EntityID] = &p
+		}
+		return Change{}, false
+	}
+	t.people[p.EntityID] = &p
+	if !known && !pushed {
+		return Change{}, false
+	}
+
+	from, wasHome := "", false
+	if known {
+		from, wasHome = prev.State, prev.Home
+	}
+	c := Change{Person: p, From: from, To: p.State, Time: time.Now()}
+	switch {
+	case p.Home && !wasHome:
+		c.Kind = KindArrival
+	case !p.Home && (wasHome || !known):
+		c.Kind = KindDeparture
+	default:
+		c.Kind = KindZone
+	}
+	return c, true
+}
+
+func dispatch(changes []Change, listeners []func(Change)) {
+	for _, c := range changes {
+		log.Printf("Presence: %s %s (%s -> %s)", c.Person.Name, c.Kind, c.From, c.To)
+		for _, fn := range listeners {
+			fn(c)
+		}
+	}
+}
+
+// People returns everyone tracked, home first, then by name
+func (t *Tracker) People() []Person {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	result := make([]Person, 0, len(t.people))
+	for _, p := range t.people {
+		result = append(result, *p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Home != result[j].Home {
+			return result[i].Home
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// Home returns the names of everyone currently home
+func (t *Tracker) Home() []string {
+	names := []string{}
+	for _, p := range t.People() {
+		if p.Home {
+			names = append(names, p.Name)
+		}
+	}
+	return names
+}
+
+// AnyoneHome reports whether at least one tracked person is home
+func (t *Tracker) AnyoneHome() bool {
+	return len(t.Home()) > 0
+}
+
+func toPerson(e *homeassistant.Entity) Person {
+	p := Person{
+		EntityID: e.EntityID,
+		Name:     e.EntityID,
+		State:    e.State,
+		Home:     e.State == "home",
+		Since:    e.LastChanged,
+	}
+	if name, ok := e.Attributes["friendly_name"].(string); ok {
+		p.Name = name
+	}
+	if pic, ok := e.Attributes["entity_picture"].(string); ok {
+		p.Picture = pic
+	}
+	return p
+}
@@ -0,0 +1,74 @@
+package printer3d
+
+import "math"
+
+// moonraker reads Klipper's printer objects through the Moonraker API
+type moonraker struct{}
+
+func (moonraker) status(c *Client) (*Status, error) {
+	var result struct {
+		Result struct {
+			Status struct {
+				PrintStats struct {
+					State         string  `json:"state"` // standby, printing, paused, complete, cancelled, error
+					Filename      string  `json:"filename"`
+					PrintDuration float64 `json:"print_duration"`
+					Message       string  `json:"message"`
+				} `json:"print_stats"`
+				VirtualSDCard struct {
+					Progress float64 `json:"progress"` // 0-1
+				} `json:"virtual_sdcard"`
+				Extruder  *heater `json:"extruder"`
+				HeaterBed *heater `json:"heater_bed"`
+			} `json:"status"`
+		} `json:"result"`
+	}
+	if err := c.getJSON("/printer/objects/query?print_stats&virtual_sdcard&extruder&heater_bed", &result); err != nil {
+		return nil, err
+	}
+
+	stats := result.Result.Status.PrintStats
+	progress := result.Result.Status.VirtualSDCard.Progress
+	s := &Status{
+		File:           stats.Filename,
+		Progress:       math.Round(progress*1000) / 10,
+		ElapsedSeconds: int(stats.PrintDuration),
+		Message:        stats.Message,
+	}
+	switch stats.State {
+	case "printing":
+		s.State = StatePrinting
+	case "paused":
+		s.State = StatePaused
+	case "complete":
+		s.State = StateFinished
+	case "cancelled":
+		s.State = StateCancelled
+	case "error":
+		s.State = StateError
+	default:
+		s.State = StateIdle
+	}
+	// Klipper has no slicer estimate here; extrapolate from progress so far
+	if (s.State == StatePrinting || s.State == StatePaused) && progress > 0.01 {
+		remaining := int(stats.PrintDuration/progress - stats.PrintDuration)
+		s.RemainingSeconds = &remaining
+	}
+	if h := result.Result.Status.Extruder; h != nil {
+		s.Hotend = &Temperature{Actual: h.Temperature, Target: h.Target}
+	}
+	if h := result.Result.Status.HeaterBed; h != nil {
+		s.Bed = &Temperature{Actual: h.Temperature, Target: h.Target}
+	}
+	return s, nil
+}
+
+type heater struct {
+	Temperature float64 `json:"temperature"`
+	Target      float64 `json:"target"`
+}
+
+func (moonraker) command(c *Client, action string) error {
+	_, err := c.do("POST", "/printer/print/"+action, nil)
+	return err
+}
@@ -0,0 +1,102 @@
+package printer3d
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// octoPrint reads /api/job and /api/printer
+type octoPrint struct{}
+
+func (octoPrint) status(c *Client) (*Status, error) {
+	var job struct {
+		Job struct {
+			File struct {
+				Display string `json:"display"`
+				Name    string `json:"name"`
+			} `json:"file"`
+		} `json:"job"`
+		Progress struct {
+			Completion    *float64 `json:"completion"`
+			PrintTime     *int     `json:"printTime"`
+			PrintTimeLeft *int     `json:"printTimeLeft"`
+		} `json:"progress"`
+		State string `json:"state"` // Operational, Printing, Pausing, Paused, Cancelling, Error: ..., Offline
+		Error string `json:"error"`
+	}
+	if err := c.getJSON("/api/job", &job); err != nil {
+		return nil, err
+	}
+
+	s := &Status{File: job.Job.File.Display, RemainingSeconds: job.Progress.PrintTimeLeft, Message: job.Error}
+	if s.File == "" {
+		s.File = job.Job.File.Name
+	}
+	if job.Progress.Completion != nil {
+		s.Progress = *job.Progress.Completion
+	}
+	if job.Progress.PrintTime != nil {
+		s.ElapsedSeconds = *job.Progress.PrintTime
+	}
+
+	state := strings.ToLower(job.State)
+	switch {
+	case strings.HasPrefix(state, "printing"), state == "pausing", state == "finishing":
+		s.State = StatePrinting
+	case state == "paused":
+		s.State = StatePaused
+	case state == "cancelling":
+		s.State = StateCancelled
+	case strings.HasPrefix(state, "error"), strings.Contains(state, "offline after error"):
+		s.State = StateError
+		if s.Message == "" {
+			s.Message = job.State
+		}
+	case strings.HasPrefix(state, "offline"), state == "closed":
+		s.State = StateOffline
+	case s.File != "" && s.Progress >= 100:
+		// OctoPrint goes back to Operational with the job still loaded
+		s.State = StateFinished
+	default:
+		s.State = StateIdle
+	}
+
+	// Temperatures are unavailable (409) while the printer isn't connected
+	var printer struct {
+		Temperature map[string]struct {
+			Actual *float64 `json:"actual"`
+			Target *float64 `json:"target"`
+		} `json:"temperature"`
+	}
+	err := c.getJSON("/api/printer?history=false", &printer)
+	var se *statusError
+	if err != nil && !(errors.As(err, &se) && se.code == http.StatusConflict) {
+		return nil, err
+	}
+	for name, t := range printer.Temperature {
+		if t.Actual == nil {
+			continue
+		}
+		temp := &Temperature{Actual: *t.Actual}
+		if t.Target != nil {
+			temp.Target = *t.Target
+		}
+		switch name {
+		case "tool0":
+			s.Hotend = temp
+		case "bed":
+			s.Bed = temp
+		}
+	}
+	return s, nil
+}
+
+func (octoPrint) command(c *Client, action string) error {
+	body := map[string]string{"command": action}
+	if action == "pause" || action == "resume" {
+		body = map[string]string{"command": "pause", "action": action}
+	}
+	_, err := c.do("POST", "/api/job", body)
+	return err
+}
@@ -0,0 +1,246 @@
+package printer3d
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Printer kinds
+const (
+	KindOctoPrint = "octoprint"
+	KindMoonraker = "moonraker" // Klipper
+)
+
+// Print states, normalized across OctoPrint and Moonraker
+const (
+	StateIdle      = "idle"
+	StatePrinting  = "printing"
+	StatePaused    = "paused"
+	StateFinished  = "finished"
+	StateCancelled = "cancelled"
+	StateError     = "error"
+	StateOffline   = "offline"
+)
+
+// Temperature is a heater's current and target temperature in °C
+type Temperature struct {
+	Actual float64 `json:"actual"`
+	Target float64 `json:"target"`
+}
+
+// Status is the printer's current job and temperatures
+type Status struct {
+	Name             string       `json:"name"`
+	State            string       `json:"state"`
+	File             string       `json:"file,omitempty"`
+	Progress         float64      `json:"progress"` // Percent
+	ElapsedSeconds   int          `json:"elapsedSeconds,omitempty"`
+	RemainingSeconds *int         `json:"remainingSeconds,omitempty"`
+	Hotend           *Temperature `json:"hotend,omitempty"`
+	Bed              *Temperature `json:"bed,omitempty"`
+	Message          string       `json:"message,omitempty"` // Error text from the host
+	UpdatedAt        time.Time    `json:"updatedAt"`
+}
+
+// backend talks to one kind of print host
+type backend interface {
+	status(c *Client) (*Status, error)
+	command(c *Client, action string) error
+}
+
+// Client monitors and controls a printer through OctoPrint or Moonraker
+type Client struct {
+	name        string
+	baseURL     string
+	apiKey      string
+	snapshotURL string
+	httpClient  *http.Client
+	backend     backend
+
+	mu       sync.RWMutex
+	last     *Status
+	onChange func(s Status, previous string)
+}
+
+// NewClient creates a client for a print host. snapshotURL defaults to the
+// mjpg-streamer snapshot path both hosts ship with.
+func NewClient(kind, baseURL, apiKey, snapshotURL, name string) (*Client, error) {
+	c := &Client{
+		name:        name,
+		baseURL:     strings.TrimSuffix(baseURL, "/"),
+		apiKey:      apiKey,
+		snapshotURL: snapshotURL,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+	switch kind {
+	case KindOctoPrint:
+		c.backend = octoPrint{}
+	case KindMoonraker:
+		c.backend = moonraker{}
+	default:
+		return nil, fmt.Errorf("unknown printer type: %s (use octoprint or moonraker)", kind)
+	}
+	if c.name == "" {
+		c.name = "3D Printer"
+	}
+	if c.snapshotURL == "" {
+		c.snapshotURL = c.baseURL + "/webcam/?action=snapshot"
+	}
+	return c, nil
+}
+
+// OnChange registers a callback invoked when the print state changes
+func (c *Client) OnChange(fn func(s Status, previous string)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.onChange = fn
+}
+
+// GetStatus queries the printer. An unreachable host is reported as offline.
+func (c *Client) GetStatus() *Status {
+	s, err := c.backend.status(c)
+	if err != nil {
+		s = &Status{State: StateOffline, Message: err.Error()}
+	}
+	s.Name = c.name
+	s.UpdatedAt = time.Now()
+	return s
+}
+
+// LastStatus returns the status from the latest poll, nil before the first one
+func (c *Client) LastStatus() *Status {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.last == nil {
+		return nil
+	}
+	s := *c.last
+	return &s
+}
+
+// Pause pauses the running print
+func (c *Client) Pause() error { return c.backend.command(c, "pause") }
+
+// Resume resumes a paused print
+func (c *Client) Resume() error { return c.backend.command(c, "resume") }
+
+// Cancel cancels the running print
+func (c *Client) Cancel() error { return c.backend.command(c, "cancel") }
+
+// Snapshot fetches a JPEG from the printer's webcam
+func (c *Client) Snapshot() ([]byte, error) {
+	req, err := http.NewRequest("GET", c.snapshotURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("snapshot returned status %d", resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Run polls the printer every interval, faster while printing, and reports state
+// changes. It blocks, so call it in a goroutine.
+func (c *Client) Run(interval time.Duration) {
+	for {
+		s := c.GetStatus()
+
+		c.mu.Lock()
+		previous := ""
+		if c.last != nil {
+			previous = c.last.State
+		}
+		c.last = s
+		fn := c.onChange
+		c.mu.Unlock()
+
+		if previous != s.State {
+			if s.State == StateOffline && previous != "" {
+				log.Printf("3D printer %s went offline: %s", c.name, s.Message)
+			}
+			if fn != nil {
+				fn(*s, previous)
+			}
+		}
+
+		wait := interval
+		if s.State == StatePrinting && wait > 10*time.Second {
+			wait = 10 * time.Second
+		}
+		time.Sleep(wait)
+	}
+}
+
+// getJSON performs an authenticated GET and decodes the response into v
+func (c *Client) getJSON(path string, v interface{}) error {
+	body, err := c.do("GET", path, nil)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to parse printer response: %w", err)
+	}
+	return nil
+}
+
+// do performs a request against the print host with its API key
+func (c *Client) do(method, path string, payload interface{}) ([]byte, error) {
+	var reader io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, c.baseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	if c.apiKey != "" {
+		req.Header.Set("X-Api-Key", c.apiKey)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("printer request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return body, &statusError{code: resp.StatusCode, body: strings.TrimSpace(string(body))}
+	}
+	return body, nil
+}
+
+// statusError is an HTTP error from the print host
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	if e.body == "" {
+		return fmt.Sprintf("printer returned status %d", e.code)
+	}
+	return fmt.Sprintf("printer returned status %d: %s", e.code, e.body)
+}
@@ -0,0 +1,417 @@
+package qr
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Code is an encoded QR code (byte mode, error correction level M)
+type Code struct {
+	Size    int
+	modules [][]bool // [y][x], true is dark
+}
+
+// version holds the level M block structure of a QR version
+type version struct {
+	total  int // Codewords, data and error correction
+	ec     int // Error correction codewords per block
+	blocks int
+	align  []int // Alignment pattern centers
+}
+
+// versions 1-10 cover up to 213 bytes, plenty for a URL
+var versions = []version{
+	{26, 10, 1, nil},
+	{44, 16, 1, []int{6, 18}},
+	{70, 26, 1, []int{6, 22}},
+	{100, 18, 2, []int{6, 26}},
+	{134, 24, 2, []int{6, 30}},
+	{172, 16, 4, []int{6, 34}},
+	{196, 18, 4, []int{6, 22, 38}},
+	{242, 22, 4, []int{6, 24, 42}},
+	{292, 22, 5, []int{6, 26, 46}},
+	{346, 26, 5, []int{6, 28, 50}},
+}
+
+// Encode makes the smallest QR code holding text
+func Encode(text string) (*Code, error) {
+	data := []byte(text)
+	for i, v := range versions {
+		ver := i + 1
+		countBits := 8
+		if ver >= 10 {
+			countBits = 16
+		}
+		capacity := (v.total - v.ec*v.blocks) * 8
+		if 4+countBits+len(data)*8 > capacity {
+			continue
+		}
+		codewords := interleave(v, dataCodewords(data, countBits, capacity/8))
+		return build(ver, v, codewords), nil
+	}
+	return nil, errors.New("text too long for a QR code")
+}
+
+// SVG renders the code with a quiet zone of border modules
+func (c *Code) SVG(border int) string {
+	var path strings.Builder
+	for y, row := range c.modules {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d,%dh1v1h-1z", x+border, y+border)
+			}
+		}
+	}
+	size := c.Size + 2*border
+	return fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="100%%" height="100%%" fill="#fff"/><path d="%s" fill="#000"/></svg>`, size, size, path.String())
+}
+
+// dataCodewords encodes byte mode data padded to n codewords
+func dataCodewords(data []byte, countBits, n int) []byte {
+	var bits []bool
+	appendBits := func(v, count int) {
+		for i := count - 1; i >= 0; i-- {
+			bits = append(bits, (v>>i)&1 == 1)
+		}
+	}
+	appendBits(0b0100, 4)
+	appendBits(len(data), countBits)
+	for _, b := range data {
+		appendBits(int(b), 8)
+	}
+	for i := 0; i < 4 && len(bits) < n*8; i++ {
+		bits = append(bits, false)
+	}
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+
+	out := make([]byte, 0, n)
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for j := 0; j < 8; j++ {
+			if bits[i+j] {
+				b |= 1 << (7 - j)
+			}
+		}
+		out = append(out, b)
+	}
+	for pad := byte(0xEC); len(out) < n; pad ^= 0xEC ^ 0x11 {
+		out = append(out, pad)
+	}
+	return out
+}
+
+// interleave splits data into blocks, adds error correction and interleaves
+// the blocks' data and then their error correction codewords
+func interleave(v version, data []byte) []byte {
+	short := len(data) / v.blocks
+	long := len(data) % v.blocks
+	divisor := rsDivisor(v.ec)
+
+	var blocks, ecBlocks [][]byte
+	for i, pos := 0, 0; i < v.blocks; i++ {
+		n := short
+		if i >= v.blocks-long {
+			n++
+		}
+		block := data[pos : pos+n]
+		pos += n
+		blocks = append(blocks, block)
+		ecBlocks = append(ecBlocks, rsRemainder(block, divisor))
+	}
+
+	out := make([]byte, 0, v.total)
+	for i := 0; i <= short; i++ {
+		for _, block := range blocks {
+			if i < len(block) {
+				out = append(out, block[i])
+			}
+		}
+	}
+	for i := 0; i < v.ec; i++ {
+		for _, ec := range ecBlocks {
+			out = append(out, ec[i])
+		}
+	}
+	return out
+}
+
+// build lays out the modules and picks the mask with the lowest penalty
+func build(ver int, v version, codewords []byte) *Code {
+	size := ver*4 + 17
+	var best *Code
+	bestPenalty := -1
+	for mask := 0; mask < 8; mask++ {
+		g := newGrid(size)
+		g.drawFunctionPatterns(ver, v.align)
+		g.drawFormat(mask)
+		g.drawCodewords(codewords)
+		g.applyMask(mask)
+		if p := g.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = &Code{Size: size, modules: g.modules}, p
+		}
+	}
+	return best
+}
+
+type grid struct {
+	size     int
+	modules  [][]bool
+	function [][]bool // Modules reserved for patterns and format information
+}
+
+func newGrid(size int) *grid {
+	g := &grid{size: size, modules: make([][]bool, size), function: make([][]bool, size)}
+	for i := range g.modules {
+		g.modules[i] = make([]bool, size)
+		g.function[i] = make([]bool, size)
+	}
+	return g
+}
+
+func (g *grid) set(x, y int, dark bool) {
+	g.modules[y][x] = dark
+	g.function[y][x] = true
+}
+
+func (g *grid) drawFunctionPatterns(ver int, align []int) {
+	for i := 0; i < g.size; i++ {
+		g.set(6, i, i%2 == 0)
+		g.set(i, 6, i%2 == 0)
+	}
+
+	for _, c := range [][2]int{{3, 3}, {g.size - 4, 3}, {3, g.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x >= 0 && x < g.size && y >= 0 && y < g.size {
+					dist := max(abs(dx), abs(dy))
+					g.set(x, y, dist != 2 && dist != 4)
+				}
+			}
+		}
+	}
+
+	last := len(align) - 1
+	for i, ay := range align {
+		for j, ax := range align {
+			if (i == 0 && j == 0) || (i == 0 && j == last) || (i == last && j == 0) {
+				continue // Overlaps a finder pattern
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					g.set(ax+dx, ay+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	if ver >= 7 {
+		rem := ver
+		for i := 0; i < 12; i++ {
+			rem = (rem << 1) ^ ((rem >> 11) * 0x1F25)
+		}
+		bits := ver<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := (bits>>i)&1 == 1
+			a, b := g.size-11+i%3, i/3
+			g.set(a, b, dark)
+			g.set(b, a, dark)
+		}
+	}
+}
+
+// drawFormat writes the level M format bits for a mask in both copies
+func (g *grid) drawFormat(mask int) {
+	data := 0b00<<3 | mask // Level M
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = (rem << 1) ^ ((rem >> 9) * 0x537)
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return (bits>>i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		g.set(8, i, bit(i))
+	}
+	g.set(8, 7, bit(6))
+	g.set(8, 8, bit(7))
+	g.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		g.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		g.set(g.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		g.set(8, g.size-15+i, bit(i))
+	}
+	g.set(8, g.size-8, true) // Always dark
+}
+
+// drawCodewords fills the data area in the two-column zigzag
+func (g *grid) drawCodewords(codewords []byte) {
+	i := 0
+	for right := g.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // Skip the vertical timing pattern
+		}
+		for vert := 0; vert < g.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = g.size - 1 - vert
+				}
+				if !g.function[y][x] && i < len(codewords)*8 {
+					g.modules[y][x] = (codewords[i>>3]>>(7-i&7))&1 == 1
+					i++
+				}
+			}
+		}
+	}
+}
+
+func (g *grid) applyMask(mask int) {
+	for y := 0; y < g.size; y++ {
+		for x := 0; x < g.size; x++ {
+			if g.function[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				g.modules[y][x] = !g.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores a masked grid by the standard's four rules (lower is better)
+func (g *grid) penalty() int {
+	score := 0
+	line := func(get func(i int) bool) {
+		run := 1
+		for i := 1; i <= g.size; i++ {
+			if i < g.size && get(i) == get(i-1) {
+				run++
+				continue
+			}
+			if run >= 5 {
+				score += run - 2
+			}
+			run = 1
+		}
+		// Finder-like 1:1:3:1:1 patterns with four light modules on a side
+		for i := 0; i+11 <= g.size; i++ {
+			pattern := []bool{true, false, true, true, true, false, true}
+			before, after := true, true
+			for k := 0; k < 7; k++ {
+				before = before && get(i+4+k) == pattern[k]
+				after = after && get(i+k) == pattern[k]
+			}
+			for k := 0; k < 4; k++ {
+				before = before && !get(i+k)
+				after = after && !get(i+7+k)
+			}
+			if before {
+				score += 40
+			}
+			if after {
+				score += 40
+			}
+		}
+	}
+	for y := 0; y < g.size; y++ {
+		line(func(i int) bool { return g.modules[y][i] })
+	}
+	for x := 0; x < g.size; x++ {
+		line(func(i int) bool { return g.modules[i][x] })
+	}
+
+	dark := 0
+	for y := 0; y < g.size; y++ {
+		for x := 0; x < g.size; x++ {
+			if g.modules[y][x] {
+				dark++
+			}
+			if x+1 < g.size && y+1 < g.size {
+				c := g.modules[y][x]
+				if g.modules[y][x+1] == c && g.modules[y+1][x] == c && g.modules[y+1][x+1] == c {
+					score += 3
+				}
+			}
+		}
+	}
+	total := g.size * g.size
+	score += abs(dark*20-total*10) / total * 10
+	return score
+}
+
+// rsDivisor returns the Reed-Solomon generator polynomial of a degree
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < len(result) {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder computes the error correction codewords for data
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = (z << 1) ^ ((z >> 7) * 0x11D)
+		z ^= int((y>>i)&1) * int(x)
+	}
+	return byte(z)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,182 @@
+package quiethours
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// timeLayout is the format of Window.Start and Window.End
+const timeLayout = "15:04"
+
+// Window caps media volume between Start and End, e.g. 22:00-07:00. End
+// before Start runs past midnight.
+type Window struct {
+	Start     string         `json:"start"`          // HH:MM
+	End       string         `json:"end"`            // HH:MM
+	Days      []time.Weekday `json:"days,omitempty"` // Days the window starts on; empty = every day
+	MaxVolume int            `json:"maxVolume"`      // Percent
+}
+
+// Settings are the quiet hours policy
+type Settings struct {
+	Enabled bool     `json:"enabled"`
+	Windows []Window `json:"windows"`
+}
+
+// Status is the policy and whether a cap applies right now
+type Status struct {
+	Settings
+	Active    bool       `json:"active"`
+	MaxVolume int        `json:"maxVolume,omitempty"`
+	Until     *time.Time `json:"until,omitempty"`
+}
+
+// Cap describes a volume that was lowered to the quiet hours limit
+type Cap struct {
+	Requested int       `json:"requested"`
+	Volume    int       `json:"volume"`
+	Until     time.Time `json:"until"`
+}
+
+// Manager holds the quiet hours policy. Callers clamp volume changes through
+// Clamp before sending them to a player. Wake-up alarms are exempt.
+type Manager struct {
+	timezone *time.Location
+
+	mu       sync.RWMutex
+	doc      *store.Doc
+	settings Settings
+}
+
+// NewManager loads the policy
+func NewManager(doc *store.Doc, timezone *time.Location) *Manager {
+	if timezone == nil {
+		timezone = time.Local
+	}
+	m := &Manager{
+		timezone: timezone,
+		doc:      doc,
+		settings: Settings{Windows: []Window{}},
+	}
+	if _, err := doc.Load(&m.settings); err != nil {
+		log.Printf("Warning: Failed to load quiet hours: %v", err)
+	}
+	return m
+}
+
+// Status returns the policy and the cap in effect now
+func (m *Manager) Status() Status {
+	m.mu.RLock()
+	s := Status{Settings: m.settings}
+	m.mu.RUnlock()
+
+	if limit, until, ok := m.Limit(time.Now()); ok {
+		s.Active = true
+		s.MaxVolume = limit
+		s.Until = &until
+	}
+	return s
+}
+
+// Update validates and replaces the policy
+func (m *Manager) Update(s Settings) error {
+	if s.Windows == nil {
+		s.Windows = []Window{}
+	}
+	for i := range s.Windows {
+		w := &s.Windows[i]
+		for _, at := range []*string{&w.Start, &w.End} {
+			parsed, err := time.Parse(timeLayout, *at)
+			if err != nil {
+				return fmt.Errorf("invalid time (use HH:MM): %s", *at)
+			}
+			*at = parsed.Format(timeLayout)
+		}
+		if w.Start == w.End {
+			return fmt.Errorf("start and end times must differ")
+		}
+		if w.MaxVolume < 0 || w.MaxVolume > 100 {
+			return fmt.Errorf("maxVolume must be between 0 and 100")
+		}
+		for _, d := range w.Days {
+			if d < time.Sunday || d > time.Saturday {
+				return fmt.Errorf("invalid day %d", d)
+			}
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.doc.Save(s); err != nil {
+		return fmt.Errorf("failed to save quiet hours: %w", err)
+	}
+	m.settings = s
+	return nil
+}
+
+// Limit returns the volume cap at t and when it lifts. When windows overlap,
+// the lowest cap wins.
+func (m *Manager) Limit(t time.Time) (limit int, until time.Time, ok bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if !m.settings.Enabled {
+		return 0, time.Time{}, false
+	}
+
+	t = t.In(m.timezone)
+	for _, w := range m.settings.Windows {
+		end, active := w.activeAt(t)
+		if !active {
+			continue
+		}
+		if !ok || w.MaxVolume < limit {
+			limit, until = w.MaxVolume, end
+		}
+		ok = true
+	}
+	return limit, until, ok
+}
+
+// Clamp lowers a volume percentage to the cap in effect now. The returned Cap
+// is nil when the volume was allowed as is.
+func (m *Manager) Clamp(volume int) (int, *Cap) {
+	limit, until, ok := m.Limit(time.Now())
+	if !ok || volume <= limit {
+		return volume, nil
+	}
+	return limit, &Cap{Requested: volume, Volume: limit, Until: until}
+}
+
+// activeAt reports whether t falls inside the window, and when it ends
+func (w Window) activeAt(t time.Time) (time.Time, bool) {
+	start, err1 := time.Parse(timeLayout, w.Start)
+	end, err2 := time.Parse(timeLayout, w.End)
+	if err1 != nil || err2 != nil {
+		return time.Time{}, false
+	}
+	on := func(day time.Time, at time.Time) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), at.Hour(), at.Minute(), 0, 0, t.Location())
+	}
+	startsOn := func(day time.Time) bool {
+		return len(w.Days) == 0 || slices.Contains(w.Days, day.Weekday())
+	}
+
+	if w.Start < w.End {
+		from, to := on(t, start), on(t, end)
+		return to, startsOn(t) && !t.Before(from) && t.Before(to)
+	}
+
+	// Past midnight: either started this evening or on the previous day
+	if from := on(t, start); !t.Before(from) && startsOn(t) {
+		return on(t.AddDate(0, 0, 1), end), true
+	}
+	if to := on(t, end); t.Before(to) && startsOn(t.AddDate(0, 0, -1)) {
+		return to, true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,180 @@
+package recipes
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Recipe is an imported recipe
+type Recipe struct {
+	ID           string    `json:"id"`
+	Name         string    `json:"name"`
+	Description  string    `json:"description,omitempty"`
+	URL          string    `json:"url"`
+	Image        string    `json:"image,omitempty"`
+	Yield        string    `json:"yield,omitempty"`
+	PrepMinutes  int       `json:"prepMinutes,omitempty"`
+	CookMinutes  int       `json:"cookMinutes,omitempty"`
+	TotalMinutes int       `json:"totalMinutes,omitempty"`
+	Ingredients  []string  `json:"ingredients"`
+	Steps        []Step    `json:"steps"`
+	ImportedAt   time.Time `json:"importedAt"`
+}
+
+// Step is one instruction. Seconds is the timer suggested by the step, zero if none.
+type Step struct {
+	Section string `json:"section,omitempty"` // e.g. "For the sauce"
+	Text    string `json:"text"`
+	Seconds int    `json:"seconds,omitempty"`
+}
+
+// Manager keeps the recipe box
+type Manager struct {
+	httpClient *http.Client
+
+	mu       sync.RWMutex
+	doc      *store.Doc
+	recipes  []Recipe
+	onChange func()
+}
+
+// NewManager loads recipes from the store
+func NewManager(doc *store.Doc) *Manager {
+	m := &Manager{
+		httpClient: &http.Client{Timeout: 20 * time.Second},
+		doc:        doc,
+	}
+	if _, err := doc.Load(&m.recipes); err != nil {
+		log.Printf("Warning: Failed to load recipes: %v", err)
+	}
+	return m
+}
+
+// OnChange registers a callback invoked after recipes are imported or deleted
+func (m *Manager) OnChange(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// List returns all recipes ordered by name
+func (m *Manager) List() []Recipe {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := append([]Recipe{}, m.recipes...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+	})
+	return result
+}
+
+// Get returns a recipe by ID
+func (m *Manager) Get(id string) (*Recipe, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	idx := m.indexOf(id)
+	if idx < 0 {
+		return nil, fmt.Errorf("recipe not found: %s", id)
+	}
+	r := m.recipes[idx]
+	return &r, nil
+}
+
+// Import fetches a recipe page and stores the schema.org Recipe it contains.
+// Importing a URL again refreshes the stored copy and keeps its ID.
+func (m *Manager) Import(rawURL string) (*Recipe, error) {
+	r, err := m.fetch(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	r.ImportedAt = time.Now()
+
+	m.mu.Lock()
+	idx := -1
+	for i, existing := range m.recipes {
+		if existing.URL == r.URL {
+			idx = i
+			break
+		}
+	}
+	if idx >= 0 {
+		r.ID = m.recipes[idx].ID
+		m.recipes[idx] = *r
+	} else {
+		r.ID = newID()
+		m.recipes = append(m.recipes, *r)
+	}
+	err = m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	log.Printf("Imported recipe %q (%d ingredients, %d steps)", r.Name, len(r.Ingredients), len(r.Steps))
+	m.notify()
+	return r, nil
+}
+
+// Delete removes a recipe
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("recipe not found: %s", id)
+	}
+	m.recipes = append(m.recipes[:idx], m.recipes[idx+1:]...)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.notify()
+	return nil
+}
+
+// indexOf finds a recipe by ID (caller must hold the lock)
+func (m *Manager) indexOf(id string) int {
+	for i, r := range m.recipes {
+		if r.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save persists recipes (caller must hold the lock)
+func (m *Manager) save() error {
+	if err := m.doc.Save(m.recipes); err != nil {
+		return fmt.Errorf("failed to save recipes: %w", err)
+	}
+	return nil
+}
+
+func (m *Manager) notify() {
+	m.mu.RLock()
+	fn := m.onChange
+	m.mu.RUnlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// newID generates a short random recipe ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
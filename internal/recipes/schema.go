@@ -0,0 +1,292 @@
+package recipes
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// userAgent identifies imports; some recipe sites refuse requests without one
+const userAgent = "Mozilla/5.0 (compatible; home_control recipe import)"
+
+var (
+	ldJSONPattern   = regexp.MustCompile(`(?is)<script[^>]*type=["']?application/ld\+json["']?[^>]*>(.*?)</script>`)
+	tagPattern      = regexp.MustCompile(`<[^>]*>`)
+	spacePattern    = regexp.MustCompile(`\s+`)
+	isoDuration     = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+	durationPattern = regexp.MustCompile(`(?i)\b(\d+(?:\.\d+)?|an?|one|half an?)\s*(?:(?:-|–|to|or)\s*\d+(?:\.\d+)?\s*)?(hours?|hrs?|minutes?|mins?|seconds?|secs?)\b`)
+)
+
+// fetch downloads a page and extracts its recipe
+func (m *Manager) fetch(rawURL string) (*Recipe, error) {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("invalid recipe URL: %s", rawURL)
+	}
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "text/html")
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch recipe: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch recipe: status %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 5<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read recipe page: %w", err)
+	}
+
+	r, err := Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	r.URL = u.String()
+	return r, nil
+}
+
+// Parse extracts the first schema.org Recipe from a page's JSON-LD blocks
+func Parse(page []byte) (*Recipe, error) {
+	for _, match := range ldJSONPattern.FindAllSubmatch(page, -1) {
+		var data interface{}
+		if err := json.Unmarshal(match[1], &data); err != nil {
+			// Some CMSes entity-encode the block
+			if err := json.Unmarshal([]byte(html.UnescapeString(string(match[1]))), &data); err != nil {
+				continue
+			}
+		}
+		if node := findRecipe(data); node != nil {
+			r := fromSchema(node)
+			if r.Name == "" || (len(r.Ingredients) == 0 && len(r.Steps) == 0) {
+				continue
+			}
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("no schema.org recipe found on page")
+}
+
+// findRecipe walks JSON-LD (objects, arrays and @graph) for a node typed Recipe
+func findRecipe(v interface{}) map[string]interface{} {
+	switch node := v.(type) {
+	case []interface{}:
+		for _, item := range node {
+			if r := findRecipe(item); r != nil {
+				return r
+			}
+		}
+	case map[string]interface{}:
+		if hasType(node, "Recipe") {
+			return node
+		}
+		if graph, ok := node["@graph"]; ok {
+			return findRecipe(graph)
+		}
+	}
+	return nil
+}
+
+// hasType reports whether a node's @type is (or includes) typ
+func hasType(node map[string]interface{}, typ string) bool {
+	switch t := node["@type"].(type) {
+	case string:
+		return t == typ
+	case []interface{}:
+		for _, item := range t {
+			if s, ok := item.(string); ok && s == typ {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func fromSchema(node map[string]interface{}) *Recipe {
+	r := &Recipe{
+		Name:         text(node["name"]),
+		Description:  text(node["description"]),
+		Image:        image(node["image"]),
+		Yield:        yield(node["recipeYield"]),
+		PrepMinutes:  ParseDuration(text(node["prepTime"])) / 60,
+		CookMinutes:  ParseDuration(text(node["cookTime"])) / 60,
+		TotalMinutes: ParseDuration(text(node["totalTime"])) / 60,
+		Ingredients:  []string{},
+		Steps:        []Step{},
+	}
+	for _, item := range list(node["recipeIngredient"]) {
+		if s := text(item); s != "" {
+			r.Ingredients = append(r.Ingredients, s)
+		}
+	}
+	r.Steps = steps(node["recipeInstructions"], "")
+	return r
+}
+
+// steps flattens recipeInstructions: a string, a list of strings, HowToSteps or HowToSections
+func steps(v interface{}, section string) []Step {
+	result := []Step{}
+	add := func(s, duration string) {
+		s = text(s)
+		if s == "" {
+			return
+		}
+		step := Step{Section: section, Text: s, Seconds: ParseDuration(duration)}
+		if step.Seconds == 0 {
+			step.Seconds = StepSeconds(s)
+		}
+		result = append(result, step)
+	}
+
+	switch node := v.(type) {
+	case string:
+		// A single block: one step per line or paragraph
+		for _, line := range strings.Split(strings.NewReplacer("<br>", "\n", "<br/>", "\n", "<br />", "\n", "</p>", "\n", "</li>", "\n").Replace(node), "\n") {
+			add(line, "")
+		}
+	case []interface{}:
+		for _, item := range node {
+			result = append(result, steps(item, section)...)
+		}
+	case map[string]interface{}:
+		if hasType(node, "HowToSection") {
+			return steps(node["itemListElement"], text(node["name"]))
+		}
+		s := text(node["text"])
+		if s == "" {
+			s = text(node["name"])
+		}
+		duration := text(node["performTime"])
+		if duration == "" {
+			duration = text(node["totalTime"])
+		}
+		add(s, duration)
+	}
+	return result
+}
+
+// ParseDuration parses an ISO 8601 duration ("PT1H30M") into seconds, 0 if invalid
+func ParseDuration(s string) int {
+	m := isoDuration.FindStringSubmatch(strings.ToUpper(strings.TrimSpace(s)))
+	if m == nil {
+		return 0
+	}
+	days, _ := strconv.Atoi(m[1])
+	hours, _ := strconv.Atoi(m[2])
+	minutes, _ := strconv.Atoi(m[3])
+	seconds, _ := strconv.ParseFloat(m[4], 64)
+	return days*86400 + hours*3600 + minutes*60 + int(seconds)
+}
+
+// StepSeconds finds the timer a step calls for in its text, e.g. "simmer for 20 minutes"
+// -> 1200 or "bake 1 hour 15 minutes" -> 4500. Ranges use the lower bound so the cook
+// checks early; with several separate durations the longest wins. 0 if none.
+func StepSeconds(s string) int {
+	best, current, prevEnd := 0, 0, -1
+	for _, loc := range durationPattern.FindAllStringSubmatchIndex(s, -1) {
+		amount := quantity(strings.ToLower(s[loc[2]:loc[3]]))
+		unit := strings.ToLower(s[loc[4]:loc[5]])
+		var seconds int
+		switch {
+		case strings.HasPrefix(unit, "h"):
+			seconds = int(amount * 3600)
+		case strings.HasPrefix(unit, "m"):
+			seconds = int(amount * 60)
+		default:
+			seconds = int(amount)
+		}
+
+		// "1 hour 15 minutes" and "1 hour and 15 minutes" are one duration
+		gap := ""
+		if prevEnd >= 0 {
+			gap = strings.TrimSpace(s[prevEnd:loc[0]])
+		}
+		if prevEnd >= 0 && (gap == "" || gap == "and") {
+			current += seconds
+		} else {
+			current = seconds
+		}
+		if current > best {
+			best = current
+		}
+		prevEnd = loc[1]
+	}
+	return best
+}
+
+func quantity(s string) float64 {
+	switch s {
+	case "a", "an", "one":
+		return 1
+	case "half a", "half an":
+		return 0.5
+	}
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// text converts a JSON-LD value to plain text, stripping markup and entities
+func text(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		s := tagPattern.ReplaceAllString(t, " ")
+		s = html.UnescapeString(s)
+		return strings.TrimSpace(spacePattern.ReplaceAllString(s, " "))
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case []interface{}:
+		if len(t) > 0 {
+			return text(t[0])
+		}
+	}
+	return ""
+}
+
+// image returns the first URL of a string, list or ImageObject
+func image(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case []interface{}:
+		if len(t) > 0 {
+			return image(t[0])
+		}
+	case map[string]interface{}:
+		return text(t["url"])
+	}
+	return ""
+}
+
+// yield prefers the descriptive form ("4 servings") when sites list both 4 and "4 servings"
+func yield(v interface{}) string {
+	best := ""
+	for _, item := range list(v) {
+		if s := text(item); len(s) > len(best) {
+			best = s
+		}
+	}
+	return best
+}
+
+func list(v interface{}) []interface{} {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return t
+	default:
+		return []interface{}{t}
+	}
+}
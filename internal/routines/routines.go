@@ -0,0 +1,424 @@
+package routines
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/daycontext"
+	"home_control/internal/housemode"
+	"home_control/internal/store"
+)
+
+// Action types
+const (
+	ActionDim          = "dim"         // Dim lights that are on (targets: light.* or hue:<group>, default all)
+	ActionPauseMedia   = "pause_media" // Pause playing media players (default all)
+	ActionDoNotDisturb = "dnd"         // Hold back info notifications until Until; targets are switched on
+	ActionScreensaver  = "screensaver" // Start the screensaver on every tablet
+	ActionLock         = "lock"        // Lock doors (default all locks)
+	ActionTurnOn       = "turn_on"     // Turn entities on
+	ActionTurnOff      = "turn_off"    // Turn entities off
+)
+
+// timeLayout is the format of Stage.At and Action.Until
+const timeLayout = "15:04"
+
+// Action is one step of a stage
+type Action struct {
+	Type       string   `json:"type"`
+	Targets    []string `json:"targets,omitempty"`    // Entity IDs or hue:<group>; empty = all of the kind
+	Brightness int      `json:"brightness,omitempty"` // Percent, for dim
+	Until      string   `json:"until,omitempty"`      // HH:MM, for dnd
+}
+
+// Stage is a set of actions run at a time of day
+type Stage struct {
+	ID       string   `json:"id"`
+	Name     string   `json:"name"`
+	At       string   `json:"at"` // HH:MM
+	Enabled  bool     `json:"enabled"`
+	DayKinds []string `json:"dayKinds,omitempty"` // Day kinds or tags (workday, holiday, school_day, ...) it runs on; empty = any
+	Actions  []Action `json:"actions"`
+}
+
+// Routine is a sequence of stages
+type Routine struct {
+	Enabled bool           `json:"enabled"`
+	Days    []time.Weekday `json:"days,omitempty"`  // Empty = every day
+	Modes   []string       `json:"modes,omitempty"` // House modes the routine runs in; empty = any
+	Stages  []Stage        `json:"stages"`
+}
+
+// StageStatus is a stage with its schedule and latest run
+type StageStatus struct {
+	Stage
+	Next      *time.Time `json:"next,omitempty"`
+	Skipped   bool       `json:"skipped"` // The next run will be skipped
+	LastRun   *time.Time `json:"lastRun,omitempty"`
+	LastError string     `json:"lastError,omitempty"`
+}
+
+// Status is a routine's settings and the state of its stages
+type Status struct {
+	Name    string         `json:"name"`
+	Enabled bool           `json:"enabled"`
+	Days    []time.Weekday `json:"days,omitempty"`
+	Modes   []string       `json:"modes,omitempty"`
+	Stages  []StageStatus  `json:"stages"`
+}
+
+// Job is a scheduled run of a stage
+type Job struct {
+	ID    string // "routine:<name>:<stage>"
+	At    string
+	Days  []time.Weekday
+	Name  string
+	Stage string
+}
+
+type stageRun struct {
+	at  time.Time
+	err string
+}
+
+// Runner runs a routine's stages and keeps their skip and run state
+type Runner struct {
+	name     string
+	timezone *time.Location
+
+	mu       sync.Mutex
+	doc      *store.Doc
+	routine  Routine
+	skips    map[string]time.Time // Stage ID -> skipped run
+	runs     map[string]stageRun
+	exec     func(a Action) error
+	mode     func() string
+	day      func(t time.Time) []string
+	onChange func()
+}
+
+// NewRunner loads a routine from the store, starting from defaults when none is saved
+func NewRunner(name string, doc *store.Doc, timezone *time.Location, defaults Routine) *Runner {
+	if timezone == nil {
+		timezone = time.Local
+	}
+	r := &Runner{
+		name:     name,
+		timezone: timezone,
+		doc:      doc,
+		routine:  defaults,
+		skips:    make(map[string]time.Time),
+		runs:     make(map[string]stageRun),
+	}
+	if _, err := doc.Load(&r.routine); err != nil {
+		log.Printf("Warning: Failed to load %s routine: %v", name, err)
+	}
+	return r
+}
+
+// Name returns the routine's name
+func (r *Runner) Name() string {
+	return r.name
+}
+
+// SetExecutor sets the function that performs actions
+func (r *Runner) SetExecutor(fn func(a Action) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.exec = fn
+}
+
+// SetModeSource sets the function that reports the current house mode, checked
+// against Routine.Modes before scheduled runs
+func (r *Runner) SetModeSource(fn func() string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.mode = fn
+}
+
+// SetDaySource sets the function that reports a date's day kind and tags,
+// checked against Stage.DayKinds before scheduled runs
+func (r *Runner) SetDaySource(fn func(t time.Time) []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.day = fn
+}
+
+// OnChange registers a callback invoked after the routine is updated, so its
+// jobs can be rescheduled
+func (r *Runner) OnChange(fn func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.onChange = fn
+}
+
+// Routine returns the current routine
+func (r *Runner) Routine() Routine {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.routine
+}
+
+// Update validates and saves a routine
+func (r *Runner) Update(routine Routine) error {
+	if err := Validate(routine); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.routine = routine
+	err := r.doc.Save(routine)
+	fn := r.onChange
+	r.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to save %s routine: %w", r.name, err)
+	}
+
+	if fn != nil {
+		fn()
+	}
+	return nil
+}
+
+// Jobs returns the scheduled runs of the enabled stages
+func (r *Runner) Jobs() []Job {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.routine.Enabled {
+		return nil
+	}
+	var jobs []Job
+	for _, st := range r.routine.Stages {
+		if !st.Enabled {
+			continue
+		}
+		jobs = append(jobs, Job{
+			ID:    fmt.Sprintf("routine:%s:%s", r.name, st.ID),
+			At:    st.At,
+			Days:  r.routine.Days,
+			Name:  fmt.Sprintf("Routine %s: %s", r.name, st.Name),
+			Stage: st.ID,
+		})
+	}
+	return jobs
+}
+
+// Skip skips (or un-skips) the next scheduled run of a stage
+func (r *Runner) Skip(stageID string, skip bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	st, ok := r.stage(stageID)
+	if !ok {
+		return fmt.Errorf("stage %s not found", stageID)
+	}
+	if !skip {
+		delete(r.skips, stageID)
+		return nil
+	}
+	next := r.next(st, time.Now())
+	if next.IsZero() {
+		return fmt.Errorf("stage %s isn't scheduled", stageID)
+	}
+	r.skips[stageID] = next
+	return nil
+}
+
+// RunScheduled runs a stage from the scheduler, unless its run was skipped
+func (r *Runner) RunScheduled(stageID string) {
+	r.mu.Lock()
+	skipped, ok := r.skips[stageID]
+	now := time.Now()
+	if ok && now.After(skipped.Add(-time.Minute)) && now.Before(skipped.Add(12*time.Hour)) {
+		delete(r.skips, stageID)
+		r.mu.Unlock()
+		log.Printf("Routine %s: skipped stage %s", r.name, stageID)
+		return
+	}
+	modes, mode := r.routine.Modes, r.mode
+	st, _ := r.stage(stageID)
+	day := r.day
+	r.mu.Unlock()
+
+	if len(modes) > 0 && mode != nil && !slices.Contains(modes, mode()) {
+		log.Printf("Routine %s: skipped stage %s in %s mode", r.name, stageID, mode())
+		return
+	}
+	if len(st.DayKinds) > 0 && day != nil {
+		if tags := day(now); !daycontext.Matches(st.DayKinds, tags) {
+			log.Printf("Routine %s: skipped stage %s on a %s", r.name, stageID, strings.Join(tags, "/"))
+			return
+		}
+	}
+
+	if err := r.Run(stageID); err != nil {
+		log.Printf("Warning: Routine %s stage %s: %v", r.name, stageID, err)
+	}
+}
+
+// Run performs a stage's actions now. Every action is attempted; the errors are
+// joined.
+func (r *Runner) Run(stageID string) error {
+	r.mu.Lock()
+	st, ok := r.stage(stageID)
+	exec := r.exec
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("stage %s not found", stageID)
+	}
+	if exec == nil {
+		return fmt.Errorf("no executor")
+	}
+
+	var errs []string
+	for _, a := range st.Actions {
+		if err := exec(a); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", a.Type, err))
+		}
+	}
+
+	run := stageRun{at: time.Now(), err: strings.Join(errs, "; ")}
+	r.mu.Lock()
+	r.runs[stageID] = run
+	r.mu.Unlock()
+
+	if run.err != "" {
+		return fmt.Errorf("%s", run.err)
+	}
+	return nil
+}
+
+// Status returns the routine with each stage's next run, skip and latest result
+func (r *Runner) Status() Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	status := Status{
+		Name:    r.name,
+		Enabled: r.routine.Enabled,
+		Days:    r.routine.Days,
+		Modes:   r.routine.Modes,
+		Stages:  make([]StageStatus, 0, len(r.routine.Stages)),
+	}
+	for _, st := range r.routine.Stages {
+		ss := StageStatus{Stage: st}
+		if r.routine.Enabled && st.Enabled {
+			if next := r.next(st, now); !next.IsZero() {
+				ss.Next = &next
+				if skipped, ok := r.skips[st.ID]; ok && skipped.Equal(next) {
+					ss.Skipped = true
+				}
+			}
+		}
+		if run, ok := r.runs[st.ID]; ok {
+			at := run.at
+			ss.LastRun = &at
+			ss.LastError = run.err
+		}
+		status.Stages = append(status.Stages, ss)
+	}
+	return status
+}
+
+// stage finds a stage by ID (caller must hold the lock)
+func (r *Runner) stage(id string) (Stage, bool) {
+	for _, st := range r.routine.Stages {
+		if st.ID == id {
+			return st, true
+		}
+	}
+	return Stage{}, false
+}
+
+// next returns a stage's first run after from (caller must hold the lock)
+func (r *Runner) next(st Stage, from time.Time) time.Time {
+	at, err := time.Parse(timeLayout, st.At)
+	if err != nil {
+		return time.Time{}
+	}
+	from = from.In(r.timezone)
+	for i := 0; i <= 7; i++ {
+		day := from.AddDate(0, 0, i)
+		t := time.Date(day.Year(), day.Month(), day.Day(), at.Hour(), at.Minute(), 0, 0, r.timezone)
+		if !t.After(from) || !runsOn(r.routine.Days, t.Weekday()) {
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func runsOn(days []time.Weekday, day time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks a routine's stages and actions
+func Validate(routine Routine) error {
+	seen := make(map[string]bool)
+	for _, d := range routine.Days {
+		if d < time.Sunday || d > time.Saturday {
+			return fmt.Errorf("invalid day %d", d)
+		}
+	}
+	for _, mode := range routine.Modes {
+		if !housemode.Valid(mode) {
+			return fmt.Errorf("unknown house mode %q", mode)
+		}
+	}
+	for _, st := range routine.Stages {
+		if st.ID == "" || strings.Contains(st.ID, ":") {
+			return fmt.Errorf("stage ID is required and can't contain ':'")
+		}
+		if seen[st.ID] {
+			return fmt.Errorf("duplicate stage %s", st.ID)
+		}
+		seen[st.ID] = true
+		if _, err := time.Parse(timeLayout, st.At); err != nil || len(st.At) != len(timeLayout) {
+			return fmt.Errorf("stage %s: invalid time (use HH:MM): %s", st.ID, st.At)
+		}
+		for _, kind := range st.DayKinds {
+			if !daycontext.Valid(kind) {
+				return fmt.Errorf("stage %s: unknown day kind %q", st.ID, kind)
+			}
+		}
+		for _, a := range st.Actions {
+			if err := validateAction(a); err != nil {
+				return fmt.Errorf("stage %s: %w", st.ID, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateAction(a Action) error {
+	switch a.Type {
+	case ActionDim:
+		if a.Brightness < 1 || a.Brightness > 100 {
+			return fmt.Errorf("dim brightness must be between 1 and 100")
+		}
+	case ActionDoNotDisturb:
+		if _, err := time.Parse(timeLayout, a.Until); err != nil {
+			return fmt.Errorf("dnd until must be HH:MM")
+		}
+	case ActionTurnOn, ActionTurnOff:
+		if len(a.Targets) == 0 {
+			return fmt.Errorf("%s needs targets", a.Type)
+		}
+	case ActionPauseMedia, ActionScreensaver, ActionLock:
+	default:
+		return fmt.Errorf("unknown action type %q", a.Type)
+	}
+	return nil
+}
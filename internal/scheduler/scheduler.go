@@ -0,0 +1,165 @@
+package scheduler
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TimeLayout is the format of Job.At
+const TimeLayout = "15:04"
+
+// Job runs a function at a local time of day, on every day or on the given weekdays
+type Job struct {
+	ID   string         `json:"id"` // Owners prefix IDs, e.g. "aquarium:<tank>:..."
+	At   string         `json:"at"` // HH:MM
+	Days []time.Weekday `json:"days,omitempty"`
+	Name string         `json:"name,omitempty"` // Shown in the job list
+	Next time.Time      `json:"next"`
+
+	run func()
+}
+
+// Scheduler runs jobs at times of day. Jobs are registered in code by the
+// features that own them and are not persisted; owners re-register on startup.
+type Scheduler struct {
+	timezone *time.Location
+
+	mu   sync.Mutex
+	jobs map[string]*Job
+	last time.Time // Minute of the latest check
+}
+
+// New creates a scheduler for a timezone
+func New(timezone *time.Location) *Scheduler {
+	if timezone == nil {
+		timezone = time.Local
+	}
+	return &Scheduler{
+		timezone: timezone,
+		jobs:     make(map[string]*Job),
+	}
+}
+
+// Set adds a job or replaces the job with the same ID
+func (s *Scheduler) Set(id, at, name string, days []time.Weekday, run func()) error {
+	if _, err := time.Parse(TimeLayout, at); err != nil {
+		return fmt.Errorf("invalid time (use HH:MM): %s", at)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job := &Job{ID: id, At: at, Days: days, Name: name, run: run}
+	job.Next = s.next(job, time.Now())
+	s.jobs[id] = job
+	return nil
+}
+
+// Remove deletes a job
+func (s *Scheduler) Remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.jobs, id)
+}
+
+// RemovePrefix deletes every job whose ID starts with prefix
+func (s *Scheduler) RemovePrefix(prefix string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id := range s.jobs {
+		if strings.HasPrefix(id, prefix) {
+			delete(s.jobs, id)
+		}
+	}
+}
+
+// List returns jobs whose ID starts with prefix (all when empty), soonest first
+func (s *Scheduler) List(prefix string) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	result := []Job{}
+	for id, job := range s.jobs {
+		if strings.HasPrefix(id, prefix) {
+			result = append(result, *job)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if !result[i].Next.Equal(result[j].Next) {
+			return result[i].Next.Before(result[j].Next)
+		}
+		return result[i].ID < result[j].ID
+	})
+	return result
+}
+
+// Run checks for due jobs every few seconds. It blocks, so call it in a goroutine.
+func (s *Scheduler) Run() {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		s.tick(now)
+	}
+}
+
+// tick runs jobs due in the current minute, once per minute
+func (s *Scheduler) tick(now time.Time) {
+	now = now.In(s.timezone)
+	minute := now.Truncate(time.Minute)
+
+	s.mu.Lock()
+	if !minute.After(s.last) {
+		s.mu.Unlock()
+		return
+	}
+	s.last = minute
+	var due []*Job
+	for _, job := range s.jobs {
+		if !job.Next.IsZero() && !job.Next.After(now) {
+			due = append(due, job)
+			job.Next = s.next(job, minute.Add(time.Minute))
+		}
+	}
+	s.mu.Unlock()
+
+	for _, job := range due {
+		go func(job *Job) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Scheduled job %s panicked: %v", job.ID, r)
+				}
+			}()
+			job.run()
+		}(job)
+	}
+}
+
+// next returns the first time at or after from that a job should run
+func (s *Scheduler) next(job *Job, from time.Time) time.Time {
+	at, _ := time.Parse(TimeLayout, job.At)
+	from = from.In(s.timezone)
+	for i := 0; i <= 7; i++ {
+		day := from.AddDate(0, 0, i)
+		t := time.Date(day.Year(), day.Month(), day.Day(), at.Hour(), at.Minute(), 0, 0, s.timezone)
+		if t.Before(from.Truncate(time.Minute)) || !runsOn(job.Days, t.Weekday()) {
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func runsOn(days []time.Weekday, day time.Weekday) bool {
+	if len(days) == 0 {
+		return true
+	}
+	for _, d := range days {
+		if d == day {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package screentime
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Report sources
+const (
+	SourceTablet = "tablet" // Android usage stats from the kiosk/kids' tablets
+	SourceRouter = "router" // Per-device online time/traffic pushed by a router script
+)
+
+// DateLayout is the format of Report.Date
+const DateLayout = "2006-01-02"
+
+// eventType is the store event type used for reports
+const eventType = "screentime"
+
+// AppUsage is foreground time for one app
+type AppUsage struct {
+	App     string  `json:"app"`            // Package name, e.g. com.google.android.youtube
+	Name    string  `json:"name,omitempty"` // Display label
+	Minutes float64 `json:"minutes"`
+}
+
+// Report is a device's cumulative usage for one day. Devices report repeatedly
+// during the day; the latest report for a device and date wins.
+type Report struct {
+	Device       string     `json:"device"`
+	Source       string     `json:"source"`
+	Date         string     `json:"date"` // YYYY-MM-DD, defaults to today
+	TotalMinutes float64    `json:"totalMinutes"`
+	Apps         []AppUsage `json:"apps,omitempty"`
+	Bytes        int64      `json:"bytes,omitempty"` // Router traffic
+	ReportedAt   time.Time  `json:"reportedAt"`
+}
+
+// DayUsage is a device's usage on one day
+type DayUsage struct {
+	Date    string  `json:"date"`
+	Minutes float64 `json:"minutes"`
+	Bytes   int64   `json:"bytes,omitempty"`
+}
+
+// DeviceSummary is a device's usage over the summary window
+type DeviceSummary struct {
+	Device         string     `json:"device"`
+	Source         string     `json:"source"`
+	TodayMinutes   float64    `json:"todayMinutes"`
+	AverageMinutes float64    `json:"averageMinutes"` // Per day over the days with reports
+	TopApps        []AppUsage `json:"topApps,omitempty"`
+	Days           []DayUsage `json:"days"`
+	LastReport     time.Time  `json:"lastReport"`
+}
+
+// Summary is usage for every reporting device, most used today first
+type Summary struct {
+	Date    string          `json:"date"`
+	Days    int             `json:"days"`
+	Devices []DeviceSummary `json:"devices"`
+}
+
+// Tracker stores usage reports
+type Tracker struct {
+	store    *store.Store
+	timezone *time.Location
+}
+
+// NewTracker creates a tracker, pruning reports older than retention
+func NewTracker(st *store.Store, timezone *time.Location, retention time.Duration) *Tracker {
+	if timezone == nil {
+		timezone = time.Local
+	}
+	if retention > 0 {
+		if n, err := st.PruneEvents(eventType, time.Now().Add(-retention)); err != nil {
+			log.Printf("Warning: Failed to prune screen time reports: %v", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d old screen time reports", n)
+		}
+	}
+	return &Tracker{store: st, timezone: timezone}
+}
+
+// Record validates and stores a report. TotalMinutes defaults to the sum of the apps.
+func (t *Tracker) Record(r Report) (Report, error) {
+	if r.Device == "" {
+		return r, fmt.Errorf("device is required")
+	}
+	switch r.Source {
+	case "":
+		r.Source = SourceTablet
+	case SourceTablet, SourceRouter:
+	default:
+		return r, fmt.Errorf("invalid source: %s", r.Source)
+	}
+	if r.Date == "" {
+		r.Date = time.Now().In(t.timezone).Format(DateLayout)
+	} else if _, err := time.Parse(DateLayout, r.Date); err != nil {
+		return r, fmt.Errorf("date must be YYYY-MM-DD: %s", r.Date)
+	}
+	if r.TotalMinutes < 0 || r.Bytes < 0 {
+		return r, fmt.Errorf("usage cannot be negative")
+	}
+	if r.TotalMinutes == 0 {
+		for _, app := range r.Apps {
+			r.TotalMinutes += app.Minutes
+		}
+	}
+	r.ReportedAt = time.Now()
+
+	if _, err := t.store.AppendEvent(r.ReportedAt, eventType, r.Device, r); err != nil {
+		return r, fmt.Errorf("failed to record screen time: %w", err)
+	}
+	return r, nil
+}
+
+// Summary returns usage for the last days (including today). An empty device
+// returns every device.
+func (t *Tracker) Summary(days int, device string) (Summary, error) {
+	now := time.Now().In(t.timezone)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, t.timezone)
+	first := today.AddDate(0, 0, -(days - 1))
+	summary := Summary{Date: today.Format(DateLayout), Days: days, Devices: []DeviceSummary{}}
+
+	events, err := t.store.Events(eventType, first, 0)
+	if err != nil {
+		return summary, fmt.Errorf("failed to load screen time: %w", err)
+	}
+
+	// Latest report per device and date (events are oldest first)
+	latest := make(map[string]map[string]Report)
+	firstDate := first.Format(DateLayout)
+	for _, e := range events {
+		if device != "" && e.Source != device {
+			continue
+		}
+		var r Report
+		if err := json.Unmarshal(e.Data, &r); err != nil || r.Date < firstDate {
+			continue
+		}
+		if latest[r.Device] == nil {
+			latest[r.Device] = make(map[string]Report)
+		}
+		latest[r.Device][r.Date] = r
+	}
+
+	for name, byDate := range latest {
+		ds := DeviceSummary{Device: name, Days: []DayUsage{}}
+		var total float64
+		for d := first; !d.After(today); d = d.AddDate(0, 0, 1) {
+			date := d.Format(DateLayout)
+			r, ok := byDate[date]
+			if !ok {
+				continue
+			}
+			ds.Days = append(ds.Days, DayUsage{Date: date, Minutes: r.TotalMinutes, Bytes: r.Bytes})
+			total += r.TotalMinutes
+			ds.Source = r.Source
+			if r.ReportedAt.After(ds.LastReport) {
+				ds.LastReport = r.ReportedAt
+			}
+			if date == summary.Date {
+				ds.TodayMinutes = r.TotalMinutes
+				ds.TopApps = topApps(r.Apps, 5)
+			}
+		}
+		if len(ds.Days) > 0 {
+			ds.AverageMinutes = total / float64(len(ds.Days))
+		}
+		summary.Devices = append(summary.Devices, ds)
+	}
+	sort.Slice(summary.Devices, func(i, j int) bool {
+		a, b := summary.Devices[i], summary.Devices[j]
+		if a.TodayMinutes != b.TodayMinutes {
+			return a.TodayMinutes > b.TodayMinutes
+		}
+		return a.Device < b.Device
+	})
+	return summary, nil
+}
+
+// topApps returns the n most used apps
+func topApps(apps []AppUsage, n int) []AppUsage {
+	sorted := append([]AppUsage{}, apps...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Minutes > sorted[j].Minutes
+	})
+	if len(sorted) > n {
+		sorted = sorted[:n]
+	}
+	return sorted
+}
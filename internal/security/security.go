@@ -0,0 +1,252 @@
+package security
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/notify"
+	"home_control/internal/store"
+)
+
+// Signal kinds
+const (
+	KindMotion   = "motion"   // Motion/occupancy sensor tripped
+	KindPerson   = "person"   // Camera person detection
+	KindDoor     = "door"     // Door, window or contact sensor opened
+	KindDoorbell = "doorbell" // Doorbell pressed
+)
+
+// eventType is the store event type used for signals
+const eventType = "security"
+
+// Signal is a single security-relevant event observed while nobody is home
+type Signal struct {
+	Time    time.Time `json:"time"`
+	Kind    string    `json:"kind"`
+	Subject string    `json:"subject"`          // Entity ID or camera name
+	Name    string    `json:"name,omitempty"`   // Display name
+	Detail  string    `json:"detail,omitempty"` // e.g. detection score
+}
+
+// Incident groups signals that happened close together
+type Incident struct {
+	ID       int64          `json:"id"` // Event ID of the first signal
+	Start    time.Time      `json:"start"`
+	End      time.Time      `json:"end"`
+	Open     bool           `json:"open"` // More signals may still join
+	Severity string         `json:"severity"`
+	Summary  string         `json:"summary"`
+	Counts   map[string]int `json:"counts"` // Signals per kind
+	Timeline []Signal       `json:"timeline"`
+}
+
+// Monitor records signals and groups them into incidents
+type Monitor struct {
+	store *store.Store
+	gap   time.Duration
+
+	mu         sync.Mutex
+	current    *Incident
+	onIncident func(inc Incident, escalated bool)
+}
+
+// NewMonitor creates a monitor. Signals less than gap apart join the same
+// incident. Signals older than retention are pruned.
+func NewMonitor(st *store.Store, gap, retention time.Duration) *Monitor {
+	if retention > 0 {
+		if n, err := st.PruneEvents(eventType, time.Now().Add(-retention)); err != nil {
+			log.Printf("Warning: Failed to prune security signals: %v", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d old security signals", n)
+		}
+	}
+	return &Monitor{store: st, gap: gap}
+}
+
+// OnIncident registers a callback invoked when an incident starts (escalated false)
+// or when a later signal raises its severity (escalated true). Signals that don't
+// change an incident's severity are recorded silently.
+func (m *Monitor) OnIncident(fn func(inc Incident, escalated bool)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onIncident = fn
+}
+
+// Record stores a signal and adds it to the current incident. Time defaults to now.
+func (m *Monitor) Record(s Signal) (Incident, error) {
+	if s.Time.IsZero() {
+		s.Time = time.Now()
+	}
+	if s.Name == "" {
+		s.Name = s.Subject
+	}
+
+	id, err := m.store.AppendEvent(s.Time, eventType, s.Kind, s)
+	if err != nil {
+		return Incident{}, fmt.Errorf("failed to record security signal: %w", err)
+	}
+
+	m.mu.Lock()
+	isNew := m.current == nil || s.Time.Sub(m.current.End) > m.gap
+	if isNew {
+		m.current = &Incident{ID: id, Start: s.Time}
+	}
+	prevSeverity := m.current.Severity
+	m.current.add(s)
+	inc := m.current.snapshot(true)
+	escalated := !isNew && severityRank(inc.Severity) > severityRank(prevSeverity)
+	fn := m.onIncident
+	m.mu.Unlock()
+
+	if fn != nil && (isNew || escalated) {
+		fn(inc, escalated)
+	}
+	return inc, nil
+}
+
+// Incidents returns incidents with signals since the given time, newest first
+func (m *Monitor) Incidents(since time.Time) ([]Incident, error) {
+	events, err := m.store.Events(eventType, since, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load security signals: %w", err)
+	}
+
+	var incidents []*Incident
+	var cur *Incident
+	for _, e := range events {
+		var s Signal
+		if err := json.Unmarshal(e.Data, &s); err != nil {
+			continue
+		}
+		if cur == nil || s.Time.Sub(cur.End) > m.gap {
+			cur = &Incident{ID: e.ID, Start: s.Time}
+			incidents = append(incidents, cur)
+		}
+		cur.add(s)
+	}
+
+	result := make([]Incident, 0, len(incidents))
+	for i := len(incidents) - 1; i >= 0; i-- {
+		inc := incidents[i]
+		result = append(result, inc.snapshot(time.Since(inc.End) <= m.gap))
+	}
+	return result, nil
+}
+
+// ClassifyEntity guesses the signal kind of a sensor from its entity ID, e.g.
+// binary_sensor.driveway_person_occupancy -> person, binary_sensor.back_door -> door.
+// Returns "" for entities that aren't security sensors.
+func ClassifyEntity(entityID string) string {
+	id := strings.ToLower(entityID)
+	switch {
+	case strings.Contains(id, "person"):
+		return KindPerson
+	case strings.Contains(id, "motion"), strings.Contains(id, "occupancy"), strings.Contains(id, "presence"):
+		return KindMotion
+	case strings.Contains(id, "door"), strings.Contains(id, "window"), strings.Contains(id, "contact"), strings.Contains(id, "opening"):
+		return KindDoor
+	default:
+		return ""
+	}
+}
+
+// add appends a signal and recomputes severity and summary
+func (inc *Incident) add(s Signal) {
+	inc.Timeline = append(inc.Timeline, s)
+	if s.Time.After(inc.End) {
+		inc.End = s.Time
+	}
+	if inc.Counts == nil {
+		inc.Counts = make(map[string]int)
+	}
+	inc.Counts[s.Kind]++
+	inc.Severity = severity(inc.Counts)
+	inc.Summary = summarize(inc.Timeline)
+}
+
+// snapshot copies an incident so it can be handed out
+func (inc *Incident) snapshot(open bool) Incident {
+	c := *inc
+	c.Open = open
+	c.Timeline = append([]Signal{}, inc.Timeline...)
+	c.Counts = make(map[string]int, len(inc.Counts))
+	for k, v := range inc.Counts {
+		c.Counts[k] = v
+	}
+	return c
+}
+
+// severity rates an incident: a person on camera or an opened door together with
+// motion is critical, an opened door or doorbell alone is a warning, and motion
+// alone is informational (pets, curtains, sunlight).
+func severity(counts map[string]int) string {
+	switch {
+	case counts[KindPerson] > 0, counts[KindDoor] > 0 && counts[KindMotion] > 0:
+		return notify.SeverityCritical
+	case counts[KindDoor] > 0, counts[KindDoorbell] > 0:
+		return notify.SeverityWarning
+	default:
+		return notify.SeverityInfo
+	}
+}
+
+func severityRank(s string) int {
+	switch s {
+	case notify.SeverityCritical:
+		return 2
+	case notify.SeverityWarning:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// summarize describes an incident, e.g. "Person at Driveway, Back Door opened, motion in Hallway"
+func summarize(timeline []Signal) string {
+	order := []string{KindPerson, KindDoor, KindDoorbell, KindMotion}
+	names := make(map[string][]string)
+	for _, s := range timeline {
+		if !contains(names[s.Kind], s.Name) {
+			names[s.Kind] = append(names[s.Kind], s.Name)
+		}
+	}
+
+	var parts []string
+	for _, kind := range order {
+		list := names[kind]
+		if len(list) == 0 {
+			continue
+		}
+		sort.Strings(list)
+		joined := strings.Join(list, ", ")
+		switch kind {
+		case KindPerson:
+			parts = append(parts, "person at "+joined)
+		case KindDoor:
+			parts = append(parts, joined+" opened")
+		case KindDoorbell:
+			parts = append(parts, "doorbell rang")
+		case KindMotion:
+			parts = append(parts, "motion in "+joined)
+		}
+	}
+	summary := strings.Join(parts, ", ")
+	if summary == "" {
+		return summary
+	}
+	return strings.ToUpper(summary[:1]) + summary[1:]
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,249 @@
+package shading
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/lighting"
+	"home_control/internal/store"
+)
+
+// Interval is how often the rules are evaluated
+const Interval = 5 * time.Minute
+
+// Rule defaults
+const (
+	DefaultSpread       = 60.0 // Sun within this many degrees of the facade's direction hits it
+	DefaultMinElevation = 5.0  // Lower, trees and buildings usually block the sun
+	DefaultHysteresis   = 1.0  // Degrees below Above before opening again
+)
+
+// Rule shades a cover on a facade while the sun shines on it and the room is warm
+type Rule struct {
+	ID           string  `json:"id"`
+	Name         string  `json:"name"`
+	Enabled      bool    `json:"enabled"`
+	Cover        string  `json:"cover"`                  // cover.* entity
+	Facing       float64 `json:"facing"`                 // Direction the facade faces, degrees clockwise from north (south = 180)
+	Spread       float64 `json:"spread,omitempty"`       // Degrees either side of Facing that count as direct sun
+	MinElevation float64 `json:"minElevation,omitempty"` // Ignore the sun below this elevation
+	Sensor       string  `json:"sensor"`                 // Indoor temperature sensor
+	Above        float64 `json:"above"`                  // Shade when the room is warmer than this
+	Hysteresis   float64 `json:"hysteresis,omitempty"`   // Open again once below Above minus this
+	Position     int     `json:"position"`               // Cover position while shading, 0 (closed) - 100 (open)
+	Tilt         *int    `json:"tilt,omitempty"`         // Slat tilt while shading, for venetian blinds
+}
+
+// Status is a rule's latest evaluation
+type Status struct {
+	Rule       string    `json:"rule"`
+	Name       string    `json:"name"`
+	Cover      string    `json:"cover"`
+	SunOn      bool      `json:"sunOn"` // Direct sun on the facade
+	Indoor     *float64  `json:"indoor,omitempty"`
+	Shading    bool      `json:"shading"`
+	Reason     string    `json:"reason"`
+	Evaluated  time.Time `json:"evaluated"`
+	SunAzimuth float64   `json:"sunAzimuth"`
+	SunElev    float64   `json:"sunElevation"`
+}
+
+// Manager evaluates the shading rules against the sun and indoor temperatures
+type Manager struct {
+	lat, lon    float64
+	temperature func(entity string) (float64, error)
+	apply       func(r Rule, shade bool) error
+
+	mu       sync.RWMutex
+	doc      *store.Doc
+	rules    []Rule
+	statuses map[string]Status
+	shading  map[string]bool // Rule ID -> shading applied; covers are only moved on changes
+}
+
+// NewManager loads the rules. temperature reads a sensor; apply moves a rule's
+// cover to its shading position or opens it.
+func NewManager(doc *store.Doc, lat, lon float64, temperature func(entity string) (float64, error), apply func(r Rule, shade bool) error) *Manager {
+	m := &Manager{
+		lat:         lat,
+		lon:         lon,
+		temperature: temperature,
+		apply:       apply,
+		doc:         doc,
+		rules:       []Rule{},
+		statuses:    make(map[string]Status),
+		shading:     make(map[string]bool),
+	}
+	if _, err := doc.Load(&m.rules); err != nil {
+		log.Printf("Warning: Failed to load shading rules: %v", err)
+	}
+	return m
+}
+
+// Rules returns all rules
+func (m *Manager) Rules() []Rule {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return append([]Rule{}, m.rules...)
+}
+
+// Update validates and replaces the rules. Rules without an ID get one.
+func (m *Manager) Update(rules []Rule) error {
+	if rules == nil {
+		rules = []Rule{}
+	}
+	for i := range rules {
+		r := &rules[i]
+		if !strings.HasPrefix(r.Cover, "cover.") {
+			return fmt.Errorf("rule %q: cover must be a cover entity", r.Name)
+		}
+		if r.Sensor == "" {
+			return fmt.Errorf("rule %q: sensor is required", r.Name)
+		}
+		if r.Facing < 0 || r.Facing >= 360 {
+			return fmt.Errorf("rule %q: facing must be between 0 and 359", r.Name)
+		}
+		if r.Spread == 0 {
+			r.Spread = DefaultSpread
+		}
+		if r.Spread < 5 || r.Spread > 90 {
+			return fmt.Errorf("rule %q: spread must be between 5 and 90", r.Name)
+		}
+		if r.MinElevation == 0 {
+			r.MinElevation = DefaultMinElevation
+		}
+		if r.Hysteresis == 0 {
+			r.Hysteresis = DefaultHysteresis
+		}
+		if r.Position < 0 || r.Position > 100 || (r.Tilt != nil && (*r.Tilt < 0 || *r.Tilt > 100)) {
+			return fmt.Errorf("rule %q: position and tilt must be between 0 and 100", r.Name)
+		}
+		if r.Name == "" {
+			r.Name = r.Cover
+		}
+		if r.ID == "" {
+			r.ID = newID()
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.doc.Save(rules); err != nil {
+		return fmt.Errorf("failed to save shading rules: %w", err)
+	}
+	m.rules = rules
+	return nil
+}
+
+// Statuses returns each rule's latest evaluation
+func (m *Manager) Statuses() []Status {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]Status, 0, len(m.rules))
+	for _, r := range m.rules {
+		if s, ok := m.statuses[r.ID]; ok {
+			result = append(result, s)
+		}
+	}
+	return result
+}
+
+// Run evaluates the rules every Interval. It blocks, so call it in a goroutine.
+func (m *Manager) Run() {
+	ticker := time.NewTicker(Interval)
+	defer ticker.Stop()
+	for {
+		m.Tick(time.Now())
+		<-ticker.C
+	}
+}
+
+// Tick evaluates every enabled rule and moves covers whose decision changed.
+// Covers aren't touched otherwise, so opening one by hand sticks until the
+// sun or temperature changes the decision.
+func (m *Manager) Tick(now time.Time) {
+	sun := lighting.Sun(now, m.lat, m.lon)
+	for _, r := range m.Rules() {
+		if !r.Enabled {
+			continue
+		}
+		status := Status{Rule: r.ID, Name: r.Name, Cover: r.Cover, Evaluated: now, SunAzimuth: round1(sun.Azimuth), SunElev: round1(sun.Elevation)}
+		status.SunOn = SunOnFacade(sun, r.Facing, r.Spread, r.MinElevation)
+
+		m.mu.RLock()
+		wasShading := m.shading[r.ID]
+		m.mu.RUnlock()
+
+		indoor, err := m.temperature(r.Sensor)
+		if err != nil {
+			status.Shading = wasShading
+			status.Reason = "Temperature unavailable: " + err.Error()
+			m.setStatus(status)
+			continue
+		}
+		status.Indoor = &indoor
+
+		switch {
+		case !status.SunOn:
+			status.Reason = "No direct sun"
+		case indoor > r.Above:
+			status.Shading = true
+			status.Reason = fmt.Sprintf("Sun on the facade and %.1f° indoors", indoor)
+		case wasShading && indoor > r.Above-r.Hysteresis:
+			status.Shading = true
+			status.Reason = "Cooling down"
+		default:
+			status.Reason = "Cool enough"
+		}
+
+		if status.Shading != wasShading {
+			if err := m.apply(r, status.Shading); err != nil {
+				log.Printf("Warning: Failed to move %s for shading: %v", r.Cover, err)
+				status.Shading = wasShading
+				status.Reason = "Failed to move cover: " + err.Error()
+			} else {
+				action := "opened"
+				if status.Shading {
+					action = "closed"
+				}
+				log.Printf("Shading: %s %s (%s)", r.Name, action, status.Reason)
+				m.mu.Lock()
+				m.shading[r.ID] = status.Shading
+				m.mu.Unlock()
+			}
+		}
+		m.setStatus(status)
+	}
+}
+
+// SunOnFacade reports whether the sun is high enough and within spread degrees
+// of the direction a facade faces
+func SunOnFacade(sun lighting.SunPosition, facing, spread, minElevation float64) bool {
+	if sun.Elevation < minElevation {
+		return false
+	}
+	diff := math.Abs(math.Mod(sun.Azimuth-facing+540, 360) - 180)
+	return diff <= spread
+}
+
+func (m *Manager) setStatus(s Status) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.statuses[s.Rule] = s
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,141 @@
+package sleeptimer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MaxMinutes caps a sleep timer
+const MaxMinutes = 240
+
+// Actions are what happens when the timer runs out
+type Actions struct {
+	Spotify bool     `json:"spotify"`          // Pause Spotify
+	Sony    []string `json:"sony,omitempty"`   // Sony device names to power off
+	Shield  []string `json:"shield,omitempty"` // Shield device names to put to sleep
+	Lights  []string `json:"lights,omitempty"` // hue:<group ID> or light.* entities to turn off
+}
+
+// Status is the running timer, if any
+type Status struct {
+	Active    bool       `json:"active"`
+	Minutes   int        `json:"minutes,omitempty"`
+	EndsAt    *time.Time `json:"endsAt,omitempty"`
+	Remaining int        `json:"remaining"` // Seconds
+	Actions   *Actions   `json:"actions,omitempty"`
+}
+
+// Timer is the household's single sleep timer. It isn't persisted; a restart
+// cancels it.
+type Timer struct {
+	run func(Actions)
+
+	mu       sync.Mutex
+	timer    *time.Timer
+	minutes  int
+	endsAt   time.Time
+	actions  Actions
+	onChange func(Status)
+}
+
+// New creates a sleep timer that calls run when it expires
+func New(run func(Actions)) *Timer {
+	return &Timer{run: run}
+}
+
+// OnChange registers a callback invoked when the timer starts, is cancelled
+// or runs out
+func (t *Timer) OnChange(fn func(Status)) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.onChange = fn
+}
+
+// Start sets the timer, replacing one already running
+func (t *Timer) Start(minutes int, actions Actions) (Status, error) {
+	if minutes < 1 || minutes > MaxMinutes {
+		return Status{}, fmt.Errorf("minutes must be between 1 and %d", MaxMinutes)
+	}
+	if !actions.Spotify && len(actions.Sony) == 0 && len(actions.Shield) == 0 && len(actions.Lights) == 0 {
+		return Status{}, fmt.Errorf("the sleep timer does nothing")
+	}
+
+	t.mu.Lock()
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+	t.minutes = minutes
+	t.actions = actions
+	t.endsAt = time.Now().Add(time.Duration(minutes) * time.Minute)
+	var timer *time.Timer
+	timer = time.AfterFunc(time.Until(t.endsAt), func() { t.expire(timer) })
+	t.timer = timer
+	s := t.status()
+	t.mu.Unlock()
+
+	t.notify(s)
+	return s, nil
+}
+
+// Status returns the running timer
+func (t *Timer) Status() Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.status()
+}
+
+// Cancel stops the timer, reporting whether one was running
+func (t *Timer) Cancel() bool {
+	t.mu.Lock()
+	if t.timer == nil {
+		t.mu.Unlock()
+		return false
+	}
+	t.timer.Stop()
+	t.timer = nil
+	s := t.status()
+	t.mu.Unlock()
+
+	t.notify(s)
+	return true
+}
+
+// expire runs the actions unless timer was replaced or cancelled meanwhile
+func (t *Timer) expire(timer *time.Timer) {
+	t.mu.Lock()
+	if t.timer != timer {
+		t.mu.Unlock()
+		return
+	}
+	t.timer = nil
+	actions := t.actions
+	s := t.status()
+	t.mu.Unlock()
+
+	t.run(actions)
+	t.notify(s)
+}
+
+// status builds the current state (caller must hold the lock)
+func (t *Timer) status() Status {
+	if t.timer == nil {
+		return Status{}
+	}
+	endsAt := t.endsAt
+	actions := t.actions
+	remaining := int(time.Until(endsAt).Seconds())
+	if remaining < 0 {
+		remaining = 0
+	}
+	return Status{Active: true, Minutes: t.minutes, EndsAt: &endsAt, Remaining: remaining, Actions: &actions}
+}
+
+func (t *Timer) notify(s Status) {
+	t.mu.Lock()
+	fn := t.onChange
+	t.mu.Unlock()
+	if fn != nil {
+		fn(s)
+	}
+}
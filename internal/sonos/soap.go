@@ -0,0 +1,110 @@
+package sonos
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Port is the port every Sonos speaker serves UPnP on
+const Port = 1400
+
+// UPnP services used, with their control paths
+type service struct {
+	urn  string
+	path string
+}
+
+var (
+	avTransport       = service{"urn:schemas-upnp-org:service:AVTransport:1", "/MediaRenderer/AVTransport/Control"}
+	renderingControl  = service{"urn:schemas-upnp-org:service:RenderingControl:1", "/MediaRenderer/RenderingControl/Control"}
+	zoneGroupTopology = service{"urn:schemas-upnp-org:service:ZoneGroupTopology:1", "/ZoneGroupTopology/Control"}
+)
+
+// arg is a SOAP action argument; order matters to some firmware
+type arg struct {
+	name, value string
+}
+
+// call invokes a UPnP action on a speaker and returns the response arguments
+func (m *Manager) call(ctx context.Context, host string, svc service, action string, args ...arg) (map[string]string, error) {
+	var body strings.Builder
+	body.WriteString(`<?xml version="1.0" encoding="utf-8"?>`)
+	body.WriteString(`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&body, `<u:%s xmlns:u="%s">`, action, svc.urn)
+	for _, a := range args {
+		fmt.Fprintf(&body, "<%s>%s</%s>", a.name, html.EscapeString(a.value), a.name)
+	}
+	fmt.Fprintf(&body, "</u:%s></s:Body></s:Envelope>", action)
+
+	url := fmt.Sprintf("http://%s:%d%s", host, Port, svc.path)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader([]byte(body.String())))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPACTION", fmt.Sprintf(`"%s#%s"`, svc.urn, action))
+
+	resp, err := m.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s failed: %s%s", action, resp.Status, upnpError(data))
+	}
+	return responseArgs(data, action+"Response")
+}
+
+// responseArgs reads the children of the <actionResponse> element
+func responseArgs(data []byte, element string) (map[string]string, error) {
+	dec := xml.NewDecoder(bytes.NewReader(data))
+	result := make(map[string]string)
+	inResponse := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid SOAP response: %w", err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local == element {
+			inResponse = true
+			continue
+		}
+		if inResponse {
+			var value string
+			if err := dec.DecodeElement(&value, &start); err != nil {
+				return nil, fmt.Errorf("invalid SOAP response: %w", err)
+			}
+			result[start.Name.Local] = value
+		}
+	}
+	return result, nil
+}
+
+// upnpError extracts the error code from a SOAP fault, e.g. " (UPnP error 701)"
+func upnpError(data []byte) string {
+	var fault struct {
+		Code string `xml:"Body>Fault>detail>UPnPError>errorCode"`
+	}
+	if xml.Unmarshal(data, &fault) == nil && fault.Code != "" {
+		return " (UPnP error " + fault.Code + ")"
+	}
+	return ""
+}
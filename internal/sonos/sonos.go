@@ -0,0 +1,435 @@
+package sonos
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Room is a Sonos speaker (or bonded set, e.g. a soundbar with surrounds)
+type Room struct {
+	ID     string `json:"id"` // RINCON_... UUID
+	Name   string `json:"name"`
+	Host   string `json:"host"`
+	Volume int    `json:"volume"`
+	Muted  bool   `json:"muted"`
+}
+
+// NowPlaying is what a zone is playing
+type NowPlaying struct {
+	State    string `json:"state"` // playing, paused, stopped, transitioning
+	Title    string `json:"title,omitempty"`
+	Artist   string `json:"artist,omitempty"`
+	Album    string `json:"album,omitempty"`
+	ArtURL   string `json:"artUrl,omitempty"`
+	Duration int    `json:"duration,omitempty"` // Seconds
+	Position int    `json:"position,omitempty"` // Seconds
+	Source   string `json:"source,omitempty"`   // spotify, radio, line-in, tv, ...
+}
+
+// Zone is a group of rooms playing in sync, led by a coordinator
+type Zone struct {
+	ID          string      `json:"id"`          // Coordinator's room ID
+	Name        string      `json:"name"`        // "Kitchen + 2"
+	Coordinator string      `json:"coordinator"` // Room name
+	Rooms       []Room      `json:"rooms"`
+	NowPlaying  *NowPlaying `json:"nowPlaying,omitempty"`
+}
+
+// Manager controls Sonos speakers over UPnP. The rooms and zones come from the
+// topology any one speaker reports, so only a few hosts need configuring.
+type Manager struct {
+	hosts      []string
+	httpClient *http.Client
+}
+
+// NewManager creates a manager for speakers reachable at hosts (IPs or names)
+func NewManager(hosts []string) *Manager {
+	return &Manager{
+		hosts:      hosts,
+		httpClient: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+type zoneGroupXML struct {
+	Coordinator string `xml:"Coordinator,attr"`
+	Members     []struct {
+		UUID      string `xml:"UUID,attr"`
+		Location  string `xml:"Location,attr"`
+		ZoneName  string `xml:"ZoneName,attr"`
+		Invisible string `xml:"Invisible,attr"`
+	} `xml:"ZoneGroupMember"`
+}
+
+// topology reads the zone groups from the first speaker that answers
+func (m *Manager) topology(ctx context.Context) ([]zoneGroupXML, error) {
+	var lastErr error
+	for _, host := range m.hosts {
+		out, err := m.call(ctx, host, zoneGroupTopology, "GetZoneGroupState")
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return parseZoneGroups(out["ZoneGroupState"])
+	}
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no Sonos hosts configured")
+	}
+	return nil, lastErr
+}
+
+// parseZoneGroups reads every <ZoneGroup>, whether wrapped in <ZoneGroupState>
+// (newer firmware) or not
+func parseZoneGroups(state string) ([]zoneGroupXML, error) {
+	dec := xml.NewDecoder(strings.NewReader(state))
+	var groups []zoneGroupXML
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid zone group state: %w", err)
+		}
+		if start, ok := tok.(xml.StartElement); ok && start.Name.Local == "ZoneGroup" {
+			var g zoneGroupXML
+			if err := dec.DecodeElement(&g, &start); err != nil {
+				return nil, fmt.Errorf("invalid zone group state: %w", err)
+			}
+			groups = append(groups, g)
+		}
+	}
+	return groups, nil
+}
+
+// Rooms returns every visible room, by name
+func (m *Manager) Rooms(ctx context.Context) ([]Room, error) {
+	groups, err := m.topology(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var rooms []Room
+	for _, g := range groups {
+		for _, member := range g.Members {
+			if member.Invisible == "1" {
+				continue
+			}
+			rooms = append(rooms, Room{ID: member.UUID, Name: member.ZoneName, Host: hostOf(member.Location)})
+		}
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].Name < rooms[j].Name })
+	return rooms, nil
+}
+
+// Zones returns the current groups with each room's volume and what's playing
+func (m *Manager) Zones(ctx context.Context) ([]Zone, error) {
+	groups, err := m.topology(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	zones := []Zone{}
+	for _, g := range groups {
+		zone := Zone{ID: g.Coordinator, Rooms: []Room{}}
+		var coordinatorHost string
+		for _, member := range g.Members {
+			if member.Invisible == "1" {
+				continue
+			}
+			room := Room{ID: member.UUID, Name: member.ZoneName, Host: hostOf(member.Location)}
+			room.Volume, room.Muted, _ = m.volume(ctx, room.Host)
+			if member.UUID == g.Coordinator {
+				zone.Coordinator = room.Name
+				coordinatorHost = room.Host
+				zone.Rooms = append([]Room{room}, zone.Rooms...)
+			} else {
+				zone.Rooms = append(zone.Rooms, room)
+			}
+		}
+		if len(zone.Rooms) == 0 || coordinatorHost == "" {
+			continue
+		}
+		zone.Name = zone.Coordinator
+		if len(zone.Rooms) > 1 {
+			zone.Name += " + " + strconv.Itoa(len(zone.Rooms)-1)
+		}
+		if np, err := m.nowPlaying(ctx, coordinatorHost); err == nil {
+			zone.NowPlaying = np
+		}
+		zones = append(zones, zone)
+	}
+	sort.Slice(zones, func(i, j int) bool { return zones[i].Name < zones[j].Name })
+	return zones, nil
+}
+
+// Room finds a room by name (case-insensitive) or ID
+func (m *Manager) Room(ctx context.Context, nameOrID string) (Room, error) {
+	rooms, err := m.Rooms(ctx)
+	if err != nil {
+		return Room{}, err
+	}
+	for _, r := range rooms {
+		if r.ID == nameOrID || strings.EqualFold(r.Name, nameOrID) {
+			return r, nil
+		}
+	}
+	return Room{}, fmt.Errorf("room not found: %s", nameOrID)
+}
+
+// Join adds a room to the zone another room plays in
+func (m *Manager) Join(ctx context.Context, room, coordinator string) error {
+	member, err := m.Room(ctx, room)
+	if err != nil {
+		return err
+	}
+	leader, err := m.Room(ctx, coordinator)
+	if err != nil {
+		return err
+	}
+	if member.ID == leader.ID {
+		return fmt.Errorf("a room can't join itself")
+	}
+	leaderID, err := m.coordinatorOf(ctx, leader.ID)
+	if err != nil {
+		return err
+	}
+	_, err = m.call(ctx, member.Host, avTransport, "SetAVTransportURI",
+		arg{"InstanceID", "0"}, arg{"CurrentURI", "x-rincon:" + leaderID}, arg{"CurrentURIMetaData", ""})
+	return err
+}
+
+// Leave takes a room out of its zone so it plays on its own
+func (m *Manager) Leave(ctx context.Context, room string) error {
+	r, err := m.Room(ctx, room)
+	if err != nil {
+		return err
+	}
+	_, err = m.call(ctx, r.Host, avTransport, "BecomeCoordinatorOfStandaloneGroup", arg{"InstanceID", "0"})
+	return err
+}
+
+// SetVolume sets one room's volume, 0-100
+func (m *Manager) SetVolume(ctx context.Context, room string, volume int) error {
+	if volume < 0 || volume > 100 {
+		return fmt.Errorf("volume must be between 0 and 100")
+	}
+	r, err := m.Room(ctx, room)
+	if err != nil {
+		return err
+	}
+	_, err = m.call(ctx, r.Host, renderingControl, "SetVolume",
+		arg{"InstanceID", "0"}, arg{"Channel", "Master"}, arg{"DesiredVolume", strconv.Itoa(volume)})
+	return err
+}
+
+// SetMute mutes or unmutes one room
+func (m *Manager) SetMute(ctx context.Context, room string, muted bool) error {
+	r, err := m.Room(ctx, room)
+	if err != nil {
+		return err
+	}
+	value := "0"
+	if muted {
+		value = "1"
+	}
+	_, err = m.call(ctx, r.Host, renderingControl, "SetMute",
+		arg{"InstanceID", "0"}, arg{"Channel", "Master"}, arg{"DesiredMute", value})
+	return err
+}
+
+// Transport actions for Control
+const (
+	ActionPlay     = "play"
+	ActionPause    = "pause"
+	ActionNext     = "next"
+	ActionPrevious = "previous"
+)
+
+// Control plays, pauses or skips on the zone a room is in
+func (m *Manager) Control(ctx context.Context, room, action string) error {
+	r, err := m.Room(ctx, room)
+	if err != nil {
+		return err
+	}
+	host, err := m.coordinatorHost(ctx, r.ID)
+	if err != nil {
+		return err
+	}
+	switch action {
+	case ActionPlay:
+		_, err = m.call(ctx, host, avTransport, "Play", arg{"InstanceID", "0"}, arg{"Speed", "1"})
+	case ActionPause:
+		_, err = m.call(ctx, host, avTransport, "Pause", arg{"InstanceID", "0"})
+	case ActionNext:
+		_, err = m.call(ctx, host, avTransport, "Next", arg{"InstanceID", "0"})
+	case ActionPrevious:
+		_, err = m.call(ctx, host, avTransport, "Previous", arg{"InstanceID", "0"})
+	default:
+		return fmt.Errorf("unknown action %q", action)
+	}
+	return err
+}
+
+// coordinatorOf returns the ID of the coordinator of the zone a room is in
+func (m *Manager) coordinatorOf(ctx context.Context, roomID string) (string, error) {
+	groups, err := m.topology(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, g := range groups {
+		for _, member := range g.Members {
+			if member.UUID == roomID {
+				return g.Coordinator, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("room not found: %s", roomID)
+}
+
+// coordinatorHost returns the host of the coordinator of the zone a room is in
+func (m *Manager) coordinatorHost(ctx context.Context, roomID string) (string, error) {
+	groups, err := m.topology(ctx)
+	if err != nil {
+		return "", err
+	}
+	for _, g := range groups {
+		host := ""
+		found := false
+		for _, member := range g.Members {
+			if member.UUID == g.Coordinator {
+				host = hostOf(member.Location)
+			}
+			if member.UUID == roomID {
+				found = true
+			}
+		}
+		if found && host != "" {
+			return host, nil
+		}
+	}
+	return "", fmt.Errorf("room not found: %s", roomID)
+}
+
+// volume reads a speaker's volume and mute state
+func (m *Manager) volume(ctx context.Context, host string) (int, bool, error) {
+	out, err := m.call(ctx, host, renderingControl, "GetVolume", arg{"InstanceID", "0"}, arg{"Channel", "Master"})
+	if err != nil {
+		return 0, false, err
+	}
+	volume, _ := strconv.Atoi(out["CurrentVolume"])
+	mute, err := m.call(ctx, host, renderingControl, "GetMute", arg{"InstanceID", "0"}, arg{"Channel", "Master"})
+	if err != nil {
+		return volume, false, nil
+	}
+	return volume, mute["CurrentMute"] == "1", nil
+}
+
+// didl is the DIDL-Lite track metadata Sonos returns
+type didl struct {
+	Item struct {
+		Title       string `xml:"title"`
+		Creator     string `xml:"creator"`
+		Album       string `xml:"album"`
+		AlbumArtURI string `xml:"albumArtURI"`
+		StreamInfo  string `xml:"streamContent"` // Radio: "Artist - Title"
+	} `xml:"item"`
+}
+
+// nowPlaying reads a coordinator's transport state and current track
+func (m *Manager) nowPlaying(ctx context.Context, host string) (*NowPlaying, error) {
+	transport, err := m.call(ctx, host, avTransport, "GetTransportInfo", arg{"InstanceID", "0"})
+	if err != nil {
+		return nil, err
+	}
+	np := &NowPlaying{State: transportState(transport["CurrentTransportState"])}
+
+	position, err := m.call(ctx, host, avTransport, "GetPositionInfo", arg{"InstanceID", "0"})
+	if err != nil {
+		return np, nil
+	}
+	np.Duration = parseClock(position["TrackDuration"])
+	np.Position = parseClock(position["RelTime"])
+	np.Source = sourceOf(position["TrackURI"])
+
+	var meta didl
+	if xml.Unmarshal([]byte(position["TrackMetaData"]), &meta) == nil {
+		np.Title = meta.Item.Title
+		np.Artist = meta.Item.Creator
+		np.Album = meta.Item.Album
+		if meta.Item.StreamInfo != "" && np.Artist == "" {
+			np.Artist, np.Title, _ = strings.Cut(meta.Item.StreamInfo, " - ")
+		}
+		if art := meta.Item.AlbumArtURI; art != "" {
+			if strings.HasPrefix(art, "/") {
+				art = fmt.Sprintf("http://%s:%d%s", host, Port, art)
+			}
+			np.ArtURL = art
+		}
+	}
+	return np, nil
+}
+
+// transportState maps UPnP transport states to playing, paused, stopped or transitioning
+func transportState(s string) string {
+	switch s {
+	case "PLAYING":
+		return "playing"
+	case "PAUSED_PLAYBACK":
+		return "paused"
+	case "TRANSITIONING":
+		return "transitioning"
+	}
+	return "stopped"
+}
+
+// sourceOf guesses the source from a track URI
+func sourceOf(uri string) string {
+	switch {
+	case strings.Contains(uri, "spotify"):
+		return "spotify"
+	case strings.HasPrefix(uri, "x-sonos-htastream:"):
+		return "tv"
+	case strings.HasPrefix(uri, "x-rincon-stream:"):
+		return "line-in"
+	case strings.HasPrefix(uri, "x-sonosapi-stream:"), strings.HasPrefix(uri, "x-rincon-mp3radio:"), strings.HasPrefix(uri, "aac:"):
+		return "radio"
+	case strings.HasPrefix(uri, "x-sonos-vli:"):
+		return "airplay"
+	case uri == "":
+		return ""
+	}
+	return "library"
+}
+
+// parseClock reads H:MM:SS into seconds
+func parseClock(s string) int {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0
+	}
+	total := 0
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return 0
+		}
+		total = total*60 + n
+	}
+	return total
+}
+
+// hostOf returns the host of a device description URL
+func hostOf(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
@@ -1,6 +1,7 @@
 package spotify
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
@@ -19,19 +20,27 @@ const (
 	apiURL   = "https://api.spotify.com/v1"
 )
 
-// Scopes required for playback control, search, and playlist browsing
+// Scopes required for playback control, search, playlist browsing and editing, and
+// podcast resume points.
+// streaming, user-read-email and user-read-private are for the Web Playback SDK.
 var Scopes = []string{
 	"user-read-playback-state",
 	"user-modify-playback-state",
 	"user-read-currently-playing",
 	"playlist-read-private",
 	"playlist-read-collaborative",
+	"playlist-modify-public",
+	"playlist-modify-private",
 	"user-library-read",
 	"user-library-modify",
 	"user-read-recently-played",
 	"user-top-read",
 	"user-follow-read",
 	"user-follow-modify",
+	"user-read-playback-position",
+	"streaming",
+	"user-read-email",
+	"user-read-private",
 }
 
 // Token represents OAuth tokens
@@ -53,6 +62,7 @@ type Client struct {
 	httpClient   *http.Client
 	mu           sync.RWMutex
 	onTokenSave  func(*Token) error
+	limits       rateLimits
 }
 
 // NewClient creates a new Spotify client
@@ -91,6 +101,33 @@ func (c *Client) IsAuthenticated() bool {
 	return c.token != nil && c.token.AccessToken != ""
 }
 
+// HasScope reports whether the current token was granted a scope. Tokens
+// authorized before a scope was added need to be re-authorized.
+func (c *Client) HasScope(scope string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.token == nil {
+		return false
+	}
+	for _, s := range strings.Fields(c.token.Scope) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessToken returns a valid access token and its expiry, refreshing it first
+// if it expires soon. For handing to the Web Playback SDK.
+func (c *Client) AccessToken(ctx context.Context) (string, time.Time, error) {
+	if err := c.ensureValidToken(ctx); err != nil {
+		return "", time.Time{}, err
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token.AccessToken, c.token.ExpiresAt, nil
+}
+
 // GetAuthURL returns the Spotify authorization URL
 func (c *Client) GetAuthURL(state string) string {
 	params := url.Values{
@@ -234,26 +271,57 @@ func (c *Client) ensureValidToken(ctx context.Context) error {
 	return nil
 }
 
-// doRequest makes an authenticated API request
+// doRequest makes an authenticated API request, retrying transient failures (see retryDelay)
 func (c *Client) doRequest(ctx context.Context, method, endpoint string, body io.Reader) (*http.Response, error) {
 	if err := c.ensureValidToken(ctx); err != nil {
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, apiURL+endpoint, body)
-	if err != nil {
-		return nil, err
+	// Buffer the body so it can be resent
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return nil, err
+		}
 	}
+	category := endpointCategory(endpoint)
 
-	c.mu.RLock()
-	req.Header.Set("Authorization", "Bearer "+c.token.AccessToken)
-	c.mu.RUnlock()
+	for attempt := 1; ; attempt++ {
+		if err := c.limits.wait(ctx, category); err != nil {
+			return nil, err
+		}
 
-	if body != nil {
-		req.Header.Set("Content-Type", "application/json")
-	}
+		var reqBody io.Reader
+		if body != nil {
+			reqBody = bytes.NewReader(payload)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, apiURL+endpoint, reqBody)
+		if err != nil {
+			return nil, err
+		}
+
+		c.mu.RLock()
+		req.Header.Set("Authorization", "Bearer "+c.token.AccessToken)
+		c.mu.RUnlock()
+
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
 
-	return c.httpClient.Do(req)
+		resp, err := c.httpClient.Do(req)
+		if ctx.Err() != nil {
+			return resp, err
+		}
+		wait, retry := c.retryDelay(method, category, attempt, resp, err)
+		if !retry {
+			return resp, err
+		}
+		discard(resp)
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
 }
 
 // Player types
@@ -267,6 +335,8 @@ type Device struct {
 	Name             string `json:"name"`
 	Type             string `json:"type"`
 	VolumePercent    int    `json:"volume_percent"`
+	OriginalName     string `json:"original_name,omitempty"` // Spotify's name when an alias is applied
+	Hidden           bool   `json:"hidden,omitempty"`        // Hidden from the device picker
 }
 
 // Image represents an image
@@ -305,13 +375,50 @@ type Track struct {
 
 // PlaybackState represents the current playback state
 type PlaybackState struct {
-	Device       *Device `json:"device"`
-	ShuffleState bool    `json:"shuffle_state"`
-	RepeatState  string  `json:"repeat_state"`
-	Timestamp    int64   `json:"timestamp"`
-	ProgressMS   int     `json:"progress_ms"`
-	IsPlaying    bool    `json:"is_playing"`
-	Item         *Track  `json:"item"`
+	Device               *Device       `json:"device"`
+	ShuffleState         bool          `json:"shuffle_state"`
+	RepeatState          string        `json:"repeat_state"`
+	Timestamp            int64         `json:"timestamp"`
+	ProgressMS           int           `json:"progress_ms"`
+	IsPlaying            bool          `json:"is_playing"`
+	CurrentlyPlayingType string        `json:"currently_playing_type"` // track, episode, ad, unknown
+	Item                 *PlaybackItem `json:"item"`
+}
+
+// PlaybackItem is the playing track or podcast episode. Type says which; the
+// album and artists are empty for episodes, the show and resume point for tracks.
+type PlaybackItem struct {
+	Track
+	Type        string       `json:"type"`
+	Description string       `json:"description,omitempty"`
+	Images      []Image      `json:"images,omitempty"`
+	Show        *Show        `json:"show,omitempty"`
+	ResumePoint *ResumePoint `json:"resume_point,omitempty"`
+}
+
+// ResumePoint is how far the user got through an episode
+type ResumePoint struct {
+	FullyPlayed      bool `json:"fully_played"`
+	ResumePositionMS int  `json:"resume_position_ms"`
+}
+
+// Episode represents a podcast episode
+type Episode struct {
+	ID          string       `json:"id"`
+	Name        string       `json:"name"`
+	URI         string       `json:"uri"`
+	Description string       `json:"description"`
+	DurationMS  int          `json:"duration_ms"`
+	ReleaseDate string       `json:"release_date"`
+	Images      []Image      `json:"images"`
+	Show        *Show        `json:"show,omitempty"` // Only set by GetEpisode
+	ResumePoint *ResumePoint `json:"resume_point,omitempty"`
+}
+
+// Queue is the current track and the tracks up next
+type Queue struct {
+	CurrentlyPlaying *Track  `json:"currently_playing"`
+	Queue            []Track `json:"queue"`
 }
 
 // Playlist represents a playlist
@@ -364,7 +471,8 @@ type SearchResults struct {
 
 // GetPlaybackState returns the current playback state
 func (c *Client) GetPlaybackState(ctx context.Context) (*PlaybackState, error) {
-	resp, err := c.doRequest(ctx, "GET", "/me/player", nil)
+	// Without additional_types Spotify reports a null item while an episode plays
+	resp, err := c.doRequest(ctx, "GET", "/me/player?additional_types=track,episode", nil)
 	if err != nil {
 		return nil, err
 	}
@@ -440,8 +548,8 @@ func (c *Client) PlayURI(ctx context.Context, deviceID string, uri string, posit
 	}
 
 	var body string
-	if strings.Contains(uri, ":track:") {
-		// For tracks, use uris array
+	if strings.Contains(uri, ":track:") || strings.Contains(uri, ":episode:") {
+		// For tracks and episodes, use uris array
 		body = fmt.Sprintf(`{"uris":["%s"]}`, uri)
 	} else {
 		// For albums/playlists, use context_uri
@@ -466,6 +574,28 @@ func (c *Client) PlayURI(ctx context.Context, deviceID string, uri string, posit
 	return nil
 }
 
+// PlayEpisode plays an episode from positionMS, e.g. its resume point
+func (c *Client) PlayEpisode(ctx context.Context, deviceID string, uri string, positionMS int) error {
+	endpoint := "/me/player/play"
+	if deviceID != "" {
+		endpoint += "?device_id=" + deviceID
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{"uris": []string{uri}, "position_ms": positionMS})
+	resp, err := c.doRequest(ctx, "PUT", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("play episode failed: %s - %s", resp.Status, string(respBody))
+	}
+
+	return nil
+}
+
 // Pause pauses playback
 func (c *Client) Pause(ctx context.Context, deviceID string) error {
 	endpoint := "/me/player/pause"
@@ -631,6 +761,48 @@ func (c *Client) SetRepeat(ctx context.Context, deviceID string, state string) e
 	return nil
 }
 
+// GetQueue returns the user's playback queue
+func (c *Client) GetQueue(ctx context.Context) (*Queue, error) {
+	resp, err := c.doRequest(ctx, "GET", "/me/player/queue", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("get queue failed: %s - %s", resp.Status, string(body))
+	}
+
+	var queue Queue
+	if err := json.NewDecoder(resp.Body).Decode(&queue); err != nil {
+		return nil, err
+	}
+
+	return &queue, nil
+}
+
+// AddToQueue adds a track or episode URI to the end of the queue
+func (c *Client) AddToQueue(ctx context.Context, deviceID string, uri string) error {
+	endpoint := "/me/player/queue?uri=" + url.QueryEscape(uri)
+	if deviceID != "" {
+		endpoint += "&device_id=" + deviceID
+	}
+
+	resp, err := c.doRequest(ctx, "POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("add to queue failed: %s - %s", resp.Status, string(body))
+	}
+
+	return nil
+}
+
 // GetPlaylists returns the user's playlists
 func (c *Client) GetPlaylists(ctx context.Context, limit, offset int) ([]Playlist, int, error) {
 	endpoint := fmt.Sprintf("/me/playlists?limit=%d&offset=%d", limit, offset)
@@ -683,6 +855,114 @@ func (c *Client) GetPlaylistTracks(ctx context.Context, playlistID string, limit
 	return result.Items, result.Total, nil
 }
 
+// MaxPlaylistItems is the most URIs Spotify accepts in one add or remove request
+const MaxPlaylistItems = 100
+
+// CreatePlaylist creates a playlist owned by the current user
+func (c *Client) CreatePlaylist(ctx context.Context, name, description string, public bool) (*Playlist, error) {
+	resp, err := c.doRequest(ctx, "GET", "/me", nil)
+	if err != nil {
+		return nil, err
+	}
+	var user struct {
+		ID string `json:"id"`
+	}
+	err = json.NewDecoder(resp.Body).Decode(&user)
+	resp.Body.Close()
+	if err != nil || user.ID == "" {
+		return nil, fmt.Errorf("get current user failed: %s", resp.Status)
+	}
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"name":        name,
+		"description": description,
+		"public":      public,
+	})
+	resp, err = c.doRequest(ctx, "POST", "/users/"+url.PathEscape(user.ID)+"/playlists", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("create playlist failed: %s - %s", resp.Status, string(respBody))
+	}
+
+	var playlist Playlist
+	if err := json.NewDecoder(resp.Body).Decode(&playlist); err != nil {
+		return nil, err
+	}
+
+	return &playlist, nil
+}
+
+// AddTracksToPlaylist adds track or episode URIs at position (negative = the end).
+// Returns the playlist's new snapshot ID.
+func (c *Client) AddTracksToPlaylist(ctx context.Context, playlistID string, uris []string, position int) (string, error) {
+	req := map[string]interface{}{"uris": uris}
+	if position >= 0 {
+		req["position"] = position
+	}
+	return c.modifyPlaylist(ctx, "POST", playlistID, req, "add playlist tracks")
+}
+
+// RemovePlaylistTracks removes every occurrence of the URIs from a playlist.
+// snapshotID is optional; when set, the removal applies to that version.
+func (c *Client) RemovePlaylistTracks(ctx context.Context, playlistID string, uris []string, snapshotID string) (string, error) {
+	tracks := make([]map[string]string, 0, len(uris))
+	for _, uri := range uris {
+		tracks = append(tracks, map[string]string{"uri": uri})
+	}
+	req := map[string]interface{}{"tracks": tracks}
+	if snapshotID != "" {
+		req["snapshot_id"] = snapshotID
+	}
+	return c.modifyPlaylist(ctx, "DELETE", playlistID, req, "remove playlist tracks")
+}
+
+// ReorderPlaylistTracks moves rangeLength tracks starting at rangeStart to before
+// the track at insertBefore
+func (c *Client) ReorderPlaylistTracks(ctx context.Context, playlistID string, rangeStart, insertBefore, rangeLength int, snapshotID string) (string, error) {
+	req := map[string]interface{}{
+		"range_start":   rangeStart,
+		"insert_before": insertBefore,
+		"range_length":  rangeLength,
+	}
+	if snapshotID != "" {
+		req["snapshot_id"] = snapshotID
+	}
+	return c.modifyPlaylist(ctx, "PUT", playlistID, req, "reorder playlist tracks")
+}
+
+// modifyPlaylist sends a change to a playlist's items and returns the new snapshot ID
+func (c *Client) modifyPlaylist(ctx context.Context, method, playlistID string, req interface{}, what string) (string, error) {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.doRequest(ctx, method, "/playlists/"+url.PathEscape(playlistID)+"/tracks", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		respBody, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("%s failed: %s - %s", what, resp.Status, string(respBody))
+	}
+
+	var result struct {
+		SnapshotID string `json:"snapshot_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", err
+	}
+
+	return result.SnapshotID, nil
+}
+
 // GetRecentlyPlayed returns recently played tracks
 func (c *Client) GetRecentlyPlayed(ctx context.Context, limit int) ([]RecentlyPlayedItem, error) {
 	endpoint := fmt.Sprintf("/me/player/recently-played?limit=%d", limit)
@@ -1179,3 +1459,74 @@ func (c *Client) GetSavedShows(ctx context.Context, limit, offset int) ([]SavedS
 
 	return result.Items, result.Total, nil
 }
+
+// GetShowEpisodes returns a show's episodes, newest first, with resume points
+func (c *Client) GetShowEpisodes(ctx context.Context, showID string, limit, offset int) ([]Episode, int, error) {
+	endpoint := fmt.Sprintf("/shows/%s/episodes?limit=%d&offset=%d", showID, limit, offset)
+
+	resp, err := c.doRequest(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, 0, fmt.Errorf("get show episodes failed: %s - %s", resp.Status, string(body))
+	}
+
+	var result struct {
+		Items []*Episode `json:"items"`
+		Total int        `json:"total"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, 0, err
+	}
+
+	// Spotify returns null for episodes unavailable in the user's market
+	episodes := make([]Episode, 0, len(result.Items))
+	for _, e := range result.Items {
+		if e != nil {
+			episodes = append(episodes, *e)
+		}
+	}
+	return episodes, result.Total, nil
+}
+
+// GetEpisode returns an episode with its show and resume point
+func (c *Client) GetEpisode(ctx context.Context, episodeID string) (*Episode, error) {
+	resp, err := c.doRequest(ctx, "GET", "/episodes/"+episodeID, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("get episode failed: %s - %s", resp.Status, string(body))
+	}
+
+	var episode Episode
+	if err := json.NewDecoder(resp.Body).Decode(&episode); err != nil {
+		return nil, err
+	}
+
+	return &episode, nil
+}
+
+// ResumeEpisode plays an episode from where the user left off, or from the
+// start if it was fully played
+func (c *Client) ResumeEpisode(ctx context.Context, deviceID, episodeID string) (*Episode, error) {
+	episode, err := c.GetEpisode(ctx, episodeID)
+	if err != nil {
+		return nil, err
+	}
+	position := 0
+	if rp := episode.ResumePoint; rp != nil && !rp.FullyPlayed {
+		position = rp.ResumePositionMS
+	}
+	if err := c.PlayEpisode(ctx, deviceID, episode.URI, position); err != nil {
+		return nil, err
+	}
+	return episode, nil
+}
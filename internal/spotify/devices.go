@@ -0,0 +1,114 @@
+package spotify
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"home_control/internal/store"
+)
+
+// DeviceSettings holds friendly names and the hide list for Spotify Connect devices.
+// Keys may be either a device ID or the name Spotify reports (IDs change for some devices).
+type DeviceSettings struct {
+	Aliases map[string]string `json:"aliases"`
+	Hidden  []string          `json:"hidden"`
+}
+
+// DeviceSettingsStore persists DeviceSettings
+type DeviceSettingsStore struct {
+	mu       sync.RWMutex
+	doc      *store.Doc
+	settings DeviceSettings
+}
+
+// NewDeviceSettingsStore loads device settings from the store
+func NewDeviceSettingsStore(doc *store.Doc) *DeviceSettingsStore {
+	s := &DeviceSettingsStore{
+		doc:      doc,
+		settings: DeviceSettings{Aliases: map[string]string{}, Hidden: []string{}},
+	}
+
+	if _, err := doc.Load(&s.settings); err != nil {
+		log.Printf("Warning: Failed to load Spotify device settings: %v", err)
+	}
+	if s.settings.Aliases == nil {
+		s.settings.Aliases = map[string]string{}
+	}
+	if s.settings.Hidden == nil {
+		s.settings.Hidden = []string{}
+	}
+	return s
+}
+
+// Get returns a copy of the current settings
+func (s *DeviceSettingsStore) Get() DeviceSettings {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	aliases := make(map[string]string, len(s.settings.Aliases))
+	for k, v := range s.settings.Aliases {
+		aliases[k] = v
+	}
+	return DeviceSettings{
+		Aliases: aliases,
+		Hidden:  append([]string{}, s.settings.Hidden...),
+	}
+}
+
+// Set replaces the settings and saves them to disk
+func (s *DeviceSettingsStore) Set(settings DeviceSettings) error {
+	if settings.Aliases == nil {
+		settings.Aliases = map[string]string{}
+	}
+	if settings.Hidden == nil {
+		settings.Hidden = []string{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.doc.Save(settings); err != nil {
+		return fmt.Errorf("failed to save device settings: %w", err)
+	}
+	s.settings = settings
+	return nil
+}
+
+// ApplyDevice renames a single device according to its alias and flags it if hidden
+func (s *DeviceSettingsStore) ApplyDevice(d *Device) {
+	if d == nil {
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	alias, ok := s.settings.Aliases[d.ID]
+	if !ok {
+		alias, ok = s.settings.Aliases[d.Name]
+	}
+	for _, h := range s.settings.Hidden {
+		if h == d.ID || h == d.Name {
+			d.Hidden = true
+			break
+		}
+	}
+	if ok && alias != "" && alias != d.Name {
+		d.OriginalName = d.Name
+		d.Name = alias
+	}
+}
+
+// ApplyDevices applies aliases to a device list and drops hidden devices.
+// The active device is always kept so the picker can show what's playing.
+func (s *DeviceSettingsStore) ApplyDevices(devices []Device, includeHidden bool) []Device {
+	result := make([]Device, 0, len(devices))
+	for _, d := range devices {
+		s.ApplyDevice(&d)
+		if d.Hidden && !includeHidden && !d.IsActive {
+			continue
+		}
+		result = append(result, d)
+	}
+	return result
+}
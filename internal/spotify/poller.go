@@ -0,0 +1,152 @@
+package spotify
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Poll intervals: fast while something is playing so progress and track
+// changes show up promptly, slow when idle to stay well inside rate limits
+const (
+	PlayingInterval = time.Second
+	IdleInterval    = 30 * time.Second
+)
+
+// pokeWindow is how long a poke keeps polling fast, since Spotify takes a
+// moment to reflect a control request
+const pokeWindow = 5 * time.Second
+
+// seekTolerance is how far progress may drift from the expected position
+// before it's treated as a seek
+const seekTolerance = 2 * time.Second
+
+// Poller keeps the latest playback state and reports changes: a new item,
+// play/pause, device, volume, shuffle, repeat or a seek.
+type Poller struct {
+	fetch func() (*PlaybackState, error)
+	poke  chan struct{}
+
+	mu       sync.RWMutex
+	state    *PlaybackState
+	at       time.Time
+	fastTill time.Time
+	onChange func(s *PlaybackState)
+}
+
+// NewPoller creates a poller. fetch reads the playback state, usually
+// Client.GetPlaybackState (optionally through a cache the poller keeps warm);
+// it returns nil when nothing is playing or Spotify isn't authorized.
+func NewPoller(fetch func() (*PlaybackState, error)) *Poller {
+	return &Poller{
+		fetch: fetch,
+		poke:  make(chan struct{}, 1),
+	}
+}
+
+// OnChange registers a callback invoked when the playback state changes. s is
+// nil when playback stops on every device.
+func (p *Poller) OnChange(fn func(s *PlaybackState)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onChange = fn
+}
+
+// State returns the latest playback state, nil when nothing is playing
+func (p *Poller) State() *PlaybackState {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.state
+}
+
+// Poke polls again right away and keeps polling fast for a few seconds, e.g.
+// after a control request
+func (p *Poller) Poke() {
+	p.mu.Lock()
+	p.fastTill = time.Now().Add(pokeWindow)
+	p.mu.Unlock()
+	select {
+	case p.poke <- struct{}{}:
+	default:
+	}
+}
+
+// Run polls until the process exits. It blocks, so call it in a goroutine.
+func (p *Poller) Run() {
+	failing := false
+	for {
+		if err := p.poll(); err != nil {
+			if !failing {
+				log.Printf("Warning: Failed to poll Spotify playback: %v", err)
+			}
+			failing = true
+		} else {
+			failing = false
+		}
+
+		timer := time.NewTimer(p.interval(failing))
+		select {
+		case <-timer.C:
+		case <-p.poke:
+			timer.Stop()
+		}
+	}
+}
+
+// interval returns the wait before the next poll
+func (p *Poller) interval(failing bool) time.Duration {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if failing {
+		return IdleInterval
+	}
+	if (p.state != nil && p.state.IsPlaying) || time.Now().Before(p.fastTill) {
+		return PlayingInterval
+	}
+	return IdleInterval
+}
+
+// poll reads the playback state and dispatches a change
+func (p *Poller) poll() error {
+	s, err := p.fetch()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	p.mu.Lock()
+	previous, previousAt := p.state, p.at
+	p.state, p.at = s, now
+	fn := p.onChange
+	p.mu.Unlock()
+
+	if fn != nil && playbackChanged(previous, s, now.Sub(previousAt)) {
+		fn(s)
+	}
+	return nil
+}
+
+// playbackChanged reports whether b differs from a, read elapsed earlier, in
+// anything but the progress a playing item makes on its own
+func playbackChanged(a, b *PlaybackState, elapsed time.Duration) bool {
+	if a == nil || b == nil {
+		return a != b
+	}
+	if a.IsPlaying != b.IsPlaying || a.ShuffleState != b.ShuffleState || a.RepeatState != b.RepeatState {
+		return true
+	}
+	if (a.Item == nil) != (b.Item == nil) || (a.Item != nil && a.Item.URI != b.Item.URI) {
+		return true
+	}
+	if (a.Device == nil) != (b.Device == nil) ||
+		(a.Device != nil && (a.Device.ID != b.Device.ID || a.Device.VolumePercent != b.Device.VolumePercent)) {
+		return true
+	}
+
+	expected := a.ProgressMS
+	if a.IsPlaying {
+		expected += int(elapsed.Milliseconds())
+	}
+	drift := time.Duration(b.ProgressMS-expected) * time.Millisecond
+	return drift > seekTolerance || drift < -seekTolerance
+}
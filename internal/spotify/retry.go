@@ -0,0 +1,126 @@
+package spotify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Retry settings for transient failures (network errors, 5xx, 429)
+const (
+	maxAttempts   = 3
+	baseBackoff   = 250 * time.Millisecond
+	maxBackoff    = 4 * time.Second
+	maxRetryAfter = 10 * time.Second // Longer rate-limit waits fail fast instead of hanging the UI
+)
+
+// rateLimits remembers Retry-After deadlines per endpoint category, so one
+// rate-limited area (e.g. search) doesn't hold up playback control
+type rateLimits struct {
+	mu    sync.Mutex
+	until map[string]time.Time
+}
+
+// wait blocks until category is no longer rate limited, or returns an error if
+// that's too far off
+func (l *rateLimits) wait(ctx context.Context, category string) error {
+	l.mu.Lock()
+	until := l.until[category]
+	l.mu.Unlock()
+
+	d := time.Until(until)
+	if d <= 0 {
+		return nil
+	}
+	if d > maxRetryAfter {
+		return fmt.Errorf("spotify rate limited %s requests for another %s", category, d.Round(time.Second))
+	}
+	return sleep(ctx, d)
+}
+
+// block rate limits category for d
+func (l *rateLimits) block(category string, d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.until == nil {
+		l.until = make(map[string]time.Time)
+	}
+	if until := time.Now().Add(d); until.After(l.until[category]) {
+		l.until[category] = until
+	}
+}
+
+// endpointCategory groups API endpoints the way Spotify's rate limits hit
+// them: player, library (the rest of /me), search, or the catalog resource
+// (albums, artists, playlists, ...)
+func endpointCategory(endpoint string) string {
+	path, _, _ := strings.Cut(strings.TrimPrefix(endpoint, "/"), "?")
+	first, rest, _ := strings.Cut(path, "/")
+	if first == "me" {
+		if strings.HasPrefix(rest, "player") {
+			return "player"
+		}
+		return "library"
+	}
+	return first
+}
+
+// retryDelay decides whether an attempt should be retried and how long to wait
+// first. POSTs (skip, queue, create) aren't idempotent, so they're only retried
+// on 429, when Spotify didn't act on them.
+func (c *Client) retryDelay(method, category string, attempt int, resp *http.Response, err error) (time.Duration, bool) {
+	if attempt >= maxAttempts {
+		return 0, false
+	}
+	if err != nil {
+		return backoff(attempt), method != http.MethodPost
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		wait := time.Second
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs > 0 {
+			wait = time.Duration(secs) * time.Second
+		}
+		c.limits.block(category, wait)
+		return wait, wait <= maxRetryAfter
+	case resp.StatusCode >= 500 && resp.StatusCode != http.StatusNotImplemented:
+		return backoff(attempt), method != http.MethodPost
+	}
+	return 0, false
+}
+
+// backoff returns the jittered exponential delay before retry attempt+1
+func backoff(attempt int) time.Duration {
+	d := baseBackoff << (attempt - 1)
+	if d > maxBackoff {
+		d = maxBackoff
+	}
+	return time.Duration(float64(d) * (0.5 + rand.Float64()))
+}
+
+// discard drains and closes a response that won't be returned, so the
+// connection can be reused
+func discard(resp *http.Response) {
+	if resp != nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
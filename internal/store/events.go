@@ -0,0 +1,115 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Event is a timestamped record in the event history
+type Event struct {
+	ID     int64           `json:"id"`
+	Time   time.Time       `json:"time"`
+	Type   string          `json:"type"`
+	Source string          `json:"source,omitempty"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// AppendEvent records an event; data is JSON-encoded. Returns the new event ID.
+func (s *Store) AppendEvent(t time.Time, eventType, source string, data interface{}) (int64, error) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode event: %w", err)
+	}
+	res, err := s.db.Exec(`INSERT INTO events (time, type, source, data) VALUES (?, ?, ?, ?)`,
+		t.UnixMilli(), eventType, source, string(encoded))
+	if err != nil {
+		return 0, fmt.Errorf("failed to insert event: %w", err)
+	}
+	return res.LastInsertId()
+}
+
+// Events returns events of the given type (empty = all types) at or after since,
+// oldest first, up to limit (0 = no limit)
+func (s *Store) Events(eventType string, since time.Time, limit int) ([]Event, error) {
+	query := `SELECT id, time, type, source, data FROM events WHERE time >= ?`
+	args := []interface{}{since.UnixMilli()}
+	if eventType != "" {
+		query += ` AND type = ?`
+		args = append(args, eventType)
+	}
+	query += ` ORDER BY time, id`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	return s.queryEvents(query, args...)
+}
+
+// EventsAfter returns events of a type with an ID greater than afterID, oldest first,
+// up to limit (0 = no limit). Useful for resuming a stream from the last seen ID.
+func (s *Store) EventsAfter(eventType string, afterID int64, limit int) ([]Event, error) {
+	query := `SELECT id, time, type, source, data FROM events WHERE id > ? AND type = ? ORDER BY id`
+	args := []interface{}{afterID, eventType}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+	return s.queryEvents(query, args...)
+}
+
+// RecentEvents returns the newest events of a type (empty = all types), newest first
+func (s *Store) RecentEvents(eventType string, limit int) ([]Event, error) {
+	query := `SELECT id, time, type, source, data FROM events`
+	var args []interface{}
+	if eventType != "" {
+		query += ` WHERE type = ?`
+		args = append(args, eventType)
+	}
+	query += ` ORDER BY time DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+	return s.queryEvents(query, args...)
+}
+
+// RecentEventsFrom returns the newest events of a type from one source, newest first
+func (s *Store) RecentEventsFrom(eventType, source string, limit int) ([]Event, error) {
+	return s.queryEvents(`SELECT id, time, type, source, data FROM events
+		WHERE type = ? AND source = ? ORDER BY time DESC, id DESC LIMIT ?`, eventType, source, limit)
+}
+
+// PruneEvents deletes events of a type (empty = all types) older than before
+func (s *Store) PruneEvents(eventType string, before time.Time) (int64, error) {
+	query := `DELETE FROM events WHERE time < ?`
+	args := []interface{}{before.UnixMilli()}
+	if eventType != "" {
+		query += ` AND type = ?`
+		args = append(args, eventType)
+	}
+	res, err := s.db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to prune events: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+func (s *Store) queryEvents(query string, args ...interface{}) ([]Event, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	var result []Event
+	for rows.Next() {
+		var e Event
+		var ms int64
+		var data string
+		if err := rows.Scan(&e.ID, &ms, &e.Type, &e.Source, &data); err != nil {
+			return nil, fmt.Errorf("failed to scan event: %w", err)
+		}
+		e.Time = time.UnixMilli(ms)
+		e.Data = json.RawMessage(data)
+		result = append(result, e)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,67 @@
+package store
+
+import (
+	"fmt"
+	"log"
+)
+
+// migrations are applied in order; never edit a released migration, append a new one
+var migrations = []string{
+	// 1: key/value documents and event history
+	`CREATE TABLE kv (
+		namespace  TEXT NOT NULL,
+		key        TEXT NOT NULL,
+		value      TEXT NOT NULL,
+		updated_at INTEGER NOT NULL,
+		PRIMARY KEY (namespace, key)
+	);
+	CREATE TABLE events (
+		id     INTEGER PRIMARY KEY AUTOINCREMENT,
+		time   INTEGER NOT NULL,
+		type   TEXT NOT NULL,
+		source TEXT NOT NULL DEFAULT '',
+		data   TEXT NOT NULL DEFAULT '{}'
+	);
+	CREATE INDEX events_type_time ON events (type, time);`,
+
+	// 2: numeric sensor readings for history charts
+	`CREATE TABLE sensor_readings (
+		sensor TEXT NOT NULL,
+		time   INTEGER NOT NULL,
+		value  REAL NOT NULL
+	);
+	CREATE INDEX sensor_readings_sensor_time ON sensor_readings (sensor, time);`,
+}
+
+// migrate applies any migrations newer than the database's schema version
+func (s *Store) migrate() error {
+	if _, err := s.db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	var current int
+	if err := s.db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	for i := current; i < len(migrations); i++ {
+		version := i + 1
+		tx, err := s.db.Begin()
+		if err != nil {
+			return fmt.Errorf("failed to begin migration %d: %w", version, err)
+		}
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %d failed: %w", version, err)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version) VALUES (?)`, version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %d: %w", version, err)
+		}
+		log.Printf("Store: applied migration %d", version)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	_ "modernc.org/sqlite" // Pure Go driver (works with CGO_ENABLED=0)
+)
+
+// Store is the SQLite database holding server state: settings documents,
+// tokens and event history
+type Store struct {
+	db *sql.DB
+}
+
+// Open opens (or creates) the database at path and applies pending migrations.
+// The database holds tokens, so it and its WAL files are only readable by
+// the owner.
+func Open(path string) (*Store, error) {
+	if f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600); err == nil {
+		f.Close()
+	}
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)", path)
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	// SQLite allows a single writer; serialize access through one connection
+	db.SetMaxOpenConns(1)
+
+	s := &Store{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	for _, file := range []string{path, path + "-wal", path + "-shm"} {
+		if err := os.Chmod(file, 0600); err != nil && !os.IsNotExist(err) {
+			log.Printf("Warning: Failed to restrict %s: %v", file, err)
+		}
+	}
+	return s, nil
+}
+
+// Close closes the database
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// DB returns the underlying database for packages with their own tables
+func (s *Store) DB() *sql.DB {
+	return s.db
+}
+
+// GetJSON loads the value stored under namespace/key into v.
+// Returns false if the key doesn't exist.
+func (s *Store) GetJSON(namespace, key string, v interface{}) (bool, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT value FROM kv WHERE namespace = ? AND key = ?`, namespace, key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s/%s: %w", namespace, key, err)
+	}
+	if err := json.Unmarshal([]byte(data), v); err != nil {
+		return false, fmt.Errorf("failed to decode %s/%s: %w", namespace, key, err)
+	}
+	return true, nil
+}
+
+// PutJSON stores v under namespace/key, replacing any existing value
+func (s *Store) PutJSON(namespace, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s/%s: %w", namespace, key, err)
+	}
+	_, err = s.db.Exec(`INSERT INTO kv (namespace, key, value, updated_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(namespace, key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		namespace, key, string(data), time.Now().UnixMilli())
+	if err != nil {
+		return fmt.Errorf("failed to write %s/%s: %w", namespace, key, err)
+	}
+	return nil
+}
+
+// Delete removes namespace/key
+func (s *Store) Delete(namespace, key string) error {
+	if _, err := s.db.Exec(`DELETE FROM kv WHERE namespace = ? AND key = ?`, namespace, key); err != nil {
+		return fmt.Errorf("failed to delete %s/%s: %w", namespace, key, err)
+	}
+	return nil
+}
+
+// Doc is a single JSON document in the store, optionally seeded from a legacy JSON file
+type Doc struct {
+	store      *Store
+	namespace  string
+	key        string
+	legacyFile string
+}
+
+// Doc returns a handle to the document namespace/key. If legacyFile is set and the
+// document doesn't exist yet, the file's contents are imported on first Load.
+func (s *Store) Doc(namespace, key, legacyFile string) *Doc {
+	return &Doc{store: s, namespace: namespace, key: key, legacyFile: legacyFile}
+}
+
+// Load decodes the document into v. Returns false if it doesn't exist.
+func (d *Doc) Load(v interface{}) (bool, error) {
+	ok, err := d.store.GetJSON(d.namespace, d.key, v)
+	if err != nil || ok || d.legacyFile == "" {
+		return ok, err
+	}
+	return d.importLegacy(v)
+}
+
+// Save stores v as the document
+func (d *Doc) Save(v interface{}) error {
+	return d.store.PutJSON(d.namespace, d.key, v)
+}
+
+// Delete removes the document
+func (d *Doc) Delete() error {
+	return d.store.Delete(d.namespace, d.key)
+}
+
+// importLegacy moves a pre-store JSON file into the database and renames the file
+func (d *Doc) importLegacy(v interface{}) (bool, error) {
+	data, err := os.ReadFile(d.legacyFile)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read %s: %w", d.legacyFile, err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return false, fmt.Errorf("failed to parse %s: %w", d.legacyFile, err)
+	}
+	if err := d.Save(v); err != nil {
+		return false, err
+	}
+	// The kept copy may hold a token, so it is as private as the database
+	if err := os.Rename(d.legacyFile, d.legacyFile+".migrated"); err != nil {
+		log.Printf("Warning: Failed to rename migrated file %s: %v", d.legacyFile, err)
+	} else if err := os.Chmod(d.legacyFile+".migrated", 0600); err != nil {
+		log.Printf("Warning: Failed to restrict migrated file %s: %v", d.legacyFile, err)
+	}
+	log.Printf("Store: imported %s into %s/%s", d.legacyFile, d.namespace, d.key)
+	return true, nil
+}
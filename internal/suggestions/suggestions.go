@@ -0,0 +1,153 @@
+package suggestions
+
+import (
+	"sort"
+	"time"
+
+	"home_control/internal/actions"
+)
+
+// Options tune the time-of-day prediction
+type Options struct {
+	Window   time.Duration // How close to the current time of day an action must be (default 45m)
+	LookBack int           // Days of history to consider (default 28)
+	MinDays  int           // Distinct days an action must recur on to be suggested (default 3)
+	Cooldown time.Duration // Skip actions already performed this recently (default 2h)
+	Limit    int           // Max suggestions (default 5)
+}
+
+func (o *Options) defaults() {
+	if o.Window <= 0 {
+		o.Window = 45 * time.Minute
+	}
+	if o.LookBack <= 0 {
+		o.LookBack = 28
+	}
+	if o.MinDays <= 0 {
+		o.MinDays = 3
+	}
+	if o.Cooldown <= 0 {
+		o.Cooldown = 2 * time.Hour
+	}
+	if o.Limit <= 0 {
+		o.Limit = 5
+	}
+}
+
+// Prediction is an action the household usually performs around this time of day
+type Prediction struct {
+	Kind        string  `json:"kind"`
+	Target      string  `json:"target"`
+	Service     string  `json:"service"`
+	TypicalTime string  `json:"typicalTime"` // "HH:MM" average time the action happens
+	Days        int     `json:"days"`        // Distinct days it happened near this time
+	Confidence  float64 `json:"confidence"`  // Days / look-back days
+}
+
+// Predict returns actions that recur around the current time of day, most regular first
+func Predict(history []actions.Action, now time.Time, opts Options) []Prediction {
+	opts.defaults()
+	loc := now.Location()
+	cutoff := now.AddDate(0, 0, -opts.LookBack)
+	nowMinute := minuteOfDay(now)
+	window := int(opts.Window.Minutes())
+
+	type stats struct {
+		pred    Prediction
+		days    map[string]bool
+		minutes int
+		count   int
+		recent  bool
+	}
+	byKey := make(map[string]*stats)
+
+	for _, a := range history {
+		if a.Time.Before(cutoff) || a.Time.After(now) {
+			continue
+		}
+		key := a.Kind + "|" + a.Target + "|" + a.Service
+		st, ok := byKey[key]
+		if !ok {
+			st = &stats{
+				pred: Prediction{Kind: a.Kind, Target: a.Target, Service: a.Service},
+				days: make(map[string]bool),
+			}
+			byKey[key] = st
+		}
+
+		if now.Sub(a.Time) < opts.Cooldown {
+			st.recent = true
+		}
+
+		t := a.Time.In(loc)
+		diff := circularDiff(minuteOfDay(t), nowMinute)
+		if diff > window {
+			continue
+		}
+		st.days[t.Format("2006-01-02")] = true
+		// Accumulate offsets relative to now so averaging works across midnight
+		st.minutes += signedDiff(minuteOfDay(t), nowMinute)
+		st.count++
+	}
+
+	var result []Prediction
+	for _, st := range byKey {
+		if st.recent || len(st.days) < opts.MinDays {
+			continue
+		}
+		avg := (nowMinute + st.minutes/st.count + 24*60) % (24 * 60)
+		st.pred.TypicalTime = time.Date(0, 1, 1, avg/60, avg%60, 0, 0, time.UTC).Format("15:04")
+		st.pred.Days = len(st.days)
+		st.pred.Confidence = float64(len(st.days)) / float64(opts.LookBack)
+		result = append(result, st.pred)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Days != result[j].Days {
+			return result[i].Days > result[j].Days
+		}
+		return result[i].Target < result[j].Target
+	})
+	if len(result) > opts.Limit {
+		result = result[:opts.Limit]
+	}
+	return result
+}
+
+// Recent returns the most recently used distinct targets, newest first
+func Recent(history []actions.Action, limit int) []actions.Action {
+	seen := make(map[string]bool)
+	var result []actions.Action
+	for i := len(history) - 1; i >= 0 && len(result) < limit; i-- {
+		a := history[i]
+		key := a.Kind + "|" + a.Target
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, a)
+	}
+	return result
+}
+
+func minuteOfDay(t time.Time) int {
+	return t.Hour()*60 + t.Minute()
+}
+
+// signedDiff returns a-b wrapped to the range [-720, 720)
+func signedDiff(a, b int) int {
+	d := (a - b + 12*60) % (24 * 60)
+	if d < 0 {
+		d += 24 * 60
+	}
+	return d - 12*60
+}
+
+// circularDiff returns the absolute distance between two minutes of the day
+func circularDiff(a, b int) int {
+	d := signedDiff(a, b)
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -33,14 +33,36 @@ type DeviceInfo struct {
 
 // Execution contains the current execution/sync state
 type Execution struct {
-	SyncActive   bool   `json:"syncActive"`
-	HDMISource   string `json:"hdmiSource"`
-	HDMIActive   bool   `json:"hdmiActive,omitempty"`
-	Mode         string `json:"mode"`
-	LastSyncMode string `json:"lastSyncMode,omitempty"`
-	Brightness   int    `json:"brightness"`
-	HueTarget    string `json:"hueTarget,omitempty"` // Currently selected entertainment area
-	Intensity    string `json:"intensity,omitempty"`
+	SyncActive   bool         `json:"syncActive"`
+	HDMISource   string       `json:"hdmiSource"`
+	HDMIActive   bool         `json:"hdmiActive,omitempty"`
+	Mode         string       `json:"mode"`
+	LastSyncMode string       `json:"lastSyncMode,omitempty"`
+	Brightness   int          `json:"brightness"`
+	HueTarget    string       `json:"hueTarget,omitempty"` // Currently selected entertainment area
+	Intensity    string       `json:"intensity,omitempty"` // Intensity of the current mode
+	Video        ModeSettings `json:"video"`
+	Game         ModeSettings `json:"game"`
+	Music        ModeSettings `json:"music"`
+}
+
+// ModeSettings contains the per-mode light settings
+type ModeSettings struct {
+	Intensity          string `json:"intensity,omitempty"`
+	BackgroundLighting bool   `json:"backgroundLighting,omitempty"` // Video and game only
+}
+
+// Intensities are the valid intensity values, weakest first
+var Intensities = []string{"subtle", "moderate", "high", "intense"}
+
+// ValidIntensity reports whether intensity is one of Intensities
+func ValidIntensity(intensity string) bool {
+	for _, i := range Intensities {
+		if i == intensity {
+			return true
+		}
+	}
+	return false
 }
 
 // HueState contains Hue bridge connection information
@@ -189,6 +211,22 @@ func (c *Client) Register(appName, instanceName string) (*RegistrationResponse,
 	return &reg, nil
 }
 
+// AwaitRegistration keeps trying to register until the button on the Sync Box
+// has been held (the box refuses registrations until then) or timeout passes
+func (c *Client) AwaitRegistration(appName, instanceName string, timeout time.Duration) (*RegistrationResponse, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		reg, err := c.Register(appName, instanceName)
+		if err == nil {
+			return reg, nil
+		}
+		if time.Now().Add(2 * time.Second).After(deadline) {
+			return nil, fmt.Errorf("registration not accepted (hold the Sync Box button until the LED blinks green): %w", err)
+		}
+		time.Sleep(2 * time.Second)
+	}
+}
+
 // GetDevice returns device information
 func (c *Client) GetDevice() (*DeviceInfo, error) {
 	body, err := c.doRequest("GET", "/device", nil)
@@ -217,6 +255,22 @@ func (c *Client) GetExecution() (*Execution, error) {
 		return nil, fmt.Errorf("failed to parse execution state: %w", err)
 	}
 
+	// Intensity is reported per mode; surface the one in use
+	if exec.Intensity == "" {
+		mode := exec.Mode
+		if mode == "passthrough" || mode == "powersave" {
+			mode = exec.LastSyncMode
+		}
+		switch mode {
+		case "video":
+			exec.Intensity = exec.Video.Intensity
+		case "game":
+			exec.Intensity = exec.Game.Intensity
+		case "music":
+			exec.Intensity = exec.Music.Intensity
+		}
+	}
+
 	return &exec, nil
 }
 
@@ -311,8 +365,11 @@ func (c *Client) SetBrightness(brightness int) error {
 	return err
 }
 
-// SetIntensity sets the intensity (subtle, moderate, high, intense)
+// SetIntensity sets the intensity of the current mode (subtle, moderate, high, intense)
 func (c *Client) SetIntensity(intensity string) error {
+	if !ValidIntensity(intensity) {
+		return fmt.Errorf("invalid intensity %q (use subtle, moderate, high or intense)", intensity)
+	}
 	_, err := c.doRequest("PUT", "/execution", map[string]interface{}{
 		"intensity": intensity,
 	})
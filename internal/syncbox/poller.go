@@ -0,0 +1,102 @@
+package syncbox
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// PollInterval is how often a Poller reads the Sync Box
+const PollInterval = 3 * time.Second
+
+// Poller keeps the latest status of a Sync Box and reports changes to its
+// execution state (sync on/off, mode, HDMI input, brightness, area).
+type Poller struct {
+	fetch    func() (*Status, error)
+	interval time.Duration
+	poke     chan struct{}
+
+	mu       sync.RWMutex
+	status   *Status
+	onChange func(s, previous *Status)
+}
+
+// NewPoller creates a poller. fetch reads the box, usually Client.GetStatus
+// (optionally through a cache the poller keeps warm).
+func NewPoller(fetch func() (*Status, error), interval time.Duration) *Poller {
+	return &Poller{
+		fetch:    fetch,
+		interval: interval,
+		poke:     make(chan struct{}, 1),
+	}
+}
+
+// OnChange registers a callback invoked when the execution state changes.
+// previous is nil for the first status after startup.
+func (p *Poller) OnChange(fn func(s, previous *Status)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.onChange = fn
+}
+
+// Status returns the latest status. ok is false until the first successful poll.
+func (p *Poller) Status() (*Status, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.status, p.status != nil
+}
+
+// Poke polls again right away, e.g. after a control request
+func (p *Poller) Poke() {
+	select {
+	case p.poke <- struct{}{}:
+	default:
+	}
+}
+
+// Run polls until the process exits. It blocks, so call it in a goroutine.
+func (p *Poller) Run() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	failing := false
+	for {
+		if err := p.poll(); err != nil {
+			if !failing {
+				log.Printf("Warning: Failed to poll Sync Box: %v", err)
+			}
+			failing = true
+		} else {
+			failing = false
+		}
+		select {
+		case <-ticker.C:
+		case <-p.poke:
+		}
+	}
+}
+
+// poll reads the box and dispatches a change
+func (p *Poller) poll() error {
+	s, err := p.fetch()
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	previous := p.status
+	p.status = s
+	fn := p.onChange
+	p.mu.Unlock()
+
+	if fn != nil && (previous == nil || executionChanged(previous.Execution, s.Execution)) {
+		fn(s, previous)
+	}
+	return nil
+}
+
+func executionChanged(a, b *Execution) bool {
+	if a == nil || b == nil {
+		return a != b
+	}
+	return *a != *b
+}
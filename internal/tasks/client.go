@@ -2,10 +2,8 @@ package tasks
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
-	"os"
 	"sort"
 	"time"
 
@@ -13,16 +11,20 @@ import (
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/option"
 	gtasks "google.golang.org/api/tasks/v1"
+
+	"home_control/internal/store"
 )
 
 // emptyString is used for clearing the Completed field
 var emptyString = ""
 
 type Client struct {
-	config    *oauth2.Config
-	tokenFile string
-	service   *gtasks.Service
-	timezone  *time.Location
+	config   *oauth2.Config
+	tokenDoc *store.Doc
+	service  *gtasks.Service
+	timezone *time.Location
+
+	recurrence *RecurrenceStore
 }
 
 // Task represents a Google Task
@@ -34,6 +36,17 @@ type Task struct {
 	Due       time.Time `json:"due,omitempty"`
 	Completed bool      `json:"completed"`
 	Position  string    `json:"position"`
+	Parent    string    `json:"parent,omitempty"` // Parent task ID for subtasks
+
+	Recurrence *Recurrence `json:"recurrence,omitempty"`
+}
+
+// TaskUpdate holds the fields to change on a task; nil fields are left untouched
+type TaskUpdate struct {
+	Title    *string
+	Notes    *string
+	Due      *time.Time
+	ClearDue bool // Remove the due date (takes precedence over Due)
 }
 
 // TaskList represents a Google Tasks list
@@ -43,7 +56,7 @@ type TaskList struct {
 }
 
 // NewClient creates a tasks client that shares the OAuth token with calendar
-func NewClient(clientID, clientSecret, tokenFile string, timezone *time.Location) *Client {
+func NewClient(clientID, clientSecret string, tokenDoc *store.Doc, timezone *time.Location) *Client {
 	config := &oauth2.Config{
 		ClientID:     clientID,
 		ClientSecret: clientSecret,
@@ -54,9 +67,9 @@ func NewClient(clientID, clientSecret, tokenFile string, timezone *time.Location
 	}
 
 	client := &Client{
-		config:    config,
-		tokenFile: tokenFile,
-		timezone:  timezone,
+		config:   config,
+		tokenDoc: tokenDoc,
+		timezone: timezone,
 	}
 
 	return client
@@ -79,15 +92,13 @@ func (c *Client) Init(ctx context.Context) error {
 }
 
 func (c *Client) loadToken() (*oauth2.Token, error) {
-	f, err := os.Open(c.tokenFile)
+	var token oauth2.Token
+	ok, err := c.tokenDoc.Load(&token)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-
-	var token oauth2.Token
-	if err := json.NewDecoder(f).Decode(&token); err != nil {
-		return nil, err
+	if !ok {
+		return nil, fmt.Errorf("no Google token stored")
 	}
 	return &token, nil
 }
@@ -96,6 +107,35 @@ func (c *Client) IsAuthorized() bool {
 	return c.service != nil
 }
 
+// SetRecurrenceStore enables local recurrence rules for tasks
+func (c *Client) SetRecurrenceStore(store *RecurrenceStore) {
+	c.recurrence = store
+}
+
+// SetRecurrence sets or clears (rule == nil) the recurrence rule for a task
+func (c *Client) SetRecurrence(taskID string, rule *Recurrence) error {
+	if c.recurrence == nil {
+		return fmt.Errorf("task recurrence not configured")
+	}
+	if rule == nil {
+		return c.recurrence.Delete(taskID)
+	}
+	if err := rule.Validate(); err != nil {
+		return err
+	}
+	return c.recurrence.Set(taskID, *rule)
+}
+
+// attachRecurrence fills in the recurrence rule for a task, if any
+func (c *Client) attachRecurrence(task *Task) {
+	if c.recurrence == nil {
+		return
+	}
+	if rule, ok := c.recurrence.Get(task.ID); ok {
+		task.Recurrence = &rule
+	}
+}
+
 // resolveListID converts "@default" or empty string to the actual list ID
 func (c *Client) resolveListID(listID string) (string, error) {
 	if listID != "" && listID != "@default" {
@@ -164,6 +204,7 @@ func (c *Client) GetTasks(ctx context.Context, listID string) ([]Task, error) {
 			Notes:     t.Notes,
 			Completed: t.Status == "completed",
 			Position:  t.Position,
+			Parent:    t.Parent,
 		}
 
 		if t.Due != "" {
@@ -182,6 +223,7 @@ func (c *Client) GetTasks(ctx context.Context, listID string) ([]Task, error) {
 			}
 		}
 
+		c.attachRecurrence(&task)
 		result = append(result, task)
 	}
 
@@ -193,11 +235,34 @@ func (c *Client) GetTasks(ctx context.Context, listID string) ([]Task, error) {
 		return result[i].Position < result[j].Position
 	})
 
-	return result, nil
+	return nestSubtasks(result), nil
+}
+
+// nestSubtasks reorders a sorted task slice so subtasks directly follow their parent.
+// Subtask positions are relative to the parent, so they can't be sorted globally.
+func nestSubtasks(sorted []Task) []Task {
+	children := make(map[string][]Task)
+	ids := make(map[string]bool)
+	for _, t := range sorted {
+		ids[t.ID] = true
+		if t.Parent != "" {
+			children[t.Parent] = append(children[t.Parent], t)
+		}
+	}
+
+	result := make([]Task, 0, len(sorted))
+	for _, t := range sorted {
+		if t.Parent != "" && ids[t.Parent] {
+			continue // emitted with its parent
+		}
+		result = append(result, t)
+		result = append(result, children[t.ID]...)
+	}
+	return result
 }
 
-// CreateTask creates a new task
-func (c *Client) CreateTask(ctx context.Context, listID, title, notes string, due *time.Time) (*Task, error) {
+// CreateTask creates a new task. If parent is set the task is created as a subtask.
+func (c *Client) CreateTask(ctx context.Context, listID, title, notes string, due *time.Time, parent string) (*Task, error) {
 	if c.service == nil {
 		return nil, fmt.Errorf("tasks service not initialized")
 	}
@@ -217,7 +282,11 @@ func (c *Client) CreateTask(ctx context.Context, listID, title, notes string, du
 		task.Due = due.Format(time.RFC3339)
 	}
 
-	created, err := c.service.Tasks.Insert(resolvedListID, task).Do()
+	call := c.service.Tasks.Insert(resolvedListID, task)
+	if parent != "" {
+		call = call.Parent(parent)
+	}
+	created, err := call.Do()
 	if err != nil {
 		return nil, fmt.Errorf("failed to create task: %w", err)
 	}
@@ -229,6 +298,7 @@ func (c *Client) CreateTask(ctx context.Context, listID, title, notes string, du
 		Notes:     created.Notes,
 		Completed: created.Status == "completed",
 		Position:  created.Position,
+		Parent:    created.Parent,
 	}
 
 	if created.Due != "" {
@@ -279,12 +349,160 @@ func (c *Client) ToggleTask(ctx context.Context, listID, taskID string) (*Task,
 		Notes:     updated.Notes,
 		Completed: updated.Status == "completed",
 		Position:  updated.Position,
+		Parent:    updated.Parent,
 	}
 
 	log.Printf("Task toggled: ID=%s, Completed=%v", result.ID, result.Completed)
+
+	// Completing a recurring task schedules its next occurrence
+	if result.Completed && c.recurrence != nil {
+		if rule, ok := c.recurrence.Get(taskID); ok {
+			if err := c.createNextOccurrence(ctx, resolvedListID, updated, rule); err != nil {
+				log.Printf("Warning: Failed to create next occurrence of task %s: %v", taskID, err)
+			}
+		}
+	}
+	c.attachRecurrence(result)
+
 	return result, nil
 }
 
+// createNextOccurrence creates the follow-up task for a completed recurring task
+// and moves the recurrence rule over to it
+func (c *Client) createNextOccurrence(ctx context.Context, listID string, done *gtasks.Task, rule Recurrence) error {
+	loc := c.timezone
+	if loc == nil {
+		loc = time.Local
+	}
+
+	// Base the next due date on the previous due date, or today if it had none
+	now := time.Now().In(loc)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	base := today
+	if len(done.Due) >= 10 {
+		if due, err := time.ParseInLocation("2006-01-02", done.Due[:10], loc); err == nil {
+			base = due
+		}
+	}
+	rule.Anchor(base)
+	next := rule.Next(base)
+	// Don't schedule into the past if a task was completed late
+	for next.Before(today) {
+		next = rule.Next(next)
+	}
+
+	// Google stores due dates as midnight UTC
+	due := time.Date(next.Year(), next.Month(), next.Day(), 0, 0, 0, 0, time.UTC)
+	created, err := c.CreateTask(ctx, listID, done.Title, done.Notes, &due, done.Parent)
+	if err != nil {
+		return err
+	}
+
+	if err := c.recurrence.Set(created.ID, rule); err != nil {
+		return err
+	}
+	if err := c.recurrence.Delete(done.Id); err != nil {
+		return err
+	}
+
+	log.Printf("Recurring task %s rescheduled as %s (due %s)", done.Id, created.ID, next.Format("2006-01-02"))
+	return nil
+}
+
+// UpdateTask edits the title, notes and/or due date of a task
+func (c *Client) UpdateTask(ctx context.Context, listID, taskID string, update TaskUpdate) (*Task, error) {
+	if c.service == nil {
+		return nil, fmt.Errorf("tasks service not initialized")
+	}
+
+	// Resolve @default to actual list ID
+	resolvedListID, err := c.resolveListID(listID)
+	if err != nil {
+		return nil, err
+	}
+
+	patch := &gtasks.Task{}
+	if update.Title != nil {
+		patch.Title = *update.Title
+		patch.ForceSendFields = append(patch.ForceSendFields, "Title")
+	}
+	if update.Notes != nil {
+		patch.Notes = *update.Notes
+		patch.ForceSendFields = append(patch.ForceSendFields, "Notes")
+	}
+	if update.ClearDue {
+		patch.NullFields = append(patch.NullFields, "Due")
+	} else if update.Due != nil {
+		patch.Due = update.Due.Format(time.RFC3339)
+	}
+
+	updated, err := c.service.Tasks.Patch(resolvedListID, taskID, patch).Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to update task: %w", err)
+	}
+
+	result := c.convertTask(updated, resolvedListID)
+	log.Printf("Task updated: ID=%s, Title=%s", result.ID, result.Title)
+	return result, nil
+}
+
+// MoveTask reorders a task. parent makes it a subtask of another task (empty = top level),
+// previous places it after the given sibling (empty = first position).
+func (c *Client) MoveTask(ctx context.Context, listID, taskID, parent, previous string) (*Task, error) {
+	if c.service == nil {
+		return nil, fmt.Errorf("tasks service not initialized")
+	}
+
+	// Resolve @default to actual list ID
+	resolvedListID, err := c.resolveListID(listID)
+	if err != nil {
+		return nil, err
+	}
+
+	call := c.service.Tasks.Move(resolvedListID, taskID)
+	if parent != "" {
+		call = call.Parent(parent)
+	}
+	if previous != "" {
+		call = call.Previous(previous)
+	}
+
+	moved, err := call.Do()
+	if err != nil {
+		return nil, fmt.Errorf("failed to move task: %w", err)
+	}
+
+	result := c.convertTask(moved, resolvedListID)
+	log.Printf("Task moved: ID=%s, Parent=%s, Position=%s", result.ID, result.Parent, result.Position)
+	return result, nil
+}
+
+// convertTask converts a Google Tasks API task into our Task type
+func (c *Client) convertTask(t *gtasks.Task, listID string) *Task {
+	task := &Task{
+		ID:        t.Id,
+		ListID:    listID,
+		Title:     t.Title,
+		Notes:     t.Notes,
+		Completed: t.Status == "completed",
+		Position:  t.Position,
+		Parent:    t.Parent,
+	}
+
+	// Due dates are date-only (midnight UTC), see GetTasks
+	if len(t.Due) >= 10 {
+		loc := c.timezone
+		if loc == nil {
+			loc = time.Local
+		}
+		if due, err := time.ParseInLocation("2006-01-02", t.Due[:10], loc); err == nil {
+			task.Due = due
+		}
+	}
+	c.attachRecurrence(task)
+	return task
+}
+
 // DeleteTask deletes a task
 func (c *Client) DeleteTask(ctx context.Context, listID, taskID string) error {
 	if c.service == nil {
@@ -301,6 +519,12 @@ func (c *Client) DeleteTask(ctx context.Context, listID, taskID string) error {
 		return fmt.Errorf("failed to delete task: %w", err)
 	}
 
+	if c.recurrence != nil {
+		if err := c.recurrence.Delete(taskID); err != nil {
+			log.Printf("Warning: Failed to remove recurrence for task %s: %v", taskID, err)
+		}
+	}
+
 	log.Printf("Task deleted: ID=%s", taskID)
 	return nil
 }
@@ -0,0 +1,146 @@
+package tasks
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// Recurrence frequencies
+const (
+	FrequencyDaily   = "daily"
+	FrequencyWeekly  = "weekly"
+	FrequencyMonthly = "monthly"
+	FrequencyYearly  = "yearly"
+)
+
+// Recurrence describes how often a task repeats.
+// Google Tasks has no native recurrence, so rules are kept locally keyed by task ID.
+type Recurrence struct {
+	Frequency string `json:"frequency"`           // daily, weekly, monthly, yearly
+	Interval  int    `json:"interval,omitempty"`  // Every N units (default 1)
+	AnchorDay int    `json:"anchorDay,omitempty"` // Day of month monthly and yearly series fall on (default: the first due date's)
+}
+
+// Validate checks the recurrence rule and fills in defaults
+func (r *Recurrence) Validate() error {
+	switch r.Frequency {
+	case FrequencyDaily, FrequencyWeekly, FrequencyMonthly, FrequencyYearly:
+	default:
+		return fmt.Errorf("invalid frequency: %s", r.Frequency)
+	}
+	if r.Interval < 0 {
+		return fmt.Errorf("invalid interval: %d", r.Interval)
+	}
+	if r.Interval == 0 {
+		r.Interval = 1
+	}
+	if r.AnchorDay < 0 || r.AnchorDay > 31 {
+		return fmt.Errorf("invalid anchor day: %d", r.AnchorDay)
+	}
+	return nil
+}
+
+// Anchor pins a monthly or yearly series without an anchor day to start's day,
+// so a short month doesn't move every later date
+func (r *Recurrence) Anchor(start time.Time) {
+	if r.AnchorDay == 0 && (r.Frequency == FrequencyMonthly || r.Frequency == FrequencyYearly) {
+		r.AnchorDay = start.Day()
+	}
+}
+
+// Next returns the next occurrence after from. Monthly and yearly series land on
+// the anchor day (from's day when unset), or the last day of shorter months, so
+// one anchored on the 31st goes Jan 31, Feb 28, Mar 31 rather than drifting.
+func (r Recurrence) Next(from time.Time) time.Time {
+	n := r.Interval
+	if n <= 0 {
+		n = 1
+	}
+	switch r.Frequency {
+	case FrequencyWeekly:
+		return from.AddDate(0, 0, 7*n)
+	case FrequencyMonthly:
+		return r.onAnchorDay(from, 0, n)
+	case FrequencyYearly:
+		return r.onAnchorDay(from, n, 0)
+	default:
+		return from.AddDate(0, 0, n)
+	}
+}
+
+// onAnchorDay moves from by years and months, landing on the anchor day clamped
+// to the target month's length
+func (r Recurrence) onAnchorDay(from time.Time, years, months int) time.Time {
+	day := r.AnchorDay
+	if day <= 0 {
+		day = from.Day()
+	}
+	first := time.Date(from.Year()+years, from.Month()+time.Month(months), 1,
+		from.Hour(), from.Minute(), from.Second(), from.Nanosecond(), from.Location())
+	if last := first.AddDate(0, 1, -1).Day(); day > last {
+		day = last
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// RecurrenceStore persists recurrence rules
+type RecurrenceStore struct {
+	mu    sync.RWMutex
+	doc   *store.Doc
+	rules map[string]Recurrence // task ID -> rule
+}
+
+// NewRecurrenceStore loads recurrence rules from the store
+func NewRecurrenceStore(doc *store.Doc) *RecurrenceStore {
+	s := &RecurrenceStore{
+		doc:   doc,
+		rules: make(map[string]Recurrence),
+	}
+
+	if _, err := doc.Load(&s.rules); err != nil {
+		log.Printf("Warning: Failed to load task recurrence rules: %v", err)
+	}
+	if s.rules == nil {
+		s.rules = make(map[string]Recurrence)
+	}
+	return s
+}
+
+// Get returns the recurrence rule for a task
+func (s *RecurrenceStore) Get(taskID string) (Recurrence, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	rule, ok := s.rules[taskID]
+	return rule, ok
+}
+
+// Set stores the recurrence rule for a task
+func (s *RecurrenceStore) Set(taskID string, rule Recurrence) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules[taskID] = rule
+	return s.save()
+}
+
+// Delete removes the recurrence rule for a task
+func (s *RecurrenceStore) Delete(taskID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rules[taskID]; !ok {
+		return nil
+	}
+	delete(s.rules, taskID)
+	return s.save()
+}
+
+// save persists rules (caller must hold the lock)
+func (s *RecurrenceStore) save() error {
+	if err := s.doc.Save(s.rules); err != nil {
+		return fmt.Errorf("failed to save recurrence rules: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package timers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// maxDuration bounds a single timer; longer waits belong on the calendar
+const maxDuration = 24 * time.Hour
+
+// Timer is a running countdown, e.g. "Simmer sauce" for 20 minutes
+type Timer struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Seconds   int       `json:"seconds"`
+	StartedAt time.Time `json:"startedAt"`
+	EndsAt    time.Time `json:"endsAt"`
+	Source    string    `json:"source,omitempty"` // What created the timer, e.g. "recipe:<id>"
+}
+
+// Remaining returns the time left, zero once the timer has finished
+func (t Timer) Remaining() time.Duration {
+	if d := time.Until(t.EndsAt); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// Manager keeps running timers. Timers are persisted so they survive a restart;
+// any that finished while the server was down fire on the next check.
+type Manager struct {
+	mu       sync.RWMutex
+	doc      *store.Doc
+	timers   []Timer
+	onChange func()
+	onDone   func(t Timer)
+}
+
+// NewManager loads timers from the store
+func NewManager(doc *store.Doc) *Manager {
+	m := &Manager{doc: doc}
+	if _, err := doc.Load(&m.timers); err != nil {
+		log.Printf("Warning: Failed to load timers: %v", err)
+	}
+	return m
+}
+
+// OnChange registers a callback invoked after timers are added or removed
+func (m *Manager) OnChange(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// OnDone registers a callback invoked when a timer finishes
+func (m *Manager) OnDone(fn func(t Timer)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onDone = fn
+}
+
+// List returns running timers, soonest first
+func (m *Manager) List() []Timer {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := append([]Timer{}, m.timers...)
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].EndsAt.Before(result[j].EndsAt)
+	})
+	return result
+}
+
+// Create starts a timer
+func (m *Manager) Create(name string, seconds int, source string) (*Timer, error) {
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	d := time.Duration(seconds) * time.Second
+	if seconds <= 0 || d > maxDuration {
+		return nil, fmt.Errorf("seconds must be between 1 and %d", int(maxDuration.Seconds()))
+	}
+
+	now := time.Now()
+	t := Timer{
+		ID:        newID(),
+		Name:      name,
+		Seconds:   seconds,
+		StartedAt: now,
+		EndsAt:    now.Add(d),
+		Source:    source,
+	}
+
+	m.mu.Lock()
+	m.timers = append(m.timers, t)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &t, nil
+}
+
+// Cancel stops a timer without firing it
+func (m *Manager) Cancel(id string) error {
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("timer not found: %s", id)
+	}
+	m.timers = append(m.timers[:idx], m.timers[idx+1:]...)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.notify()
+	return nil
+}
+
+// Run fires finished timers. It blocks, so call it in a goroutine.
+func (m *Manager) Run() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		m.check()
+	}
+}
+
+// check removes finished timers and hands them to the done callback
+func (m *Manager) check() {
+	now := time.Now()
+	m.mu.Lock()
+	var done []Timer
+	running := m.timers[:0]
+	for _, t := range m.timers {
+		if now.Before(t.EndsAt) {
+			running = append(running, t)
+		} else {
+			done = append(done, t)
+		}
+	}
+	if len(done) == 0 {
+		m.mu.Unlock()
+		return
+	}
+	m.timers = running
+	if err := m.save(); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	fn := m.onDone
+	m.mu.Unlock()
+
+	for _, t := range done {
+		log.Printf("Timer %q finished", t.Name)
+		if fn != nil {
+			fn(t)
+		}
+	}
+	m.notify()
+}
+
+// indexOf finds a timer by ID (caller must hold the lock)
+func (m *Manager) indexOf(id string) int {
+	for i, t := range m.timers {
+		if t.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save persists timers (caller must hold the lock)
+func (m *Manager) save() error {
+	if err := m.doc.Save(m.timers); err != nil {
+		return fmt.Errorf("failed to save timers: %w", err)
+	}
+	return nil
+}
+
+func (m *Manager) notify() {
+	m.mu.RLock()
+	fn := m.onChange
+	m.mu.RUnlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// newID generates a short random timer ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,215 @@
+package vacuum
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"home_control/internal/store"
+)
+
+// Zone is a rectangle in the vacuum's own map coordinates (millimetres for
+// Roborock, centimetres for Valetudo)
+type Zone struct {
+	X1 int `json:"x1"`
+	Y1 int `json:"y1"`
+	X2 int `json:"x2"`
+	Y2 int `json:"y2"`
+}
+
+// Area is where a region is drawn on the map image, in percent of its size
+type Area struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+// Region is a named place a vacuum can clean, e.g. "Kitchen". It cleans either
+// the vacuum's own room segments or explicit zones.
+type Region struct {
+	ID       string   `json:"id"`
+	Vacuum   string   `json:"vacuum"` // vacuum.* entity ID
+	Name     string   `json:"name"`
+	Segments []string `json:"segments,omitempty"` // Room IDs from the vacuum's map
+	Zones    []Zone   `json:"zones,omitempty"`
+	Repeats  int      `json:"repeats,omitempty"` // Passes, 1-3 (default 1)
+	Area     *Area    `json:"area,omitempty"`    // Tappable area on the map image
+}
+
+// Segment is a room the vacuum detected on its map
+type Segment struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Manager keeps the named cleaning regions of all vacuums
+type Manager struct {
+	mu       sync.RWMutex
+	doc      *store.Doc
+	regions  []Region
+	onChange func()
+}
+
+// NewManager loads regions from the store
+func NewManager(doc *store.Doc) *Manager {
+	m := &Manager{doc: doc}
+	if _, err := doc.Load(&m.regions); err != nil {
+		log.Printf("Warning: Failed to load vacuum regions: %v", err)
+	}
+	return m
+}
+
+// OnChange registers a callback invoked after regions are modified
+func (m *Manager) OnChange(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// List returns the regions of a vacuum ordered by name
+func (m *Manager) List(vacuum string) []Region {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := []Region{}
+	for _, r := range m.regions {
+		if r.Vacuum == vacuum {
+			result = append(result, r)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+	})
+	return result
+}
+
+// Get returns a region by ID
+func (m *Manager) Get(id string) (*Region, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		return nil, false
+	}
+	r := m.regions[idx]
+	return &r, true
+}
+
+// Save creates a region, or replaces it when its ID exists
+func (m *Manager) Save(region Region) (*Region, error) {
+	if err := Validate(&region); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if idx := m.indexOf(region.ID); region.ID != "" && idx >= 0 {
+		region.Vacuum = m.regions[idx].Vacuum
+		m.regions[idx] = region
+	} else {
+		region.ID = newID()
+		m.regions = append(m.regions, region)
+	}
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &region, nil
+}
+
+// Delete removes a region
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("region not found: %s", id)
+	}
+	m.regions = append(m.regions[:idx], m.regions[idx+1:]...)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	m.notify()
+	return nil
+}
+
+// Validate checks a region and fills in defaults. A region needs segments or
+// zones, not both, since vacuums clean one or the other per run.
+func Validate(r *Region) error {
+	r.Name = strings.TrimSpace(r.Name)
+	if r.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if !strings.HasPrefix(r.Vacuum, "vacuum.") {
+		return fmt.Errorf("invalid vacuum: %s", r.Vacuum)
+	}
+	return ValidateTarget(r.Segments, r.Zones, &r.Repeats)
+}
+
+// ValidateTarget checks an ad-hoc segment or zone clean
+func ValidateTarget(segments []string, zones []Zone, repeats *int) error {
+	switch {
+	case len(segments) == 0 && len(zones) == 0:
+		return fmt.Errorf("segments or zones are required")
+	case len(segments) > 0 && len(zones) > 0:
+		return fmt.Errorf("use either segments or zones, not both")
+	case len(zones) > 5:
+		return fmt.Errorf("at most 5 zones can be cleaned at once")
+	}
+	for _, z := range zones {
+		if z.X1 >= z.X2 || z.Y1 >= z.Y2 {
+			return fmt.Errorf("invalid zone: x1/y1 must be below x2/y2")
+		}
+	}
+	if *repeats == 0 {
+		*repeats = 1
+	}
+	if *repeats < 1 || *repeats > 3 {
+		return fmt.Errorf("repeats must be between 1 and 3")
+	}
+	return nil
+}
+
+// indexOf finds a region by ID (caller must hold the lock)
+func (m *Manager) indexOf(id string) int {
+	for i, r := range m.regions {
+		if r.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save persists regions (caller must hold the lock)
+func (m *Manager) save() error {
+	if err := m.doc.Save(m.regions); err != nil {
+		return fmt.Errorf("failed to save vacuum regions: %w", err)
+	}
+	return nil
+}
+
+func (m *Manager) notify() {
+	m.mu.RLock()
+	fn := m.onChange
+	m.mu.RUnlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+// newID generates a short random region ID
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
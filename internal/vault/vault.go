@@ -0,0 +1,247 @@
+package vault
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// MaxFileSize is the largest document the vault accepts
+const MaxFileSize = 10 << 20
+
+// UnlockTTL is how long a PIN unlock lasts on a kiosk
+const UnlockTTL = 5 * time.Minute
+
+// ErrNotFound is returned for unknown document IDs
+var ErrNotFound = errors.New("document not found")
+
+// Item describes a stored document. Contents are encrypted on disk; the index
+// holds only these details.
+type Item struct {
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"contentType"`
+	Size        int       `json:"size"`
+	UploadedAt  time.Time `json:"uploadedAt"`
+}
+
+// Vault keeps small household documents (insurance cards, warranties)
+// encrypted with AES-256-GCM
+type Vault struct {
+	dir  string
+	aead cipher.AEAD
+
+	mu       sync.RWMutex
+	doc      *store.Doc
+	items    []Item
+	sessions map[string]time.Time // Unlock token -> expiry
+}
+
+// New opens the vault in dir. The key is any passphrase; it's hashed to the
+// AES key, so changing it makes existing documents unreadable.
+func New(dir, key string, doc *store.Doc) (*Vault, error) {
+	if key == "" {
+		return nil, fmt.Errorf("vault key is required")
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create vault directory: %w", err)
+	}
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Vault{dir: dir, aead: aead, doc: doc, items: []Item{}, sessions: make(map[string]time.Time)}
+	if _, err := doc.Load(&v.items); err != nil {
+		log.Printf("Warning: Failed to load vault index: %v", err)
+	}
+	return v, nil
+}
+
+// List returns the documents, newest first
+func (v *Vault) List() []Item {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	items := append([]Item{}, v.items...)
+	sort.Slice(items, func(i, j int) bool { return items[i].UploadedAt.After(items[j].UploadedAt) })
+	return items
+}
+
+// Add encrypts and stores a document
+func (v *Vault) Add(name, contentType string, data []byte) (*Item, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, fmt.Errorf("name is required")
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("file is empty")
+	}
+	if len(data) > MaxFileSize {
+		return nil, fmt.Errorf("file is larger than %d MB", MaxFileSize>>20)
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	item := Item{ID: newID(), Name: name, ContentType: contentType, Size: len(data), UploadedAt: time.Now()}
+	nonce := make([]byte, v.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	// The ID is authenticated with the contents so files can't be swapped on disk
+	sealed := v.aead.Seal(nonce, nonce, data, []byte(item.ID))
+	if err := os.WriteFile(v.path(item.ID), sealed, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write document: %w", err)
+	}
+
+	v.mu.Lock()
+	v.items = append(v.items, item)
+	err := v.save()
+	if err != nil {
+		v.items = v.items[:len(v.items)-1]
+	}
+	v.mu.Unlock()
+	if err != nil {
+		os.Remove(v.path(item.ID))
+		return nil, err
+	}
+	return &item, nil
+}
+
+// Open decrypts a document
+func (v *Vault) Open(id string) (*Item, []byte, error) {
+	v.mu.RLock()
+	idx := v.indexOf(id)
+	var item Item
+	if idx >= 0 {
+		item = v.items[idx]
+	}
+	v.mu.RUnlock()
+	if idx < 0 {
+		return nil, nil, ErrNotFound
+	}
+
+	sealed, err := os.ReadFile(v.path(id))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	n := v.aead.NonceSize()
+	if len(sealed) < n {
+		return nil, nil, fmt.Errorf("document is corrupt")
+	}
+	data, err := v.aead.Open(nil, sealed[:n], sealed[n:], []byte(id))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt document (was VAULT_KEY changed?)")
+	}
+	return &item, data, nil
+}
+
+// Delete removes a document
+func (v *Vault) Delete(id string) error {
+	v.mu.Lock()
+	idx := v.indexOf(id)
+	if idx < 0 {
+		v.mu.Unlock()
+		return ErrNotFound
+	}
+	v.items = append(v.items[:idx], v.items[idx+1:]...)
+	err := v.save()
+	v.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(v.path(id)); err != nil && !os.IsNotExist(err) {
+		log.Printf("Warning: Failed to remove vault file %s: %v", id, err)
+	}
+	return nil
+}
+
+// Unlock starts a kiosk session after a correct PIN, returning its token and
+// expiry
+func (v *Vault) Unlock() (string, time.Time) {
+	b := make([]byte, 24)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	expires := time.Now().Add(UnlockTTL)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	now := time.Now()
+	for t, exp := range v.sessions {
+		if now.After(exp) {
+			delete(v.sessions, t)
+		}
+	}
+	v.sessions[token] = expires
+	return token, expires
+}
+
+// Unlocked reports whether a session token is valid
+func (v *Vault) Unlocked(token string) bool {
+	if token == "" {
+		return false
+	}
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	for t, exp := range v.sessions {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return time.Now().Before(exp)
+		}
+	}
+	return false
+}
+
+// Lock ends a kiosk session
+func (v *Vault) Lock(token string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.sessions, token)
+}
+
+func (v *Vault) path(id string) string {
+	return filepath.Join(v.dir, id+".bin")
+}
+
+// indexOf returns the position of a document (caller must hold the lock)
+func (v *Vault) indexOf(id string) int {
+	for i, item := range v.items {
+		if item.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save persists the index (caller must hold the lock)
+func (v *Vault) save() error {
+	if err := v.doc.Save(v.items); err != nil {
+		return fmt.Errorf("failed to save vault index: %w", err)
+	}
+	return nil
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
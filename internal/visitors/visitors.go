@@ -0,0 +1,342 @@
+package visitors
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"home_control/internal/store"
+)
+
+// TickInterval is how often visit windows are checked for pre-unlocking
+const TickInterval = time.Minute
+
+// Visitor is an expected guest. A doorbell ring during the window is taken
+// as their arrival.
+type Visitor struct {
+	ID           string     `json:"id"`
+	Name         string     `json:"name"`
+	From         time.Time  `json:"from"`
+	Until        time.Time  `json:"until"`
+	Notes        string     `json:"notes,omitempty"`
+	Announcement string     `json:"announcement,omitempty"` // Default "<name> is here"
+	Chime        string     `json:"chime,omitempty"`        // Announcement pre-chime; empty = default
+	Unlock       string     `json:"unlock,omitempty"`       // Visitor door unlocked for the window, locked again after
+	Unlocked     bool       `json:"unlocked"`               // The door is currently unlocked for them
+	ArrivedAt    *time.Time `json:"arrivedAt,omitempty"`
+	ArrivedBy    string     `json:"arrivedBy,omitempty"` // doorbell or who marked them arrived
+}
+
+// Expected reports whether t falls inside the visit window
+func (v Visitor) Expected(t time.Time) bool {
+	return !t.Before(v.From) && t.Before(v.Until)
+}
+
+// Message returns what's announced when they ring
+func (v Visitor) Message() string {
+	if v.Announcement != "" {
+		return v.Announcement
+	}
+	return v.Name + " is here"
+}
+
+// Manager keeps the expected visitors
+type Manager struct {
+	doors map[string]bool // lock.* entities a visitor may have unlocked
+	lock  func(entity string, unlock bool, visitor Visitor) error
+
+	mu       sync.RWMutex
+	doc      *store.Doc
+	visitors []Visitor
+	onChange func()
+}
+
+// NewManager loads the visitors. Only doors may be unlocked for a visitor;
+// lock unlocks one when their window opens and locks it when it closes.
+func NewManager(doc *store.Doc, doors []string, lock func(entity string, unlock bool, visitor Visitor) error) *Manager {
+	m := &Manager{doors: make(map[string]bool), lock: lock, doc: doc, visitors: []Visitor{}}
+	for _, door := range doors {
+		m.doors[door] = true
+	}
+	if _, err := doc.Load(&m.visitors); err != nil {
+		log.Printf("Warning: Failed to load visitors: %v", err)
+	}
+	return m
+}
+
+// OnChange registers a callback invoked after visitors are modified
+func (m *Manager) OnChange(fn func()) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.onChange = fn
+}
+
+// List returns the visitors by window start. Visits that ended before since
+// are left out.
+func (m *Manager) List(since time.Time) []Visitor {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	result := make([]Visitor, 0, len(m.visitors))
+	for _, v := range m.visitors {
+		if v.Until.After(since) {
+			result = append(result, v)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].From.Before(result[j].From)
+	})
+	return result
+}
+
+// Get returns a visitor by ID
+func (m *Manager) Get(id string) (Visitor, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if idx := m.indexOf(id); idx >= 0 {
+		return m.visitors[idx], true
+	}
+	return Visitor{}, false
+}
+
+// Create adds an expected visitor
+func (m *Manager) Create(v Visitor) (*Visitor, error) {
+	if err := m.validate(&v); err != nil {
+		return nil, err
+	}
+	v.ID = newID()
+	v.Unlocked = false
+	v.ArrivedAt, v.ArrivedBy = nil, ""
+
+	m.mu.Lock()
+	m.visitors = append(m.visitors, v)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &v, nil
+}
+
+// Update changes a visitor's details and window, keeping their arrival
+func (m *Manager) Update(id string, v Visitor) (*Visitor, error) {
+	if err := m.validate(&v); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("visitor not found: %s", id)
+	}
+	existing := m.visitors[idx]
+	v.ID = existing.ID
+	v.Unlocked = existing.Unlocked
+	v.ArrivedAt, v.ArrivedBy = existing.ArrivedAt, existing.ArrivedBy
+	// A different door is unlocked by the next tick instead
+	relock := existing.Unlocked && existing.Unlock != v.Unlock
+	if relock {
+		v.Unlocked = false
+	}
+	m.visitors[idx] = v
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	if relock {
+		if err := m.lock(existing.Unlock, false, existing); err != nil {
+			log.Printf("Warning: Failed to lock %s for visitor %s: %v", existing.Unlock, existing.Name, err)
+		}
+	}
+	m.notify()
+	return &v, nil
+}
+
+// Delete removes a visitor. A door unlocked for them is locked again.
+func (m *Manager) Delete(id string) error {
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return fmt.Errorf("visitor not found: %s", id)
+	}
+	v := m.visitors[idx]
+	m.visitors = append(m.visitors[:idx], m.visitors[idx+1:]...)
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if v.Unlocked {
+		if err := m.lock(v.Unlock, false, v); err != nil {
+			log.Printf("Warning: Failed to lock %s after removing visitor %s: %v", v.Unlock, v.Name, err)
+		}
+	}
+	m.notify()
+	return nil
+}
+
+// Arrived logs a visitor's arrival. Arriving again keeps the first time.
+func (m *Manager) Arrived(id, by string, t time.Time) (*Visitor, error) {
+	m.mu.Lock()
+	idx := m.indexOf(id)
+	if idx < 0 {
+		m.mu.Unlock()
+		return nil, fmt.Errorf("visitor not found: %s", id)
+	}
+	v := &m.visitors[idx]
+	if v.ArrivedAt == nil {
+		v.ArrivedAt = &t
+		v.ArrivedBy = by
+	}
+	result := *v
+	err := m.save()
+	m.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	m.notify()
+	return &result, nil
+}
+
+// Ring handles a doorbell ring: the visitor expected now who hasn't arrived
+// yet (or else one who already has) is logged as arriving and returned
+func (m *Manager) Ring(t time.Time) *Visitor {
+	var match *Visitor
+	for _, v := range m.List(t) {
+		if !v.Expected(t) {
+			continue
+		}
+		if v.ArrivedAt == nil {
+			match = &v
+			break
+		}
+		if match == nil {
+			match = &v
+		}
+	}
+	if match == nil {
+		return nil
+	}
+	v, err := m.Arrived(match.ID, "doorbell", t)
+	if err != nil {
+		log.Printf("Warning: Failed to log arrival of %s: %v", match.Name, err)
+		return match
+	}
+	return v
+}
+
+// Run unlocks and relocks doors as visit windows open and close. It blocks,
+// so call it in a goroutine.
+func (m *Manager) Run() {
+	ticker := time.NewTicker(TickInterval)
+	defer ticker.Stop()
+	for {
+		m.Tick(time.Now())
+		<-ticker.C
+	}
+}
+
+// Tick unlocks doors for windows that are open and locks them for windows
+// that have closed. A door no longer in the visitor doors is only locked.
+func (m *Manager) Tick(now time.Time) {
+	m.mu.RLock()
+	var changes []Visitor
+	for _, v := range m.visitors {
+		if v.Unlock == "" || v.Unlocked == v.Expected(now) {
+			continue
+		}
+		if !v.Unlocked && !m.doors[v.Unlock] {
+			continue
+		}
+		changes = append(changes, v)
+	}
+	m.mu.RUnlock()
+
+	for _, v := range changes {
+		unlock := !v.Unlocked
+		if err := m.lock(v.Unlock, unlock, v); err != nil {
+			log.Printf("Warning: Failed to %s %s for visitor %s: %v", lockAction(unlock), v.Unlock, v.Name, err)
+			continue
+		}
+		log.Printf("Visitors: %s %s for %s", lockAction(unlock)+"ed", v.Unlock, v.Name)
+
+		m.mu.Lock()
+		if idx := m.indexOf(v.ID); idx >= 0 {
+			m.visitors[idx].Unlocked = unlock
+			if err := m.save(); err != nil {
+				log.Printf("Warning: %v", err)
+			}
+		}
+		m.mu.Unlock()
+		m.notify()
+	}
+}
+
+func lockAction(unlock bool) string {
+	if unlock {
+		return "unlock"
+	}
+	return "lock"
+}
+
+func (m *Manager) validate(v *Visitor) error {
+	v.Name = strings.TrimSpace(v.Name)
+	if v.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if v.From.IsZero() || v.Until.IsZero() {
+		return fmt.Errorf("from and until are required")
+	}
+	if !v.Until.After(v.From) {
+		return fmt.Errorf("until must be after from")
+	}
+	if v.Unlock != "" && !m.doors[v.Unlock] {
+		return fmt.Errorf("%s is not a visitor door", v.Unlock)
+	}
+	return nil
+}
+
+// indexOf returns the position of a visitor (caller must hold the lock)
+func (m *Manager) indexOf(id string) int {
+	for i, v := range m.visitors {
+		if v.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// save persists the visitors (caller must hold the lock)
+func (m *Manager) save() error {
+	if err := m.doc.Save(m.visitors); err != nil {
+		return fmt.Errorf("failed to save visitors: %w", err)
+	}
+	return nil
+}
+
+func (m *Manager) notify() {
+	m.mu.RLock()
+	fn := m.onChange
+	m.mu.RUnlock()
+	if fn != nil {
+		fn()
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -1,21 +1,20 @@
 package weather
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"sync"
 	"time"
 )
 
-// Client handles OpenWeatherMap API requests with caching
+// Client fetches weather for one location from the first working provider,
+// with caching
 type Client struct {
-	apiKey    string
+	providers []Provider
+	googleKey string // Google Maps Platform key, also used for air quality and pollen (optional)
+	name      string // Location name, set when part of Locations
 	lat       float64
 	lon       float64
-	units     string
 	cache     *WeatherData
 	cacheMu   sync.RWMutex
 	lastFetch time.Time
@@ -24,11 +23,20 @@ type Client struct {
 
 // WeatherData represents the cached weather information
 type WeatherData struct {
+	Location  string          `json:"location,omitempty"`
+	Source    string          `json:"source"` // Provider the forecast came from
 	Current   CurrentWeather  `json:"current"`
 	Hourly    []HourlyWeather `json:"hourly"`
 	Daily     []DailyWeather  `json:"daily"`
+	UVHourly  []UVReading     `json:"uvHourly,omitempty"` // Next 24 hours of UV index
 	Timezone  string          `json:"timezone"`
 	FetchedAt time.Time       `json:"fetchedAt"`
+
+	// Optional sections, omitted when their source is unavailable
+	HourlyForecast []HourlyWeather `json:"hourlyForecast,omitempty"` // Next 48 hours, hour by hour
+	Extended       []DailyWeather  `json:"extended,omitempty"`       // 10-day forecast
+	AirQuality     *AirQuality     `json:"airQuality,omitempty"`
+	Pollen         *Pollen         `json:"pollen,omitempty"`
 }
 
 // CurrentWeather represents current weather conditions
@@ -55,6 +63,7 @@ type HourlyWeather struct {
 	Condition string  `json:"condition"`
 	Icon      string  `json:"icon"`
 	Pop       float64 `json:"pop"` // Probability of precipitation
+	UVI       float64 `json:"uvi"`
 }
 
 // DailyWeather represents daily forecast
@@ -69,67 +78,18 @@ type DailyWeather struct {
 	Sunrise   int64   `json:"sunrise"`
 	Sunset    int64   `json:"sunset"`
 	Summary   string  `json:"summary"`
+	UVIMax    float64 `json:"uviMax"`
 }
 
-// OpenWeatherMap 2.5 API response structures (FREE tier)
-type owmCurrentResponse struct {
-	Main struct {
-		Temp      float64 `json:"temp"`
-		FeelsLike float64 `json:"feels_like"`
-		Humidity  int     `json:"humidity"`
-	} `json:"main"`
-	Wind struct {
-		Speed float64 `json:"speed"`
-		Deg   int     `json:"deg"`
-	} `json:"wind"`
-	Clouds struct {
-		All int `json:"all"`
-	} `json:"clouds"`
-	Weather []struct {
-		Main        string `json:"main"`
-		Description string `json:"description"`
-		Icon        string `json:"icon"`
-	} `json:"weather"`
-	Sys struct {
-		Sunrise int64 `json:"sunrise"`
-		Sunset  int64 `json:"sunset"`
-	} `json:"sys"`
-	Timezone int    `json:"timezone"`
-	Name     string `json:"name"`
-}
-
-type owmForecastResponse struct {
-	List []struct {
-		Dt   int64 `json:"dt"`
-		Main struct {
-			Temp      float64 `json:"temp"`
-			FeelsLike float64 `json:"feels_like"`
-			TempMin   float64 `json:"temp_min"`
-			TempMax   float64 `json:"temp_max"`
-			Humidity  int     `json:"humidity"`
-		} `json:"main"`
-		Weather []struct {
-			Main        string `json:"main"`
-			Description string `json:"description"`
-			Icon        string `json:"icon"`
-		} `json:"weather"`
-		Pop float64 `json:"pop"`
-	} `json:"list"`
-	City struct {
-		Sunrise  int64 `json:"sunrise"`
-		Sunset   int64 `json:"sunset"`
-		Timezone int   `json:"timezone"`
-	} `json:"city"`
-}
-
-// NewClient creates a new weather client
-func NewClient(apiKey string, lat, lon float64, timezone *time.Location) *Client {
+// NewClient creates a new weather client. Google and OpenWeatherMap are used
+// when their keys are set, falling back to Open-Meteo.
+func NewClient(googleKey, openWeatherKey string, lat, lon float64, timezone *time.Location) *Client {
 	return &Client{
-		apiKey:   apiKey,
-		lat:      lat,
-		lon:      lon,
-		units:    "imperial", // Fahrenheit
-		timezone: timezone,
+		providers: providers(googleKey, openWeatherKey),
+		googleKey: googleKey,
+		lat:       lat,
+		lon:       lon,
+		timezone:  timezone,
 	}
 }
 
@@ -137,7 +97,7 @@ func NewClient(apiKey string, lat, lon float64, timezone *time.Location) *Client
 func (c *Client) Start() {
 	// Initial fetch
 	if err := c.Refresh(); err != nil {
-		log.Printf("Initial weather fetch failed: %v", err)
+		log.Printf("Initial weather fetch failed%s: %v", c.logName(), err)
 	}
 
 	// Start scheduler
@@ -151,13 +111,13 @@ func (c *Client) runScheduler() {
 		nextRefresh := c.getNextRefreshTime(now)
 		duration := nextRefresh.Sub(now)
 
-		log.Printf("Weather: next refresh at %s (in %v)", nextRefresh.Format("3:04 PM"), duration.Round(time.Minute))
+		log.Printf("Weather%s: next refresh at %s (in %v)", c.logName(), nextRefresh.Format("3:04 PM"), duration.Round(time.Minute))
 
 		timer := time.NewTimer(duration)
 		<-timer.C
 
 		if err := c.Refresh(); err != nil {
-			log.Printf("Scheduled weather refresh failed: %v", err)
+			log.Printf("Scheduled weather refresh failed%s: %v", c.logName(), err)
 		}
 	}
 }
@@ -179,192 +139,56 @@ func (c *Client) getNextRefreshTime(now time.Time) time.Time {
 	return today.Add(25 * time.Hour) // 1am next day
 }
 
-// Refresh fetches fresh weather data from OpenWeatherMap (FREE 2.5 API)
+// Refresh fetches fresh weather data, trying each provider in turn
 func (c *Client) Refresh() error {
-	log.Printf("Weather API request: lat=%.4f, lon=%.4f", c.lat, c.lon)
-
-	// Fetch current weather
-	currentURL := fmt.Sprintf(
-		"https://api.openweathermap.org/data/2.5/weather?lat=%f&lon=%f&units=%s&appid=%s",
-		c.lat, c.lon, c.units, c.apiKey,
-	)
-
-	currentResp, err := c.fetchURL(currentURL)
-	if err != nil {
-		return fmt.Errorf("failed to fetch current weather: %w", err)
+	log.Printf("Weather API request%s: lat=%.4f, lon=%.4f", c.logName(), c.lat, c.lon)
+
+	var data *WeatherData
+	var errs []error
+	for _, p := range c.providers {
+		d, err := p.Fetch(c.lat, c.lon, c.timezone)
+		if err != nil {
+			log.Printf("Warning: %s weather failed%s: %v", p.Name(), c.logName(), err)
+			errs = append(errs, fmt.Errorf("%s: %w", p.Name(), err))
+			continue
+		}
+		data = d
+		data.Source = p.Name()
+		break
 	}
-
-	var current owmCurrentResponse
-	if err := json.Unmarshal(currentResp, &current); err != nil {
-		return fmt.Errorf("failed to decode current weather: %w", err)
+	if data == nil {
+		return fmt.Errorf("all weather providers failed: %v", errs)
 	}
+	data.Location = c.name
 
-	// Fetch 5-day forecast
-	forecastURL := fmt.Sprintf(
-		"https://api.openweathermap.org/data/2.5/forecast?lat=%f&lon=%f&units=%s&appid=%s",
-		c.lat, c.lon, c.units, c.apiKey,
-	)
-
-	forecastResp, err := c.fetchURL(forecastURL)
-	if err != nil {
-		return fmt.Errorf("failed to fetch forecast: %w", err)
+	// UV is optional; keep the rest of the forecast if it fails
+	if uv, err := c.fetchUV(); err != nil {
+		log.Printf("Warning: %v", err)
+	} else {
+		c.applyUV(data, uv)
 	}
-
-	var forecast owmForecastResponse
-	if err := json.Unmarshal(forecastResp, &forecast); err != nil {
-		return fmt.Errorf("failed to decode forecast: %w", err)
+	if err := c.fetchExtended(data); err != nil {
+		log.Printf("Warning: %v", err)
+	}
+	if err := c.fetchAirQuality(data); err != nil {
+		log.Printf("Warning: %v", err)
 	}
-
-	// Convert to our format
-	data := c.convertResponse(&current, &forecast)
 
 	c.cacheMu.Lock()
 	c.cache = data
 	c.lastFetch = time.Now()
 	c.cacheMu.Unlock()
 
-	log.Printf("Weather updated: %.0f°F, %s", data.Current.Temp, data.Current.Condition)
+	log.Printf("Weather updated%s from %s: %.0f°F, %s", c.logName(), data.Source, data.Current.Temp, data.Current.Condition)
 	return nil
 }
 
-func (c *Client) fetchURL(url string) ([]byte, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	return body, nil
-}
-
-// convertResponse converts OpenWeatherMap 2.5 API responses to our format
-func (c *Client) convertResponse(current *owmCurrentResponse, forecast *owmForecastResponse) *WeatherData {
-	data := &WeatherData{
-		Timezone:  current.Name,
-		FetchedAt: time.Now(),
-	}
-
-	// Current weather
-	if len(current.Weather) > 0 {
-		data.Current = CurrentWeather{
-			Temp:      current.Main.Temp,
-			FeelsLike: current.Main.FeelsLike,
-			Humidity:  current.Main.Humidity,
-			WindSpeed: current.Wind.Speed,
-			WindDeg:   current.Wind.Deg,
-			Clouds:    current.Clouds.All,
-			UVI:       0, // Not available in free API
-			Condition: current.Weather[0].Main,
-			Icon:      c.mapIcon(current.Weather[0].Icon),
-			Sunrise:   current.Sys.Sunrise,
-			Sunset:    current.Sys.Sunset,
-		}
-	}
-
-	// Hourly forecast (3-hour intervals from 5-day forecast)
-	for i, item := range forecast.List {
-		if i >= 8 { // ~24 hours (8 x 3-hour intervals)
-			break
-		}
-		if len(item.Weather) > 0 {
-			data.Hourly = append(data.Hourly, HourlyWeather{
-				Time:      item.Dt,
-				Temp:      item.Main.Temp,
-				FeelsLike: item.Main.FeelsLike,
-				Humidity:  item.Main.Humidity,
-				Condition: item.Weather[0].Main,
-				Icon:      c.mapIcon(item.Weather[0].Icon),
-				Pop:       item.Pop,
-			})
-		}
-	}
-
-	// Daily forecast - aggregate from 3-hour data
-	dailyMap := make(map[string]*DailyWeather)
-	for _, item := range forecast.List {
-		if len(item.Weather) == 0 {
-			continue
-		}
-
-		// Get date string for grouping
-		t := time.Unix(item.Dt, 0).In(c.timezone)
-		dateKey := t.Format("2006-01-02")
-
-		if daily, ok := dailyMap[dateKey]; ok {
-			// Update min/max temps
-			if item.Main.TempMin < daily.TempMin {
-				daily.TempMin = item.Main.TempMin
-			}
-			if item.Main.TempMax > daily.TempMax {
-				daily.TempMax = item.Main.TempMax
-			}
-			if item.Pop > daily.Pop {
-				daily.Pop = item.Pop
-			}
-		} else {
-			// Create new daily entry
-			dailyMap[dateKey] = &DailyWeather{
-				Time:      time.Date(t.Year(), t.Month(), t.Day(), 12, 0, 0, 0, c.timezone).Unix(),
-				TempMin:   item.Main.TempMin,
-				TempMax:   item.Main.TempMax,
-				Humidity:  item.Main.Humidity,
-				Condition: item.Weather[0].Main,
-				Icon:      c.mapIcon(item.Weather[0].Icon),
-				Pop:       item.Pop,
-				Sunrise:   forecast.City.Sunrise,
-				Sunset:    forecast.City.Sunset,
-				Summary:   item.Weather[0].Description,
-			}
-		}
-	}
-
-	// Convert map to sorted slice (up to 5 days)
-	for i := 0; i < 5; i++ {
-		t := time.Now().In(c.timezone).AddDate(0, 0, i)
-		dateKey := t.Format("2006-01-02")
-		if daily, ok := dailyMap[dateKey]; ok {
-			data.Daily = append(data.Daily, *daily)
-		}
-	}
-
-	return data
-}
-
-// mapIcon converts OpenWeatherMap icon codes to our icon names
-func (c *Client) mapIcon(code string) string {
-	switch code {
-	case "01d": // clear sky day
-		return "sun"
-	case "01n": // clear sky night
-		return "moon"
-	case "02d", "02n": // few clouds
-		return "cloud-sun"
-	case "03d", "03n": // scattered clouds
-		return "cloud"
-	case "04d", "04n": // broken clouds
-		return "clouds"
-	case "09d", "09n": // shower rain
-		return "cloud-rain"
-	case "10d", "10n": // rain
-		return "cloud-showers"
-	case "11d", "11n": // thunderstorm
-		return "bolt"
-	case "13d", "13n": // snow
-		return "snowflake"
-	case "50d", "50n": // mist
-		return "smog"
-	default:
-		return "cloud"
+// logName labels log lines with the location name, if any
+func (c *Client) logName() string {
+	if c.name == "" {
+		return ""
 	}
+	return " (" + c.name + ")"
 }
 
 // GetWeather returns the cached weather data
@@ -376,7 +200,7 @@ func (c *Client) GetWeather() *WeatherData {
 
 // IsConfigured returns true if the weather client is properly configured
 func (c *Client) IsConfigured() bool {
-	return c.apiKey != ""
+	return len(c.providers) > 0
 }
 
 // GetLastFetch returns when weather was last fetched
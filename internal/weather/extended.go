@@ -0,0 +1,445 @@
+package weather
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	hourlyForecastHours = 48
+	extendedDays        = 10
+)
+
+// AirQuality is the current air quality index with the main pollutants
+type AirQuality struct {
+	AQI      int     `json:"aqi"`
+	Index    string  `json:"index"` // Scale of AQI, e.g. usa_epa or uaqi (Google's universal index, higher is better)
+	Category string  `json:"category"`
+	Dominant string  `json:"dominant,omitempty"`
+	PM25     float64 `json:"pm25,omitempty"`  // µg/m³
+	PM10     float64 `json:"pm10,omitempty"`  // µg/m³
+	Ozone    float64 `json:"ozone,omitempty"` // µg/m³ from Open-Meteo, ppb from Google
+	Source   string  `json:"source"`
+}
+
+// Pollen is today's pollen level for each plant group
+type Pollen struct {
+	Types  []PollenType `json:"types"`
+	Source string       `json:"source"`
+}
+
+// PollenType is one plant group's level, 0 (none) to 5 (very high) like
+// Google's Universal Pollen Index
+type PollenType struct {
+	Name     string `json:"name"` // Tree, Grass or Weed
+	Index    int    `json:"index"`
+	Category string `json:"category"`
+}
+
+var pollenCategories = []string{"None", "Very Low", "Low", "Moderate", "High", "Very High"}
+
+// openMeteoForecastResponse is the Open-Meteo hour-by-hour and 10-day forecast,
+// with current conditions when the Open-Meteo provider asks for them
+type openMeteoForecastResponse struct {
+	Timezone string `json:"timezone"`
+	Current  struct {
+		Temp        float64 `json:"temperature_2m"`
+		FeelsLike   float64 `json:"apparent_temperature"`
+		Humidity    int     `json:"relative_humidity_2m"`
+		WindSpeed   float64 `json:"wind_speed_10m"`
+		WindDeg     int     `json:"wind_direction_10m"`
+		Clouds      int     `json:"cloud_cover"`
+		WeatherCode int     `json:"weather_code"`
+		IsDay       int     `json:"is_day"`
+		UVIndex     float64 `json:"uv_index"`
+	} `json:"current"`
+	Hourly struct {
+		Time        []int64   `json:"time"`
+		Temp        []float64 `json:"temperature_2m"`
+		FeelsLike   []float64 `json:"apparent_temperature"`
+		Humidity    []int     `json:"relative_humidity_2m"`
+		Pop         []int     `json:"precipitation_probability"`
+		WeatherCode []int     `json:"weather_code"`
+		UVIndex     []float64 `json:"uv_index"`
+		IsDay       []int     `json:"is_day"`
+	} `json:"hourly"`
+	Daily struct {
+		Time        []int64   `json:"time"`
+		WeatherCode []int     `json:"weather_code"`
+		TempMax     []float64 `json:"temperature_2m_max"`
+		TempMin     []float64 `json:"temperature_2m_min"`
+		Humidity    []int     `json:"relative_humidity_2m_mean"`
+		Pop         []int     `json:"precipitation_probability_max"`
+		Sunrise     []int64   `json:"sunrise"`
+		Sunset      []int64   `json:"sunset"`
+		UVIndexMax  []float64 `json:"uv_index_max"`
+	} `json:"daily"`
+}
+
+// openMeteoAirResponse is the Open-Meteo air quality and pollen forecast.
+// Pollen is only forecast for Europe; elsewhere the values are null.
+type openMeteoAirResponse struct {
+	Current struct {
+		USAQI float64 `json:"us_aqi"`
+		PM25  float64 `json:"pm2_5"`
+		PM10  float64 `json:"pm10"`
+		Ozone float64 `json:"ozone"`
+	} `json:"current"`
+	Hourly map[string]json.RawMessage `json:"hourly"`
+}
+
+// fetchExtended fills the hour-by-hour and 10-day forecasts from Open-Meteo
+// when the provider didn't
+func (c *Client) fetchExtended(data *WeatherData) error {
+	if data.HourlyForecast != nil && data.Extended != nil {
+		return nil
+	}
+	resp, err := openMeteoForecast(c.lat, c.lon, false)
+	if err != nil {
+		return fmt.Errorf("failed to fetch extended forecast: %w", err)
+	}
+	resp.fill(data)
+	return nil
+}
+
+// openMeteoForecast fetches the hour-by-hour and 10-day forecast, with current
+// conditions when withCurrent is set
+func openMeteoForecast(lat, lon float64, withCurrent bool) (*openMeteoForecastResponse, error) {
+	url := fmt.Sprintf(
+		"https://api.open-meteo.com/v1/forecast?latitude=%f&longitude=%f&timeformat=unixtime&timezone=auto&temperature_unit=fahrenheit&wind_speed_unit=mph&forecast_days=%d"+
+			"&hourly=temperature_2m,apparent_temperature,relative_humidity_2m,precipitation_probability,weather_code,uv_index,is_day"+
+			"&daily=weather_code,temperature_2m_max,temperature_2m_min,relative_humidity_2m_mean,precipitation_probability_max,sunrise,sunset,uv_index_max",
+		lat, lon, extendedDays,
+	)
+	if withCurrent {
+		url += "&current=temperature_2m,apparent_temperature,relative_humidity_2m,wind_speed_10m,wind_direction_10m,cloud_cover,weather_code,is_day,uv_index"
+	}
+	body, err := fetchURL(url)
+	if err != nil {
+		return nil, err
+	}
+	var resp openMeteoForecastResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode Open-Meteo forecast: %w", err)
+	}
+	return &resp, nil
+}
+
+// fill sets whichever of the hour-by-hour and 10-day forecasts data is missing
+func (resp *openMeteoForecastResponse) fill(data *WeatherData) {
+	if data.HourlyForecast == nil {
+		h := resp.Hourly
+		from := time.Now().Add(-time.Hour).Unix()
+		for i, t := range h.Time {
+			if t < from || i >= len(h.Temp) || i >= len(h.WeatherCode) {
+				continue
+			}
+			if len(data.HourlyForecast) >= hourlyForecastHours {
+				break
+			}
+			condition, icon := wmoCondition(h.WeatherCode[i], at(h.IsDay, i) == 1)
+			data.HourlyForecast = append(data.HourlyForecast, HourlyWeather{
+				Time:      t,
+				Temp:      h.Temp[i],
+				FeelsLike: at(h.FeelsLike, i),
+				Humidity:  at(h.Humidity, i),
+				Condition: condition,
+				Icon:      icon,
+				Pop:       float64(at(h.Pop, i)) / 100,
+				UVI:       at(h.UVIndex, i),
+			})
+		}
+	}
+
+	if data.Extended == nil {
+		d := resp.Daily
+		for i, t := range d.Time {
+			if i >= len(d.TempMax) || i >= len(d.WeatherCode) {
+				break
+			}
+			condition, icon := wmoCondition(d.WeatherCode[i], true)
+			data.Extended = append(data.Extended, DailyWeather{
+				Time:      t + 12*3600, // Noon, like the 5-day forecast
+				TempMin:   at(d.TempMin, i),
+				TempMax:   d.TempMax[i],
+				Humidity:  at(d.Humidity, i),
+				Condition: condition,
+				Icon:      icon,
+				Pop:       float64(at(d.Pop, i)) / 100,
+				Sunrise:   at(d.Sunrise, i),
+				Sunset:    at(d.Sunset, i),
+				Summary:   strings.ToLower(condition),
+				UVIMax:    at(d.UVIndexMax, i),
+			})
+		}
+	}
+}
+
+// fetchAirQuality fills air quality and pollen, from Google's Air Quality and
+// Pollen APIs when a Google key is set and Open-Meteo otherwise
+func (c *Client) fetchAirQuality(data *WeatherData) error {
+	if c.googleKey != "" {
+		aq, aqErr := c.fetchGoogleAirQuality()
+		pollen, pollenErr := c.fetchGooglePollen()
+		if aqErr == nil && pollenErr == nil {
+			data.AirQuality, data.Pollen = aq, pollen
+			return nil
+		}
+		// Fill whatever Google couldn't from Open-Meteo
+		if err := c.fetchOpenMeteoAirQuality(data); err != nil {
+			return err
+		}
+		if aqErr == nil {
+			data.AirQuality = aq
+		}
+		if pollenErr == nil {
+			data.Pollen = pollen
+		}
+		if aqErr != nil {
+			return aqErr
+		}
+		return pollenErr
+	}
+	return c.fetchOpenMeteoAirQuality(data)
+}
+
+func (c *Client) fetchOpenMeteoAirQuality(data *WeatherData) error {
+	url := fmt.Sprintf(
+		"https://air-quality-api.open-meteo.com/v1/air-quality?latitude=%f&longitude=%f&timeformat=unixtime&timezone=auto&forecast_days=1"+
+			"&current=us_aqi,pm2_5,pm10,ozone&hourly=alder_pollen,birch_pollen,olive_pollen,grass_pollen,mugwort_pollen,ragweed_pollen",
+		c.lat, c.lon,
+	)
+	body, err := fetchURL(url)
+	if err != nil {
+		return fmt.Errorf("failed to fetch air quality: %w", err)
+	}
+	var resp openMeteoAirResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("failed to decode air quality: %w", err)
+	}
+
+	aqi := int(resp.Current.USAQI + 0.5)
+	data.AirQuality = &AirQuality{
+		AQI:      aqi,
+		Index:    "usa_epa",
+		Category: usAQICategory(aqi),
+		PM25:     resp.Current.PM25,
+		PM10:     resp.Current.PM10,
+		Ozone:    resp.Current.Ozone,
+		Source:   "open-meteo",
+	}
+
+	// Today's peak for each group, in grains/m³, with the NAB count thresholds
+	// for low, moderate, high and very high
+	groups := []struct {
+		name       string
+		fields     []string
+		thresholds [4]float64
+	}{
+		{"Tree", []string{"alder_pollen", "birch_pollen", "olive_pollen"}, [4]float64{1, 15, 90, 1500}},
+		{"Grass", []string{"grass_pollen"}, [4]float64{1, 5, 20, 200}},
+		{"Weed", []string{"mugwort_pollen", "ragweed_pollen"}, [4]float64{1, 10, 50, 500}},
+	}
+	pollen := &Pollen{Source: "open-meteo"}
+	for _, g := range groups {
+		peak, found := 0.0, false
+		for _, field := range g.fields {
+			var values []*float64
+			if err := json.Unmarshal(resp.Hourly[field], &values); err != nil {
+				continue
+			}
+			for _, v := range values {
+				if v != nil {
+					found = true
+					peak = max(peak, *v)
+				}
+			}
+		}
+		if !found {
+			continue
+		}
+		index := 0
+		switch {
+		case peak >= g.thresholds[3]:
+			index = 5
+		case peak >= g.thresholds[2]:
+			index = 4
+		case peak >= g.thresholds[1]:
+			index = 3
+		case peak >= g.thresholds[0]*3:
+			index = 2
+		case peak >= g.thresholds[0]:
+			index = 1
+		}
+		pollen.Types = append(pollen.Types, PollenType{Name: g.name, Index: index, Category: pollenCategories[index]})
+	}
+	if len(pollen.Types) > 0 {
+		data.Pollen = pollen
+	}
+	return nil
+}
+
+// googleAirQualityResponse is the subset of the Air Quality API response used
+type googleAirQualityResponse struct {
+	Indexes []struct {
+		Code              string `json:"code"`
+		AQI               int    `json:"aqi"`
+		Category          string `json:"category"`
+		DominantPollutant string `json:"dominantPollutant"`
+	} `json:"indexes"`
+	Pollutants []struct {
+		Code          string `json:"code"`
+		Concentration struct {
+			Value float64 `json:"value"`
+		} `json:"concentration"`
+	} `json:"pollutants"`
+}
+
+func (c *Client) fetchGoogleAirQuality() (*AirQuality, error) {
+	reqBody, _ := json.Marshal(map[string]interface{}{
+		"location":          map[string]float64{"latitude": c.lat, "longitude": c.lon},
+		"extraComputations": []string{"LOCAL_AQI", "POLLUTANT_CONCENTRATION", "DOMINANT_POLLUTANT_CONCENTRATION"},
+	})
+	resp, err := http.Post("https://airquality.googleapis.com/v1/currentConditions:lookup?key="+c.googleKey, "application/json", bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Google air quality: %w", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Google air quality: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch Google air quality: API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var data googleAirQualityResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, fmt.Errorf("failed to decode Google air quality: %w", err)
+	}
+	if len(data.Indexes) == 0 {
+		return nil, fmt.Errorf("no air quality index for this location")
+	}
+	// Prefer the local index (e.g. US EPA) over the universal one
+	idx := data.Indexes[0]
+	for _, i := range data.Indexes {
+		if i.Code != "uaqi" {
+			idx = i
+			break
+		}
+	}
+	aq := &AirQuality{AQI: idx.AQI, Index: idx.Code, Category: idx.Category, Dominant: idx.DominantPollutant, Source: "google"}
+	for _, p := range data.Pollutants {
+		switch p.Code {
+		case "pm25":
+			aq.PM25 = p.Concentration.Value
+		case "pm10":
+			aq.PM10 = p.Concentration.Value
+		case "o3":
+			aq.Ozone = p.Concentration.Value
+		}
+	}
+	return aq, nil
+}
+
+// googlePollenResponse is the subset of the Pollen API forecast used
+type googlePollenResponse struct {
+	DailyInfo []struct {
+		PollenTypeInfo []struct {
+			DisplayName string `json:"displayName"`
+			IndexInfo   *struct {
+				Value    int    `json:"value"`
+				Category string `json:"category"`
+			} `json:"indexInfo"`
+		} `json:"pollenTypeInfo"`
+	} `json:"dailyInfo"`
+}
+
+func (c *Client) fetchGooglePollen() (*Pollen, error) {
+	url := fmt.Sprintf(
+		"https://pollen.googleapis.com/v1/forecast:lookup?key=%s&location.latitude=%f&location.longitude=%f&days=1&plantsDescription=false",
+		c.googleKey, c.lat, c.lon,
+	)
+	body, err := fetchURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Google pollen: %w", err)
+	}
+	var resp googlePollenResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode Google pollen: %w", err)
+	}
+	if len(resp.DailyInfo) == 0 {
+		return nil, fmt.Errorf("no pollen forecast for this location")
+	}
+
+	pollen := &Pollen{Source: "google"}
+	for _, t := range resp.DailyInfo[0].PollenTypeInfo {
+		pt := PollenType{Name: t.DisplayName, Category: pollenCategories[0]}
+		if t.IndexInfo != nil {
+			pt.Index, pt.Category = t.IndexInfo.Value, t.IndexInfo.Category
+		}
+		pollen.Types = append(pollen.Types, pt)
+	}
+	return pollen, nil
+}
+
+// usAQICategory names a US EPA AQI band
+func usAQICategory(aqi int) string {
+	switch {
+	case aqi <= 50:
+		return "Good"
+	case aqi <= 100:
+		return "Moderate"
+	case aqi <= 150:
+		return "Unhealthy for Sensitive Groups"
+	case aqi <= 200:
+		return "Unhealthy"
+	case aqi <= 300:
+		return "Very Unhealthy"
+	default:
+		return "Hazardous"
+	}
+}
+
+// wmoCondition maps a WMO weather code to a condition and our icon names
+func wmoCondition(code int, day bool) (string, string) {
+	switch {
+	case code == 0:
+		if day {
+			return "Clear", "sun"
+		}
+		return "Clear", "moon"
+	case code == 1 || code == 2:
+		return "Clouds", "cloud-sun"
+	case code == 3:
+		return "Clouds", "clouds"
+	case code == 45 || code == 48:
+		return "Fog", "smog"
+	case code >= 51 && code <= 57:
+		return "Drizzle", "cloud-rain"
+	case code >= 61 && code <= 67:
+		return "Rain", "cloud-showers"
+	case code >= 71 && code <= 77, code == 85 || code == 86:
+		return "Snow", "snowflake"
+	case code >= 80 && code <= 82:
+		return "Rain", "cloud-rain"
+	case code >= 95:
+		return "Thunderstorm", "bolt"
+	default:
+		return "Clouds", "cloud"
+	}
+}
+
+// at returns values[i], or the zero value when the series is short
+func at[T any](values []T, i int) T {
+	var zero T
+	if i < len(values) {
+		return values[i]
+	}
+	return zero
+}
@@ -0,0 +1,94 @@
+package weather
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HomeLocation is the name of the WEATHER_LAT/WEATHER_LON location
+const HomeLocation = "home"
+
+// Location is a named place to fetch weather for
+type Location struct {
+	Name string  `json:"name"`
+	Lat  float64 `json:"lat"`
+	Lon  float64 `json:"lon"`
+}
+
+// ParseLocations parses "name=lat:lon,..." (e.g. "cabin=44.26:-72.57")
+func ParseLocations(s string) ([]Location, error) {
+	var locations []Location
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, coords, ok := strings.Cut(entry, "=")
+		latStr, lonStr, ok2 := strings.Cut(coords, ":")
+		name = strings.TrimSpace(name)
+		if !ok || !ok2 || name == "" {
+			return nil, fmt.Errorf("invalid weather location %q (use name=lat:lon)", entry)
+		}
+		lat, err := strconv.ParseFloat(strings.TrimSpace(latStr), 64)
+		if err != nil || lat < -90 || lat > 90 {
+			return nil, fmt.Errorf("invalid latitude in weather location %q", entry)
+		}
+		lon, err := strconv.ParseFloat(strings.TrimSpace(lonStr), 64)
+		if err != nil || lon < -180 || lon > 180 {
+			return nil, fmt.Errorf("invalid longitude in weather location %q", entry)
+		}
+		locations = append(locations, Location{Name: name, Lat: lat, Lon: lon})
+	}
+	return locations, nil
+}
+
+// Locations is a set of named locations, each with its own cached client.
+// The first location is the default.
+type Locations struct {
+	locations []Location
+	clients   map[string]*Client // Lowercased name -> client
+}
+
+// NewLocations creates a client per location; later duplicates of a name are ignored
+func NewLocations(googleKey, openWeatherKey string, locations []Location, timezone *time.Location) *Locations {
+	l := &Locations{clients: make(map[string]*Client)}
+	for _, loc := range locations {
+		key := strings.ToLower(loc.Name)
+		if _, ok := l.clients[key]; ok {
+			continue
+		}
+		client := NewClient(googleKey, openWeatherKey, loc.Lat, loc.Lon, timezone)
+		client.name = loc.Name
+		l.clients[key] = client
+		l.locations = append(l.locations, loc)
+	}
+	return l
+}
+
+// Start begins refreshing every location
+func (l *Locations) Start() {
+	for _, loc := range l.locations {
+		l.clients[strings.ToLower(loc.Name)].Start()
+	}
+}
+
+// List returns the locations in configured order
+func (l *Locations) List() []Location {
+	return append([]Location(nil), l.locations...)
+}
+
+// Default returns the first location's client
+func (l *Locations) Default() *Client {
+	if len(l.locations) == 0 {
+		return nil
+	}
+	return l.clients[strings.ToLower(l.locations[0].Name)]
+}
+
+// Get returns a location's client by name (case-insensitive)
+func (l *Locations) Get(name string) (*Client, bool) {
+	client, ok := l.clients[strings.ToLower(strings.TrimSpace(name))]
+	return client, ok
+}
@@ -0,0 +1,227 @@
+package weather
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// OpenWeatherMap 2.5 API response structures (FREE tier)
+type owmCurrentResponse struct {
+	Main struct {
+		Temp      float64 `json:"temp"`
+		FeelsLike float64 `json:"feels_like"`
+		Humidity  int     `json:"humidity"`
+	} `json:"main"`
+	Wind struct {
+		Speed float64 `json:"speed"`
+		Deg   int     `json:"deg"`
+	} `json:"wind"`
+	Clouds struct {
+		All int `json:"all"`
+	} `json:"clouds"`
+	Weather []struct {
+		Main        string `json:"main"`
+		Description string `json:"description"`
+		Icon        string `json:"icon"`
+	} `json:"weather"`
+	Sys struct {
+		Sunrise int64 `json:"sunrise"`
+		Sunset  int64 `json:"sunset"`
+	} `json:"sys"`
+	Timezone int    `json:"timezone"`
+	Name     string `json:"name"`
+}
+
+type owmForecastResponse struct {
+	List []struct {
+		Dt   int64 `json:"dt"`
+		Main struct {
+			Temp      float64 `json:"temp"`
+			FeelsLike float64 `json:"feels_like"`
+			TempMin   float64 `json:"temp_min"`
+			TempMax   float64 `json:"temp_max"`
+			Humidity  int     `json:"humidity"`
+		} `json:"main"`
+		Weather []struct {
+			Main        string `json:"main"`
+			Description string `json:"description"`
+			Icon        string `json:"icon"`
+		} `json:"weather"`
+		Pop float64 `json:"pop"`
+	} `json:"list"`
+	City struct {
+		Sunrise  int64 `json:"sunrise"`
+		Sunset   int64 `json:"sunset"`
+		Timezone int   `json:"timezone"`
+	} `json:"city"`
+}
+
+// openWeatherMap is the OpenWeatherMap provider (free 2.5 API)
+type openWeatherMap struct {
+	apiKey string
+	units  string
+}
+
+func (p *openWeatherMap) Name() string {
+	return "openweathermap"
+}
+
+// Fetch gets current conditions and the 5-day, 3-hour forecast
+func (p *openWeatherMap) Fetch(lat, lon float64, timezone *time.Location) (*WeatherData, error) {
+	// Fetch current weather
+	currentURL := fmt.Sprintf(
+		"https://api.openweathermap.org/data/2.5/weather?lat=%f&lon=%f&units=%s&appid=%s",
+		lat, lon, p.units, p.apiKey,
+	)
+
+	currentResp, err := fetchURL(currentURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch current weather: %w", err)
+	}
+
+	var current owmCurrentResponse
+	if err := json.Unmarshal(currentResp, &current); err != nil {
+		return nil, fmt.Errorf("failed to decode current weather: %w", err)
+	}
+
+	// Fetch 5-day forecast
+	forecastURL := fmt.Sprintf(
+		"https://api.openweathermap.org/data/2.5/forecast?lat=%f&lon=%f&units=%s&appid=%s",
+		lat, lon, p.units, p.apiKey,
+	)
+
+	forecastResp, err := fetchURL(forecastURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch forecast: %w", err)
+	}
+
+	var forecast owmForecastResponse
+	if err := json.Unmarshal(forecastResp, &forecast); err != nil {
+		return nil, fmt.Errorf("failed to decode forecast: %w", err)
+	}
+
+	// Convert to our format
+	return p.convertResponse(&current, &forecast, timezone), nil
+}
+
+// convertResponse converts OpenWeatherMap 2.5 API responses to our format
+func (p *openWeatherMap) convertResponse(current *owmCurrentResponse, forecast *owmForecastResponse, timezone *time.Location) *WeatherData {
+	data := &WeatherData{
+		Timezone:  current.Name,
+		FetchedAt: time.Now(),
+	}
+
+	// Current weather
+	if len(current.Weather) > 0 {
+		data.Current = CurrentWeather{
+			Temp:      current.Main.Temp,
+			FeelsLike: current.Main.FeelsLike,
+			Humidity:  current.Main.Humidity,
+			WindSpeed: current.Wind.Speed,
+			WindDeg:   current.Wind.Deg,
+			Clouds:    current.Clouds.All,
+			UVI:       0, // Not in the free API; filled from Open-Meteo in Refresh
+			Condition: current.Weather[0].Main,
+			Icon:      p.mapIcon(current.Weather[0].Icon),
+			Sunrise:   current.Sys.Sunrise,
+			Sunset:    current.Sys.Sunset,
+		}
+	}
+
+	// Hourly forecast (3-hour intervals from 5-day forecast)
+	for i, item := range forecast.List {
+		if i >= 8 { // ~24 hours (8 x 3-hour intervals)
+			break
+		}
+		if len(item.Weather) > 0 {
+			data.Hourly = append(data.Hourly, HourlyWeather{
+				Time:      item.Dt,
+				Temp:      item.Main.Temp,
+				FeelsLike: item.Main.FeelsLike,
+				Humidity:  item.Main.Humidity,
+				Condition: item.Weather[0].Main,
+				Icon:      p.mapIcon(item.Weather[0].Icon),
+				Pop:       item.Pop,
+			})
+		}
+	}
+
+	// Daily forecast - aggregate from 3-hour data
+	dailyMap := make(map[string]*DailyWeather)
+	for _, item := range forecast.List {
+		if len(item.Weather) == 0 {
+			continue
+		}
+
+		// Get date string for grouping
+		t := time.Unix(item.Dt, 0).In(timezone)
+		dateKey := t.Format("2006-01-02")
+
+		if daily, ok := dailyMap[dateKey]; ok {
+			// Update min/max temps
+			if item.Main.TempMin < daily.TempMin {
+				daily.TempMin = item.Main.TempMin
+			}
+			if item.Main.TempMax > daily.TempMax {
+				daily.TempMax = item.Main.TempMax
+			}
+			if item.Pop > daily.Pop {
+				daily.Pop = item.Pop
+			}
+		} else {
+			// Create new daily entry
+			dailyMap[dateKey] = &DailyWeather{
+				Time:      time.Date(t.Year(), t.Month(), t.Day(), 12, 0, 0, 0, timezone).Unix(),
+				TempMin:   item.Main.TempMin,
+				TempMax:   item.Main.TempMax,
+				Humidity:  item.Main.Humidity,
+				Condition: item.Weather[0].Main,
+				Icon:      p.mapIcon(item.Weather[0].Icon),
+				Pop:       item.Pop,
+				Sunrise:   forecast.City.Sunrise,
+				Sunset:    forecast.City.Sunset,
+				Summary:   item.Weather[0].Description,
+			}
+		}
+	}
+
+	// Convert map to sorted slice (up to 5 days)
+	for i := 0; i < 5; i++ {
+		t := time.Now().In(timezone).AddDate(0, 0, i)
+		dateKey := t.Format("2006-01-02")
+		if daily, ok := dailyMap[dateKey]; ok {
+			data.Daily = append(data.Daily, *daily)
+		}
+	}
+
+	return data
+}
+
+// mapIcon converts OpenWeatherMap icon codes to our icon names
+func (p *openWeatherMap) mapIcon(code string) string {
+	switch code {
+	case "01d": // clear sky day
+		return "sun"
+	case "01n": // clear sky night
+		return "moon"
+	case "02d", "02n": // few clouds
+		return "cloud-sun"
+	case "03d", "03n": // scattered clouds
+		return "cloud"
+	case "04d", "04n": // broken clouds
+		return "clouds"
+	case "09d", "09n": // shower rain
+		return "cloud-rain"
+	case "10d", "10n": // rain
+		return "cloud-showers"
+	case "11d", "11n": // thunderstorm
+		return "bolt"
+	case "13d", "13n": // snow
+		return "snowflake"
+	case "50d", "50n": // mist
+		return "smog"
+	default:
+		return "cloud"
+	}
+}
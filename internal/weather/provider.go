@@ -0,0 +1,285 @@
+package weather
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Provider is a weather source. Clients try their providers in order until one
+// succeeds, so weather keeps working when a keyed API is down or unset.
+type Provider interface {
+	Name() string
+	Fetch(lat, lon float64, timezone *time.Location) (*WeatherData, error)
+}
+
+// providers returns Google and OpenWeatherMap when their keys are set, then
+// Open-Meteo, which needs no key
+func providers(googleKey, openWeatherKey string) []Provider {
+	var list []Provider
+	if googleKey != "" {
+		list = append(list, &googleWeather{apiKey: googleKey})
+	}
+	if openWeatherKey != "" {
+		list = append(list, &openWeatherMap{apiKey: openWeatherKey, units: "imperial"}) // Fahrenheit
+	}
+	return append(list, openMeteo{})
+}
+
+// openMeteo is the Open-Meteo provider (no API key)
+type openMeteo struct{}
+
+func (openMeteo) Name() string {
+	return "open-meteo"
+}
+
+// Fetch gets current conditions with the hour-by-hour and 10-day forecast
+func (openMeteo) Fetch(lat, lon float64, timezone *time.Location) (*WeatherData, error) {
+	resp, err := openMeteoForecast(lat, lon, true)
+	if err != nil {
+		return nil, err
+	}
+	data := &WeatherData{Timezone: resp.Timezone, FetchedAt: time.Now()}
+	resp.fill(data)
+
+	cur := resp.Current
+	condition, icon := wmoCondition(cur.WeatherCode, cur.IsDay == 1)
+	data.Current = CurrentWeather{
+		Temp:      cur.Temp,
+		FeelsLike: cur.FeelsLike,
+		Humidity:  cur.Humidity,
+		WindSpeed: cur.WindSpeed,
+		WindDeg:   cur.WindDeg,
+		Clouds:    cur.Clouds,
+		UVI:       cur.UVIndex,
+		Condition: condition,
+		Icon:      icon,
+	}
+	if len(data.Extended) > 0 {
+		data.Current.Sunrise = data.Extended[0].Sunrise
+		data.Current.Sunset = data.Extended[0].Sunset
+	}
+
+	// Same shape as the OpenWeatherMap forecast: ~24 hours in 3-hour steps, 5 days
+	for i := 0; i < len(data.HourlyForecast) && len(data.Hourly) < 8; i += 3 {
+		data.Hourly = append(data.Hourly, data.HourlyForecast[i])
+	}
+	data.Daily = data.Extended[:min(5, len(data.Extended))]
+	return data, nil
+}
+
+// googleWeather is the Google Maps Platform Weather API provider
+type googleWeather struct {
+	apiKey string
+}
+
+func (p *googleWeather) Name() string {
+	return "google"
+}
+
+type googleCondition struct {
+	Type        string `json:"type"`
+	Description struct {
+		Text string `json:"text"`
+	} `json:"description"`
+}
+
+type googleTemperature struct {
+	Degrees float64 `json:"degrees"`
+}
+
+type googlePrecipitation struct {
+	Probability struct {
+		Percent int `json:"percent"`
+	} `json:"probability"`
+}
+
+type googleCurrentResponse struct {
+	IsDaytime            bool              `json:"isDaytime"`
+	WeatherCondition     googleCondition   `json:"weatherCondition"`
+	Temperature          googleTemperature `json:"temperature"`
+	FeelsLikeTemperature googleTemperature `json:"feelsLikeTemperature"`
+	RelativeHumidity     int               `json:"relativeHumidity"`
+	UVIndex              float64           `json:"uvIndex"`
+	CloudCover           int               `json:"cloudCover"`
+	Wind                 struct {
+		Direction struct {
+			Degrees int `json:"degrees"`
+		} `json:"direction"`
+		Speed struct {
+			Value float64 `json:"value"`
+		} `json:"speed"`
+	} `json:"wind"`
+	TimeZone struct {
+		ID string `json:"id"`
+	} `json:"timeZone"`
+}
+
+type googleHoursResponse struct {
+	ForecastHours []struct {
+		Interval struct {
+			StartTime time.Time `json:"startTime"`
+		} `json:"interval"`
+		IsDaytime            bool                `json:"isDaytime"`
+		WeatherCondition     googleCondition     `json:"weatherCondition"`
+		Temperature          googleTemperature   `json:"temperature"`
+		FeelsLikeTemperature googleTemperature   `json:"feelsLikeTemperature"`
+		RelativeHumidity     int                 `json:"relativeHumidity"`
+		Precipitation        googlePrecipitation `json:"precipitation"`
+		UVIndex              float64             `json:"uvIndex"`
+	} `json:"forecastHours"`
+}
+
+type googleDaysResponse struct {
+	ForecastDays []struct {
+		Interval struct {
+			StartTime time.Time `json:"startTime"`
+		} `json:"interval"`
+		DaytimeForecast struct {
+			WeatherCondition googleCondition     `json:"weatherCondition"`
+			RelativeHumidity int                 `json:"relativeHumidity"`
+			Precipitation    googlePrecipitation `json:"precipitation"`
+			UVIndex          float64             `json:"uvIndex"`
+		} `json:"daytimeForecast"`
+		MaxTemperature googleTemperature `json:"maxTemperature"`
+		MinTemperature googleTemperature `json:"minTemperature"`
+		SunEvents      struct {
+			SunriseTime time.Time `json:"sunriseTime"`
+			SunsetTime  time.Time `json:"sunsetTime"`
+		} `json:"sunEvents"`
+	} `json:"forecastDays"`
+}
+
+// Fetch gets current conditions, the next 24 hours and 10 days
+func (p *googleWeather) Fetch(lat, lon float64, timezone *time.Location) (*WeatherData, error) {
+	params := fmt.Sprintf("key=%s&location.latitude=%f&location.longitude=%f&unitsSystem=IMPERIAL", p.apiKey, lat, lon)
+
+	var current googleCurrentResponse
+	if err := fetchJSON("https://weather.googleapis.com/v1/currentConditions:lookup?"+params, &current); err != nil {
+		return nil, fmt.Errorf("failed to fetch current weather: %w", err)
+	}
+	var hours googleHoursResponse
+	if err := fetchJSON("https://weather.googleapis.com/v1/forecast/hours:lookup?hours=24&pageSize=24&"+params, &hours); err != nil {
+		return nil, fmt.Errorf("failed to fetch hourly forecast: %w", err)
+	}
+	var days googleDaysResponse
+	if err := fetchJSON(fmt.Sprintf("https://weather.googleapis.com/v1/forecast/days:lookup?days=%d&pageSize=%d&%s", extendedDays, extendedDays, params), &days); err != nil {
+		return nil, fmt.Errorf("failed to fetch daily forecast: %w", err)
+	}
+
+	data := &WeatherData{Timezone: current.TimeZone.ID, FetchedAt: time.Now()}
+	condition, icon := googleConditionIcon(current.WeatherCondition.Type, current.IsDaytime)
+	data.Current = CurrentWeather{
+		Temp:      current.Temperature.Degrees,
+		FeelsLike: current.FeelsLikeTemperature.Degrees,
+		Humidity:  current.RelativeHumidity,
+		WindSpeed: current.Wind.Speed.Value,
+		WindDeg:   current.Wind.Direction.Degrees,
+		Clouds:    current.CloudCover,
+		UVI:       current.UVIndex,
+		Condition: condition,
+		Icon:      icon,
+	}
+
+	for _, h := range hours.ForecastHours {
+		condition, icon := googleConditionIcon(h.WeatherCondition.Type, h.IsDaytime)
+		data.Hourly = append(data.Hourly, HourlyWeather{
+			Time:      h.Interval.StartTime.Unix(),
+			Temp:      h.Temperature.Degrees,
+			FeelsLike: h.FeelsLikeTemperature.Degrees,
+			Humidity:  h.RelativeHumidity,
+			Condition: condition,
+			Icon:      icon,
+			Pop:       float64(h.Precipitation.Probability.Percent) / 100,
+			UVI:       h.UVIndex,
+		})
+	}
+
+	for _, d := range days.ForecastDays {
+		day := d.DaytimeForecast
+		condition, icon := googleConditionIcon(day.WeatherCondition.Type, true)
+		t := d.Interval.StartTime.In(timezone)
+		data.Extended = append(data.Extended, DailyWeather{
+			Time:      time.Date(t.Year(), t.Month(), t.Day(), 12, 0, 0, 0, timezone).Unix(),
+			TempMin:   d.MinTemperature.Degrees,
+			TempMax:   d.MaxTemperature.Degrees,
+			Humidity:  day.RelativeHumidity,
+			Condition: condition,
+			Icon:      icon,
+			Pop:       float64(day.Precipitation.Probability.Percent) / 100,
+			Sunrise:   unixOrZero(d.SunEvents.SunriseTime),
+			Sunset:    unixOrZero(d.SunEvents.SunsetTime),
+			Summary:   strings.ToLower(day.WeatherCondition.Description.Text),
+			UVIMax:    day.UVIndex,
+		})
+	}
+	if len(data.Extended) > 0 {
+		data.Current.Sunrise = data.Extended[0].Sunrise
+		data.Current.Sunset = data.Extended[0].Sunset
+	}
+	data.Daily = data.Extended[:min(5, len(data.Extended))]
+	return data, nil
+}
+
+// googleConditionIcon maps a Google weather condition type (e.g.
+// PARTLY_CLOUDY, LIGHT_RAIN_SHOWERS) to a condition and our icon names
+func googleConditionIcon(kind string, day bool) (string, string) {
+	switch {
+	case strings.Contains(kind, "THUNDER"):
+		return "Thunderstorm", "bolt"
+	case strings.Contains(kind, "SNOW"), strings.Contains(kind, "HAIL"):
+		return "Snow", "snowflake"
+	case strings.Contains(kind, "SHOWERS"):
+		return "Rain", "cloud-rain"
+	case strings.Contains(kind, "RAIN"):
+		return "Rain", "cloud-showers"
+	case kind == "CLEAR" || kind == "MOSTLY_CLEAR":
+		if day {
+			return "Clear", "sun"
+		}
+		return "Clear", "moon"
+	case kind == "PARTLY_CLOUDY":
+		return "Clouds", "cloud-sun"
+	case kind == "MOSTLY_CLOUDY" || kind == "CLOUDY":
+		return "Clouds", "clouds"
+	default:
+		return "Clouds", "cloud"
+	}
+}
+
+func unixOrZero(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func fetchJSON(url string, v interface{}) error {
+	body, err := fetchURL(url)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, v)
+}
+
+func fetchURL(url string) ([]byte, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return body, nil
+}
@@ -0,0 +1,102 @@
+package weather
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"time"
+)
+
+// UVReading is the forecast UV index for one hour
+type UVReading struct {
+	Time int64   `json:"time"`
+	UVI  float64 `json:"uvi"`
+}
+
+// openMeteoUVResponse is the subset of the Open-Meteo forecast used for UV
+type openMeteoUVResponse struct {
+	Hourly struct {
+		Time    []int64   `json:"time"`
+		UVIndex []float64 `json:"uv_index"`
+	} `json:"hourly"`
+}
+
+// fetchUV gets the hourly UV index from Open-Meteo (no API key), since the free
+// OpenWeatherMap endpoints don't include UV
+func (c *Client) fetchUV() ([]UVReading, error) {
+	url := fmt.Sprintf(
+		"https://api.open-meteo.com/v1/forecast?latitude=%f&longitude=%f&hourly=uv_index&timeformat=unixtime&forecast_days=6",
+		c.lat, c.lon,
+	)
+	body, err := fetchURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch UV index: %w", err)
+	}
+
+	var resp openMeteoUVResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to decode UV index: %w", err)
+	}
+
+	readings := make([]UVReading, 0, len(resp.Hourly.Time))
+	for i, t := range resp.Hourly.Time {
+		if i < len(resp.Hourly.UVIndex) {
+			readings = append(readings, UVReading{Time: t, UVI: resp.Hourly.UVIndex[i]})
+		}
+	}
+	return readings, nil
+}
+
+// applyUV fills current, hourly and daily UV from hourly readings and keeps the
+// next 24 hours in UVHourly
+func (c *Client) applyUV(data *WeatherData, readings []UVReading) {
+	now := time.Now()
+	uvAt := func(t int64) float64 {
+		best, bestDiff := 0.0, int64(math.MaxInt64)
+		for _, r := range readings {
+			diff := r.Time - t
+			if diff < 0 {
+				diff = -diff
+			}
+			if diff < bestDiff {
+				best, bestDiff = r.UVI, diff
+			}
+		}
+		return best
+	}
+
+	data.Current.UVI = uvAt(now.Unix())
+	for i := range data.Hourly {
+		data.Hourly[i].UVI = uvAt(data.Hourly[i].Time)
+	}
+
+	maxByDay := make(map[string]float64)
+	for _, r := range readings {
+		key := time.Unix(r.Time, 0).In(c.timezone).Format("2006-01-02")
+		if r.UVI > maxByDay[key] {
+			maxByDay[key] = r.UVI
+		}
+		if r.Time >= now.Add(-time.Hour).Unix() && r.Time < now.Add(24*time.Hour).Unix() {
+			data.UVHourly = append(data.UVHourly, r)
+		}
+	}
+	for i := range data.Daily {
+		key := time.Unix(data.Daily[i].Time, 0).In(c.timezone).Format("2006-01-02")
+		data.Daily[i].UVIMax = maxByDay[key]
+	}
+}
+
+// TodayMaxUV returns today's peak UV index, or false if UV data isn't available
+func (c *Client) TodayMaxUV() (float64, bool) {
+	data := c.GetWeather()
+	if data == nil || len(data.Daily) == 0 || len(data.UVHourly) == 0 {
+		return 0, false
+	}
+	today := time.Now().In(c.timezone).Format("2006-01-02")
+	for _, d := range data.Daily {
+		if time.Unix(d.Time, 0).In(c.timezone).Format("2006-01-02") == today {
+			return d.UVIMax, true
+		}
+	}
+	return 0, false
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"log"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 
@@ -39,6 +40,7 @@ type Client struct {
 	conn       *websocket.Conn
 	send       chan []byte
 	remoteAddr string
+	kiosk      string // Name the kiosk connected with (?kiosk=), for targeted events
 }
 
 // Hub manages WebSocket connections
@@ -121,6 +123,48 @@ func (h *Hub) BroadcastDoorbell(cameraName string) {
 	log.Printf("Broadcasted doorbell event for camera: %s", cameraName)
 }
 
+// SendTo sends a message to the clients connected with the given kiosk name.
+// Returns the number of clients it was sent to.
+func (h *Hub) SendTo(kiosk string, event Event) int {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("WebSocket: Failed to marshal event: %v", err)
+		return 0
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	sent := 0
+	for client := range h.clients {
+		if client.kiosk != kiosk {
+			continue
+		}
+		select {
+		case client.send <- data:
+			sent++
+		default:
+			// Slow client; the broadcast loop drops it on its next message
+		}
+	}
+	log.Printf("WebSocket: Sent '%s' to kiosk %q (%d client(s))", event.Type, kiosk, sent)
+	return sent
+}
+
+// Kiosks returns the names of connected kiosks
+func (h *Hub) Kiosks() []string {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	seen := make(map[string]bool)
+	names := []string{}
+	for client := range h.clients {
+		if client.kiosk != "" && !seen[client.kiosk] {
+			seen[client.kiosk] = true
+			names = append(names, client.kiosk)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // ClientCount returns the number of connected clients
 func (h *Hub) ClientCount() int {
 	h.mu.RLock()
@@ -144,6 +188,7 @@ func (h *Hub) ServeWS(w http.ResponseWriter, r *http.Request) {
 		conn:       conn,
 		send:       make(chan []byte, 256),
 		remoteAddr: remoteAddr,
+		kiosk:      r.URL.Query().Get("kiosk"),
 	}
 	h.register <- client
 
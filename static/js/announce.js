@@ -0,0 +1,149 @@
+/**
+ * Announcements Module
+ * Shows server-pushed announcements (with a pre-chime, confirming each one
+ * played on this kiosk) and notifications, and handles remote page
+ * navigation (e.g. from wall buttons mapped via /api/buttons).
+ */
+const Announce = (function() {
+    let hideTimer = null;
+
+    function getBanner() {
+        let banner = document.getElementById('announcementBanner');
+        if (!banner) {
+            banner = document.createElement('div');
+            banner.id = 'announcementBanner';
+            banner.className = 'announcement-banner';
+            banner.addEventListener('click', hide);
+            document.body.appendChild(banner);
+        }
+        return banner;
+    }
+
+    function show(message, durationMs, severity) {
+        const banner = getBanner();
+        banner.textContent = message;
+        banner.classList.remove('severity-warning', 'severity-critical');
+        if (severity === 'warning' || severity === 'critical') {
+            banner.classList.add('severity-' + severity);
+        }
+        banner.classList.add('visible');
+
+        if (hideTimer) clearTimeout(hideTimer);
+        hideTimer = setTimeout(hide, durationMs || 15000);
+    }
+
+    // Pre-chime tones (Hz, start offset in seconds) synthesized so kiosks need
+    // no sound files
+    const chimes = {
+        chime: [[659, 0], [523, 0.35]],
+        ding: [[880, 0]],
+        bell: [[784, 0], [988, 0.25], [1175, 0.5]]
+    };
+    let audioCtx = null;
+
+    function playChime(name) {
+        const tones = chimes[name];
+        if (!tones) return 0;
+        try {
+            audioCtx = audioCtx || new (window.AudioContext || window.webkitAudioContext)();
+            const now = audioCtx.currentTime;
+            tones.forEach(([freq, offset]) => {
+                const osc = audioCtx.createOscillator();
+                const gain = audioCtx.createGain();
+                osc.frequency.value = freq;
+                gain.gain.setValueAtTime(0.0001, now + offset);
+                gain.gain.exponentialRampToValueAtTime(0.4, now + offset + 0.02);
+                gain.gain.exponentialRampToValueAtTime(0.0001, now + offset + 1.2);
+                osc.connect(gain).connect(audioCtx.destination);
+                osc.start(now + offset);
+                osc.stop(now + offset + 1.3);
+            });
+        } catch (err) {
+            console.error('Chime failed:', err);
+            return 0;
+        }
+        return 1500;
+    }
+
+    // Announcements from the queue carry an ID; confirm they played here
+    function announce(a) {
+        const delay = playChime(a.chime);
+        setTimeout(() => {
+            show(a.message, a.durationMs, a.priority === 'urgent' ? 'critical' : (a.priority === 'high' ? 'warning' : ''));
+            const kiosk = WS.getKioskName();
+            if (a.id && kiosk) {
+                fetch(`/api/announcements/${a.id}/played`, {
+                    method: 'POST',
+                    headers: { 'Content-Type': 'application/json' },
+                    body: JSON.stringify({ kiosk })
+                }).catch(err => console.error('Announcement confirmation failed:', err));
+            }
+        }, delay);
+    }
+
+    function hide() {
+        const banner = document.getElementById('announcementBanner');
+        if (banner) banner.classList.remove('visible');
+    }
+
+    // Emergency egress instructions take over the whole screen until cleared
+    function showEgress(status) {
+        let overlay = document.getElementById('egressOverlay');
+        if (!status.active) {
+            if (overlay) overlay.classList.remove('visible');
+            return;
+        }
+        if (!overlay) {
+            overlay = document.createElement('div');
+            overlay.id = 'egressOverlay';
+            overlay.className = 'egress-overlay';
+            overlay.innerHTML = '<div class="egress-title"></div><div class="egress-instructions"></div>';
+            document.body.appendChild(overlay);
+        }
+        overlay.classList.toggle('drill', !!status.drill);
+        overlay.querySelector('.egress-title').textContent = status.drill ? 'FIRE DRILL' : 'EMERGENCY - GET OUT';
+        overlay.querySelector('.egress-instructions').textContent = status.instructions;
+        overlay.classList.add('visible');
+    }
+
+    function init() {
+        window.addEventListener('ws:egress', function(e) {
+            showEgress(e.detail);
+        });
+        // A tablet (re)loading mid-emergency still shows the instructions
+        fetch('/api/egress')
+            .then(resp => resp.ok ? resp.json() : null)
+            .then(data => { if (data) showEgress(data.status); })
+            .catch(() => {});
+
+        window.addEventListener('ws:announcement', function(e) {
+            if (e.detail.message) {
+                announce(e.detail);
+            }
+        });
+
+        // Notification center messages; critical ones stay up longer
+        window.addEventListener('ws:notification', function(e) {
+            const n = e.detail;
+            if (!n.title) return;
+            const text = n.message ? `${n.title} — ${n.message}` : n.title;
+            show(text, n.severity === 'critical' ? 60000 : 15000, n.severity);
+        });
+
+        window.addEventListener('ws:navigate', function(e) {
+            const page = e.detail.page;
+            // Only follow same-origin paths
+            if (page && page.startsWith('/') && !page.startsWith('//') && page !== window.location.pathname) {
+                window.location.href = page;
+            }
+        });
+    }
+
+    return {
+        init,
+        show,
+        hide
+    };
+})();
+
+document.addEventListener('DOMContentLoaded', Announce.init);
@@ -12,6 +12,9 @@ const Camera = (function() {
     let isTalking = false;
     let micStream = null;
 
+    // Picture-in-picture arrival camera
+    let pipTimeout = null;
+
     // Initialize audio context on first user interaction
     function initAudioContext() {
         if (audioEnabled) return;
@@ -279,6 +282,52 @@ const Camera = (function() {
         }
     }
 
+    // Show a camera in a corner overlay for a while (arrival cameras)
+    function showCameraPip(cameraName, seconds) {
+        if (window.dismissScreensaver) {
+            window.dismissScreensaver();
+        }
+
+        let pip = document.getElementById('cameraPip');
+        if (!pip) {
+            pip = document.createElement('div');
+            pip.id = 'cameraPip';
+            pip.className = 'camera-pip';
+            pip.innerHTML = `
+                <img class="camera-pip-stream" alt="">
+                <button class="camera-pip-close" onclick="event.stopPropagation(); Camera.closeCameraPip()">&times;</button>`;
+            document.body.appendChild(pip);
+        }
+
+        const stream = pip.querySelector('.camera-pip-stream');
+        stream.src = `/api/camera/${cameraName}/stream`;
+        stream.onerror = () => {
+            stream.src = `/api/camera/${cameraName}/snapshot`;
+        };
+        pip.onclick = () => {
+            closeCameraPip();
+            showCameraModal(cameraName);
+        };
+        pip.classList.add('active');
+
+        if (pipTimeout) {
+            clearTimeout(pipTimeout);
+        }
+        pipTimeout = setTimeout(closeCameraPip, (seconds || 60) * 1000);
+    }
+
+    function closeCameraPip() {
+        const pip = document.getElementById('cameraPip');
+        if (pip) {
+            pip.classList.remove('active');
+            pip.querySelector('.camera-pip-stream').src = '';
+        }
+        if (pipTimeout) {
+            clearTimeout(pipTimeout);
+            pipTimeout = null;
+        }
+    }
+
     function init() {
         // Warm up audio on any user interaction
         document.addEventListener('click', initAudioContext, { once: true });
@@ -288,6 +337,13 @@ const Camera = (function() {
         window.addEventListener('ws:doorbell', function(e) {
             showCameraModal(e.detail.camera || 'doorbell');
         });
+
+        // Arrival cameras sent to this kiosk
+        window.addEventListener('ws:camera_pip', function(e) {
+            if (e.detail.camera) {
+                showCameraPip(e.detail.camera, e.detail.seconds);
+            }
+        });
     }
 
     // Public API
@@ -295,6 +351,8 @@ const Camera = (function() {
         init,
         showCameraModal,
         closeCameraModal,
+        showCameraPip,
+        closeCameraPip,
         playDoorbellSound,
         startTalking,
         stopTalking
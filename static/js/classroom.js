@@ -0,0 +1,100 @@
+// ===== Google Classroom Assignments Module =====
+// Shows homework due dates on the family calendar views
+
+let classroomAssignments = null;
+
+// Fetch upcoming assignments once per page load (null when Classroom isn't configured)
+async function loadClassroomAssignments() {
+    if (classroomAssignments !== null) return classroomAssignments;
+    try {
+        const resp = await fetch('/api/classroom/upcoming?days=60');
+        if (!resp.ok) {
+            classroomAssignments = [];
+            return classroomAssignments;
+        }
+        const data = await resp.json();
+        classroomAssignments = data.assignments || [];
+    } catch (err) {
+        console.error('Failed to load classroom assignments:', err);
+        classroomAssignments = [];
+    }
+    return classroomAssignments;
+}
+
+// Group assignments by local due date (YYYY-MM-DD)
+function assignmentsByDate(assignments) {
+    const byDate = {};
+    assignments.forEach(a => {
+        (byDate[a.dueDate] = byDate[a.dueDate] || []).push(a);
+    });
+    return byDate;
+}
+
+function assignmentLabel(a) {
+    return `📚 ${a.student}: ${a.title}`;
+}
+
+function assignmentTooltip(a) {
+    const due = a.allDay ? 'end of day' : new Date(a.due).toLocaleTimeString([], { hour: 'numeric', minute: '2-digit' });
+    return `${a.course} — ${a.title} (${a.student}, due ${due})${a.late ? ' · late' : ''}`;
+}
+
+function createAssignmentElement(a, className) {
+    const el = document.createElement(a.link ? 'a' : 'div');
+    el.className = className + (a.late ? ' late' : '');
+    el.textContent = assignmentLabel(a);
+    el.title = assignmentTooltip(a);
+    if (a.link) {
+        el.href = a.link;
+        el.target = '_blank';
+        el.rel = 'noopener';
+    }
+    return el;
+}
+
+// Render assignments on whichever calendar view is showing
+async function renderClassroom() {
+    if (!document.querySelector('.calendar-container')) return;
+
+    const assignments = await loadClassroomAssignments();
+    document.querySelectorAll('.assignment-badge, .assignment-event').forEach(el => el.remove());
+    if (assignments.length === 0) return;
+
+    const byDate = assignmentsByDate(assignments);
+
+    // Month view: compact badge under the day number
+    document.querySelectorAll('.month-day').forEach(dayCell => {
+        const items = byDate[dayCell.dataset.date];
+        const dayNumber = dayCell.querySelector('.day-number');
+        if (!items || !dayNumber) return;
+        const badge = document.createElement('span');
+        badge.className = 'assignment-badge';
+        badge.textContent = items.length === 1 ? `📚 ${items[0].student}` : `📚 ${items.length} due`;
+        badge.title = items.map(assignmentTooltip).join('\n');
+        dayNumber.appendChild(badge);
+    });
+
+    // Week view: one entry per assignment at the top of the day
+    document.querySelectorAll('.week-day').forEach(dayColumn => {
+        const items = byDate[dayColumn.dataset.date];
+        const eventsContainer = dayColumn.querySelector('.week-day-events');
+        if (!items || !eventsContainer) return;
+        items.slice().reverse().forEach(a => {
+            eventsContainer.insertBefore(createAssignmentElement(a, 'assignment-event'), eventsContainer.firstChild);
+        });
+    });
+
+    // Day view: with the all-day events
+    const allDayEvents = document.querySelector('.all-day-events');
+    if (allDayEvents) {
+        const dateParam = new URLSearchParams(window.location.search).get('date');
+        const items = byDate[dateParam || formatDateKey(new Date())];
+        if (items) {
+            items.forEach(a => allDayEvents.appendChild(createAssignmentElement(a, 'assignment-event')));
+        }
+    }
+}
+
+document.addEventListener('DOMContentLoaded', () => {
+    setTimeout(renderClassroom, 100);
+});
@@ -0,0 +1,66 @@
+/**
+ * Client Log Module
+ * Reports the current page and uncaught JS errors to the server so the
+ * remote support view (/support) can show what a kiosk is doing.
+ */
+const ClientLog = (function() {
+    const HEARTBEAT_MS = 60000;
+    const FLUSH_DELAY_MS = 2000;
+    const MAX_QUEUED = 20;
+
+    let queue = [];
+    let flushTimer = null;
+
+    function record(message, source, line, column, stack) {
+        if (queue.length >= MAX_QUEUED) queue.shift();
+        queue.push({
+            time: new Date().toISOString(),
+            message: String(message || 'Unknown error'),
+            source: source || '',
+            line: line || 0,
+            column: column || 0,
+            stack: stack || ''
+        });
+
+        if (!flushTimer) {
+            flushTimer = setTimeout(flush, FLUSH_DELAY_MS);
+        }
+    }
+
+    async function flush() {
+        flushTimer = null;
+        const errors = queue;
+        queue = [];
+
+        try {
+            await fetch('/api/tablet/clientlog', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({
+                    page: window.location.pathname + window.location.search,
+                    viewport: window.innerWidth + 'x' + window.innerHeight,
+                    errors
+                })
+            });
+        } catch (err) {
+            // Keep errors for the next attempt
+            queue = errors.concat(queue).slice(-MAX_QUEUED);
+        }
+    }
+
+    window.addEventListener('error', (e) => {
+        record(e.message, e.filename, e.lineno, e.colno, e.error && e.error.stack);
+    });
+
+    window.addEventListener('unhandledrejection', (e) => {
+        const reason = e.reason || {};
+        record('Unhandled rejection: ' + (reason.message || reason), '', 0, 0, reason.stack);
+    });
+
+    document.addEventListener('DOMContentLoaded', () => {
+        flush();
+        setInterval(flush, HEARTBEAT_MS);
+    });
+
+    return { record, flush };
+})();
@@ -33,6 +33,15 @@ const Entities = (function() {
         // Update summaries on load
         updateGroupSummaries();
 
+        // One-tap Home Assistant scenes
+        loadScenes();
+
+        // "12 days until vacation"
+        loadCountdowns();
+        window.addEventListener('ws:countdowns_changed', function(e) {
+            renderCountdowns(e.detail);
+        });
+
         // Start refresh interval
         setInterval(refreshEntityStates, 30000);
     }
@@ -66,6 +75,13 @@ const Entities = (function() {
                 } else if (camCount > 0) {
                     summaryEl.textContent = `${camCount} camera${camCount !== 1 ? 's' : ''}`;
                 }
+            } else if (groupName === 'Media') {
+                const playing = group.cards.filter(c => c.media && c.media.playing);
+                if (playing.length > 0) {
+                    summaryEl.textContent = playing[0].media.title || 'Playing';
+                } else {
+                    summaryEl.textContent = onCount > 0 ? onCount + ' on' : 'All off';
+                }
             } else if (groupName === 'Climate') {
                 // Find thermostat or show temp
                 const climate = group.cards.find(c => c.type === 'climate');
@@ -168,12 +184,27 @@ const Entities = (function() {
                 return renderClimateCard(card);
             }
 
+            // Covers with position support get sliders
+            if (card.type === 'cover' && card.cover && (card.cover.supportsPosition || card.cover.supportsTilt)) {
+                return renderCoverCard(card);
+            }
+
+            // Media players get now-playing and transport controls
+            if (card.type === 'media_player') {
+                return renderMediaCard(card);
+            }
+
+            // Vacuums get battery/status and cleaning controls
+            if (card.type === 'vacuum' && card.vacuum) {
+                return renderVacuumCard(card);
+            }
+
             // Light groups get expandable rendering with member controls
             if (card.type === 'light' && card.isLightGroup && card.members && card.members.length > 0) {
                 return renderLightGroupCard(card);
             }
 
-            const isToggleable = ['light', 'switch', 'fan', 'lock'].includes(card.type);
+            const isToggleable = ['light', 'switch', 'fan', 'lock', 'cover'].includes(card.type);
             const toggleAttr = isToggleable ? `onclick="toggleEntity('${card.entityId}')"` : '';
             const cursorClass = isToggleable ? 'entity-toggleable' : '';
             const onClass = card.isOn ? 'entity-on' : '';
@@ -280,6 +311,266 @@ const Entities = (function() {
         `;
     }
 
+    function renderMediaCard(card) {
+        const media = card.media || {};
+        const nowPlaying = [media.title, media.artist].filter(Boolean).join(' · ') || media.app || card.state;
+        const volumePct = Math.round((media.volume || 0) * 100);
+
+        let sourceHtml = '';
+        if (media.sourceList && media.sourceList.length > 0) {
+            sourceHtml = `
+                <select class="media-source-select" onchange="mediaCommand('${card.entityId}', 'source', { source: this.value })" onclick="event.stopPropagation()">
+                    ${media.sourceList.map(src => `
+                        <option value="${escapeHtml(src)}" ${src === media.source ? 'selected' : ''}>${escapeHtml(src)}</option>
+                    `).join('')}
+                </select>`;
+        }
+
+        return `
+            <div class="media-card ${card.isOn ? 'entity-on' : ''}" data-entity="${card.entityId}">
+                <div class="entity-row">
+                    <div class="entity-icon">${card.icon}</div>
+                    <div class="entity-info">
+                        <div class="entity-name">${escapeHtml(card.name)}</div>
+                        <div class="entity-state media-now-playing">${escapeHtml(nowPlaying)}</div>
+                    </div>
+                    <div class="entity-toggle" onclick="mediaCommand('${card.entityId}', '${card.isOn ? 'turn_off' : 'turn_on'}')">
+                        <div class="toggle-switch ${card.isOn ? 'on' : ''}">
+                            <div class="toggle-slider"></div>
+                        </div>
+                    </div>
+                </div>
+                ${card.isOn ? `
+                <div class="media-controls">
+                    <button class="media-btn" onclick="mediaCommand('${card.entityId}', 'previous')">⏮</button>
+                    <button class="media-btn" onclick="mediaCommand('${card.entityId}', 'play_pause')">${media.playing ? '⏸' : '▶'}</button>
+                    <button class="media-btn" onclick="mediaCommand('${card.entityId}', 'next')">⏭</button>
+                    <button class="media-btn" onclick="mediaCommand('${card.entityId}', 'mute', { muted: ${!media.muted} })">${media.muted ? '🔇' : '🔊'}</button>
+                    <input type="range" class="media-volume" min="0" max="100" value="${volumePct}"
+                           onchange="mediaCommand('${card.entityId}', 'volume', { volume: this.value / 100 })">
+                    ${sourceHtml}
+                </div>
+                ` : ''}
+            </div>
+        `;
+    }
+
+    function renderCoverCard(card) {
+        const cover = card.cover;
+        const position = cover.position !== undefined ? cover.position : (card.isOn ? 100 : 0);
+        const tilt = cover.tilt !== undefined ? cover.tilt : 0;
+
+        return `
+            <div class="cover-card ${card.isOn ? 'entity-on' : ''}" data-entity="${card.entityId}">
+                <div class="entity-row entity-toggleable" onclick="toggleEntity('${card.entityId}')">
+                    <div class="entity-icon">${card.icon}</div>
+                    <div class="entity-info">
+                        <div class="entity-name">${escapeHtml(card.name)}</div>
+                        <div class="entity-state">${card.state}${cover.position !== undefined ? ' · ' + cover.position + '%' : ''}</div>
+                    </div>
+                </div>
+                ${cover.supportsPosition ? `
+                <div class="cover-slider-row">
+                    <span class="cover-slider-label">Position</span>
+                    <input type="range" class="cover-slider" min="0" max="100" step="5" value="${position}"
+                           onchange="setCoverPosition('${card.entityId}', { position: parseInt(this.value, 10) })">
+                </div>` : ''}
+                ${cover.supportsTilt ? `
+                <div class="cover-slider-row">
+                    <span class="cover-slider-label">Tilt</span>
+                    <input type="range" class="cover-slider" min="0" max="100" step="5" value="${tilt}"
+                           onchange="setCoverPosition('${card.entityId}', { tilt: parseInt(this.value, 10) })">
+                </div>` : ''}
+            </div>
+        `;
+    }
+
+    function renderVacuumCard(card) {
+        const vacuum = card.vacuum;
+        const battery = vacuum.battery !== undefined ? `${vacuum.charging ? '⚡' : '🔋'} ${vacuum.battery}%` : '';
+        const status = [vacuum.status, battery].filter(Boolean).join(' · ');
+
+        let fanHtml = '';
+        if (vacuum.fanSpeedList && vacuum.fanSpeedList.length > 0) {
+            fanHtml = `
+                <select class="vacuum-fan-select" onchange="vacuumCommand('${card.entityId}', 'fan_speed', this.value)">
+                    ${vacuum.fanSpeedList.map(speed => `
+                        <option value="${escapeHtml(speed)}" ${speed === vacuum.fanSpeed ? 'selected' : ''}>${escapeHtml(speed)}</option>
+                    `).join('')}
+                </select>`;
+        }
+
+        return `
+            <div class="vacuum-card ${card.isOn ? 'entity-on' : ''}" data-entity="${card.entityId}">
+                <div class="entity-row">
+                    <div class="entity-icon">${card.icon}</div>
+                    <div class="entity-info">
+                        <div class="entity-name">${escapeHtml(card.name)}</div>
+                        <div class="entity-state">${escapeHtml(status)}</div>
+                    </div>
+                </div>
+                <div class="vacuum-controls">
+                    ${vacuum.cleaning
+                        ? `<button class="vacuum-btn" onclick="vacuumCommand('${card.entityId}', 'pause')">⏸ Pause</button>`
+                        : `<button class="vacuum-btn" onclick="vacuumCommand('${card.entityId}', 'start')">▶ Start</button>`}
+                    <button class="vacuum-btn" onclick="vacuumCommand('${card.entityId}', 'return_to_base')">🏠 Dock</button>
+                    <button class="vacuum-btn" onclick="vacuumCommand('${card.entityId}', 'locate')">📍 Locate</button>
+                    <button class="vacuum-btn" onclick="toggleVacuumMap('${card.entityId}')">🗺 Rooms</button>
+                    ${fanHtml}
+                </div>
+                <div class="vacuum-map" data-vacuum-map="${card.entityId}" style="display: none;"></div>
+            </div>
+        `;
+    }
+
+    // Send a vacuum command (fanSpeed only for 'fan_speed') and re-render with the returned state
+    async function vacuumCommand(entityID, command, fanSpeed) {
+        try {
+            const resp = await fetch(`/api/vacuum/${entityID}/command`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ command, fanSpeed })
+            });
+            if (!resp.ok) {
+                console.error('Vacuum command failed:', await resp.text());
+                return;
+            }
+            replaceCard(entityID, await resp.json());
+        } catch (err) {
+            console.error('Error sending vacuum command:', err);
+        }
+    }
+
+    // Show or hide a vacuum's map with its tappable cleaning regions
+    async function toggleVacuumMap(entityID) {
+        const panel = document.querySelector(`[data-vacuum-map="${entityID}"]`);
+        if (!panel) return;
+        if (panel.style.display !== 'none') {
+            panel.style.display = 'none';
+            return;
+        }
+        panel.style.display = 'block';
+        panel.innerHTML = '<div class="vacuum-map-empty">Loading map...</div>';
+
+        try {
+            const resp = await fetch(`/api/vacuum/${entityID}/map`);
+            if (!resp.ok) throw new Error(await resp.text());
+            panel.innerHTML = renderVacuumMap(await resp.json());
+        } catch (err) {
+            console.error('Error loading vacuum map:', err);
+            panel.innerHTML = '<div class="vacuum-map-empty">Map unavailable</div>';
+        }
+    }
+
+    // Regions with an area are drawn over the map image; all regions and any
+    // rooms the vacuum reports are also listed as buttons
+    function renderVacuumMap(map) {
+        const entityID = map.entityId;
+        const regions = map.regions || [];
+        const segments = map.segments || [];
+
+        let imageHtml = '';
+        if (map.image) {
+            const areas = regions.filter(r => r.area).map(r => `
+                <button class="vacuum-map-area"
+                        style="left:${r.area.x}%;top:${r.area.y}%;width:${r.area.w}%;height:${r.area.h}%"
+                        onclick="cleanVacuumRegion('${entityID}', { region: '${r.id}' }, this)">
+                    ${escapeHtml(r.name)}
+                </button>
+            `).join('');
+            imageHtml = `
+                <div class="vacuum-map-image">
+                    <img src="${map.image}?t=${Date.now()}" alt="Vacuum map">
+                    ${areas}
+                </div>`;
+        }
+
+        const chips = regions.map(r => `
+            <button class="vacuum-btn" onclick="cleanVacuumRegion('${entityID}', { region: '${r.id}' }, this)">${escapeHtml(r.name)}</button>
+        `).concat(segments.map(s => `
+            <button class="vacuum-btn" onclick="cleanVacuumRegion('${entityID}', { segments: ['${escapeHtml(s.id)}'] }, this)">${escapeHtml(s.name)}</button>
+        `)).join('');
+
+        if (!imageHtml && !chips) {
+            return '<div class="vacuum-map-empty">No rooms set up for this vacuum</div>';
+        }
+        return `${imageHtml}<div class="vacuum-controls">${chips}</div>`;
+    }
+
+    // Clean a saved region or ad-hoc segments; the button shows the outcome briefly
+    async function cleanVacuumRegion(entityID, body, button) {
+        try {
+            const resp = await fetch(`/api/vacuum/${entityID}/clean`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(body)
+            });
+            if (!resp.ok) {
+                console.error('Vacuum clean failed:', await resp.text());
+                return;
+            }
+            if (button) {
+                button.classList.add('vacuum-btn-started');
+                setTimeout(() => button.classList.remove('vacuum-btn-started'), 2000);
+            }
+        } catch (err) {
+            console.error('Error starting vacuum clean:', err);
+        }
+    }
+
+    // Set cover position and/or tilt (0 = closed, 100 = open)
+    async function setCoverPosition(entityID, body) {
+        try {
+            const resp = await fetch(`/api/cover/${entityID}/position`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(body)
+            });
+            if (!resp.ok) {
+                console.error('Failed to set cover position:', await resp.text());
+                return;
+            }
+            replaceCard(entityID, await resp.json());
+        } catch (err) {
+            console.error('Error setting cover position:', err);
+        }
+    }
+
+    // Replace a card's data with an updated card from the server and re-render the open group
+    function replaceCard(entityID, updated) {
+        Object.keys(groupsData).forEach(groupName => {
+            const group = groupsData[groupName];
+            const idx = group.cards.findIndex(c => c.entityId === entityID);
+            if (idx < 0) return;
+            group.cards[idx] = { ...group.cards[idx], ...updated };
+
+            const modal = document.getElementById('groupModal');
+            if (modal.classList.contains('active') &&
+                document.getElementById('groupModalTitle').textContent === group.name) {
+                document.getElementById('groupModalContent').innerHTML = renderGroupContent(group);
+            }
+        });
+        updateGroupSummaries();
+    }
+
+    // Send a media_player command and re-render with the returned state
+    async function mediaCommand(entityID, command, body) {
+        try {
+            const resp = await fetch(`/api/media/${entityID}/${command}`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(body || {})
+            });
+            if (!resp.ok) {
+                console.error('Media command failed:', await resp.text());
+                return;
+            }
+            replaceCard(entityID, await resp.json());
+        } catch (err) {
+            console.error('Error sending media command:', err);
+        }
+    }
+
     function toggleLightGroupExpand(entityId) {
         const membersId = 'members-' + entityId.replace('.', '-');
         const members = document.getElementById(membersId);
@@ -398,7 +689,38 @@ const Entities = (function() {
         `;
     }
 
-    async function toggleEntity(entityID) {
+    // PIN pad state for PIN-protected locks
+    let lockPin = '';
+    let lockPinResolve = null;
+
+    // Show the PIN pad and resolve with the entered PIN, or null if cancelled
+    function promptLockPin(error) {
+        lockPin = '';
+        document.getElementById('lockPinDisplay').textContent = '';
+        document.getElementById('lockPinError').textContent = error || '';
+        document.getElementById('lockPinModal').classList.add('active');
+        return new Promise(resolve => { lockPinResolve = resolve; });
+    }
+
+    function pressLockPinKey(key) {
+        if (key === 'clear') {
+            lockPin = lockPin.slice(0, -1);
+        } else if (lockPin.length < 12) {
+            lockPin += key;
+        }
+        document.getElementById('lockPinDisplay').textContent = '•'.repeat(lockPin.length);
+    }
+
+    function submitLockPin(ok) {
+        document.getElementById('lockPinModal').classList.remove('active');
+        if (lockPinResolve) {
+            lockPinResolve(ok && lockPin ? lockPin : null);
+            lockPinResolve = null;
+        }
+        lockPin = '';
+    }
+
+    async function toggleEntity(entityID, pin) {
         const entityRow = document.querySelector(`.entity-row[data-entity="${entityID}"]`);
 
         if (entityRow) {
@@ -406,7 +728,19 @@ const Entities = (function() {
         }
 
         try {
-            const resp = await fetch(`/api/toggle/${entityID}`, { method: 'POST' });
+            const headers = pin ? { 'X-Lock-PIN': pin } : {};
+            const resp = await fetch(`/api/toggle/${entityID}`, { method: 'POST', headers });
+
+            // PIN-protected locks: ask for the PIN and retry
+            if ([403, 428, 429].includes(resp.status)) {
+                const message = resp.status === 428 ? '' : (await resp.text()).trim();
+                const entered = await promptLockPin(message);
+                if (entered) {
+                    await toggleEntity(entityID, entered);
+                }
+                return;
+            }
+
             if (resp.ok) {
                 const data = await resp.json();
 
@@ -630,6 +964,73 @@ const Entities = (function() {
     }
 
     // Refresh entity states every 30 seconds via AJAX (no page reload)
+    // Load Home Assistant scenes into the quick scene strip
+    async function loadScenes() {
+        const container = document.getElementById('haScenes');
+        if (!container) return;
+
+        try {
+            const resp = await fetch('/api/ha/scenes');
+            if (!resp.ok) return;
+            const scenes = await resp.json();
+            if (!scenes || scenes.length === 0) return;
+
+            container.innerHTML = scenes.map(scene => `
+                <button class="ha-scene-btn" data-scene="${escapeHtml(scene.entityId)}"
+                        onclick="activateScene('${escapeHtml(scene.entityId)}')">
+                    ${escapeHtml(scene.name)}
+                </button>`).join('');
+            container.style.display = '';
+        } catch (err) {
+            console.error('Failed to load scenes:', err);
+        }
+    }
+
+    // Load upcoming countdowns
+    async function loadCountdowns() {
+        try {
+            const resp = await fetch('/api/countdowns');
+            if (!resp.ok) return;
+            renderCountdowns(await resp.json());
+        } catch (err) {
+            console.error('Failed to load countdowns:', err);
+        }
+    }
+
+    function renderCountdowns(countdowns) {
+        const container = document.getElementById('countdowns');
+        if (!container) return;
+        if (!countdowns || countdowns.length === 0) {
+            container.style.display = 'none';
+            return;
+        }
+
+        container.innerHTML = countdowns.slice(0, 4).map(c => `
+            <div class="countdown-chip${c.daysLeft === 0 ? ' today' : ''}">
+                ${c.icon ? `<span class="countdown-icon">${escapeHtml(c.icon)}</span>` : ''}
+                <span class="countdown-days">${c.daysLeft === 0 ? 'Today' : c.daysLeft}</span>
+                <span class="countdown-label">${c.daysLeft === 0 ? escapeHtml(c.name) : (c.daysLeft === 1 ? 'day until ' : 'days until ') + escapeHtml(c.name)}</span>
+            </div>`).join('');
+        container.style.display = '';
+    }
+
+    // Activate a Home Assistant scene
+    async function activateScene(entityId) {
+        const btn = document.querySelector(`.ha-scene-btn[data-scene="${entityId}"]`);
+        if (btn) btn.classList.add('activating');
+
+        try {
+            const resp = await fetch(`/api/ha/scene/${encodeURIComponent(entityId)}/activate`, { method: 'POST' });
+            if (!resp.ok) {
+                console.error('Failed to activate scene:', await resp.text());
+            }
+        } catch (err) {
+            console.error('Error activating scene:', err);
+        } finally {
+            if (btn) setTimeout(() => btn.classList.remove('activating'), 600);
+        }
+    }
+
     async function refreshEntityStates() {
         try {
             const resp = await fetch('/api/entities');
@@ -707,7 +1108,15 @@ const Entities = (function() {
         openNotifications,
         updateNotificationBadge,
         refreshEntityStates,
-        updateGroupSummaries
+        updateGroupSummaries,
+        activateScene,
+        mediaCommand,
+        setCoverPosition,
+        vacuumCommand,
+        toggleVacuumMap,
+        cleanVacuumRegion,
+        pressLockPinKey,
+        submitLockPin
     };
 })();
 
@@ -731,3 +1140,11 @@ function setClimateMode(entityID, mode) { Entities.setClimateMode(entityID, mode
 function setClimateFanMode(entityID, fanMode) { Entities.setClimateFanMode(entityID, fanMode); }
 function openNotifications() { Entities.openNotifications(); }
 function updateNotificationBadge(count) { Entities.updateNotificationBadge(count); }
+function activateScene(entityId) { Entities.activateScene(entityId); }
+function mediaCommand(entityID, command, body) { Entities.mediaCommand(entityID, command, body); }
+function setCoverPosition(entityID, body) { Entities.setCoverPosition(entityID, body); }
+function vacuumCommand(entityID, command, fanSpeed) { Entities.vacuumCommand(entityID, command, fanSpeed); }
+function toggleVacuumMap(entityID) { Entities.toggleVacuumMap(entityID); }
+function cleanVacuumRegion(entityID, body, button) { Entities.cleanVacuumRegion(entityID, body, button); }
+function pressLockPinKey(key) { Entities.pressLockPinKey(key); }
+function submitLockPin(ok) { Entities.submitLockPin(ok); }
@@ -29,20 +29,24 @@ const Hue = (function() {
                 updateSummary(null);
                 return;
             }
-            hueRooms = await resp.json();
-            updateSummary(hueRooms);
-
-            // Only re-render on entertainment tab (for streaming status updates)
-            const modal = document.getElementById('hueModal');
-            if (modal && modal.classList.contains('active') && activeHueTab === 'entertainment') {
-                renderContent();
-            }
+            applyRooms(await resp.json());
         } catch (err) {
             console.error('Failed to load Hue rooms:', err);
             updateSummary(null);
         }
     }
 
+    function applyRooms(rooms) {
+        hueRooms = rooms;
+        updateSummary(hueRooms);
+
+        // Only re-render on entertainment tab (for streaming status updates)
+        const modal = document.getElementById('hueModal');
+        if (modal && modal.classList.contains('active') && activeHueTab === 'entertainment') {
+            renderContent();
+        }
+    }
+
     function updateSummary(rooms) {
         const summaryEl = document.getElementById('summary-Hue');
         if (!summaryEl) return;
@@ -239,6 +243,21 @@ const Hue = (function() {
         }
     }
 
+    async function setSyncBoxIntensity(index, intensity) {
+        try {
+            const resp = await fetch(`/api/syncbox/${index}/intensity`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ intensity })
+            });
+            if (resp.ok) {
+                await loadSyncBoxStatus(index);
+            }
+        } catch (err) {
+            console.error('Failed to set intensity:', err);
+        }
+    }
+
     async function setSyncBoxInput(index, hdmiSource) {
         try {
             const resp = await fetch(`/api/syncbox/${index}/input`, {
@@ -322,6 +341,7 @@ const Hue = (function() {
         const isSyncing = exec.syncActive;
         const currentMode = exec.mode || 'video';
         const currentInput = exec.hdmiSource || 'input1';
+        const currentIntensity = exec.intensity || '';
         const hdmi = status.hdmi || {};
 
         const getHdmiName = (inputKey, defaultNum) => {
@@ -411,6 +431,18 @@ const Hue = (function() {
                                     `).join('')}
                                 </div>
                             </div>
+
+                            <div class="hue-syncbox-grid-section">
+                                <label>Intensity</label>
+                                <div class="hue-syncbox-grid-4col">
+                                    ${[['subtle', 'Subtle'], ['moderate', 'Moderate'], ['high', 'High'], ['intense', 'Extreme']].map(([value, label]) => `
+                                        <button class="hue-syncbox-tile ${value === currentIntensity ? 'selected' : ''}"
+                                                onclick="Hue.setSyncBoxIntensity(${selectedSyncBox}, '${value}')">
+                                            <span class="tile-name">${label}</span>
+                                        </button>
+                                    `).join('')}
+                                </div>
+                            </div>
                         </div>
                     </div>
                 </div>
@@ -508,6 +540,19 @@ const Hue = (function() {
                                 <span class="hue-brightness-value">${Math.round(getAverageRoomBrightness(room) / 254 * 100)}%</span>
                             </div>
                         </div>
+
+                        ${room.ctMax ? `
+                        <div class="hue-room-brightness-section hue-room-warmth-section ${allLightsSyncing ? 'disabled' : ''}">
+                            <label>Warmth</label>
+                            <div class="hue-brightness-control hue-warmth-control">
+                                <input type="range" min="${room.ctMin}" max="${room.ctMax}" value="${getRoomWarmth(room)}"
+                                       ${allLightsSyncing ? 'disabled' : ''}
+                                       oninput="this.nextElementSibling.textContent = Hue.miredsToKelvin(this.value) + 'K'"
+                                       onchange="Hue.setRoomWarmth('${room.id}', this.value)">
+                                <span class="hue-brightness-value">${miredsToKelvin(getRoomWarmth(room))}K</span>
+                            </div>
+                        </div>
+                        ` : ''}
                     </div>
 
                     <div class="hue-room-right-column">
@@ -659,6 +704,16 @@ const Hue = (function() {
         return Math.round(sum / onLights.length);
     }
 
+    // Current room color temperature, defaulting to the middle of its range
+    function getRoomWarmth(room) {
+        if (room.ct) return Math.min(Math.max(room.ct, room.ctMin), room.ctMax);
+        return Math.round((room.ctMin + room.ctMax) / 2);
+    }
+
+    function miredsToKelvin(mireds) {
+        return Math.round(1000000 / mireds / 100) * 100;
+    }
+
     function toggleRoomExpand(roomId) {
         const roomCard = document.querySelector(`.hue-room-card[data-room-id="${roomId}"]`);
         if (roomCard) {
@@ -701,6 +756,22 @@ const Hue = (function() {
         }
     }
 
+    async function setRoomWarmth(roomId, ct) {
+        try {
+            const resp = await fetch(`/api/hue/group/${roomId}/ct`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ ct: parseInt(ct) })
+            });
+            if (resp.ok) {
+                hueRooms = await resp.json();
+                updateSummary(hueRooms);
+            }
+        } catch (err) {
+            console.error('Failed to set warmth:', err);
+        }
+    }
+
     // ===== Light Control =====
     async function toggleLight(lightId) {
         const lightRow = document.querySelector(`.hue-light-row[data-light-id="${lightId}"]`);
@@ -829,18 +900,20 @@ const Hue = (function() {
         loadRooms();
         loadSyncBoxes();
 
-        // Refresh Hue state every second
-        setInterval(loadRooms, 1000);
+        // Room state is pushed from the bridge event stream; poll as a fallback
+        window.addEventListener('ws:hue_rooms', function(e) {
+            applyRooms(e.detail);
+        });
+        setInterval(loadRooms, 5000);
 
-        // Refresh sync box status when entertainment tab is active
-        setInterval(() => {
+        // Sync box changes are pushed by the server's pollers
+        window.addEventListener('ws:syncbox', function(e) {
+            syncBoxStatuses[e.detail.index] = e.detail.status;
             const modal = document.getElementById('hueModal');
             if (modal && modal.classList.contains('active') && activeHueTab === 'entertainment') {
-                for (const box of syncBoxes) {
-                    loadSyncBoxStatus(box.index);
-                }
+                renderContent();
             }
-        }, 3000);
+        });
     }
 
     // Public API
@@ -853,6 +926,8 @@ const Hue = (function() {
         selectEntertainmentArea: selectEntertainmentArea,
         toggleRoom: toggleRoom,
         setRoomBrightness: setRoomBrightness,
+        setRoomWarmth: setRoomWarmth,
+        miredsToKelvin: miredsToKelvin,
         toggleRoomExpand: toggleRoomExpand,
         toggleLight: toggleLight,
         openLightBrightnessPopup: openLightBrightnessPopup,
@@ -868,6 +943,7 @@ const Hue = (function() {
         setSyncBoxArea: setSyncBoxArea,
         setSyncBoxMode: setSyncBoxMode,
         setSyncBoxInput: setSyncBoxInput,
+        setSyncBoxIntensity: setSyncBoxIntensity,
         openSyncModeModal: openSyncModeModal,
         closeSyncModeModal: closeSyncModeModal,
         openHdmiInputModal: openHdmiInputModal,
@@ -0,0 +1,117 @@
+/**
+ * House Manual Module
+ * While guest mode is on, shows a button that opens the house manual
+ * (Wi-Fi, emergency contacts, appliance quirks) for babysitters and guests.
+ * Sections are edited through /api/manual.
+ */
+const HouseManual = (function() {
+    let manual = null;
+    let expireTimer = null;
+
+    function getButton() {
+        let button = document.getElementById('manualButton');
+        if (!button) {
+            button = document.createElement('button');
+            button.id = 'manualButton';
+            button.className = 'manual-button';
+            button.textContent = 'House manual';
+            button.addEventListener('click', open);
+            document.body.appendChild(button);
+        }
+        return button;
+    }
+
+    function getPanel() {
+        let panel = document.getElementById('manualPanel');
+        if (!panel) {
+            panel = document.createElement('div');
+            panel.id = 'manualPanel';
+            panel.className = 'manual-panel';
+            panel.innerHTML = '<div class="manual-content"><div class="manual-header">' +
+                '<span>House manual</span><button type="button" class="modal-close-btn">&times;</button>' +
+                '</div><div class="manual-sections"></div></div>';
+            panel.querySelector('.modal-close-btn').addEventListener('click', close);
+            document.body.appendChild(panel);
+        }
+        return panel;
+    }
+
+    function renderSections() {
+        const list = getPanel().querySelector('.manual-sections');
+        list.innerHTML = '';
+        if (!manual.sections.length) {
+            list.textContent = 'No instructions yet.';
+            return;
+        }
+        manual.sections.forEach(section => {
+            const el = document.createElement('section');
+            const title = document.createElement('h3');
+            title.textContent = section.title;
+            const body = document.createElement('div');
+            body.className = 'manual-body';
+            body.textContent = section.body;
+            el.appendChild(title);
+            el.appendChild(body);
+            list.appendChild(el);
+        });
+    }
+
+    function open() {
+        if (!manual) return;
+        renderSections();
+        getPanel().classList.add('visible');
+    }
+
+    function close() {
+        const panel = document.getElementById('manualPanel');
+        if (panel) panel.classList.remove('visible');
+    }
+
+    function apply(data) {
+        const wasActive = manual && manual.guestMode.active;
+        manual = data;
+        const active = manual.guestMode.active;
+        getButton().classList.toggle('visible', active);
+
+        if (expireTimer) {
+            clearTimeout(expireTimer);
+            expireTimer = null;
+        }
+        if (!active) {
+            close();
+            return;
+        }
+        if (manual.guestMode.until) {
+            const ms = new Date(manual.guestMode.until) - Date.now();
+            expireTimer = setTimeout(load, Math.max(ms, 0) + 1000);
+        }
+        // Surface the manual when guest mode starts; otherwise just refresh it
+        if (!wasActive) {
+            open();
+        } else if (getPanel().classList.contains('visible')) {
+            renderSections();
+        }
+    }
+
+    function load() {
+        fetch('/api/manual')
+            .then(resp => resp.ok ? resp.json() : null)
+            .then(data => { if (data) apply(data); })
+            .catch(() => {});
+    }
+
+    function init() {
+        window.addEventListener('ws:manual_changed', function(e) {
+            apply(e.detail);
+        });
+        load();
+    }
+
+    return {
+        init,
+        open,
+        close
+    };
+})();
+
+document.addEventListener('DOMContentLoaded', HouseManual.init);
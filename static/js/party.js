@@ -0,0 +1,166 @@
+/**
+ * Party Mode Module
+ * Starts and ends a party from Settings. While it runs, guests scan the QR code
+ * to upload photos into the screensaver slideshow; with moderation on, photos
+ * wait here for approval. The album is deleted when the party ends.
+ */
+const PartyMode = (function() {
+    function getPanel() {
+        let panel = document.getElementById('partyPanel');
+        if (!panel) {
+            panel = document.createElement('div');
+            panel.id = 'partyPanel';
+            panel.className = 'party-panel';
+            panel.innerHTML = '<div class="party-content"><div class="party-header">' +
+                '<span>Party Mode</span><button type="button" class="modal-close-btn">&times;</button>' +
+                '</div><div class="party-body"></div></div>';
+            panel.querySelector('.modal-close-btn').addEventListener('click', close);
+            document.body.appendChild(panel);
+        }
+        return panel;
+    }
+
+    function getBody() {
+        return getPanel().querySelector('.party-body');
+    }
+
+    function isOpen() {
+        const panel = document.getElementById('partyPanel');
+        return panel && panel.classList.contains('visible');
+    }
+
+    async function load() {
+        const resp = await fetch('/api/party?pending=true');
+        if (resp.status === 404) {
+            renderStart();
+            return;
+        }
+        if (!resp.ok) {
+            getBody().textContent = (await resp.text()).trim();
+            return;
+        }
+        renderParty(await resp.json());
+    }
+
+    function renderStart(error) {
+        const body = getBody();
+        body.innerHTML = '';
+        const form = document.createElement('form');
+        form.className = 'party-start';
+        form.innerHTML = '<input type="text" name="name" placeholder="Party name" maxlength="60">' +
+            '<label>Hours <input type="number" name="hours" min="1" max="48" value="6"></label>' +
+            '<label><input type="checkbox" name="moderated"> Approve photos before they\'re shown</label>' +
+            '<button type="submit">Start party</button><div class="party-error"></div>';
+        form.querySelector('.party-error').textContent = error || '';
+        form.addEventListener('submit', e => {
+            e.preventDefault();
+            start(form);
+        });
+        body.appendChild(form);
+    }
+
+    async function start(form) {
+        const resp = await fetch('/api/party', {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify({
+                name: form.elements.name.value,
+                hours: parseInt(form.elements.hours.value, 10) || 0,
+                moderated: form.elements.moderated.checked
+            })
+        });
+        if (!resp.ok) {
+            renderStart((await resp.text()).trim());
+            return;
+        }
+        renderParty(await resp.json());
+    }
+
+    function renderParty(party) {
+        const body = getBody();
+        body.innerHTML = '';
+
+        const info = document.createElement('div');
+        info.className = 'party-info';
+        info.innerHTML = '<img class="party-qr" alt=""><div><div class="party-name"></div>' +
+            '<div class="party-summary"></div><button type="button" class="party-end">End party</button></div>';
+        info.querySelector('.party-qr').src = '/api/party/qr.svg?t=' + encodeURIComponent(party.started);
+        info.querySelector('.party-name').textContent = party.name;
+        const ends = new Date(party.ends).toLocaleTimeString([], { hour: 'numeric', minute: '2-digit' });
+        const shown = party.photos.length - party.pending;
+        info.querySelector('.party-summary').textContent =
+            `${shown} photo${shown === 1 ? '' : 's'} shown · ends ${ends} · photos are deleted afterwards`;
+        info.querySelector('.party-end').addEventListener('click', end);
+        body.appendChild(info);
+
+        const pending = party.photos.filter(p => !p.approved);
+        if (!party.moderated && !pending.length) return;
+
+        const queue = document.createElement('div');
+        queue.className = 'party-queue';
+        if (!pending.length) {
+            queue.textContent = 'No photos waiting for approval.';
+        }
+        pending.forEach(photo => {
+            const card = document.createElement('div');
+            card.className = 'party-photo';
+            const img = document.createElement('img');
+            img.src = `/api/party/photos/${encodeURIComponent(photo.id)}`;
+            img.alt = '';
+            const by = document.createElement('div');
+            by.className = 'party-photo-by';
+            by.textContent = photo.uploader || 'Guest';
+            const approve = document.createElement('button');
+            approve.type = 'button';
+            approve.textContent = 'Show';
+            approve.addEventListener('click', () => moderate(photo, 'POST', '/approve'));
+            const reject = document.createElement('button');
+            reject.type = 'button';
+            reject.textContent = 'Reject';
+            reject.addEventListener('click', () => moderate(photo, 'DELETE', ''));
+            card.append(img, by, approve, reject);
+            queue.appendChild(card);
+        });
+        body.appendChild(queue);
+    }
+
+    async function moderate(photo, method, suffix) {
+        const resp = await fetch(`/api/party/photos/${encodeURIComponent(photo.id)}${suffix}`, { method });
+        if (!resp.ok) {
+            alert((await resp.text()).trim());
+        }
+        load();
+    }
+
+    async function end() {
+        if (!confirm('End the party and delete its photos?')) return;
+        await fetch('/api/party', { method: 'DELETE' });
+        load();
+    }
+
+    function open() {
+        if (typeof closeSettings === 'function') closeSettings();
+        getPanel().classList.add('visible');
+        load().catch(() => { getBody().textContent = 'Could not reach the server'; });
+    }
+
+    function close() {
+        const panel = document.getElementById('partyPanel');
+        if (panel) {
+            panel.classList.remove('visible');
+            panel.querySelector('.party-body').innerHTML = '';
+        }
+    }
+
+    // Keep the moderation queue current while the panel is open
+    window.addEventListener('ws:party', () => {
+        if (isOpen()) load().catch(() => {});
+    });
+
+    return {
+        open,
+        close
+    };
+})();
+
+function openPartyMode() { PartyMode.open(); }
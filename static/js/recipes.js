@@ -0,0 +1,305 @@
+/**
+ * Recipes Module
+ * Recipe box imported from recipe sites, a step-by-step cooking view sized for the
+ * kitchen tablet, and the running kitchen timers started from recipe steps.
+ */
+const Recipes = (function() {
+    let recipes = [];
+    let timers = [];
+    let currentRecipe = null;
+    let currentStep = 0;
+    let tickInterval = null;
+
+    // ===== Data =====
+    async function loadRecipes() {
+        try {
+            const resp = await fetch('/api/recipes');
+            if (resp.ok) {
+                recipes = await resp.json();
+            }
+        } catch (err) {
+            console.error('Failed to load recipes:', err);
+        }
+        updateSummary();
+    }
+
+    async function loadTimers() {
+        try {
+            const resp = await fetch('/api/timers');
+            if (resp.ok) {
+                setTimers(await resp.json());
+            }
+        } catch (err) {
+            console.error('Failed to load timers:', err);
+        }
+    }
+
+    function setTimers(list) {
+        timers = list || [];
+        renderTimers();
+        updateSummary();
+
+        // Tick only while something is counting down
+        if (timers.length > 0 && !tickInterval) {
+            tickInterval = setInterval(renderTimers, 1000);
+        } else if (timers.length === 0 && tickInterval) {
+            clearInterval(tickInterval);
+            tickInterval = null;
+        }
+    }
+
+    function updateSummary() {
+        const el = document.getElementById('summary-Recipes');
+        if (!el) return;
+        if (timers.length > 0) {
+            const next = timers[0];
+            el.textContent = `${next.name} · ${formatRemaining(next.endsAt)}`;
+        } else {
+            el.textContent = `${recipes.length} recipe${recipes.length !== 1 ? 's' : ''}`;
+        }
+    }
+
+    // ===== Modal =====
+    function openModal() {
+        currentRecipe = null;
+        renderList();
+        renderTimers();
+        document.getElementById('recipesModal').classList.add('active');
+    }
+
+    function closeModal() {
+        document.getElementById('recipesModal').classList.remove('active');
+    }
+
+    function renderList() {
+        document.getElementById('recipesModalTitle').textContent = 'Recipes';
+        const content = document.getElementById('recipesModalContent');
+
+        const rows = recipes.map(r => `
+            <div class="recipe-row" onclick="Recipes.openRecipe('${r.id}')">
+                ${r.image ? `<img class="recipe-row-image" src="${escapeHtml(r.image).replace(/"/g, '&quot;')}" alt="" loading="lazy">` : '<div class="recipe-row-image"></div>'}
+                <div class="recipe-row-info">
+                    <div class="recipe-row-name">${escapeHtml(r.name)}</div>
+                    <div class="recipe-row-meta">${escapeHtml(recipeMeta(r))}</div>
+                </div>
+                <button class="recipe-delete-btn" onclick="event.stopPropagation(); Recipes.deleteRecipe('${r.id}')">&times;</button>
+            </div>
+        `).join('');
+
+        content.innerHTML = `
+            <form class="recipe-import" onsubmit="event.preventDefault(); Recipes.importRecipe();">
+                <input type="url" id="recipeImportUrl" placeholder="Paste a recipe URL" required>
+                <button type="submit" id="recipeImportBtn">Import</button>
+            </form>
+            <div id="recipeImportError" class="recipe-import-error"></div>
+            ${recipes.length === 0 ? '<div class="recipes-empty">No recipes yet</div>' : `<div class="recipe-list">${rows}</div>`}
+        `;
+    }
+
+    function recipeMeta(r) {
+        const parts = [];
+        const minutes = r.totalMinutes || (r.prepMinutes || 0) + (r.cookMinutes || 0);
+        if (minutes > 0) parts.push(formatMinutes(minutes));
+        if (r.yield) parts.push(r.yield);
+        parts.push(`${r.steps.length} step${r.steps.length !== 1 ? 's' : ''}`);
+        return parts.join(' · ');
+    }
+
+    async function importRecipe() {
+        const input = document.getElementById('recipeImportUrl');
+        const btn = document.getElementById('recipeImportBtn');
+        const errorEl = document.getElementById('recipeImportError');
+        btn.disabled = true;
+        btn.textContent = 'Importing...';
+        errorEl.textContent = '';
+        try {
+            const resp = await fetch('/api/recipes', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ url: input.value })
+            });
+            if (!resp.ok) {
+                errorEl.textContent = (await resp.text()).trim();
+                return;
+            }
+            const recipe = await resp.json();
+            await loadRecipes();
+            openRecipe(recipe.id);
+        } catch (err) {
+            console.error('Failed to import recipe:', err);
+            errorEl.textContent = 'Import failed';
+        } finally {
+            btn.disabled = false;
+            btn.textContent = 'Import';
+        }
+    }
+
+    async function deleteRecipe(id) {
+        const recipe = recipes.find(r => r.id === id);
+        if (!recipe || !confirm(`Delete "${recipe.name}"?`)) return;
+        try {
+            await fetch(`/api/recipes/${id}`, { method: 'DELETE' });
+            await loadRecipes();
+            renderList();
+        } catch (err) {
+            console.error('Failed to delete recipe:', err);
+        }
+    }
+
+    // ===== Cooking View =====
+    function openRecipe(id) {
+        currentRecipe = recipes.find(r => r.id === id) || null;
+        currentStep = 0;
+        if (!currentRecipe) {
+            renderList();
+            return;
+        }
+        renderRecipe();
+    }
+
+    function renderRecipe() {
+        const r = currentRecipe;
+        document.getElementById('recipesModalTitle').textContent = r.name;
+        const content = document.getElementById('recipesModalContent');
+        const step = r.steps[currentStep];
+
+        content.innerHTML = `
+            <div class="recipe-view">
+                <div class="recipe-ingredients">
+                    <button class="recipe-back-btn" onclick="Recipes.showList()">&larr; All recipes</button>
+                    ${r.yield ? `<div class="recipe-yield">${escapeHtml(r.yield)}</div>` : ''}
+                    <ul>${r.ingredients.map(i => `<li>${escapeHtml(i)}</li>`).join('')}</ul>
+                </div>
+                <div class="recipe-step">
+                    ${step ? `
+                    <div class="recipe-step-count">Step ${currentStep + 1} of ${r.steps.length}${step.section ? ` · ${escapeHtml(step.section)}` : ''}</div>
+                    <div class="recipe-step-text">${escapeHtml(step.text)}</div>
+                    ${step.seconds ? `
+                    <button class="recipe-timer-btn" onclick="Recipes.startStepTimer(${currentStep})">
+                        Start ${formatSeconds(step.seconds)} timer
+                    </button>` : ''}
+                    <div class="recipe-step-nav">
+                        <button onclick="Recipes.prevStep()" ${currentStep === 0 ? 'disabled' : ''}>&larr; Back</button>
+                        <button onclick="Recipes.nextStep()" ${currentStep >= r.steps.length - 1 ? 'disabled' : ''}>Next &rarr;</button>
+                    </div>
+                    ` : '<div class="recipes-empty">This recipe has no steps</div>'}
+                </div>
+            </div>
+        `;
+    }
+
+    function showList() {
+        currentRecipe = null;
+        renderList();
+    }
+
+    function nextStep() {
+        if (currentRecipe && currentStep < currentRecipe.steps.length - 1) {
+            currentStep++;
+            renderRecipe();
+        }
+    }
+
+    function prevStep() {
+        if (currentRecipe && currentStep > 0) {
+            currentStep--;
+            renderRecipe();
+        }
+    }
+
+    async function startStepTimer(index) {
+        if (!currentRecipe) return;
+        try {
+            const resp = await fetch(`/api/recipes/${currentRecipe.id}/steps/${index}/timer`, { method: 'POST' });
+            if (!resp.ok) {
+                console.error('Failed to start timer:', (await resp.text()).trim());
+            }
+        } catch (err) {
+            console.error('Failed to start timer:', err);
+        }
+    }
+
+    // ===== Timers =====
+    function renderTimers() {
+        updateSummary();
+        const el = document.getElementById('recipeTimers');
+        if (!el) return;
+        el.innerHTML = timers.map(t => `
+            <div class="recipe-timer-chip">
+                <span class="recipe-timer-name">${escapeHtml(t.name)}</span>
+                <span class="recipe-timer-remaining">${formatRemaining(t.endsAt)}</span>
+                <button class="recipe-timer-cancel" onclick="Recipes.cancelTimer('${t.id}')">&times;</button>
+            </div>
+        `).join('');
+    }
+
+    async function cancelTimer(id) {
+        try {
+            await fetch(`/api/timers/${id}`, { method: 'DELETE' });
+        } catch (err) {
+            console.error('Failed to cancel timer:', err);
+        }
+    }
+
+    // ===== Formatting =====
+    function formatRemaining(endsAt) {
+        const seconds = Math.max(0, Math.round((new Date(endsAt) - Date.now()) / 1000));
+        return formatClock(seconds);
+    }
+
+    function formatClock(seconds) {
+        const h = Math.floor(seconds / 3600);
+        const m = Math.floor((seconds % 3600) / 60);
+        const s = seconds % 60;
+        const pad = n => String(n).padStart(2, '0');
+        return h > 0 ? `${h}:${pad(m)}:${pad(s)}` : `${m}:${pad(s)}`;
+    }
+
+    function formatSeconds(seconds) {
+        if (seconds % 60 !== 0) return formatClock(seconds);
+        return formatMinutes(seconds / 60);
+    }
+
+    function formatMinutes(minutes) {
+        const h = Math.floor(minutes / 60);
+        const m = minutes % 60;
+        if (h === 0) return `${m} min`;
+        return m === 0 ? `${h} hr` : `${h} hr ${m} min`;
+    }
+
+    function init() {
+        loadRecipes();
+        loadTimers();
+
+        window.addEventListener('ws:recipes_changed', function(e) {
+            recipes = e.detail || [];
+            updateSummary();
+            if (!currentRecipe && document.getElementById('recipesModal').classList.contains('active')) {
+                renderList();
+            }
+        });
+        window.addEventListener('ws:timers_changed', function(e) {
+            setTimers(e.detail);
+        });
+    }
+
+    return {
+        init,
+        openModal,
+        closeModal,
+        openRecipe,
+        showList,
+        importRecipe,
+        deleteRecipe,
+        nextStep,
+        prevStep,
+        startStepTimer,
+        cancelTimer
+    };
+})();
+
+document.addEventListener('DOMContentLoaded', Recipes.init);
+
+// Global functions for onclick handlers
+function openRecipesModal() { Recipes.openModal(); }
+function closeRecipesModal() { Recipes.closeModal(); }
@@ -15,6 +15,8 @@ const Screensaver = (function() {
     let photos = [];
     let currentPhotoIndex = 0;
     let currentBgElement = 0;
+    let party = null; // Running party; its guest photos replace the slideshow
+    let partyIndex = 0;
 
     // Check if a video modal is currently open (don't activate screensaver over videos)
     function isVideoModalOpen() {
@@ -47,10 +49,15 @@ const Screensaver = (function() {
         }
     }
 
+    // URL of a photo from the active source (Google Photos, local folder or Drive)
+    function photoURL(id) {
+        return `/api/screensaver/photo/${encodeURIComponent(id)}`;
+    }
+
     // Load all photos for screensaver
     async function loadPhotos() {
         try {
-            const resp = await fetch('/api/drive/photos');
+            const resp = await fetch('/api/screensaver/photos');
             if (resp.ok) {
                 photos = await resp.json();
                 console.log(`Loaded ${photos.length} photos`);
@@ -62,15 +69,59 @@ const Screensaver = (function() {
         }
     }
 
+    // Load the running party, if any
+    async function loadParty() {
+        try {
+            const resp = await fetch('/api/party');
+            setParty(resp.ok ? await resp.json() : null);
+        } catch (err) {
+            setParty(null);
+        }
+    }
+
+    // Switch the slideshow to or from a party's photos
+    function setParty(status) {
+        const started = status && !party;
+        const changed = !!status !== !!party;
+        party = status;
+        if (changed) partyIndex = 0;
+        updatePartyOverlay();
+        if (started) {
+            show();
+        } else if (changed && isActive) {
+            restartPhotoTimer();
+        }
+    }
+
+    // Show the join QR code while a party runs
+    function updatePartyOverlay() {
+        const screensaver = document.getElementById('screensaver');
+        if (!screensaver) return;
+        let overlay = document.getElementById('screensaverParty');
+        if (!party) {
+            if (overlay) overlay.remove();
+            return;
+        }
+        if (!overlay) {
+            overlay = document.createElement('div');
+            overlay.id = 'screensaverParty';
+            overlay.className = 'screensaver-party';
+            overlay.innerHTML = '<img alt=""><div class="screensaver-party-text"></div>';
+            screensaver.appendChild(overlay);
+        }
+        overlay.querySelector('img').src = '/api/party/qr.svg?t=' + encodeURIComponent(party.started);
+        overlay.querySelector('.screensaver-party-text').textContent = `${party.name}: scan to add your photos`;
+    }
+
     // Load a random background photo for the page
     async function loadBackgroundPhoto() {
         try {
-            const resp = await fetch('/api/drive/photos/random');
+            const resp = await fetch('/api/screensaver/photos/random');
             if (resp.ok) {
                 const photo = await resp.json();
                 const bg = document.getElementById('pageBackground');
                 if (bg && photo.id) {
-                    const photoUrl = `/api/drive/photo/${photo.id}`;
+                    const photoUrl = photoURL(photo.id);
                     bg.style.backgroundImage = `url(${photoUrl})`;
                     bg.classList.add('active');
                     console.log(`Background photo loaded: ${photo.name}`);
@@ -125,11 +176,8 @@ const Screensaver = (function() {
         updateClock();
         clockTimer = setInterval(updateClock, 1000);
 
-        // Show first photo and start cycling (60 second intervals)
-        if (photos.length > 0) {
-            showNextPhoto();
-            photoTimer = setInterval(showNextPhoto, 60000);
-        }
+        // Show first photo and start cycling
+        restartPhotoTimer();
 
         // Start polling proximity as fallback (in case WebSocket is disconnected)
         startProximityPolling();
@@ -223,15 +271,38 @@ const Screensaver = (function() {
         }
     }
 
+    // Cycle photos every minute, or every 10 seconds through party photos
+    function restartPhotoTimer() {
+        if (photoTimer) {
+            clearInterval(photoTimer);
+            photoTimer = null;
+        }
+        const partyPhotos = party && party.photos.length > 0;
+        if (partyPhotos || photos.length > 0) {
+            showNextPhoto();
+            photoTimer = setInterval(showNextPhoto, partyPhotos ? 10000 : 60000);
+        }
+    }
+
     // Show next photo with crossfade effect
     function showNextPhoto() {
-        if (photos.length === 0) return;
-
-        const photo = photos[currentPhotoIndex];
-        const photoUrl = `/api/drive/photo/${photo.id}`;
-
-        console.log(`Loading screensaver photo: ${photo.name} (${photo.id})`);
+        let photoUrl;
+        if (party && party.photos.length > 0) {
+            const photo = party.photos[partyIndex % party.photos.length];
+            partyIndex = (partyIndex + 1) % party.photos.length;
+            photoUrl = `/api/party/photos/${encodeURIComponent(photo.id)}`;
+        } else {
+            if (photos.length === 0) return;
+            const photo = photos[currentPhotoIndex];
+            currentPhotoIndex = (currentPhotoIndex + 1) % photos.length;
+            photoUrl = photoURL(photo.id);
+            console.log(`Loading screensaver photo: ${photo.name} (${photo.id})`);
+        }
+        crossfadeTo(photoUrl);
+    }
 
+    // Crossfade the background to a photo once it has loaded
+    function crossfadeTo(photoUrl) {
         const bgs = document.querySelectorAll('.screensaver-bg');
         if (bgs.length < 2) return;
 
@@ -249,11 +320,9 @@ const Screensaver = (function() {
             currentBgElement = 1 - currentBgElement;
         };
         img.onerror = () => {
-            console.error(`Failed to load photo: ${photo.name}`);
+            console.error(`Failed to load photo: ${photoUrl}`);
         };
         img.src = photoUrl;
-
-        currentPhotoIndex = (currentPhotoIndex + 1) % photos.length;
     }
 
     // Update Spotify display on screensaver
@@ -270,11 +339,8 @@ const Screensaver = (function() {
         container.style.display = 'flex';
 
         const track = playbackData.item;
-        const album = track.album || {};
-        const artists = track.artists ? track.artists.map(a => a.name).join(', ') : '';
-        const albumArt = album.images && album.images.length > 0
-            ? album.images[0].url
-            : '';
+        const { images, subtitle: artists } = Spotify.describeItem(track);
+        const albumArt = images.length > 0 ? images[0].url : '';
 
         // Update album art
         const artEl = document.getElementById('screensaverSpotifyArt');
@@ -314,6 +380,22 @@ const Screensaver = (function() {
             }
         });
 
+        // Routines (e.g. the evening wind-down) can start the screensaver
+        window.addEventListener('ws:screensaver', function() {
+            show();
+        });
+
+        // Party mode: new guest photos are shown straight away
+        window.addEventListener('ws:party', function(e) {
+            setParty(e.detail && e.detail.started ? e.detail : null);
+        });
+        window.addEventListener('ws:party_photo', function(e) {
+            if (!party || party.photos.some(p => p.id === e.detail.id)) return;
+            party.photos.push(e.detail);
+            partyIndex = party.photos.length - 1;
+            if (isActive) restartPhotoTimer();
+        });
+
         // When WebSocket reconnects, check if someone is already near
         window.addEventListener('ws:connected', function() {
             if (isActive) {
@@ -357,6 +439,7 @@ const Screensaver = (function() {
         setupDismissHandlers();
         setupProximityWakeListener();
         loadConfig();
+        loadParty();
     }
 
     // Public API
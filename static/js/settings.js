@@ -114,10 +114,95 @@ const Settings = (function() {
             modal.classList.add('active');
             loadThemeSetting();
             loadTimeFormatSetting();
+            loadScreenTime();
+            loadSecurityIncidents();
+        }
+    }
+
+    function formatMinutes(minutes) {
+        const m = Math.round(minutes);
+        if (m < 60) return m + 'm';
+        return Math.floor(m / 60) + 'h ' + (m % 60) + 'm';
+    }
+
+    // Load today's device usage (section stays hidden until a device has reported)
+    async function loadScreenTime() {
+        const section = document.getElementById('screenTimeSection');
+        const list = document.getElementById('screenTimeList');
+        if (!section || !list) return;
+
+        try {
+            const resp = await fetch('/api/screentime?days=7');
+            if (!resp.ok) return;
+            const summary = await resp.json();
+            if (!summary.devices || summary.devices.length === 0) return;
+
+            list.innerHTML = summary.devices.map(d => {
+                const apps = (d.topApps || []).slice(0, 3)
+                    .map(a => `${escapeHtml(a.name || a.app)} ${formatMinutes(a.minutes)}`).join(' · ');
+                return `
+                    <div class="screentime-row">
+                        <div class="screentime-device">
+                            <span class="screentime-name">${escapeHtml(d.device)}</span>
+                            <span class="screentime-today">${formatMinutes(d.todayMinutes)}</span>
+                        </div>
+                        <div class="screentime-detail">
+                            7-day avg ${formatMinutes(d.averageMinutes)}${apps ? ' · ' + apps : ''}
+                        </div>
+                    </div>`;
+            }).join('');
+            section.style.display = '';
+        } catch (err) {
+            console.error('Failed to load screen time:', err);
         }
     }
 
     // Close settings modal
+    function formatClock(iso) {
+        return new Date(iso).toLocaleTimeString([], {
+            hour: 'numeric',
+            minute: '2-digit',
+            hour12: getTimeFormat() === '12'
+        });
+    }
+
+    // Load the last day's away-mode incidents as a timeline (hidden when there are none)
+    async function loadSecurityIncidents() {
+        const section = document.getElementById('securitySection');
+        const list = document.getElementById('securityList');
+        if (!section || !list) return;
+
+        try {
+            const resp = await fetch('/api/security/incidents?hours=24');
+            if (!resp.ok) return;
+            const data = await resp.json();
+            if (!data.incidents || data.incidents.length === 0) {
+                section.style.display = 'none';
+                return;
+            }
+
+            list.innerHTML = data.incidents.map(inc => {
+                const timeline = (inc.timeline || []).map(s => `
+                    <li>
+                        <span class="security-time">${formatClock(s.time)}</span>
+                        <span class="security-kind">${escapeHtml(s.kind)}</span>
+                        ${escapeHtml(s.name || s.subject)}${s.detail ? ' · ' + escapeHtml(s.detail) : ''}
+                    </li>`).join('');
+                return `
+                    <div class="security-incident severity-${escapeHtml(inc.severity)}">
+                        <div class="security-summary">
+                            <span>${escapeHtml(inc.summary)}</span>
+                            <span class="security-time">${formatClock(inc.start)}${inc.open ? ' · ongoing' : ''}</span>
+                        </div>
+                        <ul class="security-timeline">${timeline}</ul>
+                    </div>`;
+            }).join('');
+            section.style.display = '';
+        } catch (err) {
+            console.error('Failed to load security incidents:', err);
+        }
+    }
+
     function close() {
         const modal = document.getElementById('settingsModal');
         if (modal) {
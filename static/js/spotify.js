@@ -5,9 +5,12 @@
 const Spotify = (function() {
     // State variables
     let spotifyStatus = null;
+    let spotifyAccount = localStorage.getItem('spotifyAccount') || 'default';
     let spotifyPlayback = null;
     let spotifyDevices = [];
     let spotifyPlaylists = [];
+    let spotifyQueue = [];
+    let saveMenuOpen = false;
     let activeSpotifyTab = 'now-playing';
     let activeSpotifyBrowseTab = 'home';
     let spotifyRecentItems = [];
@@ -45,18 +48,72 @@ const Spotify = (function() {
     // Progress update interval
     let miniPlayerProgressInterval = null;
 
+    // ===== Accounts =====
+
+    // Add the selected account to an API URL
+    function spotifyUrl(url) {
+        if (spotifyAccount === 'default') return url;
+        return url + (url.includes('?') ? '&' : '?') + 'account=' + encodeURIComponent(spotifyAccount);
+    }
+
+    function renderAccountSelect() {
+        const select = document.getElementById('spotifyAccountSelect');
+        if (!select || !spotifyStatus) return;
+        const accounts = spotifyStatus.accounts || [];
+        select.style.display = accounts.length > 1 ? '' : 'none';
+        select.innerHTML = accounts.map(name =>
+            `<option value="${escapeHtml(name)}"${name === spotifyAccount ? ' selected' : ''}>${escapeHtml(name.charAt(0).toUpperCase() + name.slice(1))}</option>`
+        ).join('');
+    }
+
+    // Switch the kiosk to another person's account and reload everything for it
+    async function switchAccount(name) {
+        spotifyAccount = name;
+        localStorage.setItem('spotifyAccount', name);
+
+        spotifyPlayback = null;
+        spotifyQueue = [];
+        spotifyPlaylists = [];
+        spotifyRecentItems = [];
+        spotifyTopArtists = [];
+        spotifyTopTracks = [];
+        spotifyDetailView = null;
+        spotifyDetailHistory = [];
+        libraryAlbums = [];
+        libraryArtists = [];
+        libraryShows = [];
+        libraryLoaded = false;
+
+        updateSpotifySummary();
+        await checkSpotifyStatus();
+        const modal = document.getElementById('spotifyModal');
+        if (modal && modal.classList.contains('active')) {
+            openSpotifyModal();
+        }
+    }
+
     // ===== Status and Playback Functions =====
 
     async function checkSpotifyStatus() {
         try {
-            const resp = await fetch('/api/spotify/status');
+            const resp = await fetch(spotifyUrl('/api/spotify/status'));
             if (resp.ok) {
                 spotifyStatus = await resp.json();
+                if (!(spotifyStatus.accounts || []).includes(spotifyAccount)) {
+                    // The saved account was removed from SPOTIFY_ACCOUNTS
+                    spotifyAccount = 'default';
+                    localStorage.removeItem('spotifyAccount');
+                    spotifyStatus = await (await fetch('/api/spotify/status')).json();
+                }
+                renderAccountSelect();
                 const card = document.getElementById('spotifyCard');
                 if (spotifyStatus.configured) {
                     card.style.display = '';
                     if (spotifyStatus.authenticated) {
                         loadSpotifyPlayback();
+                        if (spotifyStatus.playerName) {
+                            startWebPlayer();
+                        }
                     } else {
                         document.getElementById('summary-Spotify').textContent = 'Not connected';
                     }
@@ -67,43 +124,50 @@ const Spotify = (function() {
         }
     }
 
+    // ===== Web Playback (the kiosk as a Connect device) =====
+    let webPlayer = null;
+
+    function startWebPlayer() {
+        if (webPlayer || document.getElementById('spotifySdk')) return;
+        window.onSpotifyWebPlaybackSDKReady = function() {
+            // window.Spotify is the SDK; the bare name is this module
+            webPlayer = new window.Spotify.Player({
+                name: spotifyStatus.playerName,
+                volume: 0.5,
+                getOAuthToken: async function(callback) {
+                    try {
+                        const resp = await fetch(spotifyUrl('/api/spotify/token'));
+                        if (resp.ok) {
+                            callback((await resp.json()).accessToken);
+                        } else {
+                            console.log('Spotify web player token unavailable:', await resp.text());
+                        }
+                    } catch (err) {
+                        console.error('Failed to get Spotify token:', err);
+                    }
+                }
+            });
+            webPlayer.addListener('ready', ({ device_id }) => {
+                console.log('Spotify web player ready:', device_id);
+            });
+            webPlayer.addListener('authentication_error', ({ message }) => {
+                console.log('Spotify web player authentication failed:', message);
+            });
+            webPlayer.connect();
+        };
+        const script = document.createElement('script');
+        script.id = 'spotifySdk';
+        script.src = 'https://sdk.scdn.co/spotify-player.js';
+        document.head.appendChild(script);
+    }
+
     async function loadSpotifyPlayback() {
         if (!spotifyStatus || !spotifyStatus.authenticated) return;
 
         try {
-            const resp = await fetch('/api/spotify/playback');
+            const resp = await fetch(spotifyUrl('/api/spotify/playback'));
             if (resp.ok) {
-                const newPlayback = await resp.json();
-
-                // Preserve local progress if same track is playing to prevent jumping
-                const sameTrack = spotifyPlayback?.item?.id === newPlayback?.item?.id;
-                const wasPlaying = spotifyPlayback?.is_playing;
-                const nowPlaying = newPlayback?.is_playing;
-
-                if (sameTrack && wasPlaying && nowPlaying && spotifyPlayback.progress_ms) {
-                    // Keep local progress, only sync if drift is significant (> 3 seconds)
-                    const drift = Math.abs(newPlayback.progress_ms - spotifyPlayback.progress_ms);
-                    if (drift > 3000) {
-                        // Significant drift - sync with server
-                        spotifyPlayback = newPlayback;
-                    } else {
-                        // Keep local progress, update other fields
-                        const localProgress = spotifyPlayback.progress_ms;
-                        spotifyPlayback = newPlayback;
-                        spotifyPlayback.progress_ms = localProgress;
-                    }
-                } else {
-                    // Track changed or play state changed - use server data
-                    spotifyPlayback = newPlayback;
-                }
-
-                updateSpotifySummary();
-
-                // Update only the now playing panel if modal is open (don't re-render entire content)
-                const modal = document.getElementById('spotifyModal');
-                if (modal && modal.classList.contains('active')) {
-                    updateNowPlayingPanel();
-                }
+                applySpotifyPlayback(await resp.json());
             } else if (resp.status === 401) {
                 document.getElementById('summary-Spotify').textContent = 'Not connected';
             }
@@ -112,6 +176,112 @@ const Spotify = (function() {
         }
     }
 
+    // Apply a playback state, fetched or pushed as a spotify_playback event
+    function applySpotifyPlayback(newPlayback) {
+        if (!spotifyStatus || !spotifyStatus.authenticated) return;
+
+        // Preserve local progress if same track is playing to prevent jumping
+        const sameTrack = spotifyPlayback?.item?.id === newPlayback?.item?.id;
+        const wasPlaying = spotifyPlayback?.is_playing;
+        const nowPlaying = newPlayback?.is_playing;
+
+        if (sameTrack && wasPlaying && nowPlaying && spotifyPlayback.progress_ms) {
+            // Keep local progress, only sync if drift is significant (> 3 seconds)
+            const drift = Math.abs(newPlayback.progress_ms - spotifyPlayback.progress_ms);
+            if (drift > 3000) {
+                // Significant drift - sync with server
+                spotifyPlayback = newPlayback;
+            } else {
+                // Keep local progress, update other fields
+                const localProgress = spotifyPlayback.progress_ms;
+                spotifyPlayback = newPlayback;
+                spotifyPlayback.progress_ms = localProgress;
+            }
+        } else {
+            // Track changed or play state changed - use server data
+            spotifyPlayback = newPlayback;
+            if (!sameTrack) loadSpotifyQueue();
+        }
+
+        updateSpotifySummary();
+
+        // Update only the now playing panel if modal is open (don't re-render entire content)
+        const modal = document.getElementById('spotifyModal');
+        if (modal && modal.classList.contains('active')) {
+            updateNowPlayingPanel();
+        }
+    }
+
+    async function loadSpotifyQueue() {
+        try {
+            const resp = await fetch(spotifyUrl('/api/spotify/queue'));
+            if (resp.ok) {
+                const data = await resp.json();
+                setSpotifyQueue(data);
+            }
+        } catch (err) {
+            console.error('Failed to load Spotify queue:', err);
+        }
+    }
+
+    function setSpotifyQueue(data) {
+        spotifyQueue = (data && data.queue) || [];
+        const modal = document.getElementById('spotifyModal');
+        if (modal && modal.classList.contains('active')) {
+            updateNowPlayingPanel();
+        }
+    }
+
+    async function addToQueue(uri) {
+        try {
+            const resp = await fetch(spotifyUrl('/api/spotify/queue'), {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ uri })
+            });
+            if (!resp.ok) {
+                console.error('Failed to add to queue:', await resp.text());
+            }
+        } catch (err) {
+            console.error('Failed to add to queue:', err);
+        }
+    }
+
+    // ===== Playlist Editing =====
+
+    function toggleSaveMenu() {
+        saveMenuOpen = !saveMenuOpen;
+        updateNowPlayingPanel();
+    }
+
+    async function saveCurrentTrack(playlistId) {
+        const uri = spotifyPlayback?.item?.uri;
+        saveMenuOpen = false;
+        if (!uri) return;
+
+        try {
+            const resp = await fetch(spotifyUrl(`/api/spotify/playlist/${encodeURIComponent(playlistId)}/tracks`), {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ uris: [uri] })
+            });
+            if (!resp.ok) {
+                console.error('Failed to save track:', await resp.text());
+            }
+        } catch (err) {
+            console.error('Failed to save track:', err);
+        }
+        updateNowPlayingPanel();
+    }
+
+    function renderSaveMenu() {
+        if (!saveMenuOpen) return '';
+        const items = spotifyPlaylists.map(p =>
+            `<div class="spotify-save-menu-item" onclick="Spotify.saveCurrentTrack('${p.id}')">${escapeHtml(p.name)}</div>`
+        ).join('');
+        return `<div class="spotify-save-menu">${items || '<div class="spotify-save-menu-empty">No playlists</div>'}</div>`;
+    }
+
     function updateNowPlayingPanel() {
         // Skip full re-render while user is adjusting volume to prevent slider jumping
         if (isAdjustingVolume) {
@@ -170,7 +340,7 @@ const Spotify = (function() {
         if (!spotifyStatus || !spotifyStatus.authenticated) return;
 
         try {
-            const resp = await fetch('/api/spotify/devices');
+            const resp = await fetch(spotifyUrl('/api/spotify/devices'));
             if (resp.ok) {
                 spotifyDevices = await resp.json();
             }
@@ -183,7 +353,7 @@ const Spotify = (function() {
         if (!spotifyStatus || !spotifyStatus.authenticated) return;
 
         try {
-            const resp = await fetch('/api/spotify/playlists?limit=50');
+            const resp = await fetch(spotifyUrl('/api/spotify/playlists?limit=50'));
             if (resp.ok) {
                 const data = await resp.json();
                 spotifyPlaylists = data.items || [];
@@ -197,7 +367,7 @@ const Spotify = (function() {
         if (!spotifyStatus || !spotifyStatus.authenticated) return;
 
         try {
-            const resp = await fetch('/api/spotify/recent?limit=50');
+            const resp = await fetch(spotifyUrl('/api/spotify/recent?limit=50'));
             if (resp.ok) {
                 const data = await resp.json();
                 spotifyRecentItems = data.items || [];
@@ -216,7 +386,7 @@ const Spotify = (function() {
         if (!spotifyStatus || !spotifyStatus.authenticated) return;
 
         try {
-            const resp = await fetch('/api/spotify/top/artists?limit=20');
+            const resp = await fetch(spotifyUrl('/api/spotify/top/artists?limit=20'));
             if (resp.ok) {
                 const data = await resp.json();
                 spotifyTopArtists = data.items || [];
@@ -230,7 +400,7 @@ const Spotify = (function() {
         if (!spotifyStatus || !spotifyStatus.authenticated) return;
 
         try {
-            const resp = await fetch('/api/spotify/top/tracks?limit=20');
+            const resp = await fetch(spotifyUrl('/api/spotify/top/tracks?limit=20'));
             if (resp.ok) {
                 const data = await resp.json();
                 spotifyTopTracks = data.items || [];
@@ -242,6 +412,20 @@ const Spotify = (function() {
 
     // ===== Summary and Mini Player =====
 
+    // Art and subtitle for the playing item, which is a track or a podcast episode
+    function describeItem(item) {
+        if (item.type === 'episode') {
+            const images = item.images?.length ? item.images : (item.show?.images || []);
+            return { images, subtitle: item.show?.name || '', artists: [] };
+        }
+        const artists = item.artists || [];
+        return {
+            images: item.album?.images || [],
+            subtitle: artists.map(a => a.name).join(', '),
+            artists
+        };
+    }
+
     function updateSpotifySummary() {
         const summaryEl = document.getElementById('summary-Spotify');
         if (summaryEl) {
@@ -249,7 +433,7 @@ const Spotify = (function() {
                 summaryEl.textContent = 'Not playing';
             } else {
                 const track = spotifyPlayback.item;
-                const artist = track.artists ? track.artists.map(a => a.name).join(', ') : '';
+                const artist = describeItem(track).subtitle;
                 summaryEl.textContent = spotifyPlayback.is_playing
                     ? `${track.name} - ${artist}`
                     : 'Paused';
@@ -276,11 +460,8 @@ const Spotify = (function() {
         miniPlayer.style.display = 'flex';
 
         const track = spotifyPlayback.item;
-        const album = track.album || {};
-        const artists = track.artists ? track.artists.map(a => a.name).join(', ') : '';
-        const albumArt = album.images && album.images.length > 0
-            ? album.images[album.images.length - 1].url
-            : '';
+        const { images, subtitle: artists } = describeItem(track);
+        const albumArt = images.length > 0 ? images[images.length - 1].url : '';
 
         // Update album art
         const artEl = document.getElementById('spotifyMiniArt');
@@ -352,13 +533,25 @@ const Spotify = (function() {
 
     function openSpotifyModal() {
         if (!spotifyStatus || !spotifyStatus.authenticated) {
-            window.location.href = '/auth/spotify';
+            if ((spotifyStatus?.accounts || []).length > 1) {
+                // Keep the modal so another account can be picked
+                document.getElementById('spotifyModal').classList.add('active');
+                document.getElementById('spotifyModalContent').innerHTML = `
+                    <div class="spotify-no-playback">
+                        <img src="/icon/spotify" class="spotify-no-playback-icon" alt="">
+                        <p>${escapeHtml(spotifyAccount)} isn't connected to Spotify</p>
+                        <a class="spotify-device-btn" href="${spotifyUrl('/auth/spotify')}">Connect</a>
+                    </div>`;
+                return;
+            }
+            window.location.href = spotifyUrl('/auth/spotify');
             return;
         }
 
         document.getElementById('spotifyModal').classList.add('active');
         loadSpotifyDevices();
         loadSpotifyPlaylists();
+        loadSpotifyQueue();
         loadRecentTracks();
         loadTopArtists();
         loadTopTracks();
@@ -460,8 +653,9 @@ const Spotify = (function() {
 
         const track = spotifyPlayback.item;
         const album = track.album || {};
-        const artistsList = track.artists || [];
-        const albumArt = album.images && album.images.length > 0 ? album.images[0].url : '';
+        const described = describeItem(track);
+        const artistsList = described.artists;
+        const albumArt = described.images.length > 0 ? described.images[0].url : '';
         const isPlaying = spotifyPlayback.is_playing;
         const shuffleActive = spotifyPlayback.shuffle_state ? 'active' : '';
         const repeatState = spotifyPlayback.repeat_state || 'off';
@@ -470,10 +664,15 @@ const Spotify = (function() {
         const deviceName = spotifyPlayback.device?.name || 'Unknown device';
         const albumId = album.id || '';
 
-        // Build clickable artist links
-        const artistLinks = artistsList.map(a =>
-            `<span class="spotify-artist-link" onclick="Spotify.openArtistDetail('${a.id}')">${escapeHtml(a.name)}</span>`
-        ).join(', ');
+        // Build clickable artist links; episodes link to their show instead
+        const artistLinks = track.type === 'episode'
+            ? `<span class="spotify-artist-link" onclick="Spotify.openShow('${track.show?.id || ''}')">${escapeHtml(described.subtitle)}</span>`
+            : artistsList.map(a =>
+                `<span class="spotify-artist-link" onclick="Spotify.openArtistDetail('${a.id}')">${escapeHtml(a.name)}</span>`
+            ).join(', ');
+
+        const upNext = spotifyQueue[0];
+        const upNextArtists = upNext ? (upNext.artists || []).map(a => a.name).join(', ') : '';
 
         return `
             <div class="spotify-now-playing-panel">
@@ -483,6 +682,11 @@ const Spotify = (function() {
                 <div class="spotify-track-info">
                     <div class="spotify-track-name">${escapeHtml(track.name)}</div>
                     <div class="spotify-track-artist">${artistLinks}</div>
+                    <button class="spotify-save-btn" onclick="Spotify.toggleSaveMenu()">
+                        <img src="/icon/plus" alt="">
+                        <span>Save to playlist</span>
+                    </button>
+                    ${renderSaveMenu()}
                 </div>
                 <div class="spotify-progress">
                     <span class="spotify-time" id="spotifyCurrentTime">${formatTime(spotifyPlayback.progress_ms || 0)}</span>
@@ -514,6 +718,11 @@ const Spotify = (function() {
                            oninput="Spotify.onVolumeInput(this)">
                     <span class="spotify-volume-value">${volume}%</span>
                 </div>
+                ${upNext ? `
+                <div class="spotify-up-next">
+                    <span class="spotify-up-next-label">Up next</span>
+                    <span class="spotify-up-next-track">${escapeHtml(upNext.name)}${upNextArtists ? ' · ' + escapeHtml(upNextArtists) : ''}</span>
+                </div>` : ''}
                 <button class="spotify-device-btn" onclick="Spotify.openDeviceModal()">
                     <img src="/icon/speaker" alt="">
                     <span>${escapeHtml(deviceName)}</span>
@@ -552,6 +761,8 @@ const Spotify = (function() {
             html += renderSectionViewInline(data.title, data.items, data.roundImages);
         } else if (type === 'liked-songs') {
             html += renderLikedSongsInline(data.tracks, data.total);
+        } else if (type === 'show') {
+            html += renderShowDetailInline(data.show, data.episodes, data.total);
         }
 
         html += `</div>`;
@@ -836,10 +1047,64 @@ const Spotify = (function() {
         `;
     }
 
+    function renderShowDetailInline(show, episodes, total) {
+        const image = show.images?.[0]?.url || '';
+        const currentUri = spotifyPlayback?.item?.uri;
+        const isPlaying = spotifyPlayback?.is_playing;
+
+        let episodesHtml = '';
+        episodes.forEach(episode => {
+            const isCurrent = episode.uri === currentUri;
+            const nowPlayingClass = isCurrent ? ' now-playing' : '';
+            const resume = episode.resume_point || {};
+            let status = formatTime(episode.duration_ms);
+            let progress = 0;
+            if (resume.fully_played) {
+                status = 'Played';
+                progress = 100;
+            } else if (resume.resume_position_ms > 0) {
+                status = `${formatTime(episode.duration_ms - resume.resume_position_ms)} left`;
+                progress = Math.min(100, resume.resume_position_ms / (episode.duration_ms || 1) * 100);
+            }
+
+            episodesHtml += `
+                <div class="spotify-episode${nowPlayingClass}" onclick="Spotify.resumeEpisode('${episode.id}')" data-track-uri="${episode.uri}">
+                    <div class="spotify-episode-info">
+                        <div class="spotify-episode-name">
+                            ${isCurrent && isPlaying ? '<img src="/icon/playing" class="now-playing-icon" alt="Playing">' : ''}
+                            ${escapeHtml(episode.name)}
+                        </div>
+                        <div class="spotify-episode-description">${escapeHtml(episode.description || '')}</div>
+                        <div class="spotify-episode-meta">${escapeHtml(episode.release_date || '')} • ${status}</div>
+                        ${progress > 0 ? `<div class="spotify-episode-progress"><div class="spotify-episode-progress-fill" style="width:${progress}%"></div></div>` : ''}
+                    </div>
+                </div>`;
+        });
+
+        return `
+            <div class="spotify-album-detail-layout">
+                <div class="spotify-album-detail-left">
+                    <div class="spotify-album-image-large">
+                        ${image ? `<img src="${image}" alt="">` : ''}
+                    </div>
+                    <div class="spotify-album-info">
+                        <div class="spotify-detail-type">Podcast</div>
+                        <div class="spotify-album-name-large">${escapeHtml(show.name || '')}</div>
+                        <div class="spotify-album-artists">${escapeHtml(show.publisher || '')}</div>
+                        <div class="spotify-album-meta">${total} episodes</div>
+                    </div>
+                </div>
+                <div class="spotify-album-detail-right">
+                    <div class="spotify-album-tracklist">${episodesHtml || '<div class="spotify-no-results">No episodes</div>'}</div>
+                </div>
+            </div>
+        `;
+    }
+
     async function playLikedSongs() {
         // Play the first liked song
         try {
-            const resp = await fetch('/api/spotify/library/tracks?limit=1');
+            const resp = await fetch(spotifyUrl('/api/spotify/library/tracks?limit=1'));
             if (resp.ok) {
                 const data = await resp.json();
                 if (data.items && data.items.length > 0) {
@@ -854,13 +1119,13 @@ const Spotify = (function() {
     async function shuffleLikedSongs() {
         // Enable shuffle and play the first liked song
         try {
-            await fetch('/api/spotify/shuffle', {
+            await fetch(spotifyUrl('/api/spotify/shuffle'), {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ state: true })
             });
 
-            const resp = await fetch('/api/spotify/library/tracks?limit=1');
+            const resp = await fetch(spotifyUrl('/api/spotify/library/tracks?limit=1'));
             if (resp.ok) {
                 const data = await resp.json();
                 if (data.items && data.items.length > 0) {
@@ -1209,10 +1474,10 @@ const Spotify = (function() {
     async function loadLibraryData() {
         try {
             const [albumsResp, artistsResp, tracksResp, showsResp] = await Promise.all([
-                fetch('/api/spotify/library/albums?limit=50'),
-                fetch('/api/spotify/library/artists?limit=50'),
-                fetch('/api/spotify/library/tracks?limit=1'), // Just get total count
-                fetch('/api/spotify/library/shows?limit=50')
+                fetch(spotifyUrl('/api/spotify/library/albums?limit=50')),
+                fetch(spotifyUrl('/api/spotify/library/artists?limit=50')),
+                fetch(spotifyUrl('/api/spotify/library/tracks?limit=1')), // Just get total count
+                fetch(spotifyUrl('/api/spotify/library/shows?limit=50'))
             ]);
 
             if (albumsResp.ok) {
@@ -1280,7 +1545,7 @@ const Spotify = (function() {
         browseContent.innerHTML = '<div class="spotify-loading">Loading liked songs...</div>';
 
         try {
-            const resp = await fetch('/api/spotify/library/tracks?limit=50');
+            const resp = await fetch(spotifyUrl('/api/spotify/library/tracks?limit=50'));
             if (resp.ok) {
                 const data = await resp.json();
                 spotifyDetailView = {
@@ -1300,9 +1565,62 @@ const Spotify = (function() {
         }
     }
 
-    function openShow(showId) {
-        // Placeholder for show detail - could be implemented later
-        console.log('Open show:', showId);
+    async function openShow(showId) {
+        if (!showId) return;
+        const browseContent = document.getElementById('spotifyBrowseContent');
+
+        if (spotifyDetailView) {
+            spotifyDetailHistory.push(spotifyDetailView);
+        }
+
+        browseContent.innerHTML = '<div class="spotify-loading">Loading episodes...</div>';
+
+        try {
+            const resp = await fetch(spotifyUrl(`/api/spotify/show/${showId}/episodes?limit=50`));
+            if (resp.ok) {
+                const data = await resp.json();
+                // Saved shows carry the header details; otherwise use the playing episode's show
+                const saved = libraryShows.find(s => s.show?.id === showId);
+                const show = saved?.show
+                    || (spotifyPlayback?.item?.show?.id === showId ? spotifyPlayback.item.show : null)
+                    || { id: showId, name: '', images: data.items?.[0]?.images || [] };
+                spotifyDetailView = {
+                    type: 'show',
+                    data: {
+                        show,
+                        episodes: data.items || [],
+                        total: data.total || 0
+                    }
+                };
+                browseContent.innerHTML = renderBrowseContent();
+            } else {
+                browseContent.innerHTML = '<div class="spotify-error">Failed to load episodes</div>';
+            }
+        } catch (err) {
+            console.error('Failed to load episodes:', err);
+            browseContent.innerHTML = '<div class="spotify-error">Failed to load episodes</div>';
+        }
+    }
+
+    // Play an episode from where it was left off
+    async function resumeEpisode(episodeId) {
+        try {
+            const resp = await fetch(spotifyUrl(`/api/spotify/episode/${episodeId}/resume`), {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({})
+            });
+
+            if (!resp.ok) {
+                const errorText = await resp.text();
+                console.error('Resume failed:', errorText);
+                return;
+            }
+
+            setTimeout(loadSpotifyPlayback, 500);
+        } catch (err) {
+            console.error('Resume failed:', err);
+        }
     }
 
     function switchBrowseTab(tab) {
@@ -1342,7 +1660,9 @@ const Spotify = (function() {
                     <p>No devices found</p>
                     <p class="spotify-hint">Open Spotify on a device to see it here</p>
                     <button class="modal-btn secondary" onclick="Spotify.loadDevices().then(Spotify.renderDeviceModalContent)">Refresh</button>
-                </div>`;
+                </div>
+                <div id="spotifySonosZones"></div>`;
+            loadSonosZones();
             return;
         }
 
@@ -1360,8 +1680,98 @@ const Spotify = (function() {
                     ${device.is_active ? '<div class="spotify-device-active-indicator"></div>' : ''}
                 </div>`;
         });
-        html += `</div>`;
+        html += `</div><div id="spotifySonosZones"></div>`;
         content.innerHTML = html;
+        loadSonosZones();
+    }
+
+    // ===== Sonos Zones =====
+
+    async function loadSonosZones() {
+        try {
+            const resp = await fetch('/api/sonos/zones');
+            if (!resp.ok) return; // Sonos not configured
+            renderSonosZones(await resp.json());
+        } catch (err) {
+            console.error('Failed to load Sonos zones:', err);
+        }
+    }
+
+    function renderSonosZones(zones) {
+        const container = document.getElementById('spotifySonosZones');
+        if (!container || !zones || zones.length === 0) return;
+
+        let html = `<div class="spotify-sonos-header">Sonos</div><div class="spotify-devices-list">`;
+        zones.forEach(zone => {
+            const np = zone.nowPlaying;
+            const playing = np && np.state === 'playing';
+            const track = np && np.title ? `${np.title}${np.artist ? ' - ' + np.artist : ''}` : 'Nothing playing';
+            const coordinator = encodeURIComponent(zone.coordinator);
+            html += `
+                <div class="spotify-sonos-zone">
+                    <div class="spotify-device">
+                        <div class="spotify-device-icon">🔊</div>
+                        <div class="spotify-device-info">
+                            <div class="spotify-device-name">${escapeHtml(zone.name)}</div>
+                            <div class="spotify-device-type">${escapeHtml(track)}</div>
+                        </div>
+                        <button class="spotify-sonos-btn" onclick="Spotify.sonosControl('${coordinator}', '${playing ? 'pause' : 'play'}')">${playing ? '⏸' : '▶'}</button>
+                    </div>`;
+            zone.rooms.forEach(room => {
+                const name = encodeURIComponent(room.name);
+                const others = zones.filter(z => z.id !== zone.id);
+                let group = '';
+                if (zone.rooms.length > 1) {
+                    group = `<button class="spotify-sonos-btn" onclick="Spotify.sonosLeave('${name}')">Ungroup</button>`;
+                } else if (others.length > 0) {
+                    group = `<select class="spotify-sonos-join" onchange="Spotify.sonosJoin('${name}', this.value)">
+                        <option value="">Group with…</option>
+                        ${others.map(z => `<option value="${escapeHtml(z.coordinator)}">${escapeHtml(z.name)}</option>`).join('')}
+                    </select>`;
+                }
+                html += `
+                    <div class="spotify-sonos-room">
+                        <span class="spotify-sonos-room-name">${escapeHtml(room.name)}</span>
+                        <input type="range" min="0" max="100" value="${room.volume}" onchange="Spotify.sonosVolume('${name}', this.value)">
+                        ${group}
+                    </div>`;
+            });
+            html += `</div>`;
+        });
+        html += `</div>`;
+        container.innerHTML = html;
+    }
+
+    async function sonosRequest(url, method, body) {
+        try {
+            const resp = await fetch(url, {
+                method,
+                headers: { 'Content-Type': 'application/json' },
+                body: body ? JSON.stringify(body) : undefined
+            });
+            if (resp.ok) {
+                renderSonosZones(await resp.json());
+            }
+        } catch (err) {
+            console.error('Sonos request failed:', err);
+        }
+    }
+
+    function sonosControl(room, action) {
+        return sonosRequest(`/api/sonos/rooms/${room}/${action}`, 'POST');
+    }
+
+    function sonosVolume(room, volume) {
+        return sonosRequest(`/api/sonos/rooms/${room}/volume`, 'PUT', { volume: parseInt(volume, 10) });
+    }
+
+    function sonosJoin(room, coordinator) {
+        if (!coordinator) return;
+        return sonosRequest(`/api/sonos/rooms/${room}/join`, 'POST', { coordinator });
+    }
+
+    function sonosLeave(room) {
+        return sonosRequest(`/api/sonos/rooms/${room}/leave`, 'POST');
     }
 
     // ===== Album Detail Functions =====
@@ -1377,7 +1787,7 @@ const Spotify = (function() {
         browseContent.innerHTML = '<div class="spotify-loading">Loading album...</div>';
 
         try {
-            const resp = await fetch(`/api/spotify/album/${albumId}`);
+            const resp = await fetch(spotifyUrl(`/api/spotify/album/${albumId}`));
             if (resp.ok) {
                 const album = await resp.json();
                 spotifyDetailView = { type: 'album', data: album };
@@ -1395,7 +1805,7 @@ const Spotify = (function() {
 
     async function checkAlbumSaved(albumId) {
         try {
-            const resp = await fetch(`/api/spotify/album/${albumId}/saved`);
+            const resp = await fetch(spotifyUrl(`/api/spotify/album/${albumId}/saved`));
             if (resp.ok) {
                 const data = await resp.json();
                 albumSavedStates[albumId] = data.saved;
@@ -1421,7 +1831,7 @@ const Spotify = (function() {
     async function toggleAlbumSaved(albumId) {
         const isSaved = albumSavedStates[albumId] || false;
         try {
-            const resp = await fetch(`/api/spotify/album/${albumId}/save`, {
+            const resp = await fetch(spotifyUrl(`/api/spotify/album/${albumId}/save`), {
                 method: isSaved ? 'DELETE' : 'PUT'
             });
             if (resp.ok || resp.status === 204) {
@@ -1447,9 +1857,9 @@ const Spotify = (function() {
 
         try {
             const [artistResp, albumsResp, topTracksResp] = await Promise.all([
-                fetch(`/api/spotify/artist/${artistId}`),
-                fetch(`/api/spotify/artist/${artistId}/albums?limit=30`),
-                fetch(`/api/spotify/artist/${artistId}/top-tracks`)
+                fetch(spotifyUrl(`/api/spotify/artist/${artistId}`)),
+                fetch(spotifyUrl(`/api/spotify/artist/${artistId}/albums?limit=30`)),
+                fetch(spotifyUrl(`/api/spotify/artist/${artistId}/top-tracks`))
             ]);
 
             if (artistResp.ok && albumsResp.ok && topTracksResp.ok) {
@@ -1478,7 +1888,7 @@ const Spotify = (function() {
 
     async function checkArtistFollowing(artistId) {
         try {
-            const resp = await fetch(`/api/spotify/artist/${artistId}/following`);
+            const resp = await fetch(spotifyUrl(`/api/spotify/artist/${artistId}/following`));
             if (resp.ok) {
                 const data = await resp.json();
                 artistFollowingStates[artistId] = data.following;
@@ -1504,7 +1914,7 @@ const Spotify = (function() {
     async function toggleArtistFollow(artistId) {
         const isFollowing = artistFollowingStates[artistId] || false;
         try {
-            const resp = await fetch(`/api/spotify/artist/${artistId}/follow`, {
+            const resp = await fetch(spotifyUrl(`/api/spotify/artist/${artistId}/follow`), {
                 method: isFollowing ? 'DELETE' : 'PUT'
             });
             if (resp.ok || resp.status === 204) {
@@ -1522,7 +1932,7 @@ const Spotify = (function() {
         const endpoint = spotifyPlayback && spotifyPlayback.is_playing ? '/api/spotify/pause' : '/api/spotify/play';
         const deviceId = spotifyPlayback?.device?.id || '';
         try {
-            const resp = await fetch(endpoint, {
+            const resp = await fetch(spotifyUrl(endpoint), {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ device_id: deviceId })
@@ -1540,7 +1950,7 @@ const Spotify = (function() {
     async function next() {
         try {
             const deviceId = spotifyPlayback?.device?.id || '';
-            const resp = await fetch('/api/spotify/next', {
+            const resp = await fetch(spotifyUrl('/api/spotify/next'), {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ device_id: deviceId })
@@ -1549,10 +1959,7 @@ const Spotify = (function() {
                 const text = await resp.text();
                 console.error('Next failed:', resp.status, text);
             }
-            // Poll a few times to catch the track change
-            setTimeout(loadSpotifyPlayback, 300);
-            setTimeout(loadSpotifyPlayback, 800);
-            setTimeout(loadSpotifyPlayback, 1500);
+            // The server polls fast after a skip and pushes the track change
         } catch (err) {
             console.error('Next failed:', err);
         }
@@ -1561,7 +1968,7 @@ const Spotify = (function() {
     async function previous() {
         try {
             const deviceId = spotifyPlayback?.device?.id || '';
-            const resp = await fetch('/api/spotify/previous', {
+            const resp = await fetch(spotifyUrl('/api/spotify/previous'), {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ device_id: deviceId })
@@ -1570,10 +1977,7 @@ const Spotify = (function() {
                 const text = await resp.text();
                 console.error('Previous failed:', resp.status, text);
             }
-            // Poll a few times to catch the track change
-            setTimeout(loadSpotifyPlayback, 300);
-            setTimeout(loadSpotifyPlayback, 800);
-            setTimeout(loadSpotifyPlayback, 1500);
+            // The server polls fast after a skip and pushes the track change
         } catch (err) {
             console.error('Previous failed:', err);
         }
@@ -1660,12 +2064,19 @@ const Spotify = (function() {
                 spotifyPlayback.device.volume_percent = parseInt(volume);
             }
 
-            await fetch('/api/spotify/volume', {
+            const resp = await fetch(spotifyUrl('/api/spotify/volume'), {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ volume_percent: parseInt(volume) })
             });
 
+            // Quiet hours may have capped the volume; show what was actually set
+            const capped = resp.headers.get('X-Quiet-Hours-Volume');
+            if (capped !== null) {
+                const until = new Date(resp.headers.get('X-Quiet-Hours-Until'));
+                applyQuietHoursCap(parseInt(capped), until);
+            }
+
             // Keep adjusting flag for a bit to prevent poll overwrite
             setTimeout(() => {
                 isAdjustingVolume = false;
@@ -1676,10 +2087,25 @@ const Spotify = (function() {
         }
     }
 
+    function applyQuietHoursCap(volume, until) {
+        if (spotifyPlayback && spotifyPlayback.device) {
+            spotifyPlayback.device.volume_percent = volume;
+        }
+        const title = `Quiet hours: volume limited to ${volume}% until ${until.toLocaleTimeString([], { hour: 'numeric', minute: '2-digit' })}`;
+        document.querySelectorAll('.spotify-volume-slider, #spotifyMiniVolumeSlider').forEach(slider => {
+            slider.value = volume;
+            slider.title = title;
+        });
+        document.querySelectorAll('.spotify-volume-value').forEach(el => {
+            el.textContent = `${volume}%`;
+        });
+        updateVolumeIcons(volume);
+    }
+
     async function toggleShuffle() {
         const newState = !spotifyPlayback.shuffle_state;
         try {
-            await fetch('/api/spotify/shuffle', {
+            await fetch(spotifyUrl('/api/spotify/shuffle'), {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ state: newState })
@@ -1695,7 +2121,7 @@ const Spotify = (function() {
         const currentIndex = states.indexOf(spotifyPlayback.repeat_state || 'off');
         const newState = states[(currentIndex + 1) % states.length];
         try {
-            await fetch('/api/spotify/repeat', {
+            await fetch(spotifyUrl('/api/spotify/repeat'), {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ state: newState })
@@ -1715,7 +2141,7 @@ const Spotify = (function() {
         const positionMs = Math.floor(percent * spotifyPlayback.item.duration_ms);
 
         try {
-            await fetch('/api/spotify/seek', {
+            await fetch(spotifyUrl('/api/spotify/seek'), {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ position_ms: positionMs })
@@ -1734,7 +2160,7 @@ const Spotify = (function() {
             const hasPendingPlay = !!pendingPlayUri;
 
             // Transfer to device - don't auto-play if we have a pending URI to play
-            await fetch('/api/spotify/transfer', {
+            await fetch(spotifyUrl('/api/spotify/transfer'), {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ device_id: deviceId, play: !hasPendingPlay })
@@ -1752,14 +2178,14 @@ const Spotify = (function() {
                 setTimeout(async () => {
                     // Enable shuffle if it was a shuffle play
                     if (shouldShuffle) {
-                        await fetch('/api/spotify/shuffle', {
+                        await fetch(spotifyUrl('/api/spotify/shuffle'), {
                             method: 'POST',
                             headers: { 'Content-Type': 'application/json' },
                             body: JSON.stringify({ state: true })
                         });
                     }
 
-                    await fetch('/api/spotify/play', {
+                    await fetch(spotifyUrl('/api/spotify/play'), {
                         method: 'POST',
                         headers: { 'Content-Type': 'application/json' },
                         body: JSON.stringify({ uri: uriToPlay, device_id: deviceId, position: positionToPlay })
@@ -1781,7 +2207,7 @@ const Spotify = (function() {
     async function playUri(uri) {
         try {
             // Always fetch fresh device list before playing
-            const devicesResp = await fetch('/api/spotify/devices');
+            const devicesResp = await fetch(spotifyUrl('/api/spotify/devices'));
             if (!devicesResp.ok) {
                 console.error('Failed to fetch devices');
                 return;
@@ -1800,7 +2226,7 @@ const Spotify = (function() {
             }
 
             // Active device exists, proceed with play
-            const resp = await fetch('/api/spotify/play', {
+            const resp = await fetch(spotifyUrl('/api/spotify/play'), {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ uri: uri })
@@ -1822,7 +2248,7 @@ const Spotify = (function() {
     async function playAlbumTrack(albumUri, trackPosition) {
         try {
             // Always fetch fresh device list before playing
-            const devicesResp = await fetch('/api/spotify/devices');
+            const devicesResp = await fetch(spotifyUrl('/api/spotify/devices'));
             if (!devicesResp.ok) {
                 console.error('Failed to fetch devices');
                 return;
@@ -1842,7 +2268,7 @@ const Spotify = (function() {
             }
 
             // Active device exists, proceed with play
-            const resp = await fetch('/api/spotify/play', {
+            const resp = await fetch(spotifyUrl('/api/spotify/play'), {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ uri: albumUri, position: trackPosition })
@@ -1863,7 +2289,7 @@ const Spotify = (function() {
     async function shufflePlay(uri) {
         try {
             // Always fetch fresh device list before playing
-            const devicesResp = await fetch('/api/spotify/devices');
+            const devicesResp = await fetch(spotifyUrl('/api/spotify/devices'));
             if (!devicesResp.ok) {
                 console.error('Failed to fetch devices');
                 return;
@@ -1883,14 +2309,14 @@ const Spotify = (function() {
             }
 
             // Enable shuffle first
-            await fetch('/api/spotify/shuffle', {
+            await fetch(spotifyUrl('/api/spotify/shuffle'), {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ state: true })
             });
 
             // Then play (skip device check since we just checked)
-            const resp = await fetch('/api/spotify/play', {
+            const resp = await fetch(spotifyUrl('/api/spotify/play'), {
                 method: 'POST',
                 headers: { 'Content-Type': 'application/json' },
                 body: JSON.stringify({ uri: uri })
@@ -1918,7 +2344,7 @@ const Spotify = (function() {
         content.innerHTML = '<div class="spotify-loading">Loading tracks...</div>';
 
         try {
-            const resp = await fetch(`/api/spotify/playlist/${playlistId}/tracks?limit=50`);
+            const resp = await fetch(spotifyUrl(`/api/spotify/playlist/${playlistId}/tracks?limit=50`));
             if (resp.ok) {
                 const data = await resp.json();
                 renderPlaylistTracks(data.items, `spotify:playlist:${playlistId}`);
@@ -1995,7 +2421,7 @@ const Spotify = (function() {
         browseContent.innerHTML = '<div class="spotify-loading">Searching...</div>';
 
         try {
-            const resp = await fetch(`/api/spotify/search?q=${encodeURIComponent(query)}&type=track,artist,album,playlist&limit=10`);
+            const resp = await fetch(spotifyUrl(`/api/spotify/search?q=${encodeURIComponent(query)}&type=track,artist,album,playlist&limit=10`));
             if (resp.ok) {
                 const results = await resp.json();
                 renderSearchResults(results, browseContent);
@@ -2213,9 +2639,20 @@ const Spotify = (function() {
 
     function init() {
         checkSpotifyStatus();
-        setInterval(loadSpotifyPlayback, 5000);
         startMiniPlayerProgress();
 
+        // Playback is polled server-side and pushed on change; reload after a
+        // reconnect in case an event was missed
+        window.addEventListener('ws:spotify_playback', (e) => {
+            if (e.detail.account === spotifyAccount) applySpotifyPlayback(e.detail.state || {});
+        });
+        window.addEventListener('ws:connected', () => loadSpotifyPlayback());
+
+        // Queue updates pushed after skips and additions
+        window.addEventListener('ws:spotify_queue', (e) => {
+            if (e.detail.account === spotifyAccount) setSpotifyQueue(e.detail.queue || {});
+        });
+
         // Close modals when clicking outside (on the backdrop)
         document.querySelectorAll('.modal').forEach(modal => {
             modal.addEventListener('click', (e) => {
@@ -2240,6 +2677,8 @@ const Spotify = (function() {
         openLikedSongs,
         openSectionView,
         openShow,
+        resumeEpisode,
+        switchAccount,
         goBackFromDetail,
         togglePlayback,
         next,
@@ -2250,6 +2689,9 @@ const Spotify = (function() {
         onVolumeInput,
         seek,
         playUri,
+        addToQueue,
+        toggleSaveMenu,
+        saveCurrentTrack,
         playAlbumTrack,
         shufflePlay,
         playLikedSongs,
@@ -2267,7 +2709,12 @@ const Spotify = (function() {
         handleMiniPlayerClick,
         loadDevices: loadSpotifyDevices,
         renderDeviceModalContent,
+        sonosControl,
+        sonosVolume,
+        sonosJoin,
+        sonosLeave,
         // Expose for external access
+        describeItem,
         getPlayback: () => spotifyPlayback
     };
 })();
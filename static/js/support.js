@@ -0,0 +1,96 @@
+/**
+ * Remote Support Module
+ * Shows the tablet's screen, device metrics and client-reported errors.
+ */
+const Support = (function() {
+    function fact(label, value) {
+        if (value === undefined || value === null || value === '') return '';
+        return `<dt>${escapeHtml(label)}</dt><dd>${escapeHtml(String(value))}</dd>`;
+    }
+
+    function formatTime(value) {
+        if (!value || value.startsWith('0001-')) return '';
+        return new Date(value).toLocaleString();
+    }
+
+    function renderDevice(data) {
+        const el = document.getElementById('supportDevice');
+        const status = data.status || {};
+        const metrics = data.metrics || {};
+        const sensors = data.sensors || {};
+
+        let html = '';
+        if (data.configured) {
+            html += fact('Connected', status.connected ? 'Yes' : 'No');
+            html += fact('Screen', status.screenOn ? 'On' : 'Off');
+            html += fact('Battery', status.batteryLevel !== undefined ?
+                status.batteryLevel + '%' + (status.batteryCharging ? ' (charging)' : '') : '');
+            html += fact('Brightness', status.brightness);
+            html += fact('Foreground app', metrics.foregroundApp);
+            html += fact('Uptime', metrics.uptime);
+            if (metrics.memTotalKb) {
+                html += fact('Memory free', Math.round(metrics.memAvailableKb / 1024) + ' / ' +
+                    Math.round(metrics.memTotalKb / 1024) + ' MB');
+            }
+            html += fact('Wi-Fi RSSI', metrics.wifiRssi ? metrics.wifiRssi + ' dBm' : '');
+            html += fact('Status error', data.statusError);
+            html += fact('Metrics error', data.metricsError);
+        }
+        html += fact('Light level', sensors.lightLevel !== undefined ? sensors.lightLevel + ' lux' : '');
+        html += fact('Proximity', sensors.proximityNear ? 'Near' : 'Far');
+        html += fact('Last sensor update', formatTime(sensors.lastLightAt));
+        el.innerHTML = html;
+    }
+
+    function renderClients(clients) {
+        const el = document.getElementById('supportClients');
+        if (!clients || clients.length === 0) {
+            el.innerHTML = '<p class="support-hint">No clients have reported yet.</p>';
+            return;
+        }
+
+        el.innerHTML = clients.map(c => {
+            const errors = (c.errors || []).slice().reverse().map(e => `
+                <li>
+                    <span class="support-error-time">${escapeHtml(formatTime(e.time))}</span>
+                    ${escapeHtml(e.message)}${e.source ? ' (' + escapeHtml(e.source) + ':' + e.line + ')' : ''}
+                </li>`).join('');
+            return `
+                <div class="support-client">
+                    <dl class="support-facts">
+                        ${fact('Device', c.device)}
+                        ${fact('Page', c.page)}
+                        ${fact('Viewport', c.viewport)}
+                        ${fact('Last seen', formatTime(c.lastSeen))}
+                        ${fact('User agent', c.userAgent)}
+                    </dl>
+                    ${errors ? `<ul class="support-errors">${errors}</ul>` : '<p class="support-hint">No errors reported.</p>'}
+                </div>`;
+        }).join('');
+    }
+
+    function refreshScreenshot() {
+        const img = document.getElementById('supportScreenshot');
+        if (!img) return;
+        img.src = '/api/tablet/screenshot?t=' + Date.now();
+        document.getElementById('supportScreenshotTime').textContent =
+            'Captured ' + new Date().toLocaleTimeString();
+    }
+
+    async function refresh() {
+        refreshScreenshot();
+        try {
+            const resp = await fetch('/api/tablet/support');
+            if (!resp.ok) throw new Error(await resp.text());
+            const data = await resp.json();
+            renderDevice(data);
+            renderClients(data.clients);
+        } catch (err) {
+            console.error('Failed to load support info:', err);
+        }
+    }
+
+    document.addEventListener('DOMContentLoaded', refresh);
+
+    return { refresh };
+})();
@@ -10,3 +10,68 @@ function escapeHtml(text) {
     div.textContent = text;
     return div.innerHTML;
 }
+
+/**
+ * Read a cookie value by name
+ * @param {string} name - The cookie name
+ * @returns {string} - The cookie value, or empty string if not set
+ */
+function getCookie(name) {
+    const match = document.cookie.split('; ').find(row => row.startsWith(name + '='));
+    return match ? decodeURIComponent(match.split('=')[1]) : '';
+}
+
+/**
+ * Get a stable ID for this browser/kiosk (used for per-device favorites)
+ * @returns {string} - The device ID
+ */
+function getDeviceId() {
+    let id = localStorage.getItem('deviceId');
+    if (!id) {
+        id = 'dev-' + Math.random().toString(36).slice(2, 10);
+        localStorage.setItem('deviceId', id);
+    }
+    return id;
+}
+
+/**
+ * Check whether a feature flag is enabled for this device.
+ * Flags are fetched once per page load from /api/flags.
+ * @param {string} name - The flag name
+ * @returns {Promise<boolean>} - True if enabled
+ */
+const isFeatureEnabled = (function() {
+    let flagsPromise = null;
+
+    return async function(name) {
+        if (!flagsPromise) {
+            flagsPromise = fetch('/api/flags')
+                .then(resp => resp.ok ? resp.json() : { flags: {} })
+                .then(data => data.flags || {})
+                .catch(() => ({}));
+        }
+        const flags = await flagsPromise;
+        return flags[name] === true;
+    };
+})();
+
+// Attach the device ID to same-origin requests, and the CSRF token to state-changing ones
+(function() {
+    const originalFetch = window.fetch;
+    const safeMethods = ['GET', 'HEAD', 'OPTIONS'];
+
+    window.fetch = function(input, init = {}) {
+        const method = (init.method || (input instanceof Request ? input.method : 'GET')).toUpperCase();
+        const url = new URL(input instanceof Request ? input.url : input, window.location.href);
+
+        if (url.origin === window.location.origin) {
+            const headers = new Headers(init.headers || (input instanceof Request ? input.headers : undefined));
+            headers.set('X-Device-ID', getDeviceId());
+            if (!safeMethods.includes(method)) {
+                headers.set('X-CSRF-Token', getCookie('csrf_token'));
+            }
+            init = { ...init, headers };
+        }
+        return originalFetch.call(this, input, init);
+    };
+})();
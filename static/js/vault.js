@@ -0,0 +1,209 @@
+/**
+ * Document Vault Module
+ * Household documents (insurance cards, warranties) behind a PIN. Opened from
+ * Settings; unlocking lasts a few minutes and closing the vault locks it again.
+ * Uploading and deleting need an admin client (API token or client certificate).
+ */
+const DocumentVault = (function() {
+    let token = '';
+    let pin = '';
+    let lockTimer = null;
+
+    function getPanel() {
+        let panel = document.getElementById('vaultPanel');
+        if (!panel) {
+            panel = document.createElement('div');
+            panel.id = 'vaultPanel';
+            panel.className = 'vault-panel';
+            panel.innerHTML = '<div class="vault-content"><div class="vault-header">' +
+                '<span>Documents</span><button type="button" class="modal-close-btn">&times;</button>' +
+                '</div><div class="vault-body"></div></div>';
+            panel.querySelector('.modal-close-btn').addEventListener('click', close);
+            document.body.appendChild(panel);
+        }
+        return panel;
+    }
+
+    function getBody() {
+        return getPanel().querySelector('.vault-body');
+    }
+
+    function headers() {
+        return token ? { 'X-Vault-Token': token } : {};
+    }
+
+    function renderPinPad(error) {
+        pin = '';
+        const body = getBody();
+        body.innerHTML = '<div class="lock-pin-display"></div><div class="lock-pin-error"></div>' +
+            '<div class="lock-pin-pad"></div>';
+        body.querySelector('.lock-pin-error').textContent = error || '';
+        const pad = body.querySelector('.lock-pin-pad');
+        ['1', '2', '3', '4', '5', '6', '7', '8', '9', 'clear', '0', 'ok'].forEach(key => {
+            const button = document.createElement('button');
+            button.type = 'button';
+            button.className = 'lock-pin-key' + (key === 'ok' ? ' lock-pin-ok' : '');
+            button.textContent = key === 'clear' ? '⌫' : key === 'ok' ? '✓' : key;
+            button.addEventListener('click', () => pressKey(key));
+            pad.appendChild(button);
+        });
+    }
+
+    function pressKey(key) {
+        if (key === 'ok') {
+            unlock();
+            return;
+        }
+        if (key === 'clear') {
+            pin = pin.slice(0, -1);
+        } else if (pin.length < 12) {
+            pin += key;
+        }
+        getBody().querySelector('.lock-pin-display').textContent = '•'.repeat(pin.length);
+    }
+
+    async function unlock() {
+        if (!pin) return;
+        try {
+            const resp = await fetch('/api/vault/unlock', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ pin })
+            });
+            if (!resp.ok) {
+                renderPinPad((await resp.text()).trim());
+                return;
+            }
+            const data = await resp.json();
+            token = data.token;
+            if (lockTimer) clearTimeout(lockTimer);
+            lockTimer = setTimeout(close, Math.max(new Date(data.expires) - Date.now(), 0));
+            load();
+        } catch (e) {
+            renderPinPad('Could not reach the server');
+        }
+    }
+
+    async function load() {
+        const resp = await fetch('/api/vault', { headers: headers() });
+        if (resp.status === 401) {
+            token = '';
+            renderPinPad();
+            return;
+        }
+        if (!resp.ok) {
+            getBody().textContent = (await resp.text()).trim();
+            return;
+        }
+        renderList(await resp.json());
+    }
+
+    function renderList(items) {
+        const body = getBody();
+        body.innerHTML = '';
+
+        const list = document.createElement('div');
+        list.className = 'vault-list';
+        if (!items.length) {
+            list.textContent = 'No documents yet.';
+        }
+        items.forEach(item => {
+            const row = document.createElement('div');
+            row.className = 'vault-item';
+            const name = document.createElement('button');
+            name.type = 'button';
+            name.className = 'vault-item-name';
+            name.textContent = item.name;
+            name.addEventListener('click', () => view(item));
+            const remove = document.createElement('button');
+            remove.type = 'button';
+            remove.className = 'vault-item-delete';
+            remove.textContent = 'Delete';
+            remove.addEventListener('click', () => removeItem(item));
+            row.appendChild(name);
+            row.appendChild(remove);
+            list.appendChild(row);
+        });
+        body.appendChild(list);
+
+        const upload = document.createElement('form');
+        upload.className = 'vault-upload';
+        upload.innerHTML = '<input type="file" name="file" required>' +
+            '<input type="text" name="name" placeholder="Name (optional)">' +
+            '<button type="submit">Upload</button><div class="vault-error"></div>';
+        upload.addEventListener('submit', e => {
+            e.preventDefault();
+            uploadFile(upload);
+        });
+        body.appendChild(upload);
+    }
+
+    function view(item) {
+        const body = getBody();
+        body.innerHTML = '';
+        const back = document.createElement('button');
+        back.type = 'button';
+        back.className = 'vault-back';
+        back.textContent = '← ' + item.name;
+        back.addEventListener('click', load);
+        body.appendChild(back);
+
+        const src = `/api/vault/${encodeURIComponent(item.id)}?token=${encodeURIComponent(token)}`;
+        const viewer = document.createElement(item.contentType.startsWith('image/') ? 'img' : 'iframe');
+        viewer.className = 'vault-viewer';
+        viewer.src = src;
+        body.appendChild(viewer);
+    }
+
+    async function uploadFile(form) {
+        const error = form.querySelector('.vault-error');
+        error.textContent = '';
+        const resp = await fetch('/api/vault', { method: 'POST', headers: headers(), body: new FormData(form) });
+        if (!resp.ok) {
+            error.textContent = (await resp.text()).trim();
+            return;
+        }
+        load();
+    }
+
+    async function removeItem(item) {
+        if (!confirm(`Delete ${item.name}?`)) return;
+        const resp = await fetch(`/api/vault/${encodeURIComponent(item.id)}`, { method: 'DELETE', headers: headers() });
+        if (!resp.ok) {
+            alert((await resp.text()).trim());
+            return;
+        }
+        load();
+    }
+
+    function open() {
+        if (typeof closeSettings === 'function') closeSettings();
+        getPanel().classList.add('visible');
+        // Admin clients get the list straight away; kiosks see the PIN pad
+        load().catch(() => renderPinPad('Could not reach the server'));
+    }
+
+    function close() {
+        const panel = document.getElementById('vaultPanel');
+        if (panel) {
+            panel.classList.remove('visible');
+            panel.querySelector('.vault-body').innerHTML = '';
+        }
+        if (lockTimer) {
+            clearTimeout(lockTimer);
+            lockTimer = null;
+        }
+        if (token) {
+            fetch('/api/vault/lock', { method: 'POST', headers: headers() }).catch(() => {});
+            token = '';
+        }
+        pin = '';
+    }
+
+    return {
+        open,
+        close
+    };
+})();
+
+function openVault() { DocumentVault.open(); }
@@ -112,8 +112,11 @@ function renderWeatherContent() {
     if (!weatherData) return '<div class="weather-error">Weather data not available</div>';
 
     const current = weatherData.current;
-    const hourly = weatherData.hourly || [];
-    const daily = weatherData.daily || [];
+    // Prefer the hour-by-hour and 10-day sections when the server has them
+    const hourly = weatherData.hourlyForecast || weatherData.hourly || [];
+    const daily = weatherData.extended || weatherData.daily || [];
+    const airQuality = weatherData.airQuality;
+    const pollen = weatherData.pollen ? weatherData.pollen.types.filter(t => t.index > 0) : [];
     const moonPhase = getMoonPhase();
 
     // Format sunrise/sunset times using user's time format preference
@@ -150,6 +153,24 @@ function renderWeatherContent() {
                     <span class="weather-detail-value">${current.clouds}%</span>
                     <span class="weather-detail-label">Clouds</span>
                 </div>
+                ${current.uvi !== undefined && weatherData.uvHourly ? `
+                <div class="weather-detail">
+                    <span class="weather-detail-icon">🧴</span>
+                    <span class="weather-detail-value">${Math.round(current.uvi)}</span>
+                    <span class="weather-detail-label">UV</span>
+                </div>` : ''}
+                ${airQuality ? `
+                <div class="weather-detail" title="${airQuality.category}">
+                    <span class="weather-detail-icon">🌫️</span>
+                    <span class="weather-detail-value">${airQuality.aqi}</span>
+                    <span class="weather-detail-label">AQI</span>
+                </div>` : ''}
+                ${pollen.map(t => `
+                <div class="weather-detail">
+                    <span class="weather-detail-icon">🌼</span>
+                    <span class="weather-detail-value">${t.category}</span>
+                    <span class="weather-detail-label">${t.name}</span>
+                </div>`).join('')}
             </div>
             <div class="weather-sun-moon">
                 <div class="weather-sun"><span class="sun-icon">🌅</span><span class="sun-time">${sunrise}</span></div>
@@ -180,6 +201,7 @@ function renderWeatherContent() {
                     <div class="hourly-icon">${getWeatherEmoji(hour.icon)}</div>
                     <div class="hourly-temp">${Math.round(hour.temp)}°</div>
                     ${hour.pop > 0.1 ? `<div class="hourly-pop">${Math.round(hour.pop * 100)}%</div>` : ''}
+                    ${hour.uvi >= 3 ? `<div class="hourly-uv">UV ${Math.round(hour.uvi)}</div>` : ''}
                 </div>
             `;
         });
@@ -190,7 +212,7 @@ function renderWeatherContent() {
     // Daily forecast
     if (daily.length > 0) {
         html += '<div class="weather-section">';
-        html += '<div class="weather-section-title">5-Day Forecast</div>';
+        html += `<div class="weather-section-title">${daily.length}-Day Forecast</div>`;
         html += '<div class="weather-daily">';
         daily.forEach((day, i) => {
             const date = new Date(day.time * 1000);
@@ -25,6 +25,17 @@ const WS = (function() {
         };
     }
 
+    // Kiosk name for targeted events (e.g. arrival cameras). Set once with
+    // ?kiosk=hallway on the page URL; it's remembered on the device.
+    function getKioskName() {
+        const fromUrl = new URLSearchParams(window.location.search).get('kiosk');
+        if (fromUrl) {
+            localStorage.setItem('kioskName', fromUrl);
+            return fromUrl;
+        }
+        return localStorage.getItem('kioskName') || '';
+    }
+
     function connect() {
         if (ws && ws.readyState === WebSocket.OPEN) {
             log('Already connected, skipping');
@@ -32,7 +43,9 @@ const WS = (function() {
         }
 
         connectAttempts++;
-        const wsUrl = `${window.location.protocol === 'https:' ? 'wss:' : 'ws:'}//${window.location.host}/ws`;
+        const kiosk = getKioskName();
+        const wsUrl = `${window.location.protocol === 'https:' ? 'wss:' : 'ws:'}//${window.location.host}/ws` +
+            (kiosk ? `?kiosk=${encodeURIComponent(kiosk)}` : '');
         log(`Connecting to ${wsUrl} (attempt ${connectAttempts})`);
 
         try {
@@ -145,7 +158,8 @@ const WS = (function() {
     return {
         init,
         connect,
-        getStatus
+        getStatus,
+        getKioskName
     };
 })();
 